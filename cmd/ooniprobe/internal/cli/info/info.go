@@ -5,6 +5,10 @@ import (
 	"github.com/apex/log"
 	"github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/cli/root"
 	"github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/ooni"
+	"github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/utils"
+	"github.com/ooni/probe-cli/v3/internal/kvstore"
+	"github.com/ooni/probe-cli/v3/internal/measurementsig"
+	"github.com/ooni/probe-cli/v3/internal/tunnel"
 )
 
 func init() {
@@ -15,13 +19,31 @@ func init() {
 }
 
 type doinfoconfig struct {
-	Logger      log.Interface
-	NewProbeCLI func() (ooni.ProbeCLI, error)
+	Logger           log.Interface
+	NewProbeCLI      func() (ooni.ProbeCLI, error)
+	NewSigner        func(home string) (*measurementsig.Signer, error)
+	ReadTunnelStates func(home string) []*tunnel.State
 }
 
 var defaultconfig = doinfoconfig{
-	Logger:      log.Log,
-	NewProbeCLI: root.NewProbeCLI,
+	Logger:           log.Log,
+	NewProbeCLI:      root.NewProbeCLI,
+	NewSigner:        newSigner,
+	ReadTunnelStates: readTunnelStates,
+}
+
+// newSigner loads the measurement signing key from the engine's kvstore.
+func newSigner(home string) (*measurementsig.Signer, error) {
+	store, err := kvstore.NewFS(utils.EngineDir(home))
+	if err != nil {
+		return nil, err
+	}
+	return measurementsig.NewSigner(store, log.Log)
+}
+
+// readTunnelStates reads the state persisted by the tunnels we started.
+func readTunnelStates(home string) []*tunnel.State {
+	return tunnel.ReadStates(utils.TunnelDir(home))
 }
 
 func doinfo(config doinfoconfig) error {
@@ -32,5 +54,26 @@ func doinfo(config doinfoconfig) error {
 	}
 	config.Logger.WithFields(log.Fields{"path": probeCLI.Home()}).Info("Home")
 	config.Logger.WithFields(log.Fields{"path": probeCLI.TempDir()}).Info("TempDir")
+	if probeCLI.Config().Sharing.SignMeasurements {
+		signer, err := config.NewSigner(probeCLI.Home())
+		if err != nil {
+			config.Logger.Errorf("%s", err)
+			return err
+		}
+		config.Logger.WithFields(log.Fields{
+			"fingerprint": signer.Fingerprint(),
+			"public_key":  signer.EncodedPublicKey(),
+		}).Info("MeasurementSigningKey")
+	}
+	for _, state := range config.ReadTunnelStates(probeCLI.Home()) {
+		config.Logger.WithFields(log.Fields{
+			"name":                state.Name,
+			"starts":              state.Starts,
+			"last_start":          state.LastStart,
+			"last_bootstrap_time": state.LastBootstrapTime,
+			"last_warm_start":     state.LastWarmStart,
+			"last_egress_address": state.LastEgressAddress,
+		}).Info("Tunnel")
+	}
 	return nil
 }
@@ -2,11 +2,20 @@ package info
 
 import (
 	"errors"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/apex/log"
+	"github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/config"
 	"github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/ooni"
 	"github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/oonitest"
+	"github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/utils"
+	"github.com/ooni/probe-cli/v3/internal/kvstore"
+	"github.com/ooni/probe-cli/v3/internal/measurementsig"
+	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/tunnel"
 )
 
 func TestNewProbeCLIFailed(t *testing.T) {
@@ -39,13 +48,35 @@ func TestNewProbeCLIFailed(t *testing.T) {
 func TestSuccess(t *testing.T) {
 	handler := &oonitest.FakeLoggerHandler{}
 	cli := &oonitest.FakeProbeCLI{
+		FakeConfig:  &config.Config{Sharing: config.Sharing{SignMeasurements: true}},
 		FakeHome:    "fakehome",
 		FakeTempDir: "faketempdir",
 	}
-	err := doinfo(doinfoconfig{
+	signer, err := measurementsig.NewSigner(&kvstore.Memory{}, model.DiscardLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = doinfo(doinfoconfig{
 		NewProbeCLI: func() (ooni.ProbeCLI, error) {
 			return cli, nil
 		},
+		NewSigner: func(home string) (*measurementsig.Signer, error) {
+			if home != "fakehome" {
+				t.Fatal("invalid home", home)
+			}
+			return signer, nil
+		},
+		ReadTunnelStates: func(home string) []*tunnel.State {
+			if home != "fakehome" {
+				t.Fatal("invalid home", home)
+			}
+			return []*tunnel.State{{
+				Name:              "tor",
+				Starts:            3,
+				LastBootstrapTime: 2 * time.Second,
+				LastWarmStart:     true,
+			}}
+		},
 		Logger: &log.Logger{
 			Handler: handler,
 			Level:   log.DebugLevel,
@@ -54,7 +85,7 @@ func TestSuccess(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(handler.FakeEntries) != 2 {
+	if len(handler.FakeEntries) != 4 {
 		t.Fatal("invalid number of log entries")
 	}
 	entry := handler.FakeEntries[0]
@@ -77,4 +108,132 @@ func TestSuccess(t *testing.T) {
 	if entry.Fields["path"].(string) != "faketempdir" {
 		t.Fatal("invalid path")
 	}
+	entry = handler.FakeEntries[2]
+	if entry.Level != log.InfoLevel {
+		t.Fatal("invalid log level")
+	}
+	if entry.Message != "MeasurementSigningKey" {
+		t.Fatal("invalid .Message")
+	}
+	if entry.Fields["fingerprint"].(string) != signer.Fingerprint() {
+		t.Fatal("invalid fingerprint")
+	}
+	if entry.Fields["public_key"].(string) != signer.EncodedPublicKey() {
+		t.Fatal("invalid public_key")
+	}
+	entry = handler.FakeEntries[3]
+	if entry.Level != log.InfoLevel {
+		t.Fatal("invalid log level")
+	}
+	if entry.Message != "Tunnel" {
+		t.Fatal("invalid .Message")
+	}
+	if entry.Fields["name"].(string) != "tor" {
+		t.Fatal("invalid name")
+	}
+	if entry.Fields["starts"].(int64) != 3 {
+		t.Fatal("invalid starts")
+	}
+	if entry.Fields["last_warm_start"].(bool) != true {
+		t.Fatal("invalid last_warm_start")
+	}
+}
+
+func TestSuccessWithoutSigningMeasurements(t *testing.T) {
+	handler := &oonitest.FakeLoggerHandler{}
+	cli := &oonitest.FakeProbeCLI{
+		FakeConfig:  &config.Config{},
+		FakeHome:    "fakehome",
+		FakeTempDir: "faketempdir",
+	}
+	err := doinfo(doinfoconfig{
+		NewProbeCLI: func() (ooni.ProbeCLI, error) {
+			return cli, nil
+		},
+		NewSigner: func(home string) (*measurementsig.Signer, error) {
+			panic("should not be called")
+		},
+		ReadTunnelStates: func(home string) []*tunnel.State {
+			return nil
+		},
+		Logger: &log.Logger{
+			Handler: handler,
+			Level:   log.DebugLevel,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(handler.FakeEntries) != 2 {
+		t.Fatal("invalid number of log entries")
+	}
+}
+
+func TestNewSignerFailed(t *testing.T) {
+	expected := errors.New("mocked error")
+	handler := &oonitest.FakeLoggerHandler{}
+	cli := &oonitest.FakeProbeCLI{
+		FakeConfig:  &config.Config{Sharing: config.Sharing{SignMeasurements: true}},
+		FakeHome:    "fakehome",
+		FakeTempDir: "faketempdir",
+	}
+	err := doinfo(doinfoconfig{
+		NewProbeCLI: func() (ooni.ProbeCLI, error) {
+			return cli, nil
+		},
+		NewSigner: func(home string) (*measurementsig.Signer, error) {
+			return nil, expected
+		},
+		Logger: &log.Logger{
+			Handler: handler,
+			Level:   log.DebugLevel,
+		},
+	})
+	if !errors.Is(err, expected) {
+		t.Fatalf("not the error we expected: %+v", err)
+	}
+	if len(handler.FakeEntries) != 3 {
+		t.Fatal("invalid number of log entries")
+	}
+	entry := handler.FakeEntries[2]
+	if entry.Level != log.ErrorLevel {
+		t.Fatal("invalid log level")
+	}
+	if entry.Message != "mocked error" {
+		t.Fatal("invalid .Message")
+	}
+}
+
+func TestNewSigner(t *testing.T) {
+	home := t.TempDir()
+	first, err := newSigner(home)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := newSigner(home)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Fingerprint() != second.Fingerprint() {
+		t.Fatal("expected to load the same key")
+	}
+}
+
+func TestReadTunnelStates(t *testing.T) {
+	home := t.TempDir()
+	if states := readTunnelStates(home); len(states) != 0 {
+		t.Fatal("expected no states", states)
+	}
+	dir := filepath.Join(utils.TunnelDir(home), "tor")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	data := []byte(`{"Name":"tor","Starts":1}`)
+	if err := os.WriteFile(filepath.Join(dir, "state.json"), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	states := readTunnelStates(home)
+	if len(states) != 1 || states[0].Name != "tor" || states[0].Starts != 1 {
+		t.Fatal("unexpected states", states)
+	}
 }
@@ -1,12 +1,16 @@
 package list
 
 import (
+	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/alecthomas/kingpin/v2"
 	"github.com/apex/log"
 	"github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/cli/root"
+	"github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/nettests"
 	"github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/output"
+	"github.com/ooni/probe-cli/v3/internal/model"
 )
 
 func init() {
@@ -91,45 +95,50 @@ func init() {
 			}
 			resultSummary := output.ResultSummaryData{}
 			netCount := make(map[uint]int)
+			var builtinResults []model.DatabaseResultNetwork
+			oonirunResults := make(map[int64][]model.DatabaseResultNetwork)
+			for _, result := range doneResults {
+				if result.OONIRunSubscriptionID.Valid {
+					id := result.OONIRunSubscriptionID.Int64
+					oonirunResults[id] = append(oonirunResults[id], result)
+					continue
+				}
+				builtinResults = append(builtinResults, result)
+			}
 			output.SectionTitle("Results")
-			for idx, result := range doneResults {
-				testKeys := "{}"
-
-				// We only care to expose in the testKeys the value of the ndt test result
-				if result.TestGroupName == "performance" {
-					// The test_keys column are concanetated with the "|" character as a separator.
-					// We consider this to be safe since we only really care about values of the
-					// performance test_keys where the values are all numbers and none of the keys
-					// contain the "|" character.
-					for _, e := range strings.Split(result.TestKeys, "|") {
-						// We use the presence of the "download" key to indicate we have found the
-						// ndt test_keys, since the dash result does not contain it.
-						if strings.Contains(e, "download") {
-							testKeys = e
-						}
+			listDoneResults(builtinResults, &resultSummary, netCount)
+			if len(oonirunResults) > 0 {
+				subs, err := probeCLI.DB().ListOONIRunSubscriptions()
+				if err != nil {
+					log.WithError(err).Error("failed to list OONI Run subscriptions")
+					return err
+				}
+				for _, sub := range subs {
+					results, found := oonirunResults[sub.ID]
+					if !found {
+						continue
 					}
+					delete(oonirunResults, sub.ID)
+					name := sub.URL
+					if descriptor, err := nettests.ParseOONIRunDescriptor(sub.Descriptor); err == nil && descriptor.Name != "" {
+						name = descriptor.Name
+					}
+					output.SectionTitle(fmt.Sprintf("OONI Run: %s (%s)", name, sub.URL))
+					listDoneResults(results, &resultSummary, netCount)
+				}
+				// the remaining results belong to subscriptions that no longer exist
+				var orphanResults []model.DatabaseResultNetwork
+				for _, results := range oonirunResults {
+					orphanResults = append(orphanResults, results...)
+				}
+				if len(orphanResults) > 0 {
+					// the map iteration order is random, so we sort by result ID
+					sort.SliceStable(orphanResults, func(i, j int) bool {
+						return orphanResults[i].DatabaseResult.ID < orphanResults[j].DatabaseResult.ID
+					})
+					output.SectionTitle("OONI Run: unsubscribed links")
+					listDoneResults(orphanResults, &resultSummary, netCount)
 				}
-
-				output.ResultItem(output.ResultItemData{
-					ID:                      result.DatabaseResult.ID,
-					Index:                   idx,
-					TotalCount:              len(doneResults),
-					Name:                    result.TestGroupName,
-					StartTime:               result.StartTime,
-					NetworkName:             result.DatabaseNetwork.NetworkName,
-					Country:                 result.DatabaseNetwork.CountryCode,
-					ASN:                     result.DatabaseNetwork.ASN,
-					TestKeys:                testKeys,
-					MeasurementCount:        result.TotalCount,
-					MeasurementAnomalyCount: result.AnomalyCount,
-					Done:                    result.IsDone,
-					DataUsageUp:             result.DataUsageUp,
-					DataUsageDown:           result.DataUsageDown,
-				})
-				resultSummary.TotalTests++
-				netCount[result.DatabaseNetwork.ASN]++
-				resultSummary.TotalDataUsageUp += result.DataUsageUp
-				resultSummary.TotalDataUsageDown += result.DataUsageDown
 			}
 			resultSummary.TotalNetworks = int64(len(netCount))
 			output.ResultSummary(resultSummary)
@@ -137,3 +146,48 @@ func init() {
 		return nil
 	})
 }
+
+// listDoneResults prints the given done results and updates the summary and the
+// count of results per network accordingly.
+func listDoneResults(doneResults []model.DatabaseResultNetwork,
+	resultSummary *output.ResultSummaryData, netCount map[uint]int) {
+	for idx, result := range doneResults {
+		testKeys := "{}"
+
+		// We only care to expose in the testKeys the value of the ndt test result
+		if result.TestGroupName == "performance" {
+			// The test_keys column are concanetated with the "|" character as a separator.
+			// We consider this to be safe since we only really care about values of the
+			// performance test_keys where the values are all numbers and none of the keys
+			// contain the "|" character.
+			for _, e := range strings.Split(result.TestKeys, "|") {
+				// We use the presence of the "download" key to indicate we have found the
+				// ndt test_keys, since the dash result does not contain it.
+				if strings.Contains(e, "download") {
+					testKeys = e
+				}
+			}
+		}
+
+		output.ResultItem(output.ResultItemData{
+			ID:                      result.DatabaseResult.ID,
+			Index:                   idx,
+			TotalCount:              len(doneResults),
+			Name:                    result.TestGroupName,
+			StartTime:               result.StartTime,
+			NetworkName:             result.DatabaseNetwork.NetworkName,
+			Country:                 result.DatabaseNetwork.CountryCode,
+			ASN:                     result.DatabaseNetwork.ASN,
+			TestKeys:                testKeys,
+			MeasurementCount:        result.TotalCount,
+			MeasurementAnomalyCount: result.AnomalyCount,
+			Done:                    result.IsDone,
+			DataUsageUp:             result.DataUsageUp,
+			DataUsageDown:           result.DataUsageDown,
+		})
+		resultSummary.TotalTests++
+		netCount[result.DatabaseNetwork.ASN]++
+		resultSummary.TotalDataUsageUp += result.DataUsageUp
+		resultSummary.TotalDataUsageDown += result.DataUsageDown
+	}
+}
@@ -0,0 +1,282 @@
+package oonirun
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/apex/log"
+	"github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/cli/root"
+	"github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/nettests"
+	"github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/ooni"
+	"github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/output"
+	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/oonirun"
+	"github.com/upper/db/v4"
+)
+
+// errNoSuchSubscription indicates that we cannot find a subscription.
+var errNoSuchSubscription = errors.New("subscription not found")
+
+// confirm asks the user to confirm the given question unless skipInteractive is true.
+func confirm(message string, skipInteractive bool) bool {
+	if skipInteractive {
+		return true
+	}
+	answer := ""
+	prompt := &survey.Select{
+		Message: message,
+		Options: []string{"true", "false"},
+		Default: "false",
+	}
+	_ = survey.AskOne(prompt, &answer, nil) // no error checking: we rely on the default value
+	return answer == "true"
+}
+
+// findSubscription returns the subscription with the given ID or URL.
+func findSubscription(probe *ooni.Probe, idOrURL string) (*model.DatabaseOONIRunSubscription, error) {
+	subs, err := probe.DB().ListOONIRunSubscriptions()
+	if err != nil {
+		return nil, err
+	}
+	id, _ := strconv.ParseInt(idOrURL, 10, 64)
+	for idx := range subs {
+		if subs[idx].ID == id || subs[idx].URL == idOrURL {
+			return &subs[idx], nil
+		}
+	}
+	return nil, errNoSuchSubscription
+}
+
+// subscribe subscribes to the OONI Run v2 link at the given URL.
+func subscribe(probe *ooni.Probe, URL string, skipInteractive bool) error {
+	sess, err := probe.NewSession(context.Background(), model.RunTypeManual)
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+	descriptor, err := oonirun.GetV2DescriptorFromHTTPSURL(
+		context.Background(), sess.DefaultHTTPClient(), sess.Logger(), URL, "")
+	if err != nil {
+		return err
+	}
+	report := oonirun.V2LintDescriptor(context.Background(), descriptor)
+	for _, issue := range report.Issues {
+		log.Warn(issue.String())
+	}
+	if report.Errors() > 0 {
+		return errors.New("the OONI Run descriptor contains errors")
+	}
+	output.Paragraph(oonirun.V2DescriptorDiff(nil, descriptor, URL))
+	if !confirm(fmt.Sprintf("Do you want to subscribe to %s", URL), skipInteractive) {
+		return errors.New("canceled by user")
+	}
+	data, err := json.Marshal(descriptor)
+	if err != nil {
+		return err
+	}
+	sub, err := probe.DB().CreateOONIRunSubscription(URL, string(data))
+	if err != nil {
+		return err
+	}
+	log.Infof("Subscribed to %s as #%d", URL, sub.ID)
+	return nil
+}
+
+// refresh refreshes all the subscriptions and asks the user whether
+// to accept the changes unless skipInteractive is true.
+func refresh(probe *ooni.Probe, skipInteractive bool) error {
+	subs, err := probe.DB().ListOONIRunSubscriptions()
+	if err != nil {
+		return err
+	}
+	sess, err := probe.NewSession(context.Background(), model.RunTypeManual)
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+	for idx := range subs {
+		sub := &subs[idx]
+		diff, err := nettests.RefreshOONIRunSubscription(
+			context.Background(), sess, probe.DB(), sub, false)
+		if err != nil {
+			log.WithError(err).Errorf("failed to refresh subscription #%d", sub.ID)
+			continue
+		}
+		if diff == "" {
+			log.Infof("Subscription #%d (%s) did not change", sub.ID, sub.URL)
+			continue
+		}
+		output.Paragraph(diff)
+		if !confirm(fmt.Sprintf("Do you want to accept the changes to #%d", sub.ID), skipInteractive) {
+			log.Infof("Keeping changes to #%d pending", sub.ID)
+			continue
+		}
+		if err := accept(probe, sub); err != nil {
+			log.WithError(err).Errorf("failed to accept changes to subscription #%d", sub.ID)
+		}
+	}
+	return nil
+}
+
+// accept accepts the pending changes of the given subscription.
+func accept(probe *ooni.Probe, sub *model.DatabaseOONIRunSubscription) error {
+	if sub.PendingDescriptor == "" {
+		return errors.New("no pending changes")
+	}
+	sub.Descriptor, sub.PendingDescriptor = sub.PendingDescriptor, ""
+	return probe.DB().UpdateOONIRunSubscription(sub)
+}
+
+// reject rejects the pending changes of the given subscription.
+func reject(probe *ooni.Probe, sub *model.DatabaseOONIRunSubscription) error {
+	if sub.PendingDescriptor == "" {
+		return errors.New("no pending changes")
+	}
+	sub.PendingDescriptor = ""
+	return probe.DB().UpdateOONIRunSubscription(sub)
+}
+
+// list prints the subscriptions.
+func list(probe *ooni.Probe) error {
+	subs, err := probe.DB().ListOONIRunSubscriptions()
+	if err != nil {
+		return err
+	}
+	output.SectionTitle("OONI Run subscriptions")
+	for _, sub := range subs {
+		name := sub.URL
+		if descriptor, err := nettests.ParseOONIRunDescriptor(sub.Descriptor); err == nil && descriptor.Name != "" {
+			name = descriptor.Name
+		}
+		text := fmt.Sprintf("#%d %s (%s), refreshed at %s", sub.ID, name, sub.URL,
+			sub.RefreshedAt.Local().Format("2006-01-02 15:04"))
+		if sub.PendingDescriptor != "" {
+			text += ", has pending changes"
+		}
+		output.Bullet(text)
+	}
+	return nil
+}
+
+// dryrun shows what running the given OONI Run links and descriptor
+// files would do without running any nettest.
+func dryrun(probe *ooni.Probe, URLs, filenames []string, authFile string) error {
+	sess, err := probe.NewSession(context.Background(), model.RunTypeManual)
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+	config := &oonirun.LinkConfig{
+		AuthFile: authFile,
+		DryRun:   true,
+		KVStore:  sess.KeyValueStore(),
+		Session:  sess,
+	}
+	for _, URL := range URLs {
+		if err := oonirun.NewLinkRunner(config, URL).Run(context.Background()); err != nil {
+			log.WithError(err).Errorf("failed to dry run %s", URL)
+		}
+	}
+	for _, filename := range filenames {
+		descriptor, err := oonirun.V2ReadDescriptorFile(filename)
+		if err != nil {
+			log.WithError(err).Errorf("failed to read %s", filename)
+			continue
+		}
+		if err := oonirun.V2MeasureDescriptor(context.Background(), config, descriptor); err != nil {
+			log.WithError(err).Errorf("failed to dry run %s", filename)
+		}
+	}
+	return nil
+}
+
+func init() {
+	cmd := root.Command("oonirun", "Manage the OONI Run links you subscribed to")
+
+	var probe *ooni.Probe
+	cmd.Action(func(_ *kingpin.ParseContext) error {
+		var err error
+		probe, err = root.Init()
+		if err != nil {
+			log.Errorf("%s", err)
+			return err
+		}
+		return nil
+	})
+
+	subscribeCmd := cmd.Command("subscribe", "Subscribe to an OONI Run v2 link")
+	subscribeYes := subscribeCmd.Flag("yes", "Skip interactive prompt").Bool()
+	subscribeURL := subscribeCmd.Arg("url", "the URL of the OONI Run v2 descriptor").Required().String()
+	subscribeCmd.Action(func(_ *kingpin.ParseContext) error {
+		return subscribe(probe, *subscribeURL, *subscribeYes)
+	})
+
+	unsubscribeCmd := cmd.Command("unsubscribe", "Unsubscribe from an OONI Run v2 link")
+	unsubscribeID := unsubscribeCmd.Arg("id", "the id or URL of the subscription").Required().String()
+	unsubscribeCmd.Action(func(_ *kingpin.ParseContext) error {
+		sub, err := findSubscription(probe, *unsubscribeID)
+		if err != nil {
+			return err
+		}
+		err = probe.DB().DeleteOONIRunSubscription(sub.ID)
+		if err == db.ErrNoMoreRows {
+			return errNoSuchSubscription
+		}
+		return err
+	})
+
+	cmd.Command("list", "List the OONI Run subscriptions").Default().Action(func(_ *kingpin.ParseContext) error {
+		return list(probe)
+	})
+
+	refreshCmd := cmd.Command("refresh", "Refresh the OONI Run subscriptions and review changes")
+	refreshYes := refreshCmd.Flag("yes", "Accept all the changes without prompting").Bool()
+	refreshCmd.Action(func(_ *kingpin.ParseContext) error {
+		return refresh(probe, *refreshYes)
+	})
+
+	acceptCmd := cmd.Command("accept", "Accept the pending changes of a subscription")
+	acceptID := acceptCmd.Arg("id", "the id or URL of the subscription").Required().String()
+	acceptCmd.Action(func(_ *kingpin.ParseContext) error {
+		sub, err := findSubscription(probe, *acceptID)
+		if err != nil {
+			return err
+		}
+		return accept(probe, sub)
+	})
+
+	rejectCmd := cmd.Command("reject", "Reject the pending changes of a subscription")
+	rejectID := rejectCmd.Arg("id", "the id or URL of the subscription").Required().String()
+	rejectCmd.Action(func(_ *kingpin.ParseContext) error {
+		sub, err := findSubscription(probe, *rejectID)
+		if err != nil {
+			return err
+		}
+		return reject(probe, sub)
+	})
+
+	validateCmd := cmd.Command("validate", "Check whether OONI Run v2 descriptors contain errors")
+	validateFiles := validateCmd.Arg("file", "the OONI Run v2 descriptor files").Required().Strings()
+	validateCmd.Action(func(_ *kingpin.ParseContext) error {
+		return oonirun.V2LintFiles(context.Background(), log.Log, *validateFiles, false)
+	})
+
+	lintCmd := cmd.Command("lint", "Check whether OONI Run v2 descriptors contain errors or warnings")
+	lintFiles := lintCmd.Arg("file", "the OONI Run v2 descriptor files").Required().Strings()
+	lintCmd.Action(func(_ *kingpin.ParseContext) error {
+		return oonirun.V2LintFiles(context.Background(), log.Log, *lintFiles, true)
+	})
+
+	dryrunCmd := cmd.Command("dryrun", "Show what running OONI Run links would do without running them")
+	dryrunInputs := dryrunCmd.Flag("input", "the URL of an OONI Run link (may be repeated)").Short('i').Strings()
+	dryrunFiles := dryrunCmd.Flag("input-file", "the path of an OONI Run v2 descriptor (may be repeated)").Short('f').Strings()
+	dryrunAuthFile := dryrunCmd.Flag("bearer-token-file", "file containing a bearer token for fetching OONI Run v2 descriptors").String()
+	dryrunCmd.Action(func(_ *kingpin.ParseContext) error {
+		return dryrun(probe, *dryrunInputs, *dryrunFiles, *dryrunAuthFile)
+	})
+}
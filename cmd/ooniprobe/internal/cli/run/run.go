@@ -1,6 +1,10 @@
 package run
 
 import (
+	"errors"
+	"fmt"
+	"strings"
+
 	"github.com/alecthomas/kingpin/v2"
 	"github.com/apex/log"
 	"github.com/fatih/color"
@@ -60,7 +64,7 @@ func init() {
 	}
 
 	websitesCmd := cmd.Command("websites", "")
-	inputFile := websitesCmd.Flag("input-file", "File containing input URLs").Strings()
+	inputFile := websitesCmd.Flag("input-file", "File containing input URLs, one per line or in the test-lists CSV format").Strings()
 	input := websitesCmd.Flag("input", "Test the specified URL").Strings()
 	websitesCmd.Action(func(_ *kingpin.ParseContext) error {
 		log.Infof("Running %s tests", color.BlueString("websites"))
@@ -73,23 +77,81 @@ func init() {
 		})
 	})
 
+	experimentCmd := cmd.Command("experiment", "Run an arbitrary experiment with custom options")
+	experimentName := experimentCmd.Arg("name", "Name of the experiment to run").Required().String()
+	experimentOptions := experimentCmd.Flag("option", "Set experiment option using the key=value syntax").Strings()
+	experimentInputFile := experimentCmd.Flag("input-file", "File containing inputs").Strings()
+	experimentInput := experimentCmd.Flag("input", "Measure the specified input").Strings()
+	experimentCmd.Action(func(_ *kingpin.ParseContext) error {
+		options, err := parseExperimentOptions(*experimentOptions)
+		if err != nil {
+			log.WithError(err).Error("invalid experiment options")
+			return err
+		}
+		log.Infof("Running %s experiment", color.BlueString(*experimentName))
+		return nettests.RunAdHoc(nettests.RunGroupConfig{
+			Probe:      probe,
+			InputFiles: *experimentInputFile,
+			Inputs:     *experimentInput,
+			RunType:    model.RunTypeManual,
+		}, nettests.AdHoc{
+			ExperimentName: *experimentName,
+			Options:        options,
+		})
+	})
+
 	easyRuns := []string{
 		"im", "performance", "circumvention", "middlebox", "experimental"}
 	for _, name := range easyRuns {
 		cmd.Command(name, "").Action(genRunWithGroupName(name))
 	}
 
+	runOONIRunSubscriptions := func(runType model.RunType) error {
+		log.Infof("Running %s tests", color.BlueString(model.OONIRunGroupName))
+		return nettests.RunOONIRunSubscriptions(nettests.RunGroupConfig{
+			Probe:   probe,
+			RunType: runType,
+		})
+	}
+
+	oonirunCmd := cmd.Command("oonirun", "Run the OONI Run links you subscribed to")
+	oonirunCmd.Action(func(_ *kingpin.ParseContext) error {
+		return runOONIRunSubscriptions(model.RunTypeManual)
+	})
+
 	unattendedCmd := cmd.Command("unattended", "")
 	unattendedCmd.Action(func(_ *kingpin.ParseContext) error {
-		return functionalRun(model.RunTypeTimed, func(name string, gr nettests.Group) bool {
+		if err := functionalRun(model.RunTypeTimed, func(name string, gr nettests.Group) bool {
 			return gr.UnattendedOK
-		})
+		}); err != nil {
+			return err
+		}
+		return runOONIRunSubscriptions(model.RunTypeTimed)
 	})
 
 	allCmd := cmd.Command("all", "").Default()
 	allCmd.Action(func(_ *kingpin.ParseContext) error {
-		return functionalRun(model.RunTypeManual, func(name string, gr nettests.Group) bool {
+		if err := functionalRun(model.RunTypeManual, func(name string, gr nettests.Group) bool {
 			return true
-		})
+		}); err != nil {
+			return err
+		}
+		return runOONIRunSubscriptions(model.RunTypeManual)
 	})
 }
+
+// errInvalidExperimentOption indicates that an experiment option is not a key=value pair.
+var errInvalidExperimentOption = errors.New("experiment option must use the key=value syntax")
+
+// parseExperimentOptions parses the key=value experiment options.
+func parseExperimentOptions(input []string) (map[string]any, error) {
+	output := make(map[string]any)
+	for _, opt := range input {
+		key, value, found := strings.Cut(opt, "=")
+		if !found || key == "" {
+			return nil, fmt.Errorf("%w: %s", errInvalidExperimentOption, opt)
+		}
+		output[key] = value
+	}
+	return output, nil
+}
@@ -0,0 +1,29 @@
+package run
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseExperimentOptions(t *testing.T) {
+	t.Run("with valid options", func(t *testing.T) {
+		options, err := parseExperimentOptions([]string{"SNI=example.com", "Repetitions=10", "Empty="})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect := map[string]any{"SNI": "example.com", "Repetitions": "10", "Empty": ""}
+		if diff := cmp.Diff(expect, options); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("with invalid options", func(t *testing.T) {
+		for _, input := range []string{"SNI", "=value"} {
+			if _, err := parseExperimentOptions([]string{input}); !errors.Is(err, errInvalidExperimentOption) {
+				t.Fatal("unexpected error", input, err)
+			}
+		}
+	})
+}
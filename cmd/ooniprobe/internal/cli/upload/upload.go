@@ -1,17 +1,179 @@
 package upload
 
 import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"os"
+
 	"github.com/alecthomas/kingpin/v2"
 	"github.com/apex/log"
 	"github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/cli/root"
+	"github.com/ooni/probe-cli/v3/internal/model"
 )
 
 func init() {
-	cmd := root.Command("upload", "Upload a specific measurement")
+	cmd := root.Command("upload", "Upload the measurements we could not upload while measuring")
+	resultID := cmd.Arg("id", "the id of the result whose measurements to upload (default: all results)").Int64()
 
 	cmd.Action(func(_ *kingpin.ParseContext) error {
+		probe, err := root.Init()
+		if err != nil {
+			log.Errorf("%s", err)
+			return err
+		}
+		ctx := context.Background()
+		sess, err := probe.NewSession(ctx, model.RunTypeManual)
+		if err != nil {
+			log.WithError(err).Error("Failed to create a measurement session")
+			return err
+		}
+		defer sess.Close()
+		submitter, err := sess.NewSubmitter(ctx)
+		if err != nil {
+			log.WithError(err).Error("Failed to create a submitter")
+			return err
+		}
 		log.Info("Uploading")
-		log.Error("this function is not implemented")
-		return nil
+		count, err := uploadResults(ctx, probe.DB(), submitter, *resultID)
+		log.Infof("Uploaded %d measurements", count)
+		return err
 	})
 }
+
+// resultsDatabase is the database used by [uploadResults].
+type resultsDatabase interface {
+	model.ReadableDatabase
+	model.WritableDatabase
+}
+
+// batchSubmitter is a [model.Submitter] that can also submit several
+// measurements at once using batched and compressed uploads.
+type batchSubmitter interface {
+	model.Submitter
+	SubmitMany(ctx context.Context, ms []*model.Measurement) error
+}
+
+// errResultNotFound indicates that there is no result with the given ID.
+var errResultNotFound = errors.New("result not found")
+
+// uploadResults uploads the measurements of the given result, or of all the results when
+// resultID is zero, which we have saved to disk because we could not upload them while
+// measuring. Returns the number of measurements we uploaded and the first error occurred.
+func uploadResults(ctx context.Context, db resultsDatabase, submitter model.Submitter, resultID int64) (int, error) {
+	done, incomplete, err := db.ListResults()
+	if err != nil {
+		return 0, err
+	}
+	var (
+		count    int
+		found    bool
+		firstErr error
+	)
+	for _, result := range append(done, incomplete...) {
+		if resultID != 0 && result.DatabaseResult.ID != resultID {
+			continue
+		}
+		found = true
+		// the joined query assigns the network_id column to the network, so we need
+		// to fill the result's network ID before updating the result
+		r := result.DatabaseResult
+		r.NetworkID = result.DatabaseNetwork.ID
+		n, err := uploadResult(ctx, db, submitter, &r)
+		count += n
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if !found && resultID != 0 {
+		return 0, errResultNotFound
+	}
+	return count, firstErr
+}
+
+// uploadResult uploads the measurements of the given result we saved to disk.
+func uploadResult(ctx context.Context, db resultsDatabase, submitter model.Submitter, result *model.DatabaseResult) (int, error) {
+	entries, err := db.ListMeasurements(result.ID)
+	if err != nil {
+		return 0, err
+	}
+	var (
+		msmts        []*model.DatabaseMeasurement
+		measurements []*model.Measurement
+	)
+	for _, entry := range entries {
+		msmt := entry.DatabaseMeasurement
+		// the joined query assigns the result_id column to the result, so we need
+		// to fill the measurement's result ID before updating the measurement
+		msmt.ResultID = result.ID
+		if !msmt.IsDone || msmt.IsFailed || msmt.IsUploaded || !msmt.MeasurementFilePath.Valid {
+			continue
+		}
+		measurement, err := readMeasurement(msmt.MeasurementFilePath.String)
+		if err != nil {
+			log.WithError(err).Warnf("cannot read measurement #%d", msmt.ID)
+			continue
+		}
+		// the saved measurement may reference the report we failed to submit it
+		// to, so we clear the report ID, which the submitter sets on success
+		measurement.ReportID = ""
+		msmts = append(msmts, &msmt)
+		measurements = append(measurements, measurement)
+	}
+	if len(measurements) <= 0 {
+		return 0, nil
+	}
+	submitErr := submitAll(ctx, submitter, measurements)
+	count := 0
+	for idx, measurement := range measurements {
+		msmt := msmts[idx]
+		if measurement.ReportID == "" {
+			if submitErr != nil {
+				if err := db.UploadFailed(msmt, submitErr.Error()); err != nil {
+					return count, err
+				}
+			}
+			continue
+		}
+		msmt.ReportID = sql.NullString{String: measurement.ReportID, Valid: true}
+		if err := db.UploadSucceeded(msmt); err != nil {
+			return count, err
+		}
+		count++
+		// we only keep measurements on disk until we upload them
+		if err := os.Remove(msmt.MeasurementFilePath.String); err != nil {
+			log.WithError(err).Warnf("cannot remove %s", msmt.MeasurementFilePath.String)
+		}
+	}
+	if err := db.UpdateUploadedStatus(result); err != nil {
+		return count, err
+	}
+	return count, submitErr
+}
+
+// submitAll submits the given measurements, using batched uploads when possible.
+func submitAll(ctx context.Context, submitter model.Submitter, measurements []*model.Measurement) error {
+	if bsubm, ok := submitter.(batchSubmitter); ok {
+		return bsubm.SubmitMany(ctx, measurements)
+	}
+	for _, measurement := range measurements {
+		if err := submitter.Submit(ctx, measurement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readMeasurement reads the measurement saved at the given path.
+func readMeasurement(filepath string) (*model.Measurement, error) {
+	data, err := os.ReadFile(filepath)
+	if err != nil {
+		return nil, err
+	}
+	var measurement model.Measurement
+	if err := json.Unmarshal(data, &measurement); err != nil {
+		return nil, err
+	}
+	return &measurement, nil
+}
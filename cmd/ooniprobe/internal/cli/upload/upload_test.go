@@ -0,0 +1,177 @@
+package upload
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/ooni/probe-cli/v3/internal/database"
+	"github.com/ooni/probe-cli/v3/internal/engine"
+	"github.com/ooni/probe-cli/v3/internal/fsx"
+	"github.com/ooni/probe-cli/v3/internal/mocks"
+	"github.com/ooni/probe-cli/v3/internal/model"
+)
+
+// batchSubmitterForTesting is a [batchSubmitter] setting the report ID
+// of the measurements or failing with the given error.
+type batchSubmitterForTesting struct {
+	err       error
+	submitted int
+}
+
+func (s *batchSubmitterForTesting) Submit(ctx context.Context, m *model.Measurement) error {
+	panic("should not be called")
+}
+
+func (s *batchSubmitterForTesting) SubmitMany(ctx context.Context, ms []*model.Measurement) error {
+	if s.err != nil {
+		return s.err
+	}
+	for _, m := range ms {
+		m.ReportID = "20240102T100000Z_example_IT_30722_n1_abc"
+		s.submitted++
+	}
+	return nil
+}
+
+// newDatabaseForTesting creates a database containing a result with a measurement
+// we did not upload, a measurement we uploaded, and a failed measurement. This
+// function returns the database, the result, and the not uploaded measurement.
+func newDatabaseForTesting(t *testing.T) (*database.Database, *model.DatabaseResult, *model.DatabaseMeasurement) {
+	dir := t.TempDir()
+	db, err := database.Open(filepath.Join(dir, "main.sqlite3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	network, err := db.CreateNetwork(&mocks.LocationProvider{
+		MockProbeASN:         func() uint { return 30722 },
+		MockProbeCC:          func() string { return "IT" },
+		MockProbeNetworkName: func() string { return "Vodafone Italia S.p.A." },
+		MockProbeIP:          func() string { return "127.0.0.1" },
+		MockProbeIPv4Egress:  func() *model.LocationEgress { return nil },
+		MockProbeIPv6Egress:  func() *model.LocationEgress { return nil },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := db.CreateResult(dir, "websites", network.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	create := func(idx int) *model.DatabaseMeasurement {
+		msmt, err := db.CreateMeasurement(sql.NullString{}, "example", result.MeasurementDir, idx, result.ID, sql.NullInt64{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := engine.SaveMeasurement(&model.Measurement{TestName: "example"}, msmt.MeasurementFilePath.String); err != nil {
+			t.Fatal(err)
+		}
+		return msmt
+	}
+
+	pending := create(0)
+	if err := db.Done(pending); err != nil {
+		t.Fatal(err)
+	}
+
+	uploaded := create(1)
+	if err := db.UploadSucceeded(uploaded); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Done(uploaded); err != nil {
+		t.Fatal(err)
+	}
+
+	failed := create(2)
+	if err := db.Failed(failed, "generic_timeout_error"); err != nil {
+		t.Fatal(err)
+	}
+
+	return db, result, pending
+}
+
+// findMeasurement returns the measurement with the given ID.
+func findMeasurement(t *testing.T, db *database.Database, result *model.DatabaseResult, ID int64) *model.DatabaseMeasurement {
+	entries, err := db.ListMeasurements(result.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if entry.DatabaseMeasurement.ID == ID {
+			return &entry.DatabaseMeasurement
+		}
+	}
+	t.Fatal("cannot find measurement", ID)
+	return nil
+}
+
+func TestUploadResults(t *testing.T) {
+	t.Run("we only upload the measurements saved to disk", func(t *testing.T) {
+		db, result, pending := newDatabaseForTesting(t)
+		submitter := &batchSubmitterForTesting{}
+		count, err := uploadResults(context.Background(), db, submitter, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != 1 || submitter.submitted != 1 {
+			t.Fatal("unexpected number of uploaded measurements", count, submitter.submitted)
+		}
+		msmt := findMeasurement(t, db, result, pending.ID)
+		if !msmt.IsUploaded || msmt.ReportID.String != "20240102T100000Z_example_IT_30722_n1_abc" {
+			t.Fatal("did not mark the measurement as uploaded")
+		}
+		if fsx.RegularFileExists(pending.MeasurementFilePath.String) {
+			t.Fatal("did not remove the uploaded measurement from disk")
+		}
+	})
+
+	t.Run("we record the error when the upload fails", func(t *testing.T) {
+		expected := errors.New("mocked error")
+		db, result, pending := newDatabaseForTesting(t)
+		submitter := &batchSubmitterForTesting{err: expected}
+		count, err := uploadResults(context.Background(), db, submitter, result.ID)
+		if !errors.Is(err, expected) {
+			t.Fatal("unexpected error", err)
+		}
+		if count != 0 {
+			t.Fatal("unexpected number of uploaded measurements", count)
+		}
+		msmt := findMeasurement(t, db, result, pending.ID)
+		if msmt.IsUploaded || msmt.UploadFailureMsg.String != expected.Error() {
+			t.Fatal("did not mark the upload as failed")
+		}
+		if !fsx.RegularFileExists(pending.MeasurementFilePath.String) {
+			t.Fatal("removed the measurement from disk")
+		}
+	})
+
+	t.Run("we submit each measurement without batching support", func(t *testing.T) {
+		db, result, pending := newDatabaseForTesting(t)
+		submitter := &mocks.Submitter{
+			MockSubmit: func(ctx context.Context, m *model.Measurement) error {
+				m.ReportID = "20240102T100000Z_example_IT_30722_n1_def"
+				return nil
+			},
+		}
+		count, err := uploadResults(context.Background(), db, submitter, result.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != 1 {
+			t.Fatal("unexpected number of uploaded measurements", count)
+		}
+		if msmt := findMeasurement(t, db, result, pending.ID); !msmt.IsUploaded {
+			t.Fatal("did not mark the measurement as uploaded")
+		}
+	})
+
+	t.Run("with a nonexistent result", func(t *testing.T) {
+		db, _, _ := newDatabaseForTesting(t)
+		_, err := uploadResults(context.Background(), db, &batchSubmitterForTesting{}, 1234)
+		if !errors.Is(err, errResultNotFound) {
+			t.Fatal("unexpected error", err)
+		}
+	})
+}
@@ -33,6 +33,16 @@ func TestParseConfig(t *testing.T) {
 	if config.Sharing.UploadResults != true {
 		t.Fatal("not the expected value for UploadResults")
 	}
+	if config.Advanced.GeolocationDatabase != "/var/lib/ooniprobe/dbip.mmdb" {
+		t.Fatal("not the expected value for GeolocationDatabase")
+	}
+	override := config.Advanced.GeolocationOverride
+	if override.ProbeASN != 30722 || override.ProbeCC != "IT" || override.ProbeNetworkName != "" {
+		t.Fatal("not the expected value for GeolocationOverride")
+	}
+	if config.Advanced.ProbeServicesURL != "http://127.0.0.1:8080" {
+		t.Fatal("not the expected value for ProbeServicesURL")
+	}
 }
 
 func TestUpdateConfig(t *testing.T) {
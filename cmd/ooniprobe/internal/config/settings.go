@@ -1,16 +1,79 @@
 package config
 
+import "github.com/ooni/probe-cli/v3/internal/model"
+
 // Sharing settings
 type Sharing struct {
 	UploadResults bool `json:"upload_results"`
+
+	// SignMeasurements indicates whether to sign the measurements we submit
+	// using a per-installation key (see `ooniprobe info` for the public key).
+	SignMeasurements bool `json:"sign_measurements,omitempty"`
 }
 
 // Advanced settings
-type Advanced struct{}
+type Advanced struct {
+	// GeolocationDatabase is the path of a MaxMind-compatible MMDB
+	// file to use instead of the embedded geolocation database.
+	GeolocationDatabase string `json:"geolocation_database,omitempty"`
+
+	// GeolocationOverride overrides the probe ASN and country code
+	// for probes behind networks the database does not know about.
+	GeolocationOverride GeolocationOverride `json:"geolocation_override"`
+
+	// ProbeServicesURL is the OPTIONAL URL of the probe services to use
+	// instead of the default ones (e.g., a local oobackend instance).
+	ProbeServicesURL string `json:"probe_services_url,omitempty"`
+
+	// TorColdStart forces the tor tunnel (i.e., --proxy=tor:///) to bootstrap
+	// without reusing the directory information cached by previous runs.
+	TorColdStart bool `json:"tor_cold_start,omitempty"`
+}
+
+// GeolocationOverride contains the geolocation override settings
+type GeolocationOverride struct {
+	// ProbeASN is the ASN to use when not zero.
+	ProbeASN uint `json:"probe_asn,omitempty"`
+
+	// ProbeCC is the country code to use when not empty.
+	ProbeCC string `json:"probe_cc,omitempty"`
+
+	// ProbeNetworkName is the network name to use along with ProbeASN.
+	ProbeNetworkName string `json:"probe_network_name,omitempty"`
+}
 
 // Nettests related settings
 type Nettests struct {
 	WebsitesMaxRuntime           int64    `json:"websites_max_runtime"`
 	WebsitesURLLimit             int64    `json:"websites_url_limit"`
 	WebsitesEnabledCategoryCodes []string `json:"websites_enabled_category_codes"`
+
+	// WebsitesSampling controls how we sample the URLs to measure
+	// such that limited-runtime runs cover the list more evenly.
+	WebsitesSampling WebsitesSampling `json:"websites_sampling"`
+
+	// WebsitesRemoteLists contains signed lists of URLs served by
+	// remote HTTPS servers that we measure instead of the URLs
+	// returned by the check-in API.
+	WebsitesRemoteLists []model.ExperimentTargetRemoteList `json:"websites_remote_lists"`
+}
+
+// WebsitesSampling contains the websites sampling settings
+type WebsitesSampling struct {
+	// Enabled enables sampling the URLs returned by the check-in API.
+	Enabled bool `json:"enabled"`
+
+	// CategoryPriorities maps category codes to weights, where a
+	// missing category has weight one.
+	CategoryPriorities map[string]float64 `json:"category_priorities"`
+
+	// DeduplicateDomains measures URLs with the same domain last.
+	DeduplicateDomains bool `json:"deduplicate_domains"`
+
+	// Seed makes the sampling reproducible when not zero.
+	Seed int64 `json:"seed"`
+
+	// StaleAfterDays is the number of days after which we consider
+	// stale a URL already measured on the current network.
+	StaleAfterDays int64 `json:"stale_after_days"`
 }
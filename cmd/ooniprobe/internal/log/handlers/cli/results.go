@@ -83,6 +83,20 @@ var summarizers = map[string]func(uint64, uint64, string) []string{
 			"",
 		}
 	},
+	"adhoc": func(totalCount uint64, anomalyCount uint64, ss string) []string {
+		return []string{
+			fmt.Sprintf("%d tested", totalCount),
+			fmt.Sprintf("%d anomalies", anomalyCount),
+			"",
+		}
+	},
+	"oonirun": func(totalCount uint64, anomalyCount uint64, ss string) []string {
+		return []string{
+			fmt.Sprintf("%d tested", totalCount),
+			fmt.Sprintf("%d anomalies", anomalyCount),
+			"",
+		}
+	},
 }
 
 func makeSummary(name string, totalCount uint64, anomalyCount uint64, ss string) []string {
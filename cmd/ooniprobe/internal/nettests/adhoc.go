@@ -0,0 +1,62 @@
+package nettests
+
+import (
+	"context"
+
+	"github.com/ooni/probe-cli/v3/internal/model"
+)
+
+// AdHocGroupName is the name of the result group containing the
+// measurements collected by running [AdHoc] nettests.
+const AdHocGroupName = "adhoc"
+
+// AdHoc runs an arbitrary experiment in the registry using
+// user-provided options and inputs. Use [RunAdHoc] to run it.
+type AdHoc struct {
+	// ExperimentName is the name of the experiment to run.
+	ExperimentName string
+
+	// Options contains the experiment options.
+	Options map[string]any
+}
+
+func (n AdHoc) lookupURLs(ctl *Controller, builder model.ExperimentBuilder) ([]model.ExperimentTarget, error) {
+	config := &model.ExperimentTargetLoaderConfig{
+		CheckInConfig: &model.OOAPICheckInConfig{
+			// Setting Charging and OnWiFi to true causes the CheckIn
+			// API to return to us as much URL as possible with the
+			// given RunType hint.
+			Charging: true,
+			OnWiFi:   true,
+			RunType:  ctl.RunType,
+			WebConnectivity: model.OOAPICheckInConfigWebConnectivity{
+				CategoryCodes: ctl.Probe.Config().Nettests.WebsitesEnabledCategoryCodes,
+			},
+		},
+		Session:      ctl.Session,
+		SourceFiles:  ctl.InputFiles,
+		StaticInputs: ctl.Inputs,
+	}
+	targetloader := builder.NewTargetLoader(config)
+	testlist, err := targetloader.Load(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return ctl.BuildAndSetInputIdxMap(testlist)
+}
+
+// Run starts the nettest.
+func (n AdHoc) Run(ctl *Controller) error {
+	builder, err := ctl.Session.NewExperimentBuilder(n.ExperimentName)
+	if err != nil {
+		return err
+	}
+	if err := builder.SetOptionsAny(n.Options); err != nil {
+		return err
+	}
+	urls, err := n.lookupURLs(ctl, builder)
+	if err != nil {
+		return err
+	}
+	return ctl.Run(builder, urls)
+}
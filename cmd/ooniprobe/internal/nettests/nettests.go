@@ -22,7 +22,7 @@ type Nettest interface {
 
 // NewController creates a nettest controller
 func NewController(
-	nt Nettest, probe *ooni.Probe, res *model.DatabaseResult, sess *engine.Session) *Controller {
+	nt Nettest, probe *ooni.Probe, res *model.DatabaseResult, sess Session) *Controller {
 	return &Controller{
 		Probe:   probe,
 		nt:      nt,
@@ -31,11 +31,44 @@ func NewController(
 	}
 }
 
+// Session is the measurement session used by the nettests. The
+// [*engine.Session] type implements this interface.
+type Session interface {
+	model.ExperimentTargetLoaderSession
+	model.LocationProvider
+
+	// DefaultHTTPClient returns the default HTTP client.
+	DefaultHTTPClient() model.HTTPClient
+
+	// KeyValueStore returns the key-value store.
+	KeyValueStore() model.KeyValueStore
+
+	// NewExperimentBuilder creates a builder for the given experiment.
+	NewExperimentBuilder(name string) (model.ExperimentBuilder, error)
+
+	// UserAgent returns the user agent to use.
+	UserAgent() string
+}
+
+var _ Session = &engine.Session{}
+
+// NetworkWatcher detects network changes between inputs. The
+// [*engine.NetworkWatcher] type implements this interface.
+type NetworkWatcher interface {
+	// Due returns whether we should check whether the network changed.
+	Due() bool
+
+	// CheckContext returns whether the network changed.
+	CheckContext(ctx context.Context) (bool, error)
+}
+
+var _ NetworkWatcher = &engine.NetworkWatcher{}
+
 // Controller is passed to the run method of every Nettest
 // each nettest instance has one controller
 type Controller struct {
 	Probe       *ooni.Probe
-	Session     *engine.Session
+	Session     Session
 	res         *model.DatabaseResult
 	nt          Nettest
 	ntCount     int
@@ -57,6 +90,13 @@ type Controller struct {
 	// not set, the underlying code defaults to model.RunTypeTimed.
 	RunType model.RunType
 
+	// NetworkWatcher optionally allows to detect network changes between
+	// inputs. When the network changes, we flag the measurements performed
+	// since the previous check and we continue measuring using a new
+	// result and a new report, such that each result references
+	// the network it has actually been measured from.
+	NetworkWatcher NetworkWatcher
+
 	// numInputs is the total number of inputs
 	numInputs int
 
@@ -133,22 +173,16 @@ func (c *Controller) Run(builder model.ExperimentBuilder, inputs []model.Experim
 	c.msmts = make(map[int64]*model.DatabaseMeasurement)
 
 	// These values are shared by every measurement
-	var reportID sql.NullString
 	resultID := c.res.ID
 
 	log.Debug(color.RedString("status.queued"))
 	log.Debug(color.RedString("status.started"))
 
-	if c.Probe.Config().Sharing.UploadResults {
-		if err := exp.OpenReportContext(context.Background()); err != nil {
-			log.Debugf(
-				"%s: %s", color.RedString("failure.report_create"), err.Error(),
-			)
-		} else {
-			log.Debugf(color.RedString("status.report_create"))
-			reportID = sql.NullString{String: exp.ReportID(), Valid: true}
-		}
-	}
+	reportID := c.maybeOpenReport(exp)
+
+	// unchecked contains the measurements performed since the last
+	// time we checked whether the network changed
+	var unchecked []*model.DatabaseMeasurement
 
 	maxRuntime := time.Duration(c.Probe.Config().Nettests.WebsitesMaxRuntime) * time.Second
 	if c.RunType == model.RunTypeTimed && maxRuntime > 0 {
@@ -175,6 +209,31 @@ func (c *Controller) Run(builder model.ExperimentBuilder, inputs []model.Experim
 			log.Info("exceeded maximum runtime")
 			break
 		}
+		if idx > 0 && c.NetworkWatcher != nil && c.NetworkWatcher.Due() {
+			changed, err := c.NetworkWatcher.CheckContext(context.Background())
+			switch {
+			case err != nil:
+				log.WithError(err).Warn("failed to check whether the network changed")
+			case changed:
+				log.Infof("the network changed, continuing with a new result")
+				for _, m := range unchecked {
+					if err := db.NetworkChanged(m); err != nil {
+						return errors.Wrap(err, "failed to mark measurement as network changed")
+					}
+				}
+				c.res.DataUsageDown += exp.KibiBytesReceived()
+				c.res.DataUsageUp += exp.KibiBytesSent()
+				if err := c.splitResult(); err != nil {
+					return errors.Wrap(err, "failed to split result")
+				}
+				exp = builder.NewExperiment()
+				reportID = c.maybeOpenReport(exp)
+				resultID = c.res.ID
+				unchecked = nil
+			default:
+				unchecked = nil
+			}
+		}
 		c.curInputIdx = idx // allow for precise progress
 		idx64 := int64(idx)
 		log.Debug(color.RedString("status.measurement_start"))
@@ -190,6 +249,7 @@ func (c *Controller) Run(builder model.ExperimentBuilder, inputs []model.Experim
 			return errors.Wrap(err, "failed to create measurement")
 		}
 		c.msmts[idx64] = msmt
+		unchecked = append(unchecked, msmt)
 
 		if input.Input() != "" {
 			c.OnProgress(0, fmt.Sprintf("processing input: %s", input))
@@ -249,6 +309,54 @@ func (c *Controller) Run(builder model.ExperimentBuilder, inputs []model.Experim
 	return err
 }
 
+// maybeOpenReport opens a report using the given experiment if we are
+// uploading results and returns the corresponding report ID.
+func (c *Controller) maybeOpenReport(exp model.Experiment) (reportID sql.NullString) {
+	if !c.Probe.Config().Sharing.UploadResults {
+		return
+	}
+	if err := exp.OpenReportContext(context.Background()); err != nil {
+		log.Debugf(
+			"%s: %s", color.RedString("failure.report_create"), err.Error(),
+		)
+		return
+	}
+	log.Debugf(color.RedString("status.report_create"))
+	return sql.NullString{String: exp.ReportID(), Valid: true}
+}
+
+// splitResult marks the current result as finished and replaces it with a
+// new result referencing the network the session is currently using.
+func (c *Controller) splitResult() error {
+	db := c.Probe.DB()
+	if err := db.UpdateUploadedStatus(c.res); err != nil {
+		return err
+	}
+	if err := removeDirIfEmpty(c.res.MeasurementDir); err != nil {
+		return err
+	}
+	if err := db.Finished(c.res); err != nil {
+		return err
+	}
+	network, err := db.CreateNetwork(c.Session)
+	if err != nil {
+		return err
+	}
+	var result *model.DatabaseResult
+	if c.res.OONIRunSubscriptionID.Valid {
+		result, err = db.CreateOONIRunResult(
+			c.Probe.Home(), c.res.OONIRunSubscriptionID.Int64, network.ID)
+	} else {
+		result, err = db.CreateResult(
+			c.Probe.Home(), c.res.TestGroupName, network.ID)
+	}
+	if err != nil {
+		return err
+	}
+	c.res = result
+	return nil
+}
+
 // OnProgress should be called when a new progress event is available.
 func (c *Controller) OnProgress(perc float64, msg string) {
 	// when we have maxRuntime, honor it
@@ -2,12 +2,16 @@ package nettests
 
 import (
 	"context"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path"
+	"sort"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
 	"github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/ooni"
+	"github.com/ooni/probe-cli/v3/internal/mocks"
 	"github.com/ooni/probe-cli/v3/internal/model"
 )
 
@@ -65,3 +69,303 @@ func TestRun(t *testing.T) {
 	ctl := NewController(nt, probe, res, sess)
 	nt.Run(ctl)
 }
+
+func TestRunAdHocWithInvalidExperimentName(t *testing.T) {
+	probe := newOONIProbe(t)
+	config := RunGroupConfig{
+		Probe:   probe,
+		RunType: model.RunTypeManual,
+	}
+	err := RunAdHoc(config, AdHoc{ExperimentName: "nonexistent"})
+	if err == nil || err.Error() != "invalid experiment name" {
+		t.Fatal("unexpected error", err)
+	}
+}
+
+// sessionForTesting is a [runGroupSession] that does not use the network.
+type sessionForTesting struct {
+	*mocks.Session
+}
+
+func (sess *sessionForTesting) ProbeASN() uint {
+	return 30722
+}
+
+func (sess *sessionForTesting) ProbeIPv4Egress() *model.LocationEgress {
+	return nil
+}
+
+func (sess *sessionForTesting) ProbeIPv6Egress() *model.LocationEgress {
+	return nil
+}
+
+func TestRunAdHoc(t *testing.T) {
+	probe := newOONIProbe(t)
+
+	var (
+		experimentName string
+		options        map[string]any
+	)
+	builder := &mocks.ExperimentBuilder{
+		MockSetOptionsAny: func(value map[string]any) error {
+			options = value
+			return nil
+		},
+		MockNewTargetLoader: func(config *model.ExperimentTargetLoaderConfig) model.ExperimentTargetLoader {
+			return &mocks.ExperimentTargetLoader{
+				MockLoad: func(ctx context.Context) ([]model.ExperimentTarget, error) {
+					var targets []model.ExperimentTarget
+					for _, input := range config.StaticInputs {
+						targets = append(targets, model.NewOOAPIURLInfoWithDefaultCategoryAndCountry(input))
+					}
+					return targets, nil
+				},
+			}
+		},
+		MockSetCallbacks: func(callbacks model.ExperimentCallbacks) {},
+		MockNewExperiment: func() model.Experiment {
+			return &mocks.Experiment{
+				MockKibiBytesReceived: func() float64 {
+					return 0
+				},
+				MockKibiBytesSent: func() float64 {
+					return 0
+				},
+				MockName: func() string {
+					return experimentName
+				},
+				MockOpenReportContext: func(ctx context.Context) error {
+					return nil
+				},
+				MockReportID: func() string {
+					return "report-1"
+				},
+				MockMeasureWithContext: func(ctx context.Context, target model.ExperimentTarget) (*model.Measurement, error) {
+					return &model.Measurement{
+						Input:    model.MeasurementInput(target.Input()),
+						TestName: experimentName,
+					}, nil
+				},
+				MockSubmitAndUpdateMeasurementContext: func(ctx context.Context, measurement *model.Measurement) error {
+					return nil
+				},
+			}
+		},
+	}
+	sess := &sessionForTesting{&mocks.Session{
+		MockClose: func() error {
+			return nil
+		},
+		MockLogger: func() model.Logger {
+			return model.DiscardLogger
+		},
+		MockMaybeLookupBackendsContext: func(ctx context.Context) error {
+			return nil
+		},
+		MockMaybeLookupLocationContext: func(ctx context.Context) error {
+			return nil
+		},
+		MockNewExperimentBuilder: func(name string) (model.ExperimentBuilder, error) {
+			experimentName = name
+			return builder, nil
+		},
+		MockProbeCC: func() string {
+			return "IT"
+		},
+		MockProbeIP: func() string {
+			return "127.0.0.1"
+		},
+		MockProbeNetworkName: func() string {
+			return "Vodafone Italia S.p.A."
+		},
+	}}
+	saved := newRunGroupSession
+	defer func() {
+		newRunGroupSession = saved
+	}()
+	newRunGroupSession = func(probe *ooni.Probe, runType model.RunType) (runGroupSession, NetworkWatcher, error) {
+		return sess, nil, nil
+	}
+
+	config := RunGroupConfig{
+		Inputs:  []string{"https://www.example.com/", "https://www.example.org/"},
+		Probe:   probe,
+		RunType: model.RunTypeManual,
+	}
+	nt := AdHoc{
+		ExperimentName: "example",
+		Options: map[string]any{
+			"Message":   "Good day from the example experiment!",
+			"SleepTime": int64(0),
+		},
+	}
+	if err := RunAdHoc(config, nt); err != nil {
+		t.Fatal(err)
+	}
+
+	if experimentName != "example" {
+		t.Fatal("unexpected experiment name", experimentName)
+	}
+	if diff := cmp.Diff(nt.Options, options); diff != "" {
+		t.Fatal(diff)
+	}
+
+	db := probe.DB()
+	done, incomplete, err := db.ListResults()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != 1 || len(incomplete) != 0 {
+		t.Fatal("expected a single done result", len(done), len(incomplete))
+	}
+	result := done[0]
+	if result.TestGroupName != AdHocGroupName {
+		t.Fatal("unexpected test group name", result.TestGroupName)
+	}
+	if result.DatabaseNetwork.ASN != 30722 || result.DatabaseNetwork.CountryCode != "IT" {
+		t.Fatal("unexpected network", result.DatabaseNetwork)
+	}
+
+	measurements, err := db.ListMeasurements(result.DatabaseResult.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var urls []string
+	for _, m := range measurements {
+		if m.TestName != "example" || !m.DatabaseMeasurement.IsDone || !m.DatabaseMeasurement.IsUploaded || m.ReportID.String != "report-1" {
+			t.Fatal("unexpected measurement", m.DatabaseMeasurement)
+		}
+		urls = append(urls, m.URL.String)
+	}
+	sort.Strings(urls)
+	if diff := cmp.Diff(config.Inputs, urls); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+// networkWatcherForTesting is a [NetworkWatcher] reporting that
+// the network changed when checking for the changeAt-th time.
+type networkWatcherForTesting struct {
+	changeAt int
+	checks   int
+}
+
+func (nw *networkWatcherForTesting) Due() bool {
+	return true
+}
+
+func (nw *networkWatcherForTesting) CheckContext(ctx context.Context) (bool, error) {
+	nw.checks++
+	return nw.checks == nw.changeAt, nil
+}
+
+func TestControllerRunWhenTheNetworkChanges(t *testing.T) {
+	probe := newOONIProbe(t)
+	sess, err := probe.NewSession(context.Background(), model.RunTypeManual)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+	db := probe.DB()
+	network, err := db.CreateNetwork(sess)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := db.CreateResult(probe.Home(), AdHocGroupName, network.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// each experiment opens a new report with a distinct ID
+	var reports int
+	builder := &mocks.ExperimentBuilder{
+		MockSetCallbacks: func(callbacks model.ExperimentCallbacks) {},
+		MockNewExperiment: func() model.Experiment {
+			var reportID string
+			return &mocks.Experiment{
+				MockKibiBytesReceived: func() float64 {
+					return 0
+				},
+				MockKibiBytesSent: func() float64 {
+					return 0
+				},
+				MockName: func() string {
+					return "example"
+				},
+				MockOpenReportContext: func(ctx context.Context) error {
+					reports++
+					reportID = fmt.Sprintf("report-%d", reports)
+					return nil
+				},
+				MockReportID: func() string {
+					return reportID
+				},
+				MockMeasureWithContext: func(ctx context.Context, target model.ExperimentTarget) (*model.Measurement, error) {
+					return &model.Measurement{Input: model.MeasurementInput(target.Input())}, nil
+				},
+				MockSubmitAndUpdateMeasurementContext: func(ctx context.Context, measurement *model.Measurement) error {
+					return nil
+				},
+			}
+		},
+	}
+	inputs := []model.ExperimentTarget{
+		model.NewOOAPIURLInfoWithDefaultCategoryAndCountry("https://www.example.com/"),
+		model.NewOOAPIURLInfoWithDefaultCategoryAndCountry("https://www.example.org/"),
+		model.NewOOAPIURLInfoWithDefaultCategoryAndCountry("https://www.example.net/"),
+	}
+
+	// the network changes before measuring the third input
+	ctl := NewController(AdHoc{ExperimentName: "example"}, probe, res, sess)
+	ctl.NetworkWatcher = &networkWatcherForTesting{changeAt: 2}
+	if err := ctl.Run(builder, inputs); err != nil {
+		t.Fatal(err)
+	}
+
+	if ctl.res.ID == res.ID {
+		t.Fatal("expected a new result")
+	}
+	done, incomplete, err := db.ListResults()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != 1 || done[0].DatabaseResult.ID != res.ID {
+		t.Fatal("expected the old result to be done")
+	}
+	if len(incomplete) != 1 || incomplete[0].DatabaseResult.ID != ctl.res.ID {
+		t.Fatal("expected the new result to be incomplete")
+	}
+
+	before, err := db.ListMeasurements(res.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(before) != 2 {
+		t.Fatal("expected two measurements before the network changed, got", len(before))
+	}
+	// the watcher did not detect any change before measuring the second input, so
+	// we should only mark the measurement of the second input as network changed
+	for _, m := range before {
+		second := m.DatabaseMeasurement.ID == max(before[0].DatabaseMeasurement.ID, before[1].DatabaseMeasurement.ID)
+		if m.IsNetworkChanged != second {
+			t.Fatal("unexpected network changed mark for measurement", m.DatabaseMeasurement.ID)
+		}
+		if m.ReportID.String != "report-1" {
+			t.Fatal("unexpected report ID", m.ReportID.String)
+		}
+	}
+
+	after, err := db.ListMeasurements(ctl.res.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(after) != 1 {
+		t.Fatal("expected one measurement after the network changed, got", len(after))
+	}
+	if after[0].IsNetworkChanged {
+		t.Fatal("did not expect the measurement to be marked as network changed")
+	}
+	if after[0].ReportID.String != "report-2" {
+		t.Fatal("unexpected report ID", after[0].ReportID.String)
+	}
+}
@@ -0,0 +1,200 @@
+package nettests
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/oonirun"
+	"github.com/pkg/errors"
+)
+
+// OONIRunRefreshInterval is the interval after which we automatically
+// refresh an OONI Run subscription before running its nettests.
+const OONIRunRefreshInterval = 24 * time.Hour
+
+// OONIRun runs a nettest contained inside the descriptor of an OONI Run v2
+// subscription. Use [RunOONIRunSubscription] to run it.
+type OONIRun struct {
+	// Nettest is the nettest inside the descriptor.
+	Nettest oonirun.V2Nettest
+}
+
+func (n OONIRun) lookupURLs(ctl *Controller, builder model.ExperimentBuilder) ([]model.ExperimentTarget, error) {
+	config := &model.ExperimentTargetLoaderConfig{
+		CheckInConfig: &model.OOAPICheckInConfig{
+			// Setting Charging and OnWiFi to true causes the CheckIn
+			// API to return to us as much URL as possible with the
+			// given RunType hint.
+			Charging: true,
+			OnWiFi:   true,
+			RunType:  ctl.RunType,
+			WebConnectivity: model.OOAPICheckInConfigWebConnectivity{
+				CategoryCodes: ctl.Probe.Config().Nettests.WebsitesEnabledCategoryCodes,
+			},
+		},
+		Session:      ctl.Session,
+		StaticInputs: n.Nettest.Inputs,
+	}
+	targetloader := builder.NewTargetLoader(config)
+	testlist, err := targetloader.Load(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return ctl.BuildAndSetInputIdxMap(testlist)
+}
+
+// Run starts the nettest.
+func (n OONIRun) Run(ctl *Controller) error {
+	builder, err := ctl.Session.NewExperimentBuilder(n.Nettest.TestName)
+	if err != nil {
+		return err
+	}
+	if len(n.Nettest.Options) > 0 {
+		if err := builder.SetOptionsJSON(n.Nettest.Options); err != nil {
+			return err
+		}
+	}
+	urls, err := n.lookupURLs(ctl, builder)
+	if err != nil {
+		return err
+	}
+	return ctl.Run(builder, urls)
+}
+
+// ParseOONIRunDescriptor parses a JSON serialized OONI Run v2 descriptor
+// such as the ones stored inside the database subscriptions.
+func ParseOONIRunDescriptor(data string) (*oonirun.V2Descriptor, error) {
+	var descriptor oonirun.V2Descriptor
+	if err := json.Unmarshal([]byte(data), &descriptor); err != nil {
+		return nil, errors.Wrap(err, "parsing the OONI Run descriptor")
+	}
+	return &descriptor, nil
+}
+
+// RefreshOONIRunSubscription fetches again the descriptor of the given subscription
+// and returns the diff between the accepted descriptor and the fetched one.
+//
+// When the diff is not empty and accept is true, the fetched descriptor becomes the
+// accepted one. Otherwise, we keep the accepted descriptor, which is the one we are
+// going to run, and we store the fetched one as pending, so that the user can later
+// accept or reject the changes. In all cases, we update the subscription in the database.
+func RefreshOONIRunSubscription(ctx context.Context, sess model.ExperimentSession,
+	db model.WritableDatabase, sub *model.DatabaseOONIRunSubscription, accept bool) (string, error) {
+	oldValue, err := ParseOONIRunDescriptor(sub.Descriptor)
+	if err != nil {
+		return "", err
+	}
+	newValue, err := oonirun.GetV2DescriptorFromHTTPSURL(
+		ctx, sess.DefaultHTTPClient(), sess.Logger(), sub.URL, "")
+	if err != nil {
+		return "", err
+	}
+	diff := oonirun.V2DescriptorDiff(oldValue, newValue, sub.URL)
+	switch {
+	case diff == "":
+		sub.PendingDescriptor = ""
+	case accept:
+		data, err := json.Marshal(newValue)
+		if err != nil {
+			return "", err
+		}
+		sub.Descriptor, sub.PendingDescriptor = string(data), ""
+	default:
+		data, err := json.Marshal(newValue)
+		if err != nil {
+			return "", err
+		}
+		sub.PendingDescriptor = string(data)
+	}
+	sub.RefreshedAt = time.Now().UTC()
+	if err := db.UpdateOONIRunSubscription(sub); err != nil {
+		return "", err
+	}
+	return diff, nil
+}
+
+// RunOONIRunSubscriptions runs the nettests inside the accepted descriptors of all
+// the OONI Run subscriptions. Before running, we refresh the subscriptions not refreshed
+// for more than [OONIRunRefreshInterval] without accepting changes, and we warn the user
+// about the subscriptions with pending changes.
+func RunOONIRunSubscriptions(config RunGroupConfig) error {
+	db := config.Probe.DB()
+	subs, err := db.ListOONIRunSubscriptions()
+	if err != nil {
+		log.WithError(err).Error("Failed to list the OONI Run subscriptions")
+		return err
+	}
+	if len(subs) <= 0 {
+		return nil
+	}
+	if err := maybeRefreshOONIRunSubscriptions(config, subs); err != nil {
+		log.WithError(err).Warn("Failed to refresh the OONI Run subscriptions")
+	}
+	for idx := range subs {
+		if config.Probe.IsTerminated() {
+			log.Debugf("context is terminated, stopping RunOONIRunSubscriptions early")
+			break
+		}
+		sub := &subs[idx]
+		if sub.PendingDescriptor != "" {
+			log.Warnf("OONI Run subscription #%d (%s) has pending changes; run `ooniprobe oonirun refresh` to review them",
+				sub.ID, sub.URL)
+		}
+		if err := RunOONIRunSubscription(config, sub); err != nil {
+			log.WithError(err).Errorf("failed to run OONI Run subscription #%d", sub.ID)
+		}
+	}
+	return nil
+}
+
+// maybeRefreshOONIRunSubscriptions refreshes the stale subscriptions.
+func maybeRefreshOONIRunSubscriptions(config RunGroupConfig, subs []model.DatabaseOONIRunSubscription) error {
+	var stale []*model.DatabaseOONIRunSubscription
+	for idx := range subs {
+		if time.Since(subs[idx].RefreshedAt) > OONIRunRefreshInterval {
+			stale = append(stale, &subs[idx])
+		}
+	}
+	if len(stale) <= 0 {
+		return nil
+	}
+	sess, err := config.Probe.NewSession(context.Background(), config.RunType)
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+	for _, sub := range stale {
+		log.Infof("Refreshing OONI Run subscription #%d (%s)", sub.ID, sub.URL)
+		if _, err := RefreshOONIRunSubscription(
+			context.Background(), sess, config.Probe.DB(), sub, false); err != nil {
+			log.WithError(err).Warnf("Failed to refresh OONI Run subscription #%d", sub.ID)
+		}
+	}
+	return nil
+}
+
+// RunOONIRunSubscription runs the nettests inside the accepted descriptor of the
+// given subscription according to the specified config and stores the results into
+// the database using the [model.OONIRunGroupName] group name.
+func RunOONIRunSubscription(config RunGroupConfig, sub *model.DatabaseOONIRunSubscription) error {
+	descriptor, err := ParseOONIRunDescriptor(sub.Descriptor)
+	if err != nil {
+		return err
+	}
+	config.GroupName = model.OONIRunGroupName
+	config.OONIRunSubscriptionID = sub.ID
+	group := Group{
+		Label:        descriptor.Name,
+		UnattendedOK: true,
+	}
+	if group.Label == "" {
+		group.Label = sub.URL
+	}
+	for _, nettest := range descriptor.Nettests {
+		group.Nettests = append(group.Nettests, OONIRun{Nettest: nettest})
+	}
+	return runGroup(config, group)
+}
@@ -0,0 +1,105 @@
+package nettests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/apex/log"
+	"github.com/ooni/probe-cli/v3/internal/mocks"
+	"github.com/ooni/probe-cli/v3/internal/model"
+)
+
+func TestParseOONIRunDescriptor(t *testing.T) {
+	descriptor, err := ParseOONIRunDescriptor(`{"name":"Example","nettests":[{"test_name":"example"}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if descriptor.Name != "Example" || len(descriptor.Nettests) != 1 {
+		t.Fatal("unexpected descriptor", descriptor)
+	}
+	if _, err := ParseOONIRunDescriptor("{"); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestRefreshOONIRunSubscription(t *testing.T) {
+	body := `{"name":"Example","nettests":[{"test_name":"example"}]}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	probe := newOONIProbe(t)
+	sess := &mocks.Session{
+		MockDefaultHTTPClient: func() model.HTTPClient {
+			return http.DefaultClient
+		},
+		MockLogger: func() model.Logger {
+			return log.Log
+		},
+	}
+	db := probe.DB()
+	sub, err := db.CreateOONIRunSubscription(server.URL, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("without changes", func(t *testing.T) {
+		diff, err := RefreshOONIRunSubscription(context.Background(), sess, db, sub, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff != "" || sub.PendingDescriptor != "" {
+			t.Fatal("expected no changes", diff, sub.PendingDescriptor)
+		}
+	})
+
+	t.Run("with changes we do not accept", func(t *testing.T) {
+		body = `{"name":"Changed","nettests":[{"test_name":"example"}]}`
+		diff, err := RefreshOONIRunSubscription(context.Background(), sess, db, sub, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff == "" || sub.PendingDescriptor == "" {
+			t.Fatal("expected pending changes", diff, sub.PendingDescriptor)
+		}
+		descriptor, err := ParseOONIRunDescriptor(sub.Descriptor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if descriptor.Name != "Example" {
+			t.Fatal("the accepted descriptor should not have changed")
+		}
+	})
+
+	t.Run("with changes we accept", func(t *testing.T) {
+		diff, err := RefreshOONIRunSubscription(context.Background(), sess, db, sub, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff == "" || sub.PendingDescriptor != "" {
+			t.Fatal("expected accepted changes", diff, sub.PendingDescriptor)
+		}
+		subs, err := db.ListOONIRunSubscriptions()
+		if err != nil {
+			t.Fatal(err)
+		}
+		descriptor, err := ParseOONIRunDescriptor(subs[0].Descriptor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if descriptor.Name != "Changed" {
+			t.Fatal("the accepted descriptor should have changed")
+		}
+	})
+
+	t.Run("with a broken accepted descriptor", func(t *testing.T) {
+		broken := *sub
+		broken.Descriptor = "{"
+		if _, err := RefreshOONIRunSubscription(context.Background(), sess, db, &broken, false); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
@@ -8,7 +8,10 @@ import (
 
 	"github.com/apex/log"
 	"github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/ooni"
+	engine "github.com/ooni/probe-cli/v3/internal/engine"
+	"github.com/ooni/probe-cli/v3/internal/experimentname"
 	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/registry"
 	"github.com/pkg/errors"
 )
 
@@ -19,6 +22,10 @@ type RunGroupConfig struct {
 	Inputs     []string
 	Probe      *ooni.Probe
 	RunType    model.RunType // hint for check-in API
+
+	// OONIRunSubscriptionID is the ID of the OONI Run subscription whose
+	// nettests we are running or zero when running built-in groups.
+	OONIRunSubscriptionID int64
 }
 
 const websitesURLLimitRemoved = `WARNING: CONFIGURATION CHANGE REQUIRED:
@@ -42,6 +49,56 @@ var deprecationWarningOnce sync.Once
 
 // RunGroup runs a group of nettests according to the specified config.
 func RunGroup(config RunGroupConfig) error {
+	group, ok := All[config.GroupName]
+	if !ok {
+		log.Errorf("No test group named %s", config.GroupName)
+		return errors.New("invalid test group name")
+	}
+	return runGroup(config, group)
+}
+
+// RunAdHoc runs an arbitrary experiment according to the specified config and
+// stores the results into the database using the [AdHocGroupName] group name.
+func RunAdHoc(config RunGroupConfig, nt AdHoc) error {
+	if _, found := registry.AllExperiments[experimentname.Canonicalize(nt.ExperimentName)]; !found {
+		log.Errorf("No experiment named %s", nt.ExperimentName)
+		return errors.New("invalid experiment name")
+	}
+	config.GroupName = AdHocGroupName
+	group := Group{
+		Label:        "Ad-hoc experiment",
+		Nettests:     []Nettest{nt},
+		UnattendedOK: false,
+	}
+	return runGroup(config, group)
+}
+
+// runGroupSession is the [Session] used by [runGroup].
+type runGroupSession interface {
+	Session
+
+	// Close closes the session.
+	Close() error
+
+	// MaybeLookupBackendsContext discovers the OONI backends.
+	MaybeLookupBackendsContext(ctx context.Context) error
+
+	// MaybeLookupLocationContext looks up the probe location.
+	MaybeLookupLocationContext(ctx context.Context) error
+}
+
+// newRunGroupSession creates the session used by [runGroup] along with the
+// [NetworkWatcher] detecting network changes. Tests override this function.
+var newRunGroupSession = func(probe *ooni.Probe, runType model.RunType) (runGroupSession, NetworkWatcher, error) {
+	sess, err := probe.NewSession(context.Background(), runType)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sess, sess.NewNetworkWatcher(engine.DefaultNetworkCheckInterval), nil
+}
+
+// runGroup runs the given group of nettests according to the specified config.
+func runGroup(config RunGroupConfig, group Group) error {
 	if config.Probe.Config().Nettests.WebsitesURLLimit > 0 {
 		if config.Probe.Config().Nettests.WebsitesMaxRuntime <= 0 {
 			limit := config.Probe.Config().Nettests.WebsitesURLLimit
@@ -59,7 +116,7 @@ func RunGroup(config RunGroupConfig) error {
 		return nil
 	}
 
-	sess, err := config.Probe.NewSession(context.Background(), config.RunType)
+	sess, watcher, err := newRunGroupSession(config.Probe, config.RunType)
 	if err != nil {
 		log.WithError(err).Error("Failed to create a measurement session")
 		return err
@@ -82,15 +139,16 @@ func RunGroup(config RunGroupConfig) error {
 		return err
 	}
 
-	group, ok := All[config.GroupName]
-	if !ok {
-		log.Errorf("No test group named %s", config.GroupName)
-		return errors.New("invalid test group name")
-	}
 	log.Debugf("Running test group %s", group.Label)
 
-	result, err := db.CreateResult(
-		config.Probe.Home(), config.GroupName, network.ID)
+	var result *model.DatabaseResult
+	if config.OONIRunSubscriptionID > 0 {
+		result, err = db.CreateOONIRunResult(
+			config.Probe.Home(), config.OONIRunSubscriptionID, network.ID)
+	} else {
+		result, err = db.CreateResult(
+			config.Probe.Home(), config.GroupName, network.ID)
+	}
 	if err != nil {
 		log.Errorf("DB result error: %s", err)
 		return err
@@ -114,31 +172,41 @@ func RunGroup(config RunGroupConfig) error {
 		ctl.InputFiles = config.InputFiles
 		ctl.Inputs = config.Inputs
 		ctl.RunType = config.RunType
+		ctl.NetworkWatcher = watcher
 		ctl.SetNettestIndex(i, len(group.Nettests))
 		if err = nt.Run(ctl); err != nil {
 			// We used to emit an error here, now we emit a warning--the proper choice
 			// given that we continue running. See https://github.com/ooni/probe/issues/2576.
 			log.WithError(err).Warnf("Failed to run %s", group.Label)
 		}
+		// The controller replaces the result when the network changes
+		result = ctl.res
 	}
 
-	// Remove the directory if it's emtpy, which happens when the corresponding
-	// measurements have been submitted (see https://github.com/ooni/probe/issues/2090)
-	dir, err := os.Open(result.MeasurementDir)
-	if err != nil {
+	if err := removeDirIfEmpty(result.MeasurementDir); err != nil {
 		return err
 	}
-	defer dir.Close()
-	_, err = dir.Readdirnames(1)
-	if err != nil {
-		_ = os.Remove(result.MeasurementDir)
-	}
 	if err = db.Finished(result); err != nil {
 		return err
 	}
 	return nil
 }
 
+// removeDirIfEmpty removes the measurement directory if it's emtpy, which happens
+// when the corresponding measurements have been submitted (see
+// https://github.com/ooni/probe/issues/2090)
+func removeDirIfEmpty(path string) error {
+	dir, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	if _, err := dir.Readdirnames(1); err != nil {
+		_ = os.Remove(path)
+	}
+	return nil
+}
+
 // onlyBackground is the interface implements by nettests that we don't
 // want to run in manual mode because they take too much runtime
 //
@@ -2,6 +2,7 @@ package nettests
 
 import (
 	"context"
+	"time"
 
 	"github.com/apex/log"
 	"github.com/ooni/probe-cli/v3/internal/model"
@@ -24,6 +25,8 @@ func (n WebConnectivity) lookupURLs(
 		Session:      ctl.Session,
 		SourceFiles:  ctl.InputFiles,
 		StaticInputs: ctl.Inputs,
+		Sampling:     n.samplingConfig(ctl),
+		RemoteLists:  n.remoteListsConfig(ctl),
 	}
 	targetloader := builder.NewTargetLoader(config)
 	testlist, err := targetloader.Load(context.Background())
@@ -33,6 +36,42 @@ func (n WebConnectivity) lookupURLs(
 	return ctl.BuildAndSetInputIdxMap(testlist)
 }
 
+// samplingConfig returns the configuration for sampling the URLs returned by
+// the check-in API or nil when sampling is disabled by the settings.
+func (n WebConnectivity) samplingConfig(ctl *Controller) *model.ExperimentTargetSamplingConfig {
+	settings := ctl.Probe.Config().Nettests.WebsitesSampling
+	if !settings.Enabled {
+		return nil
+	}
+	lastMeasured, err := ctl.Probe.DB().ListURLsLastMeasured("web_connectivity", ctl.Session.ProbeASN())
+	if err != nil {
+		// We can still sample without knowing which URLs we measured
+		log.WithError(err).Warn("failed to list the URLs we last measured")
+	}
+	return &model.ExperimentTargetSamplingConfig{
+		CategoryPriorities: settings.CategoryPriorities,
+		DeduplicateDomains: settings.DeduplicateDomains,
+		LastMeasured:       lastMeasured,
+		Seed:               settings.Seed,
+		StaleAfter:         time.Duration(settings.StaleAfterDays) * 24 * time.Hour,
+	}
+}
+
+// remoteListsConfig returns the configuration for loading the signed
+// remote lists of URLs or nil when there are no configured lists.
+func (n WebConnectivity) remoteListsConfig(ctl *Controller) *model.ExperimentTargetRemoteListsConfig {
+	lists := ctl.Probe.Config().Nettests.WebsitesRemoteLists
+	if len(lists) <= 0 {
+		return nil
+	}
+	return &model.ExperimentTargetRemoteListsConfig{
+		HTTPClient: ctl.Session.DefaultHTTPClient(),
+		KVStore:    ctl.Session.KeyValueStore(),
+		Lists:      lists,
+		UserAgent:  ctl.Session.UserAgent(),
+	}
+}
+
 // WebConnectivity test implementation
 type WebConnectivity struct{}
 
@@ -15,6 +15,7 @@ import (
 	"github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/utils"
 	"github.com/ooni/probe-cli/v3/internal/database"
 	"github.com/ooni/probe-cli/v3/internal/engine"
+	"github.com/ooni/probe-cli/v3/internal/enginelocate"
 	"github.com/ooni/probe-cli/v3/internal/kvstore"
 	"github.com/ooni/probe-cli/v3/internal/legacy/assetsdir"
 	"github.com/ooni/probe-cli/v3/internal/model"
@@ -232,14 +233,31 @@ func (p *Probe) NewSession(ctx context.Context, runType model.RunType) (*engine.
 	if runType == model.RunTypeTimed && softwareName == DefaultSoftwareName {
 		softwareName = DefaultSoftwareName + "-unattended"
 	}
+	advanced := p.config.Advanced
+	var probeServices []model.OOAPIService
+	if advanced.ProbeServicesURL != "" {
+		probeServices = []model.OOAPIService{{
+			Address: advanced.ProbeServicesURL,
+			Type:    "https",
+		}}
+	}
 	return engine.NewSession(ctx, engine.SessionConfig{
-		KVStore:         kvstore,
-		Logger:          logger,
-		SoftwareName:    softwareName,
-		SoftwareVersion: p.softwareVersion,
-		TempDir:         p.tempDir,
-		TunnelDir:       p.tunnelDir,
-		ProxyURL:        p.proxyURL,
+		AvailableProbeServices: probeServices,
+		GeolocationDatabase:    advanced.GeolocationDatabase,
+		GeolocationOverride: enginelocate.Override{
+			ProbeASN:         advanced.GeolocationOverride.ProbeASN,
+			ProbeCC:          advanced.GeolocationOverride.ProbeCC,
+			ProbeNetworkName: advanced.GeolocationOverride.ProbeNetworkName,
+		},
+		KVStore:          kvstore,
+		Logger:           logger,
+		SignMeasurements: p.config.Sharing.SignMeasurements,
+		SoftwareName:     softwareName,
+		SoftwareVersion:  p.softwareVersion,
+		TempDir:          p.tempDir,
+		TorColdStart:     advanced.TorColdStart,
+		TunnelDir:        p.tunnelDir,
+		ProxyURL:         p.proxyURL,
 	})
 }
 
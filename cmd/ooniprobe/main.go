@@ -7,6 +7,7 @@ import (
 	_ "github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/cli/info"
 	_ "github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/cli/list"
 	_ "github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/cli/onboard"
+	_ "github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/cli/oonirun"
 	_ "github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/cli/reset"
 	_ "github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/cli/rm"
 	_ "github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/cli/run"
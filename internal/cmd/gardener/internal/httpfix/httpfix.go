@@ -0,0 +1,211 @@
+// Package httpfix implements the httpfix command.
+package httpfix
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/ooni/probe-cli/v3/internal/cmd/gardener/internal/testlists"
+	"github.com/ooni/probe-cli/v3/internal/fsx"
+	"github.com/ooni/probe-cli/v3/internal/runtimex"
+	"github.com/schollz/progressbar/v3"
+)
+
+// Subcommand is the httpfix subcommand. The zero value is invalid; please, make
+// sure you initialize all the fields marked as MANDATORY.
+type Subcommand struct {
+	// ReportFile is the MANDATORY file from which to read
+	// the results of the httpreport subcommand.
+	ReportFile string
+
+	// ReviewFile is the MANDATORY file where to write the summary
+	// of the changes for researchers to review.
+	ReviewFile string
+}
+
+// Main is the main function of the httpfix subcommand. This function calls
+// [runtimex.PanicOnError] in case of failure.
+func (s *Subcommand) Main() {
+	// obtain entries in the file generated by the httpreport subcommand
+	entries := s.collectEntries()
+
+	// create the progress bar to show the user progress
+	bar := progressbar.NewOptions64(
+		int64(len(entries)),
+		progressbar.OptionShowDescriptionAtLineEnd(),
+		progressbar.OptionSetWidth(40),
+		progressbar.OptionShowCount(),
+		progressbar.OptionSetPredictTime(true),
+		progressbar.OptionThrottle(65*time.Millisecond),
+		progressbar.OptionOnCompletion(func() {
+			fmt.Fprint(os.Stdout, "\n")
+		}),
+		progressbar.OptionSetWriter(os.Stdout),
+	)
+
+	// walk through each entry
+	rv := &review{}
+	for _, entry := range entries {
+		_ = bar.Add(1)
+		s.processEntry(rv, entry)
+	}
+
+	// write the summary for researchers
+	s.writeReview(rv)
+}
+
+func (s *Subcommand) collectEntries() (out []*reportEntry) {
+	// open file and create CSV reader
+	filep := runtimex.Try1(fsx.OpenFile(s.ReportFile))
+	reader := csv.NewReader(filep)
+
+	// remember to close the open file
+	defer filep.Close()
+
+	// loop through all entries
+	var lineno int64
+	for {
+		// read the current entry
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		runtimex.PanicOnError(err, "reader.Read")
+		// this record seems malformed but in theory this
+		// cannot happen because the csv library should return
+		// an error in case we see a short record.
+		runtimex.Assert(len(record) == 8, "unexpected record length")
+
+		// skip the first line, which contains the headers
+		lineno++
+		if lineno == 1 {
+			continue
+		}
+
+		// add this entry to the list
+		out = append(out, newReportEntry(record))
+	}
+
+	// return to the caller
+	return
+}
+
+// reportEntry is an entry generated by httpreport.
+type reportEntry struct {
+	file           string
+	line           int64
+	url            string
+	classification string
+	statusCode     int64
+	finalURL       string
+	proposedURL    string
+	failure        string
+}
+
+// newReportEntry generates a report entry from a CSV record.
+func newReportEntry(record []string) *reportEntry {
+	runtimex.Assert(len(record) == 8, "unexpected record length")
+	return &reportEntry{
+		file:           record[0],
+		line:           runtimex.Try1(strconv.ParseInt(record[1], 10, 64)),
+		url:            record[2],
+		classification: record[3],
+		statusCode:     runtimex.Try1(strconv.ParseInt(record[4], 10, 64)),
+		finalURL:       record[5],
+		proposedURL:    record[6],
+		failure:        record[7],
+	}
+}
+
+// review collects the information for researchers to review.
+type review struct {
+	// replaced contains the entries we replaced.
+	replaced []*reportEntry
+
+	// duplicate contains the entries we did not replace because
+	// the proposed URL was already in the test list.
+	duplicate []*reportEntry
+
+	// notFound contains the entries we did not replace because
+	// the URL was not in the test list anymore.
+	notFound []*reportEntry
+
+	// manual contains the entries requiring manual review.
+	manual []*reportEntry
+}
+
+// processEntry processes the given entry and possibly edits
+// the test lists to replace the URL with the proposed URL.
+func (s *Subcommand) processEntry(rv *review, entry *reportEntry) {
+	// entries without a proposed URL require manual review
+	if entry.proposedURL == "" {
+		rv.manual = append(rv.manual, entry)
+		return
+	}
+
+	// replace the URL inside the test lists
+	switch err := testlists.Replace(entry.file, entry.url, entry.proposedURL); {
+	case errors.Is(err, testlists.ErrURLAlreadyPresent):
+		rv.duplicate = append(rv.duplicate, entry)
+	case errors.Is(err, testlists.ErrURLNotFound):
+		rv.notFound = append(rv.notFound, entry)
+	default:
+		rv.replaced = append(rv.replaced, entry)
+	}
+}
+
+// writeReview writes the review summary as a markdown file.
+func (s *Subcommand) writeReview(rv *review) {
+	log.Infof("writing researchers' review file: %s", s.ReviewFile)
+
+	// create the output file
+	filep := runtimex.Try1(os.Create(s.ReviewFile))
+
+	// write the replaced entries
+	fmt.Fprintf(filep, "# httpfix review summary\n\n")
+	fmt.Fprintf(filep, "## Replaced URLs (%d)\n\n", len(rv.replaced))
+	for _, entry := range rv.replaced {
+		fmt.Fprintf(filep, "- %s:%d: %s => %s (%s)\n",
+			entry.file, entry.line, entry.url, entry.proposedURL, entry.classification)
+	}
+
+	// write the entries we could not replace
+	fmt.Fprintf(filep, "\n## Proposed URL already in the test list (%d)\n\n", len(rv.duplicate))
+	for _, entry := range rv.duplicate {
+		fmt.Fprintf(filep, "- %s:%d: %s => %s (%s)\n",
+			entry.file, entry.line, entry.url, entry.proposedURL, entry.classification)
+	}
+
+	// write the entries that are not in the test list anymore
+	fmt.Fprintf(filep, "\n## URL not found in the test list (%d)\n\n", len(rv.notFound))
+	for _, entry := range rv.notFound {
+		fmt.Fprintf(filep, "- %s:%d: %s => %s (%s)\n",
+			entry.file, entry.line, entry.url, entry.proposedURL, entry.classification)
+	}
+
+	// write the entries requiring manual review
+	fmt.Fprintf(filep, "\n## Requiring manual review (%d)\n\n", len(rv.manual))
+	for _, entry := range rv.manual {
+		fmt.Fprintf(filep, "- %s:%d: %s (%s)\n", entry.file, entry.line, entry.url, entry.details())
+	}
+
+	runtimex.Try0(filep.Close())
+}
+
+// details returns a description of the problem with this entry.
+func (e *reportEntry) details() string {
+	switch {
+	case e.failure != "":
+		return fmt.Sprintf("%s: %s", e.classification, e.failure)
+	case e.statusCode != 0:
+		return fmt.Sprintf("%s: %d %s", e.classification, e.statusCode, e.finalURL)
+	default:
+		return e.classification
+	}
+}
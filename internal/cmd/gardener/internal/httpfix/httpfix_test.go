@@ -0,0 +1,49 @@
+package httpfix_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/ooni/probe-cli/v3/internal/cmd/gardener/internal/httpfix"
+	"github.com/ooni/probe-cli/v3/internal/shellx"
+)
+
+func TestWorkingAsIntended(t *testing.T) {
+	// copy the original CSV file so we modify a copy
+	orig := filepath.Join("testdata", "lists", "it.csv")
+	copied := filepath.Join("testdata", "lists", "it-copy.csv")
+	if err := shellx.CopyFile(orig, copied, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// fix the test list according to the httpreport.csv file
+	reviewFile := filepath.Join("testdata", "httpfix.md")
+	subc := &httpfix.Subcommand{
+		ReportFile: filepath.Join("testdata", "httpreport.csv"),
+		ReviewFile: reviewFile,
+	}
+	subc.Main()
+
+	// compareFiles compares the content of two files
+	compareFiles := func(expectFile, gotFile string) {
+		expect, err := os.ReadFile(expectFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := os.ReadFile(gotFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(string(expect), string(got)); diff != "" {
+			t.Fatal(diff)
+		}
+	}
+
+	// make sure we get the expected changes
+	compareFiles(filepath.Join("testdata", "lists", "it-expected.csv"), copied)
+
+	// make sure we get the expected review summary
+	compareFiles(filepath.Join("testdata", "httpfix-expected.md"), reviewFile)
+}
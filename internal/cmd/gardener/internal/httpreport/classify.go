@@ -0,0 +1,126 @@
+package httpreport
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+
+	"github.com/ooni/probe-cli/v3/internal/measurexlite"
+	"github.com/ooni/probe-cli/v3/internal/netxlite"
+)
+
+const (
+	// classificationOK means the URL works as intended.
+	classificationOK = "ok"
+
+	// classificationDeadDomain means the URL domain does not exist anymore.
+	classificationDeadDomain = "dead_domain"
+
+	// classificationParked means the final page looks like a parked domain.
+	classificationParked = "parked"
+
+	// classificationRedirectOtherDomain means the URL permanently
+	// redirects to a URL with a different domain.
+	classificationRedirectOtherDomain = "redirect_other_domain"
+
+	// classificationHTTPSUpgrade means the http:// URL redirects to
+	// an https:// URL for the same domain.
+	classificationHTTPSUpgrade = "https_upgrade"
+
+	// classificationHTTPError means the final response status code is >= 400.
+	classificationHTTPError = "http_error"
+
+	// classificationNetworkError means we could not fetch the URL.
+	classificationNetworkError = "network_error"
+)
+
+// parkedPageSignatures contains lowercase strings commonly found
+// in the pages served by domain parking and domain selling services.
+var parkedPageSignatures = []string{
+	"this domain is for sale",
+	"this domain may be for sale",
+	"buy this domain",
+	"the domain has expired",
+	"this domain is parked",
+	"domain is parked free",
+	"parkingcrew.net",
+	"sedoparking.com",
+	"bodis.com",
+	"hugedomains.com",
+	"afternic.com",
+}
+
+// looksParked returns whether the body looks like a parked page.
+func looksParked(body []byte) bool {
+	body = bytes.ToLower(body)
+	for _, signature := range parkedPageSignatures {
+		if bytes.Contains(body, []byte(signature)) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameDomain returns whether two hostnames are equal ignoring the "www." prefix.
+func sameDomain(left, right *url.URL) bool {
+	trim := func(URL *url.URL) string {
+		return strings.TrimPrefix(strings.ToLower(URL.Hostname()), "www.")
+	}
+	return trim(left) == trim(right)
+}
+
+// allPermanent returns whether all the redirects are permanent.
+func allPermanent(redirects []*redirect) bool {
+	for _, r := range redirects {
+		if r.StatusCode != 301 && r.StatusCode != 308 {
+			return false
+		}
+	}
+	return true
+}
+
+// classify sets the classification and the proposed URL given the
+// original URL and the redirect chain we followed.
+func (r *result) classify(original *url.URL) {
+	r.classification, r.proposedURL = r.doClassify(original)
+}
+
+func (r *result) doClassify(original *url.URL) (string, string) {
+	// deal with failures, noting that NXDOMAIN on the original domain means
+	// the domain is dead, while other failures need manual investigation
+	if r.err != nil {
+		failure := measurexlite.NewFailure(r.err)
+		if len(r.redirects) <= 0 && *failure == netxlite.FailureDNSNXDOMAINError {
+			return classificationDeadDomain, ""
+		}
+		return classificationNetworkError, ""
+	}
+
+	// deal with HTTP errors
+	if r.statusCode() >= 400 {
+		return classificationHTTPError, ""
+	}
+
+	// deal with parked pages
+	if looksParked(r.body) {
+		return classificationParked, ""
+	}
+
+	// deal with redirects to a working URL
+	final, err := url.Parse(r.finalURL())
+	if err != nil || len(r.redirects) <= 1 {
+		return classificationOK, ""
+	}
+	if !sameDomain(original, final) {
+		// temporary redirects to other domains are common for login
+		// pages and geo-redirects, so we do not propose to replace them
+		if allPermanent(r.redirects[:len(r.redirects)-1]) {
+			return classificationRedirectOtherDomain, final.String()
+		}
+		return classificationOK, ""
+	}
+	if original.Scheme == "http" && final.Scheme == "https" {
+		return classificationHTTPSUpgrade, final.String()
+	}
+	return classificationOK, ""
+}
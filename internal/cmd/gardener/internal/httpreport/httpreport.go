@@ -0,0 +1,483 @@
+// Package httpreport implements the httpreport subcommand.
+package httpreport
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/apex/log"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/ooni/probe-cli/v3/internal/cmd/gardener/internal/testlists"
+	"github.com/ooni/probe-cli/v3/internal/fsx"
+	"github.com/ooni/probe-cli/v3/internal/measurexlite"
+	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/netxlite"
+	"github.com/ooni/probe-cli/v3/internal/runtimex"
+	"github.com/schollz/progressbar/v3"
+)
+
+// Subcommand is the httpreport subcommand. The zero value is invalid; please, make
+// sure you initialize all the fields marked as MANDATORY.
+type Subcommand struct {
+	// DNSOverHTTPSServerURL is the MANDATORY DNS-over-HTTPS server URL.
+	DNSOverHTTPSServerURL string
+
+	// Database is the MANDATORY path of the database where to
+	// store interim state while processing URLs.
+	Database string
+
+	// ReportFile is the MANDATORY file where to write the final report.
+	ReportFile string
+
+	// RepositoryDir is the MANDATORY directory where we previously
+	// cloned the citizenlab/test-lists repository.
+	RepositoryDir string
+
+	// RootCAs is the OPTIONAL cert pool to use when verifying TLS
+	// certificates. When nil, we use the bundled Mozilla cert pool.
+	RootCAs *x509.CertPool
+}
+
+// loadedFromRepository counts the number of times we loaded from the repository
+var loadedFromRepository = &atomic.Int64{}
+
+// databaseIsGood returns whether we should use the existing database content. You should
+// pass to this function the results of os.Stat invoked on the database file path.
+func databaseIsGood(statbuf fs.FileInfo, err error) bool {
+	const recreateInterval = 7 * 24 * time.Hour
+	return err == nil && fsx.IsRegular(statbuf) && time.Since(statbuf.ModTime()) < recreateInterval
+}
+
+// Main is the main function of the httpreport subcommand. This function calls
+// [runtimex.PanicOnError] in case of failure.
+func (s *Subcommand) Main(ctx context.Context) {
+	// check whether the database exists and check its statistics
+	isGood := databaseIsGood(os.Stat(s.Database))
+
+	// if the database is not good, truncate it and restart
+	if !isGood {
+		log.Infof("rm -f %s", s.Database)
+		_ = os.Remove(s.Database)
+	}
+
+	// create or open the underlying sqlite3 database
+	db := s.createOrOpenDatabase()
+	defer db.Close()
+
+	// fill again the database if what we had before was not good
+	if !isGood {
+		log.Infof("creating new %s database", s.Database)
+		s.loadFromRepository(db)
+		loadedFromRepository.Add(1)
+	} else {
+		log.Infof("using existing %s database", s.Database)
+	}
+
+	// obtain the list of entries to measure
+	entries := s.getEntriesToMeasure(db)
+	log.Infof("we need to measure %d entries", len(entries))
+
+	// measure each entry and update the database
+	s.measureEntries(ctx, db, entries)
+
+	// generate CSV report
+	s.writeReport(db)
+}
+
+// createTableQuery is the query to create the httpreport table.
+const createTableQuery = `
+CREATE TABLE IF NOT EXISTS httpreport(
+	file TEXT NOT NULL,
+	line INTEGER NOT NULL,
+	url TEXT NOT NULL,
+	status TEXT NOT NULL,
+	classification TEXT NOT NULL,
+	status_code INTEGER NOT NULL,
+	redirects TEXT NOT NULL,
+	final_url TEXT NOT NULL,
+	proposed_url TEXT NOT NULL,
+	failure TEXT
+);
+`
+
+// createOrOpenDatabase is the function that either creates or
+// opens the interim database containing status.
+func (s *Subcommand) createOrOpenDatabase() *sql.DB {
+	db := runtimex.Try1(sql.Open("sqlite3", s.Database))
+	_ = runtimex.Try1(db.Exec(createTableQuery))
+	return db
+}
+
+// insertIntoQuery is the query we use to insert a URL into the httpreport table.
+const insertIntoQuery = `
+INSERT INTO httpreport VALUES(
+	?,
+	?,
+	?,
+	?,
+	'',
+	0,
+	'[]',
+	'',
+	'',
+	NULL
+)
+`
+
+// loadFromRepository loads URLs from the local repository clone
+func (s *Subcommand) loadFromRepository(db *sql.DB) {
+	log.Info("loading information from the github.com/citizenlab/test-lists repository")
+
+	// create channel where to read the test list URLs
+	och := make(chan *testlists.Entry)
+
+	// create wait group to await for background goroutine to terminate
+	wg := &sync.WaitGroup{}
+
+	// start background worker goroutine
+	wg.Add(1)
+	go testlists.Generator(wg, filepath.Join(s.RepositoryDir, "lists"), och)
+
+	// create transaction for inserting into the database
+	tx := runtimex.Try1(db.Begin())
+	defer tx.Commit()
+
+	// read each entry and insert into transaction
+	for entry := range och {
+		_ = runtimex.Try1(tx.Exec(
+			insertIntoQuery,
+			entry.File,
+			entry.Line,
+			entry.URL,
+			"inserted",
+		))
+	}
+}
+
+// selectInsertedQuery selects the entries to measure by checking the status
+const selectInsertedQuery = `
+SELECT rowid, file, line, url
+FROM httpreport
+WHERE status = 'inserted';
+`
+
+// entryToMeasure contains data about an entry to measure.
+type entryToMeasure struct {
+	rowid int64
+	file  string
+	line  int64
+	url   string
+}
+
+// getEntriesToMeasure gets the entries to measure from the database.
+func (s *Subcommand) getEntriesToMeasure(db *sql.DB) (out []*entryToMeasure) {
+	// execute the query and get the matching rows
+	rows := runtimex.Try1(db.Query(selectInsertedQuery))
+	defer rows.Close()
+
+	// convert the rows to a list of [entryToMeasure]
+	for rows.Next() {
+		entry := &entryToMeasure{}
+		runtimex.Try0(rows.Scan(&entry.rowid, &entry.file, &entry.line, &entry.url))
+		out = append(out, entry)
+	}
+
+	// make sure there was no error while reading
+	runtimex.Try0(rows.Err())
+
+	// return list to the caller
+	return
+}
+
+// measureEntries measures all the entries we need to measure
+func (s *Subcommand) measureEntries(ctx context.Context, db *sql.DB, entries []*entryToMeasure) {
+	// create the progress bar to show the user progress
+	bar := progressbar.NewOptions64(
+		int64(len(entries)),
+		progressbar.OptionShowDescriptionAtLineEnd(),
+		progressbar.OptionSetWidth(40),
+		progressbar.OptionShowCount(),
+		progressbar.OptionSetPredictTime(true),
+		progressbar.OptionThrottle(65*time.Millisecond),
+		progressbar.OptionOnCompletion(func() {
+			fmt.Fprint(os.Stdout, "\n")
+		}),
+		progressbar.OptionSetWriter(os.Stdout),
+	)
+
+	// walk through each entry until we're interrupted by the context
+	for idx := 0; idx < len(entries) && ctx.Err() == nil; idx++ {
+		_ = bar.Add(1)
+		s.measureSingleEntry(db, entries[idx])
+	}
+}
+
+// measureSingleEntry measures a single entry
+func (s *Subcommand) measureSingleEntry(db *sql.DB, entry *entryToMeasure) {
+	// parse the entry URL
+	URL := runtimex.Try1(url.Parse(entry.url))
+
+	// handle the input URLs we cannot fetch
+	if URL.Scheme != "http" && URL.Scheme != "https" {
+		s.updateEntry(db, "skipped", &result{}, entry.rowid)
+		return
+	}
+
+	// follow the redirect chain and classify the result
+	r := s.fetch(URL)
+	r.classify(URL)
+
+	// update the database
+	s.updateEntry(db, "measured", r, entry.rowid)
+}
+
+// maxRedirects is the maximum number of redirects we follow.
+const maxRedirects = 10
+
+// maxBodySize is the maximum number of body bytes we read.
+const maxBodySize = 1 << 18
+
+// errTooManyRedirects indicates we stopped following redirects.
+var errTooManyRedirects = errors.New("httpreport: too many redirects")
+
+// redirect is an entry of the redirect chain.
+type redirect struct {
+	// URL is the URL we fetched.
+	URL string `json:"url"`
+
+	// StatusCode is the status code we received.
+	StatusCode int64 `json:"status_code"`
+}
+
+// result is the result of fetching an URL.
+type result struct {
+	// body contains the first bytes of the final response body.
+	body []byte
+
+	// classification is the classification of this result.
+	classification string
+
+	// err is the error that occurred, if any.
+	err error
+
+	// proposedURL is the URL we propose to use instead of the original URL.
+	proposedURL string
+
+	// redirects is the redirect chain including the final response.
+	redirects []*redirect
+}
+
+// finalURL returns the last URL of the redirect chain or an empty string.
+func (r *result) finalURL() string {
+	if len(r.redirects) <= 0 {
+		return ""
+	}
+	return r.redirects[len(r.redirects)-1].URL
+}
+
+// statusCode returns the last status code of the redirect chain or zero.
+func (r *result) statusCode() int64 {
+	if len(r.redirects) <= 0 {
+		return 0
+	}
+	return r.redirects[len(r.redirects)-1].StatusCode
+}
+
+// fetch fetches the given URL following redirects. The DNS lookups use
+// the DoH resolver so that we detect dead domains like dnsreport does.
+func (s *Subcommand) fetch(URL *url.URL) *result {
+	// create context bound to timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// create the HTTP transport using the DoH resolver
+	netx := &netxlite.Netx{}
+	reso := netx.NewParallelDNSOverHTTPSResolver(log.Log, s.DNSOverHTTPSServerURL)
+	defer reso.CloseIdleConnections()
+	dialer := netx.NewDialerWithResolver(log.Log, reso)
+	tlsDialer := netxlite.NewTLSDialerWithConfig(
+		dialer,
+		netx.NewTLSHandshakerStdlib(log.Log),
+		&tls.Config{RootCAs: s.RootCAs},
+	)
+	txp := netxlite.NewHTTPTransportWithOptions(log.Log, dialer, tlsDialer)
+	defer txp.CloseIdleConnections()
+
+	// follow the redirect chain manually so we can record it
+	r := &result{}
+	for idx := 0; idx < maxRedirects; idx++ {
+		resp, err := s.roundTrip(ctx, txp, URL)
+		if err != nil {
+			r.err = err
+			return r
+		}
+		r.redirects = append(r.redirects, &redirect{
+			URL:        URL.String(),
+			StatusCode: int64(resp.StatusCode),
+		})
+
+		// stop when this is not a redirect, after reading the body
+		location, err := resp.Location()
+		if !isRedirect(resp.StatusCode) || err != nil {
+			reader := io.LimitReader(resp.Body, maxBodySize)
+			r.body, r.err = netxlite.ReadAllContext(ctx, reader)
+			resp.Body.Close()
+			return r
+		}
+		resp.Body.Close()
+		URL = location
+	}
+	r.err = errTooManyRedirects
+	return r
+}
+
+// roundTrip sends a GET request for the given URL.
+func (s *Subcommand) roundTrip(ctx context.Context, txp model.HTTPTransport, URL *url.URL) (*http.Response, error) {
+	req := runtimex.Try1(http.NewRequestWithContext(ctx, "GET", URL.String(), nil))
+	req.Header.Set("Accept", model.HTTPHeaderAccept)
+	req.Header.Set("Accept-Language", model.HTTPHeaderAcceptLanguage)
+	req.Header.Set("User-Agent", model.HTTPHeaderUserAgent)
+	return txp.RoundTrip(req)
+}
+
+// isRedirect returns whether the status code is a redirect.
+func isRedirect(statusCode int) bool {
+	switch statusCode {
+	case 301, 302, 303, 307, 308:
+		return true
+	default:
+		return false
+	}
+}
+
+// updateQuery is the query to update a given entry.
+const updateQuery = `
+UPDATE httpreport
+SET status = ?,
+    classification = ?,
+    status_code = ?,
+    redirects = ?,
+    final_url = ?,
+    proposed_url = ?,
+    failure = ?
+WHERE
+    rowid = ?;
+`
+
+// updateEntry updates an entry into the database using the given result.
+func (s *Subcommand) updateEntry(db *sql.DB, status string, r *result, rowid int64) {
+	// create transaction for inserting into the database
+	tx := runtimex.Try1(db.Begin())
+	defer tx.Commit()
+
+	// make sure we serialize an empty redirect chain as []
+	redirects := r.redirects
+	if redirects == nil {
+		redirects = []*redirect{}
+	}
+
+	// update the existing row with new information
+	_ = runtimex.Try1(tx.Exec(
+		updateQuery,
+		status,
+		r.classification,
+		r.statusCode(),
+		string(runtimex.Try1(json.Marshal(redirects))),
+		r.finalURL(),
+		r.proposedURL,
+		measurexlite.NewFailure(r.err), // deals with nil gracefully
+		rowid,
+	))
+}
+
+// selectProblemsQuery selects the entries that researchers should examine
+const selectProblemsQuery = `
+SELECT file, line, url, classification, status_code,
+	final_url, proposed_url, failure
+FROM httpreport
+WHERE status = 'measured' AND classification != 'ok';
+`
+
+// writeReport writes a CSV report containing the results inside the
+// database that should be examined by researchers.
+func (s *Subcommand) writeReport(db *sql.DB) {
+	log.Infof("writing researchers' report file: %s", s.ReportFile)
+
+	// create the output file
+	filep := runtimex.Try1(os.Create(s.ReportFile))
+
+	// create the CSV writer wrapper
+	writer := csv.NewWriter(filep)
+
+	// write the first CSV row with headers
+	runtimex.Try0(writer.Write([]string{
+		"file", "line", "url", "classification", "status_code",
+		"final_url", "proposed_url", "failure",
+	}))
+	writer.Flush()
+
+	// query all the entries that need attention
+	rows := runtimex.Try1(db.Query(selectProblemsQuery))
+	defer rows.Close()
+
+	// write each row into the CSV file
+	for rows.Next() {
+		// read from query
+		var (
+			file           string
+			line           int64
+			url            string
+			classification string
+			statusCode     int64
+			finalURL       string
+			proposedURL    string
+			failure        *string
+		)
+		runtimex.Try0(rows.Scan(
+			&file, &line, &url, &classification, &statusCode,
+			&finalURL, &proposedURL, &failure,
+		))
+
+		// the CSV format does not distinguish between NULL and empty
+		var failureString string
+		if failure != nil {
+			failureString = *failure
+		}
+
+		// write to CSV
+		runtimex.Try0(writer.Write([]string{
+			file,
+			strconv.FormatInt(line, 10),
+			url,
+			classification,
+			strconv.FormatInt(statusCode, 10),
+			finalURL,
+			proposedURL,
+			failureString,
+		}))
+		writer.Flush()
+	}
+
+	// make sure there was no error while reading
+	runtimex.Try0(rows.Err())
+
+	// make sure there was no error while writing
+	runtimex.Try0(writer.Error())
+	runtimex.Try0(filep.Close())
+}
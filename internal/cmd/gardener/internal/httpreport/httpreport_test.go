@@ -0,0 +1,372 @@
+package httpreport
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/csv"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/miekg/dns"
+	"github.com/ooni/probe-cli/v3/internal/fsx"
+	"github.com/ooni/probe-cli/v3/internal/netxlite"
+	"github.com/ooni/probe-cli/v3/internal/runtimex"
+)
+
+// newServerDNSOverHTTPS creates a fake DNS-over-HTTPS server resolving
+// every domain to 127.0.0.1 except for nxdomain.example.
+func newServerDNSOverHTTPS() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// read incoming DNS query
+		data, err := netxlite.ReadAllContext(r.Context(), r.Body)
+		if err != nil {
+			w.WriteHeader(400)
+			return
+		}
+
+		// parse incoming DNS query
+		query := &dns.Msg{}
+		if err := query.Unpack(data); err != nil {
+			w.WriteHeader(400)
+			return
+		}
+
+		// obtain the query question
+		runtimex.Assert(len(query.Question) >= 1, "no questions")
+		q0 := query.Question[0]
+
+		// create DNS response
+		resp := &dns.Msg{}
+		switch {
+		case q0.Name == "nxdomain.example.":
+			resp.SetRcode(query, dns.RcodeNameError)
+		case q0.Qtype == dns.TypeA:
+			resp.SetReply(query)
+			resp.Answer = append(resp.Answer, &dns.A{
+				Hdr: dns.RR_Header{
+					Name:     q0.Name,
+					Rrtype:   dns.TypeA,
+					Class:    dns.ClassINET,
+					Ttl:      1234,
+					Rdlength: 0,
+				},
+				A: net.IPv4(127, 0, 0, 1),
+			})
+		default:
+			resp.SetReply(query)
+		}
+
+		// serialize and return the reponse
+		data, err = resp.Pack()
+		if err != nil {
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Add("content-type", "application/dns-message")
+		w.Write(data)
+	}))
+}
+
+// newServerHTTP creates a fake HTTP server whose behavior depends on the
+// host header. The httpsURL argument is the URL of the HTTPS server.
+func newServerHTTP(httpsURL *url.URL) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, port := runtimex.Try2(net.SplitHostPort(r.Host))
+		switch host {
+		case "parked.example":
+			w.Write([]byte("<html><body>This domain is for sale!</body></html>"))
+		case "moved.example":
+			http.Redirect(w, r, "http://www.newsite.example:"+port+"/", http.StatusMovedPermanently)
+		case "temporary.example":
+			http.Redirect(w, r, "http://www.newsite.example:"+port+"/", http.StatusFound)
+		case "example.com":
+			http.Redirect(w, r, "https://example.com:"+httpsURL.Port()+"/", http.StatusMovedPermanently)
+		case "gone.example":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.Write([]byte("<html><body>Hello, world!</body></html>"))
+		}
+	}))
+}
+
+// newServerHTTPS creates a fake HTTPS server.
+func newServerHTTPS() *httptest.Server {
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html><body>Hello, world!</body></html>"))
+	}))
+}
+
+// writeTestList writes the test list used for testing.
+func writeTestList(repoDir, port string) string {
+	listsDir := filepath.Join(repoDir, "lists")
+	runtimex.Try0(os.MkdirAll(listsDir, 0700))
+	filename := filepath.Join(listsDir, "it.csv")
+	filep := runtimex.Try1(os.Create(filename))
+	writer := csv.NewWriter(filep)
+	records := [][]string{
+		{"url", "category_code", "category_description", "date_added", "source", "notes"},
+		{"http://ok.example:" + port + "/", "NEWS", "News Media", "2017-04-12", "", ""},
+		{"http://parked.example:" + port + "/", "NEWS", "News Media", "2017-04-12", "", ""},
+		{"http://moved.example:" + port + "/", "NEWS", "News Media", "2017-04-12", "", ""},
+		{"http://temporary.example:" + port + "/", "NEWS", "News Media", "2017-04-12", "", ""},
+		{"http://example.com:" + port + "/", "NEWS", "News Media", "2017-04-12", "", ""},
+		{"http://gone.example:" + port + "/", "NEWS", "News Media", "2017-04-12", "", ""},
+		{"http://nxdomain.example/", "NEWS", "News Media", "2017-04-12", "", ""},
+		{"ftp://ok.example/", "NEWS", "News Media", "2017-04-12", "", ""},
+	}
+	runtimex.Try0(writer.WriteAll(records))
+	runtimex.Try0(filep.Close())
+	return filename
+}
+
+// readReport reads the CSV report generated by the subcommand.
+func readReport(reportFile string) [][]string {
+	filep := runtimex.Try1(fsx.OpenFile(reportFile))
+	defer filep.Close()
+	return runtimex.Try1(csv.NewReader(filep).ReadAll())
+}
+
+func TestWorkingAsIntended(t *testing.T) {
+	// create DNS-over-HTTPS server running on localhost
+	dnsSrvr := newServerDNSOverHTTPS()
+	defer dnsSrvr.Close()
+
+	// create HTTPS server running on localhost
+	httpsSrvr := newServerHTTPS()
+	defer httpsSrvr.Close()
+	httpsURL := runtimex.Try1(url.Parse(httpsSrvr.URL))
+
+	// create HTTP server running on localhost
+	httpSrvr := newServerHTTP(httpsURL)
+	defer httpSrvr.Close()
+	httpURL := runtimex.Try1(url.Parse(httpSrvr.URL))
+
+	// make sure we trust the HTTPS server certificate
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(httpsSrvr.Certificate())
+
+	// create the test list
+	tempDir := t.TempDir()
+	repoDir := filepath.Join(tempDir, "repo")
+	listFile := writeTestList(repoDir, httpURL.Port())
+
+	// initialize the httpreport subcommand
+	databaseFile := filepath.Join(tempDir, "httpreport.sqlite3")
+	reportFile := filepath.Join(tempDir, "httpreport.csv")
+	sc := &Subcommand{
+		DNSOverHTTPSServerURL: dnsSrvr.URL,
+		Database:              databaseFile,
+		ReportFile:            reportFile,
+		RepositoryDir:         repoDir,
+		RootCAs:               rootCAs,
+	}
+
+	// expectedReport is the report we expect
+	port := httpURL.Port()
+	expectedReport := [][]string{{
+		"file", "line", "url", "classification", "status_code",
+		"final_url", "proposed_url", "failure",
+	}, {
+		listFile, "3", "http://parked.example:" + port + "/", "parked", "200",
+		"http://parked.example:" + port + "/", "", "",
+	}, {
+		listFile, "4", "http://moved.example:" + port + "/", "redirect_other_domain", "200",
+		"http://www.newsite.example:" + port + "/", "http://www.newsite.example:" + port + "/", "",
+	}, {
+		listFile, "6", "http://example.com:" + port + "/", "https_upgrade", "200",
+		"https://example.com:" + httpsURL.Port() + "/", "https://example.com:" + httpsURL.Port() + "/", "",
+	}, {
+		listFile, "7", "http://gone.example:" + port + "/", "http_error", "404",
+		"http://gone.example:" + port + "/", "", "",
+	}, {
+		listFile, "8", "http://nxdomain.example/", "dead_domain", "0",
+		"", "", "dns_nxdomain_error",
+	}}
+
+	t.Run("without pre-existing database", func(t *testing.T) {
+		// make sure there is no databaseFile when testing
+		runtimex.Try0(os.RemoveAll(databaseFile))
+
+		// obtain previous value of loadFromRepository counter
+		prev := loadedFromRepository.Load()
+
+		// run the main function of the subcommand
+		sc.Main(context.Background())
+
+		// make sure we loaded once from the repository
+		if value := loadedFromRepository.Load(); prev+1 != value {
+			t.Fatal("expected", prev+1, "got", value)
+		}
+
+		// validate the results
+		if diff := cmp.Diff(expectedReport, readReport(reportFile)); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("with a pre-existing database", func(t *testing.T) {
+		// make sure there is no databaseFile when testing
+		runtimex.Try0(os.RemoveAll(databaseFile))
+
+		// obtain previous value of loadFromRepository counter
+		prev := loadedFromRepository.Load()
+
+		// run the main function of the subcommand with a cancelled context, which
+		// should prevent us from processing the URLs
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel() // immediately
+		sc.Main(ctx)
+
+		// make sure we loaded once from the repository
+		if value := loadedFromRepository.Load(); prev+1 != value {
+			t.Fatal("expected", prev+1, "got", value)
+		}
+
+		// make sure the report only contains the headers
+		if diff := cmp.Diff(expectedReport[:1], readReport(reportFile)); diff != "" {
+			t.Fatal(diff)
+		}
+
+		// run again with background context, which should cause us to
+		// start processing from a pre-existing database.
+		sc.Main(context.Background())
+
+		// make sure the counter remained the same after the second attempt
+		if value := loadedFromRepository.Load(); prev+1 != value {
+			t.Fatal("expected", prev+1, "got", value)
+		}
+
+		// validate the results
+		if diff := cmp.Diff(expectedReport, readReport(reportFile)); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+}
+
+func TestClassify(t *testing.T) {
+	// errNXDOMAIN is the error returned by netxlite for NXDOMAIN
+	errNXDOMAIN := &netxlite.ErrWrapper{
+		Failure:    netxlite.FailureDNSNXDOMAINError,
+		Operation:  netxlite.ResolveOperation,
+		WrappedErr: netxlite.ErrOODNSNoSuchHost,
+	}
+
+	type testcase struct {
+		name                 string
+		original             string
+		result               *result
+		expectClassification string
+		expectProposedURL    string
+	}
+
+	cases := []testcase{{
+		name:     "NXDOMAIN for the original domain",
+		original: "http://example.com/",
+		result: &result{
+			err: errNXDOMAIN,
+		},
+		expectClassification: classificationDeadDomain,
+		expectProposedURL:    "",
+	}, {
+		name:     "NXDOMAIN after a redirect",
+		original: "http://example.com/",
+		result: &result{
+			err:       errNXDOMAIN,
+			redirects: []*redirect{{URL: "http://example.com/", StatusCode: 301}},
+		},
+		expectClassification: classificationNetworkError,
+		expectProposedURL:    "",
+	}, {
+		name:     "too many redirects",
+		original: "http://example.com/",
+		result: &result{
+			err: errTooManyRedirects,
+		},
+		expectClassification: classificationNetworkError,
+		expectProposedURL:    "",
+	}, {
+		name:     "HTTP upgrade to www",
+		original: "http://example.com/",
+		result: &result{
+			redirects: []*redirect{
+				{URL: "http://example.com/", StatusCode: 301},
+				{URL: "http://www.example.com/", StatusCode: 200},
+			},
+		},
+		expectClassification: classificationOK,
+		expectProposedURL:    "",
+	}, {
+		name:     "HTTPS upgrade using a temporary redirect",
+		original: "http://example.com/",
+		result: &result{
+			redirects: []*redirect{
+				{URL: "http://example.com/", StatusCode: 302},
+				{URL: "https://www.example.com/", StatusCode: 200},
+			},
+		},
+		expectClassification: classificationHTTPSUpgrade,
+		expectProposedURL:    "https://www.example.com/",
+	}, {
+		name:     "mixed redirects to another domain",
+		original: "http://example.com/",
+		result: &result{
+			redirects: []*redirect{
+				{URL: "http://example.com/", StatusCode: 301},
+				{URL: "http://example.org/", StatusCode: 307},
+				{URL: "http://example.net/", StatusCode: 200},
+			},
+		},
+		expectClassification: classificationOK,
+		expectProposedURL:    "",
+	}, {
+		name:     "permanent redirects to another domain",
+		original: "http://example.com/",
+		result: &result{
+			redirects: []*redirect{
+				{URL: "http://example.com/", StatusCode: 308},
+				{URL: "http://example.org/", StatusCode: 301},
+				{URL: "https://example.net/", StatusCode: 200},
+			},
+		},
+		expectClassification: classificationRedirectOtherDomain,
+		expectProposedURL:    "https://example.net/",
+	}, {
+		name:     "parked page after a redirect",
+		original: "http://example.com/",
+		result: &result{
+			body: []byte(`<a href="https://www.HugeDomains.com/">Buy now</a>`),
+			redirects: []*redirect{
+				{URL: "http://example.com/", StatusCode: 301},
+				{URL: "http://example.org/", StatusCode: 200},
+			},
+		},
+		expectClassification: classificationParked,
+		expectProposedURL:    "",
+	}, {
+		name:     "server error",
+		original: "http://example.com/",
+		result: &result{
+			redirects: []*redirect{{URL: "http://example.com/", StatusCode: 503}},
+		},
+		expectClassification: classificationHTTPError,
+		expectProposedURL:    "",
+	}}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.result.classify(runtimex.Try1(url.Parse(tc.original)))
+			if tc.result.classification != tc.expectClassification {
+				t.Fatal("expected", tc.expectClassification, "got", tc.result.classification)
+			}
+			if tc.result.proposedURL != tc.expectProposedURL {
+				t.Fatalf("expected %q, got %q", tc.expectProposedURL, tc.result.proposedURL)
+			}
+		})
+	}
+}
@@ -20,6 +20,10 @@ type Subcommand struct {
 	// that we're currently using to avoid repeating measurements.
 	DNSReportDatabase string
 
+	// HTTPReportDatabase is the MANDATORY file containing the `httpreport` database
+	// that we're currently using to avoid repeating measurements.
+	HTTPReportDatabase string
+
 	// RepositoryDir is the MANDATORY directory where to clone the test lists repository.
 	RepositoryDir string
 
@@ -46,6 +50,9 @@ func (s *Subcommand) Main() {
 	// would require us to write a more complex diff.
 	runtimex.Try0(shellx.Run(log.Log, "rm", "-f", s.DNSReportDatabase))
 
+	// likewise, possibly remove an existing httpreport.sqlite3 database
+	runtimex.Try0(shellx.Run(log.Log, "rm", "-f", s.HTTPReportDatabase))
+
 	// clone a new working copy
 	runtimex.Try0(shellx.Run(log.Log, "git", "clone", testListsRepo, s.RepositoryDir))
 
@@ -69,12 +69,14 @@ func TestWorkingAsIntended(t *testing.T) {
 	// create the subcommand instance
 	repodir := filepath.Join("testdata", "repo")
 	dnsreportfile := filepath.Join("testdata", "dnsreport.sqlite3")
+	httpreportfile := filepath.Join("testdata", "httpreport.sqlite3")
 	sc := &sync.Subcommand{
-		DNSReportDatabase: dnsreportfile,
-		RepositoryDir:     repodir,
-		OsChdir:           cc.Chdir,
-		OsGetwd:           cc.Getwd,
-		TimeNow:           cc.TimeNow,
+		DNSReportDatabase:  dnsreportfile,
+		HTTPReportDatabase: httpreportfile,
+		RepositoryDir:      repodir,
+		OsChdir:            cc.Chdir,
+		OsGetwd:            cc.Getwd,
+		TimeNow:            cc.TimeNow,
 	}
 
 	// run the subcommand with custom shellx dependencies
@@ -86,6 +88,7 @@ func TestWorkingAsIntended(t *testing.T) {
 	expect := []string{
 		fmt.Sprintf("rm -rf %s", repodir),
 		fmt.Sprintf("rm -f %s", dnsreportfile),
+		fmt.Sprintf("rm -f %s", httpreportfile),
 		fmt.Sprintf("git clone https://github.com/citizenlab/test-lists %s", repodir),
 		fmt.Sprintf("cd %s", repodir),
 		"git checkout -b gardener_20230315T114300Z",
@@ -158,6 +158,39 @@ func Rewrite(filename string, shouldKeep func(URL string) bool) {
 	csvWriteBack(filename, records)
 }
 
+// ErrURLNotFound indicates that the URL to replace is not in the test list.
+var ErrURLNotFound = errors.New("testlists: URL not found")
+
+// ErrURLAlreadyPresent indicates that the replacement URL is already in the test list.
+var ErrURLAlreadyPresent = errors.New("testlists: URL already present")
+
+// Replace rewrites a file in the test lists replacing oldURL with newURL. This
+// function leaves the file untouched and returns [ErrURLNotFound] when oldURL is
+// not in the file or [ErrURLAlreadyPresent] when newURL is already in the file,
+// to avoid duplicate entries.
+func Replace(filename string, oldURL string, newURL string) error {
+	records := csvReadAndFilter(filename, func(URL string) bool {
+		return true
+	})
+	var found bool
+	for _, record := range records[1:] {
+		if record[0] == newURL {
+			return ErrURLAlreadyPresent
+		}
+		found = found || record[0] == oldURL
+	}
+	if !found {
+		return ErrURLNotFound
+	}
+	for _, record := range records[1:] {
+		if record[0] == oldURL {
+			record[0] = newURL
+		}
+	}
+	csvWriteBack(filename, records)
+	return nil
+}
+
 // csvReadAndFilter returns all the records that we shouldKeep.
 func csvReadAndFilter(filepath string, shouldKeep func(URL string) bool) [][]string {
 	// open file and create CSV reader
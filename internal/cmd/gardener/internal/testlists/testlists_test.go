@@ -1,6 +1,7 @@
 package testlists_test
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
@@ -63,3 +64,54 @@ func TestRewrite(t *testing.T) {
 		t.Fatal(diff)
 	}
 }
+
+func TestReplace(t *testing.T) {
+	// create a copy of the test list we want to rewrite
+	orig := filepath.Join("testdata", "it.csv")
+	copied := filepath.Join("testdata", "it-copy.csv")
+	if err := shellx.CopyFile(orig, copied, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// readCopy returns the content of the copied file
+	readCopy := func() []byte {
+		data, err := os.ReadFile(copied)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	t.Run("when the old URL is not in the file", func(t *testing.T) {
+		before := readCopy()
+		err := testlists.Replace(copied, "http://www.example.com/", "https://www.example.com/")
+		if !errors.Is(err, testlists.ErrURLNotFound) {
+			t.Fatal("unexpected error", err)
+		}
+		if diff := cmp.Diff(before, readCopy()); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("when the new URL is already in the file", func(t *testing.T) {
+		before := readCopy()
+		err := testlists.Replace(copied, "http://torrentroom.com/", "http://www.torrentroom.com/")
+		if !errors.Is(err, testlists.ErrURLAlreadyPresent) {
+			t.Fatal("unexpected error", err)
+		}
+		if diff := cmp.Diff(before, readCopy()); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("when we can replace the URL", func(t *testing.T) {
+		before := readCopy()
+		if err := testlists.Replace(copied, "http://torrentroom.com/", "https://torrentroom.com/"); err != nil {
+			t.Fatal(err)
+		}
+		expect := strings.Replace(string(before), "http://torrentroom.com/,", "https://torrentroom.com/,", 1)
+		if diff := cmp.Diff(expect, string(readCopy())); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+}
@@ -12,6 +12,8 @@ import (
 	"github.com/apex/log/handlers/cli"
 	"github.com/ooni/probe-cli/v3/internal/cmd/gardener/internal/dnsfix"
 	"github.com/ooni/probe-cli/v3/internal/cmd/gardener/internal/dnsreport"
+	"github.com/ooni/probe-cli/v3/internal/cmd/gardener/internal/httpfix"
+	"github.com/ooni/probe-cli/v3/internal/cmd/gardener/internal/httpreport"
 	"github.com/ooni/probe-cli/v3/internal/cmd/gardener/internal/sync"
 	"github.com/ooni/probe-cli/v3/internal/runtimex"
 	"github.com/ooni/probe-cli/v3/internal/version"
@@ -24,6 +26,9 @@ const repositoryDir = "citizenlab-test-lists"
 // dnsReportDatabase is the path of the database maintained by the dnsreport subcommand.
 const dnsReportDatabase = "dnsreport.sqlite3"
 
+// httpReportDatabase is the path of the database maintained by the httpreport subcommand.
+const httpReportDatabase = "httpreport.sqlite3"
+
 func main() {
 	// select a colourful apex/log handler
 	log.SetHandler(cli.New(os.Stderr))
@@ -42,11 +47,12 @@ func main() {
 		Args:  cobra.NoArgs,
 		Run: func(cmd *cobra.Command, args []string) {
 			sc := &sync.Subcommand{
-				DNSReportDatabase: dnsReportDatabase,
-				RepositoryDir:     repositoryDir,
-				OsChdir:           os.Chdir,
-				OsGetwd:           os.Getwd,
-				TimeNow:           time.Now,
+				DNSReportDatabase:  dnsReportDatabase,
+				HTTPReportDatabase: httpReportDatabase,
+				RepositoryDir:      repositoryDir,
+				OsChdir:            os.Chdir,
+				OsGetwd:            os.Getwd,
+				TimeNow:            time.Now,
 			}
 			sc.Main()
 		},
@@ -85,6 +91,39 @@ func main() {
 	}
 	rootCmd.AddCommand(dnsFixCmd)
 
+	// create the httpreport subcommand
+	httpReportCmd := &cobra.Command{
+		Use:   "httpreport",
+		Short: "Generates an HTTP report from the citizenlab/test-lists working copy",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			sc := &httpreport.Subcommand{
+				DNSOverHTTPSServerURL: "https://dns.google/dns-query",
+				Database:              httpReportDatabase,
+				ReportFile:            "httpreport.csv",
+				RepositoryDir:         repositoryDir,
+				RootCAs:               nil,
+			}
+			runInterruptible(sc.Main)
+		},
+	}
+	rootCmd.AddCommand(httpReportCmd)
+
+	// create the httpfix subcommand
+	httpFixCmd := &cobra.Command{
+		Use:   "httpfix",
+		Short: "Edits the citizenlab/test-lists using the report generated by httpreport",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			sc := &httpfix.Subcommand{
+				ReportFile: "httpreport.csv",
+				ReviewFile: "httpfix.md",
+			}
+			sc.Main()
+		},
+	}
+	rootCmd.AddCommand(httpFixCmd)
+
 	// execute the root command
 	runtimex.Try0(rootCmd.Execute())
 }
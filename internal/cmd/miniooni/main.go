@@ -27,6 +27,7 @@ import (
 type Options struct {
 	Annotations         []string
 	AuthFile            string
+	DryRun              bool
 	Emoji               bool
 	ExtraOptions        []string
 	HomeDir             string
@@ -35,16 +36,20 @@ type Options struct {
 	MaxRuntime          int64
 	NoJSON              bool
 	NoCollector         bool
+	PcapDir             string
 	ProbeServicesURL    string
 	Proxy               string
 	Random              bool
 	RepeatEvery         int64
 	ReportFile          string
+	SignMeasurements    bool
 	SnowflakeRendezvous string
 	SoftwareName        string
 	SoftwareVersion     string
+	SSLKeyLogFile       string
 	TorArgs             []string
 	TorBinary           string
+	TorColdStart        bool
 	Tunnel              string
 	Verbose             bool
 	Yes                 bool
@@ -100,6 +105,13 @@ func main() {
 		"do not submit measurements to the OONI collector",
 	)
 
+	flags.StringVar(
+		&globalOptions.PcapDir,
+		"pcap-dir",
+		"",
+		"write a pcapng file for each measurement into the given directory (Linux only, requires CAP_NET_RAW)",
+	)
+
 	flags.StringVar(
 		&globalOptions.ProbeServicesURL,
 		"probe-services",
@@ -129,6 +141,13 @@ func main() {
 		"set the output report file path (default: \"report.jsonl\")",
 	)
 
+	flags.BoolVar(
+		&globalOptions.SignMeasurements,
+		"sign-measurements",
+		false,
+		"sign submitted measurements using a per-installation key",
+	)
+
 	flags.StringVar(
 		&globalOptions.SnowflakeRendezvous,
 		"snowflake-rendezvous",
@@ -150,6 +169,13 @@ func main() {
 		"Set the version of the application",
 	)
 
+	flags.StringVar(
+		&globalOptions.SSLKeyLogFile,
+		"ssl-key-log-file",
+		os.Getenv("SSLKEYLOGFILE"),
+		"append the TLS and QUIC session secrets to the given file (default: $SSLKEYLOGFILE)",
+	)
+
 	flags.StringSliceVar(
 		&globalOptions.TorArgs,
 		"tor-args",
@@ -164,6 +190,13 @@ func main() {
 		"execute a specific tor binary",
 	)
 
+	flags.BoolVar(
+		&globalOptions.TorColdStart,
+		"tor-cold-start",
+		false,
+		"bootstrap --tunnel=tor without reusing the cached directory information",
+	)
+
 	flags.StringVar(
 		&globalOptions.Tunnel,
 		"tunnel",
@@ -192,6 +225,7 @@ func main() {
 	registerAllExperiments(rootCmd, &globalOptions)
 	registerOONIRun(rootCmd, &globalOptions)
 	registerJavaScript(rootCmd, &globalOptions)
+	registerResolvers(rootCmd, &globalOptions)
 
 	if err := rootCmd.Execute(); err != nil {
 		os.Exit(1)
@@ -236,6 +270,7 @@ func registerOONIRun(rootCmd *cobra.Command, globalOptions *Options) {
 		"",
 		"Path to a file containing a bearer token for fetching a remote OONI Run v2 descriptor",
 	)
+	registerOONIRunLint(subCmd, globalOptions)
 }
 
 // registerAllExperiments registers a subcommand for each experiment
@@ -6,12 +6,12 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
-	"os"
 
+	"github.com/apex/log"
 	"github.com/ooni/probe-cli/v3/internal/engine"
 	"github.com/ooni/probe-cli/v3/internal/oonirun"
+	"github.com/spf13/cobra"
 )
 
 // ooniRunMain runs the experiments described by the given OONI Run URLs. This
@@ -23,6 +23,7 @@ func ooniRunMain(ctx context.Context,
 		AcceptChanges: currentOptions.Yes,
 		AuthFile:      currentOptions.AuthFile,
 		Annotations:   annotations,
+		DryRun:        currentOptions.DryRun,
 		KVStore:       sess.KeyValueStore(),
 		MaxRuntime:    currentOptions.MaxRuntime,
 		NoCollector:   currentOptions.NoCollector,
@@ -44,21 +45,79 @@ func ooniRunMain(ctx context.Context,
 		}
 	}
 	for _, filename := range currentOptions.InputFilePaths {
-		data, err := os.ReadFile(filename) // #nosec G304 - this is working as intended
+		descr, err := oonirun.V2ReadDescriptorFile(filename)
 		if err != nil {
 			logger.Warnf("oonirun: reading OONI Run v2 descriptor failed: %s", err.Error())
 			continue
 		}
-		var descr oonirun.V2Descriptor
-		if err := json.Unmarshal(data, &descr); err != nil {
-			logger.Warnf("oonirun: parsing OONI Run v2 descriptor failed: %s", err.Error())
-			continue
-		}
 		logger.Infof("oonirun: running '%s'", descr.Name)
 		logger.Infof("oonirun: link authored by '%s'", descr.Author)
-		if err := oonirun.V2MeasureDescriptor(ctx, cfg, &descr); err != nil {
+		if err := oonirun.V2MeasureDescriptor(ctx, cfg, descr); err != nil {
 			logger.Warnf("oonirun: running link failed: %s", err.Error())
 			continue
 		}
 	}
 }
+
+// registerOONIRunLint registers the oonirun subcommands for validating,
+// linting, and dry running OONI Run v2 descriptors.
+func registerOONIRunLint(ooniRunCmd *cobra.Command, globalOptions *Options) {
+	ooniRunCmd.AddCommand(&cobra.Command{
+		Use:   "validate FILE...",
+		Short: "Checks whether OONI Run v2 descriptors contain errors",
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			ooniRunLintMain(args, false)
+		},
+	})
+
+	ooniRunCmd.AddCommand(&cobra.Command{
+		Use:   "lint FILE...",
+		Short: "Checks whether OONI Run v2 descriptors contain errors or warnings",
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			ooniRunLintMain(args, true)
+		},
+	})
+
+	dryRunCmd := &cobra.Command{
+		Use:   "dryrun",
+		Short: "Shows what running OONI Run v2 links would do without running them",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			globalOptions.DryRun = true
+			MainWithConfiguration(ooniRunCmd.Use, globalOptions)
+		},
+	}
+	ooniRunCmd.AddCommand(dryRunCmd)
+	flags := dryRunCmd.Flags()
+	flags.StringSliceVarP(
+		&globalOptions.Inputs,
+		"input",
+		"i",
+		[]string{},
+		"URL of the OONI Run v2 descriptor to dry run (may be specified multiple times)",
+	)
+	flags.StringSliceVarP(
+		&globalOptions.InputFilePaths,
+		"input-file",
+		"f",
+		[]string{},
+		"Path to the OONI Run v2 descriptor to dry run (may be specified multiple times)",
+	)
+	flags.StringVarP(
+		&globalOptions.AuthFile,
+		"bearer-token-file",
+		"",
+		"",
+		"Path to a file containing a bearer token for fetching a remote OONI Run v2 descriptor",
+	)
+}
+
+// ooniRunLintMain lints the OONI Run v2 descriptors inside the given files and
+// exits with failure if there are errors or, when strict, warnings.
+func ooniRunLintMain(filenames []string, strict bool) {
+	if err := oonirun.V2LintFiles(context.Background(), log.Log, filenames, strict); err != nil {
+		log.Fatalf("%s", err.Error())
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+//
+// Inspecting and steering the engine resolver
+//
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+
+	"github.com/apex/log"
+	"github.com/ooni/probe-cli/v3/internal/engineresolver"
+	"github.com/ooni/probe-cli/v3/internal/kvstore"
+	"github.com/ooni/probe-cli/v3/internal/runtimex"
+	"github.com/spf13/cobra"
+)
+
+// registerResolvers registers the resolvers subcommand
+func registerResolvers(rootCmd *cobra.Command, globalOptions *Options) {
+	subCmd := &cobra.Command{
+		Use:   "resolvers",
+		Short: "Lists the resolvers used by the engine along with their health",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			resolversListMain(newEngineResolverOrPanic(globalOptions))
+		},
+	}
+	rootCmd.AddCommand(subCmd)
+
+	// resolversAction is a resolvers subcommand taking a URL argument.
+	type resolversAction struct {
+		use   string
+		short string
+		fn    func(reso *engineresolver.Resolver, URL string) error
+	}
+	actions := []resolversAction{{
+		use:   "pin URL",
+		short: "Always tries the given resolver first",
+		fn:    (*engineresolver.Resolver).Pin,
+	}, {
+		use:   "blacklist URL",
+		short: "Never uses the given resolver",
+		fn:    (*engineresolver.Resolver).Blacklist,
+	}, {
+		use:   "unblacklist URL",
+		short: "Removes the given resolver from the blacklist",
+		fn:    (*engineresolver.Resolver).Unblacklist,
+	}, {
+		use:   "add URL",
+		short: "Adds a custom DoH (https://...) or DoT (dot://...) resolver",
+		fn:    (*engineresolver.Resolver).AddCustom,
+	}, {
+		use:   "remove URL",
+		short: "Removes a custom resolver",
+		fn:    (*engineresolver.Resolver).RemoveCustom,
+	}}
+	for _, action := range actions {
+		action := action
+		subCmd.AddCommand(&cobra.Command{
+			Use:   action.use,
+			Short: action.short,
+			Args:  cobra.ExactArgs(1),
+			Run: func(cmd *cobra.Command, args []string) {
+				reso := newEngineResolverOrPanic(globalOptions)
+				resolversMaybeFatal(action.fn(reso, args[0]))
+				resolversListMain(reso)
+			},
+		})
+	}
+
+	subCmd.AddCommand(&cobra.Command{
+		Use:   "unpin",
+		Short: "Removes the pinned resolver, if any",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			reso := newEngineResolverOrPanic(globalOptions)
+			resolversMaybeFatal(reso.Unpin())
+			resolversListMain(reso)
+		},
+	})
+}
+
+// newEngineResolverOrPanic returns an [engineresolver.Resolver] using
+// the same key-value store used by the measurement session.
+func newEngineResolverOrPanic(currentOptions *Options) *engineresolver.Resolver {
+	homeDir := gethomedir(currentOptions.HomeDir)
+	runtimex.Assert(homeDir != "", "home directory is empty")
+	enginedir := filepath.Join(path.Join(homeDir, ".miniooni"), "engine")
+	kvstore, err := kvstore.NewFS(enginedir)
+	runtimex.PanicOnError(err, "cannot create engine directory")
+	return &engineresolver.Resolver{KVStore: kvstore}
+}
+
+// resolversMaybeFatal exits with failure if err is not nil.
+func resolversMaybeFatal(err error) {
+	if err != nil {
+		log.Fatalf("resolvers: %s", err.Error())
+	}
+}
+
+// resolversListMain prints the resolvers known to the engine.
+func resolversListMain(reso *engineresolver.Resolver) {
+	for _, e := range reso.Entries() {
+		var flags string
+		if e.Pinned {
+			flags += " [pinned]"
+		}
+		if e.Blacklisted {
+			flags += " [blacklisted]"
+		}
+		if e.Custom {
+			flags += " [custom]"
+		}
+		fmt.Printf("%.3f %s%s\n", e.Score, e.URL, flags)
+		if e.LastError != "" {
+			fmt.Printf("      last error: %s\n", e.LastError)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"io"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -34,14 +35,18 @@ func newSessionOrPanic(ctx context.Context, currentOptions *Options,
 	runtimex.PanicOnError(err, "cannot create tunnelDir")
 
 	config := engine.SessionConfig{
+		KeyLogWriter:        maybeOpenKeyLogFileOrPanic(currentOptions.SSLKeyLogFile),
 		KVStore:             kvstore,
 		Logger:              logger,
+		PacketCaptureDir:    currentOptions.PcapDir,
 		ProxyURL:            proxyURL,
+		SignMeasurements:    currentOptions.SignMeasurements,
 		SnowflakeRendezvous: currentOptions.SnowflakeRendezvous,
 		SoftwareName:        currentOptions.SoftwareName,
 		SoftwareVersion:     currentOptions.SoftwareVersion,
 		TorArgs:             currentOptions.TorArgs,
 		TorBinary:           currentOptions.TorBinary,
+		TorColdStart:        currentOptions.TorColdStart,
 		TunnelDir:           tunnelDir,
 	}
 	if currentOptions.ProbeServicesURL != "" {
@@ -58,6 +63,19 @@ func newSessionOrPanic(ctx context.Context, currentOptions *Options,
 	return sess
 }
 
+// maybeOpenKeyLogFileOrPanic opens the SSLKEYLOGFILE for appending, if
+// configured, and otherwise returns a nil writer.
+func maybeOpenKeyLogFileOrPanic(filename string) io.Writer {
+	if filename == "" {
+		return nil
+	}
+	log.Warnf("writing TLS and QUIC session secrets to %s", filename)
+	log.Warn("anyone with access to this file can decrypt your traffic")
+	filep, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	runtimex.PanicOnError(err, "cannot open the SSLKEYLOGFILE")
+	return filep
+}
+
 func lookupBackendsOrPanic(ctx context.Context, sess *engine.Session) {
 	log.Info("Looking up OONI backends; please be patient...")
 	err := sess.MaybeLookupBackendsContext(ctx)
@@ -72,6 +90,10 @@ func lookupLocationOrPanic(ctx context.Context, sess *engine.Session) {
 	log.Debugf("- IP: %s", sess.ProbeIP()) // make sure it does not appear in default logs
 	log.Infof("- country: %s", sess.ProbeCC())
 	log.Infof("- network: %s (%s)", sess.ProbeNetworkName(), sess.ProbeASNString())
+	if v4, v6 := sess.ProbeIPv4Egress(), sess.ProbeIPv6Egress(); v4 != nil && v6 != nil {
+		log.Infof("- dual stack: IPv4 via %s (AS%d), IPv6 via %s (AS%d)",
+			v4.NetworkName, v4.ASN, v6.NetworkName, v6.ASN)
+	}
 	log.Infof("- resolver's IP: %s", sess.ResolverIP())
 	log.Infof("- resolver's network: %s (%s)", sess.ResolverNetworkName(),
 		sess.ResolverASNString())
@@ -0,0 +1,187 @@
+// Command oobackend implements an in-memory stand-in for the OONI probe
+// services API for running ooniprobe end-to-end without the real backend.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/oobackend"
+	"github.com/ooni/probe-cli/v3/internal/runtimex"
+)
+
+// stringList is a [flag.Value] collecting a list of strings.
+type stringList []string
+
+var _ flag.Value = &stringList{}
+
+// String implements flag.Value.
+func (sl *stringList) String() string {
+	return strings.Join(*sl, " ")
+}
+
+// Set implements flag.Value.
+func (sl *stringList) Set(value string) error {
+	*sl = append(*sl, value)
+	return nil
+}
+
+var (
+	// apiEndpoint is the endpoint where we serve ooniprobe requests
+	apiEndpoint = flag.String("api-endpoint", "127.0.0.1:8080", "API endpoint")
+
+	// debug controls whether to enable verbose logging
+	debug = flag.Bool("debug", false, "Toggle debug mode")
+
+	// faults contains the faults to inject
+	faults stringList
+
+	// measurementsFile is the file where to append the submitted measurements
+	measurementsFile = flag.String("measurements", "", "Appends submitted measurements to the given JSONL file")
+
+	// sigs is the channel where we collect signals
+	sigs = make(chan os.Signal, 1)
+
+	// srvAddr is used to pass the server address to tests
+	srvAddr = make(chan string, 1)
+
+	// srvWg is used by tests to know when the server has shut down
+	srvWg = new(sync.WaitGroup)
+
+	// testHelpers contains the web connectivity test helpers to return
+	testHelpers stringList
+
+	// urls contains the URLs to return with the check-in response
+	urls stringList
+)
+
+func init() {
+	flag.Var(&faults, "fault", "Injects a fault (e.g., /report,status=503,count=2), may be repeated")
+	flag.Var(&testHelpers, "th", "Web Connectivity test helper URL to return, may be repeated")
+	flag.Var(&urls, "url", "URL to return with the check-in response, may be repeated")
+}
+
+// errInvalidFault indicates that a fault specification is invalid.
+var errInvalidFault = errors.New("invalid fault specification")
+
+// parseFault parses a fault specification. The specification is a comma-separated
+// list where the first element is the URL path prefix and the other elements are
+// status=CODE, delay=DURATION, count=N, and malformed.
+func parseFault(spec string) (*oobackend.Fault, error) {
+	values := strings.Split(spec, ",")
+	fault := &oobackend.Fault{PathPrefix: values[0]}
+	for _, value := range values[1:] {
+		key, arg, _ := strings.Cut(value, "=")
+		var err error
+		switch key {
+		case "status":
+			fault.StatusCode, err = strconv.Atoi(arg)
+		case "delay":
+			fault.Delay, err = time.ParseDuration(arg)
+		case "count":
+			fault.Count, err = strconv.Atoi(arg)
+		case "malformed":
+			fault.MalformedJSON = true
+		default:
+			err = fmt.Errorf("unknown key: %s", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %s", errInvalidFault, spec, err.Error())
+		}
+	}
+	return fault, nil
+}
+
+// newBackend creates a new backend using the command line flags.
+func newBackend() (*oobackend.Backend, error) {
+	backend := &oobackend.Backend{}
+	for _, spec := range faults {
+		fault, err := parseFault(spec)
+		if err != nil {
+			return nil, err
+		}
+		backend.AddFault(fault)
+	}
+	if len(urls) > 0 {
+		var infos []model.OOAPIURLInfo
+		for _, URL := range urls {
+			infos = append(infos, model.OOAPIURLInfo{
+				CategoryCode: "MISC",
+				CountryCode:  "XX",
+				URL:          URL,
+			})
+		}
+		backend.SetCheckInURLs(infos)
+	}
+	if len(testHelpers) > 0 {
+		var services []model.OOAPIService
+		for _, URL := range testHelpers {
+			services = append(services, model.OOAPIService{Address: URL, Type: "https"})
+		}
+		backend.SetTestHelpers(map[string][]model.OOAPIService{"web-connectivity": services})
+	}
+	if *measurementsFile != "" {
+		filep, err := os.OpenFile(*measurementsFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return nil, err
+		}
+		backend.OnMeasurement = func(meas *model.Measurement) {
+			data := runtimex.Try1(json.Marshal(meas))
+			data = append(data, '\n')
+			if _, err := filep.Write(data); err != nil {
+				log.Warnf("cannot write measurement: %s", err.Error())
+			}
+		}
+	}
+	return backend, nil
+}
+
+func main() {
+	// parse command line options
+	flag.Parse()
+
+	// set log level
+	logmap := map[bool]log.Level{
+		true:  log.DebugLevel,
+		false: log.InfoLevel,
+	}
+	log.SetLevel(logmap[*debug])
+
+	// create the backend
+	backend, err := newBackend()
+	runtimex.PanicOnError(err, "newBackend failed")
+
+	// start listening
+	srvWg.Add(1)
+	defer srvWg.Done()
+	listener, err := net.Listen("tcp", *apiEndpoint)
+	runtimex.PanicOnError(err, "net.Listen failed")
+	srvAddr <- listener.Addr().String()
+	srv := &http.Server{
+		Handler:           backend.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go srv.Serve(listener)
+	log.Infof("serving the OONI probe services API at http://%s", listener.Addr().String())
+
+	// wait for a signal and then shut down
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	<-sigs
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_ = srv.Shutdown(ctx)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/oobackend"
+)
+
+func TestParseFault(t *testing.T) {
+	type testCase struct {
+		spec      string
+		expect    *oobackend.Fault
+		expectErr error
+	}
+
+	testCases := []testCase{{
+		spec:   "/report",
+		expect: &oobackend.Fault{PathPrefix: "/report"},
+	}, {
+		spec: "/api/v1/check-in,status=503,count=2",
+		expect: &oobackend.Fault{
+			PathPrefix: "/api/v1/check-in",
+			StatusCode: 503,
+			Count:      2,
+		},
+	}, {
+		spec: ",delay=5s,malformed",
+		expect: &oobackend.Fault{
+			Delay:         5 * time.Second,
+			MalformedJSON: true,
+		},
+	}, {
+		spec:      "/report,status=xx",
+		expectErr: errInvalidFault,
+	}, {
+		spec:      "/report,delay=forever",
+		expectErr: errInvalidFault,
+	}, {
+		spec:      "/report,antani",
+		expectErr: errInvalidFault,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.spec, func(t *testing.T) {
+			fault, err := parseFault(tc.spec)
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatal("unexpected error", err)
+			}
+			if diff := cmp.Diff(tc.expect, fault); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestMainRunServerWorkingAsIntended(t *testing.T) {
+	// let the kernel pick a random free port and configure the backend
+	*apiEndpoint = "127.0.0.1:0"
+	*measurementsFile = filepath.Join(t.TempDir(), "measurements.jsonl")
+	faults = stringList{"/api/v1/check-in,status=503,count=1"}
+	testHelpers = stringList{"http://127.0.0.1:9090"}
+	urls = stringList{"https://www.example.com/"}
+
+	// run the main function in a background goroutine
+	go main()
+
+	// construct the backend URL
+	endpoint := <-srvAddr
+	URL := &url.URL{
+		Scheme: "http",
+		Host:   endpoint,
+		Path:   "/api/v1/test-helpers",
+	}
+
+	// make sure we return the configured test helpers
+	resp, err := http.Get(URL.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var ths map[string][]model.OOAPIService
+	if err := json.NewDecoder(resp.Body).Decode(&ths); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string][]model.OOAPIService{
+		"web-connectivity": {{Address: "http://127.0.0.1:9090", Type: "https"}},
+	}
+	if diff := cmp.Diff(expected, ths); diff != "" {
+		t.Fatal(diff)
+	}
+
+	// make sure we inject the configured fault
+	URL.Path = "/api/v1/check-in"
+	resp, err = http.Post(URL.String(), "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 503 {
+		t.Fatal("unexpected status code", resp.StatusCode)
+	}
+
+	// make sure we created the measurements file
+	if _, err := os.Stat(*measurementsFile); err != nil {
+		t.Fatal(err)
+	}
+
+	// shut down the server and wait for it to terminate
+	sigs <- syscall.SIGINT
+	srvWg.Wait()
+}
@@ -1,10 +1,12 @@
-// Command oonireport uploads reports stored on disk to the OONI collector.
+// Command oonireport uploads reports stored on disk to the OONI collector
+// and verifies the signature of measurements stored on disk.
 package main
 
 import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -14,6 +16,7 @@ import (
 
 	"github.com/ooni/probe-cli/v3/internal/engine"
 	"github.com/ooni/probe-cli/v3/internal/fsx"
+	"github.com/ooni/probe-cli/v3/internal/measurementsig"
 	"github.com/ooni/probe-cli/v3/internal/model"
 	"github.com/ooni/probe-cli/v3/internal/runtimex"
 	"github.com/ooni/probe-cli/v3/internal/version"
@@ -99,9 +102,19 @@ func toMeasurement(s string) *model.Measurement {
 	return &mm
 }
 
+// batchSubmitter is a [model.Submitter] that can also submit several
+// measurements at once using batched and compressed uploads.
+type batchSubmitter interface {
+	model.Submitter
+	SubmitMany(ctx context.Context, ms []*model.Measurement) error
+}
+
 // submitAll submits the measurements in input. Returns the count of submitted measurements, both
 // on success and on error, and the error that occurred (nil on success).
 func submitAll(ctx context.Context, lines []string, subm model.Submitter) (int, error) {
+	if bsubm, ok := subm.(batchSubmitter); ok {
+		return submitAllBatched(ctx, lines, bsubm)
+	}
 	submitted := 0
 	for _, line := range lines {
 		mm := toMeasurement(line)
@@ -115,23 +128,84 @@ func submitAll(ctx context.Context, lines []string, subm model.Submitter) (int,
 	return submitted, nil
 }
 
+// submitAllBatched is like submitAll but uses batched uploads, which is much faster
+// than submitting each measurement separately over slow network links.
+func submitAllBatched(ctx context.Context, lines []string, subm batchSubmitter) (int, error) {
+	var measurements []*model.Measurement
+	for _, line := range lines {
+		measurements = append(measurements, toMeasurement(line))
+	}
+	err := subm.SubmitMany(ctx, measurements)
+	submitted := 0
+	for _, mm := range measurements {
+		if mm.ReportID != "" {
+			submitted += 1
+		}
+	}
+	return submitted, err
+}
+
+// errVerificationFailed indicates that we could not verify some measurements.
+var errVerificationFailed = errors.New("some measurements failed verification")
+
+// verifyAll verifies the signature of the measurements in input using the given
+// base64 encoded public key. Returns the count of verified measurements and
+// an error if any measurement failed verification.
+func verifyAll(lines []string, encodedPublicKey string) (int, error) {
+	publicKey, err := measurementsig.ParsePublicKey(encodedPublicKey)
+	if err != nil {
+		return 0, err
+	}
+	verified := 0
+	for idx, line := range lines {
+		if err := measurementsig.Verify([]byte(line), publicKey); err != nil {
+			fmt.Printf("measurement #%d: %s\n", idx, err.Error())
+			continue
+		}
+		verified += 1
+	}
+	if verified != len(lines) {
+		return verified, errVerificationFailed
+	}
+	return verified, nil
+}
+
+const usage = "Usage: ./oonireport upload <file> | ./oonireport verify <file> <public-key>"
+
 func mainWithArgs(args []string) {
-	fatalIfFalse(len(args) == 2, "Usage: ./oonireport upload <file>")
-	fatalIfFalse(args[0] == "upload", "Unsupported operation")
+	fatalIfFalse(len(args) >= 2, usage)
 	fatalIfFalse(fsx.RegularFileExists(args[1]), "Cannot open measurement file")
 
-	path = args[1]
-	lines := readLines(path)
+	switch args[0] {
+	case "upload":
+		fatalIfFalse(len(args) == 2, usage)
 
-	ctx := context.Background()
-	sess := newSession(ctx)
-	defer sess.Close()
+		path = args[1]
+		lines := readLines(path)
 
-	submitter := newSubmitter(sess, ctx)
+		ctx := context.Background()
+		sess := newSession(ctx)
+		defer sess.Close()
 
-	n, err := submitAll(ctx, lines, submitter)
-	fmt.Println("Submitted measurements: ", n)
-	runtimex.PanicOnError(err, "error occurred while submitting")
+		submitter := newSubmitter(sess, ctx)
+
+		n, err := submitAll(ctx, lines, submitter)
+		fmt.Println("Submitted measurements: ", n)
+		runtimex.PanicOnError(err, "error occurred while submitting")
+
+	case "verify":
+		fatalIfFalse(len(args) == 3, usage)
+
+		path = args[1]
+		lines := readLines(path)
+
+		n, err := verifyAll(lines, args[2])
+		fmt.Println("Verified measurements: ", n)
+		runtimex.PanicOnError(err, "error occurred while verifying")
+
+	default:
+		panic("Unsupported operation")
+	}
 }
 
 func main() {
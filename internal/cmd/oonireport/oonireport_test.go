@@ -2,7 +2,16 @@ package main
 
 import (
 	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/ooni/probe-cli/v3/internal/kvstore"
+	"github.com/ooni/probe-cli/v3/internal/measurementsig"
+	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/must"
 )
 
 func TestReadLines(t *testing.T) {
@@ -85,3 +94,126 @@ func TestSubmitAllFails(t *testing.T) {
 		t.Fatal("nothing should be submitted here")
 	}
 }
+
+// fakeBatchSubmitter is a batchSubmitter for testing.
+type fakeBatchSubmitter struct {
+	failAfter int
+}
+
+func (fbs *fakeBatchSubmitter) Submit(ctx context.Context, m *model.Measurement) error {
+	panic("should not be called")
+}
+
+func (fbs *fakeBatchSubmitter) SubmitMany(ctx context.Context, ms []*model.Measurement) error {
+	for idx, mm := range ms {
+		if idx >= fbs.failAfter {
+			mm.ReportID = ""
+			continue
+		}
+		mm.ReportID = "xxx-xxx-xxx-xxx"
+	}
+	if fbs.failAfter < len(ms) {
+		return errors.New("mocked error")
+	}
+	return nil
+}
+
+func TestSubmitAllBatched(t *testing.T) {
+	lines := readLines("testdata/testmeasurement.json")
+
+	t.Run("on success", func(t *testing.T) {
+		n, err := submitAll(context.Background(), lines, &fakeBatchSubmitter{failAfter: len(lines)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != len(lines) {
+			t.Fatal("unexpected number of submitted measurements", n)
+		}
+	})
+
+	t.Run("on failure", func(t *testing.T) {
+		n, err := submitAll(context.Background(), lines, &fakeBatchSubmitter{failAfter: 1})
+		if err == nil {
+			t.Fatal("expected an error here")
+		}
+		if n != 1 {
+			t.Fatal("unexpected number of submitted measurements", n)
+		}
+	})
+}
+
+// signedLinesForTesting returns the test measurements signed using a new key and the public key.
+func signedLinesForTesting(t *testing.T) ([]string, string) {
+	signer, err := measurementsig.NewSigner(&kvstore.Memory{}, model.DiscardLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines []string
+	for _, line := range readLines("testdata/testmeasurement.json") {
+		mm := toMeasurement(line)
+		if err := signer.SignMeasurement(mm); err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, string(must.MarshalJSON(mm)))
+	}
+	return lines, signer.EncodedPublicKey()
+}
+
+func TestVerifyAll(t *testing.T) {
+	t.Run("with signed measurements", func(t *testing.T) {
+		lines, publicKey := signedLinesForTesting(t)
+		n, err := verifyAll(lines, publicKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != len(lines) {
+			t.Fatal("unexpected number of verified measurements", n)
+		}
+	})
+
+	t.Run("with an altered measurement", func(t *testing.T) {
+		lines, publicKey := signedLinesForTesting(t)
+		lines[0] = strings.Replace(lines[0], `"probe_asn":"AS6805"`, `"probe_asn":"AS137"`, 1)
+		n, err := verifyAll(lines, publicKey)
+		if !errors.Is(err, errVerificationFailed) {
+			t.Fatal("unexpected error", err)
+		}
+		if n != len(lines)-1 {
+			t.Fatal("unexpected number of verified measurements", n)
+		}
+	})
+
+	t.Run("with an invalid public key", func(t *testing.T) {
+		lines, _ := signedLinesForTesting(t)
+		n, err := verifyAll(lines, "AAAA")
+		if !errors.Is(err, measurementsig.ErrInvalidPublicKey) {
+			t.Fatal("unexpected error", err)
+		}
+		if n != 0 {
+			t.Fatal("unexpected number of verified measurements", n)
+		}
+	})
+}
+
+func TestMainVerify(t *testing.T) {
+	lines, publicKey := signedLinesForTesting(t)
+	filename := filepath.Join(t.TempDir(), "signed.jsonl")
+	if err := os.WriteFile(filename, []byte(strings.Join(lines, "\n")+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if s := recover(); s != nil {
+			t.Fatal("unexpected panic", s)
+		}
+	}()
+	mainWithArgs([]string{"verify", filename, publicKey})
+}
+
+func TestMainUnsupportedOperation(t *testing.T) {
+	defer func() {
+		if s := recover(); s != "Unsupported operation" {
+			t.Fatal("unexpected panic message", s)
+		}
+	}()
+	mainWithArgs([]string{"download", "testdata/testmeasurement.json"})
+}
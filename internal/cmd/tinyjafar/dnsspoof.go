@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/miekg/dns"
+)
+
+// dnsSpoofPort is the port where the DNS spoofer listens.
+const dnsSpoofPort = "5353"
+
+// dnsSpoofUpstream is the upstream resolver used for the names we do not spoof. We use
+// DNS-over-TCP so that our queries are not redirected back to us by the DNAT rules.
+const dnsSpoofUpstream = "8.8.8.8:53"
+
+// parseDNSSpoofRules parses rules having the DOMAIN=IP format.
+func parseDNSSpoofRules(rules []string) (map[string]net.IP, error) {
+	out := map[string]net.IP{}
+	for _, rule := range rules {
+		domain, address, found := strings.Cut(rule, "=")
+		ip := net.ParseIP(address)
+		if !found || domain == "" || ip == nil {
+			return nil, fmt.Errorf("tinyjafar: invalid -dns-spoof rule: %s", rule)
+		}
+		out[dns.CanonicalName(domain)] = ip
+	}
+	return out, nil
+}
+
+// dnsSpoofer is a DNS server that returns the configured addresses for
+// some domains and forwards all the other queries to an upstream resolver.
+type dnsSpoofer struct {
+	// answers maps canonical domain names to the IP address to return.
+	answers map[string]net.IP
+
+	// upstream is the DNS-over-TCP upstream resolver endpoint.
+	upstream string
+}
+
+var _ dns.Handler = &dnsSpoofer{}
+
+// ServeDNS implements dns.Handler.
+func (s *dnsSpoofer) ServeDNS(w dns.ResponseWriter, query *dns.Msg) {
+	if resp := s.maybeSpoof(query); resp != nil {
+		_ = w.WriteMsg(resp)
+		return
+	}
+	clnt := &dns.Client{Net: "tcp", Timeout: 4 * time.Second}
+	resp, _, err := clnt.Exchange(query, s.upstream)
+	if err != nil {
+		log.Warnf("tinyjafar: cannot forward DNS query: %s", err.Error())
+		resp = &dns.Msg{}
+		resp.SetRcode(query, dns.RcodeServerFailure)
+	}
+	_ = w.WriteMsg(resp)
+}
+
+// maybeSpoof returns the spoofed response or nil if we should not spoof.
+func (s *dnsSpoofer) maybeSpoof(query *dns.Msg) *dns.Msg {
+	if len(query.Question) != 1 {
+		return nil
+	}
+	q0 := query.Question[0]
+	ip, found := s.answers[dns.CanonicalName(q0.Name)]
+	if !found {
+		return nil
+	}
+	resp := &dns.Msg{}
+	resp.SetReply(query)
+	header := dns.RR_Header{
+		Name:  q0.Name,
+		Class: dns.ClassINET,
+		Ttl:   60,
+	}
+	switch {
+	case q0.Qtype == dns.TypeA && ip.To4() != nil:
+		header.Rrtype = dns.TypeA
+		resp.Answer = append(resp.Answer, &dns.A{Hdr: header, A: ip.To4()})
+	case q0.Qtype == dns.TypeAAAA && ip.To4() == nil:
+		header.Rrtype = dns.TypeAAAA
+		resp.Answer = append(resp.Answer, &dns.AAAA{Hdr: header, AAAA: ip})
+	}
+	return resp
+}
+
+// startDNSSpoofer starts the DNS spoofer listening on the given UDP address.
+func startDNSSpoofer(address string, answers map[string]net.IP) (*dns.Server, error) {
+	pconn, err := net.ListenPacket("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	server := &dns.Server{
+		PacketConn: pconn,
+		Handler:    &dnsSpoofer{answers: answers, upstream: dnsSpoofUpstream},
+	}
+	go server.ActivateAndServe()
+	return server, nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"net"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/miekg/dns"
+	"github.com/ooni/probe-cli/v3/internal/runtimex"
+)
+
+func TestParseDNSSpoofRules(t *testing.T) {
+	t.Run("with valid rules", func(t *testing.T) {
+		answers, err := parseDNSSpoofRules([]string{"OONI.org=10.0.0.1", "example.com.=2001:db8::1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect := map[string]net.IP{
+			"ooni.org.":    net.ParseIP("10.0.0.1"),
+			"example.com.": net.ParseIP("2001:db8::1"),
+		}
+		if diff := cmp.Diff(expect, answers); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+
+	for _, rule := range []string{"ooni.org", "=10.0.0.1", "ooni.org=antani"} {
+		t.Run("with invalid rule "+rule, func(t *testing.T) {
+			answers, err := parseDNSSpoofRules([]string{rule})
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if answers != nil {
+				t.Fatal("expected nil answers")
+			}
+		})
+	}
+}
+
+func TestDNSSpoofer(t *testing.T) {
+	// create an upstream DNS-over-TCP server returning NXDOMAIN
+	listener := runtimex.Try1(net.Listen("tcp", "127.0.0.1:0"))
+	upstream := &dns.Server{
+		Listener: listener,
+		Handler: dns.HandlerFunc(func(w dns.ResponseWriter, query *dns.Msg) {
+			resp := &dns.Msg{}
+			resp.SetRcode(query, dns.RcodeNameError)
+			_ = w.WriteMsg(resp)
+		}),
+	}
+	go upstream.ActivateAndServe()
+	defer upstream.Shutdown()
+
+	// create the DNS spoofer using such an upstream
+	pconn := runtimex.Try1(net.ListenPacket("udp", "127.0.0.1:0"))
+	server := &dns.Server{
+		PacketConn: pconn,
+		Handler: &dnsSpoofer{
+			answers: map[string]net.IP{
+				"ooni.org.": net.ParseIP("10.0.0.1"),
+			},
+			upstream: listener.Addr().String(),
+		},
+	}
+	go server.ActivateAndServe()
+	defer server.Shutdown()
+
+	// exchange sends a query for the given name and type
+	exchange := func(name string, qtype uint16) *dns.Msg {
+		query := &dns.Msg{}
+		query.SetQuestion(name, qtype)
+		clnt := &dns.Client{Net: "udp"}
+		resp, _, err := clnt.Exchange(query, pconn.LocalAddr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	t.Run("for a spoofed domain and A query", func(t *testing.T) {
+		resp := exchange("OONI.org.", dns.TypeA)
+		if resp.Rcode != dns.RcodeSuccess || len(resp.Answer) != 1 {
+			t.Fatal("unexpected response", resp)
+		}
+		if a := resp.Answer[0].(*dns.A); !a.A.Equal(net.ParseIP("10.0.0.1")) {
+			t.Fatal("unexpected address", a.A)
+		}
+	})
+
+	t.Run("for a spoofed domain and AAAA query", func(t *testing.T) {
+		resp := exchange("ooni.org.", dns.TypeAAAA)
+		if resp.Rcode != dns.RcodeSuccess || len(resp.Answer) != 0 {
+			t.Fatal("unexpected response", resp)
+		}
+	})
+
+	t.Run("for another domain", func(t *testing.T) {
+		resp := exchange("example.com.", dns.TypeA)
+		if resp.Rcode != dns.RcodeNameError {
+			t.Fatal("unexpected response", resp)
+		}
+	})
+}
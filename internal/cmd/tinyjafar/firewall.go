@@ -0,0 +1,265 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+)
+
+// firewall generates the commands implementing the interference primitives
+// using a specific firewall backend (e.g., iptables or nftables).
+type firewall interface {
+	// setup returns the commands to create the tables and chains.
+	setup() []string
+
+	// cleanup returns the commands to remove the tables and chains.
+	cleanup() []string
+
+	// dropIP returns the command to drop traffic to the given IP address.
+	dropIP(ipAddr string) (string, error)
+
+	// dropKeywordHex returns the command to drop traffic containing the given hex keyword.
+	dropKeywordHex(keyword string) (string, error)
+
+	// dropKeyword returns the command to drop traffic containing the given keyword.
+	dropKeyword(keyword string) (string, error)
+
+	// resetIP returns the command to reset TCP traffic to the given IP address.
+	resetIP(ipAddr string) (string, error)
+
+	// resetKeywordHex returns the command to reset TCP traffic containing the given hex keyword.
+	resetKeywordHex(keyword string) (string, error)
+
+	// resetKeyword returns the command to reset TCP traffic containing the given keyword.
+	resetKeyword(keyword string) (string, error)
+
+	// resetSNI returns the command to reset TLS traffic using the given SNI.
+	resetSNI(sni string) (string, error)
+
+	// hijackDNS returns the command to redirect DNS queries to the given endpoint.
+	hijackDNS(endpoint string) (string, error)
+
+	// masquerade returns the commands to setup and cleanup NAT for the given subnet.
+	masquerade(subnet string) (setup []string, cleanup []string)
+}
+
+// newFirewall creates a new [firewall] for the given backend name.
+func newFirewall(backend string) (firewall, error) {
+	switch backend {
+	case "iptables":
+		return &iptablesFirewall{}, nil
+	case "nftables":
+		return &nftablesFirewall{}, nil
+	default:
+		return nil, fmt.Errorf("tinyjafar: unsupported backend: %s", backend)
+	}
+}
+
+// iptablesFirewall implements [firewall] using iptables.
+type iptablesFirewall struct{}
+
+var _ firewall = &iptablesFirewall{}
+
+// setup implements firewall.
+func (*iptablesFirewall) setup() []string {
+	return []string{
+		"iptables -N JAFAR_INPUT",
+		"iptables -N JAFAR_OUTPUT",
+		"iptables -t nat -N JAFAR_NAT_OUTPUT",
+		"iptables -I OUTPUT -j JAFAR_OUTPUT",
+		"iptables -I INPUT -j JAFAR_INPUT",
+		"iptables -t nat -I OUTPUT -j JAFAR_NAT_OUTPUT",
+	}
+}
+
+// cleanup implements firewall.
+func (*iptablesFirewall) cleanup() []string {
+	return []string{
+		"iptables -D OUTPUT -j JAFAR_OUTPUT",
+		"iptables -D INPUT -j JAFAR_INPUT",
+		"iptables -t nat -D OUTPUT -j JAFAR_NAT_OUTPUT",
+		"iptables -F JAFAR_INPUT",
+		"iptables -X JAFAR_INPUT",
+		"iptables -F JAFAR_OUTPUT",
+		"iptables -X JAFAR_OUTPUT",
+		"iptables -t nat -F JAFAR_NAT_OUTPUT",
+		"iptables -t nat -X JAFAR_NAT_OUTPUT",
+	}
+}
+
+// dropIP implements firewall.
+func (*iptablesFirewall) dropIP(ipAddr string) (string, error) {
+	return fmt.Sprintf("iptables -A JAFAR_OUTPUT -d '%s' -j DROP", ipAddr), nil
+}
+
+// dropKeywordHex implements firewall.
+func (*iptablesFirewall) dropKeywordHex(keyword string) (string, error) {
+	return fmt.Sprintf(
+		"iptables -A JAFAR_OUTPUT -m string --algo kmp --hex-string '%s' -j DROP",
+		keyword,
+	), nil
+}
+
+// dropKeyword implements firewall.
+func (*iptablesFirewall) dropKeyword(keyword string) (string, error) {
+	return fmt.Sprintf(
+		"iptables -A JAFAR_OUTPUT -m string --algo kmp --string '%s' -j DROP",
+		keyword,
+	), nil
+}
+
+// resetIP implements firewall.
+func (*iptablesFirewall) resetIP(ipAddr string) (string, error) {
+	return fmt.Sprintf(
+		"iptables -A JAFAR_OUTPUT --proto tcp -d '%s' -j REJECT --reject-with tcp-reset",
+		ipAddr,
+	), nil
+}
+
+// resetKeywordHex implements firewall.
+func (*iptablesFirewall) resetKeywordHex(keyword string) (string, error) {
+	return fmt.Sprintf(
+		"iptables -A JAFAR_OUTPUT -m string --proto tcp --algo kmp --hex-string '%s' -j REJECT --reject-with tcp-reset",
+		keyword,
+	), nil
+}
+
+// resetKeyword implements firewall.
+func (*iptablesFirewall) resetKeyword(keyword string) (string, error) {
+	return fmt.Sprintf(
+		"iptables -A JAFAR_OUTPUT -m string --proto tcp --algo kmp --string '%s' -j REJECT --reject-with tcp-reset",
+		keyword,
+	), nil
+}
+
+// resetSNI implements firewall.
+func (*iptablesFirewall) resetSNI(sni string) (string, error) {
+	return fmt.Sprintf(
+		"iptables -A JAFAR_OUTPUT --proto tcp --dport 443 -m string --algo kmp --string '%s' -j REJECT --reject-with tcp-reset",
+		sni,
+	), nil
+}
+
+// hijackDNS implements firewall.
+func (*iptablesFirewall) hijackDNS(endpoint string) (string, error) {
+	return fmt.Sprintf(
+		"iptables -t nat -A JAFAR_NAT_OUTPUT --proto udp --dport 53 -j DNAT --to-destination '%s'",
+		endpoint,
+	), nil
+}
+
+// masquerade implements firewall.
+func (*iptablesFirewall) masquerade(subnet string) ([]string, []string) {
+	setup := []string{
+		fmt.Sprintf("iptables -t nat -I POSTROUTING -s '%s' -j MASQUERADE", subnet),
+		fmt.Sprintf("iptables -I FORWARD -s '%s' -j ACCEPT", subnet),
+		fmt.Sprintf("iptables -I FORWARD -d '%s' -j ACCEPT", subnet),
+	}
+	cleanup := []string{
+		fmt.Sprintf("iptables -t nat -D POSTROUTING -s '%s' -j MASQUERADE", subnet),
+		fmt.Sprintf("iptables -D FORWARD -s '%s' -j ACCEPT", subnet),
+		fmt.Sprintf("iptables -D FORWARD -d '%s' -j ACCEPT", subnet),
+	}
+	return setup, cleanup
+}
+
+// errNftablesNoStringMatch indicates that nftables cannot match strings inside packets.
+var errNftablesNoStringMatch = errors.New("tinyjafar: the nftables backend does not support keyword matching")
+
+// nftablesFirewall implements [firewall] using nftables. We create our own
+// tables, so the cleanup consists of deleting such tables.
+type nftablesFirewall struct{}
+
+var _ firewall = &nftablesFirewall{}
+
+// setup implements firewall.
+func (*nftablesFirewall) setup() []string {
+	return []string{
+		"nft add table inet tinyjafar",
+		"nft add chain inet tinyjafar output '{ type filter hook output priority 0 ; }'",
+		"nft add table ip tinyjafar_nat",
+		"nft add chain ip tinyjafar_nat output '{ type nat hook output priority -100 ; }'",
+	}
+}
+
+// cleanup implements firewall.
+func (*nftablesFirewall) cleanup() []string {
+	return []string{
+		"nft delete table inet tinyjafar",
+		"nft delete table ip tinyjafar_nat",
+	}
+}
+
+// daddr returns the nftables destination address match for the given IP address.
+func (*nftablesFirewall) daddr(ipAddr string) (string, error) {
+	ip := net.ParseIP(ipAddr)
+	switch {
+	case ip == nil:
+		return "", fmt.Errorf("tinyjafar: invalid IP address: %s", ipAddr)
+	case ip.To4() != nil:
+		return fmt.Sprintf("ip daddr %s", ipAddr), nil
+	default:
+		return fmt.Sprintf("ip6 daddr %s", ipAddr), nil
+	}
+}
+
+// dropIP implements firewall.
+func (fw *nftablesFirewall) dropIP(ipAddr string) (string, error) {
+	daddr, err := fw.daddr(ipAddr)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("nft add rule inet tinyjafar output %s drop", daddr), nil
+}
+
+// dropKeywordHex implements firewall.
+func (*nftablesFirewall) dropKeywordHex(keyword string) (string, error) {
+	return "", errNftablesNoStringMatch
+}
+
+// dropKeyword implements firewall.
+func (*nftablesFirewall) dropKeyword(keyword string) (string, error) {
+	return "", errNftablesNoStringMatch
+}
+
+// resetIP implements firewall.
+func (fw *nftablesFirewall) resetIP(ipAddr string) (string, error) {
+	daddr, err := fw.daddr(ipAddr)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("nft add rule inet tinyjafar output meta l4proto tcp %s reject with tcp reset", daddr), nil
+}
+
+// resetKeywordHex implements firewall.
+func (*nftablesFirewall) resetKeywordHex(keyword string) (string, error) {
+	return "", errNftablesNoStringMatch
+}
+
+// resetKeyword implements firewall.
+func (*nftablesFirewall) resetKeyword(keyword string) (string, error) {
+	return "", errNftablesNoStringMatch
+}
+
+// resetSNI implements firewall.
+func (*nftablesFirewall) resetSNI(sni string) (string, error) {
+	return "", errNftablesNoStringMatch
+}
+
+// hijackDNS implements firewall.
+func (*nftablesFirewall) hijackDNS(endpoint string) (string, error) {
+	return fmt.Sprintf("nft add rule ip tinyjafar_nat output udp dport 53 dnat to %s", endpoint), nil
+}
+
+// masquerade implements firewall.
+func (*nftablesFirewall) masquerade(subnet string) ([]string, []string) {
+	setup := []string{
+		"nft add table ip tinyjafar_host",
+		"nft add chain ip tinyjafar_host postrouting '{ type nat hook postrouting priority 100 ; }'",
+		fmt.Sprintf("nft add rule ip tinyjafar_host postrouting ip saddr %s masquerade", subnet),
+	}
+	cleanup := []string{
+		"nft delete table ip tinyjafar_host",
+	}
+	return setup, cleanup
+}
@@ -1,13 +1,19 @@
 // Command tinyjafar implements a subset of the CLI flags of the original jafar tool. Because several
 // tutorials mention some jafar commands, we want to have a tiny tool to support exploration.
+//
+// In addition, tinyjafar supports nftables and running a command inside a throwaway network
+// namespace where we apply the rules, so developers can reproduce censorship locally.
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"net"
 	"os"
 	"os/signal"
+	"strings"
 	"sync/atomic"
 	"syscall"
 
@@ -21,25 +27,69 @@ import (
 
 // config contains tinyjafar's configuration.
 type config struct {
+	backend         string
+	dnsSpoof        flagx.StringArray
 	dropIP          flagx.StringArray
 	dropKeywordHex  flagx.StringArray
 	dropKeyword     flagx.StringArray
 	dryRun          bool
+	netns           bool
 	resetIP         flagx.StringArray
 	resetKeywordHex flagx.StringArray
 	resetKeyword    flagx.StringArray
+	resetSNI        flagx.StringArray
+	tcDev           string
+	tcRate          string
 }
 
 func (cfg *config) initFlags(fset *flag.FlagSet) {
-	fset.Var(&cfg.dropIP, "iptables-drop-ip", "Drop traffic to the specified IP address")
-	fset.Var(&cfg.dropKeywordHex, "iptables-drop-keyword-hex", "Drop traffic containing the specified keyword in hex")
-	fset.Var(&cfg.dropKeyword, "iptables-drop-keyword", "Drop traffic containing the specified keyword")
+	fset.StringVar(&cfg.backend, "backend", "iptables", "Firewall backend to use: iptables or nftables")
+	fset.Var(&cfg.dnsSpoof, "dns-spoof", "Spoof DNS responses for DOMAIN using IP (format: DOMAIN=IP)")
+	ruleVar(fset, &cfg.dropIP, "drop-ip", "Drop traffic to the specified IP address")
+	ruleVar(fset, &cfg.dropKeywordHex, "drop-keyword-hex", "Drop traffic containing the specified keyword in hex")
+	ruleVar(fset, &cfg.dropKeyword, "drop-keyword", "Drop traffic containing the specified keyword")
 	fset.BoolVar(&cfg.dryRun, "dry-run", false, "print which commands we would execute")
-	fset.Var(&cfg.resetIP, "iptables-reset-ip", "Reset TCP/IP traffic to the specified IP address")
-	fset.Var(&cfg.resetKeywordHex, "iptables-reset-keyword-hex", "Reset TCP/IP traffic containing the specified keyword in hex")
-	fset.Var(&cfg.resetKeyword, "iptables-reset-keyword", "Reset TCP/IP traffic containing the specified keyword")
+	fset.BoolVar(&cfg.netns, "netns", false, "Run the command given as argument inside a throwaway network namespace")
+	ruleVar(fset, &cfg.resetIP, "reset-ip", "Reset TCP/IP traffic to the specified IP address")
+	ruleVar(fset, &cfg.resetKeywordHex, "reset-keyword-hex", "Reset TCP/IP traffic containing the specified keyword in hex")
+	ruleVar(fset, &cfg.resetKeyword, "reset-keyword", "Reset TCP/IP traffic containing the specified keyword")
+	fset.Var(&cfg.resetSNI, "reset-sni", "Reset TLS connections using the specified SNI")
+	fset.StringVar(&cfg.tcDev, "tc-dev", "", "Device to shape with -tc-rate (default: the namespace device with -netns)")
+	fset.StringVar(&cfg.tcRate, "tc-rate", "", "Shape the outgoing bandwidth using tc (e.g., 256kbit)")
 }
 
+// ruleVar registers the flag with the given name along with the same flag prefixed
+// by `iptables-`, which is what the original jafar used. Both flags apply to
+// all the backends, hence we only document the iptables prefixed flag as an alias.
+func ruleVar(fset *flag.FlagSet, value *flagx.StringArray, name, usage string) {
+	fset.Var(value, name, usage)
+	fset.Var(value, "iptables-"+name, fmt.Sprintf("Alias for -%s (for compatibility with jafar)", name))
+}
+
+const (
+	// netnsName is the name of the throwaway network namespace.
+	netnsName = "tinyjafar"
+
+	// netnsHostDev is the host side of the veth pair.
+	netnsHostDev = "tj-host"
+
+	// netnsDev is the namespace side of the veth pair.
+	netnsDev = "tj-ns"
+
+	// netnsHostAddr is the address of the host side of the veth pair.
+	netnsHostAddr = "10.117.0.1"
+
+	// netnsAddr is the address of the namespace side of the veth pair.
+	netnsAddr = "10.117.0.2"
+
+	// netnsSubnet is the subnet of the veth pair.
+	netnsSubnet = "10.117.0.0/30"
+
+	// netnsResolver is the resolver used inside the namespace, since
+	// the host may be using a resolver listening on localhost.
+	netnsResolver = "8.8.8.8"
+)
+
 // cmd is a cmd to execute
 type cmd struct {
 	argv []string
@@ -47,89 +97,216 @@ type cmd struct {
 
 // cmdSet contains the commands to execute. The zero value is invalid
 // and you must construct using the [newCmdSet] factory.
+//
+// With -netns, we apply the interference rules inside a throwaway network
+// namespace, hence we prefix them with `ip netns exec` and we do not need
+// to clean them up because deleting the namespace deletes them.
+//
+// With -dry-run, we do not read the host's IPv4 forwarding setting, rather we
+// print the command to read it and use [ipForwardDryRunValue] in its place.
 type cmdSet struct {
 	setup   []*cmd
 	cleanup []*cmd
+	fw      firewall
+	netns   bool
 }
 
-func newCmdSet() *cmdSet {
-	c := &cmdSet{}
+func newCmdSet(fw firewall, netns, dryRun bool) *cmdSet {
+	c := &cmdSet{fw: fw, netns: netns}
 
-	c.addSetupCmd("iptables -N JAFAR_INPUT")
-	c.addSetupCmd("iptables -N JAFAR_OUTPUT")
-	c.addSetupCmd("iptables -t nat -N JAFAR_NAT_OUTPUT")
-	c.addSetupCmd("iptables -I OUTPUT -j JAFAR_OUTPUT")
-	c.addSetupCmd("iptables -I INPUT -j JAFAR_INPUT")
-	c.addSetupCmd("iptables -t nat -I OUTPUT -j JAFAR_NAT_OUTPUT")
+	if netns {
+		c.addSetupCmd(fmt.Sprintf("ip netns add %s", netnsName))
+		c.addSetupCmd(fmt.Sprintf("ip link add %s type veth peer name %s", netnsHostDev, netnsDev))
+		c.addSetupCmd(fmt.Sprintf("ip link set %s netns %s", netnsDev, netnsName))
+		c.addSetupCmd(fmt.Sprintf("ip addr add %s/30 dev %s", netnsHostAddr, netnsHostDev))
+		c.addSetupCmd(fmt.Sprintf("ip link set %s up", netnsHostDev))
+		c.addScopedSetupCmd(fmt.Sprintf("ip addr add %s/30 dev %s", netnsAddr, netnsDev))
+		c.addScopedSetupCmd(fmt.Sprintf("ip link set %s up", netnsDev))
+		c.addScopedSetupCmd("ip link set lo up")
+		c.addScopedSetupCmd(fmt.Sprintf("ip route add default via %s", netnsHostAddr))
+		c.addSetupCmd(fmt.Sprintf("mkdir -p /etc/netns/%s", netnsName))
+		c.addSetupCmd(fmt.Sprintf(
+			"sh -c 'echo nameserver %s > /etc/netns/%s/resolv.conf'", netnsResolver, netnsName))
+		ipForward := ipForwardDryRunValue
+		if dryRun {
+			c.addSetupCmd("sysctl net.ipv4.ip_forward")
+		} else {
+			ipForward = readIPForward()
+		}
+		if ipForward != "1" {
+			c.addSetupCmd("sysctl -w net.ipv4.ip_forward=1")
+		}
+		masqSetup, masqCleanup := fw.masquerade(netnsSubnet)
+		for _, argv := range masqSetup {
+			c.addSetupCmd(argv)
+		}
 
-	addCleanupCmd := func(argv string) {
-		c.cleanup = append(c.cleanup, &cmd{runtimex.Try1(shlex.Split(argv))})
+		// deleting the namespace also deletes the veth pair
+		c.addCleanupCmd(fmt.Sprintf("ip netns del %s", netnsName))
+		for _, argv := range masqCleanup {
+			c.addCleanupCmd(argv)
+		}
+		c.addCleanupCmd(fmt.Sprintf("rm -rf /etc/netns/%s", netnsName))
+		if ipForward != "1" {
+			c.addCleanupCmd(fmt.Sprintf("sysctl -w net.ipv4.ip_forward=%s", ipForward))
+		}
 	}
 
-	addCleanupCmd("iptables -D OUTPUT -j JAFAR_OUTPUT")
-	addCleanupCmd("iptables -D INPUT -j JAFAR_INPUT")
-	addCleanupCmd("iptables -t nat -D OUTPUT -j JAFAR_NAT_OUTPUT")
-	addCleanupCmd("iptables -F JAFAR_INPUT")
-	addCleanupCmd("iptables -X JAFAR_INPUT")
-	addCleanupCmd("iptables -F JAFAR_OUTPUT")
-	addCleanupCmd("iptables -X JAFAR_OUTPUT")
-	addCleanupCmd("iptables -t nat -F JAFAR_NAT_OUTPUT")
-	addCleanupCmd("iptables -t nat -X JAFAR_NAT_OUTPUT")
+	for _, argv := range fw.setup() {
+		c.addScopedSetupCmd(argv)
+	}
+	for _, argv := range fw.cleanup() {
+		c.addScopedCleanupCmd(argv)
+	}
 
 	return c
 }
 
+// ipForwardFile is the file containing the host's IPv4 forwarding setting.
+const ipForwardFile = "/proc/sys/net/ipv4/ip_forward"
+
+// ipForwardDryRunValue is the placeholder for the host's IPv4 forwarding
+// setting we print with -dry-run, where we do not read the setting.
+const ipForwardDryRunValue = "PREVIOUS_VALUE"
+
+// readIPForward returns the host's IPv4 forwarding setting, such that we
+// can restore it on cleanup. We assume forwarding is disabled if we cannot
+// read the setting, which is the kernel default.
+var readIPForward = func() string {
+	data, err := os.ReadFile(ipForwardFile)
+	if err != nil {
+		return "0"
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// shellRunFunc is the type of the function running commands.
+type shellRunFunc func(logger model.Logger, command string, args ...string) error
+
+// runSetup runs the setup commands. When a command fails, we run the cleanup
+// commands, to avoid leaving a partial setup behind, and return the error.
+func (c *cmdSet) runSetup(runx shellRunFunc) error {
+	for _, cmd := range c.setup {
+		if err := runx(log.Log, cmd.argv[0], cmd.argv[1:]...); err != nil {
+			c.runCleanup(runx)
+			return err
+		}
+	}
+	return nil
+}
+
+// runCleanup runs all the cleanup commands.
+func (c *cmdSet) runCleanup(runx shellRunFunc) {
+	for _, cmd := range c.cleanup {
+		// ignoring the return value here is intentional to avoid interrupting the cleanup midway
+		_ = runx(log.Log, cmd.argv[0], cmd.argv[1:]...)
+	}
+}
+
 func (c *cmdSet) addSetupCmd(argv string) {
 	c.setup = append(c.setup, &cmd{runtimex.Try1(shlex.Split(argv))})
 }
 
-func (c *cmdSet) handleDropIP(cfg *config) {
-	for _, ipAddr := range cfg.dropIP {
-		c.addSetupCmd(fmt.Sprintf("iptables -A JAFAR_OUTPUT -d '%s' -j DROP", ipAddr))
+func (c *cmdSet) addCleanupCmd(argv string) {
+	c.cleanup = append(c.cleanup, &cmd{runtimex.Try1(shlex.Split(argv))})
+}
+
+// scoped returns the argv to run the given command in the right network namespace.
+func (c *cmdSet) scoped(argv string) string {
+	if c.netns {
+		return fmt.Sprintf("ip netns exec %s %s", netnsName, argv)
 	}
+	return argv
 }
 
-func (c *cmdSet) handleDropKeywordHex(cfg *config) {
-	for _, keyword := range cfg.dropKeywordHex {
-		c.addSetupCmd(fmt.Sprintf(
-			"iptables -A JAFAR_OUTPUT -m string --algo kmp --hex-string '%s' -j DROP", keyword))
+func (c *cmdSet) addScopedSetupCmd(argv string) {
+	c.addSetupCmd(c.scoped(argv))
+}
+
+func (c *cmdSet) addScopedCleanupCmd(argv string) {
+	if !c.netns {
+		c.addCleanupCmd(argv)
 	}
 }
 
-func (c *cmdSet) handleDropKeyword(cfg *config) {
-	for _, keyword := range cfg.dropKeyword {
-		c.addSetupCmd(fmt.Sprintf(
-			"iptables -A JAFAR_OUTPUT -m string --algo kmp --string '%s' -j DROP",
-			keyword,
-		))
+// addRules adds a rule for each value using the given rule factory.
+func (c *cmdSet) addRules(values []string, factory func(value string) (string, error)) error {
+	for _, value := range values {
+		argv, err := factory(value)
+		if err != nil {
+			return err
+		}
+		c.addScopedSetupCmd(argv)
 	}
+	return nil
 }
 
-func (c *cmdSet) handleResetIP(cfg *config) {
-	for _, ipAddr := range cfg.resetIP {
-		c.addSetupCmd(fmt.Sprintf(
-			"iptables -A JAFAR_OUTPUT --proto tcp -d '%s' -j REJECT --reject-with tcp-reset",
-			ipAddr,
-		))
+func (c *cmdSet) handleDropIP(cfg *config) error {
+	return c.addRules(cfg.dropIP, c.fw.dropIP)
+}
+
+func (c *cmdSet) handleDropKeywordHex(cfg *config) error {
+	return c.addRules(cfg.dropKeywordHex, c.fw.dropKeywordHex)
+}
+
+func (c *cmdSet) handleDropKeyword(cfg *config) error {
+	return c.addRules(cfg.dropKeyword, c.fw.dropKeyword)
+}
+
+func (c *cmdSet) handleResetIP(cfg *config) error {
+	return c.addRules(cfg.resetIP, c.fw.resetIP)
+}
+
+func (c *cmdSet) handleResetKeywordHex(cfg *config) error {
+	return c.addRules(cfg.resetKeywordHex, c.fw.resetKeywordHex)
+}
+
+func (c *cmdSet) handleResetKeyword(cfg *config) error {
+	return c.addRules(cfg.resetKeyword, c.fw.resetKeyword)
+}
+
+func (c *cmdSet) handleResetSNI(cfg *config) error {
+	return c.addRules(cfg.resetSNI, c.fw.resetSNI)
+}
+
+// dnsSpoofAddress returns the address where the DNS spoofer should listen.
+func (c *cmdSet) dnsSpoofAddress() string {
+	if c.netns {
+		return net.JoinHostPort(netnsHostAddr, dnsSpoofPort)
 	}
+	return net.JoinHostPort("127.0.0.1", dnsSpoofPort)
 }
 
-func (c *cmdSet) handleResetKeywordHex(cfg *config) {
-	for _, keyword := range cfg.resetKeywordHex {
-		c.addSetupCmd(fmt.Sprintf(
-			"iptables -A JAFAR_OUTPUT -m string --proto tcp --algo kmp --hex-string '%s' -j REJECT --reject-with tcp-reset",
-			keyword,
-		))
+func (c *cmdSet) handleDNSSpoof(cfg *config) error {
+	if len(cfg.dnsSpoof) <= 0 {
+		return nil
+	}
+	argv, err := c.fw.hijackDNS(c.dnsSpoofAddress())
+	if err != nil {
+		return err
 	}
+	c.addScopedSetupCmd(argv)
+	return nil
 }
 
-func (c *cmdSet) handleResetKeyword(cfg *config) {
-	for _, keyword := range cfg.resetKeyword {
-		c.addSetupCmd(fmt.Sprintf(
-			"iptables -A JAFAR_OUTPUT -m string --proto tcp --algo kmp --string '%s' -j REJECT --reject-with tcp-reset",
-			keyword,
-		))
+// errTCRequiresDevice indicates that we do not know which device to shape.
+var errTCRequiresDevice = errors.New("tinyjafar: -tc-rate requires -tc-dev unless using -netns")
+
+func (c *cmdSet) handleTCRate(cfg *config) error {
+	if cfg.tcRate == "" {
+		return nil
+	}
+	dev := cfg.tcDev
+	if dev == "" && c.netns {
+		dev = netnsDev
 	}
+	if dev == "" {
+		return errTCRequiresDevice
+	}
+	c.addScopedSetupCmd(fmt.Sprintf(
+		"tc qdisc add dev '%s' root tbf rate '%s' burst 32kbit latency 400ms", dev, cfg.tcRate))
+	c.addScopedCleanupCmd(fmt.Sprintf("tc qdisc del dev '%s' root", dev))
+	return nil
 }
 
 func main() {
@@ -143,6 +320,12 @@ var (
 	mainWithArgsCalled = &atomic.Int64{}
 )
 
+// errNetnsRequiresCommand indicates that -netns requires a command to run.
+var errNetnsRequiresCommand = errors.New("tinyjafar: -netns requires a command to run")
+
+// errCommandRequiresNetns indicates that a command to run requires -netns.
+var errCommandRequiresNetns = errors.New("tinyjafar: running a command requires -netns")
+
 func mainWithArgs(writer io.Writer, sigChan <-chan os.Signal, args ...string) {
 	if returnImmediately.Load() {
 		mainWithArgsCalled.Add(1)
@@ -155,34 +338,59 @@ func mainWithArgs(writer io.Writer, sigChan <-chan os.Signal, args ...string) {
 
 	runtimex.Try0(fset.Parse(args))
 
-	cs := newCmdSet()
-	cs.handleDropIP(cfg)
-	cs.handleDropKeywordHex(cfg)
-	cs.handleDropKeyword(cfg)
-	cs.handleResetIP(cfg)
-	cs.handleResetKeywordHex(cfg)
-	cs.handleResetKeyword(cfg)
+	command := fset.Args()
+	switch {
+	case cfg.netns && len(command) <= 0:
+		runtimex.PanicOnError(errNetnsRequiresCommand, "invalid command line")
+	case !cfg.netns && len(command) > 0:
+		runtimex.PanicOnError(errCommandRequiresNetns, "invalid command line")
+	}
+	dnsAnswers := runtimex.Try1(parseDNSSpoofRules(cfg.dnsSpoof))
+
+	cs := newCmdSet(runtimex.Try1(newFirewall(cfg.backend)), cfg.netns, cfg.dryRun)
+	runtimex.Try0(cs.handleDropIP(cfg))
+	runtimex.Try0(cs.handleDropKeywordHex(cfg))
+	runtimex.Try0(cs.handleDropKeyword(cfg))
+	runtimex.Try0(cs.handleResetIP(cfg))
+	runtimex.Try0(cs.handleResetKeywordHex(cfg))
+	runtimex.Try0(cs.handleResetKeyword(cfg))
+	runtimex.Try0(cs.handleResetSNI(cfg))
+	runtimex.Try0(cs.handleDNSSpoof(cfg))
+	runtimex.Try0(cs.handleTCRate(cfg))
 
 	// with -dry-run, we're just going to print the commands we'd execute
 	dryShellRun := func(logger model.Logger, command string, args ...string) error {
 		_, err := fmt.Fprintf(writer, "+ %s\n", shellx.QuotedCommandLineUnsafe(command, args...))
 		return err
 	}
-	var runSelector = map[bool]func(logger model.Logger, command string, args ...string) error{
+	var runSelector = map[bool]shellRunFunc{
 		true:  dryShellRun,
 		false: shellx.Run,
 	}
 	runx := runSelector[cfg.dryRun]
 
-	for _, cmd := range cs.setup {
-		runtimex.Try0(runx(log.Log, cmd.argv[0], cmd.argv[1:]...))
-	}
+	runtimex.Try0(cs.runSetup(runx))
 
-	fmt.Fprintf(writer, "\nUse Ctrl-C to terminate\n\n")
-	<-sigChan
+	// the DNS spoofer only makes sense when we're not using -dry-run
+	if len(dnsAnswers) > 0 && !cfg.dryRun {
+		server, err := startDNSSpoofer(cs.dnsSpoofAddress(), dnsAnswers)
+		if err != nil {
+			cs.runCleanup(runx)
+			runtimex.PanicOnError(err, "startDNSSpoofer")
+		}
+		defer server.Shutdown()
+	}
 
-	for _, cmd := range cs.cleanup {
-		// ignoring the return value here is intentional to avoid interrupting the cleanup midway
-		_ = runx(log.Log, cmd.argv[0], cmd.argv[1:]...)
+	if cfg.netns {
+		// we only log the failure here because we want to cleanup anyway
+		argv := append([]string{"netns", "exec", netnsName}, command...)
+		if err := runx(log.Log, "ip", argv...); err != nil {
+			log.Warnf("tinyjafar: command failed: %s", err.Error())
+		}
+	} else {
+		fmt.Fprintf(writer, "\nUse Ctrl-C to terminate\n\n")
+		<-sigChan
 	}
+
+	cs.runCleanup(runx)
 }
@@ -1,11 +1,14 @@
 package main
 
 import (
+	"errors"
 	"os"
 	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/shellx"
 )
 
 func TestMain(t *testing.T) {
@@ -230,13 +233,217 @@ func TestMain(t *testing.T) {
 				"",
 			},
 		},
+		{
+			name: "with -reset-sni",
+			args: []string{
+				"-reset-sni", "ooni.org",
+			},
+			expect: []string{
+				"+ iptables -N JAFAR_INPUT",
+				"+ iptables -N JAFAR_OUTPUT",
+				"+ iptables -t nat -N JAFAR_NAT_OUTPUT",
+				"+ iptables -I OUTPUT -j JAFAR_OUTPUT",
+				"+ iptables -I INPUT -j JAFAR_INPUT",
+				"+ iptables -t nat -I OUTPUT -j JAFAR_NAT_OUTPUT",
+				"+ iptables -A JAFAR_OUTPUT --proto tcp --dport 443 -m string --algo kmp --string ooni.org -j REJECT --reject-with tcp-reset",
+				"",
+				"Use Ctrl-C to terminate",
+				"",
+				"+ iptables -D OUTPUT -j JAFAR_OUTPUT",
+				"+ iptables -D INPUT -j JAFAR_INPUT",
+				"+ iptables -t nat -D OUTPUT -j JAFAR_NAT_OUTPUT",
+				"+ iptables -F JAFAR_INPUT",
+				"+ iptables -X JAFAR_INPUT",
+				"+ iptables -F JAFAR_OUTPUT",
+				"+ iptables -X JAFAR_OUTPUT",
+				"+ iptables -t nat -F JAFAR_NAT_OUTPUT",
+				"+ iptables -t nat -X JAFAR_NAT_OUTPUT",
+				"",
+			},
+		},
+
+		{
+			name: "with -dns-spoof",
+			args: []string{
+				"-dns-spoof", "ooni.org=10.0.0.1",
+			},
+			expect: []string{
+				"+ iptables -N JAFAR_INPUT",
+				"+ iptables -N JAFAR_OUTPUT",
+				"+ iptables -t nat -N JAFAR_NAT_OUTPUT",
+				"+ iptables -I OUTPUT -j JAFAR_OUTPUT",
+				"+ iptables -I INPUT -j JAFAR_INPUT",
+				"+ iptables -t nat -I OUTPUT -j JAFAR_NAT_OUTPUT",
+				"+ iptables -t nat -A JAFAR_NAT_OUTPUT --proto udp --dport 53 -j DNAT --to-destination 127.0.0.1:5353",
+				"",
+				"Use Ctrl-C to terminate",
+				"",
+				"+ iptables -D OUTPUT -j JAFAR_OUTPUT",
+				"+ iptables -D INPUT -j JAFAR_INPUT",
+				"+ iptables -t nat -D OUTPUT -j JAFAR_NAT_OUTPUT",
+				"+ iptables -F JAFAR_INPUT",
+				"+ iptables -X JAFAR_INPUT",
+				"+ iptables -F JAFAR_OUTPUT",
+				"+ iptables -X JAFAR_OUTPUT",
+				"+ iptables -t nat -F JAFAR_NAT_OUTPUT",
+				"+ iptables -t nat -X JAFAR_NAT_OUTPUT",
+				"",
+			},
+		},
+
+		{
+			name: "with -tc-rate and -tc-dev",
+			args: []string{
+				"-tc-rate", "256kbit", "-tc-dev", "eth0",
+			},
+			expect: []string{
+				"+ iptables -N JAFAR_INPUT",
+				"+ iptables -N JAFAR_OUTPUT",
+				"+ iptables -t nat -N JAFAR_NAT_OUTPUT",
+				"+ iptables -I OUTPUT -j JAFAR_OUTPUT",
+				"+ iptables -I INPUT -j JAFAR_INPUT",
+				"+ iptables -t nat -I OUTPUT -j JAFAR_NAT_OUTPUT",
+				"+ tc qdisc add dev eth0 root tbf rate 256kbit burst 32kbit latency 400ms",
+				"",
+				"Use Ctrl-C to terminate",
+				"",
+				"+ iptables -D OUTPUT -j JAFAR_OUTPUT",
+				"+ iptables -D INPUT -j JAFAR_INPUT",
+				"+ iptables -t nat -D OUTPUT -j JAFAR_NAT_OUTPUT",
+				"+ iptables -F JAFAR_INPUT",
+				"+ iptables -X JAFAR_INPUT",
+				"+ iptables -F JAFAR_OUTPUT",
+				"+ iptables -X JAFAR_OUTPUT",
+				"+ iptables -t nat -F JAFAR_NAT_OUTPUT",
+				"+ iptables -t nat -X JAFAR_NAT_OUTPUT",
+				"+ tc qdisc del dev eth0 root",
+				"",
+			},
+		},
+
+		{
+			name: "with -backend nftables",
+			args: []string{
+				"-backend", "nftables",
+				"-drop-ip", "130.192.16.171",
+				"-reset-ip", "2001:db8::1",
+				"-dns-spoof", "ooni.org=10.0.0.1",
+			},
+			expect: []string{
+				"+ nft add table inet tinyjafar",
+				`+ nft add chain inet tinyjafar output "{ type filter hook output priority 0 ; }"`,
+				"+ nft add table ip tinyjafar_nat",
+				`+ nft add chain ip tinyjafar_nat output "{ type nat hook output priority -100 ; }"`,
+				"+ nft add rule inet tinyjafar output ip daddr 130.192.16.171 drop",
+				"+ nft add rule inet tinyjafar output meta l4proto tcp ip6 daddr 2001:db8::1 reject with tcp reset",
+				"+ nft add rule ip tinyjafar_nat output udp dport 53 dnat to 127.0.0.1:5353",
+				"",
+				"Use Ctrl-C to terminate",
+				"",
+				"+ nft delete table inet tinyjafar",
+				"+ nft delete table ip tinyjafar_nat",
+				"",
+			},
+		},
+
+		{
+			name: "with -netns",
+			args: []string{
+				"-netns",
+				"-iptables-drop-ip", "130.192.16.171",
+				"-tc-rate", "1mbit",
+				"curl", "-v", "https://nexa.polito.it/",
+			},
+			expect: []string{
+				"+ ip netns add tinyjafar",
+				"+ ip link add tj-host type veth peer name tj-ns",
+				"+ ip link set tj-ns netns tinyjafar",
+				"+ ip addr add 10.117.0.1/30 dev tj-host",
+				"+ ip link set tj-host up",
+				"+ ip netns exec tinyjafar ip addr add 10.117.0.2/30 dev tj-ns",
+				"+ ip netns exec tinyjafar ip link set tj-ns up",
+				"+ ip netns exec tinyjafar ip link set lo up",
+				"+ ip netns exec tinyjafar ip route add default via 10.117.0.1",
+				"+ mkdir -p /etc/netns/tinyjafar",
+				`+ sh -c "echo nameserver 8.8.8.8 > /etc/netns/tinyjafar/resolv.conf"`,
+				"+ sysctl net.ipv4.ip_forward",
+				"+ sysctl -w net.ipv4.ip_forward=1",
+				"+ iptables -t nat -I POSTROUTING -s 10.117.0.0/30 -j MASQUERADE",
+				"+ iptables -I FORWARD -s 10.117.0.0/30 -j ACCEPT",
+				"+ iptables -I FORWARD -d 10.117.0.0/30 -j ACCEPT",
+				"+ ip netns exec tinyjafar iptables -N JAFAR_INPUT",
+				"+ ip netns exec tinyjafar iptables -N JAFAR_OUTPUT",
+				"+ ip netns exec tinyjafar iptables -t nat -N JAFAR_NAT_OUTPUT",
+				"+ ip netns exec tinyjafar iptables -I OUTPUT -j JAFAR_OUTPUT",
+				"+ ip netns exec tinyjafar iptables -I INPUT -j JAFAR_INPUT",
+				"+ ip netns exec tinyjafar iptables -t nat -I OUTPUT -j JAFAR_NAT_OUTPUT",
+				"+ ip netns exec tinyjafar iptables -A JAFAR_OUTPUT -d 130.192.16.171 -j DROP",
+				"+ ip netns exec tinyjafar tc qdisc add dev tj-ns root tbf rate 1mbit burst 32kbit latency 400ms",
+				"+ ip netns exec tinyjafar curl -v https://nexa.polito.it/",
+				"+ ip netns del tinyjafar",
+				"+ iptables -t nat -D POSTROUTING -s 10.117.0.0/30 -j MASQUERADE",
+				"+ iptables -D FORWARD -s 10.117.0.0/30 -j ACCEPT",
+				"+ iptables -D FORWARD -d 10.117.0.0/30 -j ACCEPT",
+				"+ rm -rf /etc/netns/tinyjafar",
+				"+ sysctl -w net.ipv4.ip_forward=PREVIOUS_VALUE",
+				"",
+			},
+		},
+
+		{
+			name: "with -netns and -backend nftables",
+			args: []string{
+				"-netns",
+				"-backend", "nftables",
+				"-dns-spoof", "ooni.org=10.0.0.1",
+				"dig", "ooni.org",
+			},
+			expect: []string{
+				"+ ip netns add tinyjafar",
+				"+ ip link add tj-host type veth peer name tj-ns",
+				"+ ip link set tj-ns netns tinyjafar",
+				"+ ip addr add 10.117.0.1/30 dev tj-host",
+				"+ ip link set tj-host up",
+				"+ ip netns exec tinyjafar ip addr add 10.117.0.2/30 dev tj-ns",
+				"+ ip netns exec tinyjafar ip link set tj-ns up",
+				"+ ip netns exec tinyjafar ip link set lo up",
+				"+ ip netns exec tinyjafar ip route add default via 10.117.0.1",
+				"+ mkdir -p /etc/netns/tinyjafar",
+				`+ sh -c "echo nameserver 8.8.8.8 > /etc/netns/tinyjafar/resolv.conf"`,
+				"+ sysctl net.ipv4.ip_forward",
+				"+ sysctl -w net.ipv4.ip_forward=1",
+				"+ nft add table ip tinyjafar_host",
+				`+ nft add chain ip tinyjafar_host postrouting "{ type nat hook postrouting priority 100 ; }"`,
+				"+ nft add rule ip tinyjafar_host postrouting ip saddr 10.117.0.0/30 masquerade",
+				"+ ip netns exec tinyjafar nft add table inet tinyjafar",
+				`+ ip netns exec tinyjafar nft add chain inet tinyjafar output "{ type filter hook output priority 0 ; }"`,
+				"+ ip netns exec tinyjafar nft add table ip tinyjafar_nat",
+				`+ ip netns exec tinyjafar nft add chain ip tinyjafar_nat output "{ type nat hook output priority -100 ; }"`,
+				"+ ip netns exec tinyjafar nft add rule ip tinyjafar_nat output udp dport 53 dnat to 10.117.0.1:5353",
+				"+ ip netns exec tinyjafar dig ooni.org",
+				"+ ip netns del tinyjafar",
+				"+ nft delete table ip tinyjafar_host",
+				"+ rm -rf /etc/netns/tinyjafar",
+				"+ sysctl -w net.ipv4.ip_forward=PREVIOUS_VALUE",
+				"",
+			},
+		},
+	}
+
+	// make sure that we do not read the host's IPv4 forwarding setting with -dry-run
+	oldReadIPForward := readIPForward
+	defer func() {
+		readIPForward = oldReadIPForward
+	}()
+	readIPForward = func() string {
+		t.Fatal("should not read the IPv4 forwarding setting with -dry-run")
+		return ""
 	}
 
 	for _, tc := range testcases {
 		t.Run(tc.name, func(t *testing.T) {
 			var builder strings.Builder
-			input := append([]string{}, tc.args...)
-			input = append(input, "-dry-run")
+			input := append([]string{"-dry-run"}, tc.args...)
 
 			sigChan := make(chan os.Signal)
 			close(sigChan) // so mainWithArgs would not block
@@ -252,3 +459,150 @@ func TestMain(t *testing.T) {
 		})
 	}
 }
+
+func TestNewCmdSetWithIPForwardEnabled(t *testing.T) {
+	oldReadIPForward := readIPForward
+	defer func() {
+		readIPForward = oldReadIPForward
+	}()
+	readIPForward = func() string {
+		return "1"
+	}
+	cs := newCmdSet(&iptablesFirewall{}, true, false)
+	for _, cmd := range append(cs.setup, cs.cleanup...) {
+		if cmd.argv[0] == "sysctl" {
+			t.Fatal("did not expect to change the IPv4 forwarding setting", cmd.argv)
+		}
+	}
+}
+
+func TestNewCmdSetWithIPForwardDisabled(t *testing.T) {
+	oldReadIPForward := readIPForward
+	defer func() {
+		readIPForward = oldReadIPForward
+	}()
+	readIPForward = func() string {
+		return "0"
+	}
+	cs := newCmdSet(&iptablesFirewall{}, true, false)
+	var sysctl []string
+	for _, cmd := range append(cs.setup, cs.cleanup...) {
+		if cmd.argv[0] == "sysctl" {
+			sysctl = append(sysctl, shellx.QuotedCommandLineUnsafe(cmd.argv[0], cmd.argv[1:]...))
+		}
+	}
+	expect := []string{
+		"sysctl -w net.ipv4.ip_forward=1",
+		"sysctl -w net.ipv4.ip_forward=0",
+	}
+	if diff := cmp.Diff(expect, sysctl); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+func TestCmdSetRunSetup(t *testing.T) {
+	expected := errors.New("mocked error")
+	cs := &cmdSet{}
+	cs.addSetupCmd("ip netns add tinyjafar")
+	cs.addSetupCmd("ip link add tj-host type veth peer name tj-ns")
+	cs.addSetupCmd("ip link set tj-host up")
+	cs.addCleanupCmd("ip netns del tinyjafar")
+	cs.addCleanupCmd("rm -rf /etc/netns/tinyjafar")
+
+	var executed []string
+	runx := func(logger model.Logger, command string, args ...string) error {
+		argv := shellx.QuotedCommandLineUnsafe(command, args...)
+		executed = append(executed, argv)
+		if strings.HasPrefix(argv, "ip link add") {
+			return expected
+		}
+		return nil
+	}
+	if err := cs.runSetup(runx); !errors.Is(err, expected) {
+		t.Fatal("unexpected error", err)
+	}
+	expect := []string{
+		"ip netns add tinyjafar",
+		"ip link add tj-host type veth peer name tj-ns",
+		"ip netns del tinyjafar",
+		"rm -rf /etc/netns/tinyjafar",
+	}
+	if diff := cmp.Diff(expect, executed); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+func TestMainWithInvalidArguments(t *testing.T) {
+	// testcase is a test case for this function
+	type testcase struct {
+		// name is the test case name
+		name string
+
+		// args contains the arguments passed to the command line
+		args []string
+
+		// expect is the expected panic message
+		expect string
+	}
+
+	testcases := []testcase{{
+		name:   "with an unsupported backend",
+		args:   []string{"-backend", "pf"},
+		expect: "tinyjafar: unsupported backend: pf",
+	}, {
+		name:   "with keyword matching and nftables",
+		args:   []string{"-backend", "nftables", "-drop-keyword", "ooni.org"},
+		expect: errNftablesNoStringMatch.Error(),
+	}, {
+		name:   "with -reset-sni and nftables",
+		args:   []string{"-backend", "nftables", "-reset-sni", "ooni.org"},
+		expect: errNftablesNoStringMatch.Error(),
+	}, {
+		name:   "with an invalid IP address and nftables",
+		args:   []string{"-backend", "nftables", "-iptables-drop-ip", "ooni.org"},
+		expect: "tinyjafar: invalid IP address: ooni.org",
+	}, {
+		name:   "with an invalid -dns-spoof rule",
+		args:   []string{"-dns-spoof", "ooni.org"},
+		expect: "tinyjafar: invalid -dns-spoof rule: ooni.org",
+	}, {
+		name:   "with -tc-rate and without -tc-dev",
+		args:   []string{"-tc-rate", "1mbit"},
+		expect: errTCRequiresDevice.Error(),
+	}, {
+		name:   "with -netns and without a command",
+		args:   []string{"-netns"},
+		expect: errNetnsRequiresCommand.Error(),
+	}, {
+		name:   "with a command and without -netns",
+		args:   []string{"curl", "https://ooni.org/"},
+		expect: errCommandRequiresNetns.Error(),
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var builder strings.Builder
+			input := append([]string{"-dry-run"}, tc.args...)
+
+			sigChan := make(chan os.Signal)
+			close(sigChan) // so mainWithArgs would not block
+
+			var panicked error
+			func() {
+				defer func() {
+					if r := recover(); r != nil {
+						panicked, _ = r.(error)
+					}
+				}()
+				mainWithArgs(&builder, sigChan, input...)
+			}()
+
+			if panicked == nil || !strings.Contains(panicked.Error(), tc.expect) {
+				t.Fatal("expected", tc.expect, "got", panicked)
+			}
+			if builder.Len() != 0 {
+				t.Fatal("expected no commands, got", builder.String())
+			}
+		})
+	}
+}
@@ -8,9 +8,11 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/apex/log"
+	"github.com/mattn/go-sqlite3"
 	"github.com/ooni/probe-cli/v3/internal/engine"
 	"github.com/ooni/probe-cli/v3/internal/model"
 	"github.com/pkg/errors"
@@ -146,6 +148,7 @@ func (d *Database) ListResults() ([]model.DatabaseResultNetwork, []model.Databas
 		db.Raw("results.result_data_usage_up"),
 		db.Raw("results.result_data_usage_down"),
 		db.Raw("results.measurement_dir"),
+		db.Raw("results.oonirun_subscription_id"),
 
 		db.Raw("COUNT(CASE WHEN measurements.is_anomaly = TRUE THEN 1 END) as anomaly_count"),
 		db.Raw("COUNT() as total_count"),
@@ -176,6 +179,7 @@ func (d *Database) ListResults() ([]model.DatabaseResultNetwork, []model.Databas
 			db.Raw("results.result_data_usage_up"),
 			db.Raw("results.result_data_usage_down"),
 			db.Raw("results.measurement_dir"),
+			db.Raw("results.oonirun_subscription_id"),
 		)
 	if err := req.Where("result_is_done = true").All(&doneResults); err != nil {
 		return doneResults, incompleteResults, errors.Wrap(err, "failed to get result done list")
@@ -291,6 +295,131 @@ func (d *Database) CreateResult(homePath string, testGroupName string, networkID
 	return &result, nil
 }
 
+// CreateOONIRunResult implements WritableDatabase.CreateOONIRunResult
+func (d *Database) CreateOONIRunResult(homePath string, subscriptionID int64, networkID int64) (*model.DatabaseResult, error) {
+	startTime := time.Now().UTC()
+
+	p, err := makeResultsDir(homePath, model.OONIRunGroupName, startTime)
+	if err != nil {
+		return nil, err
+	}
+
+	result := model.DatabaseResult{
+		TestGroupName:         model.OONIRunGroupName,
+		StartTime:             startTime,
+		NetworkID:             networkID,
+		OONIRunSubscriptionID: sql.NullInt64{Int64: subscriptionID, Valid: true},
+	}
+	result.MeasurementDir = p
+	log.Debugf("Creating result %v", result)
+
+	newID, err := d.sess.Collection("results").Insert(result)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating result")
+	}
+	result.ID = newID.ID().(int64)
+	return &result, nil
+}
+
+// CreateOONIRunSubscription implements WritableDatabase.CreateOONIRunSubscription
+func (d *Database) CreateOONIRunSubscription(URL string, descriptor string) (*model.DatabaseOONIRunSubscription, error) {
+	now := time.Now().UTC()
+	sub := model.DatabaseOONIRunSubscription{
+		URL:               URL,
+		Descriptor:        descriptor,
+		PendingDescriptor: "",
+		CreatedAt:         now,
+		RefreshedAt:       now,
+	}
+	newID, err := d.sess.Collection("oonirun_subscriptions").Insert(sub)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating subscription")
+	}
+	sub.ID = newID.ID().(int64)
+	return &sub, nil
+}
+
+// UpdateOONIRunSubscription implements WritableDatabase.UpdateOONIRunSubscription
+func (d *Database) UpdateOONIRunSubscription(sub *model.DatabaseOONIRunSubscription) error {
+	err := d.sess.Collection("oonirun_subscriptions").Find("subscription_id", sub.ID).Update(sub)
+	if err != nil {
+		return errors.Wrap(err, "updating subscription")
+	}
+	return nil
+}
+
+// DeleteOONIRunSubscription implements WritableDatabase.DeleteOONIRunSubscription
+func (d *Database) DeleteOONIRunSubscription(subscriptionID int64) error {
+	res := d.sess.Collection("oonirun_subscriptions").Find("subscription_id", subscriptionID)
+	exists, err := res.Exists()
+	if err != nil {
+		return errors.Wrap(err, "finding subscription")
+	}
+	if !exists {
+		return db.ErrNoMoreRows
+	}
+	return res.Delete()
+}
+
+// ListURLsLastMeasured implements ReadableDatabase.ListURLsLastMeasured
+func (d *Database) ListURLsLastMeasured(testName string, asn uint) (map[string]time.Time, error) {
+	rows := []struct {
+		URL       string `db:"url"`
+		StartTime string `db:"measurement_start_time"`
+	}{}
+	req := d.sess.SQL().Select(
+		db.Raw("urls.url"),
+		// The driver stores times as strings that may use different UTC offsets, so we
+		// normalise them to UTC, which strftime does, before comparing them as strings.
+		db.Raw("MAX(strftime('%Y-%m-%d %H:%M:%f', measurements.measurement_start_time)) AS measurement_start_time"),
+	).From("measurements").
+		Join("urls").On("urls.url_id = measurements.url_id").
+		Join("results").On("results.result_id = measurements.result_id").
+		Join("networks").On("results.network_id = networks.network_id").
+		Where("measurements.test_name = ? AND networks.asn = ?", testName, asn).
+		GroupBy("measurements.url_id")
+	if err := req.All(&rows); err != nil {
+		log.Errorf("failed to run query %s: %v", req.String(), err)
+		return nil, err
+	}
+	lastMeasured := make(map[string]time.Time)
+	for _, row := range rows {
+		// The driver does not know that MAX returns a DATETIME, so we need to
+		// parse the UTC string returned by strftime using the driver formats.
+		startTime, err := parseSQLiteTimestamp(row.StartTime)
+		if err != nil {
+			log.Errorf("failed to parse start time %s: %v", row.StartTime, err)
+			return nil, err
+		}
+		lastMeasured[row.URL] = startTime
+	}
+	return lastMeasured, nil
+}
+
+// errInvalidTimestamp indicates that we cannot parse an SQLite timestamp.
+var errInvalidTimestamp = errors.New("invalid timestamp")
+
+// parseSQLiteTimestamp parses a timestamp using the formats used by the SQLite driver.
+func parseSQLiteTimestamp(value string) (time.Time, error) {
+	value = strings.TrimSuffix(value, "Z")
+	for _, format := range sqlite3.SQLiteTimestampFormats {
+		if t, err := time.ParseInLocation(format, value, time.UTC); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.Wrap(errInvalidTimestamp, value)
+}
+
+// ListOONIRunSubscriptions implements ReadableDatabase.ListOONIRunSubscriptions
+func (d *Database) ListOONIRunSubscriptions() ([]model.DatabaseOONIRunSubscription, error) {
+	subs := []model.DatabaseOONIRunSubscription{}
+	res := d.sess.Collection("oonirun_subscriptions").Find().OrderBy("subscription_id")
+	if err := res.All(&subs); err != nil {
+		return subs, errors.Wrap(err, "failed to list subscriptions")
+	}
+	return subs, nil
+}
+
 // CreateNetwork implements WritableDatabase.CreateNetwork
 func (d *Database) CreateNetwork(loc model.LocationProvider) (*model.DatabaseNetwork, error) {
 	network := model.DatabaseNetwork{
@@ -301,6 +430,14 @@ func (d *Database) CreateNetwork(loc model.LocationProvider) (*model.DatabaseNet
 		NetworkType: "wifi",
 		IP:          loc.ProbeIP(),
 	}
+	v4, v6 := loc.ProbeIPv4Egress(), loc.ProbeIPv6Egress()
+	if v4 != nil {
+		network.IPv4, network.IPv4ASN = v4.ProbeIP, v4.ASN
+	}
+	if v6 != nil {
+		network.IPv6, network.IPv6ASN = v6.ProbeIP, v6.ASN
+	}
+	network.IsDualStack = v4 != nil && v6 != nil
 	newID, err := d.sess.Collection("networks").Insert(network)
 	if err != nil {
 		return nil, err
@@ -3,10 +3,12 @@ package database
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/ooni/probe-cli/v3/internal/engine"
@@ -21,6 +23,8 @@ type locationInfo struct {
 	asn         uint
 	countryCode string
 	ip          string
+	ipv4Egress  *model.LocationEgress
+	ipv6Egress  *model.LocationEgress
 	networkName string
 	resolverIP  string
 }
@@ -41,6 +45,14 @@ func (lp *locationInfo) ProbeIP() string {
 	return lp.ip
 }
 
+func (lp *locationInfo) ProbeIPv4Egress() *model.LocationEgress {
+	return lp.ipv4Egress
+}
+
+func (lp *locationInfo) ProbeIPv6Egress() *model.LocationEgress {
+	return lp.ipv6Egress
+}
+
 func (lp *locationInfo) ProbeNetworkName() string {
 	return lp.networkName
 }
@@ -136,6 +148,10 @@ func TestMeasurementWorkflow(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	if err := database.NetworkChanged(m2); err != nil {
+		t.Fatal(err)
+	}
+
 	if m2.ResultID != m1.ResultID {
 		t.Error("result_id mismatch")
 	}
@@ -180,6 +196,12 @@ func TestMeasurementWorkflow(t *testing.T) {
 	if msmts[0].DatabaseNetwork.NetworkType != "wifi" {
 		t.Error("network_type should be wifi")
 	}
+	if msmts[0].IsNetworkChanged {
+		t.Error("the first measurement should not be marked as network changed")
+	}
+	if !msmts[1].IsNetworkChanged {
+		t.Error("the second measurement should be marked as network changed")
+	}
 }
 
 func TestDeleteResult(t *testing.T) {
@@ -262,6 +284,175 @@ func TestDeleteResult(t *testing.T) {
 	}
 }
 
+func TestOONIRunSubscriptions(t *testing.T) {
+	tmpfile, err := ioutil.TempFile("", "dbtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	tmpdir, err := ioutil.TempDir("", "oonitest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	database, err := Open(tmpfile.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const URL = "https://run.ooni.io/v2/10001"
+	sub, err := database.CreateOONIRunSubscription(URL, `{"name":"antani"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.CreateOONIRunSubscription(URL, `{}`); err == nil {
+		t.Fatal("expected an error when subscribing twice to the same URL")
+	}
+
+	sub.PendingDescriptor = `{"name":"mascetti"}`
+	if err := database.UpdateOONIRunSubscription(sub); err != nil {
+		t.Fatal(err)
+	}
+	subs, err := database.ListOONIRunSubscriptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subs) != 1 || subs[0].ID != sub.ID || subs[0].URL != URL {
+		t.Fatal("unexpected subscriptions", subs)
+	}
+	if subs[0].Descriptor != `{"name":"antani"}` || subs[0].PendingDescriptor != `{"name":"mascetti"}` {
+		t.Fatal("unexpected descriptors", subs[0])
+	}
+
+	location := locationInfo{
+		asn:         0,
+		countryCode: "IT",
+		networkName: "Unknown",
+	}
+	network, err := database.CreateNetwork(&location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := database.CreateOONIRunResult(tmpdir, sub.ID, network.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.TestGroupName != model.OONIRunGroupName {
+		t.Fatal("unexpected test group name", result.TestGroupName)
+	}
+	_, err = database.CreateMeasurement(
+		sql.NullString{}, "example", result.MeasurementDir, 0, result.ID, sql.NullInt64{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := database.Finished(result); err != nil {
+		t.Fatal(err)
+	}
+
+	done, _, err := database.ListResults()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != 1 || done[0].OONIRunSubscriptionID.Int64 != sub.ID {
+		t.Fatal("unexpected results", done)
+	}
+
+	// deleting the subscription must not delete the results
+	if err := database.DeleteOONIRunSubscription(sub.ID); err != nil {
+		t.Fatal(err)
+	}
+	done, _, err = database.ListResults()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != 1 || done[0].OONIRunSubscriptionID.Valid {
+		t.Fatal("unexpected results", done)
+	}
+	if err := database.DeleteOONIRunSubscription(sub.ID); err != db.ErrNoMoreRows {
+		t.Fatal("unexpected error", err)
+	}
+}
+
+func TestListURLsLastMeasured(t *testing.T) {
+	tmpfile, err := ioutil.TempFile("", "dbtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	tmpdir, err := ioutil.TempDir("", "oonitest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	database, err := Open(tmpfile.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	createMeasurement := func(asn uint, testName, URL string, startTime time.Time) {
+		network, err := database.CreateNetwork(&locationInfo{asn: asn, countryCode: "IT", networkName: "Unknown"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := database.CreateResult(tmpdir, "websites", network.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		urlID, err := database.CreateOrUpdateURL(URL, "NEWS", "IT")
+		if err != nil {
+			t.Fatal(err)
+		}
+		msmt, err := database.CreateMeasurement(sql.NullString{}, testName, tmpdir, 0,
+			result.ID, sql.NullInt64{Int64: urlID, Valid: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		msmt.StartTime = startTime
+		err = database.Session().Collection("measurements").Find("measurement_id", msmt.ID).Update(msmt)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	older := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	newer := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	createMeasurement(30722, "web_connectivity", "https://repubblica.it/", older)
+	createMeasurement(30722, "web_connectivity", "https://repubblica.it/", newer)
+	createMeasurement(30722, "web_connectivity", "https://corriere.it/", older)
+	createMeasurement(3269, "web_connectivity", "https://ilpost.it/", newer)
+	createMeasurement(30722, "dnscheck", "https://dns.google/dns-query", newer)
+
+	// make sure we compare the times rather than their text representations, which
+	// would sort the time using the +02:00 offset after the one using UTC
+	cest := time.FixedZone("CEST", 2*60*60)
+	earlierWithOffset := time.Date(2024, 1, 3, 1, 0, 0, 0, cest)
+	laterInUTC := time.Date(2024, 1, 2, 23, 30, 0, 0, time.UTC)
+	createMeasurement(30722, "web_connectivity", "https://ilfattoquotidiano.it/", earlierWithOffset)
+	createMeasurement(30722, "web_connectivity", "https://ilfattoquotidiano.it/", laterInUTC)
+
+	lastMeasured, err := database.ListURLsLastMeasured("web_connectivity", 30722)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := map[string]time.Time{
+		"https://repubblica.it/":        newer,
+		"https://corriere.it/":          older,
+		"https://ilfattoquotidiano.it/": laterInUTC,
+	}
+	if len(lastMeasured) != len(expect) {
+		t.Fatal("unexpected last measured URLs", lastMeasured)
+	}
+	for URL, startTime := range expect {
+		if !lastMeasured[URL].Equal(startTime) {
+			t.Fatal("unexpected last measured time for", URL, lastMeasured[URL])
+		}
+	}
+}
+
 func TestNetworkCreate(t *testing.T) {
 	tmpfile, err := ioutil.TempFile("", "dbtest")
 	if err != nil {
@@ -296,6 +487,40 @@ func TestNetworkCreate(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	l3 := locationInfo{
+		asn:         30722,
+		countryCode: "IT",
+		ip:          "2a01:e11::1",
+		ipv4Egress:  &model.LocationEgress{ASN: 12874, ProbeIP: "130.25.90.1"},
+		ipv6Egress:  &model.LocationEgress{ASN: 30722, ProbeIP: "2a01:e11::1"},
+		networkName: "Vodafone Italia S.p.A.",
+	}
+
+	n3, err := database.CreateNetwork(&l3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got model.DatabaseNetwork
+	if err := database.Session().Collection("networks").Find("network_id", n3.ID).One(&got); err != nil {
+		t.Fatal(err)
+	}
+	expect := model.DatabaseNetwork{
+		ID:          n3.ID,
+		NetworkName: "Vodafone Italia S.p.A.",
+		NetworkType: "wifi",
+		IP:          "2a01:e11::1",
+		ASN:         30722,
+		CountryCode: "IT",
+		IPv4:        "130.25.90.1",
+		IPv4ASN:     12874,
+		IPv6:        "2a01:e11::1",
+		IPv6ASN:     30722,
+		IsDualStack: true,
+	}
+	if diff := cmp.Diff(expect, got); diff != "" {
+		t.Fatal(diff)
+	}
 }
 
 func TestURLCreation(t *testing.T) {
@@ -531,3 +756,23 @@ func TestUpdateDatabaseMeasurementWithSummaryKeys(t *testing.T) {
 		}
 	})
 }
+
+func TestParseSQLiteTimestamp(t *testing.T) {
+	t.Run("with a valid timestamp", func(t *testing.T) {
+		expect := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+		got, err := parseSQLiteTimestamp("2024-01-02 10:00:00+00:00")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !got.Equal(expect) {
+			t.Fatal("unexpected time", got)
+		}
+	})
+
+	t.Run("with an invalid timestamp", func(t *testing.T) {
+		_, err := parseSQLiteTimestamp("antani")
+		if !errors.Is(err, errInvalidTimestamp) {
+			t.Fatal("unexpected error", err)
+		}
+	})
+}
@@ -1,11 +1,13 @@
 package database
 
 import (
+	"database/sql"
 	"io/ioutil"
 	"os"
 	"testing"
 
 	"github.com/apex/log"
+	migrate "github.com/rubenv/sql-migrate"
 )
 
 func TestConnect(t *testing.T) {
@@ -30,3 +32,79 @@ func TestConnect(t *testing.T) {
 	}
 
 }
+
+func TestMigrationsDown(t *testing.T) {
+	tmpfile, err := ioutil.TempFile("", "dbtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+	tmpdir, err := ioutil.TempDir("", "oonitest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	database, err := Open(tmpfile.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := database.CreateOONIRunSubscription("https://run.ooni.io/v2/10001", `{}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	network, err := database.CreateNetwork(&locationInfo{countryCode: "IT", networkName: "Unknown"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := database.CreateOONIRunResult(tmpdir, sub.ID, network.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = database.CreateMeasurement(
+		sql.NullString{}, "example", result.MeasurementDir, 0, result.ID, sql.NullInt64{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// migrate down to the version preceding the OONI Run subscriptions
+	sqldb := database.Session().Driver().(*sql.DB)
+	migrations := &migrate.AssetMigrationSource{
+		Asset:    readAsset,
+		AssetDir: readAssetDir,
+		Dir:      "migrations",
+	}
+	names, err := readAssetDir("migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrate.ExecMax(sqldb, "sqlite3", migrations, migrate.Down, len(names)-3); err != nil {
+		t.Fatal(err)
+	}
+
+	// make sure we kept the results and the measurements referencing them
+	var count int
+	if err := sqldb.QueryRow("SELECT COUNT(*) FROM results").Scan(&count); err != nil || count != 1 {
+		t.Fatal("unexpected results count", count, err)
+	}
+	if err := sqldb.QueryRow("SELECT COUNT(*) FROM measurements").Scan(&count); err != nil || count != 1 {
+		t.Fatal("unexpected measurements count", count, err)
+	}
+	query := "SELECT COUNT(*) FROM pragma_table_info('results') WHERE name = 'oonirun_subscription_id'"
+	if err := sqldb.QueryRow(query).Scan(&count); err != nil || count != 0 {
+		t.Fatal("expected the oonirun_subscription_id column to be gone", count, err)
+	}
+	rows, err := sqldb.Query("PRAGMA foreign_key_check")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows.Next() {
+		t.Fatal("expected no foreign key violations")
+	}
+	rows.Close()
+
+	// make sure we can migrate up again
+	if err := RunMigrations(sqldb); err != nil {
+		t.Fatal(err)
+	}
+}
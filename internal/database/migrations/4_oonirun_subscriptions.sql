@@ -0,0 +1,88 @@
+-- +migrate Down notransaction
+-- +migrate StatementBegin
+
+-- SQLite cannot drop a column referencing another table, so we rebuild the
+-- `results` table without the `oonirun_subscription_id` column. We need to run
+-- outside of a transaction because PRAGMA foreign_keys is a no-op inside a
+-- transaction and, with foreign keys on, dropping `results` would cascade.
+PRAGMA foreign_keys=off;
+BEGIN TRANSACTION;
+
+CREATE TABLE `_results_new` (
+    `result_id` INTEGER PRIMARY KEY AUTOINCREMENT,
+    `test_group_name` VARCHAR(16) NOT NULL,
+    `result_start_time` DATETIME NOT NULL,
+    `result_runtime` REAL,
+    `result_is_viewed` TINYINT(1) NOT NULL,
+    `result_is_done` TINYINT(1) NOT NULL,
+    `result_data_usage_up` REAL NOT NULL,
+    `result_data_usage_down` REAL NOT NULL,
+    `measurement_dir` VARCHAR(260) NOT NULL,
+    `network_id` INTEGER NOT NULL,
+    `result_is_uploaded` TINYINT(1) DEFAULT 1 NOT NULL,
+    CONSTRAINT `fk_network_id`
+      FOREIGN KEY(`network_id`)
+      REFERENCES `networks`(`network_id`)
+);
+
+INSERT INTO _results_new (
+`result_id`,
+`test_group_name`,
+`result_start_time`,
+`result_runtime`,
+`result_is_viewed`,
+`result_is_done`,
+`result_data_usage_up`,
+`result_data_usage_down`,
+`measurement_dir`,
+`network_id`,
+`result_is_uploaded`
+)
+  SELECT `result_id`,
+`test_group_name`,
+`result_start_time`,
+`result_runtime`,
+`result_is_viewed`,
+`result_is_done`,
+`result_data_usage_up`,
+`result_data_usage_down`,
+`measurement_dir`,
+`network_id`,
+`result_is_uploaded`
+  FROM results;
+
+DROP TABLE results;
+
+ALTER TABLE _results_new RENAME TO results;
+
+DROP TABLE `oonirun_subscriptions`;
+
+COMMIT;
+PRAGMA foreign_keys=on;
+
+-- +migrate StatementEnd
+
+-- +migrate Up
+-- +migrate StatementBegin
+
+CREATE TABLE `oonirun_subscriptions` (
+    `subscription_id` INTEGER PRIMARY KEY AUTOINCREMENT,
+    -- The URL of the OONI Run v2 descriptor.
+    `subscription_url` TEXT NOT NULL UNIQUE,
+    -- The JSON serialized descriptor accepted by the user, which is the
+    -- one we use when running the subscription.
+    `subscription_descriptor` TEXT NOT NULL,
+    -- The JSON serialized descriptor we fetched when refreshing, which
+    -- differs from the accepted one and awaits for the user to accept
+    -- or reject it. It is empty when there are no pending changes.
+    `subscription_pending_descriptor` TEXT NOT NULL DEFAULT '',
+    `subscription_created_at` DATETIME NOT NULL,
+    -- The last time we successfully refreshed the descriptor.
+    `subscription_refreshed_at` DATETIME NOT NULL
+);
+
+ALTER TABLE `results`
+ADD COLUMN oonirun_subscription_id INTEGER DEFAULT NULL
+REFERENCES `oonirun_subscriptions` (`subscription_id`) ON DELETE SET NULL;
+
+-- +migrate StatementEnd
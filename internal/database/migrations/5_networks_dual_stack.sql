@@ -0,0 +1,44 @@
+-- +migrate Down
+-- +migrate StatementBegin
+
+ALTER TABLE `networks`
+DROP COLUMN is_dual_stack;
+
+ALTER TABLE `networks`
+DROP COLUMN ipv6_asn;
+
+ALTER TABLE `networks`
+DROP COLUMN ipv6;
+
+ALTER TABLE `networks`
+DROP COLUMN ipv4_asn;
+
+ALTER TABLE `networks`
+DROP COLUMN ipv4;
+
+-- +migrate StatementEnd
+
+-- +migrate Up
+-- +migrate StatementBegin
+
+-- The IPv4 and IPv6 egress addresses and their ASNs. Dual stack probes may
+-- use different networks for IPv4 and IPv6 (e.g., with NAT64 or CGNAT), so
+-- we record both. The address is empty and the ASN is zero when we did not
+-- discover an egress address for the given family.
+ALTER TABLE `networks`
+ADD COLUMN ipv4 VARCHAR(40) DEFAULT '' NOT NULL;
+
+ALTER TABLE `networks`
+ADD COLUMN ipv4_asn INT(4) DEFAULT 0 NOT NULL;
+
+ALTER TABLE `networks`
+ADD COLUMN ipv6 VARCHAR(40) DEFAULT '' NOT NULL;
+
+ALTER TABLE `networks`
+ADD COLUMN ipv6_asn INT(4) DEFAULT 0 NOT NULL;
+
+-- Whether we discovered both an IPv4 and an IPv6 egress address.
+ALTER TABLE `networks`
+ADD COLUMN is_dual_stack TINYINT(1) DEFAULT 0 NOT NULL;
+
+-- +migrate StatementEnd
@@ -0,0 +1,18 @@
+-- +migrate Down
+-- +migrate StatementBegin
+
+ALTER TABLE `measurements`
+DROP COLUMN measurement_is_network_changed;
+
+-- +migrate StatementEnd
+
+-- +migrate Up
+-- +migrate StatementBegin
+
+-- Whether the network changed while running this measurement or shortly
+-- thereafter, in which case the measurement may have been performed using
+-- a network different from the one its result row references.
+ALTER TABLE `measurements`
+ADD COLUMN measurement_is_network_changed TINYINT(1) DEFAULT 0 NOT NULL;
+
+-- +migrate StatementEnd
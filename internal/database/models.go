@@ -46,6 +46,16 @@ func (d *Database) Done(msmt *model.DatabaseMeasurement) error {
 	return nil
 }
 
+// NetworkChanged implements WritableDatabase.NetworkChanged
+func (d *Database) NetworkChanged(msmt *model.DatabaseMeasurement) error {
+	msmt.IsNetworkChanged = true
+	err := d.sess.Collection("measurements").Find("measurement_id", msmt.ID).Update(msmt)
+	if err != nil {
+		return errors.Wrap(err, "updating measurement")
+	}
+	return nil
+}
+
 // UploadFailed implements WritableDatabase.UploadFailed
 func (d *Database) UploadFailed(msmt *model.DatabaseMeasurement, failure string) error {
 	msmt.UploadFailureMsg = sql.NullString{String: failure, Valid: true}
@@ -7,13 +7,17 @@ package engine
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"runtime"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/ooni/probe-cli/v3/internal/bytecounter"
 	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/netxlite"
+	"github.com/ooni/probe-cli/v3/internal/packetcapture"
 	"github.com/ooni/probe-cli/v3/internal/probeservices"
 	"github.com/ooni/probe-cli/v3/internal/runtimex"
 	"github.com/ooni/probe-cli/v3/internal/version"
@@ -147,6 +151,24 @@ func (e *experiment) newMeasurement(target model.ExperimentTarget) *model.Measur
 	m.AddAnnotation("vcs_time", runtimex.BuildInfo.VcsTime)
 	m.AddAnnotation("vcs_tool", runtimex.BuildInfo.VcsTool)
 
+	// Record where the probe ASN and country code come from, such that
+	// we can tell apart the values provided by custom databases and overrides.
+	asnSource, ccSource := e.session.probeGeolocationSources()
+	if asnSource != "" {
+		m.AddAnnotation("probe_asn_source", asnSource)
+	}
+	if ccSource != "" {
+		m.AddAnnotation("probe_cc_source", ccSource)
+	}
+
+	// Record the dual stack situation, such that it's possible to interpret
+	// per-family failures. Note that we only record the ASNs and not the IPs.
+	e.maybeAddEgressAnnotations(m)
+
+	// Record the health of the tunnel, if any, such that it's possible
+	// to tell apart measurements collected using slow or cold tunnels.
+	e.maybeAddTunnelAnnotations(m)
+
 	return m
 }
 
@@ -211,6 +233,14 @@ func (e *experiment) MeasureWithContext(
 	ctx = bytecounter.WithSessionByteCounter(ctx, e.session.byteCounter)
 	ctx = bytecounter.WithExperimentByteCounter(ctx, e.byteCounter)
 
+	// Possibly capture the packets exchanged while measuring. The measurexlite
+	// dialers register their flows with the capture stored in the context.
+	capture := e.maybeStartPacketCapture()
+	ctx = packetcapture.WithCapture(ctx, capture)
+
+	// Possibly write the TLS and QUIC session secrets for debugging.
+	ctx = netxlite.ContextWithKeyLogWriter(ctx, e.session.keyLogWriter)
+
 	// Create a new measurement that the experiment measurer will finish filling
 	// by adding the test keys etc. Please, note that, as of 2024-06-06:
 	//
@@ -247,6 +277,9 @@ func (e *experiment) MeasureWithContext(
 	// Record when the experiment finished running.
 	stop := time.Now()
 
+	// Stop capturing and record which pcapng file belongs to the measurement.
+	e.maybeFinishPacketCapture(capture, measurement)
+
 	// Handle the case where there was a fundamental error.
 	if err != nil {
 		return nil, err
@@ -260,12 +293,13 @@ func (e *experiment) MeasureWithContext(
 	// going to submit the measurement in case we can't scrub it, so we just return an error
 	// if this specific corner case happens.
 	//
-	// TODO(bassosimone,DecFox): a dual stack client MAY be such that we discover its IPv4
-	// address but the IPv6 address is present inside the measurement. We should ensure that
-	// we improve our discovering capabilities to also cover this specific case.
-	if err := model.ScrubMeasurement(measurement, e.session.ProbeIP()); err != nil {
-		e.session.Logger().Warnf("can't scrub measurement: %s", err.Error())
-		return nil, err
+	// Because a dual stack client MAY discover its IPv4 address but use its IPv6 address
+	// while measuring (or vice versa), we also scrub the per-family egress addresses.
+	for _, ip := range e.probeIPsToScrub() {
+		if err := model.ScrubMeasurement(measurement, ip); err != nil {
+			e.session.Logger().Warnf("can't scrub measurement: %s", err.Error())
+			return nil, err
+		}
 	}
 
 	// We're all good! Let us return the measurement to the caller, which will
@@ -273,6 +307,51 @@ func (e *experiment) MeasureWithContext(
 	return measurement, nil
 }
 
+// probeIPsToScrub returns the probe IP addresses we should scrub from measurements.
+func (e *experiment) probeIPsToScrub() []string {
+	ips := []string{e.session.ProbeIP()}
+	for _, egress := range []*model.LocationEgress{e.session.ProbeIPv4Egress(), e.session.ProbeIPv6Egress()} {
+		if egress != nil && egress.ProbeIP != ips[0] {
+			ips = append(ips, egress.ProbeIP)
+		}
+	}
+	return ips
+}
+
+// maybeAddEgressAnnotations annotates the measurement with the per-family egress ASNs
+// and with whether the probe is dual stack, provided that we looked up the location.
+func (e *experiment) maybeAddEgressAnnotations(m *model.Measurement) {
+	v4, v6 := e.session.ProbeIPv4Egress(), e.session.ProbeIPv6Egress()
+	if v4 == nil && v6 == nil {
+		return
+	}
+	m.AddAnnotation("probe_dual_stack", strconv.FormatBool(v4 != nil && v6 != nil))
+	if v4 != nil {
+		m.AddAnnotation("probe_ipv4_asn", fmt.Sprintf("AS%d", v4.ASN))
+	}
+	if v6 != nil {
+		m.AddAnnotation("probe_ipv6_asn", fmt.Sprintf("AS%d", v6.ASN))
+	}
+}
+
+// maybeAddTunnelAnnotations adds annotations describing the tunnel health.
+func (e *experiment) maybeAddTunnelAnnotations(m *model.Measurement) {
+	health := e.session.TunnelHealth()
+	if health == nil {
+		return
+	}
+	m.AddAnnotation("tunnel_name", health.Name)
+	m.AddAnnotation("tunnel_bootstrap_time",
+		strconv.FormatFloat(health.BootstrapTime.Seconds(), 'f', -1, 64))
+	m.AddAnnotation("tunnel_warm_start", strconv.FormatBool(health.WarmStart))
+	if health.Circuits > 0 {
+		m.AddAnnotation("tunnel_circuits", strconv.FormatInt(health.Circuits, 10))
+	}
+	if health.EgressAddress != "" {
+		m.AddAnnotation("tunnel_egress_address", health.EgressAddress)
+	}
+}
+
 func (e *experiment) newReportTemplate() model.OOAPIReportTemplate {
 	return model.OOAPIReportTemplate{
 		DataFormatVersion: model.OOAPIReportDefaultDataFormatVersion,
@@ -1,6 +1,9 @@
 package engine
 
 import (
+	"bytes"
+	"context"
+	"io"
 	"sync"
 	"testing"
 	"time"
@@ -11,7 +14,11 @@ import (
 	"github.com/ooni/probe-cli/v3/internal/experiment/dnscheck"
 	"github.com/ooni/probe-cli/v3/internal/experiment/example"
 	"github.com/ooni/probe-cli/v3/internal/experiment/signal"
+	"github.com/ooni/probe-cli/v3/internal/mocks"
 	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/netxlite"
+	"github.com/ooni/probe-cli/v3/internal/tunnel"
+	tunnelmocks "github.com/ooni/probe-cli/v3/internal/tunnel/mocks"
 )
 
 func TestExperimentHonoursSharingDefaults(t *testing.T) {
@@ -191,3 +198,197 @@ func TestExperimentNewMeasurement(t *testing.T) {
 	// TODO(bassosimone,DecFox): this is the correct place where to
 	// add more tests regarding how we create measurements.
 }
+
+// This test ensures that (*experiment).MeasureWithContext passes the session's
+// key log writer to the experiment measurer using the context.
+func TestExperimentMeasureWithContextKeyLogWriter(t *testing.T) {
+	keyLogWriter := &bytes.Buffer{}
+	sess := &Session{
+		byteCounter:  bytecounter.New(),
+		keyLogWriter: keyLogWriter,
+		location:     &enginelocate.Results{ProbeIP: model.DefaultProbeIP},
+		logger:       model.DiscardLogger,
+	}
+	var gotKeyLogWriter io.Writer
+	exp := &experiment{
+		byteCounter: bytecounter.New(),
+		callbacks:   model.NewPrinterCallbacks(model.DiscardLogger),
+		measurer: &mocks.ExperimentMeasurer{
+			MockRun: func(ctx context.Context, args *model.ExperimentArgs) error {
+				gotKeyLogWriter = netxlite.ContextKeyLogWriter(ctx)
+				return nil
+			},
+		},
+		mrep: &experimentMutableReport{
+			mu:     sync.Mutex{},
+			report: nil,
+		},
+		session:  sess,
+		testName: "example",
+	}
+	target := model.NewOOAPIURLInfoWithDefaultCategoryAndCountry("")
+	if _, err := exp.MeasureWithContext(context.Background(), target); err != nil {
+		t.Fatal(err)
+	}
+	if gotKeyLogWriter != keyLogWriter {
+		t.Fatal("the measurer did not receive the key log writer")
+	}
+}
+
+// This test ensures that (*experiment).newMeasurement records the geolocation sources.
+func TestExperimentNewMeasurementGeolocationSources(t *testing.T) {
+	newMeasurement := func(location *enginelocate.Results) *model.Measurement {
+		sess := &Session{location: location}
+		builder, err := sess.NewExperimentBuilder("example")
+		if err != nil {
+			t.Fatal(err)
+		}
+		exp := builder.NewExperiment().(*experiment)
+		return exp.newMeasurement(model.NewOOAPIURLInfoWithDefaultCategoryAndCountry(""))
+	}
+
+	t.Run("without location", func(t *testing.T) {
+		meas := newMeasurement(nil)
+		if _, found := meas.Annotations["probe_asn_source"]; found {
+			t.Fatal("did not expect probe_asn_source")
+		}
+		if _, found := meas.Annotations["probe_cc_source"]; found {
+			t.Fatal("did not expect probe_cc_source")
+		}
+	})
+
+	t.Run("with location", func(t *testing.T) {
+		meas := newMeasurement(&enginelocate.Results{
+			ProbeASNSource: "custom:dbip.mmdb",
+			ProbeCCSource:  enginelocate.SourceOverride,
+		})
+		if meas.Annotations["probe_asn_source"] != "custom:dbip.mmdb" {
+			t.Fatal("unexpected probe_asn_source")
+		}
+		if meas.Annotations["probe_cc_source"] != enginelocate.SourceOverride {
+			t.Fatal("unexpected probe_cc_source")
+		}
+	})
+}
+
+// This test ensures that we correctly handle the dual stack situation.
+func TestExperimentDualStack(t *testing.T) {
+	newExperiment := func(location *enginelocate.Results) *experiment {
+		sess := &Session{location: location}
+		builder, err := sess.NewExperimentBuilder("example")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return builder.NewExperiment().(*experiment)
+	}
+	v4 := &model.LocationEgress{ASN: 30722, ProbeIP: "130.25.90.1"}
+	v6 := &model.LocationEgress{ASN: 12874, ProbeIP: "2a01:e11::1"}
+
+	t.Run("without location", func(t *testing.T) {
+		exp := newExperiment(nil)
+		meas := exp.newMeasurement(model.NewOOAPIURLInfoWithDefaultCategoryAndCountry(""))
+		if _, found := meas.Annotations["probe_dual_stack"]; found {
+			t.Fatal("did not expect probe_dual_stack")
+		}
+		if diff := cmp.Diff([]string{model.DefaultProbeIP}, exp.probeIPsToScrub()); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("with single stack location", func(t *testing.T) {
+		exp := newExperiment(&enginelocate.Results{ProbeIP: v4.ProbeIP, ProbeIPv4Egress: v4})
+		meas := exp.newMeasurement(model.NewOOAPIURLInfoWithDefaultCategoryAndCountry(""))
+		expect := map[string]string{"probe_dual_stack": "false", "probe_ipv4_asn": "AS30722"}
+		for key, value := range expect {
+			if meas.Annotations[key] != value {
+				t.Fatal("unexpected annotation", key, meas.Annotations[key])
+			}
+		}
+		if _, found := meas.Annotations["probe_ipv6_asn"]; found {
+			t.Fatal("did not expect probe_ipv6_asn")
+		}
+		if diff := cmp.Diff([]string{v4.ProbeIP}, exp.probeIPsToScrub()); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("with dual stack location", func(t *testing.T) {
+		exp := newExperiment(&enginelocate.Results{ProbeIP: v6.ProbeIP, ProbeIPv4Egress: v4, ProbeIPv6Egress: v6})
+		meas := exp.newMeasurement(model.NewOOAPIURLInfoWithDefaultCategoryAndCountry(""))
+		expect := map[string]string{
+			"probe_dual_stack": "true",
+			"probe_ipv4_asn":   "AS30722",
+			"probe_ipv6_asn":   "AS12874",
+		}
+		for key, value := range expect {
+			if meas.Annotations[key] != value {
+				t.Fatal("unexpected annotation", key, meas.Annotations[key])
+			}
+		}
+		if diff := cmp.Diff([]string{v6.ProbeIP, v4.ProbeIP}, exp.probeIPsToScrub()); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+}
+
+// This test ensures that we annotate measurements with the tunnel health.
+func TestExperimentTunnelAnnotations(t *testing.T) {
+	newMeasurement := func(tun tunnel.Tunnel) *model.Measurement {
+		sess := &Session{tunnel: tun}
+		builder, err := sess.NewExperimentBuilder("example")
+		if err != nil {
+			t.Fatal(err)
+		}
+		exp := builder.NewExperiment().(*experiment)
+		return exp.newMeasurement(model.NewOOAPIURLInfoWithDefaultCategoryAndCountry(""))
+	}
+
+	t.Run("without tunnel", func(t *testing.T) {
+		meas := newMeasurement(nil)
+		if _, found := meas.Annotations["tunnel_name"]; found {
+			t.Fatal("did not expect tunnel_name")
+		}
+	})
+
+	t.Run("with tunnel", func(t *testing.T) {
+		meas := newMeasurement(&tunnelmocks.Tunnel{
+			MockHealth: func() tunnel.Health {
+				return tunnel.Health{
+					Name:          "tor",
+					BootstrapTime: 1500 * time.Millisecond,
+					WarmStart:     true,
+					Circuits:      3,
+					EgressAddress: "130.192.91.211",
+				}
+			},
+		})
+		expect := map[string]string{
+			"tunnel_name":           "tor",
+			"tunnel_bootstrap_time": "1.5",
+			"tunnel_warm_start":     "true",
+			"tunnel_circuits":       "3",
+			"tunnel_egress_address": "130.192.91.211",
+		}
+		for key, value := range expect {
+			if meas.Annotations[key] != value {
+				t.Fatal("unexpected annotation", key, meas.Annotations[key])
+			}
+		}
+	})
+
+	t.Run("with tunnel without circuits info", func(t *testing.T) {
+		meas := newMeasurement(&tunnelmocks.Tunnel{
+			MockHealth: func() tunnel.Health {
+				return tunnel.Health{Name: "psiphon", BootstrapTime: time.Second}
+			},
+		})
+		if meas.Annotations["tunnel_warm_start"] != "false" {
+			t.Fatal("unexpected tunnel_warm_start", meas.Annotations["tunnel_warm_start"])
+		}
+		for _, key := range []string{"tunnel_circuits", "tunnel_egress_address"} {
+			if _, found := meas.Annotations[key]; found {
+				t.Fatal("did not expect", key)
+			}
+		}
+	})
+}
@@ -0,0 +1,110 @@
+package engine
+
+//
+// Detecting network changes during long runs
+//
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultNetworkCheckInterval is the default interval between two
+// consecutive checks performed by a [NetworkWatcher].
+const DefaultNetworkCheckInterval = 5 * time.Minute
+
+// NetworkWatcher periodically re-checks the probe IP address to detect
+// whether the network changed during a long run (e.g., because the user
+// moved from a Wi-Fi network to a tethered phone). You MUST create
+// instances of this struct using [Session.NewNetworkWatcher].
+//
+// The watcher does not run in the background. The caller is expected to
+// call Due between inputs and, if it returns true, CheckContext.
+type NetworkWatcher struct {
+	// interval is the minimum interval between checks.
+	interval time.Duration
+
+	// last is the time of the last check.
+	last time.Time
+
+	// mu provides mutual exclusion.
+	mu sync.Mutex
+
+	// sess is the session we're using.
+	sess *Session
+
+	// timeNow is the function returning the current time.
+	timeNow func() time.Time
+}
+
+// NewNetworkWatcher creates a new [NetworkWatcher] for this session. A zero
+// or negative interval causes the watcher to use [DefaultNetworkCheckInterval].
+// The session MUST have already looked up its location.
+func (s *Session) NewNetworkWatcher(interval time.Duration) *NetworkWatcher {
+	if interval <= 0 {
+		interval = DefaultNetworkCheckInterval
+	}
+	return &NetworkWatcher{
+		interval: interval,
+		last:     time.Now(),
+		sess:     s,
+		timeNow:  time.Now,
+	}
+}
+
+// Due returns whether enough time has elapsed since the last check.
+func (nw *NetworkWatcher) Due() bool {
+	defer nw.mu.Unlock()
+	nw.mu.Lock()
+	return nw.timeNow().Sub(nw.last) >= nw.interval
+}
+
+// CheckContext looks up the current probe IP and compares it with the probe
+// IP addresses known to the session. If the probe IP changed, this function
+// geolocates the probe again and updates the session location. We only return
+// true when the probe ASN or country code changed, since a new IP address within
+// the same network (e.g., a new DHCP lease) does not change the network we are
+// measuring. When geolocating again fails, we keep using the old location and
+// return the error, such that the caller can retry at the next check.
+func (nw *NetworkWatcher) CheckContext(ctx context.Context) (bool, error) {
+	nw.mu.Lock()
+	nw.last = nw.timeNow()
+	nw.mu.Unlock()
+	probeIP, err := nw.sess.lookupProbeIPContext(ctx)
+	if err != nil {
+		return false, err
+	}
+	if nw.isKnownProbeIP(probeIP) {
+		return false, nil
+	}
+	nw.sess.Logger().Infof("network: probe IP changed, geolocating again")
+	location, err := nw.sess.lookupLocationContext(ctx)
+	if err != nil {
+		return false, err
+	}
+	nw.sess.mu.Lock()
+	old := nw.sess.location
+	nw.sess.location = location
+	nw.sess.mu.Unlock()
+	changed := old == nil || old.ASN != location.ASN || old.CountryCode != location.CountryCode
+	if !changed {
+		nw.sess.Logger().Infof("network: probe IP changed but ASN and country code did not")
+	}
+	return changed, nil
+}
+
+// isKnownProbeIP returns whether the given IP address is the probe IP or
+// one of the per-address-family egress IPs known to the session.
+func (nw *NetworkWatcher) isKnownProbeIP(probeIP string) bool {
+	if probeIP == nw.sess.ProbeIP() {
+		return true
+	}
+	if egress := nw.sess.ProbeIPv4Egress(); egress != nil && egress.ProbeIP == probeIP {
+		return true
+	}
+	if egress := nw.sess.ProbeIPv6Egress(); egress != nil && egress.ProbeIP == probeIP {
+		return true
+	}
+	return false
+}
@@ -0,0 +1,166 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ooni/probe-cli/v3/internal/enginelocate"
+	"github.com/ooni/probe-cli/v3/internal/model"
+)
+
+func newSessionForNetworkWatcher() *Session {
+	return &Session{
+		location: &enginelocate.Results{
+			ASN:         30722,
+			CountryCode: "IT",
+			ProbeIP:     "130.192.91.211",
+			ProbeIPv6Egress: &model.LocationEgress{
+				ASN:     30722,
+				ProbeIP: "2001:db8::1",
+			},
+		},
+		logger: model.DiscardLogger,
+	}
+}
+
+func TestNetworkWatcher(t *testing.T) {
+	t.Run("NewNetworkWatcher uses the default interval", func(t *testing.T) {
+		nw := newSessionForNetworkWatcher().NewNetworkWatcher(0)
+		if nw.interval != DefaultNetworkCheckInterval {
+			t.Fatal("unexpected interval", nw.interval)
+		}
+	})
+
+	t.Run("Due", func(t *testing.T) {
+		nw := newSessionForNetworkWatcher().NewNetworkWatcher(time.Minute)
+		now := nw.last
+		nw.timeNow = func() time.Time {
+			return now.Add(30 * time.Second)
+		}
+		if nw.Due() {
+			t.Fatal("expected not to be due")
+		}
+		nw.timeNow = func() time.Time {
+			return now.Add(time.Minute)
+		}
+		if !nw.Due() {
+			t.Fatal("expected to be due")
+		}
+	})
+
+	t.Run("CheckContext when the probe IP lookup fails", func(t *testing.T) {
+		expected := errors.New("mocked error")
+		sess := newSessionForNetworkWatcher()
+		sess.testLookupProbeIPContext = func(ctx context.Context) (string, error) {
+			return "", expected
+		}
+		nw := sess.NewNetworkWatcher(time.Minute)
+		now := nw.last.Add(time.Hour)
+		nw.timeNow = func() time.Time {
+			return now
+		}
+		changed, err := nw.CheckContext(context.Background())
+		if !errors.Is(err, expected) {
+			t.Fatal("unexpected error", err)
+		}
+		if changed {
+			t.Fatal("expected no change")
+		}
+		if nw.Due() {
+			t.Fatal("expected the check to reset the timer")
+		}
+	})
+
+	t.Run("CheckContext with known probe IPs", func(t *testing.T) {
+		for _, ip := range []string{"130.192.91.211", "2001:db8::1"} {
+			sess := newSessionForNetworkWatcher()
+			sess.testLookupProbeIPContext = func(ctx context.Context) (string, error) {
+				return ip, nil
+			}
+			sess.testLookupLocationContext = func(ctx context.Context) (*enginelocate.Results, error) {
+				panic("should not be called")
+			}
+			changed, err := sess.NewNetworkWatcher(time.Minute).CheckContext(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if changed {
+				t.Fatal("expected no change for", ip)
+			}
+		}
+	})
+
+	t.Run("CheckContext when the network changed", func(t *testing.T) {
+		sess := newSessionForNetworkWatcher()
+		sess.testLookupProbeIPContext = func(ctx context.Context) (string, error) {
+			return "93.147.252.33", nil
+		}
+		sess.testLookupLocationContext = func(ctx context.Context) (*enginelocate.Results, error) {
+			return &enginelocate.Results{
+				ASN:         12874,
+				CountryCode: "IT",
+				ProbeIP:     "93.147.252.33",
+			}, nil
+		}
+		changed, err := sess.NewNetworkWatcher(time.Minute).CheckContext(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !changed {
+			t.Fatal("expected a change")
+		}
+		if sess.ProbeASN() != 12874 || sess.ProbeIP() != "93.147.252.33" {
+			t.Fatal("did not update the location")
+		}
+		if sess.ProbeIPv6Egress() != nil {
+			t.Fatal("expected the old egress to be gone")
+		}
+	})
+
+	t.Run("CheckContext when the probe IP changed within the same network", func(t *testing.T) {
+		sess := newSessionForNetworkWatcher()
+		sess.testLookupProbeIPContext = func(ctx context.Context) (string, error) {
+			return "130.192.91.212", nil
+		}
+		sess.testLookupLocationContext = func(ctx context.Context) (*enginelocate.Results, error) {
+			return &enginelocate.Results{
+				ASN:         30722,
+				CountryCode: "IT",
+				ProbeIP:     "130.192.91.212",
+			}, nil
+		}
+		changed, err := sess.NewNetworkWatcher(time.Minute).CheckContext(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if changed {
+			t.Fatal("expected no change")
+		}
+		if sess.ProbeIP() != "130.192.91.212" {
+			t.Fatal("did not update the location")
+		}
+	})
+
+	t.Run("CheckContext when geolocating again fails", func(t *testing.T) {
+		expected := errors.New("mocked error")
+		sess := newSessionForNetworkWatcher()
+		sess.testLookupProbeIPContext = func(ctx context.Context) (string, error) {
+			return "93.147.252.33", nil
+		}
+		sess.testLookupLocationContext = func(ctx context.Context) (*enginelocate.Results, error) {
+			return nil, expected
+		}
+		changed, err := sess.NewNetworkWatcher(time.Minute).CheckContext(context.Background())
+		if !errors.Is(err, expected) {
+			t.Fatal("unexpected error", err)
+		}
+		if changed {
+			t.Fatal("expected no change")
+		}
+		if sess.ProbeASN() != 30722 {
+			t.Fatal("should have kept the old location")
+		}
+	})
+}
@@ -0,0 +1,70 @@
+package engine
+
+//
+// Optional per-measurement packet capture
+//
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/packetcapture"
+)
+
+// maybeStartPacketCapture starts capturing packets into a new pcapng file inside
+// the session's packet capture directory, if configured. This function returns
+// nil when we are not capturing, which is fine because the methods of a nil
+// [*packetcapture.Capture] are no-ops. We do not fail the measurement when we
+// cannot capture (e.g., because we lack CAP_NET_RAW); we just emit a warning.
+func (e *experiment) maybeStartPacketCapture() *packetcapture.Capture {
+	dir := e.session.packetCaptureDir
+	if dir == "" {
+		return nil
+	}
+	logger := e.session.Logger()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		logger.Warnf("packetcapture: cannot create directory: %s", err.Error())
+		return nil
+	}
+	filep, err := os.CreateTemp(dir, e.testName+"-*.pcapng")
+	if err != nil {
+		logger.Warnf("packetcapture: cannot create file: %s", err.Error())
+		return nil
+	}
+	filename := filep.Name()
+	filep.Close()
+	capture, err := packetcapture.Start(&packetcapture.Config{
+		Filename: filename,
+		Logger:   logger,
+		SnapLen:  0,
+	})
+	if err != nil {
+		logger.Warnf("packetcapture: cannot capture packets: %s", err.Error())
+		os.Remove(filename)
+		return nil
+	}
+	logger.Infof("packetcapture: writing packets into %s", filename)
+	return capture
+}
+
+// maybeFinishPacketCapture stops the possibly-nil capture and adds the pcap_file and
+// pcap_sha256 annotations to the measurement. We only include the base name of the
+// pcapng file, to avoid leaking the local directory structure.
+func (e *experiment) maybeFinishPacketCapture(capture *packetcapture.Capture, measurement *model.Measurement) {
+	if capture == nil {
+		return
+	}
+	logger := e.session.Logger()
+	if err := capture.Close(); err != nil {
+		logger.Warnf("packetcapture: cannot close capture: %s", err.Error())
+		return
+	}
+	digest, err := packetcapture.FileSHA256(capture.Filename())
+	if err != nil {
+		logger.Warnf("packetcapture: cannot hash capture: %s", err.Error())
+		return
+	}
+	measurement.AddAnnotation("pcap_file", filepath.Base(capture.Filename()))
+	measurement.AddAnnotation("pcap_sha256", digest)
+}
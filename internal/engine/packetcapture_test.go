@@ -0,0 +1,81 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/packetcapture"
+)
+
+func TestExperimentPacketCapture(t *testing.T) {
+	newExperimentForTesting := func(dir string) *experiment {
+		return &experiment{
+			session: &Session{
+				logger:           model.DiscardLogger,
+				packetCaptureDir: dir,
+			},
+			testName: "example",
+		}
+	}
+
+	t.Run("we do not capture by default", func(t *testing.T) {
+		e := newExperimentForTesting("")
+		if capture := e.maybeStartPacketCapture(); capture != nil {
+			t.Fatal("expected nil capture")
+		}
+		measurement := &model.Measurement{}
+		e.maybeFinishPacketCapture(nil, measurement)
+		if len(measurement.Annotations) != 0 {
+			t.Fatal("expected no annotations")
+		}
+	})
+
+	t.Run("we capture or we do not leave files behind", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "pcap")
+		e := newExperimentForTesting(dir)
+		capture := e.maybeStartPacketCapture()
+		measurement := &model.Measurement{}
+		e.maybeFinishPacketCapture(capture, measurement)
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if capture == nil {
+			// we are either not on Linux or we lack CAP_NET_RAW
+			if len(entries) != 0 {
+				t.Fatal("expected no files")
+			}
+			return
+		}
+		if len(entries) != 1 || measurement.Annotations["pcap_file"] != entries[0].Name() {
+			t.Fatal("unexpected pcap_file annotation")
+		}
+	})
+
+	t.Run("we annotate the measurement with the file name and hash", func(t *testing.T) {
+		dir := t.TempDir()
+		e := newExperimentForTesting(dir)
+		filename := filepath.Join(dir, "example-1234.pcapng")
+		capture, err := packetcapture.New(&packetcapture.Config{
+			Filename: filename,
+			Logger:   model.DiscardLogger,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		measurement := &model.Measurement{}
+		e.maybeFinishPacketCapture(capture, measurement)
+		digest, err := packetcapture.FileSHA256(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if measurement.Annotations["pcap_file"] != "example-1234.pcapng" {
+			t.Fatal("unexpected pcap_file annotation")
+		}
+		if measurement.Annotations["pcap_sha256"] != digest {
+			t.Fatal("unexpected pcap_sha256 annotation")
+		}
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net/url"
 	"os"
 	"sync"
@@ -13,7 +14,9 @@ import (
 	"github.com/ooni/probe-cli/v3/internal/enginelocate"
 	"github.com/ooni/probe-cli/v3/internal/enginenetx"
 	"github.com/ooni/probe-cli/v3/internal/engineresolver"
+	"github.com/ooni/probe-cli/v3/internal/geoipx"
 	"github.com/ooni/probe-cli/v3/internal/kvstore"
+	"github.com/ooni/probe-cli/v3/internal/measurementsig"
 	"github.com/ooni/probe-cli/v3/internal/model"
 	"github.com/ooni/probe-cli/v3/internal/platform"
 	"github.com/ooni/probe-cli/v3/internal/probeservices"
@@ -34,10 +37,38 @@ type SessionConfig struct {
 	TorArgs                []string
 	TorBinary              string
 
+	// GeolocationDatabase is the OPTIONAL path of a MaxMind-compatible
+	// MMDB file to use instead of the embedded geolocation database.
+	GeolocationDatabase string
+
+	// GeolocationOverride OPTIONALLY overrides the probe ASN and country
+	// code, for probes behind networks unknown to the database.
+	GeolocationOverride enginelocate.Override
+
+	// KeyLogWriter is the OPTIONAL writer where the TLS and QUIC
+	// handshakes performed by experiments write session secrets using
+	// the SSLKEYLOGFILE format. Because these secrets allow decrypting
+	// the traffic, you should only set this field when debugging.
+	KeyLogWriter io.Writer
+
+	// PacketCaptureDir is the OPTIONAL directory where to write
+	// a pcapng file for each measurement. Capturing packets is
+	// disabled when this field is empty and currently requires
+	// Linux and the CAP_NET_RAW capability.
+	PacketCaptureDir string
+
+	// SignMeasurements OPTIONALLY enables signing the measurements we
+	// submit using a per-installation key stored into the KVStore.
+	SignMeasurements bool
+
 	// SnowflakeRendezvous is the rendezvous method
 	// to be used by the torsf tunnel
 	SnowflakeRendezvous string
 
+	// TorColdStart OPTIONALLY forces the tor tunnel to bootstrap without
+	// reusing the directory information cached by previous sessions.
+	TorColdStart bool
+
 	// TunnelDir is the directory where we should store
 	// the state of persistent tunnels. This field is
 	// optional _unless_ you want to use tunnels. In such
@@ -54,15 +85,20 @@ type Session struct {
 	availableProbeServices   []model.OOAPIService
 	availableTestHelpers     map[string][]model.OOAPIService
 	byteCounter              *bytecounter.Counter
+	geolocationDatabase      *geoipx.Database
+	geolocationOverride      enginelocate.Override
 	network                  *enginenetx.Network
+	keyLogWriter             io.Writer
 	kvStore                  model.KeyValueStore
 	location                 *enginelocate.Results
 	logger                   model.Logger
+	packetCaptureDir         string
 	proxyURL                 *url.URL
 	queryProbeServicesCount  *atomic.Int64
 	resolver                 *engineresolver.Resolver
 	selectedProbeServiceHook func(*model.OOAPIService)
 	selectedProbeService     *model.OOAPIService
+	signer                   *measurementsig.Signer
 	softwareName             string
 	softwareVersion          string
 	tempDir                  string
@@ -77,6 +113,10 @@ type Session struct {
 	// allowing us to mock LookupLocationContext.
 	testLookupLocationContext func(ctx context.Context) (*enginelocate.Results, error)
 
+	// testLookupProbeIPContext is an optional hook for testing
+	// allowing us to mock lookupProbeIPContext.
+	testLookupProbeIPContext func(ctx context.Context) (string, error)
+
 	// testMaybeLookupBackendsContext is an optional hook for testing
 	// allowing us to mock MaybeLookupBackendsContext.
 	testMaybeLookupBackendsContext func(ctx context.Context) error
@@ -120,7 +160,8 @@ type sessionProbeServicesClientForCheckIn interface {
 //
 // 2. Create a temporary directory.
 //
-// 3. Create an instance of the session.
+// 3. Create an instance of the session, opening the custom
+// geolocation database, if configured.
 //
 // 4. If the user requested for a proxy that entails a tunnel (at the
 // moment of writing this note, either psiphon or tor), then start the
@@ -150,6 +191,14 @@ func NewSession(ctx context.Context, config SessionConfig) (*Session, error) {
 	if config.KVStore == nil {
 		config.KVStore = &kvstore.Memory{}
 	}
+	var signer *measurementsig.Signer
+	if config.SignMeasurements {
+		var err error
+		signer, err = measurementsig.NewSigner(config.KVStore, config.Logger)
+		if err != nil {
+			return nil, err
+		}
+	}
 	// Implementation note: if config.TempDir is empty, then Go will
 	// use the temporary directory on the current system. This should
 	// work on Desktop. We tested that it did also work on iOS, but
@@ -168,9 +217,13 @@ func NewSession(ctx context.Context, config SessionConfig) (*Session, error) {
 	sess := &Session{
 		availableProbeServices:  config.AvailableProbeServices,
 		byteCounter:             bytecounter.New(),
+		geolocationOverride:     config.GeolocationOverride,
+		keyLogWriter:            config.KeyLogWriter,
 		kvStore:                 config.KVStore,
 		logger:                  config.Logger,
+		packetCaptureDir:        config.PacketCaptureDir,
 		queryProbeServicesCount: &atomic.Int64{},
+		signer:                  signer,
 		softwareName:            config.SoftwareName,
 		softwareVersion:         config.SoftwareVersion,
 		tempDir:                 tempDir,
@@ -178,6 +231,15 @@ func NewSession(ctx context.Context, config SessionConfig) (*Session, error) {
 		torBinary:               config.TorBinary,
 		tunnelDir:               config.TunnelDir,
 	}
+	if config.GeolocationDatabase != "" {
+		db, err := geoipx.Open(config.GeolocationDatabase)
+		if err != nil {
+			_ = os.RemoveAll(tempDir)
+			return nil, err
+		}
+		config.Logger.Infof("using geolocation database: %s", db.Source())
+		sess.geolocationDatabase = db
+	}
 	proxyURL := config.ProxyURL
 	if proxyURL != nil {
 		switch proxyURL.Scheme {
@@ -191,12 +253,20 @@ func NewSession(ctx context.Context, config SessionConfig) (*Session, error) {
 				Session:             &sessionTunnelEarlySession{},
 				TorArgs:             config.TorArgs,
 				TorBinary:           config.TorBinary,
+				TorColdStart:        config.TorColdStart,
 				TunnelDir:           config.TunnelDir,
+				OnBootstrapEvent: func(ev tunnel.BootstrapEvent) {
+					config.Logger.Infof("tunnel '%s' bootstrap: %d%% (%s)",
+						proxyURL.Scheme, ev.Progress, ev.Summary)
+				},
 			})
 			if err != nil {
+				sess.maybeCloseGeolocationDatabase()
 				return nil, err
 			}
-			config.Logger.Infof("tunnel '%s' running...", proxyURL.Scheme)
+			health := tunnel.Health()
+			config.Logger.Infof("tunnel '%s' running (bootstrap time: %s, warm start: %v)...",
+				proxyURL.Scheme, health.BootstrapTime, health.WarmStart)
 			sess.tunnel = tunnel
 			proxyURL = tunnel.SOCKS5ProxyURL()
 		case "none":
@@ -220,6 +290,16 @@ func NewSession(ctx context.Context, config SessionConfig) (*Session, error) {
 	return sess, nil
 }
 
+// TunnelHealth returns the health of the tunnel we're using or
+// nil when this session is not using any tunnel.
+func (s *Session) TunnelHealth() *tunnel.Health {
+	if s.tunnel == nil {
+		return nil
+	}
+	health := s.tunnel.Health()
+	return &health
+}
+
 // TunnelDir returns the persistent directory used by tunnels.
 func (s *Session) TunnelDir() string {
 	return s.tunnelDir
@@ -344,9 +424,17 @@ func (s *Session) doClose() {
 	if s.tunnel != nil {
 		s.tunnel.Stop()
 	}
+	s.maybeCloseGeolocationDatabase()
 	_ = os.RemoveAll(s.tempDir)
 }
 
+// maybeCloseGeolocationDatabase closes the custom geolocation database, if any.
+func (s *Session) maybeCloseGeolocationDatabase() {
+	if s.geolocationDatabase != nil {
+		_ = s.geolocationDatabase.Close()
+	}
+}
+
 // GetTestHelpersByName returns the available test helpers that
 // use the specified name, or false if there's none.
 func (s *Session) GetTestHelpersByName(name string) ([]model.OOAPIService, bool) {
@@ -451,7 +539,14 @@ func (s *Session) newProbeServicesClient(ctx context.Context) (*probeservices.Cl
 	if s.selectedProbeServiceHook != nil {
 		s.selectedProbeServiceHook(s.selectedProbeService)
 	}
-	return probeservices.NewClient(s, *s.selectedProbeService)
+	client, err := probeservices.NewClient(s, *s.selectedProbeService)
+	if err != nil {
+		return nil, err
+	}
+	if s.signer != nil {
+		client.Signer = s.signer
+	}
+	return client, nil
 }
 
 // NewSubmitter creates a new submitter instance.
@@ -516,6 +611,17 @@ func (s *Session) ProbeCC() string {
 	return cc
 }
 
+// probeGeolocationSources returns where the probe ASN and the probe country
+// code come from, or empty strings if we have not looked up the location.
+func (s *Session) probeGeolocationSources() (asnSource, ccSource string) {
+	defer s.mu.Unlock()
+	s.mu.Lock()
+	if s.location != nil {
+		asnSource, ccSource = s.location.ProbeASNSource, s.location.ProbeCCSource
+	}
+	return
+}
+
 // ProbeNetworkName returns the probe network name.
 func (s *Session) ProbeNetworkName() string {
 	defer s.mu.Unlock()
@@ -538,6 +644,28 @@ func (s *Session) ProbeIP() string {
 	return ip
 }
 
+// ProbeIPv4Egress returns the possibly-nil IPv4 egress.
+func (s *Session) ProbeIPv4Egress() *model.LocationEgress {
+	defer s.mu.Unlock()
+	s.mu.Lock()
+	var egress *model.LocationEgress
+	if s.location != nil {
+		egress = s.location.ProbeIPv4Egress
+	}
+	return egress
+}
+
+// ProbeIPv6Egress returns the possibly-nil IPv6 egress.
+func (s *Session) ProbeIPv6Egress() *model.LocationEgress {
+	defer s.mu.Unlock()
+	s.mu.Lock()
+	var egress *model.LocationEgress
+	if s.location != nil {
+		egress = s.location.ProbeIPv6Egress
+	}
+	return egress
+}
+
 // ProxyURL returns the Proxy URL, or nil if not set
 func (s *Session) ProxyURL() *url.URL {
 	return s.proxyURL
@@ -684,15 +812,33 @@ func (s *Session) MaybeLookupBackendsContext(ctx context.Context) error {
 	return nil
 }
 
-// doLookupLocationContext performs a location lookup. If you want memoisation
-// of the results, you should use MaybeLookupLocationContext.
-func (s *Session) doLookupLocationContext(ctx context.Context) (*enginelocate.Results, error) {
-	task := enginelocate.NewTask(enginelocate.Config{
+// newLocationTask creates a new [enginelocate.Task] using the session config.
+func (s *Session) newLocationTask() *enginelocate.Task {
+	config := enginelocate.Config{
 		Logger:    s.Logger(),
 		Resolver:  s.resolver,
 		UserAgent: s.UserAgent(),
-	})
-	return task.Run(ctx)
+		Override:  s.geolocationOverride,
+	}
+	if s.geolocationDatabase != nil {
+		config.Provider = s.geolocationDatabase
+	}
+	return enginelocate.NewTask(config)
+}
+
+// doLookupLocationContext performs a location lookup. If you want memoisation
+// of the results, you should use MaybeLookupLocationContext.
+func (s *Session) doLookupLocationContext(ctx context.Context) (*enginelocate.Results, error) {
+	return s.newLocationTask().Run(ctx)
+}
+
+// lookupProbeIPContext calls testLookupProbeIPContext if set and otherwise
+// uses enginelocate to only look up the current probe IP.
+func (s *Session) lookupProbeIPContext(ctx context.Context) (string, error) {
+	if s.testLookupProbeIPContext != nil {
+		return s.testLookupProbeIPContext(ctx)
+	}
+	return s.newLocationTask().LookupProbeIP(ctx)
 }
 
 // lookupLocationContext calls testLookupLocationContext if set and
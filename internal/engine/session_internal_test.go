@@ -4,17 +4,21 @@ import (
 	"context"
 	"errors"
 	"net/url"
+	"os"
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/apex/log"
 	"github.com/google/go-cmp/cmp"
+	"github.com/ooni/probe-assets/assets"
 	"github.com/ooni/probe-cli/v3/internal/checkincache"
 	"github.com/ooni/probe-cli/v3/internal/enginelocate"
 	"github.com/ooni/probe-cli/v3/internal/experiment/webconnectivity"
 	"github.com/ooni/probe-cli/v3/internal/experiment/webconnectivitylte"
 	"github.com/ooni/probe-cli/v3/internal/kvstore"
+	"github.com/ooni/probe-cli/v3/internal/mocks"
 	"github.com/ooni/probe-cli/v3/internal/model"
 	"github.com/ooni/probe-cli/v3/internal/registry"
 )
@@ -313,6 +317,9 @@ func TestNewSessionWithFakeTunnel(t *testing.T) {
 	if sess.tunnel == nil {
 		t.Fatal("expected non-nil tunnel here")
 	}
+	if health := sess.TunnelHealth(); health == nil || health.Name != "fake" {
+		t.Fatal("unexpected tunnel health", health)
+	}
 	sess.Close() // ensure we don't crash
 }
 
@@ -334,6 +341,48 @@ func TestNewSessionWithFakeTunnelAndCancelledContext(t *testing.T) {
 	}
 }
 
+func TestNewSessionWithGeolocationDatabase(t *testing.T) {
+	t.Run("with nonexistent database", func(t *testing.T) {
+		sess, err := NewSession(context.Background(), SessionConfig{
+			GeolocationDatabase: filepath.Join(t.TempDir(), "nonexistent.mmdb"),
+			Logger:              model.DiscardLogger,
+			SoftwareName:        "miniooni",
+			SoftwareVersion:     "0.1.0-dev",
+		})
+		if err == nil {
+			t.Fatal("expected an error here")
+		}
+		if sess != nil {
+			t.Fatal("expected nil session here")
+		}
+	})
+
+	t.Run("with existing database", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "custom.mmdb")
+		if err := os.WriteFile(filename, assets.OOMMDBDatabaseBytes, 0600); err != nil {
+			t.Fatal(err)
+		}
+		override := enginelocate.Override{ProbeCC: "IT"}
+		sess, err := NewSession(context.Background(), SessionConfig{
+			GeolocationDatabase: filename,
+			GeolocationOverride: override,
+			Logger:              model.DiscardLogger,
+			SoftwareName:        "miniooni",
+			SoftwareVersion:     "0.1.0-dev",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer sess.Close()
+		if sess.geolocationDatabase == nil || sess.geolocationDatabase.Source() != "custom:custom.mmdb" {
+			t.Fatal("did not open the geolocation database")
+		}
+		if sess.geolocationOverride != override {
+			t.Fatal("did not set the geolocation override")
+		}
+	})
+}
+
 func TestSessionNewExperimentBuilder(t *testing.T) {
 	t.Run("for a normal experiment", func(t *testing.T) {
 		sess := &Session{
@@ -418,3 +467,103 @@ func TestSessionNewExperimentBuilder(t *testing.T) {
 		}
 	})
 }
+
+func TestNewSessionWithMeasurementSigner(t *testing.T) {
+	t.Run("we reuse the key stored in the kvstore", func(t *testing.T) {
+		store := &kvstore.Memory{}
+		newSession := func() *Session {
+			sess, err := NewSession(context.Background(), SessionConfig{
+				KVStore:          store,
+				Logger:           model.DiscardLogger,
+				SignMeasurements: true,
+				SoftwareName:     "miniooni",
+				SoftwareVersion:  "0.1.0-dev",
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { sess.Close() })
+			return sess
+		}
+		first, second := newSession(), newSession()
+		if first.signer.Fingerprint() != second.signer.Fingerprint() {
+			t.Fatal("expected to reuse the same key")
+		}
+	})
+
+	t.Run("we fail when we cannot load the key", func(t *testing.T) {
+		expected := errors.New("mocked error")
+		sess, err := NewSession(context.Background(), SessionConfig{
+			KVStore: &mocks.KeyValueStore{
+				MockGet: func(key string) ([]byte, error) {
+					return nil, expected
+				},
+			},
+			Logger:           model.DiscardLogger,
+			SignMeasurements: true,
+			SoftwareName:     "miniooni",
+			SoftwareVersion:  "0.1.0-dev",
+		})
+		if !errors.Is(err, expected) {
+			t.Fatal("unexpected error", err)
+		}
+		if sess != nil {
+			t.Fatal("expected nil session here")
+		}
+	})
+
+	t.Run("probe services clients sign measurements", func(t *testing.T) {
+		sess, err := NewSession(context.Background(), SessionConfig{
+			Logger:           model.DiscardLogger,
+			SignMeasurements: true,
+			SoftwareName:     "miniooni",
+			SoftwareVersion:  "0.1.0-dev",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer sess.Close()
+		sess.selectedProbeService = &model.OOAPIService{Address: "https://x.org", Type: "https"}
+		sess.testMaybeLookupLocationContext = func(ctx context.Context) error {
+			return nil
+		}
+		client, err := sess.newProbeServicesClient(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if client.Signer != sess.signer {
+			t.Fatal("expected the client to use the session signer")
+		}
+	})
+
+	t.Run("we do not sign measurements unless asked to", func(t *testing.T) {
+		store := &kvstore.Memory{}
+		sess, err := NewSession(context.Background(), SessionConfig{
+			KVStore:         store,
+			Logger:          model.DiscardLogger,
+			SoftwareName:    "miniooni",
+			SoftwareVersion: "0.1.0-dev",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer sess.Close()
+		if sess.signer != nil {
+			t.Fatal("expected nil signer")
+		}
+		if _, err := store.Get("measurementsig.state"); !errors.Is(err, kvstore.ErrNoSuchKey) {
+			t.Fatal("expected not to generate a key", err)
+		}
+		sess.selectedProbeService = &model.OOAPIService{Address: "https://x.org", Type: "https"}
+		sess.testMaybeLookupLocationContext = func(ctx context.Context) error {
+			return nil
+		}
+		client, err := sess.newProbeServicesClient(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if client.Signer != nil {
+			t.Fatal("expected the client not to sign measurements")
+		}
+	})
+}
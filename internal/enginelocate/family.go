@@ -0,0 +1,59 @@
+package enginelocate
+
+//
+// Address-family-aware IP lookups
+//
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/ooni/probe-cli/v3/internal/model"
+)
+
+const (
+	// familyIPv4 restricts a lookup to IPv4.
+	familyIPv4 = "ipv4"
+
+	// familyIPv6 restricts a lookup to IPv6.
+	familyIPv6 = "ipv6"
+)
+
+// ErrNoAddressForFamily indicates that a domain does not have
+// any address belonging to the requested address family.
+var ErrNoAddressForFamily = errors.New("no address for the requested address family")
+
+// familyOf returns the address family of the given IP address.
+func familyOf(ip string) string {
+	if strings.Contains(ip, ":") {
+		return familyIPv6
+	}
+	return familyIPv4
+}
+
+// familyResolver is a [model.Resolver] only returning the addresses belonging to
+// the given address family, such that dialers using this resolver use the given family.
+type familyResolver struct {
+	model.Resolver
+	family string
+}
+
+// LookupHost implements model.Resolver.
+func (r *familyResolver) LookupHost(ctx context.Context, hostname string) ([]string, error) {
+	addrs, err := r.Resolver.LookupHost(ctx, hostname)
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, addr := range addrs {
+		if net.ParseIP(addr) != nil && familyOf(addr) == r.family {
+			out = append(out, addr)
+		}
+	}
+	if len(out) <= 0 {
+		return nil, ErrNoAddressForFamily
+	}
+	return out, nil
+}
@@ -0,0 +1,122 @@
+package enginelocate
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/ooni/probe-cli/v3/internal/mocks"
+	"github.com/ooni/probe-cli/v3/internal/model"
+)
+
+func TestFamilyOf(t *testing.T) {
+	if familyOf("8.8.8.8") != familyIPv4 {
+		t.Fatal("expected IPv4")
+	}
+	if familyOf("2001:4860:4860::8888") != familyIPv6 {
+		t.Fatal("expected IPv6")
+	}
+}
+
+func TestFamilyResolver(t *testing.T) {
+	newResolver := func(family string, addrs []string, err error) *familyResolver {
+		return &familyResolver{
+			Resolver: &mocks.Resolver{
+				MockLookupHost: func(ctx context.Context, domain string) ([]string, error) {
+					return addrs, err
+				},
+			},
+			family: family,
+		}
+	}
+	allAddrs := []string{"8.8.8.8", "2001:4860:4860::8888", "8.8.4.4", "2001:4860:4860::8844"}
+
+	t.Run("on lookup failure", func(t *testing.T) {
+		expected := errors.New("mocked error")
+		reso := newResolver(familyIPv4, nil, expected)
+		addrs, err := reso.LookupHost(context.Background(), "dns.google")
+		if !errors.Is(err, expected) {
+			t.Fatal("unexpected error", err)
+		}
+		if len(addrs) != 0 {
+			t.Fatal("expected no addrs")
+		}
+	})
+
+	t.Run("with IPv4", func(t *testing.T) {
+		reso := newResolver(familyIPv4, allAddrs, nil)
+		addrs, err := reso.LookupHost(context.Background(), "dns.google")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff([]string{"8.8.8.8", "8.8.4.4"}, addrs); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("with IPv6", func(t *testing.T) {
+		reso := newResolver(familyIPv6, allAddrs, nil)
+		addrs, err := reso.LookupHost(context.Background(), "dns.google")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff([]string{"2001:4860:4860::8888", "2001:4860:4860::8844"}, addrs); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("without addresses of the given family", func(t *testing.T) {
+		reso := newResolver(familyIPv6, []string{"8.8.8.8"}, nil)
+		addrs, err := reso.LookupHost(context.Background(), "dns.google")
+		if !errors.Is(err, ErrNoAddressForFamily) {
+			t.Fatal("unexpected error", err)
+		}
+		if len(addrs) != 0 {
+			t.Fatal("expected no addrs")
+		}
+	})
+}
+
+func TestIPLookupClientWithFamily(t *testing.T) {
+	newClient := func(family string) ipLookupClient {
+		return ipLookupClient{
+			Family: family,
+			Logger: model.DiscardLogger,
+			Resolver: &mocks.Resolver{
+				MockCloseIdleConnections: func() {},
+			},
+			UserAgent: "ooniprobe-engine/0.1.0",
+		}
+	}
+
+	t.Run("we reject addresses of the wrong family", func(t *testing.T) {
+		client := newClient(familyIPv6)
+		ip, err := client.doWithCustomFunc(context.Background(), fixedIPLookup("1.2.3.4"))
+		if !errors.Is(err, ErrInvalidIPAddress) {
+			t.Fatal("unexpected error", err)
+		}
+		if ip != "127.0.0.1" {
+			t.Fatal("unexpected IP", ip)
+		}
+	})
+
+	t.Run("we accept addresses of the right family", func(t *testing.T) {
+		client := newClient(familyIPv6)
+		ip, err := client.doWithCustomFunc(context.Background(), fixedIPLookup("2001:db8::1"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ip != "2001:db8::1" {
+			t.Fatal("unexpected IP", ip)
+		}
+	})
+}
+
+// fixedIPLookup returns a lookupFunc always returning the given IP.
+func fixedIPLookup(ip string) lookupFunc {
+	return func(ctx context.Context, httpClient model.HTTPClient,
+		logger model.Logger, userAgent string, resolver model.Resolver) (string, error) {
+		return ip, nil
+	}
+}
@@ -4,7 +4,9 @@ package enginelocate
 import (
 	"context"
 	"fmt"
+	"time"
 
+	"github.com/ooni/probe-cli/v3/internal/geoipx"
 	"github.com/ooni/probe-cli/v3/internal/model"
 	"github.com/ooni/probe-cli/v3/internal/netxlite"
 	"github.com/ooni/probe-cli/v3/internal/version"
@@ -24,9 +26,23 @@ type Results struct {
 	// NetworkName is the network name.
 	NetworkName string
 
+	// ProbeASNSource describes where the ASN and the network name come
+	// from: either the [Provider.Source] or [SourceOverride].
+	ProbeASNSource string
+
+	// ProbeCCSource describes where the country code comes from: either
+	// the [Provider.Source] or [SourceOverride].
+	ProbeCCSource string
+
 	// IP is the probe IP.
 	ProbeIP string
 
+	// ProbeIPv4Egress is the possibly-nil IPv4 egress.
+	ProbeIPv4Egress *model.LocationEgress
+
+	// ProbeIPv6Egress is the possibly-nil IPv6 egress.
+	ProbeIPv6Egress *model.LocationEgress
+
 	// ResolverASN is the resolver ASN.
 	ResolverASN uint
 
@@ -42,6 +58,11 @@ func (r *Results) ASNString() string {
 	return fmt.Sprintf("AS%d", r.ASN)
 }
 
+// IsDualStack returns whether we discovered both an IPv4 and an IPv6 egress.
+func (r *Results) IsDualStack() bool {
+	return r.ProbeIPv4Egress != nil && r.ProbeIPv6Egress != nil
+}
+
 type probeIPLookupper interface {
 	LookupProbeIP(ctx context.Context) (addr string, err error)
 }
@@ -58,6 +79,39 @@ type resolverIPLookupper interface {
 	LookupResolverIP(ctx context.Context) (addr string, err error)
 }
 
+// Provider is a geolocation provider mapping IP addresses to ASN, network
+// name, and country code. The [*geoipx.Database] type implements this interface.
+type Provider interface {
+	// LookupASN maps the IP address to an AS number and name.
+	LookupASN(ip string) (asn uint, network string, err error)
+
+	// LookupCC maps the IP address to a country code.
+	LookupCC(ip string) (cc string, err error)
+
+	// Source returns a string describing the provider.
+	Source() string
+}
+
+var _ Provider = &geoipx.Database{}
+
+// SourceOverride is the source we use when [Override] provides the value.
+const SourceOverride = "override"
+
+// Override contains values overriding the ones we would otherwise get from
+// the [Provider], which is useful for probes behind well-known networks whose
+// address blocks the [Provider] does not know about (yet).
+type Override struct {
+	// ProbeASN is the OPTIONAL ASN to use instead of looking it up.
+	ProbeASN uint
+
+	// ProbeCC is the OPTIONAL country code to use instead of looking it up.
+	ProbeCC string
+
+	// ProbeNetworkName is the OPTIONAL network name to use along with
+	// ProbeASN. We ignore this field when ProbeASN is zero.
+	ProbeNetworkName string
+}
+
 // Config contains configuration for a geolocate Task.
 type Config struct {
 	// Resolver is the resolver we should use when
@@ -72,6 +126,13 @@ type Config struct {
 	// UserAgent is the user agent to use. If not set, then
 	// we will use a default user agent.
 	UserAgent string
+
+	// Provider is the OPTIONAL geolocation provider. If not set,
+	// then we will use the embedded geoipx database.
+	Provider Provider
+
+	// Override OPTIONALLY overrides the probe ASN and country code.
+	Override Override
 }
 
 // NewTask creates a new instance of Task from config.
@@ -86,14 +147,35 @@ func NewTask(config Config) *Task {
 	if config.Resolver == nil {
 		config.Resolver = netx.NewStdlibResolver(config.Logger)
 	}
+	if config.Provider == nil {
+		config.Provider = geoipx.Embedded()
+	}
 	return &Task{
-		countryLookupper:     mmdbLookupper{},
-		probeIPLookupper:     ipLookupClient(config),
-		probeASNLookupper:    mmdbLookupper{},
-		resolverASNLookupper: mmdbLookupper{},
+		countryLookupper: config.Provider,
+		override:         config.Override,
+		probeIPLookupper: ipLookupClient{
+			Resolver:  config.Resolver,
+			Logger:    config.Logger,
+			UserAgent: config.UserAgent,
+		},
+		probeIPv4Lookupper: ipLookupClient{
+			Family:    familyIPv4,
+			Resolver:  config.Resolver,
+			Logger:    config.Logger,
+			UserAgent: config.UserAgent,
+		},
+		probeIPv6Lookupper: ipLookupClient{
+			Family:    familyIPv6,
+			Resolver:  config.Resolver,
+			Logger:    config.Logger,
+			UserAgent: config.UserAgent,
+		},
+		probeASNLookupper:    config.Provider,
+		resolverASNLookupper: config.Provider,
 		resolverIPLookupper: resolverLookupClient{
 			Logger: config.Logger,
 		},
+		source: config.Provider.Source(),
 	}
 }
 
@@ -101,10 +183,20 @@ func NewTask(config Config) *Task {
 // instance of Task using the NewTask factory.
 type Task struct {
 	countryLookupper     countryLookupper
+	override             Override
 	probeIPLookupper     probeIPLookupper
+	probeIPv4Lookupper   probeIPLookupper
+	probeIPv6Lookupper   probeIPLookupper
 	probeASNLookupper    asnLookupper
 	resolverASNLookupper asnLookupper
 	resolverIPLookupper  resolverIPLookupper
+	source               string
+}
+
+// LookupProbeIP only looks up the probe IP address. This operation is
+// much cheaper than Run and allows to detect network changes.
+func (op Task) LookupProbeIP(ctx context.Context) (string, error) {
+	return op.probeIPLookupper.LookupProbeIP(ctx)
 }
 
 // Run runs the task.
@@ -124,17 +216,13 @@ func (op Task) Run(ctx context.Context) (*Results, error) {
 		return out, fmt.Errorf("lookupProbeIP failed: %w", err)
 	}
 	out.ProbeIP = ip
-	asn, networkName, err := op.probeASNLookupper.LookupASN(out.ProbeIP)
-	if err != nil {
+	if err := op.lookupProbeASN(out); err != nil {
 		return out, fmt.Errorf("lookupASN failed: %w", err)
 	}
-	out.ASN = asn
-	out.NetworkName = networkName
-	cc, err := op.countryLookupper.LookupCC(out.ProbeIP)
-	if err != nil {
+	if err := op.lookupProbeCC(out); err != nil {
 		return out, fmt.Errorf("lookupProbeCC failed: %w", err)
 	}
-	out.CountryCode = cc
+	op.lookupEgresses(ctx, out)
 	out.didResolverLookup = true
 	// Note: ignoring the result of lookupResolverIP and lookupASN
 	// here is intentional. We don't want this (~minor) failure
@@ -155,3 +243,94 @@ func (op Task) Run(ctx context.Context) (*Results, error) {
 	out.ResolverNetworkName = resolverNetworkName
 	return out, nil
 }
+
+// lookupProbeASN sets the probe ASN and network name, honouring the override.
+func (op Task) lookupProbeASN(out *Results) error {
+	if op.override.ProbeASN != 0 {
+		out.ASN = op.override.ProbeASN
+		out.NetworkName = model.DefaultProbeNetworkName
+		if op.override.ProbeNetworkName != "" {
+			out.NetworkName = op.override.ProbeNetworkName
+		}
+		out.ProbeASNSource = SourceOverride
+		return nil
+	}
+	asn, networkName, err := op.probeASNLookupper.LookupASN(out.ProbeIP)
+	if err != nil {
+		return err
+	}
+	out.ASN = asn
+	out.NetworkName = networkName
+	out.ProbeASNSource = op.source
+	return nil
+}
+
+// lookupProbeCC sets the probe country code, honouring the override.
+func (op Task) lookupProbeCC(out *Results) error {
+	if op.override.ProbeCC != "" {
+		out.CountryCode = op.override.ProbeCC
+		out.ProbeCCSource = SourceOverride
+		return nil
+	}
+	cc, err := op.countryLookupper.LookupCC(out.ProbeIP)
+	if err != nil {
+		return err
+	}
+	out.CountryCode = cc
+	out.ProbeCCSource = op.source
+	return nil
+}
+
+// egressLookupTimeout is the maximum time we spend looking up the egress
+// address of the family we did not use for looking up the probe IP.
+const egressLookupTimeout = 20 * time.Second
+
+// lookupEgresses fills the per-family egresses. The egress of the family of the probe IP
+// is consistent with the probe ASN and country code. For the other family, we perform
+// a family-restricted lookup, whose failure just means that we're not dual stack.
+func (op Task) lookupEgresses(ctx context.Context, out *Results) {
+	primary := &model.LocationEgress{
+		ASN:         out.ASN,
+		CountryCode: out.CountryCode,
+		NetworkName: out.NetworkName,
+		ProbeIP:     out.ProbeIP,
+	}
+	switch familyOf(out.ProbeIP) {
+	case familyIPv6:
+		out.ProbeIPv6Egress = primary
+		out.ProbeIPv4Egress = op.lookupEgress(ctx, op.probeIPv4Lookupper)
+	default:
+		out.ProbeIPv4Egress = primary
+		out.ProbeIPv6Egress = op.lookupEgress(ctx, op.probeIPv6Lookupper)
+	}
+}
+
+// lookupEgress returns the egress discovered using the given lookupper or nil.
+func (op Task) lookupEgress(ctx context.Context, lookupper probeIPLookupper) *model.LocationEgress {
+	if lookupper == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, egressLookupTimeout)
+	defer cancel()
+	ip, err := lookupper.LookupProbeIP(ctx)
+	if err != nil {
+		return nil
+	}
+	// Note: we intentionally do not apply the override here because the
+	// override describes the network we use for looking up the probe IP.
+	asn, networkName, err := op.probeASNLookupper.LookupASN(ip)
+	if err != nil {
+		return nil
+	}
+	cc, err := op.countryLookupper.LookupCC(ip)
+	if err != nil {
+		return nil
+	}
+	egress := &model.LocationEgress{
+		ASN:         asn,
+		CountryCode: cc,
+		NetworkName: networkName,
+		ProbeIP:     ip,
+	}
+	return egress
+}
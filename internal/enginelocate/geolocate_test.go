@@ -5,6 +5,8 @@ import (
 	"errors"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
+	"github.com/ooni/probe-cli/v3/internal/geoipx"
 	"github.com/ooni/probe-cli/v3/internal/model"
 )
 
@@ -289,3 +291,172 @@ func TestASNStringWorks(t *testing.T) {
 		t.Fatal("unexpected result")
 	}
 }
+
+func TestLocationLookupRecordsTheSource(t *testing.T) {
+	op := Task{
+		probeIPLookupper:     taskProbeIPLookupper{ip: "1.2.3.4"},
+		probeASNLookupper:    taskASNLookupper{asn: 1234, name: "1234.com"},
+		countryLookupper:     taskCCLookupper{cc: "IT"},
+		resolverIPLookupper:  taskResolverIPLookupper{ip: "4.3.2.1"},
+		resolverASNLookupper: taskASNLookupper{asn: 4321, name: "4321.com"},
+		source:               "custom:dbip.mmdb",
+	}
+	out, err := op.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.ProbeASNSource != "custom:dbip.mmdb" {
+		t.Fatal("invalid ProbeASNSource", out.ProbeASNSource)
+	}
+	if out.ProbeCCSource != "custom:dbip.mmdb" {
+		t.Fatal("invalid ProbeCCSource", out.ProbeCCSource)
+	}
+}
+
+func TestLocationLookupWithOverride(t *testing.T) {
+	newTask := func(override Override) Task {
+		return Task{
+			probeIPLookupper:     taskProbeIPLookupper{ip: "1.2.3.4"},
+			probeASNLookupper:    taskASNLookupper{err: errors.New("should not be called")},
+			countryLookupper:     taskCCLookupper{err: errors.New("should not be called")},
+			resolverIPLookupper:  taskResolverIPLookupper{ip: "4.3.2.1"},
+			resolverASNLookupper: taskASNLookupper{asn: 4321, name: "4321.com"},
+			override:             override,
+			source:               "embedded",
+		}
+	}
+
+	t.Run("with ASN, network name, and CC", func(t *testing.T) {
+		op := newTask(Override{ProbeASN: 30722, ProbeCC: "IT", ProbeNetworkName: "Vodafone Italia S.p.A."})
+		out, err := op.Run(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out.ASN != 30722 || out.NetworkName != "Vodafone Italia S.p.A." || out.CountryCode != "IT" {
+			t.Fatalf("unexpected results: %+v", out)
+		}
+		if out.ProbeASNSource != SourceOverride || out.ProbeCCSource != SourceOverride {
+			t.Fatalf("unexpected sources: %+v", out)
+		}
+		if out.ResolverASN != 4321 {
+			t.Fatal("should not have overridden the resolver ASN")
+		}
+	})
+
+	t.Run("with ASN only", func(t *testing.T) {
+		op := newTask(Override{ProbeASN: 30722})
+		op.countryLookupper = taskCCLookupper{cc: "DE"}
+		out, err := op.Run(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out.ASN != 30722 || out.NetworkName != model.DefaultProbeNetworkName {
+			t.Fatalf("unexpected results: %+v", out)
+		}
+		if out.CountryCode != "DE" || out.ProbeCCSource != "embedded" {
+			t.Fatalf("unexpected results: %+v", out)
+		}
+	})
+}
+
+func TestNewTaskUsesTheEmbeddedDatabaseByDefault(t *testing.T) {
+	task := NewTask(Config{})
+	if task.source != geoipx.SourceEmbedded {
+		t.Fatal("unexpected source", task.source)
+	}
+	if task.probeASNLookupper != geoipx.Embedded() {
+		t.Fatal("unexpected provider")
+	}
+}
+
+func TestLocationLookupEgresses(t *testing.T) {
+	newTask := func(ip string, otherIP string, otherErr error) Task {
+		task := Task{
+			probeIPLookupper:     taskProbeIPLookupper{ip: ip},
+			probeASNLookupper:    taskASNLookupper{asn: 1234, name: "1234.com"},
+			countryLookupper:     taskCCLookupper{cc: "IT"},
+			resolverIPLookupper:  taskResolverIPLookupper{ip: "4.3.2.1"},
+			resolverASNLookupper: taskASNLookupper{asn: 4321, name: "4321.com"},
+		}
+		other := taskProbeIPLookupper{ip: otherIP, err: otherErr}
+		switch familyOf(ip) {
+		case familyIPv4:
+			task.probeIPv6Lookupper = other
+		default:
+			task.probeIPv4Lookupper = other
+		}
+		return task
+	}
+
+	t.Run("for a dual-stack IPv4-first probe", func(t *testing.T) {
+		op := newTask("1.2.3.4", "2001:db8::1", nil)
+		out, err := op.Run(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !out.IsDualStack() {
+			t.Fatal("expected dual stack")
+		}
+		expectV4 := &model.LocationEgress{ASN: 1234, CountryCode: "IT", NetworkName: "1234.com", ProbeIP: "1.2.3.4"}
+		if diff := cmp.Diff(expectV4, out.ProbeIPv4Egress); diff != "" {
+			t.Fatal(diff)
+		}
+		expectV6 := &model.LocationEgress{ASN: 1234, CountryCode: "IT", NetworkName: "1234.com", ProbeIP: "2001:db8::1"}
+		if diff := cmp.Diff(expectV6, out.ProbeIPv6Egress); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("for a dual-stack IPv6-first probe", func(t *testing.T) {
+		op := newTask("2001:db8::1", "1.2.3.4", nil)
+		out, err := op.Run(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !out.IsDualStack() {
+			t.Fatal("expected dual stack")
+		}
+		if out.ProbeIPv4Egress.ProbeIP != "1.2.3.4" || out.ProbeIPv6Egress.ProbeIP != "2001:db8::1" {
+			t.Fatalf("unexpected egresses: %+v %+v", out.ProbeIPv4Egress, out.ProbeIPv6Egress)
+		}
+	})
+
+	t.Run("for an IPv4-only probe", func(t *testing.T) {
+		op := newTask("1.2.3.4", "", errors.New("mocked error"))
+		out, err := op.Run(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out.IsDualStack() {
+			t.Fatal("did not expect dual stack")
+		}
+		if out.ProbeIPv4Egress == nil || out.ProbeIPv6Egress != nil {
+			t.Fatalf("unexpected egresses: %+v %+v", out.ProbeIPv4Egress, out.ProbeIPv6Egress)
+		}
+	})
+
+	t.Run("when we cannot geolocate the other egress", func(t *testing.T) {
+		op := newTask("1.2.3.4", "2001:db8::1", nil)
+		op.probeASNLookupper = &taskFailingOnSecondASNLookupper{}
+		out, err := op.Run(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out.ProbeIPv6Egress != nil {
+			t.Fatal("expected nil IPv6 egress")
+		}
+	})
+}
+
+// taskFailingOnSecondASNLookupper fails the ASN lookups after the first one.
+type taskFailingOnSecondASNLookupper struct {
+	count int
+}
+
+func (c *taskFailingOnSecondASNLookupper) LookupASN(ip string) (uint, string, error) {
+	c.count++
+	if c.count > 1 {
+		return 0, "", errors.New("mocked error")
+	}
+	return 1234, "1234.com", nil
+}
@@ -57,6 +57,9 @@ var (
 )
 
 type ipLookupClient struct {
+	// Family OPTIONALLY restricts the lookup to either "ipv4" or "ipv6".
+	Family string
+
 	// Resolver is the resolver to use for HTTP.
 	Resolver model.Resolver
 
@@ -92,22 +95,32 @@ func (c ipLookupClient) doWithCustomFunc(
 	ctx, cancel := contextForIPLookupWithTimeout(ctx)
 	defer cancel()
 
+	// When we're looking up a specific family, we make sure that we're
+	// only going to connect to addresses of such a family.
+	resolver := c.Resolver
+	if c.Family != "" {
+		resolver = &familyResolver{Resolver: resolver, family: c.Family}
+	}
+
 	// Implementation note: we MUST use an HTTP client that we're
 	// sure IS NOT using any proxy. To this end, we construct a
 	// client ourself that we know is not proxied.
 	// TODO(https://github.com/ooni/probe/issues/2534): the NewHTTPTransportWithResolver has QUIRKS but
 	// we don't care about them in this context
 	netx := &netxlite.Netx{}
-	txp := netxlite.NewHTTPTransportWithResolver(netx, c.Logger, c.Resolver)
+	txp := netxlite.NewHTTPTransportWithResolver(netx, c.Logger, resolver)
 	clnt := &http.Client{Transport: txp}
 	defer clnt.CloseIdleConnections()
-	ip, err := fn(ctx, clnt, c.Logger, c.UserAgent, c.Resolver)
+	ip, err := fn(ctx, clnt, c.Logger, c.UserAgent, resolver)
 	if err != nil {
 		return model.DefaultProbeIP, err
 	}
 	if net.ParseIP(ip) == nil {
 		return model.DefaultProbeIP, fmt.Errorf("%w: %s", ErrInvalidIPAddress, ip)
 	}
+	if c.Family != "" && familyOf(ip) != c.Family {
+		return model.DefaultProbeIP, fmt.Errorf("%w: %s is not %s", ErrInvalidIPAddress, ip, c.Family)
+	}
 	c.Logger.Debugf("iplookup: IP: %s", ip)
 	return ip, nil
 }
@@ -115,7 +128,7 @@ func (c ipLookupClient) doWithCustomFunc(
 func (c ipLookupClient) LookupProbeIP(ctx context.Context) (string, error) {
 	union := multierror.New(ErrAllIPLookuppersFailed)
 	for _, method := range makeSlice() {
-		c.Logger.Infof("iplookup: using %s", method.name)
+		c.Logger.Infof("iplookup: using %s%s", method.name, c.familySuffix())
 		ip, err := c.doWithCustomFunc(ctx, method.fn)
 		if err == nil {
 			return ip, nil
@@ -124,3 +137,11 @@ func (c ipLookupClient) LookupProbeIP(ctx context.Context) (string, error) {
 	}
 	return model.DefaultProbeIP, union
 }
+
+// familySuffix returns a suffix for logging the family we're using, if any.
+func (c ipLookupClient) familySuffix() string {
+	if c.Family == "" {
+		return ""
+	}
+	return " over " + c.Family
+}
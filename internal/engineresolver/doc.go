@@ -21,4 +21,9 @@
 // We also support a socks5 proxy. When such a proxy is configured,
 // the code WILL skip http3 resolvers AS WELL AS the system
 // resolver, in an attempt to avoid leaking your queries.
+//
+// Users may steer this process by pinning a resolver, which we will always
+// try first, by blacklisting resolvers, which we will never use, and by
+// adding custom DoH and DoT resolvers to the pool. We store these preferences
+// in the key-value store alongside the resolvers state.
 package engineresolver
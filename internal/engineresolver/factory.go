@@ -1,7 +1,9 @@
 package engineresolver
 
 import (
+	"context"
 	"errors"
+	"net"
 	"net/url"
 
 	"github.com/ooni/probe-cli/v3/internal/bytecounter"
@@ -15,7 +17,7 @@ import (
 var errCannotUseHTTP3WithAProxyURL = errors.New("cannot use HTTP/3 with a proxy URL")
 
 // errUnsupportedResolverScheme means we don't support the
-// given resolver scheme. We only support https, http and system.
+// given resolver scheme. We only support https, http, dot and system.
 var errUnsupportedResolverScheme = errors.New("unsupported resolver scheme")
 
 // newChildResolver constructs a new child resolver.
@@ -24,7 +26,7 @@ var errUnsupportedResolverScheme = errors.New("unsupported resolver scheme")
 //
 // - logger is the MANDATORY logger;
 //
-// - URL is the MANDATORY URL to use (a DoH URL or system:///);
+// - URL is the MANDATORY URL to use (a DoH URL, a DoT URL or system:///);
 //
 // - http3Enabled indicates whether to use HTTP/3;
 //
@@ -56,6 +58,8 @@ func newChildResolver(
 	switch parsed.Scheme {
 	case "http", "https": // http is here for testing
 		reso = newChildResolverHTTPS(logger, URL, http3Enabled, counter, proxyURL)
+	case "dot":
+		reso = newChildResolverDoT(logger, parsed, counter, proxyURL)
 	case "system":
 		netx := &netxlite.Netx{}
 		reso = bytecounter.MaybeWrapSystemResolver(
@@ -99,3 +103,36 @@ func newChildResolverHTTPS(
 	wrapped := netxlite.WrapResolver(logger, underlying)
 	return wrapped
 }
+
+// newChildResolverDoT is like newChildResolver but assumes that
+// we already know that the URL scheme is dot. When the URL does not
+// contain a port, we use the default DoT port (i.e., 853).
+func newChildResolverDoT(
+	logger model.Logger,
+	URL *url.URL,
+	counter *bytecounter.Counter,
+	proxyURL *url.URL,
+) model.Resolver {
+	address := URL.Host
+	if URL.Port() == "" {
+		address = net.JoinHostPort(URL.Hostname(), "853")
+	}
+	netx := &netxlite.Netx{}
+	dialer := netxlite.MaybeWrapWithProxyDialer(
+		netxlite.NewDialerWithStdlibResolver(logger),
+		proxyURL, // nil here disables using the proxy
+	)
+	thx := netx.NewTLSHandshakerStdlib(logger)
+	tlsDialer := netxlite.NewTLSDialer(dialer, thx)
+	dial := func(ctx context.Context, network, address string) (net.Conn, error) {
+		conn, err := tlsDialer.DialTLSContext(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+		return bytecounter.MaybeWrapConn(conn, counter), nil
+	}
+	dnstxp := netxlite.NewUnwrappedDNSOverTLSTransport(dial, address)
+	underlying := netxlite.NewUnwrappedParallelResolver(dnstxp)
+	wrapped := netxlite.WrapResolver(logger, underlying)
+	return wrapped
+}
@@ -104,7 +104,7 @@ func Test_newChildResolver(t *testing.T) {
 	t.Run("we return an error when we don't support the URL scheme", func(t *testing.T) {
 		reso, err := newChildResolver(
 			model.DiscardLogger,
-			"dot://8.8.8.8:853/",
+			"tcp://8.8.8.8:53/",
 			true,
 			bytecounter.New(),
 			nil,
@@ -117,6 +117,40 @@ func Test_newChildResolver(t *testing.T) {
 		}
 	})
 
+	t.Run("for DoT resolvers", func(t *testing.T) {
+		t.Run("we use the default port when not specified", func(t *testing.T) {
+			reso, err := newChildResolver(
+				model.DiscardLogger,
+				"dot://8.8.8.8",
+				false,
+				bytecounter.New(),
+				nil,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reso.Network() != "dot" || reso.Address() != "8.8.8.8:853" {
+				t.Fatal("unexpected resolver", reso.Network(), reso.Address())
+			}
+		})
+
+		t.Run("we honour the port when specified", func(t *testing.T) {
+			reso, err := newChildResolver(
+				model.DiscardLogger,
+				"dot://1.1.1.1:8853",
+				false,
+				nil,
+				nil,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reso.Address() != "1.1.1.1:8853" {
+				t.Fatal("unexpected address", reso.Address())
+			}
+		})
+	})
+
 	t.Run("for HTTPS resolvers", func(t *testing.T) {
 
 		t.Run("the returned resolver wraps errors", func(t *testing.T) {
@@ -0,0 +1,250 @@
+package engineresolver
+
+//
+// User-configurable resolver policy
+//
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+// policykey is the key used by the key value store to store
+// the resolver policy configured by the user.
+const policykey = "engineresolver.policy"
+
+// customResolverScore is the initial score of custom resolvers. Because
+// the user explicitly added them, we want to try them first.
+const customResolverScore = 1.0
+
+// policy contains the user preferences regarding resolvers.
+type policy struct {
+	// Pinned is the OPTIONAL URL of the resolver to always try first.
+	Pinned string
+
+	// Blacklisted contains the URLs of the resolvers we should never use.
+	Blacklisted []string
+
+	// Custom contains the URLs of user-provided DoH/DoT resolvers.
+	Custom []string
+}
+
+// isBlacklisted returns whether the given URL is blacklisted.
+func (p *policy) isBlacklisted(URL string) bool {
+	return stringSliceContains(p.Blacklisted, URL)
+}
+
+// isCustom returns whether the given URL is a custom resolver.
+func (p *policy) isCustom(URL string) bool {
+	return stringSliceContains(p.Custom, URL)
+}
+
+// isKnown returns whether the given URL is a builtin or a custom resolver.
+func (p *policy) isKnown(URL string) bool {
+	_, found := allbyurl[URL]
+	return found || p.isCustom(URL)
+}
+
+// stringSliceContains returns whether values contains value.
+func stringSliceContains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// stringSliceRemove returns a copy of values without value.
+func stringSliceRemove(values []string, value string) (out []string) {
+	for _, v := range values {
+		if v != value {
+			out = append(out, v)
+		}
+	}
+	return
+}
+
+// readpolicy reads the resolver policy from the kvstore.
+func (r *Resolver) readpolicy() (*policy, error) {
+	if r.KVStore == nil {
+		return nil, ErrNilKVStore
+	}
+	data, err := r.KVStore.Get(policykey)
+	if err != nil {
+		return nil, err
+	}
+	var p policy
+	if err := r.codec().Decode(data, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// readpolicydefault is like readpolicy but returns an empty
+// policy if we cannot read the policy from the kvstore.
+func (r *Resolver) readpolicydefault() *policy {
+	p, err := r.readpolicy()
+	if err != nil {
+		return &policy{}
+	}
+	return p
+}
+
+// writepolicy writes the resolver policy to the kvstore.
+func (r *Resolver) writepolicy(p *policy) error {
+	if r.KVStore == nil {
+		return ErrNilKVStore
+	}
+	data, err := r.codec().Encode(p)
+	if err != nil {
+		return err
+	}
+	return r.KVStore.Set(policykey, data)
+}
+
+// candidates returns the entries of the state that we should try, in the order
+// in which we should try them, according to the given policy.
+func (r *Resolver) candidates(state []*resolverinfo, p *policy) []*resolverinfo {
+	var pinned, out []*resolverinfo
+	for _, e := range state {
+		switch {
+		case p.isBlacklisted(e.URL):
+			// skip
+		case e.URL == p.Pinned:
+			pinned = append(pinned, e)
+		default:
+			out = append(out, e)
+		}
+	}
+	return append(pinned, out...)
+}
+
+// Entry describes a resolver known to the [Resolver].
+type Entry struct {
+	// URL is the resolver URL.
+	URL string
+
+	// Score is the resolver score (the higher the better).
+	Score float64
+
+	// LastError is the error that occurred the last time we used
+	// this resolver or an empty string if the lookup succeeded.
+	LastError string
+
+	// Pinned indicates we always try this resolver first.
+	Pinned bool
+
+	// Blacklisted indicates we never use this resolver.
+	Blacklisted bool
+
+	// Custom indicates this is a user-provided resolver.
+	Custom bool
+}
+
+// Entries returns the resolvers known to the [Resolver], sorted by
+// descending score, along with their state and policy.
+func (r *Resolver) Entries() []Entry {
+	p := r.readpolicydefault()
+	var out []Entry
+	for _, e := range r.readstatedefault(p) {
+		out = append(out, Entry{
+			URL:         e.URL,
+			Score:       e.Score,
+			LastError:   e.LastError,
+			Pinned:      e.URL == p.Pinned,
+			Blacklisted: p.isBlacklisted(e.URL),
+			Custom:      p.isCustom(e.URL),
+		})
+	}
+	return out
+}
+
+// ErrUnknownResolver indicates that a resolver URL is not known.
+var ErrUnknownResolver = errors.New("engineresolver: unknown resolver")
+
+// ErrInvalidCustomResolver indicates that a custom resolver URL is invalid.
+var ErrInvalidCustomResolver = errors.New("engineresolver: invalid custom resolver")
+
+// Pin ensures that we always try the resolver with the given URL first
+// and otherwise fall back to the other resolvers. Pinning a resolver also
+// removes it from the blacklist. Pinning disables the occasional reordering
+// of resolvers we otherwise perform to give other resolvers a chance.
+func (r *Resolver) Pin(URL string) error {
+	p := r.readpolicydefault()
+	if !p.isKnown(URL) {
+		return fmt.Errorf("%w: %s", ErrUnknownResolver, URL)
+	}
+	p.Pinned = URL
+	p.Blacklisted = stringSliceRemove(p.Blacklisted, URL)
+	return r.writepolicy(p)
+}
+
+// Unpin removes the pinned resolver, if any.
+func (r *Resolver) Unpin() error {
+	p := r.readpolicydefault()
+	p.Pinned = ""
+	return r.writepolicy(p)
+}
+
+// Blacklist ensures we never use the resolver with the given URL. If the
+// resolver was pinned, blacklisting it also unpins it.
+func (r *Resolver) Blacklist(URL string) error {
+	p := r.readpolicydefault()
+	if !p.isKnown(URL) {
+		return fmt.Errorf("%w: %s", ErrUnknownResolver, URL)
+	}
+	if !p.isBlacklisted(URL) {
+		p.Blacklisted = append(p.Blacklisted, URL)
+	}
+	if p.Pinned == URL {
+		p.Pinned = ""
+	}
+	return r.writepolicy(p)
+}
+
+// Unblacklist removes the resolver with the given URL from the blacklist.
+func (r *Resolver) Unblacklist(URL string) error {
+	p := r.readpolicydefault()
+	p.Blacklisted = stringSliceRemove(p.Blacklisted, URL)
+	return r.writepolicy(p)
+}
+
+// AddCustom adds a user-provided resolver to the pool. The URL must be either
+// a DoH URL (e.g., https://dns.example.com/dns-query) or a DoT URL (e.g.,
+// dot://dns.example.com or dot://1.1.1.1:853). Custom resolvers start with a
+// high score, therefore we will try them before the builtin ones.
+func (r *Resolver) AddCustom(URL string) error {
+	parsed, err := url.Parse(URL)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidCustomResolver, err.Error())
+	}
+	switch {
+	case parsed.Scheme != "https" && parsed.Scheme != "dot":
+		return fmt.Errorf("%w: unsupported scheme: %s", ErrInvalidCustomResolver, parsed.Scheme)
+	case parsed.Hostname() == "":
+		return fmt.Errorf("%w: missing host", ErrInvalidCustomResolver)
+	}
+	p := r.readpolicydefault()
+	if p.isKnown(URL) {
+		return nil
+	}
+	p.Custom = append(p.Custom, URL)
+	return r.writepolicy(p)
+}
+
+// RemoveCustom removes a user-provided resolver from the pool along
+// with any pinning or blacklisting referring to it.
+func (r *Resolver) RemoveCustom(URL string) error {
+	p := r.readpolicydefault()
+	if !p.isCustom(URL) {
+		return fmt.Errorf("%w: %s", ErrUnknownResolver, URL)
+	}
+	p.Custom = stringSliceRemove(p.Custom, URL)
+	p.Blacklisted = stringSliceRemove(p.Blacklisted, URL)
+	if p.Pinned == URL {
+		p.Pinned = ""
+	}
+	return r.writepolicy(p)
+}
@@ -0,0 +1,269 @@
+package engineresolver
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/ooni/probe-cli/v3/internal/kvstore"
+	"github.com/ooni/probe-cli/v3/internal/mocks"
+	"github.com/ooni/probe-cli/v3/internal/model"
+)
+
+func TestReadPolicyNoKVStore(t *testing.T) {
+	reso := &Resolver{}
+	p, err := reso.readpolicy()
+	if !errors.Is(err, ErrNilKVStore) {
+		t.Fatal("not the error we expected", err)
+	}
+	if p != nil {
+		t.Fatal("expected nil policy here")
+	}
+	if err := reso.writepolicy(&policy{}); !errors.Is(err, ErrNilKVStore) {
+		t.Fatal("not the error we expected", err)
+	}
+}
+
+func TestReadPolicyDecodeError(t *testing.T) {
+	errMocked := errors.New("mocked error")
+	reso := &Resolver{
+		KVStore: &kvstore.Memory{},
+		jsonCodec: &jsonCodecMockable{
+			DecodeErr: errMocked,
+		},
+	}
+	if err := reso.writepolicy(&policy{Pinned: systemResolverURL}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reso.readpolicy(); !errors.Is(err, errMocked) {
+		t.Fatal("not the error we expected", err)
+	}
+	if diff := cmp.Diff(&policy{}, reso.readpolicydefault()); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+func TestPolicyPinAndBlacklist(t *testing.T) {
+	const (
+		google     = "https://dns.google/dns-query"
+		cloudflare = "https://cloudflare-dns.com/dns-query"
+	)
+
+	t.Run("we cannot pin or blacklist unknown resolvers", func(t *testing.T) {
+		reso := &Resolver{KVStore: &kvstore.Memory{}}
+		if err := reso.Pin("https://dns.example.com/dns-query"); !errors.Is(err, ErrUnknownResolver) {
+			t.Fatal("not the error we expected", err)
+		}
+		if err := reso.Blacklist("https://dns.example.com/dns-query"); !errors.Is(err, ErrUnknownResolver) {
+			t.Fatal("not the error we expected", err)
+		}
+	})
+
+	t.Run("pinning removes from the blacklist and vice versa", func(t *testing.T) {
+		reso := &Resolver{KVStore: &kvstore.Memory{}}
+		if err := reso.Blacklist(google); err != nil {
+			t.Fatal(err)
+		}
+		if err := reso.Blacklist(google); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff([]string{google}, reso.readpolicydefault().Blacklisted); diff != "" {
+			t.Fatal(diff)
+		}
+		if err := reso.Pin(google); err != nil {
+			t.Fatal(err)
+		}
+		p := reso.readpolicydefault()
+		if p.Pinned != google || len(p.Blacklisted) != 0 {
+			t.Fatal("unexpected policy", p)
+		}
+		if err := reso.Blacklist(google); err != nil {
+			t.Fatal(err)
+		}
+		p = reso.readpolicydefault()
+		if p.Pinned != "" || !p.isBlacklisted(google) {
+			t.Fatal("unexpected policy", p)
+		}
+		if err := reso.Unblacklist(google); err != nil {
+			t.Fatal(err)
+		}
+		if err := reso.Pin(cloudflare); err != nil {
+			t.Fatal(err)
+		}
+		if err := reso.Unpin(); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(&policy{}, reso.readpolicydefault()); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("Entries reflects the policy", func(t *testing.T) {
+		reso := &Resolver{KVStore: &kvstore.Memory{}}
+		if err := reso.Pin(google); err != nil {
+			t.Fatal(err)
+		}
+		if err := reso.Blacklist(cloudflare); err != nil {
+			t.Fatal(err)
+		}
+		entries := reso.Entries()
+		if len(entries) != len(allmakers) {
+			t.Fatal("unexpected number of entries", len(entries))
+		}
+		for _, e := range entries {
+			if e.Pinned != (e.URL == google) {
+				t.Fatal("unexpected Pinned for", e.URL)
+			}
+			if e.Blacklisted != (e.URL == cloudflare) {
+				t.Fatal("unexpected Blacklisted for", e.URL)
+			}
+			if e.Custom {
+				t.Fatal("unexpected Custom for", e.URL)
+			}
+		}
+	})
+}
+
+func TestPolicyCustomResolvers(t *testing.T) {
+	t.Run("AddCustom validates the URL", func(t *testing.T) {
+		inputs := []string{
+			"\t",
+			"http://dns.example.com/dns-query",
+			"system:///",
+			"dot://",
+		}
+		for _, input := range inputs {
+			reso := &Resolver{KVStore: &kvstore.Memory{}}
+			if err := reso.AddCustom(input); !errors.Is(err, ErrInvalidCustomResolver) {
+				t.Fatal("not the error we expected for", input, err)
+			}
+		}
+	})
+
+	t.Run("we can add, use, and remove custom resolvers", func(t *testing.T) {
+		const custom = "dot://dns.example.com"
+		reso := &Resolver{KVStore: &kvstore.Memory{}}
+		if err := reso.AddCustom(custom); err != nil {
+			t.Fatal(err)
+		}
+		if err := reso.AddCustom(custom); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff([]string{custom}, reso.readpolicydefault().Custom); diff != "" {
+			t.Fatal(diff)
+		}
+		entries := reso.Entries()
+		if len(entries) != len(allmakers)+1 {
+			t.Fatal("unexpected number of entries", len(entries))
+		}
+		if entries[0].URL != custom || !entries[0].Custom || entries[0].Score != customResolverScore {
+			t.Fatal("unexpected first entry", entries[0])
+		}
+		if err := reso.Pin(custom); err != nil {
+			t.Fatal(err)
+		}
+		if err := reso.RemoveCustom(custom); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(&policy{}, reso.readpolicydefault()); diff != "" {
+			t.Fatal(diff)
+		}
+		if err := reso.RemoveCustom(custom); !errors.Is(err, ErrUnknownResolver) {
+			t.Fatal("not the error we expected", err)
+		}
+	})
+}
+
+func TestLookupHostHonoursPolicy(t *testing.T) {
+	const (
+		// Note: we're using quad9 because it has no http3 variant
+		quad9  = "https://dns.quad9.net/dns-query"
+		custom = "https://dns.example.com/dns-query"
+	)
+	expected := []string{"8.8.8.8"}
+
+	// newResolver returns a resolver where only the resolver with the working
+	// URL works and that records all the URLs it used
+	newResolver := func(working string, used *[]string) *Resolver {
+		var mu sync.Mutex
+		return &Resolver{
+			KVStore: &kvstore.Memory{},
+			Logger:  model.DiscardLogger,
+			newChildResolverFn: func(h3 bool, URL string) (model.Resolver, error) {
+				reso := &mocks.Resolver{
+					MockLookupHost: func(ctx context.Context, domain string) ([]string, error) {
+						mu.Lock()
+						*used = append(*used, URL)
+						mu.Unlock()
+						if URL != working {
+							return nil, errors.New("mocked error")
+						}
+						return expected, nil
+					},
+				}
+				return reso, nil
+			},
+		}
+	}
+
+	t.Run("we try the pinned resolver first", func(t *testing.T) {
+		for idx := 0; idx < 16; idx++ {
+			var used []string
+			reso := newResolver(quad9, &used)
+			if err := reso.Pin(quad9); err != nil {
+				t.Fatal(err)
+			}
+			addrs, err := reso.LookupHost(context.Background(), "example.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(expected, addrs); diff != "" {
+				t.Fatal(diff)
+			}
+			if diff := cmp.Diff([]string{quad9}, used); diff != "" {
+				t.Fatal(diff)
+			}
+		}
+	})
+
+	t.Run("we never use blacklisted resolvers", func(t *testing.T) {
+		var used []string
+		reso := newResolver(quad9, &used)
+		if err := reso.Blacklist(quad9); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := reso.LookupHost(context.Background(), "example.com"); !errors.Is(err, ErrLookupHost) {
+			t.Fatal("not the error we expected", err)
+		}
+		for _, URL := range used {
+			if URL == quad9 {
+				t.Fatal("we used a blacklisted resolver")
+			}
+		}
+		for _, e := range reso.Entries() {
+			if e.URL != quad9 && e.LastError != "mocked error" {
+				t.Fatal("unexpected last error for", e.URL, e.LastError)
+			}
+		}
+	})
+
+	t.Run("we use custom resolvers and we remember switches", func(t *testing.T) {
+		var used []string
+		reso := newResolver(custom, &used)
+		if err := reso.AddCustom(custom); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := reso.LookupHost(context.Background(), "example.com"); err != nil {
+			t.Fatal(err)
+		}
+		if reso.lastURL != custom {
+			t.Fatal("unexpected lastURL", reso.lastURL)
+		}
+		reso.maybeLogSwitch(quad9)
+		if reso.lastURL != quad9 {
+			t.Fatal("unexpected lastURL", reso.lastURL)
+		}
+	})
+}
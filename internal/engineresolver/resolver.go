@@ -57,6 +57,10 @@ type Resolver struct {
 	// we will construct a default codec.
 	jsonCodec jsonCodec
 
+	// lastURL is the URL of the last resolver that worked, which
+	// allows us to log when we switch to another resolver.
+	lastURL string
+
 	// mu provides synchronisation of internal fields.
 	mu sync.Mutex
 
@@ -100,11 +104,15 @@ var ErrLookupHost = errors.New("sessionresolver: LookupHost failed")
 // multierror.Union error on failure, so you can see individual errors
 // and get a better picture of what's been going wrong.
 func (r *Resolver) LookupHost(ctx context.Context, hostname string) ([]string, error) {
-	state := r.readstatedefault()
-	r.maybeConfusion(state, time.Now().UnixNano())
+	p := r.readpolicydefault()
+	state := r.readstatedefault(p)
 	defer r.writestate(state)
+	candidates := r.candidates(state, p)
+	if p.Pinned == "" {
+		r.maybeConfusion(candidates, time.Now().UnixNano())
+	}
 	me := multierror.New(ErrLookupHost)
-	for _, e := range state {
+	for _, e := range candidates {
 		if r.ProxyURL != nil && r.shouldSkipWithProxy(e) {
 			r.logger().Infof("sessionresolver: skipping with proxy: %+v", e)
 			continue // we cannot proxy this URL so ignore it
@@ -121,6 +129,7 @@ func (r *Resolver) LookupHost(ctx context.Context, hostname string) ([]string, e
 
 		addrs, err := r.lookupHost(ctx, e, hostname)
 		if err == nil {
+			r.maybeLogSwitch(e.URL)
 			return addrs, nil
 		}
 		me.Add(newErrWrapper(err, e.URL))
@@ -147,6 +156,7 @@ func (r *Resolver) lookupHost(ctx context.Context, ri *resolverinfo, hostname st
 	if err != nil {
 		r.logger().Warnf("sessionresolver: getresolver: %s", err.Error())
 		ri.Score = 0 // this is a hard error
+		ri.LastError = err.Error()
 		return nil, err
 	}
 	op := logx.NewOperationLogger(
@@ -155,12 +165,29 @@ func (r *Resolver) lookupHost(ctx context.Context, ri *resolverinfo, hostname st
 	op.Stop(err)
 	if err == nil {
 		ri.Score = ewma*1.0 + (1-ewma)*ri.Score // increase score
+		ri.LastError = ""
 		return addrs, nil
 	}
 	ri.Score = ewma*0.0 + (1-ewma)*ri.Score // decrease score
+	ri.LastError = err.Error()
 	return nil, err
 }
 
+// maybeLogSwitch logs when the resolver that worked is not the
+// same resolver that worked during the previous lookup.
+func (r *Resolver) maybeLogSwitch(URL string) {
+	defer r.mu.Unlock()
+	r.mu.Lock()
+	if r.lastURL != URL {
+		if r.lastURL != "" {
+			r.logger().Infof("sessionresolver: switching from %s to %s", r.lastURL, URL)
+		} else {
+			r.logger().Infof("sessionresolver: using %s", URL)
+		}
+		r.lastURL = URL
+	}
+}
+
 // maybeConfusion will rearrange the  first elements of the vector
 // with low probability, so giving other resolvers a chance
 // to run and show that they are also viable. We do not fully
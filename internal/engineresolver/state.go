@@ -23,6 +23,10 @@ type resolverinfo struct {
 
 	// Score is the score of a resolver.
 	Score float64
+
+	// LastError is the error that occurred the last time we used
+	// this resolver or an empty string on success.
+	LastError string
 }
 
 // ErrNilKVStore indicates that the KVStore is nil.
@@ -49,15 +53,15 @@ func (r *Resolver) readstate() ([]*resolverinfo, error) {
 var errNoEntries = errors.New("sessionresolver: no available entries")
 
 // readstateandprune reads the state from disk and removes all the
-// entries that we don't actually support.
-func (r *Resolver) readstateandprune() ([]*resolverinfo, error) {
+// entries that we don't actually support or that the user removed.
+func (r *Resolver) readstateandprune(p *policy) ([]*resolverinfo, error) {
 	ri, err := r.readstate()
 	if err != nil {
 		return nil, err
 	}
 	var out []*resolverinfo
 	for _, e := range ri {
-		if _, found := allbyurl[e.URL]; !found {
+		if !p.isKnown(e.URL) {
 			continue // we don't support this specific entry
 		}
 		out = append(out, e)
@@ -76,9 +80,10 @@ func sortstate(ri []*resolverinfo) {
 }
 
 // readstatedefault reads the state from disk and merges the state
-// so that all supported entries are represented.
-func (r *Resolver) readstatedefault() []*resolverinfo {
-	ri, _ := r.readstateandprune()
+// so that all supported entries, including the custom entries
+// configured by the user, are represented.
+func (r *Resolver) readstatedefault(p *policy) []*resolverinfo {
+	ri, _ := r.readstateandprune(p)
 	here := make(map[string]bool)
 	for _, e := range ri {
 		here[e.URL] = true // record what we already have
@@ -92,6 +97,15 @@ func (r *Resolver) readstatedefault() []*resolverinfo {
 			Score: e.score,
 		})
 	}
+	for _, URL := range p.Custom {
+		if _, found := here[URL]; found {
+			continue // already here so no need to add
+		}
+		ri = append(ri, &resolverinfo{
+			URL:   URL,
+			Score: customResolverScore,
+		})
+	}
 	sortstate(ri)
 	return ri
 }
@@ -49,7 +49,7 @@ func TestReadStateDecodeError(t *testing.T) {
 
 func TestReadStateAndPruneReadStateError(t *testing.T) {
 	reso := &Resolver{KVStore: &kvstore.Memory{}}
-	out, err := reso.readstateandprune()
+	out, err := reso.readstateandprune(&policy{})
 	if !errors.Is(err, kvstore.ErrNoSuchKey) {
 		t.Fatal("not the error we expected", err)
 	}
@@ -65,7 +65,7 @@ func TestReadStateAndPruneWithUnsupportedEntries(t *testing.T) {
 	if err := reso.writestate(in); err != nil {
 		t.Fatal(err)
 	}
-	out, err := reso.readstateandprune()
+	out, err := reso.readstateandprune(&policy{})
 	if !errors.Is(err, errNoEntries) {
 		t.Fatal("not the error we expected", err)
 	}
@@ -88,7 +88,7 @@ func TestReadStateDefaultWithMissingEntries(t *testing.T) {
 		t.Fatal(err)
 	}
 	// let us seee what we read
-	out := reso.readstatedefault()
+	out := reso.readstatedefault(&policy{})
 	if len(out) < 1 {
 		t.Fatal("expected non-empty output")
 	}
@@ -0,0 +1,149 @@
+// Package dslrun contains the dslrun experiment.
+//
+// This experiment runs a measurement program written using the dsljson
+// format, which describes a DAG of measurement stages (e.g., getaddrinfo,
+// tcp_connect, tls_handshake, http_round_trip). The program is the experiment
+// input, which allows OONI Run v2 descriptors to carry custom measurement
+// recipes that run without requiring a new probe release.
+//
+// We archive the observations collected by the stages using the standard
+// test keys (e.g., queries, tcp_connect, tls_handshakes, requests).
+package dslrun
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ooni/probe-cli/v3/internal/measurexlite"
+	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/x/dslengine"
+	"github.com/ooni/probe-cli/v3/internal/x/dsljson"
+	"github.com/ooni/probe-cli/v3/internal/x/dslvm"
+)
+
+const (
+	testName    = "dslrun"
+	testVersion = "0.1.0"
+)
+
+// Config contains the experiment config.
+type Config struct {
+	// MaxActiveConns is the maximum number of active connections.
+	MaxActiveConns int64 `ooni:"maximum number of active connections"`
+
+	// MaxActiveDNSLookups is the maximum number of active DNS lookups.
+	MaxActiveDNSLookups int64 `ooni:"maximum number of active DNS lookups"`
+
+	// MaxRuntime is the maximum runtime in seconds.
+	MaxRuntime int64 `ooni:"maximum runtime in seconds"`
+}
+
+func (c *Config) maxActiveConns() int {
+	if c.MaxActiveConns > 0 {
+		return int(c.MaxActiveConns)
+	}
+	return 16
+}
+
+func (c *Config) maxActiveDNSLookups() int {
+	if c.MaxActiveDNSLookups > 0 {
+		return int(c.MaxActiveDNSLookups)
+	}
+	return 4
+}
+
+func (c *Config) maxRuntime() time.Duration {
+	if c.MaxRuntime > 0 {
+		return time.Duration(c.MaxRuntime) * time.Second
+	}
+	return 60 * time.Second
+}
+
+// TestKeys contains the experiment's result.
+type TestKeys struct {
+	// Observations contains the observations collected by the stages.
+	*dslvm.Observations
+
+	// ProgramSHA256 is the SHA256 of the program we executed.
+	ProgramSHA256 string `json:"program_sha256"`
+
+	// ProgramVersion is the version of the program format.
+	ProgramVersion int64 `json:"program_version"`
+
+	// Failure is the failure that prevented us from running the program, if any.
+	Failure *string `json:"failure"`
+}
+
+// Measurer performs the measurement.
+type Measurer struct {
+	config Config
+}
+
+// ExperimentName implements ExperimentMeasurer.ExperimentName.
+func (m *Measurer) ExperimentName() string {
+	return testName
+}
+
+// ExperimentVersion implements ExperimentMeasurer.ExperimentVersion.
+func (m *Measurer) ExperimentVersion() string {
+	return testVersion
+}
+
+var (
+	// errNoInputProvided indicates you didn't provide any input
+	errNoInputProvided = errors.New("dslrun: no input provided")
+
+	// errInvalidProgram indicates the input is not a valid program
+	errInvalidProgram = errors.New("dslrun: invalid program")
+)
+
+// Run implements ExperimentMeasurer.Run.
+func (m *Measurer) Run(ctx context.Context, args *model.ExperimentArgs) error {
+	measurement := args.Measurement
+	logger := args.Session.Logger()
+	if measurement.Input == "" {
+		return errNoInputProvided
+	}
+
+	// make sure the program is valid before running it
+	rawProgram := []byte(measurement.Input)
+	root, err := dsljson.ParseProgram(logger, rawProgram)
+	if err != nil {
+		return fmt.Errorf("%w: %s", errInvalidProgram, err.Error())
+	}
+
+	digest := sha256.Sum256(rawProgram)
+	tk := &TestKeys{
+		Observations:   dslvm.NewObservations(),
+		ProgramSHA256:  hex.EncodeToString(digest[:]),
+		ProgramVersion: dsljson.ProgramVersion,
+		Failure:        nil,
+	}
+	measurement.TestKeys = tk
+
+	// make sure the program runtime is bounded
+	ctx, cancel := context.WithTimeout(ctx, m.config.maxRuntime())
+	defer cancel()
+
+	// create the runtime collecting observations
+	rtx := dslengine.NewRuntimeMeasurexLite(
+		logger, measurement.MeasurementStartTimeSaved,
+		dslengine.OptionMaxActiveDNSLookups(m.config.maxActiveDNSLookups()),
+		dslengine.OptionMaxActiveConns(m.config.maxActiveConns()),
+	)
+
+	// run the program and collect the observations
+	err = dsljson.Run(ctx, rtx, root)
+	tk.Observations = rtx.Observations()
+	tk.Failure = measurexlite.NewFailure(err)
+	return nil // we want to submit this measurement
+}
+
+// NewExperimentMeasurer creates a new ExperimentMeasurer.
+func NewExperimentMeasurer(config Config) model.ExperimentMeasurer {
+	return &Measurer{config: config}
+}
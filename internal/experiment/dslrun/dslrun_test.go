@@ -0,0 +1,183 @@
+package dslrun
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ooni/netem"
+	"github.com/ooni/probe-cli/v3/internal/mocks"
+	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/netemx"
+	"github.com/ooni/probe-cli/v3/internal/x/dsljson"
+)
+
+// program is the program we use for testing.
+const program = `{
+	"version": 1,
+	"stages": [{
+		"name": "getaddrinfo",
+		"value": {"domain": "www.example.com", "output": "addrs", "tags": []}
+	}, {
+		"name": "make_endpoints",
+		"value": {"input": "addrs", "output": "endpoints", "port": "443"}
+	}, {
+		"name": "tcp_connect",
+		"value": {"input": "endpoints", "output": "tcp_conns", "tags": []}
+	}, {
+		"name": "tls_handshake",
+		"value": {
+			"input": "tcp_conns",
+			"output": "tls_conns",
+			"next_protos": ["h2", "http/1.1"],
+			"server_name": "www.example.com"
+		}
+	}, {
+		"name": "http_round_trip",
+		"value": {
+			"input": "tls_conns",
+			"output": "done",
+			"host": "www.example.com",
+			"method": "GET",
+			"url_path": "/"
+		}
+	}]
+}`
+
+func TestConfig(t *testing.T) {
+	c := Config{}
+	if c.maxActiveConns() != 16 {
+		t.Fatal("invalid default max active conns")
+	}
+	if c.maxActiveDNSLookups() != 4 {
+		t.Fatal("invalid default max active DNS lookups")
+	}
+	if c.maxRuntime() != 60*time.Second {
+		t.Fatal("invalid default max runtime")
+	}
+}
+
+func TestMeasurerRun(t *testing.T) {
+	// runHelper is an helper function to run this set of tests.
+	runHelper := func(ctx context.Context, input string) (*model.Measurement, error) {
+		m := NewExperimentMeasurer(Config{})
+		if m.ExperimentName() != "dslrun" {
+			t.Fatal("invalid experiment name")
+		}
+		if m.ExperimentVersion() != "0.1.0" {
+			t.Fatal("invalid experiment version")
+		}
+		meas := &model.Measurement{
+			Input:                     model.MeasurementInput(input),
+			MeasurementStartTimeSaved: time.Now(),
+		}
+		sess := &mocks.Session{
+			MockLogger: func() model.Logger { return model.DiscardLogger },
+		}
+		args := &model.ExperimentArgs{
+			Callbacks:   model.NewPrinterCallbacks(model.DiscardLogger),
+			Measurement: meas,
+			Session:     sess,
+		}
+		err := m.Run(ctx, args)
+		return meas, err
+	}
+
+	t.Run("with empty input", func(t *testing.T) {
+		_, err := runHelper(context.Background(), "")
+		if !errors.Is(err, errNoInputProvided) {
+			t.Fatal("unexpected error", err)
+		}
+	})
+
+	t.Run("with invalid programs", func(t *testing.T) {
+		inputs := []string{
+			`{`,
+			`{"version": 2, "stages": []}`,
+			`{"version": 1, "stages": []}`,
+			`{"version": 1, "stages": [], "antani": true}`,
+			`{"version": 1, "stages": [{"name": "antani", "value": {}}]}`,
+			`{"version": 1, "stages": [{"name": "drop", "value": {"input": "x", "output": "y"}}]}`,
+			`{"version": 1, "stages": [{"name": "getaddrinfo", "value": {"domain": "x", "output": "y", "antani": 1}}]}`,
+		}
+		for _, input := range inputs {
+			meas, err := runHelper(context.Background(), input)
+			if !errors.Is(err, errInvalidProgram) {
+				t.Fatal("unexpected error", err, "for", input)
+			}
+			if meas.TestKeys != nil {
+				t.Fatal("expected nil test keys for", input)
+			}
+		}
+	})
+
+	t.Run("with unsupported program version", func(t *testing.T) {
+		_, err := runHelper(context.Background(), `{"version": 2, "stages": []}`)
+		if err == nil || !strings.Contains(err.Error(), dsljson.ErrUnsupportedProgramVersion.Error()) {
+			t.Fatal("unexpected error", err)
+		}
+	})
+
+	t.Run("with netem: without censorship", func(t *testing.T) {
+		env := netemx.MustNewScenario(netemx.InternetScenario)
+		defer env.Close()
+
+		env.Do(func() {
+			meas, err := runHelper(context.Background(), program)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tk := meas.TestKeys.(*TestKeys)
+			if tk.Failure != nil {
+				t.Fatal("unexpected failure", *tk.Failure)
+			}
+			if tk.ProgramVersion != 1 || len(tk.ProgramSHA256) != 64 {
+				t.Fatal("unexpected program metadata", tk.ProgramVersion, tk.ProgramSHA256)
+			}
+			if len(tk.Queries) <= 0 {
+				t.Fatal("expected queries")
+			}
+			if len(tk.TCPConnect) != 1 || tk.TCPConnect[0].Status.Failure != nil {
+				t.Fatal("unexpected tcp_connect", tk.TCPConnect)
+			}
+			if len(tk.TLSHandshakes) != 1 || tk.TLSHandshakes[0].Failure != nil {
+				t.Fatal("unexpected tls_handshakes", tk.TLSHandshakes)
+			}
+			if len(tk.Requests) != 1 || tk.Requests[0].Failure != nil {
+				t.Fatal("unexpected requests", tk.Requests)
+			}
+		})
+	})
+
+	t.Run("with netem: with RST on the SNI", func(t *testing.T) {
+		env := netemx.MustNewScenario(netemx.InternetScenario)
+		defer env.Close()
+
+		env.DPIEngine().AddRule(&netem.DPIResetTrafficForTLSSNI{
+			Logger: model.DiscardLogger,
+			SNI:    "www.example.com",
+		})
+
+		env.Do(func() {
+			meas, err := runHelper(context.Background(), program)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tk := meas.TestKeys.(*TestKeys)
+			if tk.Failure != nil {
+				t.Fatal("unexpected failure", *tk.Failure)
+			}
+			if len(tk.TLSHandshakes) != 1 || tk.TLSHandshakes[0].Failure == nil {
+				t.Fatal("unexpected tls_handshakes", tk.TLSHandshakes)
+			}
+			if *tk.TLSHandshakes[0].Failure != "connection_reset" {
+				t.Fatal("unexpected failure", *tk.TLSHandshakes[0].Failure)
+			}
+			if len(tk.Requests) != 0 {
+				t.Fatal("expected no requests", tk.Requests)
+			}
+		})
+	})
+}
@@ -0,0 +1,167 @@
+// Package jsscript contains the jsscript experiment.
+//
+// This experiment runs a measurement script written in JavaScript inside a
+// sandboxed goja VM. The script cannot access the file system and can only
+// use the `_measure` native module, which allows it to perform DNS lookups,
+// TCP connects, TLS and QUIC handshakes, and HTTP GETs, to sleep, and to emit
+// test keys. We bound the CPU time, wall clock time, and memory used by
+// the script. We archive the script name, version, and SHA256 inside the
+// measurement annotations, so that we can always tell which script
+// produced a given measurement.
+//
+// The script must export the `experimentName`, `experimentVersion`, and
+// `run` functions. The `run` function receives the measurement input,
+// which may be empty, and emits test keys using `_measure.emit`.
+package jsscript
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ooni/probe-cli/v3/internal/measurexlite"
+	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/x/dsljavascript"
+	"github.com/ooni/probe-cli/v3/internal/x/dslvm"
+)
+
+const (
+	testName    = "jsscript"
+	testVersion = "0.1.0"
+)
+
+// Config contains the experiment config.
+type Config struct {
+	// MaxCPUTime is the maximum CPU time in milliseconds.
+	MaxCPUTime int64 `ooni:"maximum time spent running JavaScript code in milliseconds"`
+
+	// MaxHeapGrowth is the maximum process heap growth in MiB.
+	MaxHeapGrowth int64 `ooni:"maximum process heap growth while running the script in MiB (approximate)"`
+
+	// MaxRuntime is the maximum runtime in seconds.
+	MaxRuntime int64 `ooni:"maximum runtime in seconds"`
+
+	// RandSeed is the seed for Math.random.
+	RandSeed int64 `ooni:"seed for Math.random making the script deterministic"`
+
+	// ScriptPath is the path of the script to run.
+	ScriptPath string `ooni:"path of the JavaScript measurement script to run"`
+}
+
+func (c *Config) maxCPUTime() time.Duration {
+	if c.MaxCPUTime > 0 {
+		return time.Duration(c.MaxCPUTime) * time.Millisecond
+	}
+	return 5 * time.Second
+}
+
+func (c *Config) maxHeapGrowth() uint64 {
+	if c.MaxHeapGrowth > 0 {
+		return uint64(c.MaxHeapGrowth) << 20
+	}
+	return 256 << 20
+}
+
+func (c *Config) maxRuntime() time.Duration {
+	if c.MaxRuntime > 0 {
+		return time.Duration(c.MaxRuntime) * time.Second
+	}
+	return 60 * time.Second
+}
+
+// TestKeys contains the experiment's result.
+type TestKeys struct {
+	// Observations contains the observations collected by the script.
+	*dslvm.Observations
+
+	// Script contains the test keys emitted by the script.
+	Script map[string]any `json:"script"`
+
+	// Failure is the failure that prevented the script from completing, if any.
+	Failure *string `json:"failure"`
+}
+
+// Measurer performs the measurement.
+type Measurer struct {
+	config Config
+}
+
+// ExperimentName implements ExperimentMeasurer.ExperimentName.
+func (m *Measurer) ExperimentName() string {
+	return testName
+}
+
+// ExperimentVersion implements ExperimentMeasurer.ExperimentVersion.
+func (m *Measurer) ExperimentVersion() string {
+	return testVersion
+}
+
+var (
+	// errNoScriptPath indicates you didn't configure the script path
+	errNoScriptPath = errors.New("jsscript: no script path provided")
+
+	// errInvalidScript indicates that we cannot load the script
+	errInvalidScript = errors.New("jsscript: invalid script")
+)
+
+// Run implements ExperimentMeasurer.Run.
+func (m *Measurer) Run(ctx context.Context, args *model.ExperimentArgs) error {
+	measurement := args.Measurement
+	logger := args.Session.Logger()
+	if m.config.ScriptPath == "" {
+		return errNoScriptPath
+	}
+
+	// read the script and compute its hash
+	source, err := os.ReadFile(m.config.ScriptPath)
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256(source)
+
+	// load the script inside a sandboxed VM
+	config := &dsljavascript.VMConfig{
+		Logger:        logger,
+		MaxCPUTime:    m.config.maxCPUTime(),
+		MaxHeapGrowth: m.config.maxHeapGrowth(),
+		MaxRuntime:    m.config.maxRuntime(),
+		RandSeed:      m.config.RandSeed,
+		Sandboxed:     true,
+		ZeroTime:      measurement.MeasurementStartTimeSaved,
+	}
+	vm, err := dsljavascript.LoadExperimentSource(config, m.config.ScriptPath, source)
+	if err != nil {
+		return fmt.Errorf("%w: %s", errInvalidScript, err.Error())
+	}
+	name, err := vm.ExperimentName()
+	if err != nil {
+		return fmt.Errorf("%w: %s", errInvalidScript, err.Error())
+	}
+	version, err := vm.ExperimentVersion()
+	if err != nil {
+		return fmt.Errorf("%w: %s", errInvalidScript, err.Error())
+	}
+
+	// archive the script metadata
+	measurement.AddAnnotation("jsscript_name", name)
+	measurement.AddAnnotation("jsscript_sha256", hex.EncodeToString(digest[:]))
+	measurement.AddAnnotation("jsscript_version", version)
+
+	// run the script and collect the results
+	err = vm.RunContext(ctx, string(measurement.Input))
+	measurement.TestKeys = &TestKeys{
+		Observations: vm.Observations(),
+		Script:       vm.TestKeys(),
+		Failure:      measurexlite.NewFailure(err),
+	}
+	return nil // we want to submit this measurement
+}
+
+// NewExperimentMeasurer creates a new ExperimentMeasurer.
+func NewExperimentMeasurer(config Config) model.ExperimentMeasurer {
+	return &Measurer{config: config}
+}
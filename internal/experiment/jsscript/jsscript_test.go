@@ -0,0 +1,292 @@
+package jsscript
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ooni/netem"
+	"github.com/ooni/probe-cli/v3/internal/mocks"
+	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/netemx"
+)
+
+// script is the script we use for testing.
+const script = `
+const m = require("_measure");
+
+exports.experimentName = function () {
+	return "example";
+};
+
+exports.experimentVersion = function () {
+	return "0.2.0";
+};
+
+exports.run = function (input) {
+	const lookup = m.lookupHost(input);
+	m.emit("lookup_failure", lookup.failure);
+	if (lookup.failure !== null) {
+		return;
+	}
+	const endpoint = lookup.addresses[0] + ":443";
+	m.emit("tcp_failure", m.tcpConnect(endpoint).failure);
+	m.emit("tls_failure", m.tlsHandshake(endpoint, {sni: input, alpn: ["h2"]}).failure);
+	m.emit("quic_failure", m.quicHandshake(endpoint, {sni: input}).failure);
+	const resp = m.httpGet("https://" + input + "/", {address: lookup.addresses[0]});
+	m.emit("http_failure", resp.failure);
+	m.emit("http_status_code", resp.statusCode);
+	m.emit("random", Math.random());
+};
+`
+
+// writeScript writes the given script inside a temporary directory.
+func writeScript(t *testing.T, content string) string {
+	filename := filepath.Join(t.TempDir(), "script.js")
+	if err := os.WriteFile(filename, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+// newScript returns a script whose run function contains the given body.
+func newScript(body string) string {
+	return `
+const m = require("_measure");
+exports.experimentName = function () { return "example"; };
+exports.experimentVersion = function () { return "0.2.0"; };
+exports.run = function (input) {` + body + `};
+`
+}
+
+func TestConfig(t *testing.T) {
+	c := Config{}
+	if c.maxCPUTime() != 5*time.Second {
+		t.Fatal("invalid default max CPU time")
+	}
+	if c.maxHeapGrowth() != 256<<20 {
+		t.Fatal("invalid default max memory")
+	}
+	if c.maxRuntime() != 60*time.Second {
+		t.Fatal("invalid default max runtime")
+	}
+}
+
+func TestMeasurerRun(t *testing.T) {
+	// runHelper is an helper function to run this set of tests.
+	runHelper := func(ctx context.Context, config Config, input string) (*model.Measurement, error) {
+		m := NewExperimentMeasurer(config)
+		if m.ExperimentName() != "jsscript" {
+			t.Fatal("invalid experiment name")
+		}
+		if m.ExperimentVersion() != "0.1.0" {
+			t.Fatal("invalid experiment version")
+		}
+		meas := &model.Measurement{
+			Input:                     model.MeasurementInput(input),
+			MeasurementStartTimeSaved: time.Now(),
+		}
+		sess := &mocks.Session{
+			MockLogger: func() model.Logger { return model.DiscardLogger },
+		}
+		args := &model.ExperimentArgs{
+			Callbacks:   model.NewPrinterCallbacks(model.DiscardLogger),
+			Measurement: meas,
+			Session:     sess,
+		}
+		err := m.Run(ctx, args)
+		return meas, err
+	}
+
+	t.Run("without script path", func(t *testing.T) {
+		_, err := runHelper(context.Background(), Config{}, "")
+		if !errors.Is(err, errNoScriptPath) {
+			t.Fatal("unexpected error", err)
+		}
+	})
+
+	t.Run("with nonexistent script", func(t *testing.T) {
+		config := Config{ScriptPath: filepath.Join(t.TempDir(), "nonexistent.js")}
+		_, err := runHelper(context.Background(), config, "")
+		if !errors.Is(err, os.ErrNotExist) {
+			t.Fatal("unexpected error", err)
+		}
+	})
+
+	t.Run("with invalid scripts", func(t *testing.T) {
+		scripts := []string{
+			`exports.run = function (input) {`,
+			`require("./other.js");`,
+			`require("fs");`,
+			`require("_ooni");`,
+			`exports.experimentVersion = function () { return "0.1.0"; };`,
+			`exports.experimentName = function () { return "example"; };`,
+		}
+		for _, content := range scripts {
+			config := Config{ScriptPath: writeScript(t, content)}
+			meas, err := runHelper(context.Background(), config, "")
+			if !errors.Is(err, errInvalidScript) {
+				t.Fatal("unexpected error", err, "for", content)
+			}
+			if meas.TestKeys != nil {
+				t.Fatal("expected nil test keys for", content)
+			}
+		}
+	})
+
+	t.Run("with a script that throws", func(t *testing.T) {
+		config := Config{ScriptPath: writeScript(t, newScript(`m.emit("x", 1); throw "antani";`))}
+		meas, err := runHelper(context.Background(), config, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		tk := meas.TestKeys.(*TestKeys)
+		if tk.Failure == nil || !strings.Contains(*tk.Failure, "antani") {
+			t.Fatal("unexpected failure", tk.Failure)
+		}
+		if tk.Script["x"] != int64(1) {
+			t.Fatal("expected to see the keys emitted before throwing", tk.Script)
+		}
+	})
+
+	t.Run("with the CPU time limit", func(t *testing.T) {
+		config := Config{
+			MaxCPUTime: 100,
+			ScriptPath: writeScript(t, newScript(`while (true) {}`)),
+		}
+		meas, err := runHelper(context.Background(), config, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		tk := meas.TestKeys.(*TestKeys)
+		if tk.Failure == nil || !strings.Contains(*tk.Failure, "CPU time limit exceeded") {
+			t.Fatal("unexpected failure", tk.Failure)
+		}
+	})
+
+	t.Run("with the heap growth limit", func(t *testing.T) {
+		config := Config{
+			MaxHeapGrowth: 16,
+			ScriptPath:    writeScript(t, newScript(`const v = []; while (true) { v.push("x".repeat(1024) + v.length); }`)),
+		}
+		meas, err := runHelper(context.Background(), config, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		tk := meas.TestKeys.(*TestKeys)
+		if tk.Failure == nil || !strings.Contains(*tk.Failure, "heap growth limit exceeded") {
+			t.Fatal("unexpected failure", tk.Failure)
+		}
+	})
+
+	t.Run("with the time limit", func(t *testing.T) {
+		config := Config{
+			MaxRuntime: 1,
+			ScriptPath: writeScript(t, newScript(`while (true) { m.sleep(100); }`)),
+		}
+		meas, err := runHelper(context.Background(), config, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		tk := meas.TestKeys.(*TestKeys)
+		if tk.Failure == nil || !strings.Contains(*tk.Failure, "time limit exceeded") {
+			t.Fatal("unexpected failure", tk.Failure)
+		}
+	})
+
+	t.Run("with netem: without censorship", func(t *testing.T) {
+		env := netemx.MustNewScenario(netemx.InternetScenario)
+		defer env.Close()
+
+		env.Do(func() {
+			config := Config{RandSeed: 4, ScriptPath: writeScript(t, script)}
+			meas, err := runHelper(context.Background(), config, "www.example.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if meas.Annotations["jsscript_name"] != "example" {
+				t.Fatal("unexpected script name", meas.Annotations)
+			}
+			if meas.Annotations["jsscript_version"] != "0.2.0" {
+				t.Fatal("unexpected script version", meas.Annotations)
+			}
+			if len(meas.Annotations["jsscript_sha256"]) != 64 {
+				t.Fatal("unexpected script sha256", meas.Annotations)
+			}
+			tk := meas.TestKeys.(*TestKeys)
+			if tk.Failure != nil {
+				t.Fatal("unexpected failure", *tk.Failure)
+			}
+			for _, key := range []string{"lookup_failure", "tcp_failure", "tls_failure", "quic_failure", "http_failure"} {
+				if tk.Script[key] != nil {
+					t.Fatal("unexpected", key, tk.Script[key])
+				}
+			}
+			if tk.Script["http_status_code"] != int64(200) {
+				t.Fatal("unexpected status code", tk.Script["http_status_code"])
+			}
+			if len(tk.Queries) <= 0 {
+				t.Fatal("expected queries")
+			}
+			if len(tk.TCPConnect) != 3 {
+				t.Fatal("unexpected tcp_connect", tk.TCPConnect)
+			}
+			if len(tk.TLSHandshakes) != 2 {
+				t.Fatal("unexpected tls_handshakes", tk.TLSHandshakes)
+			}
+			if len(tk.QUICHandshakes) != 1 {
+				t.Fatal("unexpected quic_handshakes", tk.QUICHandshakes)
+			}
+			if len(tk.Requests) != 1 || tk.Requests[0].Failure != nil {
+				t.Fatal("unexpected requests", tk.Requests)
+			}
+
+			// make sure that we can replay the script deterministically
+			again, err := runHelper(context.Background(), config, "www.example.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tk.Script["random"] != again.TestKeys.(*TestKeys).Script["random"] {
+				t.Fatal("expected Math.random to be deterministic")
+			}
+		})
+	})
+
+	t.Run("with netem: with RST on the SNI", func(t *testing.T) {
+		env := netemx.MustNewScenario(netemx.InternetScenario)
+		defer env.Close()
+
+		env.DPIEngine().AddRule(&netem.DPIResetTrafficForTLSSNI{
+			Logger: model.DiscardLogger,
+			SNI:    "www.example.com",
+		})
+
+		env.Do(func() {
+			config := Config{ScriptPath: writeScript(t, script)}
+			meas, err := runHelper(context.Background(), config, "www.example.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+			tk := meas.TestKeys.(*TestKeys)
+			if tk.Failure != nil {
+				t.Fatal("unexpected failure", *tk.Failure)
+			}
+			if tk.Script["tcp_failure"] != nil {
+				t.Fatal("unexpected tcp_failure", tk.Script["tcp_failure"])
+			}
+			if tk.Script["tls_failure"] != "connection_reset" {
+				t.Fatal("unexpected tls_failure", tk.Script["tls_failure"])
+			}
+			if tk.Script["http_failure"] != "connection_reset" {
+				t.Fatal("unexpected http_failure", tk.Script["http_failure"])
+			}
+			if len(tk.Requests) != 0 {
+				t.Fatal("expected no requests", tk.Requests)
+			}
+		})
+	})
+}
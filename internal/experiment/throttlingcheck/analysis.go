@@ -0,0 +1,216 @@
+package throttlingcheck
+
+import (
+	"math"
+	"sort"
+
+	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/throttling"
+)
+
+const (
+	// minSpeedSamples is the minimum number of speed samples we need
+	// for each speed curve to say something meaningful.
+	minSpeedSamples = 4
+
+	// significanceLevel is the p-value below which we consider
+	// the target significantly slower than the control.
+	significanceLevel = 0.01
+
+	// slowdownThreshold is the target/control median speed ratio
+	// below which we consider the slowdown to be relevant.
+	slowdownThreshold = 0.5
+)
+
+// SpeedSample is a sample of a speed curve.
+type SpeedSample struct {
+	// T is the end of the sampling interval, measured in seconds
+	// since the beginning of the measurement.
+	T float64 `json:"t"`
+
+	// Speed is the average speed in kbit/s during the interval.
+	Speed float64 `json:"speed"`
+}
+
+// Analysis compares the target and control speed curves.
+type Analysis struct {
+	// ControlMedianSpeed is the median control speed in kbit/s.
+	ControlMedianSpeed float64 `json:"control_median_speed"`
+
+	// TargetMedianSpeed is the median target speed in kbit/s.
+	TargetMedianSpeed float64 `json:"target_median_speed"`
+
+	// SlowdownRatio is the target/control median speed ratio.
+	SlowdownRatio float64 `json:"slowdown_ratio"`
+
+	// PValue is the one-sided Mann-Whitney U test p-value for the
+	// hypothesis that target speeds are lower than control speeds.
+	PValue float64 `json:"p_value"`
+
+	// ThrottlingDetected is nil when the analysis is inconclusive.
+	ThrottlingDetected *bool `json:"throttling_detected"`
+}
+
+// newSpeedCurve computes the speed curve from the bytes_received_cumulative
+// samples collected by the [throttling.Sampler], ignoring the samples taken
+// before the handshake completed at the given time, when we were not downloading.
+func newSpeedCurve(events []*model.ArchivalNetworkEvent, handshakeTime float64) []*SpeedSample {
+	// sum the cumulative counters of all the endpoints at each sampling time
+	cumulative := map[float64]int64{}
+	for _, ev := range events {
+		if ev.Operation != throttling.BytesReceivedCumulativeOperation {
+			continue
+		}
+		if ev.T < handshakeTime {
+			continue
+		}
+		cumulative[ev.T] += ev.NumBytes
+	}
+	times := []float64{}
+	for t := range cumulative {
+		times = append(times, t)
+	}
+	sort.Float64s(times)
+
+	// compute the average speed between consecutive samples
+	curve := []*SpeedSample{}
+	for idx := 1; idx < len(times); idx++ {
+		elapsed := times[idx] - times[idx-1]
+		if elapsed <= 0 {
+			continue
+		}
+		delta := cumulative[times[idx]] - cumulative[times[idx-1]]
+		curve = append(curve, &SpeedSample{
+			T:     times[idx],
+			Speed: float64(delta*8) / 1000 / elapsed,
+		})
+	}
+	return curve
+}
+
+// analyze compares the target and control downloads using the given protocol.
+func analyze(downloads []*Download, proto string) *Analysis {
+	var target, control *Download
+	for _, dl := range downloads {
+		if dl.Protocol != proto {
+			continue
+		}
+		switch dl.Role {
+		case roleTarget:
+			target = dl
+		case roleControl:
+			control = dl
+		}
+	}
+	if target == nil || control == nil {
+		return nil
+	}
+
+	targetSpeeds, controlSpeeds := speedsOf(target.SpeedCurve), speedsOf(control.SpeedCurve)
+	analysis := &Analysis{
+		ControlMedianSpeed: median(controlSpeeds),
+		TargetMedianSpeed:  median(targetSpeeds),
+		SlowdownRatio:      0,
+		PValue:             1,
+		ThrottlingDetected: nil,
+	}
+
+	// a failure is blocking rather than throttling, so we cannot say anything
+	if target.Failure != nil || control.Failure != nil {
+		return analysis
+	}
+	if len(targetSpeeds) < minSpeedSamples || len(controlSpeeds) < minSpeedSamples {
+		return analysis
+	}
+	if analysis.ControlMedianSpeed <= 0 {
+		return analysis
+	}
+
+	analysis.SlowdownRatio = analysis.TargetMedianSpeed / analysis.ControlMedianSpeed
+	analysis.PValue = mannWhitneyLess(targetSpeeds, controlSpeeds)
+	detected := analysis.PValue < significanceLevel && analysis.SlowdownRatio < slowdownThreshold
+	analysis.ThrottlingDetected = &detected
+	return analysis
+}
+
+// mergeVerdicts returns true if any analysis detected throttling, false if
+// all the conclusive analyses did not, and nil if none was conclusive.
+func mergeVerdicts(analyses ...*Analysis) *bool {
+	var verdict *bool
+	for _, a := range analyses {
+		if a == nil || a.ThrottlingDetected == nil {
+			continue
+		}
+		if *a.ThrottlingDetected {
+			return a.ThrottlingDetected
+		}
+		verdict = a.ThrottlingDetected
+	}
+	return verdict
+}
+
+// speedsOf returns the speeds in a speed curve.
+func speedsOf(curve []*SpeedSample) (out []float64) {
+	for _, s := range curve {
+		out = append(out, s.Speed)
+	}
+	return
+}
+
+// median returns the median of the given values or zero.
+func median(values []float64) float64 {
+	if len(values) <= 0 {
+		return 0
+	}
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+// mannWhitneyLess returns the p-value of the one-sided Mann-Whitney U test
+// with alternative hypothesis "x tends to be smaller than y", using the normal
+// approximation with continuity correction and average ranks for ties.
+func mannWhitneyLess(x, y []float64) float64 {
+	type value struct {
+		v     float64
+		fromX bool
+	}
+	all := []value{}
+	for _, v := range x {
+		all = append(all, value{v, true})
+	}
+	for _, v := range y {
+		all = append(all, value{v, false})
+	}
+	sort.SliceStable(all, func(i, j int) bool { return all[i].v < all[j].v })
+
+	// compute the sum of the ranks of x, assigning average ranks to ties
+	var rankSumX float64
+	for i := 0; i < len(all); {
+		j := i
+		for j < len(all) && all[j].v == all[i].v {
+			j++
+		}
+		rank := float64(i+j+1) / 2 // average of the 1-based ranks i+1..j
+		for k := i; k < j; k++ {
+			if all[k].fromX {
+				rankSumX += rank
+			}
+		}
+		i = j
+	}
+
+	nx, ny := float64(len(x)), float64(len(y))
+	u := rankSumX - nx*(nx+1)/2
+	mean := nx * ny / 2
+	sd := math.Sqrt(nx * ny * (nx + ny + 1) / 12)
+	if sd <= 0 {
+		return 1
+	}
+	z := (u - mean + 0.5) / sd
+	return 0.5 * math.Erfc(-z/math.Sqrt2)
+}
@@ -0,0 +1,166 @@
+package throttlingcheck
+
+import (
+	"math"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/throttling"
+)
+
+func TestNewSpeedCurve(t *testing.T) {
+	events := []*model.ArchivalNetworkEvent{{
+		Operation: throttling.BytesReceivedCumulativeOperation,
+		NumBytes:  0, // taken before the handshake, must be ignored
+		T:         0.5,
+	}, {
+		Operation: throttling.BytesReceivedCumulativeOperation,
+		NumBytes:  1000,
+		T:         1.0,
+	}, {
+		Operation: "read", // must be ignored
+		NumBytes:  1 << 20,
+		T:         1.5,
+	}, {
+		Operation: throttling.BytesReceivedCumulativeOperation,
+		NumBytes:  3000,
+		T:         2.0,
+	}, {
+		Operation: throttling.BytesReceivedCumulativeOperation,
+		NumBytes:  3000,
+		T:         2.5,
+	}}
+	expect := []*SpeedSample{{
+		T:     2.0,
+		Speed: 16, // 2000 bytes in 1 second
+	}, {
+		T:     2.5,
+		Speed: 0,
+	}}
+	if diff := cmp.Diff(expect, newSpeedCurve(events, 0.75)); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+func TestMedian(t *testing.T) {
+	cases := []struct {
+		values []float64
+		expect float64
+	}{
+		{nil, 0},
+		{[]float64{3, 1, 2}, 2},
+		{[]float64{4, 1, 3, 2}, 2.5},
+	}
+	for _, tc := range cases {
+		if got := median(tc.values); got != tc.expect {
+			t.Fatal("expected", tc.expect, "got", got)
+		}
+	}
+}
+
+func TestMannWhitneyLess(t *testing.T) {
+	t.Run("when x is clearly smaller than y", func(t *testing.T) {
+		x := []float64{1, 2, 3, 4, 5, 6, 7, 8}
+		y := []float64{100, 110, 120, 130, 140, 150, 160, 170}
+		if p := mannWhitneyLess(x, y); p >= significanceLevel {
+			t.Fatal("expected a small p-value, got", p)
+		}
+	})
+
+	t.Run("when x is clearly larger than y", func(t *testing.T) {
+		x := []float64{100, 110, 120, 130, 140, 150, 160, 170}
+		y := []float64{1, 2, 3, 4, 5, 6, 7, 8}
+		if p := mannWhitneyLess(x, y); p < 0.99 {
+			t.Fatal("expected a large p-value, got", p)
+		}
+	})
+
+	t.Run("when all values are tied", func(t *testing.T) {
+		x := []float64{5, 5, 5, 5}
+		y := []float64{5, 5, 5, 5}
+		if p := mannWhitneyLess(x, y); math.Abs(p-0.5) > 0.1 {
+			t.Fatal("expected a p-value close to 0.5, got", p)
+		}
+	})
+
+	t.Run("with empty inputs", func(t *testing.T) {
+		if p := mannWhitneyLess(nil, nil); p != 1 {
+			t.Fatal("expected 1, got", p)
+		}
+	})
+}
+
+func TestAnalyze(t *testing.T) {
+	newCurve := func(speeds ...float64) (out []*SpeedSample) {
+		for idx, speed := range speeds {
+			out = append(out, &SpeedSample{T: float64(idx), Speed: speed})
+		}
+		return
+	}
+	slow := newCurve(10, 12, 9, 11, 10, 13, 8, 10)
+	fast := newCurve(1000, 1100, 900, 1050, 980, 1020, 990, 1010)
+
+	t.Run("when there are no downloads for the protocol", func(t *testing.T) {
+		if analyze([]*Download{{Protocol: "tcp", Role: roleTarget}}, "quic") != nil {
+			t.Fatal("expected nil")
+		}
+	})
+
+	t.Run("when the target is much slower than the control", func(t *testing.T) {
+		a := analyze([]*Download{
+			{Protocol: "tcp", Role: roleTarget, SpeedCurve: slow},
+			{Protocol: "tcp", Role: roleControl, SpeedCurve: fast},
+		}, "tcp")
+		if a.ThrottlingDetected == nil || !*a.ThrottlingDetected {
+			t.Fatal("expected throttling to be detected")
+		}
+		if a.SlowdownRatio >= slowdownThreshold {
+			t.Fatal("unexpected slowdown ratio", a.SlowdownRatio)
+		}
+	})
+
+	t.Run("when the target and the control have the same speed", func(t *testing.T) {
+		a := analyze([]*Download{
+			{Protocol: "tcp", Role: roleTarget, SpeedCurve: fast},
+			{Protocol: "tcp", Role: roleControl, SpeedCurve: fast},
+		}, "tcp")
+		if a.ThrottlingDetected == nil || *a.ThrottlingDetected {
+			t.Fatal("expected throttling not to be detected")
+		}
+	})
+
+	t.Run("when there are too few samples", func(t *testing.T) {
+		a := analyze([]*Download{
+			{Protocol: "tcp", Role: roleTarget, SpeedCurve: slow[:2]},
+			{Protocol: "tcp", Role: roleControl, SpeedCurve: fast},
+		}, "tcp")
+		if a.ThrottlingDetected != nil {
+			t.Fatal("expected inconclusive analysis")
+		}
+	})
+
+	t.Run("when a download failed", func(t *testing.T) {
+		failure := "connection_reset"
+		a := analyze([]*Download{
+			{Protocol: "tcp", Role: roleTarget, SpeedCurve: slow, Failure: &failure},
+			{Protocol: "tcp", Role: roleControl, SpeedCurve: fast},
+		}, "tcp")
+		if a.ThrottlingDetected != nil {
+			t.Fatal("expected inconclusive analysis")
+		}
+	})
+}
+
+func TestMergeVerdicts(t *testing.T) {
+	yes, no := true, false
+	if mergeVerdicts(nil, &Analysis{}) != nil {
+		t.Fatal("expected nil")
+	}
+	if v := mergeVerdicts(&Analysis{ThrottlingDetected: &no}, nil); v == nil || *v {
+		t.Fatal("expected false")
+	}
+	if v := mergeVerdicts(&Analysis{ThrottlingDetected: &no}, &Analysis{ThrottlingDetected: &yes}); v == nil || !*v {
+		t.Fatal("expected true")
+	}
+}
@@ -0,0 +1,255 @@
+package throttlingcheck
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/ooni/probe-cli/v3/internal/logx"
+	"github.com/ooni/probe-cli/v3/internal/measurexlite"
+	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/netxlite"
+	"github.com/ooni/probe-cli/v3/internal/throttling"
+	"github.com/quic-go/quic-go"
+)
+
+const (
+	// roleTarget is the role of downloads using the target SNI.
+	roleTarget = "target"
+
+	// roleControl is the role of downloads using the control SNI.
+	roleControl = "control"
+)
+
+// errMaxDownloadTime is the cause of the download context expiring
+// because we reached the maximum download time.
+var errMaxDownloadTime = errors.New("throttlingcheck: reached the maximum download time")
+
+// Download contains the results of downloading the object once.
+type Download struct {
+	// Address is the endpoint address we used.
+	Address string `json:"address"`
+
+	// BodyLength is the number of response body bytes we read.
+	BodyLength int64 `json:"body_length"`
+
+	// Failure is the failure that occurred, if any. Reaching the maximum
+	// download time after the handshake is not a failure.
+	Failure *string `json:"failure"`
+
+	// NetworkEvents contains the network events, including the
+	// bytes_received_cumulative samples collected while downloading.
+	NetworkEvents []*model.ArchivalNetworkEvent `json:"network_events"`
+
+	// Protocol is either "tcp" or "quic".
+	Protocol string `json:"protocol"`
+
+	// Requests contains the HTTP request we sent.
+	Requests []*model.ArchivalHTTPRequestResult `json:"requests"`
+
+	// Role is either "target" or "control".
+	Role string `json:"role"`
+
+	// SNI is the SNI we used.
+	SNI string `json:"sni"`
+
+	// SpeedCurve is the speed curve computed from the samples.
+	SpeedCurve []*SpeedSample `json:"speed_curve"`
+
+	// TCPConnect is the TCP connect result (only for TCP).
+	TCPConnect *model.ArchivalTCPConnectResult `json:"tcp_connect"`
+
+	// TLSHandshake is the TLS or QUIC handshake result.
+	TLSHandshake *model.ArchivalTLSOrQUICHandshakeResult `json:"tls_handshake"`
+
+	// Truncated indicates we stopped the HTTP transaction because
+	// we reached the maximum download time.
+	Truncated bool `json:"truncated"`
+}
+
+// downloader downloads the target object once.
+type downloader struct {
+	address  string
+	config   *Config
+	index    int64
+	logger   model.Logger
+	proto    string
+	role     string
+	target   *url.URL
+	zeroTime time.Time
+}
+
+// sni returns the SNI to use given the downloader role.
+func (d *downloader) sni() string {
+	if d.role == roleControl {
+		return d.config.controlSNI()
+	}
+	return d.target.Hostname()
+}
+
+// run performs the download and returns its results.
+func (d *downloader) run(ctx context.Context) *Download {
+	dl := &Download{
+		Address:       d.address,
+		BodyLength:    0,
+		Failure:       nil,
+		NetworkEvents: []*model.ArchivalNetworkEvent{},
+		Protocol:      d.proto,
+		Requests:      []*model.ArchivalHTTPRequestResult{},
+		Role:          d.role,
+		SNI:           d.sni(),
+		SpeedCurve:    []*SpeedSample{},
+		TCPConnect:    nil,
+		TLSHandshake:  nil,
+		Truncated:     false,
+	}
+
+	// create trace and start sampling
+	trace := measurexlite.NewTrace(d.index, d.zeroTime, d.role)
+	sampler := throttling.NewSampler(trace)
+
+	ol := logx.NewOperationLogger(d.logger, "[#%d] ThrottlingCheck: GET %s using %s/%s SNI=%s",
+		d.index, d.target.String(), d.address, d.proto, dl.SNI)
+
+	// make sure the whole download is bounded in time
+	ctx, cancel := context.WithTimeoutCause(ctx, d.config.maxDownloadTime(), errMaxDownloadTime)
+	defer cancel()
+
+	var err error
+	switch d.proto {
+	case "quic":
+		err = d.downloadQUIC(ctx, trace, dl)
+	default:
+		err = d.downloadTCP(ctx, trace, dl)
+	}
+	ol.Stop(err)
+
+	// collect the samples and compute the speed curve
+	samples := sampler.ExtractSamples()
+	_ = sampler.Close()
+	dl.NetworkEvents = append(dl.NetworkEvents, trace.NetworkEvents()...)
+	dl.NetworkEvents = append(dl.NetworkEvents, samples...)
+	dl.SpeedCurve = newSpeedCurve(samples, handshakeTime(dl))
+	dl.Failure = measurexlite.NewFailure(err)
+	return dl
+}
+
+// handshakeTime returns when the handshake completed, measured in seconds
+// since the beginning of the measurement, or zero if we did not handshake.
+func handshakeTime(dl *Download) float64 {
+	if dl.TLSHandshake == nil {
+		return 0
+	}
+	return dl.TLSHandshake.T
+}
+
+// newTLSConfig creates the TLS config to use. We cannot verify the certificate
+// for the control SNI since the server is not expected to serve it.
+func (d *downloader) newTLSConfig(alpn []string) *tls.Config {
+	// See https://github.com/ooni/probe/issues/2413 to understand
+	// why we're using nil to force netxlite to use the cached
+	// default Mozilla cert pool.
+	return &tls.Config{ // #nosec G402 - we need to use a large TLS versions range for measuring
+		InsecureSkipVerify: d.role == roleControl, // #nosec G402 - the control SNI does not match the cert
+		NextProtos:         alpn,
+		RootCAs:            nil,
+		ServerName:         d.sni(),
+	}
+}
+
+// downloadTCP downloads the object using HTTP over TLS over TCP.
+func (d *downloader) downloadTCP(ctx context.Context, trace *measurexlite.Trace, dl *Download) error {
+	dialer := trace.NewDialerWithoutResolver(d.logger)
+	conn, err := dialer.DialContext(ctx, "tcp", d.address)
+	dl.TCPConnect = trace.FirstTCPConnectOrNil()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	thx := trace.NewTLSHandshakerStdlib(d.logger)
+	tlsConn, err := thx.Handshake(ctx, conn, d.newTLSConfig([]string{"h2", "http/1.1"}))
+	dl.TLSHandshake = trace.FirstTLSHandshakeOrNil()
+	if err != nil {
+		return err
+	}
+	defer tlsConn.Close()
+
+	alpn := netxlite.MaybeTLSConnectionState(tlsConn).NegotiatedProtocol
+	txp := netxlite.NewHTTPTransportWithOptions(
+		d.logger,
+		netxlite.NewNullDialer(),
+		netxlite.NewSingleUseTLSDialer(tlsConn),
+	)
+	defer txp.CloseIdleConnections()
+	return d.httpTransaction(ctx, trace, "tcp", alpn, txp, dl)
+}
+
+// downloadQUIC downloads the object using HTTP/3.
+func (d *downloader) downloadQUIC(ctx context.Context, trace *measurexlite.Trace, dl *Download) error {
+	listener := trace.NewUDPListener()
+	dialer := trace.NewQUICDialerWithoutResolver(listener, d.logger)
+	tlsConfig := d.newTLSConfig([]string{"h3"})
+	qconn, err := dialer.DialContext(ctx, d.address, tlsConfig, &quic.Config{})
+	dl.TLSHandshake = trace.FirstQUICHandshakeOrNil()
+	if err != nil {
+		return err
+	}
+	defer measurexlite.MaybeCloseQUICConn(qconn)
+
+	alpn := qconn.ConnectionState().TLS.NegotiatedProtocol
+	txp := netxlite.NewHTTP3Transport(d.logger, netxlite.NewSingleUseQUICDialer(qconn), tlsConfig)
+	defer txp.CloseIdleConnections()
+	return d.httpTransaction(ctx, trace, "udp", alpn, txp, dl)
+}
+
+// httpTransaction sends the request and reads the body until EOF or until
+// we reach the maximum download time, which is not considered a failure. The
+// archived request still contains the error that occurred, if any.
+func (d *downloader) httpTransaction(ctx context.Context, trace *measurexlite.Trace,
+	network, alpn string, txp model.HTTPTransport, dl *Download) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", d.target.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Host", d.target.Host)
+	req.Header.Set("Accept", model.HTTPHeaderAccept)
+	req.Header.Set("Accept-Language", model.HTTPHeaderAcceptLanguage)
+	req.Header.Set("User-Agent", model.HTTPHeaderUserAgent)
+
+	started := trace.TimeSince(trace.ZeroTime())
+	resp, err := txp.RoundTrip(req)
+	if err == nil {
+		defer resp.Body.Close()
+		dl.BodyLength, err = io.Copy(io.Discard, resp.Body)
+	}
+	finished := trace.TimeSince(trace.ZeroTime())
+
+	dl.Requests = append(dl.Requests, measurexlite.NewArchivalHTTPRequestResult(
+		trace.Index(),
+		started,
+		network,
+		d.address,
+		alpn,
+		txp.Network(),
+		req,
+		resp,
+		0,
+		nil,
+		err,
+		finished,
+		trace.Tags()...,
+	))
+
+	// after the handshake, running out of time is the expected outcome
+	// of a slow download, which is what we want to measure, while the
+	// parent context being done is just an interrupted download
+	if err != nil && errors.Is(context.Cause(ctx), errMaxDownloadTime) {
+		dl.Truncated, err = true, nil
+	}
+	return err
+}
@@ -0,0 +1,199 @@
+// Package throttlingcheck contains the throttlingcheck experiment.
+//
+// This experiment downloads the same large object using the target SNI and a
+// control SNI, optionally also using QUIC, samples the bytes received while
+// downloading using [throttling.Sampler], computes speed curves, and flags
+// statistically significant slowdowns of the target compared to the control.
+//
+// The methodology is loosely based on the one used to measure Twitter's
+// throttling in Russia: https://censoredplanet.org/throttling.
+package throttlingcheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/ooni/probe-cli/v3/internal/logx"
+	"github.com/ooni/probe-cli/v3/internal/measurexlite"
+	"github.com/ooni/probe-cli/v3/internal/model"
+)
+
+const (
+	testName    = "throttlingcheck"
+	testVersion = "0.1.0"
+)
+
+// Config contains the experiment configuration.
+type Config struct {
+	// ControlSNI is the SNI to use for the control downloads.
+	ControlSNI string `ooni:"SNI to use for the control downloads"`
+
+	// MaxDownloadTime is the maximum runtime of each download (in seconds).
+	MaxDownloadTime int64 `ooni:"maximum number of seconds to spend downloading with each SNI"`
+
+	// QUIC indicates whether we should also download using HTTP/3.
+	QUIC bool `ooni:"also download the object using HTTP/3"`
+}
+
+func (c *Config) controlSNI() string {
+	if c.ControlSNI != "" {
+		return c.ControlSNI
+	}
+	return "example.org"
+}
+
+func (c *Config) maxDownloadTime() time.Duration {
+	if c.MaxDownloadTime > 0 {
+		return time.Duration(c.MaxDownloadTime) * time.Second
+	}
+	return 10 * time.Second
+}
+
+// TestKeys contains the experiment results.
+type TestKeys struct {
+	// Queries contains the DNS lookups of the target domain.
+	Queries []*model.ArchivalDNSLookupResult `json:"queries"`
+
+	// Downloads contains the results of each download.
+	Downloads []*Download `json:"downloads"`
+
+	// TCP contains the analysis of the downloads over TCP.
+	TCP *Analysis `json:"tcp"`
+
+	// QUIC contains the analysis of the downloads over QUIC (if any).
+	QUIC *Analysis `json:"quic"`
+
+	// Failure is the failure that prevented us from downloading.
+	Failure *string `json:"failure"`
+
+	// ThrottlingDetected is true if we detected throttling for either
+	// TCP or QUIC, false if we did not, and nil if we could not tell.
+	ThrottlingDetected *bool `json:"throttling_detected"`
+}
+
+// Measurer performs the measurement.
+type Measurer struct {
+	config Config
+}
+
+// ExperimentName implements ExperimentMeasurer.ExperimentName.
+func (m *Measurer) ExperimentName() string {
+	return testName
+}
+
+// ExperimentVersion implements ExperimentMeasurer.ExperimentVersion.
+func (m *Measurer) ExperimentVersion() string {
+	return testVersion
+}
+
+var (
+	// errNoInputProvided indicates you didn't provide any input
+	errNoInputProvided = errors.New("no input provided")
+
+	// errInputIsNotAnURL indicates that input is not an URL
+	errInputIsNotAnURL = errors.New("input is not an URL")
+
+	// errInvalidScheme indicates that the scheme is invalid
+	errInvalidScheme = errors.New("scheme must be https")
+
+	// errNoAddresses indicates that the DNS lookup did not return any address.
+	errNoAddresses = errors.New("dns lookup returned no addresses")
+)
+
+// Run implements ExperimentMeasurer.Run.
+func (m *Measurer) Run(ctx context.Context, args *model.ExperimentArgs) error {
+	measurement := args.Measurement
+	logger := args.Session.Logger()
+	if measurement.Input == "" {
+		return errNoInputProvided
+	}
+	parsed, err := url.Parse(string(measurement.Input))
+	if err != nil {
+		return fmt.Errorf("%w: %s", errInputIsNotAnURL, err.Error())
+	}
+	if parsed.Scheme != "https" {
+		return errInvalidScheme
+	}
+	tk := &TestKeys{
+		Queries:            []*model.ArchivalDNSLookupResult{},
+		Downloads:          []*Download{},
+		TCP:                nil,
+		QUIC:               nil,
+		Failure:            nil,
+		ThrottlingDetected: nil,
+	}
+	measurement.TestKeys = tk
+	zeroTime := measurement.MeasurementStartTimeSaved
+
+	// resolve the target domain and pick the first address
+	address, err := m.lookup(ctx, logger, zeroTime, parsed, tk)
+	if err != nil {
+		tk.Failure = measurexlite.NewFailure(err)
+		return nil // return nil so we always submit the measurement
+	}
+
+	// perform the downloads sequentially so they don't compete for bandwidth
+	var index int64
+	protocols := []string{"tcp"}
+	if m.config.QUIC {
+		protocols = append(protocols, "quic")
+	}
+	for _, proto := range protocols {
+		for _, role := range []string{roleTarget, roleControl} {
+			index++
+			d := &downloader{
+				address:  address,
+				config:   &m.config,
+				index:    index,
+				logger:   logger,
+				proto:    proto,
+				role:     role,
+				target:   parsed,
+				zeroTime: zeroTime,
+			}
+			tk.Downloads = append(tk.Downloads, d.run(ctx))
+		}
+	}
+
+	// analyze the speed curves
+	tk.TCP = analyze(tk.Downloads, "tcp")
+	if m.config.QUIC {
+		tk.QUIC = analyze(tk.Downloads, "quic")
+	}
+	tk.ThrottlingDetected = mergeVerdicts(tk.TCP, tk.QUIC)
+	return nil // return nil so we always submit the measurement
+}
+
+// lookup resolves the domain in the target URL and returns the first address.
+func (m *Measurer) lookup(ctx context.Context, logger model.Logger,
+	zeroTime time.Time, target *url.URL, tk *TestKeys) (string, error) {
+	const timeout = 4 * time.Second
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	trace := measurexlite.NewTrace(0, zeroTime)
+	ol := logx.NewOperationLogger(logger, "ThrottlingCheck: lookup %s", target.Hostname())
+	reso := trace.NewStdlibResolver(logger)
+	addrs, err := reso.LookupHost(ctx, target.Hostname())
+	tk.Queries = append(tk.Queries, trace.DNSLookupsFromRoundTrip()...)
+	ol.Stop(err)
+	if err != nil {
+		return "", err
+	}
+	if len(addrs) < 1 {
+		return "", errNoAddresses
+	}
+	port := target.Port()
+	if port == "" {
+		port = "443"
+	}
+	return net.JoinHostPort(addrs[0], port), nil
+}
+
+// NewExperimentMeasurer creates a new ExperimentMeasurer.
+func NewExperimentMeasurer(config Config) model.ExperimentMeasurer {
+	return &Measurer{config: config}
+}
@@ -0,0 +1,232 @@
+package throttlingcheck
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/ooni/netem"
+	"github.com/ooni/probe-cli/v3/internal/measurexlite"
+	"github.com/ooni/probe-cli/v3/internal/mocks"
+	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/netemx"
+)
+
+func TestConfig(t *testing.T) {
+	c := Config{}
+	if c.controlSNI() != "example.org" {
+		t.Fatal("invalid default control SNI")
+	}
+	if c.maxDownloadTime() != 10*time.Second {
+		t.Fatal("invalid default max download time")
+	}
+}
+
+func TestDownloaderHTTPTransaction(t *testing.T) {
+	// runHelper runs the HTTP transaction using a transport failing with the context error.
+	runHelper := func(ctx context.Context) (*Download, error) {
+		d := &downloader{
+			address:  "130.192.91.211:443",
+			config:   &Config{},
+			logger:   model.DiscardLogger,
+			proto:    "tcp",
+			role:     roleTarget,
+			target:   &url.URL{Scheme: "https", Host: "largefile.com", Path: "/"},
+			zeroTime: time.Now(),
+		}
+		txp := &mocks.HTTPTransport{
+			MockNetwork: func() string {
+				return "tcp"
+			},
+			MockRoundTrip: func(req *http.Request) (*http.Response, error) {
+				return nil, req.Context().Err()
+			},
+		}
+		trace := measurexlite.NewTrace(d.index, d.zeroTime)
+		dl := &Download{}
+		err := d.httpTransaction(ctx, trace, "tcp", "h2", txp, dl)
+		return dl, err
+	}
+
+	t.Run("when we reach the maximum download time", func(t *testing.T) {
+		ctx, cancel := context.WithTimeoutCause(context.Background(), 0, errMaxDownloadTime)
+		defer cancel()
+		dl, err := runHelper(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !dl.Truncated {
+			t.Fatal("expected the download to be truncated")
+		}
+		if len(dl.Requests) != 1 || dl.Requests[0].Failure == nil {
+			t.Fatal("expected to archive the request failure")
+		}
+	})
+
+	t.Run("when the parent context is canceled", func(t *testing.T) {
+		parent, cancelParent := context.WithCancel(context.Background())
+		cancelParent()
+		ctx, cancel := context.WithTimeoutCause(parent, time.Hour, errMaxDownloadTime)
+		defer cancel()
+		dl, err := runHelper(ctx)
+		if !errors.Is(err, context.Canceled) {
+			t.Fatal("unexpected error", err)
+		}
+		if dl.Truncated {
+			t.Fatal("did not expect the download to be truncated")
+		}
+	})
+
+	t.Run("when the parent context deadline expires", func(t *testing.T) {
+		parent, cancelParent := context.WithTimeout(context.Background(), 0)
+		defer cancelParent()
+		ctx, cancel := context.WithTimeoutCause(parent, time.Hour, errMaxDownloadTime)
+		defer cancel()
+		dl, err := runHelper(ctx)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatal("unexpected error", err)
+		}
+		if dl.Truncated {
+			t.Fatal("did not expect the download to be truncated")
+		}
+	})
+}
+
+func TestMeasurerRun(t *testing.T) {
+	// runHelper is an helper function to run this set of tests.
+	runHelper := func(ctx context.Context, config Config, input string) (*model.Measurement, error) {
+		m := NewExperimentMeasurer(config)
+		if m.ExperimentName() != "throttlingcheck" {
+			t.Fatal("invalid experiment name")
+		}
+		if m.ExperimentVersion() != "0.1.0" {
+			t.Fatal("invalid experiment version")
+		}
+		meas := &model.Measurement{
+			Input:                     model.MeasurementInput(input),
+			MeasurementStartTimeSaved: time.Now(),
+		}
+		sess := &mocks.Session{
+			MockLogger: func() model.Logger { return model.DiscardLogger },
+		}
+		args := &model.ExperimentArgs{
+			Callbacks:   model.NewPrinterCallbacks(model.DiscardLogger),
+			Measurement: meas,
+			Session:     sess,
+		}
+		err := m.Run(ctx, args)
+		return meas, err
+	}
+
+	t.Run("with empty input", func(t *testing.T) {
+		_, err := runHelper(context.Background(), Config{}, "")
+		if !errors.Is(err, errNoInputProvided) {
+			t.Fatal("unexpected error", err)
+		}
+	})
+
+	t.Run("with invalid URL", func(t *testing.T) {
+		_, err := runHelper(context.Background(), Config{}, "\t")
+		if !errors.Is(err, errInputIsNotAnURL) {
+			t.Fatal("unexpected error", err)
+		}
+	})
+
+	t.Run("with invalid scheme", func(t *testing.T) {
+		_, err := runHelper(context.Background(), Config{}, "http://largefile.com/")
+		if !errors.Is(err, errInvalidScheme) {
+			t.Fatal("unexpected error", err)
+		}
+	})
+
+	if testing.Short() {
+		t.Skip("skip test in short mode")
+	}
+
+	t.Run("with netem: when the DNS lookup fails", func(t *testing.T) {
+		env := netemx.MustNewScenario(netemx.InternetScenario)
+		defer env.Close()
+
+		env.Do(func() {
+			meas, err := runHelper(context.Background(), Config{}, "https://nonexistent.example/")
+			if err != nil {
+				t.Fatal(err)
+			}
+			tk := meas.TestKeys.(*TestKeys)
+			if tk.Failure == nil || *tk.Failure != "dns_nxdomain_error" {
+				t.Fatal("unexpected failure", tk.Failure)
+			}
+			if len(tk.Downloads) != 0 {
+				t.Fatal("expected no downloads")
+			}
+		})
+	})
+
+	t.Run("with netem: without DPI: expect no throttling", func(t *testing.T) {
+		env := netemx.MustNewScenario(netemx.InternetScenario)
+		defer env.Close()
+
+		env.Do(func() {
+			config := Config{MaxDownloadTime: 2, QUIC: true}
+			meas, err := runHelper(context.Background(), config, "https://largefile.com/")
+			if err != nil {
+				t.Fatal(err)
+			}
+			tk := meas.TestKeys.(*TestKeys)
+			if tk.Failure != nil {
+				t.Fatal("unexpected failure", *tk.Failure)
+			}
+			if len(tk.Downloads) != 4 {
+				t.Fatal("expected four downloads")
+			}
+			for _, dl := range tk.Downloads {
+				if dl.Failure != nil {
+					t.Fatal("unexpected failure", dl.Role, dl.Protocol, *dl.Failure)
+				}
+				if dl.BodyLength <= 0 {
+					t.Fatal("expected to receive some bytes")
+				}
+			}
+			if tk.TCP == nil || tk.QUIC == nil {
+				t.Fatal("expected both TCP and QUIC analyses")
+			}
+			if tk.ThrottlingDetected != nil && *tk.ThrottlingDetected {
+				t.Fatal("did not expect to detect throttling")
+			}
+		})
+	})
+
+	t.Run("with netem: with SNI-based throttling: expect throttling", func(t *testing.T) {
+		env := netemx.MustNewScenario(netemx.InternetScenario)
+		defer env.Close()
+
+		env.DPIEngine().AddRule(&netem.DPIThrottleTrafficForTLSSNI{
+			Delay:  300 * time.Millisecond,
+			Logger: log.Log,
+			PLR:    0.1,
+			SNI:    "largefile.com",
+		})
+
+		env.Do(func() {
+			config := Config{MaxDownloadTime: 3}
+			meas, err := runHelper(context.Background(), config, "https://largefile.com/")
+			if err != nil {
+				t.Fatal(err)
+			}
+			tk := meas.TestKeys.(*TestKeys)
+			if len(tk.Downloads) != 2 {
+				t.Fatal("expected two downloads")
+			}
+			if tk.QUIC != nil {
+				t.Fatal("did not expect a QUIC analysis")
+			}
+			if tk.ThrottlingDetected == nil || !*tk.ThrottlingDetected {
+				t.Fatalf("expected to detect throttling: %+v", tk.TCP)
+			}
+		})
+	})
+}
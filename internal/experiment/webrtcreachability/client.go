@@ -0,0 +1,217 @@
+package webrtcreachability
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/ooni/probe-cli/v3/internal/logx"
+	"github.com/ooni/probe-cli/v3/internal/measurexlite"
+	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/netxlite"
+	"github.com/pion/turn/v2"
+)
+
+const (
+	// operationConnect is the operation of establishing a TCP or TLS connection.
+	operationConnect = "connect"
+
+	// operationBindingRequest is the operation of sending a STUN binding request.
+	operationBindingRequest = "binding_request"
+
+	// operationAllocate is the operation of performing a TURN allocation.
+	operationAllocate = "allocate"
+)
+
+// Attempt is the result of a STUN or TURN operation.
+type Attempt struct {
+	// Failure is the failure that occurred, if any.
+	Failure *string `json:"failure"`
+
+	// NetworkEvents contains the network events for this transport. We only
+	// fill this field for the first attempt using each transport.
+	NetworkEvents []*model.ArchivalNetworkEvent `json:"network_events,omitempty"`
+
+	// Operation is one of "connect", "binding_request", and "allocate".
+	Operation string `json:"operation"`
+
+	// T0 is when we started the operation.
+	T0 float64 `json:"t0"`
+
+	// T is when we finished the operation.
+	T float64 `json:"t"`
+
+	// TCPConnect is the TCP connect result for TCP and TLS. We only
+	// fill this field for the first attempt using each transport.
+	TCPConnect *model.ArchivalTCPConnectResult `json:"tcp_connect,omitempty"`
+
+	// TLSHandshake is the TLS handshake result for TLS. We only
+	// fill this field for the first attempt using each transport.
+	TLSHandshake *model.ArchivalTLSOrQUICHandshakeResult `json:"tls_handshake,omitempty"`
+
+	// Transport is one of "udp", "tcp", and "tls".
+	Transport string `json:"transport"`
+}
+
+// candidateType returns the ICE candidate type gathered by a successful attempt.
+func (a *Attempt) candidateType() string {
+	if a.Operation == operationAllocate {
+		return "relay"
+	}
+	return "srflx"
+}
+
+// client performs the STUN and TURN operations using a given transport.
+type client struct {
+	address   string
+	config    *Config
+	index     int64
+	logger    model.Logger
+	target    *target
+	transport string
+	zeroTime  time.Time
+}
+
+// run performs all the operations and returns the related attempts.
+func (c *client) run(ctx context.Context) (attempts []*Attempt) {
+	// make sure the operations using this transport are bounded in time
+	const timeout = 15 * time.Second
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	trace := measurexlite.NewTrace(c.index, c.zeroTime, c.transport)
+
+	// make sure we save the network events and the connection results
+	defer func() {
+		if len(attempts) > 0 {
+			attempts[0].NetworkEvents = trace.NetworkEvents()
+			attempts[0].TCPConnect = trace.FirstTCPConnectOrNil()
+			attempts[0].TLSHandshake = trace.FirstTLSHandshakeOrNil()
+		}
+	}()
+
+	// establish the connection, if needed
+	t0 := trace.TimeSince(c.zeroTime)
+	conn, err := c.dial(ctx, trace)
+	if err != nil {
+		attempts = append(attempts, c.newAttempt(operationConnect, t0, trace, err))
+		return
+	}
+
+	// create the STUN/TURN client
+	clnt, err := turn.NewClient(&turn.ClientConfig{
+		STUNServerAddr: c.address,
+		TURNServerAddr: c.address,
+		Username:       c.config.TURNUsername,
+		Password:       c.config.TURNPassword,
+		Conn:           conn,
+	})
+	if err != nil {
+		_ = conn.Close()
+		attempts = append(attempts, c.newAttempt(operationConnect, t0, trace, err))
+		return
+	}
+
+	// close the client and the conn when the context is done, which also
+	// interrupts pending transactions, and wait for that to happen
+	done := make(chan any)
+	go func() {
+		defer close(done)
+		<-ctx.Done()
+		clnt.Close()
+		_ = conn.Close()
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	if err := clnt.Listen(); err != nil {
+		attempts = append(attempts, c.newAttempt(operationConnect, t0, trace, err))
+		return
+	}
+
+	// gather the server reflexive candidate
+	t0 = trace.TimeSince(c.zeroTime)
+	ol := logx.NewOperationLogger(c.logger, "[#%d] WebRTCReachability: binding request %s/%s",
+		c.index, c.address, c.transport)
+	_, err = clnt.SendBindingRequest()
+	ol.Stop(err)
+	attempts = append(attempts, c.newAttempt(operationBindingRequest, t0, trace, err))
+	if !c.target.isTURN() {
+		return
+	}
+
+	// gather the relayed candidate
+	t0 = trace.TimeSince(c.zeroTime)
+	ol = logx.NewOperationLogger(c.logger, "[#%d] WebRTCReachability: allocate %s/%s",
+		c.index, c.address, c.transport)
+	relayConn, err := clnt.Allocate()
+	ol.Stop(err)
+	attempts = append(attempts, c.newAttempt(operationAllocate, t0, trace, err))
+	if err == nil {
+		_ = relayConn.Close()
+	}
+	return
+}
+
+// newAttempt creates a new [*Attempt] instance.
+func (c *client) newAttempt(operation string, t0 time.Duration, trace *measurexlite.Trace, err error) *Attempt {
+	return &Attempt{
+		Failure:   newFailure(err),
+		Operation: operation,
+		T0:        t0.Seconds(),
+		T:         trace.TimeSince(c.zeroTime).Seconds(),
+		Transport: c.transport,
+	}
+}
+
+// newFailure is like [measurexlite.NewFailure] except that it maps the timeout
+// returned by pion/turn when all retransmissions fail to generic_timeout_error.
+func newFailure(err error) *string {
+	if err != nil && strings.HasPrefix(err.Error(), "all retransmissions failed") {
+		err = &netxlite.ErrWrapper{
+			Failure:    netxlite.FailureGenericTimeoutError,
+			Operation:  netxlite.TopLevelOperation,
+			WrappedErr: err,
+		}
+	}
+	return measurexlite.NewFailure(err)
+}
+
+// dial creates a [net.PacketConn] suitable for STUN and TURN using the transport.
+func (c *client) dial(ctx context.Context, trace *measurexlite.Trace) (net.PacketConn, error) {
+	if c.transport == "udp" {
+		return trace.NewUDPListener().Listen(&net.UDPAddr{IP: net.IPv4zero, Port: 0, Zone: ""})
+	}
+
+	ol := logx.NewOperationLogger(c.logger, "[#%d] WebRTCReachability: connect %s/%s",
+		c.index, c.address, c.transport)
+	dialer := trace.NewDialerWithoutResolver(c.logger)
+	conn, err := dialer.DialContext(ctx, "tcp", c.address)
+	if err != nil {
+		ol.Stop(err)
+		return nil, err
+	}
+	if c.transport == "tls" {
+		thx := trace.NewTLSHandshakerStdlib(c.logger)
+		// See https://github.com/ooni/probe/issues/2413 to understand
+		// why we're using nil to force netxlite to use the cached
+		// default Mozilla cert pool.
+		config := &tls.Config{ // #nosec G402 - we need to use a large TLS versions range for measuring
+			RootCAs:    nil,
+			ServerName: c.target.domain,
+		}
+		tlsConn, err := thx.Handshake(ctx, conn, config)
+		if err != nil {
+			conn.Close()
+			ol.Stop(err)
+			return nil, err
+		}
+		conn = tlsConn
+	}
+	ol.Stop(nil)
+	return turn.NewSTUNConn(conn), nil
+}
@@ -0,0 +1,317 @@
+// Package webrtcreachability contains the webrtcreachability experiment.
+//
+// This experiment measures whether we can use a STUN or TURN server using
+// UDP, TCP and TLS. For STUN servers, we send a binding request to gather a
+// server reflexive ICE candidate. For TURN servers, we additionally try to
+// allocate a relayed ICE candidate using the configured credentials. Then,
+// we classify the server depending on which transports succeeded.
+//
+// This experiment extends stunreachability to cover the transports used by
+// WebRTC-based tools such as Snowflake and video calling applications.
+package webrtcreachability
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ooni/probe-cli/v3/internal/logx"
+	"github.com/ooni/probe-cli/v3/internal/measurexlite"
+	"github.com/ooni/probe-cli/v3/internal/model"
+)
+
+const (
+	testName    = "webrtcreachability"
+	testVersion = "0.1.0"
+)
+
+// Config contains the experiment config.
+type Config struct {
+	// Transports is the space separated list of transports to try when
+	// the input URL does not specify the transport.
+	Transports string `ooni:"space separated list of transports to try for stun: and turn: URLs (udp, tcp)"`
+
+	// TURNPassword is the password to use for TURN allocations.
+	TURNPassword string `ooni:"password to use for TURN allocations"`
+
+	// TURNUsername is the username to use for TURN allocations.
+	TURNUsername string `ooni:"username to use for TURN allocations"`
+}
+
+func (c *Config) transports() []string {
+	if c.Transports != "" {
+		return strings.Fields(c.Transports)
+	}
+	return []string{"udp", "tcp"}
+}
+
+// TestKeys contains the experiment's result.
+type TestKeys struct {
+	// Endpoint is the endpoint we're measuring.
+	Endpoint string `json:"endpoint"`
+
+	// Scheme is the URL scheme (one of stun, stuns, turn, turns).
+	Scheme string `json:"scheme"`
+
+	// Queries contains the DNS lookups for the server domain.
+	Queries []*model.ArchivalDNSLookupResult `json:"queries"`
+
+	// Attempts contains the result of each STUN/TURN operation.
+	Attempts []*Attempt `json:"attempts"`
+
+	// Candidates summarizes the ICE candidates we could gather.
+	Candidates []*Candidate `json:"candidates"`
+
+	// SuccessfulTransports lists the transports for which all operations succeeded.
+	SuccessfulTransports []string `json:"successful_transports"`
+
+	// FailedTransports lists the transports for which any operation failed.
+	FailedTransports []string `json:"failed_transports"`
+
+	// Classification is one of "reachable", "partially_reachable", and "unreachable".
+	Classification string `json:"classification"`
+
+	// Failure is the failure that prevented us from measuring, if any.
+	Failure *string `json:"failure"`
+}
+
+// Candidate summarizes an ICE candidate. We deliberately omit the candidate
+// address, because the server reflexive address is the probe IP address.
+type Candidate struct {
+	// Type is the ICE candidate type (either "srflx" or "relay").
+	Type string `json:"type"`
+
+	// Transport is the transport used to gather the candidate.
+	Transport string `json:"transport"`
+}
+
+// Measurer performs the measurement.
+type Measurer struct {
+	config Config
+}
+
+// ExperimentName implements ExperimentMeasurer.ExperimentName.
+func (m *Measurer) ExperimentName() string {
+	return testName
+}
+
+// ExperimentVersion implements ExperimentMeasurer.ExperimentVersion.
+func (m *Measurer) ExperimentVersion() string {
+	return testVersion
+}
+
+var (
+	// errMissingInput means that the user did not provide any input
+	errMissingInput = errors.New("webrtcreachability: missing input")
+
+	// errInvalidInput means the input is not a valid URL
+	errInvalidInput = errors.New("webrtcreachability: invalid input")
+
+	// errUnsupportedURLScheme means we don't support the URL scheme
+	errUnsupportedURLScheme = errors.New("webrtcreachability: unsupported URL scheme")
+
+	// errUnsupportedTransport means we don't support the transport
+	errUnsupportedTransport = errors.New("webrtcreachability: unsupported transport")
+
+	// errMissingTURNCredentials means we need credentials for TURN
+	errMissingTURNCredentials = errors.New("webrtcreachability: missing TURN credentials")
+
+	// errNoAddresses indicates that the DNS lookup did not return any address.
+	errNoAddresses = errors.New("webrtcreachability: dns lookup returned no addresses")
+)
+
+// target is the parsed experiment input.
+type target struct {
+	// domain is the domain or IP address of the server.
+	domain string
+
+	// port is the server port.
+	port string
+
+	// scheme is one of stun, stuns, turn, turns.
+	scheme string
+
+	// transports contains the transports to use.
+	transports []string
+}
+
+// isTURN returns whether we should attempt TURN allocations.
+func (t *target) isTURN() bool {
+	return t.scheme == "turn" || t.scheme == "turns"
+}
+
+// parseInput parses STUN and TURN URLs following RFC7064 and RFC7065 (e.g.,
+// stun:example.com:3478, turns:example.com?transport=tcp) as well as the
+// stun://example.com:3478 format used by the stunreachability experiment.
+func (m *Measurer) parseInput(input string) (*target, error) {
+	URL, err := url.Parse(input)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errInvalidInput, err.Error())
+	}
+	hostport := URL.Host
+	if URL.Opaque != "" {
+		hostport = URL.Opaque
+	}
+	if hostport == "" {
+		return nil, errInvalidInput
+	}
+	t := &target{scheme: URL.Scheme}
+	switch URL.Scheme {
+	case "stun", "turn":
+		t.port, t.transports = "3478", m.config.transports()
+	case "stuns", "turns":
+		t.port, t.transports = "5349", []string{"tls"}
+	default:
+		return nil, errUnsupportedURLScheme
+	}
+	t.domain = hostport
+	if host, port, err := net.SplitHostPort(hostport); err == nil {
+		t.domain, t.port = host, port
+	}
+	if transport := URL.Query().Get("transport"); transport != "" {
+		if t.scheme == "stuns" || t.scheme == "turns" {
+			// RFC7065 Sect. 3 says that the transport is TLS-over-TCP in this case
+			transport = "tls"
+		}
+		t.transports = []string{transport}
+	}
+	for _, transport := range t.transports {
+		switch transport {
+		case "udp", "tcp", "tls":
+		default:
+			return nil, fmt.Errorf("%w: %s", errUnsupportedTransport, transport)
+		}
+	}
+	return t, nil
+}
+
+// Run implements ExperimentMeasurer.Run.
+func (m *Measurer) Run(ctx context.Context, args *model.ExperimentArgs) error {
+	measurement := args.Measurement
+	logger := args.Session.Logger()
+	input := string(measurement.Input)
+	if input == "" {
+		return errMissingInput
+	}
+	t, err := m.parseInput(input)
+	if err != nil {
+		return err
+	}
+	if t.isTURN() && (m.config.TURNUsername == "" || m.config.TURNPassword == "") {
+		return errMissingTURNCredentials
+	}
+	tk := &TestKeys{
+		Endpoint:             net.JoinHostPort(t.domain, t.port),
+		Scheme:               t.scheme,
+		Queries:              []*model.ArchivalDNSLookupResult{},
+		Attempts:             []*Attempt{},
+		Candidates:           []*Candidate{},
+		SuccessfulTransports: []string{},
+		FailedTransports:     []string{},
+		Classification:       "",
+		Failure:              nil,
+	}
+	measurement.TestKeys = tk
+	zeroTime := measurement.MeasurementStartTimeSaved
+
+	// resolve the server domain, if needed
+	address, err := m.lookup(ctx, logger, zeroTime, t, tk)
+	if err != nil {
+		tk.Failure = measurexlite.NewFailure(err)
+		tk.Classification = "unreachable"
+		return nil // we want to submit this measurement
+	}
+
+	// try each transport sequentially
+	var index int64
+	for _, transport := range t.transports {
+		index++
+		c := &client{
+			address:   address,
+			config:    &m.config,
+			index:     index,
+			logger:    logger,
+			target:    t,
+			transport: transport,
+			zeroTime:  zeroTime,
+		}
+		attempts := c.run(ctx)
+		tk.Attempts = append(tk.Attempts, attempts...)
+		tk.addTransportResult(transport, attempts)
+	}
+	tk.classify()
+	return nil // we want to submit this measurement
+}
+
+// addTransportResult updates the test keys using the attempts for a transport.
+func (tk *TestKeys) addTransportResult(transport string, attempts []*Attempt) {
+	success := len(attempts) > 0
+	for _, a := range attempts {
+		if a.Failure != nil {
+			success = false
+			continue
+		}
+		tk.Candidates = append(tk.Candidates, &Candidate{
+			Type:      a.candidateType(),
+			Transport: transport,
+		})
+	}
+	if !success {
+		tk.FailedTransports = append(tk.FailedTransports, transport)
+		return
+	}
+	tk.SuccessfulTransports = append(tk.SuccessfulTransports, transport)
+}
+
+// classify sets the classification depending on which transports succeeded.
+func (tk *TestKeys) classify() {
+	switch {
+	case len(tk.SuccessfulTransports) <= 0:
+		tk.Classification = "unreachable"
+	case len(tk.FailedTransports) <= 0:
+		tk.Classification = "reachable"
+	default:
+		tk.Classification = "partially_reachable"
+	}
+}
+
+// lookup resolves the domain of the server and returns the endpoint to use.
+func (m *Measurer) lookup(ctx context.Context, logger model.Logger,
+	zeroTime time.Time, t *target, tk *TestKeys) (string, error) {
+	if net.ParseIP(t.domain) != nil {
+		return net.JoinHostPort(t.domain, t.port), nil
+	}
+	const timeout = 4 * time.Second
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	trace := measurexlite.NewTrace(0, zeroTime)
+	ol := logx.NewOperationLogger(logger, "WebRTCReachability: lookup %s", t.domain)
+	reso := trace.NewStdlibResolver(logger)
+	addrs, err := reso.LookupHost(ctx, t.domain)
+	tk.Queries = append(tk.Queries, trace.DNSLookupsFromRoundTrip()...)
+	ol.Stop(err)
+	if err != nil {
+		return "", err
+	}
+	if len(addrs) < 1 {
+		return "", errNoAddresses
+	}
+	// prefer IPv4 because the TURN client resolves server addresses as udp4
+	address := addrs[0]
+	for _, addr := range addrs {
+		if ip := net.ParseIP(addr); ip != nil && ip.To4() != nil {
+			address = addr
+			break
+		}
+	}
+	return net.JoinHostPort(address, t.port), nil
+}
+
+// NewExperimentMeasurer creates a new ExperimentMeasurer.
+func NewExperimentMeasurer(config Config) model.ExperimentMeasurer {
+	return &Measurer{config: config}
+}
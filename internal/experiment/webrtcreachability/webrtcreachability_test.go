@@ -0,0 +1,259 @@
+package webrtcreachability
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/ooni/netem"
+	"github.com/ooni/probe-cli/v3/internal/mocks"
+	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/netemx"
+)
+
+func TestMeasurerParseInput(t *testing.T) {
+	type testcase struct {
+		name   string
+		config Config
+		input  string
+		expect *target
+		err    error
+	}
+
+	cases := []testcase{{
+		name:  "with the stunreachability URL format",
+		input: "stun://stun.l.google.com:19302",
+		expect: &target{
+			domain:     "stun.l.google.com",
+			port:       "19302",
+			scheme:     "stun",
+			transports: []string{"udp", "tcp"},
+		},
+	}, {
+		name:  "with an RFC7064 URL without port",
+		input: "stun:stun.example.com",
+		expect: &target{
+			domain:     "stun.example.com",
+			port:       "3478",
+			scheme:     "stun",
+			transports: []string{"udp", "tcp"},
+		},
+	}, {
+		name:  "with an RFC7065 URL with transport",
+		input: "turn:turn.example.com:3479?transport=tcp",
+		expect: &target{
+			domain:     "turn.example.com",
+			port:       "3479",
+			scheme:     "turn",
+			transports: []string{"tcp"},
+		},
+	}, {
+		name:  "with a secure URL",
+		input: "turns:turn.example.com?transport=tcp",
+		expect: &target{
+			domain:     "turn.example.com",
+			port:       "5349",
+			scheme:     "turns",
+			transports: []string{"tls"},
+		},
+	}, {
+		name:   "with custom transports",
+		config: Config{Transports: "tcp"},
+		input:  "stun:1.1.1.1:3478",
+		expect: &target{
+			domain:     "1.1.1.1",
+			port:       "3478",
+			scheme:     "stun",
+			transports: []string{"tcp"},
+		},
+	}, {
+		name:  "with unsupported scheme",
+		input: "https://example.com/",
+		err:   errUnsupportedURLScheme,
+	}, {
+		name:  "with unsupported transport",
+		input: "stun:example.com?transport=sctp",
+		err:   errUnsupportedTransport,
+	}, {
+		name:  "with invalid URL",
+		input: "\t",
+		err:   errInvalidInput,
+	}, {
+		name:  "with empty host",
+		input: "stun:",
+		err:   errInvalidInput,
+	}}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := &Measurer{config: tc.config}
+			got, err := m.parseInput(tc.input)
+			if !errors.Is(err, tc.err) {
+				t.Fatal("unexpected error", err)
+			}
+			if diff := cmp.Diff(tc.expect, got, cmp.AllowUnexported(target{})); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestMeasurerRun(t *testing.T) {
+	// runHelper is an helper function to run this set of tests.
+	runHelper := func(config Config, input string) (*TestKeys, error) {
+		m := NewExperimentMeasurer(config)
+		if m.ExperimentName() != "webrtcreachability" {
+			t.Fatal("invalid experiment name")
+		}
+		if m.ExperimentVersion() != "0.1.0" {
+			t.Fatal("invalid experiment version")
+		}
+		meas := &model.Measurement{
+			Input:                     model.MeasurementInput(input),
+			MeasurementStartTimeSaved: time.Now(),
+		}
+		args := &model.ExperimentArgs{
+			Callbacks:   model.NewPrinterCallbacks(model.DiscardLogger),
+			Measurement: meas,
+			Session: &mocks.Session{
+				MockLogger: func() model.Logger { return model.DiscardLogger },
+			},
+		}
+		err := m.Run(context.Background(), args)
+		tk, _ := meas.TestKeys.(*TestKeys)
+		return tk, err
+	}
+
+	credentials := Config{
+		TURNPassword: netemx.TURNServerPassword,
+		TURNUsername: netemx.TURNServerUsername,
+	}
+
+	t.Run("with empty input", func(t *testing.T) {
+		if _, err := runHelper(Config{}, ""); !errors.Is(err, errMissingInput) {
+			t.Fatal("unexpected error", err)
+		}
+	})
+
+	t.Run("with invalid input", func(t *testing.T) {
+		if _, err := runHelper(Config{}, "http://x.org"); !errors.Is(err, errUnsupportedURLScheme) {
+			t.Fatal("unexpected error", err)
+		}
+	})
+
+	t.Run("with TURN and missing credentials", func(t *testing.T) {
+		if _, err := runHelper(Config{}, "turn:x.org"); !errors.Is(err, errMissingTURNCredentials) {
+			t.Fatal("unexpected error", err)
+		}
+	})
+
+	t.Run("with netem: when the DNS lookup fails", func(t *testing.T) {
+		env := netemx.MustNewScenario(netemx.InternetScenario)
+		defer env.Close()
+
+		env.Do(func() {
+			tk, err := runHelper(Config{}, "stun:stun.nonexistent.example")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tk.Failure == nil || *tk.Failure != "dns_nxdomain_error" {
+				t.Fatal("unexpected failure", tk.Failure)
+			}
+			if tk.Classification != "unreachable" {
+				t.Fatal("unexpected classification", tk.Classification)
+			}
+		})
+	})
+
+	t.Run("with netem: STUN over UDP and TCP", func(t *testing.T) {
+		env := netemx.MustNewScenario(netemx.InternetScenario)
+		defer env.Close()
+
+		env.Do(func() {
+			tk, err := runHelper(Config{}, "stun:turn.cloudflare.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tk.Classification != "reachable" {
+				t.Fatal("unexpected classification", tk.Classification)
+			}
+			expect := []*Candidate{{Type: "srflx", Transport: "udp"}, {Type: "srflx", Transport: "tcp"}}
+			if diff := cmp.Diff(expect, tk.Candidates); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	})
+
+	t.Run("with netem: TURN over UDP, TCP and TLS", func(t *testing.T) {
+		env := netemx.MustNewScenario(netemx.InternetScenario)
+		defer env.Close()
+
+		env.Do(func() {
+			for _, input := range []string{"turn:turn.cloudflare.com", "turns:turn.cloudflare.com"} {
+				tk, err := runHelper(credentials, input)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if tk.Classification != "reachable" {
+					t.Fatal("unexpected classification", input, tk.Classification, tk.FailedTransports)
+				}
+				if len(tk.Candidates) != 2*len(tk.SuccessfulTransports) {
+					t.Fatal("expected srflx and relay candidates for each transport")
+				}
+			}
+		})
+	})
+
+	t.Run("with netem: TURN with wrong credentials", func(t *testing.T) {
+		env := netemx.MustNewScenario(netemx.InternetScenario)
+		defer env.Close()
+
+		env.Do(func() {
+			config := Config{TURNUsername: "nobody", TURNPassword: "nothing", Transports: "udp"}
+			tk, err := runHelper(config, "turn:turn.cloudflare.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tk.Classification != "unreachable" {
+				t.Fatal("unexpected classification", tk.Classification)
+			}
+			expect := []*Candidate{{Type: "srflx", Transport: "udp"}}
+			if diff := cmp.Diff(expect, tk.Candidates); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	})
+
+	t.Run("with netem: when UDP is blocked", func(t *testing.T) {
+		env := netemx.MustNewScenario(netemx.InternetScenario)
+		defer env.Close()
+
+		env.DPIEngine().AddRule(&netem.DPIDropTrafficForServerEndpoint{
+			Logger:          model.DiscardLogger,
+			ServerIPAddress: netemx.AddressTURNCloudflareCom,
+			ServerPort:      3478,
+			ServerProtocol:  17, // UDP
+		})
+
+		env.Do(func() {
+			tk, err := runHelper(credentials, "turn:turn.cloudflare.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tk.Classification != "partially_reachable" {
+				t.Fatal("unexpected classification", tk.Classification)
+			}
+			if diff := cmp.Diff([]string{"udp"}, tk.FailedTransports); diff != "" {
+				t.Fatal(diff)
+			}
+			if f := tk.Attempts[0].Failure; f == nil || *f != "generic_timeout_error" {
+				t.Fatal("unexpected failure", f)
+			}
+			if diff := cmp.Diff([]string{"tcp"}, tk.SuccessfulTransports); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	})
+}
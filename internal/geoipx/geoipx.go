@@ -3,6 +3,8 @@ package geoipx
 
 import (
 	"net"
+	"path/filepath"
+	"sync"
 
 	"github.com/ooni/probe-assets/assets"
 	"github.com/ooni/probe-cli/v3/internal/model"
@@ -10,16 +12,106 @@ import (
 	"github.com/oschwald/maxminddb-golang"
 )
 
-// TODO(bassosimone): this would be more efficient if we'd open just
-// once the database and then reuse it for every address.
+// SourceEmbedded is the source of the embedded database.
+const SourceEmbedded = "embedded"
+
+// record is the information we read from a database. The fields are a
+// superset of [assets.OOMMDBRecord] such that we're also able to use the
+// MaxMind-compatible databases provided by, e.g., MaxMind and DB-IP.
+type record struct {
+	// AutonomousSystemNumber is the AS number.
+	AutonomousSystemNumber uint `maxminddb:"autonomous_system_number"`
+
+	// AutonomousSystemOrganization is the org name.
+	AutonomousSystemOrganization string `maxminddb:"autonomous_system_organization"`
+
+	// Country contains country info.
+	Country assets.OOMMDBCountryInfo `maxminddb:"country"`
+
+	// RegisteredCountry contains the registered country info.
+	RegisteredCountry assets.OOMMDBCountryInfo `maxminddb:"registered_country"`
+}
+
+// Database is an open MaxMind-like database. Use [Embedded] to get the
+// embedded database and [Open] to open a user-supplied database.
+type Database struct {
+	// embedded indicates whether this is the embedded database.
+	embedded bool
+
+	// reader is the underlying reader.
+	reader *maxminddb.Reader
+
+	// source describes where the database comes from.
+	source string
+}
+
+var (
+	// embeddedOnce provides "once" semantics for Embedded.
+	embeddedOnce sync.Once
+
+	// embeddedDatabase is the cached embedded database.
+	embeddedDatabase *Database
+)
+
+// Embedded returns the embedded database. We open the database the first
+// time this function is called and we reuse it afterwards.
+func Embedded() *Database {
+	embeddedOnce.Do(func() {
+		reader, err := maxminddb.FromBytes(assets.OOMMDBDatabaseBytes)
+		runtimex.PanicOnError(err, "cannot load embedded geoip2 database")
+		embeddedDatabase = &Database{
+			embedded: true,
+			reader:   reader,
+			source:   SourceEmbedded,
+		}
+	})
+	return embeddedDatabase
+}
+
+// Open opens the MaxMind-compatible MMDB file at the given path (e.g., a
+// newer DB-IP dump). The file should contain both the ASN and the country
+// information, using the same record layout used by MaxMind's databases.
+func Open(filename string) (*Database, error) {
+	reader, err := maxminddb.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	db := &Database{
+		embedded: false,
+		reader:   reader,
+		source:   "custom:" + filepath.Base(filename),
+	}
+	return db, nil
+}
+
+// Source returns a string describing where the database comes from, which
+// is either [SourceEmbedded] or "custom:" followed by the file base name.
+func (db *Database) Source() string {
+	return db.source
+}
+
+// Close closes a database opened using [Open]. Closing the
+// database returned by [Embedded] is a no-op.
+func (db *Database) Close() error {
+	if db.embedded {
+		return nil
+	}
+	return db.reader.Close()
+}
+
+// lookup looks up the record for the given IP address.
+func (db *Database) lookup(ip string) (*record, error) {
+	var r record
+	if err := db.reader.Lookup(net.ParseIP(ip), &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
 
 // LookupASN maps [ip] to an AS number and an AS organization name.
-func LookupASN(ip string) (asn uint, org string, err error) {
+func (db *Database) LookupASN(ip string) (asn uint, org string, err error) {
 	asn, org = model.DefaultProbeASN, model.DefaultProbeNetworkName
-	db, err := maxminddb.FromBytes(assets.OOMMDBDatabaseBytes)
-	runtimex.PanicOnError(err, "cannot load embedded geoip2 database")
-	defer db.Close()
-	record, err := assets.OOMMDBLooup(db, net.ParseIP(ip))
+	record, err := db.lookup(ip)
 	if err != nil {
 		return
 	}
@@ -31,20 +123,33 @@ func LookupASN(ip string) (asn uint, org string, err error) {
 }
 
 // LookupCC maps [ip] to a country code.
-func LookupCC(ip string) (cc string, err error) {
+func (db *Database) LookupCC(ip string) (cc string, err error) {
 	cc = model.DefaultProbeCC
-	db, err := maxminddb.FromBytes(assets.OOMMDBDatabaseBytes)
-	runtimex.PanicOnError(err, "cannot load embedded geoip2 country database")
-	defer db.Close()
-	record, err := assets.OOMMDBLooup(db, net.ParseIP(ip))
+	record, err := db.lookup(ip)
 	if err != nil {
 		return
 	}
 	// With MaxMind DB we used record.RegisteredCountry.IsoCode but that does
 	// not seem to work with the db-ip.com database. The record is empty, at
 	// least for my own IP address in Italy. --Simone (2020-02-25)
-	if record.Country.IsoCode != "" {
+	//
+	// So, we only use the registered country as a fallback.
+	switch {
+	case record.Country.IsoCode != "":
 		cc = record.Country.IsoCode
+	case record.RegisteredCountry.IsoCode != "":
+		cc = record.RegisteredCountry.IsoCode
 	}
 	return
 }
+
+// LookupASN maps [ip] to an AS number and an AS organization name
+// using the [Embedded] database.
+func LookupASN(ip string) (asn uint, org string, err error) {
+	return Embedded().LookupASN(ip)
+}
+
+// LookupCC maps [ip] to a country code using the [Embedded] database.
+func LookupCC(ip string) (cc string, err error) {
+	return Embedded().LookupCC(ip)
+}
@@ -1,8 +1,11 @@
 package geoipx
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/ooni/probe-assets/assets"
 	"github.com/ooni/probe-cli/v3/internal/model"
 )
 
@@ -57,3 +60,61 @@ func TestLookupCC(t *testing.T) {
 		}
 	})
 }
+
+func TestEmbedded(t *testing.T) {
+	db := Embedded()
+	if db != Embedded() {
+		t.Fatal("expected the same database")
+	}
+	if db.Source() != SourceEmbedded {
+		t.Fatal("unexpected source", db.Source())
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// make sure closing the embedded database is a no-op
+	if _, _, err := db.LookupASN(ipAddr); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	t.Run("with nonexistent file", func(t *testing.T) {
+		db, err := Open(filepath.Join(t.TempDir(), "nonexistent.mmdb"))
+		if err == nil {
+			t.Fatal("expected an error here")
+		}
+		if db != nil {
+			t.Fatal("expected nil db")
+		}
+	})
+
+	t.Run("with valid file", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "custom.mmdb")
+		if err := os.WriteFile(filename, assets.OOMMDBDatabaseBytes, 0600); err != nil {
+			t.Fatal(err)
+		}
+		db, err := Open(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		if db.Source() != "custom:custom.mmdb" {
+			t.Fatal("unexpected source", db.Source())
+		}
+		asn, org, err := db.LookupASN(ipAddr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if asn != 15169 || org != "Google LLC" {
+			t.Fatal("unexpected ASN", asn, org)
+		}
+		cc, err := db.LookupCC(ipAddr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cc != "US" {
+			t.Fatal("unexpected CC", cc)
+		}
+	})
+}
@@ -12,6 +12,11 @@ type Config struct {
 	// Client is the MANDATORY [model.HTTPClient] to use.
 	Client model.HTTPClient
 
+	// ContentEncoding is the OPTIONAL encoding to use for compressing the
+	// request body. We support "gzip" and "zstd". If not set, we do not
+	// compress the request body.
+	ContentEncoding string
+
 	// Logger is the MANDATORY [model.Logger] to use.
 	Logger model.Logger
 
@@ -0,0 +1,48 @@
+package httpclientx
+
+//
+// encoding.go - request body compression
+//
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// ErrUnsupportedContentEncoding indicates that the [*Config] specifies
+// a request body content encoding that we do not support.
+var ErrUnsupportedContentEncoding = errors.New("httpclientx: unsupported content encoding")
+
+// encodeBody compresses the given request body using the given encoding. When the
+// encoding is empty, this function returns the original request body.
+func encodeBody(encoding string, body []byte) ([]byte, error) {
+	switch encoding {
+	case "":
+		return body, nil
+
+	case "gzip":
+		var buff bytes.Buffer
+		writer := gzip.NewWriter(&buff)
+		if _, err := writer.Write(body); err != nil {
+			return nil, err
+		}
+		if err := writer.Close(); err != nil {
+			return nil, err
+		}
+		return buff.Bytes(), nil
+
+	case "zstd":
+		encoder, err := zstd.NewWriter(nil)
+		if err != nil {
+			return nil, err
+		}
+		defer encoder.Close()
+		return encoder.EncodeAll(body, make([]byte, 0, len(body)/4)), nil
+
+	default:
+		return nil, ErrUnsupportedContentEncoding
+	}
+}
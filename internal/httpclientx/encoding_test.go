@@ -0,0 +1,57 @@
+package httpclientx
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/klauspost/compress/zstd"
+	"github.com/ooni/probe-cli/v3/internal/runtimex"
+)
+
+// decodeBodyForTesting decompresses a body compressed using encodeBody.
+func decodeBodyForTesting(encoding string, body []byte) []byte {
+	switch encoding {
+	case "gzip":
+		reader := runtimex.Try1(gzip.NewReader(bytes.NewReader(body)))
+		return runtimex.Try1(io.ReadAll(reader))
+	case "zstd":
+		decoder := runtimex.Try1(zstd.NewReader(nil))
+		defer decoder.Close()
+		return runtimex.Try1(decoder.DecodeAll(body, nil))
+	default:
+		return body
+	}
+}
+
+func TestEncodeBody(t *testing.T) {
+	body := bytes.Repeat([]byte(`{"failure":null,"requests":[]}`), 128)
+
+	for _, encoding := range []string{"", "gzip", "zstd"} {
+		t.Run("with encoding "+encoding, func(t *testing.T) {
+			encoded, err := encodeBody(encoding, body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if encoding != "" && len(encoded) >= len(body) {
+				t.Fatal("expected the encoded body to be smaller")
+			}
+			if diff := cmp.Diff(body, decodeBodyForTesting(encoding, encoded)); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+
+	t.Run("with an unsupported encoding", func(t *testing.T) {
+		encoded, err := encodeBody("br", body)
+		if !errors.Is(err, ErrUnsupportedContentEncoding) {
+			t.Fatal("unexpected error", err)
+		}
+		if len(encoded) != 0 {
+			t.Fatal("expected empty body")
+		}
+	})
+}
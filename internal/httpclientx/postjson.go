@@ -43,8 +43,14 @@ func postJSON[Input, Output any](ctx context.Context, epnt *Endpoint, input Inpu
 	// log the raw request body
 	config.Logger.Debugf("POST %s: raw request body: %s", epnt.URL, string(rawreqbody))
 
+	// optionally compress the request body
+	encodedreqbody, err := encodeBody(config.ContentEncoding, rawreqbody)
+	if err != nil {
+		return zeroValue[Output](), err
+	}
+
 	// construct the request to use
-	req, err := http.NewRequestWithContext(ctx, "POST", epnt.URL, bytes.NewReader(rawreqbody))
+	req, err := http.NewRequestWithContext(ctx, "POST", epnt.URL, bytes.NewReader(encodedreqbody))
 	if err != nil {
 		return zeroValue[Output](), err
 	}
@@ -52,6 +58,11 @@ func postJSON[Input, Output any](ctx context.Context, epnt *Endpoint, input Inpu
 	// assign the content type
 	req.Header.Set("Content-Type", "application/json")
 
+	// optionally assign the content encoding
+	if value := config.ContentEncoding; value != "" {
+		req.Header.Set("Content-Encoding", value)
+	}
+
 	// get the raw response body
 	rawrespbody, err := do(ctx, req, epnt, config)
 
@@ -265,6 +265,73 @@ func TestPostJSONCommunicationOkay(t *testing.T) {
 	}
 }
 
+// This test ensures that PostJSON compresses the request body when requested.
+func TestPostJSONContentEncoding(t *testing.T) {
+	apireq := &apiRequest{UserID: 117}
+
+	for _, encoding := range []string{"gzip", "zstd"} {
+		t.Run("with encoding "+encoding, func(t *testing.T) {
+			var (
+				gotencoding string
+				gotrawbody  []byte
+				gotmu       sync.Mutex
+			)
+
+			server := testingx.MustNewHTTPServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				rawbody := runtimex.Try1(netxlite.ReadAllContext(r.Context(), r.Body))
+				gotmu.Lock()
+				gotencoding = r.Header.Get("Content-Encoding")
+				gotrawbody = rawbody
+				gotmu.Unlock()
+				w.Write([]byte(`{}`))
+			}))
+			defer server.Close()
+
+			_, err := PostJSON[*apiRequest, *apiResponse](
+				context.Background(),
+				NewEndpoint(server.URL),
+				apireq,
+				&Config{
+					Client:          http.DefaultClient,
+					ContentEncoding: encoding,
+					Logger:          model.DiscardLogger,
+					UserAgent:       model.HTTPHeaderUserAgent,
+				})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer gotmu.Unlock()
+			gotmu.Lock()
+			if gotencoding != encoding {
+				t.Fatal("unexpected Content-Encoding value", gotencoding)
+			}
+			if diff := cmp.Diff(must.MarshalJSON(apireq), decodeBodyForTesting(encoding, gotrawbody)); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+
+	t.Run("with an unsupported encoding", func(t *testing.T) {
+		resp, err := PostJSON[*apiRequest, *apiResponse](
+			context.Background(),
+			NewEndpoint("http://127.0.0.1/"),
+			apireq,
+			&Config{
+				Client:          http.DefaultClient,
+				ContentEncoding: "br",
+				Logger:          model.DiscardLogger,
+				UserAgent:       model.HTTPHeaderUserAgent,
+			})
+		if !errors.Is(err, ErrUnsupportedContentEncoding) {
+			t.Fatal("unexpected error", err)
+		}
+		if resp != nil {
+			t.Fatal("expected nil response")
+		}
+	})
+}
+
 // This test ensures PostJSON logs the request and response body at Debug level.
 func TestPostJSONLoggingOkay(t *testing.T) {
 	req := &apiRequest{117}
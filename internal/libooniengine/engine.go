@@ -25,6 +25,64 @@ func OONIEngineFreeMemory(ptr *C.void) {
 	C.free(unsafe.Pointer(ptr))
 }
 
+//export OONIEngineSessionNew
+func OONIEngineSessionNew(config *C.char) *C.char {
+	return C.CString(sessionNew(C.GoString(config)))
+}
+
+//export OONIEngineSessionGeolocate
+func OONIEngineSessionGeolocate(session C.int64_t, timeout C.int64_t) *C.char {
+	return C.CString(sessionGeolocate(int64(session), int64(timeout)))
+}
+
+//export OONIEngineSessionCheckIn
+func OONIEngineSessionCheckIn(session C.int64_t, config *C.char, timeout C.int64_t) *C.char {
+	return C.CString(sessionCheckIn(int64(session), C.GoString(config), int64(timeout)))
+}
+
+//export OONIEngineSessionSubmit
+func OONIEngineSessionSubmit(session C.int64_t, measurement *C.char, timeout C.int64_t) *C.char {
+	return C.CString(sessionSubmit(int64(session), C.GoString(measurement), int64(timeout)))
+}
+
+//export OONIEngineSessionInterrupt
+func OONIEngineSessionInterrupt(session C.int64_t) {
+	sessionInterrupt(int64(session))
+}
+
+//export OONIEngineSessionFree
+func OONIEngineSessionFree(session C.int64_t) {
+	sessionFree(int64(session))
+}
+
+//export OONIEngineTaskStart
+func OONIEngineTaskStart(settings *C.char) *C.char {
+	return C.CString(taskStart(C.GoString(settings)))
+}
+
+//export OONIEngineTaskWaitForNextEvent
+func OONIEngineTaskWaitForNextEvent(task C.int64_t) *C.char {
+	return C.CString(taskWaitForNextEvent(int64(task)))
+}
+
+//export OONIEngineTaskIsDone
+func OONIEngineTaskIsDone(task C.int64_t) C.int {
+	if taskIsDone(int64(task)) {
+		return 1
+	}
+	return 0
+}
+
+//export OONIEngineTaskInterrupt
+func OONIEngineTaskInterrupt(task C.int64_t) {
+	taskInterrupt(int64(task))
+}
+
+//export OONIEngineTaskFree
+func OONIEngineTaskFree(task C.int64_t) {
+	taskFree(int64(task))
+}
+
 func main() {
 	// do nothing
 }
@@ -6,6 +6,28 @@
 ///
 /// C API for using the OONI engine.
 ///
+/// Conventions:
+///
+/// 1. functions take JSON documents as input and return JSON documents
+/// as output, encoded as NUL-terminated UTF-8 strings, and the engine does
+/// not take ownership of (nor modifies) the input strings;
+///
+/// 2. the caller owns the returned strings and MUST free them using
+/// OONIEngineFreeMemory;
+///
+/// 3. unless otherwise noted, the returned JSON document is an object
+/// containing a "failure" and a "result" key. On success, "failure" is
+/// null and "result" contains the function specific output. On failure,
+/// "failure" is a string explaining what went wrong and "result" is null;
+///
+/// 4. sessions and tasks are referred to using int64_t handles, which
+/// the caller MUST release using the corresponding free function;
+///
+/// 5. timeouts are expressed in seconds, and a zero or negative timeout
+/// means that the operation does not time out.
+///
+
+#include <stdint.h>
 
 #ifdef __cplusplus
 extern "C" {
@@ -19,7 +41,94 @@ char *OONIEngineVersion(void);
 /// OONIEngineFreeMemory frees the memory allocated by the engine.
 ///
 /// @param ptr a void pointer refering to the memory to be freed.
-void OONIENgineFreeMemory(void *ptr);
+void OONIEngineFreeMemory(void *ptr);
+
+/// OONIEngineSessionNew creates a new measurement session.
+///
+/// @param config a JSON object containing the software_name, software_version,
+/// state_dir, and temp_dir mandatory keys and the probe_services_url, proxy,
+/// and tunnel_dir optional keys.
+///
+/// @return A JSON response whose result is {"session": <handle>}.
+char *OONIEngineSessionNew(char *config);
+
+/// OONIEngineSessionGeolocate discovers the probe IP, ASN, CC, and network name.
+///
+/// @param session the session handle.
+/// @param timeout the timeout in seconds.
+///
+/// @return A JSON response whose result contains the probe_asn, probe_cc,
+/// probe_ip, and probe_network_name keys.
+char *OONIEngineSessionGeolocate(int64_t session, int64_t timeout);
+
+/// OONIEngineSessionCheckIn calls the check-in API.
+///
+/// @param session the session handle.
+/// @param config a JSON object containing the charging, on_wifi, platform,
+/// run_type, software_name, software_version, and web_connectivity keys, where
+/// web_connectivity is an object containing the category_codes list.
+/// @param timeout the timeout in seconds.
+///
+/// @return A JSON response whose result contains the web_connectivity key, which
+/// is either null or an object containing the report_id and urls keys.
+char *OONIEngineSessionCheckIn(int64_t session, char *config, int64_t timeout);
+
+/// OONIEngineSessionSubmit submits a measurement to the OONI collector.
+///
+/// @param session the session handle.
+/// @param measurement the JSON serialized measurement.
+/// @param timeout the timeout in seconds.
+///
+/// @return A JSON response whose result contains the updated_measurement
+/// and the updated_report_id keys.
+char *OONIEngineSessionSubmit(int64_t session, char *measurement, int64_t timeout);
+
+/// OONIEngineSessionInterrupt interrupts all the pending session operations,
+/// which will return a JSON response containing a failure.
+///
+/// @param session the session handle.
+void OONIEngineSessionInterrupt(int64_t session);
+
+/// OONIEngineSessionFree interrupts all the pending session operations and
+/// releases the session. Using the handle afterwards causes failures.
+///
+/// @param session the session handle.
+void OONIEngineSessionFree(int64_t session);
+
+/// OONIEngineTaskStart starts running an experiment in the background.
+///
+/// @param settings a JSON object containing the task settings, which
+/// are the same settings used by the mobile library (pkg/oonimkall).
+///
+/// @return A JSON response whose result is {"task": <handle>}.
+char *OONIEngineTaskStart(char *settings);
+
+/// OONIEngineTaskWaitForNextEvent blocks until the task emits the next event.
+///
+/// @param task the task handle.
+///
+/// @return A JSON object containing the "key" and "value" keys, which follow
+/// the same conventions used by the mobile library (pkg/oonimkall). When the
+/// task is done, or the handle is invalid, the key is "task_terminated".
+char *OONIEngineTaskWaitForNextEvent(int64_t task);
+
+/// OONIEngineTaskIsDone returns whether the task is done.
+///
+/// @param task the task handle.
+///
+/// @return One if the task is done or the handle is invalid and zero otherwise.
+int OONIEngineTaskIsDone(int64_t task);
+
+/// OONIEngineTaskInterrupt interrupts the task, which will eventually terminate.
+///
+/// @param task the task handle.
+void OONIEngineTaskInterrupt(int64_t task);
+
+/// OONIEngineTaskFree interrupts and releases the task. You MUST NOT call this
+/// function while another thread is waiting for the next event of the task.
+///
+/// @param task the task handle.
+void OONIEngineTaskFree(int64_t task);
 
 #ifdef __cplusplus
 }
@@ -0,0 +1,58 @@
+package main
+
+//
+// Handles referring to Go objects owned by C code
+//
+
+import (
+	"errors"
+	"sync"
+)
+
+// errInvalidHandle indicates that a handle does not refer to any live object.
+var errInvalidHandle = errors.New("libooniengine: invalid handle")
+
+// handleTable maps integer handles to Go objects. We cannot pass Go pointers
+// to C code, so we give C code handles and keep the objects alive here until
+// C code explicitly frees them. The zero handle is never valid.
+type handleTable struct {
+	mu      sync.Mutex
+	next    int64
+	objects map[int64]any
+}
+
+// handles is the global handle table.
+var handles = &handleTable{objects: make(map[int64]any)}
+
+// add adds an object to the table and returns its handle.
+func (ht *handleTable) add(obj any) int64 {
+	ht.mu.Lock()
+	defer ht.mu.Unlock()
+	ht.next++
+	ht.objects[ht.next] = obj
+	return ht.next
+}
+
+// get returns the object referred by the given handle.
+func (ht *handleTable) get(handle int64) (any, error) {
+	ht.mu.Lock()
+	defer ht.mu.Unlock()
+	obj, found := ht.objects[handle]
+	if !found {
+		return nil, errInvalidHandle
+	}
+	return obj, nil
+}
+
+// remove removes the object referred by the given handle from the table
+// and returns it, so that the caller can perform any cleanup.
+func (ht *handleTable) remove(handle int64) (any, error) {
+	ht.mu.Lock()
+	defer ht.mu.Unlock()
+	obj, found := ht.objects[handle]
+	if !found {
+		return nil, errInvalidHandle
+	}
+	delete(ht.objects, handle)
+	return obj, nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/apex/log"
+	"github.com/ooni/probe-cli/v3/internal/shellx"
+)
+
+// TestCHarness builds the shared library and runs the C test harness against it.
+func TestCHarness(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skip test in short mode")
+	}
+	if runtime.GOOS != "linux" {
+		t.Skip("the C test harness only runs on Linux")
+	}
+	if _, err := exec.LookPath("cc"); err != nil {
+		t.Skip("there is no C compiler")
+	}
+	dir := t.TempDir()
+	library := filepath.Join(dir, "libooniengine.so")
+	if err := shellx.Run(log.Log, "go", "build", "-buildmode=c-shared", "-o", library, "."); err != nil {
+		t.Fatal(err)
+	}
+	harness := filepath.Join(dir, "harness")
+	if err := shellx.Run(log.Log, "cc", "-Wall", "-Werror", "-I.", "-o", harness,
+		filepath.Join("testdata", "harness.c"), library, "-Wl,-rpath,"+dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := shellx.Run(log.Log, harness, dir); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+//
+// JSON-out convention
+//
+
+import (
+	"encoding/json"
+
+	"github.com/ooni/probe-cli/v3/internal/runtimex"
+)
+
+// response is the JSON document returned by most API functions. On success,
+// the failure is null and the result contains the function specific output. On
+// failure, the failure is a string and the result is null.
+type response struct {
+	Failure *string `json:"failure"`
+	Result  any     `json:"result"`
+}
+
+// newResponse serializes a response given the result and the error.
+func newResponse(result any, err error) string {
+	var resp response
+	if err != nil {
+		failure := err.Error()
+		resp.Failure = &failure
+	} else {
+		resp.Result = result
+	}
+	data, err := json.Marshal(&resp)
+	runtimex.PanicOnError(err, "json.Marshal failed")
+	return string(data)
+}
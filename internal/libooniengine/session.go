@@ -0,0 +1,242 @@
+package main
+
+//
+// Sessions
+//
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/pkg/oonimkall"
+)
+
+// errNotASession indicates that a handle does not refer to a session.
+var errNotASession = errors.New("libooniengine: handle does not refer to a session")
+
+// sessionConfig is the JSON config for creating a session.
+type sessionConfig struct {
+	// ProbeServicesURL is the OPTIONAL probe services URL to use.
+	ProbeServicesURL string `json:"probe_services_url"`
+
+	// Proxy is the OPTIONAL proxy URL (e.g., "socks5://127.0.0.1:9050/").
+	Proxy string `json:"proxy"`
+
+	// SoftwareName is the MANDATORY name of the application.
+	SoftwareName string `json:"software_name"`
+
+	// SoftwareVersion is the MANDATORY version of the application.
+	SoftwareVersion string `json:"software_version"`
+
+	// StateDir is the MANDATORY directory where to store state.
+	StateDir string `json:"state_dir"`
+
+	// TempDir is the MANDATORY directory where to store temporary files.
+	TempDir string `json:"temp_dir"`
+
+	// TunnelDir is the OPTIONAL directory where to store tunnels state.
+	TunnelDir string `json:"tunnel_dir"`
+}
+
+// session wraps an [*oonimkall.Session] and tracks the contexts of the
+// pending operations such that we can interrupt all of them.
+type session struct {
+	mu       sync.Mutex
+	contexts map[*oonimkall.Context]bool
+	sess     *oonimkall.Session
+}
+
+// sessionNew implements OONIEngineSessionNew.
+func sessionNew(input string) string {
+	var config sessionConfig
+	if err := json.Unmarshal([]byte(input), &config); err != nil {
+		return newResponse(nil, err)
+	}
+	sess, err := oonimkall.NewSession(&oonimkall.SessionConfig{
+		ProbeServicesURL: config.ProbeServicesURL,
+		Proxy:            config.Proxy,
+		SoftwareName:     config.SoftwareName,
+		SoftwareVersion:  config.SoftwareVersion,
+		StateDir:         config.StateDir,
+		TempDir:          config.TempDir,
+		TunnelDir:        config.TunnelDir,
+	})
+	if err != nil {
+		return newResponse(nil, err)
+	}
+	handle := handles.add(&session{contexts: make(map[*oonimkall.Context]bool), sess: sess})
+	return newResponse(map[string]int64{"session": handle}, nil)
+}
+
+// sessionGet returns the session referred by the given handle.
+func sessionGet(handle int64) (*session, error) {
+	obj, err := handles.get(handle)
+	if err != nil {
+		return nil, err
+	}
+	sess, good := obj.(*session)
+	if !good {
+		return nil, errNotASession
+	}
+	return sess, nil
+}
+
+// do runs the given operation with a context using the given timeout in seconds,
+// where zero or negative means no timeout, and returns the JSON response.
+func (s *session) do(timeout int64, op func(ctx *oonimkall.Context) (any, error)) string {
+	ctx := s.sess.NewContextWithTimeout(timeout)
+	s.mu.Lock()
+	s.contexts[ctx] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.contexts, ctx)
+		s.mu.Unlock()
+		ctx.Cancel()
+	}()
+	return newResponse(op(ctx))
+}
+
+// interrupt interrupts all the pending operations.
+func (s *session) interrupt() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ctx := range s.contexts {
+		ctx.Cancel()
+	}
+}
+
+// geolocateResult is the result of geolocating the probe.
+type geolocateResult struct {
+	ASN     string `json:"probe_asn"`
+	Country string `json:"probe_cc"`
+	IP      string `json:"probe_ip"`
+	Org     string `json:"probe_network_name"`
+}
+
+// sessionGeolocate implements OONIEngineSessionGeolocate.
+func sessionGeolocate(handle int64, timeout int64) string {
+	sess, err := sessionGet(handle)
+	if err != nil {
+		return newResponse(nil, err)
+	}
+	return sess.do(timeout, func(ctx *oonimkall.Context) (any, error) {
+		results, err := sess.sess.Geolocate(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return &geolocateResult{
+			ASN:     results.ASN,
+			Country: results.Country,
+			IP:      results.IP,
+			Org:     results.Org,
+		}, nil
+	})
+}
+
+// checkInConfig is the JSON config for calling the check-in API.
+type checkInConfig struct {
+	Charging        bool   `json:"charging"`
+	OnWiFi          bool   `json:"on_wifi"`
+	Platform        string `json:"platform"`
+	RunType         string `json:"run_type"`
+	SoftwareName    string `json:"software_name"`
+	SoftwareVersion string `json:"software_version"`
+	WebConnectivity struct {
+		CategoryCodes []string `json:"category_codes"`
+	} `json:"web_connectivity"`
+}
+
+// checkInResultWebConnectivity is the Web Connectivity specific check-in result.
+type checkInResultWebConnectivity struct {
+	ReportID string               `json:"report_id"`
+	URLs     []model.OOAPIURLInfo `json:"urls"`
+}
+
+// checkInResult is the result of calling the check-in API.
+type checkInResult struct {
+	WebConnectivity *checkInResultWebConnectivity `json:"web_connectivity"`
+}
+
+// sessionCheckIn implements OONIEngineSessionCheckIn.
+func sessionCheckIn(handle int64, input string, timeout int64) string {
+	sess, err := sessionGet(handle)
+	if err != nil {
+		return newResponse(nil, err)
+	}
+	var config checkInConfig
+	if err := json.Unmarshal([]byte(input), &config); err != nil {
+		return newResponse(nil, err)
+	}
+	return sess.do(timeout, func(ctx *oonimkall.Context) (any, error) {
+		info, err := sess.sess.CheckIn(ctx, &oonimkall.CheckInConfig{
+			Charging:        config.Charging,
+			OnWiFi:          config.OnWiFi,
+			Platform:        config.Platform,
+			RunType:         config.RunType,
+			SoftwareName:    config.SoftwareName,
+			SoftwareVersion: config.SoftwareVersion,
+			WebConnectivity: &oonimkall.CheckInConfigWebConnectivity{
+				CategoryCodes: config.WebConnectivity.CategoryCodes,
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		result := &checkInResult{}
+		if info.WebConnectivity != nil {
+			result.WebConnectivity = &checkInResultWebConnectivity{
+				ReportID: info.WebConnectivity.ReportID,
+				URLs:     info.WebConnectivity.URLs,
+			}
+		}
+		return result, nil
+	})
+}
+
+// submitResult is the result of submitting a measurement.
+type submitResult struct {
+	UpdatedMeasurement json.RawMessage `json:"updated_measurement"`
+	UpdatedReportID    string          `json:"updated_report_id"`
+}
+
+// sessionSubmit implements OONIEngineSessionSubmit.
+func sessionSubmit(handle int64, measurement string, timeout int64) string {
+	sess, err := sessionGet(handle)
+	if err != nil {
+		return newResponse(nil, err)
+	}
+	return sess.do(timeout, func(ctx *oonimkall.Context) (any, error) {
+		results, err := sess.sess.Submit(ctx, measurement)
+		if err != nil {
+			return nil, err
+		}
+		return &submitResult{
+			UpdatedMeasurement: json.RawMessage(results.UpdatedMeasurement),
+			UpdatedReportID:    results.UpdatedReportID,
+		}, nil
+	})
+}
+
+// sessionInterrupt implements OONIEngineSessionInterrupt.
+func sessionInterrupt(handle int64) {
+	if sess, err := sessionGet(handle); err == nil {
+		sess.interrupt()
+	}
+}
+
+// sessionFree implements OONIEngineSessionFree.
+func sessionFree(handle int64) {
+	if _, err := sessionGet(handle); err != nil {
+		return
+	}
+	obj, err := handles.remove(handle)
+	if err != nil {
+		return
+	}
+	// Note: the underlying [*oonimkall.Session] closes itself when the
+	// garbage collector finalizes it, so we just need to interrupt.
+	obj.(*session).interrupt()
+}
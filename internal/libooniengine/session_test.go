@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/ooni/probe-cli/v3/internal/runtimex"
+)
+
+// mustParseResponse parses a JSON response or panics.
+func mustParseResponse(data string) map[string]any {
+	var resp map[string]any
+	runtimex.PanicOnError(json.Unmarshal([]byte(data), &resp), "json.Unmarshal failed")
+	return resp
+}
+
+// newSessionForTesting creates a new session using a proxy refusing
+// connections, such that all the network operations fail quickly.
+func newSessionForTesting(t *testing.T) int64 {
+	config := map[string]any{
+		"proxy":            "socks5://127.0.0.1:1/",
+		"software_name":    "libooniengine-tests",
+		"software_version": "0.1.0",
+		"state_dir":        t.TempDir(),
+		"temp_dir":         t.TempDir(),
+	}
+	data, err := json.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := mustParseResponse(sessionNew(string(data)))
+	if resp["failure"] != nil {
+		t.Fatal(resp["failure"])
+	}
+	handle := int64(resp["result"].(map[string]any)["session"].(float64))
+	t.Cleanup(func() {
+		sessionFree(handle)
+	})
+	return handle
+}
+
+func TestSession(t *testing.T) {
+	t.Run("sessionNew fails with invalid JSON", func(t *testing.T) {
+		resp := mustParseResponse(sessionNew("{"))
+		if resp["failure"] == nil || resp["result"] != nil {
+			t.Fatal("unexpected response", resp)
+		}
+	})
+
+	t.Run("sessionNew fails with missing mandatory settings", func(t *testing.T) {
+		resp := mustParseResponse(sessionNew("{}"))
+		if resp["failure"] == nil || resp["result"] != nil {
+			t.Fatal("unexpected response", resp)
+		}
+	})
+
+	t.Run("the operations fail with an invalid handle", func(t *testing.T) {
+		for _, data := range []string{
+			sessionGeolocate(0, 1),
+			sessionCheckIn(0, "{}", 1),
+			sessionSubmit(0, "{}", 1),
+		} {
+			resp := mustParseResponse(data)
+			if resp["failure"] != errInvalidHandle.Error() {
+				t.Fatal("unexpected response", resp)
+			}
+		}
+		sessionInterrupt(0) // should not crash
+		sessionFree(0)      // ditto
+	})
+
+	t.Run("the operations fail with a task handle", func(t *testing.T) {
+		handle := handles.add(struct{}{})
+		defer handles.remove(handle)
+		resp := mustParseResponse(sessionGeolocate(handle, 1))
+		if resp["failure"] != errNotASession.Error() {
+			t.Fatal("unexpected response", resp)
+		}
+		sessionFree(handle) // should not remove the handle
+		if _, err := handles.get(handle); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("the network operations fail when we cannot connect", func(t *testing.T) {
+		handle := newSessionForTesting(t)
+		for _, data := range []string{
+			sessionGeolocate(handle, 10),
+			sessionCheckIn(handle, `{"web_connectivity":{"category_codes":["NEWS"]}}`, 10),
+			sessionSubmit(handle, "{}", 10),
+		} {
+			resp := mustParseResponse(data)
+			if resp["failure"] == nil || resp["result"] != nil {
+				t.Fatal("unexpected response", resp)
+			}
+		}
+	})
+
+	t.Run("sessionCheckIn fails with invalid JSON", func(t *testing.T) {
+		handle := newSessionForTesting(t)
+		resp := mustParseResponse(sessionCheckIn(handle, "{", 10))
+		if resp["failure"] == nil || !strings.Contains(fmt.Sprint(resp["failure"]), "unexpected end of JSON input") {
+			t.Fatal("unexpected response", resp)
+		}
+	})
+
+	t.Run("we can interrupt pending operations", func(t *testing.T) {
+		// create a proxy that accepts connections and never replies
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer listener.Close()
+		accepted := make(chan bool, 1)
+		go func() {
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+				select {
+				case accepted <- true:
+				default:
+				}
+			}
+		}()
+		config := map[string]any{
+			"proxy":            "socks5://" + listener.Addr().String() + "/",
+			"software_name":    "libooniengine-tests",
+			"software_version": "0.1.0",
+			"state_dir":        t.TempDir(),
+			"temp_dir":         t.TempDir(),
+		}
+		data, err := json.Marshal(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp := mustParseResponse(sessionNew(string(data)))
+		handle := int64(resp["result"].(map[string]any)["session"].(float64))
+		defer sessionFree(handle)
+
+		done := make(chan string)
+		go func() {
+			done <- sessionGeolocate(handle, 0)
+		}()
+		<-accepted
+		sessionInterrupt(handle)
+		resp = mustParseResponse(<-done)
+		if resp["failure"] == nil || resp["result"] != nil {
+			t.Fatal("unexpected response", resp)
+		}
+	})
+
+	t.Run("sessionFree invalidates the handle", func(t *testing.T) {
+		handle := newSessionForTesting(t)
+		sessionFree(handle)
+		if _, err := sessionGet(handle); err != errInvalidHandle {
+			t.Fatal("unexpected error", err)
+		}
+	})
+}
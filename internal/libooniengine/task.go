@@ -0,0 +1,81 @@
+package main
+
+//
+// Tasks running experiments asynchronously
+//
+
+import (
+	"errors"
+
+	"github.com/ooni/probe-cli/v3/pkg/oonimkall"
+)
+
+// errNotATask indicates that a handle does not refer to a task.
+var errNotATask = errors.New("libooniengine: handle does not refer to a task")
+
+// taskTerminated is the event returned when a task is done or the handle is invalid.
+const taskTerminated = `{"key":"task_terminated","value":{}}`
+
+// taskStart implements OONIEngineTaskStart.
+func taskStart(settings string) string {
+	task, err := oonimkall.StartTask(settings)
+	if err != nil {
+		return newResponse(nil, err)
+	}
+	return newResponse(map[string]int64{"task": handles.add(task)}, nil)
+}
+
+// taskGet returns the task referred by the given handle.
+func taskGet(handle int64) (*oonimkall.Task, error) {
+	obj, err := handles.get(handle)
+	if err != nil {
+		return nil, err
+	}
+	task, good := obj.(*oonimkall.Task)
+	if !good {
+		return nil, errNotATask
+	}
+	return task, nil
+}
+
+// taskWaitForNextEvent implements OONIEngineTaskWaitForNextEvent.
+func taskWaitForNextEvent(handle int64) string {
+	task, err := taskGet(handle)
+	if err != nil {
+		return taskTerminated
+	}
+	return task.WaitForNextEvent()
+}
+
+// taskIsDone implements OONIEngineTaskIsDone.
+func taskIsDone(handle int64) bool {
+	task, err := taskGet(handle)
+	if err != nil {
+		return true
+	}
+	return task.IsDone()
+}
+
+// taskInterrupt implements OONIEngineTaskInterrupt.
+func taskInterrupt(handle int64) {
+	if task, err := taskGet(handle); err == nil {
+		task.Interrupt()
+	}
+}
+
+// taskFree implements OONIEngineTaskFree.
+func taskFree(handle int64) {
+	task, err := taskGet(handle)
+	if err != nil {
+		return
+	}
+	_, _ = handles.remove(handle)
+	// Interrupt the task and drain the pending events, such that the goroutine
+	// running the task does not block emitting events nobody will read.
+	task.Interrupt()
+	go func() {
+		for !task.IsDone() {
+			_ = task.WaitForNextEvent()
+		}
+	}()
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTask(t *testing.T) {
+	t.Run("taskStart fails with invalid JSON", func(t *testing.T) {
+		resp := mustParseResponse(taskStart("{"))
+		if resp["failure"] == nil || resp["result"] != nil {
+			t.Fatal("unexpected response", resp)
+		}
+	})
+
+	t.Run("we receive the task events until the task terminates", func(t *testing.T) {
+		settings := map[string]any{
+			"name":      "antani",
+			"log_level": "DEBUG",
+			"state_dir": t.TempDir(),
+			"temp_dir":  t.TempDir(),
+			"options": map[string]any{
+				"software_name":    "libooniengine-tests",
+				"software_version": "0.1.0",
+			},
+			"version": 1,
+		}
+		data, err := json.Marshal(settings)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp := mustParseResponse(taskStart(string(data)))
+		if resp["failure"] != nil {
+			t.Fatal(resp["failure"])
+		}
+		handle := int64(resp["result"].(map[string]any)["task"].(float64))
+		defer taskFree(handle)
+		var keys []string
+		for !taskIsDone(handle) {
+			ev := mustParseResponse(taskWaitForNextEvent(handle))
+			if ev["key"] == "log" {
+				continue
+			}
+			keys = append(keys, ev["key"].(string))
+		}
+		expect := []string{"status.queued", "status.started", "failure.startup", "status.end", "task_terminated"}
+		if diff := cmp.Diff(expect, keys); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("the operations behave with an invalid handle", func(t *testing.T) {
+		if taskWaitForNextEvent(0) != taskTerminated {
+			t.Fatal("expected the task terminated event")
+		}
+		if !taskIsDone(0) {
+			t.Fatal("expected the task to be done")
+		}
+		taskInterrupt(0) // should not crash
+		taskFree(0)      // ditto
+	})
+
+	t.Run("the operations behave with a session handle", func(t *testing.T) {
+		handle := handles.add(&session{})
+		defer handles.remove(handle)
+		if _, err := taskGet(handle); err != errNotATask {
+			t.Fatal("unexpected error", err)
+		}
+	})
+
+	t.Run("taskFree interrupts and releases the task", func(t *testing.T) {
+		resp := mustParseResponse(taskStart(`{"name":"example","options":{"software_name":"x","software_version":"y"}}`))
+		handle := int64(resp["result"].(map[string]any)["task"].(float64))
+		taskFree(handle)
+		if _, err := taskGet(handle); err != errInvalidHandle {
+			t.Fatal("unexpected error", err)
+		}
+	})
+}
@@ -0,0 +1,101 @@
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+//
+// Test harness for the C API. We build this file and link it against the
+// shared library in harness_test.go. The first argument is a directory we
+// can use for storing the session and task state.
+//
+
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+
+#include "engine.h"
+
+/// check exits with failure if the condition is false.
+#define check(cond)                                                    \
+  do {                                                                 \
+    if (!(cond)) {                                                     \
+      fprintf(stderr, "%s:%d: check failed: %s\n", __FILE__, __LINE__, \
+              #cond);                                                  \
+      exit(1);                                                         \
+    }                                                                  \
+  } while (0)
+
+/// contains returns whether the JSON document contains the needle and
+/// frees the JSON document returned by the engine.
+static int contains(char *json, const char *needle) {
+  int found;
+  check(json != NULL);
+  found = strstr(json, needle) != NULL;
+  if (!found) {
+    fprintf(stderr, "'%s' does not contain '%s'\n", json, needle);
+  }
+  OONIEngineFreeMemory(json);
+  return found;
+}
+
+/// parse_handle parses the handle following key and frees the JSON document.
+static int64_t parse_handle(char *json, const char *key) {
+  char *p;
+  long long handle = 0;
+  check(json != NULL);
+  p = strstr(json, key);
+  check(p != NULL);
+  check(sscanf(p + strlen(key), "%lld", &handle) == 1);
+  OONIEngineFreeMemory(json);
+  return (int64_t)handle;
+}
+
+int main(int argc, char **argv) {
+  char buffer[4096];
+  char *version;
+  int64_t session, task;
+  int terminated = 0, started = 0, failed = 0;
+
+  check(argc == 2);
+
+  // version
+  version = OONIEngineVersion();
+  check(version != NULL && strlen(version) > 0);
+  OONIEngineFreeMemory(version);
+
+  // session
+  check(contains(OONIEngineSessionNew("{"), "\"failure\":\"unexpected end of JSON input\""));
+  snprintf(buffer, sizeof(buffer),
+           "{\"software_name\":\"harness\",\"software_version\":\"0.1.0\","
+           "\"proxy\":\"socks5://127.0.0.1:1/\","
+           "\"state_dir\":\"%s\",\"temp_dir\":\"%s\"}",
+           argv[1], argv[1]);
+  session = parse_handle(OONIEngineSessionNew(buffer), "\"session\":");
+  check(session > 0);
+  check(contains(OONIEngineSessionCheckIn(session, "{", 10), "\"result\":null"));
+  check(contains(OONIEngineSessionSubmit(session, "{}", 10), "\"result\":null"));
+  OONIEngineSessionInterrupt(session);
+  OONIEngineSessionFree(session);
+  check(contains(OONIEngineSessionGeolocate(session, 10), "\"failure\":\"libooniengine: invalid handle\""));
+
+  // task
+  snprintf(buffer, sizeof(buffer),
+           "{\"name\":\"antani\",\"log_level\":\"INFO\",\"state_dir\":\"%s\","
+           "\"temp_dir\":\"%s\",\"options\":{\"software_name\":\"harness\","
+           "\"software_version\":\"0.1.0\"},\"version\":1}",
+           argv[1], argv[1]);
+  task = parse_handle(OONIEngineTaskStart(buffer), "\"task\":");
+  check(task > 0);
+  while (!OONIEngineTaskIsDone(task)) {
+    char *event = OONIEngineTaskWaitForNextEvent(task);
+    check(event != NULL);
+    started |= strstr(event, "\"key\":\"status.started\"") != NULL;
+    failed |= strstr(event, "\"key\":\"failure.startup\"") != NULL;
+    terminated |= strstr(event, "\"key\":\"task_terminated\"") != NULL;
+    OONIEngineFreeMemory(event);
+  }
+  check(started && failed && terminated);
+  OONIEngineTaskInterrupt(task);
+  OONIEngineTaskFree(task);
+  check(OONIEngineTaskIsDone(task));
+
+  printf("all checks passed\n");
+  return 0;
+}
@@ -0,0 +1,224 @@
+// Package measurementsig signs measurements using a per-installation Ed25519 key.
+//
+// We generate the key the first time we need it and store it into the key-value
+// store, so resetting the probe state (e.g., using `ooniprobe reset`) also creates
+// a new key. Before submitting a measurement, we add the public key fingerprint
+// to its annotations, sign its canonical JSON, and add the signature to the
+// annotations. Whoever archives a copy of the measurement can later use [Verify]
+// and the probe public key to prove the measurement was not altered.
+//
+// The canonical JSON is the measurement serialized as JSON without the signature
+// annotation, with object keys sorted and numbers preserved verbatim.
+package measurementsig
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ooni/probe-cli/v3/internal/kvstore"
+	"github.com/ooni/probe-cli/v3/internal/model"
+)
+
+// kvstoreKey is the key used by the key value store to store the private key.
+const kvstoreKey = "measurementsig.state"
+
+const (
+	// AnnotationSignature is the annotation containing the base64 encoded signature.
+	AnnotationSignature = "signature_ed25519"
+
+	// AnnotationKeyFingerprint is the annotation containing the public key fingerprint.
+	AnnotationKeyFingerprint = "signature_key_fingerprint"
+)
+
+var (
+	// ErrInvalidPublicKey indicates that a public key is not valid.
+	ErrInvalidPublicKey = errors.New("measurementsig: invalid public key")
+
+	// ErrInvalidSignature indicates that the measurement signature is not valid.
+	ErrInvalidSignature = errors.New("measurementsig: invalid signature")
+
+	// ErrInvalidState indicates that the stored key is not valid.
+	ErrInvalidState = errors.New("measurementsig: invalid stored key")
+
+	// ErrKeyMismatch indicates that the measurement was signed using another key.
+	ErrKeyMismatch = errors.New("measurementsig: signed using another key")
+
+	// ErrNotSigned indicates that the measurement is not signed.
+	ErrNotSigned = errors.New("measurementsig: measurement not signed")
+)
+
+// state is the state we store into the key-value store.
+type state struct {
+	// Seed is the Ed25519 private key seed.
+	Seed []byte
+}
+
+// Signer signs measurements. The zero value is invalid; use [NewSigner].
+type Signer struct {
+	private ed25519.PrivateKey
+}
+
+// NewSigner loads the private key from the given key-value store, generating
+// and storing a new private key when the store does not contain any key. When
+// the stored key is not valid, we emit a warning using the given logger and we
+// replace it with a newly generated private key.
+func NewSigner(store model.KeyValueStore, logger model.Logger) (*Signer, error) {
+	data, err := store.Get(kvstoreKey)
+	if errors.Is(err, kvstore.ErrNoSuchKey) {
+		return newSigner(store)
+	}
+	if err != nil {
+		return nil, err
+	}
+	private, err := parseState(data)
+	if err != nil {
+		logger.Warnf("measurementsig: %s; generating a new key", err.Error())
+		return newSigner(store)
+	}
+	return &Signer{private: private}, nil
+}
+
+// parseState parses the state stored into the key-value store.
+func parseState(data []byte) (ed25519.PrivateKey, error) {
+	var st state
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidState, err.Error())
+	}
+	if len(st.Seed) != ed25519.SeedSize {
+		return nil, ErrInvalidState
+	}
+	return ed25519.NewKeyFromSeed(st.Seed), nil
+}
+
+// newSigner generates a new private key and stores it into the key-value store.
+func newSigner(store model.KeyValueStore) (*Signer, error) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(&state{Seed: private.Seed()})
+	if err != nil {
+		return nil, err
+	}
+	if err := store.Set(kvstoreKey, data); err != nil {
+		return nil, err
+	}
+	return &Signer{private: private}, nil
+}
+
+// PublicKey returns the public key.
+func (s *Signer) PublicKey() ed25519.PublicKey {
+	return s.private.Public().(ed25519.PublicKey)
+}
+
+// EncodedPublicKey returns the base64 encoded public key.
+func (s *Signer) EncodedPublicKey() string {
+	return base64.StdEncoding.EncodeToString(s.PublicKey())
+}
+
+// Fingerprint returns the public key fingerprint.
+func (s *Signer) Fingerprint() string {
+	return Fingerprint(s.PublicKey())
+}
+
+// SignMeasurement adds the public key fingerprint to the measurement annotations and
+// then signs the measurement, adding the signature to the annotations. Because the
+// signature covers all the measurement fields, you should only call this method after
+// the measurement is final, i.e., right before submitting it.
+func (s *Signer) SignMeasurement(m *model.Measurement) error {
+	m.AddAnnotation(AnnotationKeyFingerprint, s.Fingerprint())
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	canonical, err := CanonicalJSON(data)
+	if err != nil {
+		return err
+	}
+	signature := ed25519.Sign(s.private, canonical)
+	m.AddAnnotation(AnnotationSignature, base64.StdEncoding.EncodeToString(signature))
+	return nil
+}
+
+// Fingerprint returns the fingerprint of the given public key, i.e., the
+// hex encoded SHA256 of the public key bytes.
+func Fingerprint(public ed25519.PublicKey) string {
+	digest := sha256.Sum256(public)
+	return hex.EncodeToString(digest[:])
+}
+
+// ParsePublicKey parses a base64 encoded public key.
+func ParsePublicKey(value string) (ed25519.PublicKey, error) {
+	data, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPublicKey, err.Error())
+	}
+	if len(data) != ed25519.PublicKeySize {
+		return nil, ErrInvalidPublicKey
+	}
+	return ed25519.PublicKey(data), nil
+}
+
+// CanonicalJSON returns the canonical JSON of the given serialized measurement, i.e.,
+// the measurement without the signature annotation, with object keys sorted and
+// numbers preserved verbatim.
+func CanonicalJSON(data []byte) ([]byte, error) {
+	value, err := decodeJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	if annotations, ok := value[annotationsKey].(map[string]any); ok {
+		delete(annotations, AnnotationSignature)
+	}
+	return json.Marshal(value)
+}
+
+// annotationsKey is the JSON key of the measurement annotations.
+const annotationsKey = "annotations"
+
+// decodeJSON decodes a serialized measurement preserving numbers verbatim.
+func decodeJSON(data []byte) (map[string]any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value map[string]any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// Verify verifies the signature of the given serialized measurement using
+// the given public key. It returns nil if the signature is valid.
+func Verify(data []byte, public ed25519.PublicKey) error {
+	value, err := decodeJSON(data)
+	if err != nil {
+		return err
+	}
+	annotations, _ := value[annotationsKey].(map[string]any)
+	encoded, _ := annotations[AnnotationSignature].(string)
+	if encoded == "" {
+		return ErrNotSigned
+	}
+	if fingerprint, _ := annotations[AnnotationKeyFingerprint].(string); fingerprint != Fingerprint(public) {
+		return ErrKeyMismatch
+	}
+	signature, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidSignature, err.Error())
+	}
+	canonical, err := CanonicalJSON(data)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(public, canonical, signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
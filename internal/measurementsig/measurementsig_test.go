@@ -0,0 +1,200 @@
+package measurementsig
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/ooni/probe-cli/v3/internal/kvstore"
+	"github.com/ooni/probe-cli/v3/internal/mocks"
+	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/must"
+	"github.com/ooni/probe-cli/v3/internal/runtimex"
+)
+
+// newMeasurement creates a new measurement for testing.
+func newMeasurement() *model.Measurement {
+	return &model.Measurement{
+		Annotations:          map[string]string{"architecture": "amd64"},
+		DataFormatVersion:    model.OOAPIReportDefaultDataFormatVersion,
+		Input:                "https://www.example.com/",
+		MeasurementRuntime:   1.2345678901234567,
+		MeasurementStartTime: "2024-03-01 10:00:01",
+		ProbeASN:             "AS30722",
+		ProbeCC:              "IT",
+		ReportID:             "20240301T100000Z_webconnectivity_IT_30722_n1_xxx",
+		SoftwareName:         "ooniprobe",
+		SoftwareVersion:      "3.21.0",
+		TestKeys: map[string]any{
+			"accessible": true,
+			"body":       "<html>è</html>",
+			"counter":    9007199254740993, // not representable as float64
+		},
+		TestName:      "web_connectivity",
+		TestStartTime: "2024-03-01 10:00:00",
+		TestVersion:   "0.5.28",
+	}
+}
+
+func TestNewSigner(t *testing.T) {
+	t.Run("we generate and store a key when there is none", func(t *testing.T) {
+		store := &kvstore.Memory{}
+		first, err := NewSigner(store, model.DiscardLogger)
+		if err != nil {
+			t.Fatal(err)
+		}
+		second, err := NewSigner(store, model.DiscardLogger)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !first.PublicKey().Equal(second.PublicKey()) {
+			t.Fatal("expected to load the same key")
+		}
+		third := runtimex.Try1(NewSigner(&kvstore.Memory{}, model.DiscardLogger))
+		if first.PublicKey().Equal(third.PublicKey()) {
+			t.Fatal("expected a different key with a new store")
+		}
+	})
+
+	t.Run("when the store fails", func(t *testing.T) {
+		expected := errors.New("mocked error")
+		store := &mocks.KeyValueStore{
+			MockGet: func(key string) ([]byte, error) {
+				return nil, expected
+			},
+		}
+		signer, err := NewSigner(store, model.DiscardLogger)
+		if !errors.Is(err, expected) {
+			t.Fatal("unexpected error", err)
+		}
+		if signer != nil {
+			t.Fatal("expected nil signer")
+		}
+	})
+
+	t.Run("when we cannot store the new key", func(t *testing.T) {
+		expected := errors.New("mocked error")
+		store := &mocks.KeyValueStore{
+			MockGet: func(key string) ([]byte, error) {
+				return nil, kvstore.ErrNoSuchKey
+			},
+			MockSet: func(key string, value []byte) error {
+				return expected
+			},
+		}
+		signer, err := NewSigner(store, model.DiscardLogger)
+		if !errors.Is(err, expected) {
+			t.Fatal("unexpected error", err)
+		}
+		if signer != nil {
+			t.Fatal("expected nil signer")
+		}
+	})
+
+	t.Run("when the stored key is invalid", func(t *testing.T) {
+		for _, value := range []string{`{`, `{"Seed":"AAAA"}`} {
+			store := &kvstore.Memory{}
+			runtimex.Try0(store.Set(kvstoreKey, []byte(value)))
+			var warnings int
+			logger := &mocks.Logger{
+				MockWarnf: func(format string, v ...interface{}) {
+					warnings++
+				},
+			}
+			signer, err := NewSigner(store, logger)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if warnings != 1 {
+				t.Fatal("expected a warning")
+			}
+			again := runtimex.Try1(NewSigner(store, model.DiscardLogger))
+			if !signer.PublicKey().Equal(again.PublicKey()) {
+				t.Fatal("expected to store the new key")
+			}
+		}
+	})
+}
+
+func TestSignAndVerify(t *testing.T) {
+	signer := runtimex.Try1(NewSigner(&kvstore.Memory{}, model.DiscardLogger))
+	meas := newMeasurement()
+	if err := signer.SignMeasurement(meas); err != nil {
+		t.Fatal(err)
+	}
+	if meas.Annotations[AnnotationKeyFingerprint] != signer.Fingerprint() {
+		t.Fatal("unexpected fingerprint annotation")
+	}
+	if meas.Annotations[AnnotationSignature] == "" {
+		t.Fatal("expected the signature annotation")
+	}
+	data := must.MarshalJSON(meas)
+
+	t.Run("with the original measurement", func(t *testing.T) {
+		public := runtimex.Try1(ParsePublicKey(signer.EncodedPublicKey()))
+		if err := Verify(data, public); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("with an indented copy of the measurement", func(t *testing.T) {
+		indented := runtimex.Try1(json.MarshalIndent(meas, "", "  "))
+		if err := Verify(indented, signer.PublicKey()); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("with an altered measurement", func(t *testing.T) {
+		var altered model.Measurement
+		must.UnmarshalJSON(data, &altered)
+		altered.ProbeASN = "AS137"
+		err := Verify(must.MarshalJSON(&altered), signer.PublicKey())
+		if !errors.Is(err, ErrInvalidSignature) {
+			t.Fatal("unexpected error", err)
+		}
+	})
+
+	t.Run("with another public key", func(t *testing.T) {
+		public, _ := runtimex.Try2(ed25519.GenerateKey(nil))
+		err := Verify(data, public)
+		if !errors.Is(err, ErrKeyMismatch) {
+			t.Fatal("unexpected error", err)
+		}
+	})
+
+	t.Run("with an unsigned measurement", func(t *testing.T) {
+		err := Verify(must.MarshalJSON(newMeasurement()), signer.PublicKey())
+		if !errors.Is(err, ErrNotSigned) {
+			t.Fatal("unexpected error", err)
+		}
+	})
+
+	t.Run("with a malformed signature", func(t *testing.T) {
+		var altered model.Measurement
+		must.UnmarshalJSON(data, &altered)
+		altered.Annotations[AnnotationSignature] = "@@@"
+		err := Verify(must.MarshalJSON(&altered), signer.PublicKey())
+		if !errors.Is(err, ErrInvalidSignature) {
+			t.Fatal("unexpected error", err)
+		}
+	})
+
+	t.Run("with invalid JSON", func(t *testing.T) {
+		if err := Verify([]byte(`{`), signer.PublicKey()); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
+
+func TestParsePublicKey(t *testing.T) {
+	for _, value := range []string{"@@@", "AAAA"} {
+		public, err := ParsePublicKey(value)
+		if !errors.Is(err, ErrInvalidPublicKey) {
+			t.Fatal("unexpected error", err)
+		}
+		if public != nil {
+			t.Fatal("expected nil public key")
+		}
+	}
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/ooni/probe-cli/v3/internal/bytecounter"
 	"github.com/ooni/probe-cli/v3/internal/model"
 	"github.com/ooni/probe-cli/v3/internal/netxlite"
+	"github.com/ooni/probe-cli/v3/internal/packetcapture"
 )
 
 // NewDialerWithoutResolver is equivalent to [netxlite.Netx.NewDialerWithoutResolver]
@@ -41,7 +42,18 @@ var _ model.Dialer = &dialerTrace{}
 func (d *dialerTrace) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
 	// Here we make sure that we're counting bytes sent and received.
 	dialer := bytecounter.WrapWithContextAwareDialer(d.d)
-	return dialer.DialContext(netxlite.ContextWithTrace(ctx, d.tx), network, address)
+
+	// Here we make sure that a packet capture, if any, maps the packets of
+	// this connection to this trace. We register the remote endpoint before
+	// connecting to also map the packets of connections that fail.
+	capture := packetcapture.ContextCapture(ctx)
+	capture.AddRemote(d.tx.Index(), network, address)
+	conn, err := dialer.DialContext(netxlite.ContextWithTrace(ctx, d.tx), network, address)
+	if err != nil {
+		return nil, err
+	}
+	capture.AddConnFlow(d.tx.Index(), network, conn)
+	return conn, nil
 }
 
 // CloseIdleConnections implements model.Dialer.CloseIdleConnections.
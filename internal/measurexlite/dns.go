@@ -108,6 +108,14 @@ func (tx *Trace) NewParallelDNSOverHTTPSResolver(logger model.DebugLogger, URL s
 	return tx.wrapResolver(tx.Netx.NewParallelDNSOverHTTPSResolver(logger, URL))
 }
 
+// NewParallelDNSOverTLSResolver returns a trace-aware parallel DoT resolver using
+// this trace's dialer and TLS handshaker, so that we also collect observations about
+// the TCP connect and the TLS handshake with the given endpoint (e.g., 8.8.8.8:853).
+func (tx *Trace) NewParallelDNSOverTLSResolver(logger model.DebugLogger, address string) model.Resolver {
+	tlsDialer := netxlite.NewTLSDialer(tx.NewDialerWithoutResolver(logger), tx.NewTLSHandshakerStdlib(logger))
+	return tx.wrapResolver(netxlite.NewParallelDNSOverTLSResolver(logger, tlsDialer, address))
+}
+
 // OnDNSRoundTripForLookupHost implements model.Trace.OnDNSRoundTripForLookupHost
 func (tx *Trace) OnDNSRoundTripForLookupHost(started time.Time, reso model.Resolver, query model.DNSQuery,
 	response model.DNSResponse, addrs []string, err error, finished time.Time) {
@@ -311,6 +311,22 @@ func TestNewWrappedResolvers(t *testing.T) {
 		}
 	})
 
+	t.Run("NewParallelDNSOverTLSResolver works as intended", func(t *testing.T) {
+		zeroTime := time.Now()
+		trace := NewTrace(0, zeroTime)
+		resolver := trace.NewParallelDNSOverTLSResolver(model.DiscardLogger, "8.8.8.8:853")
+		resolvert := resolver.(*resolverTrace)
+		if resolvert.tx != trace {
+			t.Fatal("invalid trace")
+		}
+		if resolver.Network() != "dot" {
+			t.Fatal("unexpected resolver network")
+		}
+		if resolver.Address() != "8.8.8.8:853" {
+			t.Fatal("unexpected resolver address")
+		}
+	})
+
 	t.Run("NewParallelUDPResolver works as intended", func(t *testing.T) {
 		zeroTime := time.Now()
 		trace := NewTrace(0, zeroTime)
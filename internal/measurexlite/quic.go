@@ -11,6 +11,7 @@ import (
 
 	"github.com/ooni/probe-cli/v3/internal/model"
 	"github.com/ooni/probe-cli/v3/internal/netxlite"
+	"github.com/ooni/probe-cli/v3/internal/packetcapture"
 	"github.com/quic-go/quic-go"
 )
 
@@ -40,7 +41,17 @@ func (qdx *quicDialerTrace) DialContext(ctx context.Context,
 	address string, tlsConfig *tls.Config, quicConfig *quic.Config) (
 	quic.EarlyConnection, error) {
 	// TODO(https://github.com/ooni/probe/issues/2665)
-	return qdx.qd.DialContext(netxlite.ContextWithTrace(ctx, qdx.tx), address, tlsConfig, quicConfig)
+
+	// Like we do for dialers, make sure a packet capture, if any,
+	// maps the packets of this connection to this trace.
+	capture := packetcapture.ContextCapture(ctx)
+	capture.AddRemote(qdx.tx.Index(), "udp", address)
+	qconn, err := qdx.qd.DialContext(netxlite.ContextWithTrace(ctx, qdx.tx), address, tlsConfig, quicConfig)
+	if err != nil {
+		return nil, err
+	}
+	capture.AddConnFlow(qdx.tx.Index(), "udp", qconn)
+	return qconn, nil
 }
 
 // CloseIdleConnections implements model.QUICDialer.CloseIdleConnections.
@@ -2,6 +2,7 @@ package mocks
 
 import (
 	"database/sql"
+	"time"
 
 	"github.com/ooni/probe-cli/v3/internal/model"
 )
@@ -21,9 +22,17 @@ type Database struct {
 	MockUploadFailed       func(msmt *model.DatabaseMeasurement, failure string) error
 	MockUploadSucceeded    func(msmt *model.DatabaseMeasurement) error
 	MockFailed             func(msmt *model.DatabaseMeasurement, failure string) error
+	MockNetworkChanged     func(msmt *model.DatabaseMeasurement) error
 	MockListResults        func() ([]model.DatabaseResultNetwork, []model.DatabaseResultNetwork, error)
 	MockListMeasurements   func(resultID int64) ([]model.DatabaseMeasurementURLNetwork, error)
 	MockGetMeasurementJSON func(msmtID int64) (map[string]interface{}, error)
+
+	MockCreateOONIRunResult       func(homePath string, subscriptionID int64, networkID int64) (*model.DatabaseResult, error)
+	MockCreateOONIRunSubscription func(URL string, descriptor string) (*model.DatabaseOONIRunSubscription, error)
+	MockUpdateOONIRunSubscription func(sub *model.DatabaseOONIRunSubscription) error
+	MockDeleteOONIRunSubscription func(subscriptionID int64) error
+	MockListOONIRunSubscriptions  func() ([]model.DatabaseOONIRunSubscription, error)
+	MockListURLsLastMeasured      func(testName string, asn uint) (map[string]time.Time, error)
 }
 
 var _ model.WritableDatabase = &Database{}
@@ -89,6 +98,31 @@ func (d *Database) Failed(msmt *model.DatabaseMeasurement, failure string) error
 	return d.MockFailed(msmt, failure)
 }
 
+// NetworkChanged calls MockNetworkChanged
+func (d *Database) NetworkChanged(msmt *model.DatabaseMeasurement) error {
+	return d.MockNetworkChanged(msmt)
+}
+
+// CreateOONIRunResult calls MockCreateOONIRunResult
+func (d *Database) CreateOONIRunResult(homePath string, subscriptionID int64, networkID int64) (*model.DatabaseResult, error) {
+	return d.MockCreateOONIRunResult(homePath, subscriptionID, networkID)
+}
+
+// CreateOONIRunSubscription calls MockCreateOONIRunSubscription
+func (d *Database) CreateOONIRunSubscription(URL string, descriptor string) (*model.DatabaseOONIRunSubscription, error) {
+	return d.MockCreateOONIRunSubscription(URL, descriptor)
+}
+
+// UpdateOONIRunSubscription calls MockUpdateOONIRunSubscription
+func (d *Database) UpdateOONIRunSubscription(sub *model.DatabaseOONIRunSubscription) error {
+	return d.MockUpdateOONIRunSubscription(sub)
+}
+
+// DeleteOONIRunSubscription calls MockDeleteOONIRunSubscription
+func (d *Database) DeleteOONIRunSubscription(subscriptionID int64) error {
+	return d.MockDeleteOONIRunSubscription(subscriptionID)
+}
+
 var _ model.ReadableDatabase = &Database{}
 
 // ListResults calla MockListResults
@@ -105,3 +139,13 @@ func (d *Database) ListMeasurements(resultID int64) ([]model.DatabaseMeasurement
 func (d *Database) GetMeasurementJSON(msmtID int64) (map[string]interface{}, error) {
 	return d.MockGetMeasurementJSON(msmtID)
 }
+
+// ListURLsLastMeasured calls MockListURLsLastMeasured
+func (d *Database) ListURLsLastMeasured(testName string, asn uint) (map[string]time.Time, error) {
+	return d.MockListURLsLastMeasured(testName, asn)
+}
+
+// ListOONIRunSubscriptions calls MockListOONIRunSubscriptions
+func (d *Database) ListOONIRunSubscriptions() ([]model.DatabaseOONIRunSubscription, error) {
+	return d.MockListOONIRunSubscriptions()
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/ooni/probe-cli/v3/internal/model"
 )
@@ -180,6 +181,19 @@ func TestDatabase(t *testing.T) {
 		}
 	})
 
+	t.Run("NetworkChanged", func(t *testing.T) {
+		expected := errors.New("mocked")
+		db := &Database{
+			MockNetworkChanged: func(msmt *model.DatabaseMeasurement) error {
+				return expected
+			},
+		}
+		err := db.NetworkChanged(&model.DatabaseMeasurement{})
+		if !errors.Is(err, expected) {
+			t.Fatal("not the error we expected")
+		}
+	})
+
 	t.Run("Failed", func(t *testing.T) {
 		expected := errors.New("mocked")
 		db := &Database{
@@ -243,4 +257,94 @@ func TestDatabase(t *testing.T) {
 			t.Fatal("not the error we expected")
 		}
 	})
+
+	t.Run("CreateOONIRunResult", func(t *testing.T) {
+		expected := errors.New("mocked")
+		db := &Database{
+			MockCreateOONIRunResult: func(homePath string, subscriptionID, networkID int64) (*model.DatabaseResult, error) {
+				return nil, expected
+			},
+		}
+		result, err := db.CreateOONIRunResult("", 0, 0)
+		if result != nil {
+			t.Fatal("expected nil result")
+		}
+		if !errors.Is(err, expected) {
+			t.Fatal("not the error we expected")
+		}
+	})
+
+	t.Run("CreateOONIRunSubscription", func(t *testing.T) {
+		expected := errors.New("mocked")
+		db := &Database{
+			MockCreateOONIRunSubscription: func(URL, descriptor string) (*model.DatabaseOONIRunSubscription, error) {
+				return nil, expected
+			},
+		}
+		sub, err := db.CreateOONIRunSubscription("https://example.com/", "{}")
+		if sub != nil {
+			t.Fatal("expected nil subscription")
+		}
+		if !errors.Is(err, expected) {
+			t.Fatal("not the error we expected")
+		}
+	})
+
+	t.Run("UpdateOONIRunSubscription", func(t *testing.T) {
+		expected := errors.New("mocked")
+		db := &Database{
+			MockUpdateOONIRunSubscription: func(sub *model.DatabaseOONIRunSubscription) error {
+				return expected
+			},
+		}
+		err := db.UpdateOONIRunSubscription(&model.DatabaseOONIRunSubscription{})
+		if !errors.Is(err, expected) {
+			t.Fatal("not the error we expected")
+		}
+	})
+
+	t.Run("DeleteOONIRunSubscription", func(t *testing.T) {
+		expected := errors.New("mocked")
+		db := &Database{
+			MockDeleteOONIRunSubscription: func(subscriptionID int64) error {
+				return expected
+			},
+		}
+		err := db.DeleteOONIRunSubscription(0)
+		if !errors.Is(err, expected) {
+			t.Fatal("not the error we expected")
+		}
+	})
+
+	t.Run("ListOONIRunSubscriptions", func(t *testing.T) {
+		expected := errors.New("mocked")
+		db := &Database{
+			MockListOONIRunSubscriptions: func() ([]model.DatabaseOONIRunSubscription, error) {
+				return nil, expected
+			},
+		}
+		subs, err := db.ListOONIRunSubscriptions()
+		if subs != nil {
+			t.Fatal("expected nil subscriptions")
+		}
+		if !errors.Is(err, expected) {
+			t.Fatal("not the error we expected")
+		}
+	})
+
+	t.Run("ListURLsLastMeasured", func(t *testing.T) {
+		expected := errors.New("mocked")
+		db := &Database{
+			MockListURLsLastMeasured: func(testName string, asn uint) (map[string]time.Time, error) {
+				return nil, expected
+			},
+		}
+		urls, err := db.ListURLsLastMeasured("web_connectivity", 30722)
+		if urls != nil {
+			t.Fatal("expected nil urls")
+		}
+		if !errors.Is(err, expected) {
+			t.Fatal("not the error we expected")
+		}
+	})
 }
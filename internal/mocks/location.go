@@ -7,6 +7,8 @@ type LocationProvider struct {
 	MockProbeASNString   func() string
 	MockProbeCC          func() string
 	MockProbeIP          func() string
+	MockProbeIPv4Egress  func() *model.LocationEgress
+	MockProbeIPv6Egress  func() *model.LocationEgress
 	MockProbeNetworkName func() string
 	MockResolverIP       func() string
 }
@@ -33,6 +35,16 @@ func (loc *LocationProvider) ProbeIP() string {
 	return loc.MockProbeIP()
 }
 
+// ProbeIPv4Egress calls MockProbeIPv4Egress
+func (loc *LocationProvider) ProbeIPv4Egress() *model.LocationEgress {
+	return loc.MockProbeIPv4Egress()
+}
+
+// ProbeIPv6Egress calls MockProbeIPv6Egress
+func (loc *LocationProvider) ProbeIPv6Egress() *model.LocationEgress {
+	return loc.MockProbeIPv6Egress()
+}
+
 // ProbeNetworkName calls MockProbeNetworkName
 func (loc *LocationProvider) ProbeNetworkName() string {
 	return loc.MockProbeNetworkName()
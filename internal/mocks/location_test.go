@@ -1,6 +1,10 @@
 package mocks
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/ooni/probe-cli/v3/internal/model"
+)
 
 func TestLocationProvider(t *testing.T) {
 	t.Run("ProbeASN", func(t *testing.T) {
@@ -55,6 +59,32 @@ func TestLocationProvider(t *testing.T) {
 		}
 	})
 
+	t.Run("ProbeIPv4Egress", func(t *testing.T) {
+		expected := &model.LocationEgress{}
+		loc := LocationProvider{
+			MockProbeIPv4Egress: func() *model.LocationEgress {
+				return expected
+			},
+		}
+		r := loc.ProbeIPv4Egress()
+		if r != expected {
+			t.Fatal("not the egress we expected")
+		}
+	})
+
+	t.Run("ProbeIPv6Egress", func(t *testing.T) {
+		expected := &model.LocationEgress{}
+		loc := LocationProvider{
+			MockProbeIPv6Egress: func() *model.LocationEgress {
+				return expected
+			},
+		}
+		r := loc.ProbeIPv6Egress()
+		if r != expected {
+			t.Fatal("not the egress we expected")
+		}
+	})
+
 	t.Run("ProbeNetworkName", func(t *testing.T) {
 		expected := "mocked"
 		loc := LocationProvider{
@@ -9,6 +9,10 @@ import (
 	"time"
 )
 
+// OONIRunGroupName is the test group name of the results containing the
+// measurements collected by running the OONI Run v2 subscriptions.
+const OONIRunGroupName = "oonirun"
+
 // WritableDatabase supports writing and updating data.
 type WritableDatabase interface {
 	// CreateNetwork will create a new network in the network table
@@ -147,6 +151,58 @@ type WritableDatabase interface {
 	//
 	// Returns a non-nil error if the measurement update failed
 	Failed(msmt *DatabaseMeasurement, failure string) error
+
+	// NetworkChanged marks the measurement as possibly affected by a network change
+	//
+	// Arguments:
+	//
+	// - msmt is the database measurement to update
+	//
+	// Returns a non-nil error if the measurement update failed
+	NetworkChanged(msmt *DatabaseMeasurement) error
+
+	// CreateOONIRunResult is like CreateResult but creates a result for the
+	// nettests inside the descriptor of the given OONI Run subscription
+	//
+	// Arguments:
+	//
+	// - homePath is the home directory path to make the results directory
+	//
+	// - subscriptionID is the id of the OONI Run subscription
+	//
+	// - networkID is the id of the underlying network
+	//
+	// Returns either a database result instance or an error
+	CreateOONIRunResult(homePath string, subscriptionID int64, networkID int64) (*DatabaseResult, error)
+
+	// CreateOONIRunSubscription subscribes to an OONI Run v2 link
+	//
+	// Arguments:
+	//
+	// - URL is the URL of the OONI Run v2 descriptor
+	//
+	// - descriptor is the JSON serialized descriptor accepted by the user
+	//
+	// Returns either the new database subscription or an error
+	CreateOONIRunSubscription(URL string, descriptor string) (*DatabaseOONIRunSubscription, error)
+
+	// UpdateOONIRunSubscription writes the subscription to the database
+	//
+	// Arguments:
+	//
+	// - sub is the database subscription to update
+	//
+	// Returns a non-nil error if the subscription update failed
+	UpdateOONIRunSubscription(sub *DatabaseOONIRunSubscription) error
+
+	// DeleteOONIRunSubscription unsubscribes from an OONI Run v2 link
+	//
+	// Arguments:
+	//
+	// - subscriptionID is the id of the subscription to delete
+	//
+	// Returns a non-nil error if the subscription could not be deleted
+	DeleteOONIRunSubscription(subscriptionID int64) error
 }
 
 // ReadableDatabase only supports reading data.
@@ -175,6 +231,24 @@ type ReadableDatabase interface {
 	//
 	// Returns the measurement JSON or an error
 	GetMeasurementJSON(msmtID int64) (map[string]interface{}, error)
+
+	// ListURLsLastMeasured returns when we last measured each URL
+	//
+	// Arguments:
+	//
+	// - testName is the name of the test that measured the URLs
+	//
+	// - asn is the ASN of the network where we measured the URLs
+	//
+	// Returns a map from each URL to its last measurement time or an error
+	ListURLsLastMeasured(testName string, asn uint) (map[string]time.Time, error)
+
+	// ListOONIRunSubscriptions returns the OONI Run subscriptions
+	//
+	// Arguments:
+	//
+	// Returns the subscriptions sorted by id or an error
+	ListOONIRunSubscriptions() ([]DatabaseOONIRunSubscription, error)
 }
 
 // ResultNetwork is used to represent the structure made from the JOIN
@@ -209,6 +283,11 @@ type DatabaseNetwork struct {
 	IP          string `db:"ip"`
 	ASN         uint   `db:"asn"`
 	CountryCode string `db:"network_country_code"`
+	IPv4        string `db:"ipv4"`
+	IPv4ASN     uint   `db:"ipv4_asn"`
+	IPv6        string `db:"ipv6"`
+	IPv6ASN     uint   `db:"ipv6_asn"`
+	IsDualStack bool   `db:"is_dual_stack"`
 }
 
 // DatabaseURL represents URLs from the testing lists
@@ -232,6 +311,7 @@ type DatabaseMeasurement struct {
 	IsUploadFailed   bool           `db:"measurement_is_upload_failed"`
 	UploadFailureMsg sql.NullString `db:"measurement_upload_failure_msg,omitempty"`
 	IsRerun          bool           `db:"measurement_is_rerun"`
+	IsNetworkChanged bool           `db:"measurement_is_network_changed"`
 	ReportID         sql.NullString `db:"report_id,omitempty"`
 	URLID            sql.NullInt64  `db:"url_id,omitempty"` // Used to reference URL
 	MeasurementID    sql.NullInt64  `db:"collector_measurement_id,omitempty"`
@@ -256,6 +336,22 @@ type DatabaseResult struct {
 	DataUsageUp    float64   `db:"result_data_usage_up"`
 	DataUsageDown  float64   `db:"result_data_usage_down"`
 	MeasurementDir string    `db:"measurement_dir"`
+	// OONIRunSubscriptionID references the OONI Run subscription, if any, whose
+	// descriptor contains the nettests that produced this result.
+	OONIRunSubscriptionID sql.NullInt64 `db:"oonirun_subscription_id,omitempty"`
+}
+
+// DatabaseOONIRunSubscription is an OONI Run v2 link the user subscribed to
+type DatabaseOONIRunSubscription struct {
+	ID  int64  `db:"subscription_id,omitempty"`
+	URL string `db:"subscription_url"`
+	// Descriptor is the JSON serialized descriptor accepted by the user
+	Descriptor string `db:"subscription_descriptor"`
+	// PendingDescriptor is the JSON serialized descriptor that changed when
+	// refreshing and needs to be accepted or rejected by the user (or empty)
+	PendingDescriptor string    `db:"subscription_pending_descriptor"`
+	CreatedAt         time.Time `db:"subscription_created_at"`
+	RefreshedAt       time.Time `db:"subscription_refreshed_at"`
 }
 
 // PerformanceTestKeys is the result summary for a performance test
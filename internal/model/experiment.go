@@ -10,6 +10,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
 )
 
 // ErrNoAvailableTestHelpers is emitted when there are no available test helpers.
@@ -284,6 +285,71 @@ type ExperimentTargetLoaderConfig struct {
 	// per line. We will fail if any file is unreadable
 	// as well as if any file is empty.
 	SourceFiles []string
+
+	// Sampling contains OPTIONAL settings for sampling the targets returned by
+	// the check-in API. If not set, we use the targets in the returned order.
+	Sampling *ExperimentTargetSamplingConfig
+
+	// RemoteLists contains OPTIONAL settings for loading signed lists of
+	// targets from remote HTTPS servers in addition to local inputs.
+	RemoteLists *ExperimentTargetRemoteListsConfig
+}
+
+// ExperimentTargetRemoteListsConfig contains the settings for loading
+// signed lists of targets from remote HTTPS servers.
+type ExperimentTargetRemoteListsConfig struct {
+	// HTTPClient is the MANDATORY HTTP client for fetching the lists.
+	HTTPClient HTTPClient
+
+	// KVStore is the MANDATORY key-value store where we cache the lists
+	// we fetched and verified. When we cannot fetch or verify a list, we
+	// fall back to using the cached copy, if any.
+	KVStore KeyValueStore
+
+	// Lists contains the MANDATORY lists to load.
+	Lists []ExperimentTargetRemoteList
+
+	// UserAgent is the OPTIONAL User-Agent header to use.
+	UserAgent string
+}
+
+// ExperimentTargetRemoteList is a signed list of targets served by a remote HTTPS
+// server. The list format is either one URL per line or the citizenlab test-lists
+// CSV format. The server MUST serve a detached Ed25519 signature of the list, encoded
+// using base64, at the URL obtained by appending ".sig" to the list URL.
+type ExperimentTargetRemoteList struct {
+	// URL is the MANDATORY URL of the list.
+	URL string `json:"url"`
+
+	// PublicKey is the MANDATORY base64-encoded Ed25519 public key
+	// that we should use for verifying the list signature.
+	PublicKey string `json:"public_key"`
+}
+
+// ExperimentTargetSamplingConfig controls how we sample the targets returned by the check-in
+// API, so that runs with limited runtime cover the whole list more evenly over time.
+type ExperimentTargetSamplingConfig struct {
+	// CategoryPriorities contains OPTIONAL weights for category codes. A category with
+	// a higher weight is more likely to be measured first. A missing category has
+	// weight one and a category with zero or negative weight is measured last.
+	CategoryPriorities map[string]float64
+
+	// DeduplicateDomains OPTIONALLY moves the targets whose domain we have already
+	// seen after all the targets with a previously unseen domain.
+	DeduplicateDomains bool
+
+	// LastMeasured contains the OPTIONAL time when we last measured each target URL
+	// on the current network. Targets not measured for at least StaleAfter come
+	// first, followed by the other targets, least recently measured first.
+	LastMeasured map[string]time.Time
+
+	// Seed is the OPTIONAL seed for the random generator. When zero, we use
+	// the current time, otherwise the sampling is reproducible.
+	Seed int64
+
+	// StaleAfter is the OPTIONAL interval after which we consider stale a target
+	// URL contained in LastMeasured. When zero, we use one week.
+	StaleAfter time.Duration
 }
 
 // ExperimentTargetLoaderSession is the session according to [ExperimentTargetLoader].
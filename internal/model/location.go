@@ -7,10 +7,29 @@ type LocationProvider interface {
 	ProbeASNString() string
 	ProbeCC() string
 	ProbeIP() string
+	ProbeIPv4Egress() *LocationEgress
+	ProbeIPv6Egress() *LocationEgress
 	ProbeNetworkName() string
 	ResolverIP() string
 }
 
+// LocationEgress contains information about the egress address of a specific
+// address family. Dual-stack probes have both an IPv4 and an IPv6 egress, which
+// may belong to different networks (e.g., with NAT64 or CGNAT).
+type LocationEgress struct {
+	// ASN is the autonomous system number of the egress address.
+	ASN uint
+
+	// CountryCode is the country code of the egress address.
+	CountryCode string
+
+	// NetworkName is the network name of the egress address.
+	NetworkName string
+
+	// ProbeIP is the egress address.
+	ProbeIP string
+}
+
 // LocationASN contains ASN information related to a location.
 type LocationASN struct {
 	ASNumber     uint
@@ -179,6 +179,10 @@ type HTTPSSvc struct {
 	// ALPN contains the ALPNs inside the HTTPS reply.
 	ALPN []string
 
+	// ECHConfig contains the ECHConfigList inside the HTTPS
+	// reply (which may be empty).
+	ECHConfig []byte
+
 	// IPv4 contains the IPv4 hints (which may be empty).
 	IPv4 []string
 
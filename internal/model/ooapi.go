@@ -296,6 +296,15 @@ type OOAPICollectorOpenResponse struct {
 
 	// SupportedFormats contains supported formats.
 	SupportedFormats []string `json:"supported_formats"`
+
+	// MaxBatchSize is the OPTIONAL maximum number of measurements the
+	// collector accepts in a single batch update request. When zero or
+	// one, the collector does not support batch updates.
+	MaxBatchSize int `json:"max_batch_size,omitempty"`
+
+	// SupportedEncodings contains the OPTIONAL content encodings (e.g.,
+	// "gzip", "zstd") the collector accepts for request bodies.
+	SupportedEncodings []string `json:"supported_encodings,omitempty"`
 }
 
 // OOAPICollectorUpdateRequest is a request for the collector update API.
@@ -313,6 +322,22 @@ type OOAPICollectorUpdateResponse struct {
 	MeasurementUID string `json:"measurement_uid"`
 }
 
+// OOAPICollectorBatchUpdateRequest is a request for the collector batch update API.
+type OOAPICollectorBatchUpdateRequest struct {
+	// Format is the Content's data format
+	Format string `json:"format"`
+
+	// Content contains the actual reports
+	Content []any `json:"content"`
+}
+
+// OOAPICollectorBatchUpdateResponse is the response from the collector batch update API.
+type OOAPICollectorBatchUpdateResponse struct {
+	// MeasurementUIDs contains the measurement UIDs in the same
+	// order of the measurements in the request.
+	MeasurementUIDs []string `json:"measurement_uids"`
+}
+
 // OOAPILoginCredentials contains the login credentials
 type OOAPILoginCredentials struct {
 	Username string `json:"username"`
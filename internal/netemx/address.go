@@ -89,3 +89,6 @@ const AddressOpenDNS220 = "208.67.220.220"
 
 // AddressNextDNSIo is a dns.nextdns.io address.
 const AddressNextDNSIo = "38.175.119.129"
+
+// AddressTURNCloudflareCom is a turn.cloudflare.com address.
+const AddressTURNCloudflareCom = "162.159.207.1"
@@ -15,6 +15,7 @@ import (
 	"github.com/ooni/netem"
 	"github.com/ooni/probe-cli/v3/internal/logx"
 	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/packetcapture"
 	"github.com/ooni/probe-cli/v3/internal/runtimex"
 )
 
@@ -59,6 +60,17 @@ func QAEnvOptionClientNICWrapper(wrapper netem.LinkNICWrapper) QAEnvOption {
 	}
 }
 
+// QAEnvOptionPacketCapture captures the packets sent and received by the client
+// using the given [*packetcapture.Capture], which should be created using [packetcapture.New]. This
+// option overrides [QAEnvOptionClientNICWrapper]. Remember to store the capture into the
+// context using [packetcapture.WithCapture] such that measurexlite registers the flows.
+func QAEnvOptionPacketCapture(capture *packetcapture.Capture) QAEnvOption {
+	runtimex.Assert(capture != nil, "passed nil capture")
+	return func(config *qaEnvConfig) {
+		config.clientNICWrapper = capture
+	}
+}
+
 // QAEnvOptionLogger sets the logger to use. If you do not set this option we
 // will use [model.DiscardLogger] as the logger.
 func QAEnvOptionLogger(logger model.Logger) QAEnvOption {
@@ -37,6 +37,9 @@ const (
 	// ScenarioRoleBadSSL means that the host hosts services to
 	// measure against common TLS issues.
 	ScenarioRoleBadSSL
+
+	// ScenarioRoleTURNServer means that the host is a STUN/TURN server.
+	ScenarioRoleTURNServer
 )
 
 // ScenarioDomainAddresses describes a domain and address used in a scenario.
@@ -267,6 +270,14 @@ var InternetScenario = []*ScenarioDomainAddresses{{
 	Role:             ScenarioRolePublicDNS,
 	ServerNameMain:   "dns.nextdns.io",
 	ServerNameExtras: []string{},
+}, {
+	Domains: []string{"turn.cloudflare.com"},
+	Addresses: []string{
+		AddressTURNCloudflareCom,
+	},
+	Role:             ScenarioRoleTURNServer,
+	ServerNameMain:   "turn.cloudflare.com",
+	ServerNameExtras: []string{},
 }}
 
 // MustNewScenario constructs a complete testing scenario using the domains and IP
@@ -390,6 +401,16 @@ func MustNewScenario(config []*ScenarioDomainAddresses) *QAEnv {
 			for _, addr := range sad.Addresses {
 				opts = append(opts, qaEnvOptionNetStack(addr, &BadSSLServerFactory{}))
 			}
+
+		case ScenarioRoleTURNServer:
+			for _, addr := range sad.Addresses {
+				opts = append(opts, QAEnvOptionNetStack(addr, &TURNServerFactory{
+					Ports:            []int{3478},
+					ServerNameMain:   sad.ServerNameMain,
+					ServerNameExtras: sad.ServerNameExtras,
+					TLSPorts:         []int{5349},
+				}))
+			}
 		}
 	}
 
@@ -0,0 +1,188 @@
+package netemx
+
+import (
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/ooni/netem"
+	"github.com/ooni/probe-cli/v3/internal/runtimex"
+	"github.com/pion/turn/v2"
+)
+
+// TURNServerUsername is the username accepted by the TURN servers
+// created by [TURNServerFactory] when the Username field is empty.
+const TURNServerUsername = "ooni"
+
+// TURNServerPassword is the password accepted by the TURN servers
+// created by [TURNServerFactory] when the Password field is empty.
+const TURNServerPassword = "antani"
+
+// TURNServerFactory implements [NetStackServerFactory] for STUN/TURN servers.
+//
+// The constructed server answers to STUN binding requests and TURN allocations
+// over UDP and TCP on [TURNServerFactory.Ports] and over TLS on [TURNServerFactory.TLSPorts].
+// The relayed addresses are allocated on the same [netem.UNetStack].
+//
+// Use this factory along with [QAEnvOptionNetStack] to create STUN/TURN servers.
+type TURNServerFactory struct {
+	// Password is the OPTIONAL password to accept (default: [TURNServerPassword]).
+	Password string
+
+	// Ports is the MANDATORY list of UDP and TCP ports where to listen (e.g., 3478).
+	Ports []int
+
+	// Realm is the OPTIONAL realm to use (default: "ooni.org").
+	Realm string
+
+	// ServerNameMain is the MANDATORY server name to use for TLS.
+	ServerNameMain string
+
+	// ServerNameExtras contains OPTIONAL extra server names we should configure.
+	ServerNameExtras []string
+
+	// TLSPorts is the OPTIONAL list of TLS ports where to listen (e.g., 5349).
+	TLSPorts []int
+
+	// Username is the OPTIONAL username to accept (default: [TURNServerUsername]).
+	Username string
+}
+
+var _ NetStackServerFactory = &TURNServerFactory{}
+
+// MustNewServer implements NetStackServerFactory.
+func (f *TURNServerFactory) MustNewServer(env NetStackServerFactoryEnv, stack *netem.UNetStack) NetStackServer {
+	return &turnServer{
+		closers: []io.Closer{},
+		factory: f,
+		mu:      sync.Mutex{},
+		unet:    stack,
+	}
+}
+
+type turnServer struct {
+	closers []io.Closer
+	factory *TURNServerFactory
+	mu      sync.Mutex
+	unet    *netem.UNetStack
+}
+
+// Close implements NetStackServer.
+func (srv *turnServer) Close() error {
+	// make the method locked as requested by the documentation
+	defer srv.mu.Unlock()
+	srv.mu.Lock()
+
+	// close each of the closers
+	for _, closer := range srv.closers {
+		_ = closer.Close()
+	}
+
+	// be idempotent
+	srv.closers = []io.Closer{}
+	return nil
+}
+
+// MustStart implements NetStackServer.
+func (srv *turnServer) MustStart() {
+	// make the method locked as requested by the documentation
+	defer srv.mu.Unlock()
+	srv.mu.Lock()
+
+	ipAddr := net.ParseIP(srv.unet.IPAddress())
+	runtimex.Assert(ipAddr != nil, "invalid IP address")
+	relayGen := &turnRelayAddressGenerator{ipAddr: ipAddr, unet: srv.unet}
+
+	config := turn.ServerConfig{
+		AuthHandler: srv.authHandler,
+		Realm:       srv.realm(),
+	}
+
+	// create the UDP and TCP listeners
+	for _, port := range srv.factory.Ports {
+		pconn := runtimex.Try1(srv.unet.ListenUDP("udp", &net.UDPAddr{IP: ipAddr, Port: port}))
+		config.PacketConnConfigs = append(config.PacketConnConfigs, turn.PacketConnConfig{
+			PacketConn:            pconn,
+			RelayAddressGenerator: relayGen,
+		})
+		listener := runtimex.Try1(srv.unet.ListenTCP("tcp", &net.TCPAddr{IP: ipAddr, Port: port}))
+		config.ListenerConfigs = append(config.ListenerConfigs, turn.ListenerConfig{
+			Listener:              listener,
+			RelayAddressGenerator: relayGen,
+		})
+	}
+
+	// create the TLS listeners
+	for _, port := range srv.factory.TLSPorts {
+		tlsConfig := srv.unet.MustNewServerTLSConfig(srv.factory.ServerNameMain, srv.factory.ServerNameExtras...)
+		listener := runtimex.Try1(srv.unet.ListenTCP("tcp", &net.TCPAddr{IP: ipAddr, Port: port}))
+		config.ListenerConfigs = append(config.ListenerConfigs, turn.ListenerConfig{
+			Listener:              tls.NewListener(listener, tlsConfig),
+			RelayAddressGenerator: relayGen,
+		})
+	}
+
+	// create the server, which takes ownership of the listeners
+	server := runtimex.Try1(turn.NewServer(config))
+	srv.closers = append(srv.closers, server)
+}
+
+func (srv *turnServer) authHandler(username, realm string, _ net.Addr) ([]byte, bool) {
+	if username != srv.username() {
+		return nil, false
+	}
+	return turn.GenerateAuthKey(username, realm, srv.password()), true
+}
+
+func (srv *turnServer) password() string {
+	if srv.factory.Password != "" {
+		return srv.factory.Password
+	}
+	return TURNServerPassword
+}
+
+func (srv *turnServer) realm() string {
+	if srv.factory.Realm != "" {
+		return srv.factory.Realm
+	}
+	return "ooni.org"
+}
+
+func (srv *turnServer) username() string {
+	if srv.factory.Username != "" {
+		return srv.factory.Username
+	}
+	return TURNServerUsername
+}
+
+// turnRelayAddressGenerator allocates relayed addresses on the [netem.UNetStack].
+type turnRelayAddressGenerator struct {
+	ipAddr net.IP
+	unet   *netem.UNetStack
+}
+
+var _ turn.RelayAddressGenerator = &turnRelayAddressGenerator{}
+
+// errTURNRelayTCPNotSupported indicates that we do not support TCP allocations.
+var errTURNRelayTCPNotSupported = errors.New("netemx: TCP allocations are not supported")
+
+// Validate implements turn.RelayAddressGenerator.
+func (g *turnRelayAddressGenerator) Validate() error {
+	return nil
+}
+
+// AllocatePacketConn implements turn.RelayAddressGenerator.
+func (g *turnRelayAddressGenerator) AllocatePacketConn(network string, requestedPort int) (net.PacketConn, net.Addr, error) {
+	pconn, err := g.unet.ListenUDP("udp", &net.UDPAddr{IP: g.ipAddr, Port: requestedPort})
+	if err != nil {
+		return nil, nil, err
+	}
+	return pconn, pconn.LocalAddr(), nil
+}
+
+// AllocateConn implements turn.RelayAddressGenerator.
+func (g *turnRelayAddressGenerator) AllocateConn(network string, requestedPort int) (net.Conn, net.Addr, error) {
+	return nil, nil, errTURNRelayTCPNotSupported
+}
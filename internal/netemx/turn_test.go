@@ -0,0 +1,89 @@
+package netemx
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/apex/log"
+	"github.com/ooni/probe-cli/v3/internal/netxlite"
+	"github.com/pion/turn/v2"
+)
+
+func TestTURNServerFactory(t *testing.T) {
+	env := MustNewQAEnv(
+		QAEnvOptionNetStack(AddressTURNCloudflareCom, &TURNServerFactory{
+			Ports:          []int{3478},
+			ServerNameMain: "turn.cloudflare.com",
+		}),
+	)
+	defer env.Close()
+
+	endpoint := net.JoinHostPort(AddressTURNCloudflareCom, "3478")
+
+	// newClient creates a TURN client using the given conn and password.
+	newClient := func(t *testing.T, conn net.PacketConn, password string) *turn.Client {
+		clnt, err := turn.NewClient(&turn.ClientConfig{
+			STUNServerAddr: endpoint,
+			TURNServerAddr: endpoint,
+			Username:       TURNServerUsername,
+			Password:       password,
+			Conn:           conn,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := clnt.Listen(); err != nil {
+			t.Fatal(err)
+		}
+		return clnt
+	}
+
+	t.Run("binding and allocation over UDP", func(t *testing.T) {
+		env.Do(func() {
+			netx := &netxlite.Netx{}
+			conn, err := netx.NewUDPListener().Listen(&net.UDPAddr{IP: net.IPv4zero})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			clnt := newClient(t, conn, TURNServerPassword)
+			defer clnt.Close()
+
+			reflexive, err := clnt.SendBindingRequest()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if host, _, _ := net.SplitHostPort(reflexive.String()); host != DefaultClientAddress {
+				t.Fatal("unexpected reflexive address", reflexive)
+			}
+
+			relayConn, err := clnt.Allocate()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer relayConn.Close()
+			if host, _, _ := net.SplitHostPort(relayConn.LocalAddr().String()); host != AddressTURNCloudflareCom {
+				t.Fatal("unexpected relayed address", relayConn.LocalAddr())
+			}
+		})
+	})
+
+	t.Run("allocation over TCP with wrong password", func(t *testing.T) {
+		env.Do(func() {
+			netx := &netxlite.Netx{}
+			dialer := netx.NewDialerWithoutResolver(log.Log)
+			conn, err := dialer.DialContext(context.Background(), "tcp", endpoint)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			clnt := newClient(t, turn.NewSTUNConn(conn), "wrong")
+			defer clnt.Close()
+
+			if _, err := clnt.Allocate(); err == nil {
+				t.Fatal("expected an error here")
+			}
+		})
+	})
+}
@@ -216,7 +216,7 @@ func TestDNSDecoderMiekg(t *testing.T) {
 				d := &DNSDecoderMiekg{}
 				queryID := dns.Id()
 				rawQuery := dnsGenQuery(dns.TypeHTTPS, queryID)
-				rawResponse := dnsGenHTTPSReplySuccess(rawQuery, nil, nil, nil)
+				rawResponse := dnsGenHTTPSReplySuccess(rawQuery, nil, nil, nil, nil)
 				query := &mocks.DNSQuery{
 					MockID: func() uint16 {
 						return queryID
@@ -242,10 +242,11 @@ func TestDNSDecoderMiekg(t *testing.T) {
 				alpn := []string{"h3"}
 				v4 := []string{"1.1.1.1"}
 				v6 := []string{"::1"}
+				ech := []byte{0x00, 0x04, 0xfe, 0x0d, 0x00, 0x00}
 				d := &DNSDecoderMiekg{}
 				queryID := dns.Id()
 				rawQuery := dnsGenQuery(dns.TypeHTTPS, queryID)
-				rawResponse := dnsGenHTTPSReplySuccess(rawQuery, alpn, v4, v6)
+				rawResponse := dnsGenHTTPSReplySuccess(rawQuery, alpn, v4, v6, ech)
 				query := &mocks.DNSQuery{
 					MockID: func() uint16 {
 						return queryID
@@ -268,6 +269,9 @@ func TestDNSDecoderMiekg(t *testing.T) {
 				if diff := cmp.Diff(v6, reply.IPv6); diff != "" {
 					t.Fatal(diff)
 				}
+				if diff := cmp.Diff(ech, reply.ECHConfig); diff != "" {
+					t.Fatal(diff)
+				}
 			})
 		})
 
@@ -708,8 +712,8 @@ func dnsGenLookupHostReplySuccess(rawQuery []byte, cname *dnsCNAMEAnswer, ips ..
 }
 
 // dnsGenHTTPSReplySuccess generates a successful HTTPS response containing
-// the given (possibly nil) alpns, ipv4s, and ipv6s.
-func dnsGenHTTPSReplySuccess(rawQuery []byte, alpns, ipv4s, ipv6s []string) []byte {
+// the given (possibly nil) alpns, ipv4s, ipv6s, and ech config.
+func dnsGenHTTPSReplySuccess(rawQuery []byte, alpns, ipv4s, ipv6s []string, ech []byte) []byte {
 	query := new(dns.Msg)
 	err := query.Unpack(rawQuery)
 	runtimex.PanicOnError(err, "query.Unpack failed")
@@ -750,6 +754,9 @@ func dnsGenHTTPSReplySuccess(rawQuery []byte, alpns, ipv4s, ipv6s []string) []by
 		}
 		answer.Value = append(answer.Value, &dns.SVCBIPv6Hint{Hint: addrs})
 	}
+	if len(ech) > 0 {
+		answer.Value = append(answer.Value, &dns.SVCBECHConfig{ECH: ech})
+	}
 	data, err := reply.Pack()
 	runtimex.PanicOnError(err, "reply.Pack failed")
 	return data
@@ -0,0 +1,119 @@
+package netxlite
+
+//
+// Context-based SSLKEYLOGFILE support
+//
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+
+	ootls "github.com/ooni/oocrypto/tls"
+)
+
+// keyLogWriterKey is the private type used to set/retrieve the context's key log writer.
+type keyLogWriterKey struct{}
+
+// ContextKeyLogWriter retrieves the possibly-nil key log writer bound to the context.
+func ContextKeyLogWriter(ctx context.Context) io.Writer {
+	w, _ := ctx.Value(keyLogWriterKey{}).(io.Writer)
+	return w
+}
+
+// ContextWithKeyLogWriter returns a new context that binds to the given key log
+// writer. The TLS handshakers (both stdlib and utls) and the QUIC dialers will
+// write the session secrets into such a writer using the NSS key log format (i.e.,
+// the format used by SSLKEYLOGFILE), thus allowing to decrypt captured traffic.
+//
+// The writer MUST be safe for concurrent use (an [*os.File] is). Note that the
+// written secrets allow to decrypt the traffic, so you should only use this
+// functionality for debugging. Passing a nil writer disables writing secrets.
+func ContextWithKeyLogWriter(ctx context.Context, w io.Writer) context.Context {
+	return context.WithValue(ctx, keyLogWriterKey{}, w)
+}
+
+// maybeApplyContextKeyLogWriter returns a clone of the config using the context's key log
+// writer, if any, unless the config already has a key log writer. Otherwise, it returns
+// the original config, which SHOULD NOT be modified by the caller.
+func maybeApplyContextKeyLogWriter(ctx context.Context, config *tls.Config) *tls.Config {
+	w := ContextKeyLogWriter(ctx)
+	if w == nil || config.KeyLogWriter != nil {
+		return config
+	}
+	config = config.Clone()
+	config.KeyLogWriter = w
+	return config
+}
+
+// errOOTLSIncompatibleStdlibConfig indicates that the stdlib config you passed
+// to newOOTLSConnWithKeyLogWriter contains some fields we don't support.
+var errOOTLSIncompatibleStdlibConfig = errors.New("ootls: incompatible stdlib config")
+
+// newOOTLSConnWithKeyLogWriter is like [ootls.NewClientConnStdlib] except that it also
+// supports the KeyLogWriter field, which [ootls.NewClientConnStdlib] rejects.
+func newOOTLSConnWithKeyLogWriter(conn net.Conn, config *tls.Config) (TLSConn, error) {
+	supportedFields := map[string]bool{
+		"DynamicRecordSizingDisabled": true,
+		"InsecureSkipVerify":          true,
+		"KeyLogWriter":                true,
+		"MaxVersion":                  true,
+		"MinVersion":                  true,
+		"NextProtos":                  true,
+		"RootCAs":                     true,
+		"ServerName":                  true,
+	}
+	value := reflect.ValueOf(config).Elem()
+	kind := value.Type()
+	for idx := 0; idx < value.NumField(); idx++ {
+		field := value.Field(idx)
+		if field.IsZero() {
+			continue
+		}
+		fieldKind := kind.Field(idx)
+		if supportedFields[fieldKind.Name] {
+			continue
+		}
+		err := fmt.Errorf("%w: field %s is nonzero", errOOTLSIncompatibleStdlibConfig, fieldKind.Name)
+		return nil, err
+	}
+	ooConfig := &ootls.Config{
+		DynamicRecordSizingDisabled: config.DynamicRecordSizingDisabled,
+		InsecureSkipVerify:          config.InsecureSkipVerify,
+		KeyLogWriter:                config.KeyLogWriter,
+		MaxVersion:                  config.MaxVersion,
+		MinVersion:                  config.MinVersion,
+		NextProtos:                  config.NextProtos,
+		RootCAs:                     config.RootCAs,
+		ServerName:                  config.ServerName,
+	}
+	return &ootlsConnWithKeyLogWriter{ootls.Client(conn, ooConfig)}, nil
+}
+
+// ootlsConnWithKeyLogWriter adapts an [*ootls.Conn] to [TLSConn].
+type ootlsConnWithKeyLogWriter struct {
+	*ootls.Conn
+}
+
+// ConnectionState implements TLSConn.
+func (c *ootlsConnWithKeyLogWriter) ConnectionState() tls.ConnectionState {
+	state := c.Conn.ConnectionState()
+	return tls.ConnectionState{
+		Version:                     state.Version,
+		HandshakeComplete:           state.HandshakeComplete,
+		DidResume:                   state.DidResume,
+		CipherSuite:                 state.CipherSuite,
+		NegotiatedProtocol:          state.NegotiatedProtocol,
+		NegotiatedProtocolIsMutual:  state.NegotiatedProtocolIsMutual,
+		ServerName:                  state.ServerName,
+		PeerCertificates:            state.PeerCertificates,
+		VerifiedChains:              state.VerifiedChains,
+		SignedCertificateTimestamps: state.SignedCertificateTimestamps,
+		OCSPResponse:                state.OCSPResponse,
+		TLSUnique:                   state.TLSUnique,
+	}
+}
@@ -0,0 +1,173 @@
+package netxlite
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/ooni/probe-cli/v3/internal/mocks"
+	"github.com/quic-go/quic-go"
+	utls "gitlab.com/yawning/utls.git"
+)
+
+func TestContextKeyLogWriter(t *testing.T) {
+	t.Run("without a writer", func(t *testing.T) {
+		if ContextKeyLogWriter(context.Background()) != nil {
+			t.Fatal("expected nil writer")
+		}
+	})
+
+	t.Run("with a writer", func(t *testing.T) {
+		w := &bytes.Buffer{}
+		ctx := ContextWithKeyLogWriter(context.Background(), w)
+		if ContextKeyLogWriter(ctx) != w {
+			t.Fatal("unexpected writer")
+		}
+	})
+}
+
+func TestMaybeApplyContextKeyLogWriter(t *testing.T) {
+	t.Run("without a context writer", func(t *testing.T) {
+		config := &tls.Config{}
+		out := maybeApplyContextKeyLogWriter(context.Background(), config)
+		if out != config {
+			t.Fatal("expected the original config")
+		}
+	})
+
+	t.Run("with a context writer", func(t *testing.T) {
+		w := &bytes.Buffer{}
+		ctx := ContextWithKeyLogWriter(context.Background(), w)
+		config := &tls.Config{ServerName: "example.com"}
+		out := maybeApplyContextKeyLogWriter(ctx, config)
+		if out == config {
+			t.Fatal("expected a clone of the config")
+		}
+		if config.KeyLogWriter != nil {
+			t.Fatal("should not have modified the original config")
+		}
+		if out.KeyLogWriter != w {
+			t.Fatal("did not set the key log writer")
+		}
+		if out.ServerName != "example.com" {
+			t.Fatal("did not clone the config")
+		}
+	})
+
+	t.Run("with a config writer", func(t *testing.T) {
+		ctx := ContextWithKeyLogWriter(context.Background(), &bytes.Buffer{})
+		w := &bytes.Buffer{}
+		config := &tls.Config{KeyLogWriter: w}
+		out := maybeApplyContextKeyLogWriter(ctx, config)
+		if out != config {
+			t.Fatal("expected the original config")
+		}
+		if out.KeyLogWriter != w {
+			t.Fatal("should not have overridden the key log writer")
+		}
+	})
+}
+
+func TestTLSHandshakerWritesKeyLog(t *testing.T) {
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(200)
+	})
+	srvr := httptest.NewTLSServer(handler)
+	defer srvr.Close()
+	URL, err := url.Parse(srvr.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handshakers := map[string]*tlsHandshakerConfigurable{
+		"stdlib": {},
+		"utls":   {NewConn: newUTLSConnFactory(&utls.HelloFirefox_55)},
+	}
+	for name, handshaker := range handshakers {
+		t.Run(name, func(t *testing.T) {
+			conn, err := net.Dial("tcp", URL.Host)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			w := &bytes.Buffer{}
+			ctx := ContextWithKeyLogWriter(context.Background(), w)
+			config := &tls.Config{
+				InsecureSkipVerify: true,
+				ServerName:         URL.Hostname(),
+			}
+			tlsConn, err := handshaker.Handshake(ctx, conn, config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer tlsConn.Close()
+			if config.KeyLogWriter != nil {
+				t.Fatal("should not have modified the original config")
+			}
+			if !strings.Contains(w.String(), "CLIENT_") {
+				t.Fatal("did not write the session secrets", w.String())
+			}
+			if !tlsConn.ConnectionState().HandshakeComplete {
+				t.Fatal("expected the handshake to be complete")
+			}
+			if _, good := tlsConn.(*ootlsConnWithKeyLogWriter); name == "stdlib" && !good {
+				t.Fatalf("expected to use ootls, got %T", tlsConn)
+			}
+		})
+	}
+}
+
+func TestNewOOTLSConnWithKeyLogWriter(t *testing.T) {
+	t.Run("with an unsupported field", func(t *testing.T) {
+		config := &tls.Config{
+			CipherSuites: []uint16{tls.TLS_AES_128_GCM_SHA256},
+			KeyLogWriter: &bytes.Buffer{},
+		}
+		conn, err := newOOTLSConnWithKeyLogWriter(&mocks.Conn{}, config)
+		if !errors.Is(err, errOOTLSIncompatibleStdlibConfig) {
+			t.Fatal("unexpected error", err)
+		}
+		if conn != nil {
+			t.Fatal("expected nil conn")
+		}
+	})
+}
+
+func TestQUICDialerUsesContextKeyLogWriter(t *testing.T) {
+	expected := errors.New("mocked error")
+	var gotTLSConfig *tls.Config
+	tlsConfig := &tls.Config{
+		ServerName: "dns.google",
+	}
+	systemdialer := quicDialerQUICGo{
+		UDPListener: &udpListenerStdlib{},
+		mockDialEarly: func(ctx context.Context, pconn net.PacketConn,
+			remoteAddr net.Addr, tlsConfig *tls.Config,
+			quicConfig *quic.Config) (quic.EarlyConnection, error) {
+			gotTLSConfig = tlsConfig
+			return nil, expected
+		},
+	}
+	w := &bytes.Buffer{}
+	ctx := ContextWithKeyLogWriter(context.Background(), w)
+	qconn, err := systemdialer.DialContext(ctx, "8.8.8.8:443", tlsConfig, &quic.Config{})
+	if !errors.Is(err, expected) {
+		t.Fatal("not the error we expected", err)
+	}
+	if qconn != nil {
+		t.Fatal("expected nil connection here")
+	}
+	if tlsConfig.KeyLogWriter != nil {
+		t.Fatal("tlsConfig.KeyLogWriter should not have been changed")
+	}
+	if gotTLSConfig.KeyLogWriter != w {
+		t.Fatal("gotTLSConfig.KeyLogWriter should have been set")
+	}
+}
@@ -117,7 +117,10 @@ func ParseUDPAddr(address string) (*net.UDPAddr, error) {
 // bundle with this measurement library;
 //
 // 2. if tlsConfig.NextProtos is empty _and_ the port is 443 or 8853,
-// then we configure, respectively, "h3" and "dq".
+// then we configure, respectively, "h3" and "dq";
+//
+// 3. if tlsConfig.KeyLogWriter is nil, we use the key log writer
+// bound to the context, if any (see [ContextWithKeyLogWriter]).
 func (d *quicDialerQUICGo) DialContext(ctx context.Context,
 	address string, tlsConfig *tls.Config, quicConfig *quic.Config) (
 	quic.EarlyConnection, error) {
@@ -130,6 +133,7 @@ func (d *quicDialerQUICGo) DialContext(ctx context.Context,
 		return nil, err
 	}
 	tlsConfig = d.maybeApplyTLSDefaults(tlsConfig, udpAddr.Port)
+	tlsConfig = maybeApplyContextKeyLogWriter(ctx, tlsConfig)
 	trace := ContextTraceOrDefault(ctx)
 	pconn = trace.MaybeWrapUDPLikeConn(pconn)
 	started := trace.TimeNow()
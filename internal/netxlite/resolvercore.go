@@ -35,6 +35,15 @@ func (netx *Netx) NewParallelDNSOverHTTPSResolver(logger model.DebugLogger, URL
 	return WrapResolver(logger, NewUnwrappedParallelResolver(txp))
 }
 
+// NewParallelDNSOverTLSResolver creates a new DNS-over-TLS resolver with error
+// wrapping using the given [model.TLSDialer] to connect to the given endpoint
+// address (e.g., 8.8.8.8:853). The TLS dialer uses the endpoint IP address
+// as the SNI unless it has been configured to use a specific server name.
+func NewParallelDNSOverTLSResolver(logger model.DebugLogger, dialer model.TLSDialer, address string) model.Resolver {
+	txp := wrapDNSTransport(NewUnwrappedDNSOverTLSTransport(dialer.DialTLSContext, address))
+	return WrapResolver(logger, NewUnwrappedParallelResolver(txp))
+}
+
 func (netx *Netx) newUnwrappedStdlibResolver() model.Resolver {
 	return &resolverSystem{
 		t: wrapDNSTransport(netx.newDNSOverGetaddrinfoTransport()),
@@ -92,6 +92,28 @@ func TestNewParallelDNSOverHTTPSResolver(t *testing.T) {
 	}
 }
 
+func TestNewParallelDNSOverTLSResolver(t *testing.T) {
+	netx := &Netx{}
+	d := NewTLSDialer(netx.NewDialerWithoutResolver(log.Log), netx.NewTLSHandshakerStdlib(log.Log))
+	resolver := NewParallelDNSOverTLSResolver(log.Log, d, "1.1.1.1:853")
+	idnaReso := resolver.(*resolverIDNA)
+	logger := idnaReso.Resolver.(*resolverLogger)
+	if logger.Logger != log.Log {
+		t.Fatal("invalid logger")
+	}
+	shortCircuit := logger.Resolver.(*ResolverShortCircuitIPAddr)
+	errWrapper := shortCircuit.Resolver.(*resolverErrWrapper)
+	para := errWrapper.Resolver.(*ParallelResolver)
+	txp := para.Transport().(*dnsTransportErrWrapper)
+	dnsTxp := txp.DNSTransport.(*DNSOverTCPTransport)
+	if dnsTxp.Address() != "1.1.1.1:853" {
+		t.Fatal("invalid address")
+	}
+	if dnsTxp.Network() != "dot" {
+		t.Fatal("invalid network")
+	}
+}
+
 func TestResolverSystem(t *testing.T) {
 	t.Run("Network", func(t *testing.T) {
 		expected := "antani"
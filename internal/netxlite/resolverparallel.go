@@ -79,12 +79,25 @@ func (r *ParallelResolver) LookupHost(ctx context.Context, hostname string) ([]s
 func (r *ParallelResolver) LookupHTTPS(
 	ctx context.Context, hostname string) (*model.HTTPSSvc, error) {
 	encoder := &DNSEncoderMiekg{}
+	trace := ContextTraceOrDefault(ctx)
 	query := encoder.Encode(hostname, dns.TypeHTTPS, r.Txp.RequiresPadding())
+	started := trace.TimeNow()
 	response, err := r.Txp.RoundTrip(ctx, query)
+	finished := trace.TimeNow()
 	if err != nil {
+		trace.OnDNSRoundTripForLookupHost(started, r, query, response, []string{}, err, finished)
 		return nil, err
 	}
-	return response.DecodeHTTPS()
+	https, err := response.DecodeHTTPS()
+	// Note: we report the IP hints as the addresses so that they are
+	// part of the answers in the archived DNS lookup results.
+	addrs := []string{}
+	if https != nil {
+		addrs = append(addrs, https.IPv4...)
+		addrs = append(addrs, https.IPv6...)
+	}
+	trace.OnDNSRoundTripForLookupHost(started, r, query, response, addrs, err, finished)
+	return https, err
 }
 
 // parallelResolverResult is the internal representation of a
@@ -223,6 +223,61 @@ func TestParallelResolver(t *testing.T) {
 				t.Fatal("unexpected result")
 			}
 		})
+
+		t.Run("we call the trace with the IP hints", func(t *testing.T) {
+			expected := &model.HTTPSSvc{
+				ALPN:      []string{"h3"},
+				ECHConfig: []byte{},
+				IPv4:      []string{"1.1.1.1"},
+				IPv6:      []string{"::1"},
+			}
+			r := &ParallelResolver{
+				Txp: &mocks.DNSTransport{
+					MockRoundTrip: func(ctx context.Context, query model.DNSQuery) (model.DNSResponse, error) {
+						response := &mocks.DNSResponse{
+							MockDecodeHTTPS: func() (*model.HTTPSSvc, error) {
+								return expected, nil
+							},
+						}
+						return response, nil
+					},
+					MockRequiresPadding: func() bool {
+						return false
+					},
+				},
+			}
+			var (
+				called   bool
+				gotAddrs []string
+				gotType  uint16
+			)
+			tx := &mocks.Trace{
+				MockTimeNow: time.Now,
+				MockOnDNSRoundTripForLookupHost: func(started time.Time, reso model.Resolver, query model.DNSQuery,
+					response model.DNSResponse, addrs []string, err error, finished time.Time) {
+					called = true
+					gotAddrs = addrs
+					gotType = query.Type()
+				},
+			}
+			ctx := ContextWithTrace(context.Background(), tx)
+			https, err := r.LookupHTTPS(ctx, "example.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if https != expected {
+				t.Fatal("unexpected result")
+			}
+			if !called {
+				t.Fatal("trace not called")
+			}
+			if diff := cmp.Diff([]string{"1.1.1.1", "::1"}, gotAddrs); diff != "" {
+				t.Fatal(diff)
+			}
+			if gotType != dns.TypeHTTPS {
+				t.Fatal("unexpected query type", gotType)
+			}
+		})
 	})
 
 	t.Run("LookupNS", func(t *testing.T) {
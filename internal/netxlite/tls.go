@@ -202,6 +202,9 @@ func tlsMaybeConnectionState(conn TLSConn, err error) tls.ConnectionState {
 // configure the code to use the built-in Mozilla CA if the config
 // field contains a nil RootCAs field.
 //
+// This function will also use the key log writer bound to the context, if
+// any, unless the config contains a KeyLogWriter (see [ContextWithKeyLogWriter]).
+//
 // This function will also emit TLS-handshake-related tracing events.
 func (h *tlsHandshakerConfigurable) Handshake(
 	ctx context.Context, conn net.Conn, config *tls.Config,
@@ -217,6 +220,7 @@ func (h *tlsHandshakerConfigurable) Handshake(
 		// See https://github.com/ooni/probe/issues/2413 for context
 		config.RootCAs = h.provider.Get().DefaultCertPool()
 	}
+	config = maybeApplyContextKeyLogWriter(ctx, config)
 	tlsconn, err := h.newConn(conn, config)
 	if err != nil {
 		return nil, err
@@ -241,6 +245,11 @@ func (h *tlsHandshakerConfigurable) newConn(conn net.Conn, config *tls.Config) (
 	if h.NewConn != nil {
 		return h.NewConn(conn, config)
 	}
+	if config.KeyLogWriter != nil {
+		// ootls.NewClientConnStdlib rejects a config with a KeyLogWriter, so
+		// we build the ootls config ourselves to keep using ootls.
+		return newOOTLSConnWithKeyLogWriter(conn, config)
+	}
 	return ootls.NewClientConnStdlib(conn, config)
 }
 
@@ -55,6 +55,7 @@ func NewUTLSConn(conn net.Conn, config *tls.Config, cid *utls.ClientHelloID) (*U
 	supportedFields := map[string]bool{
 		"DynamicRecordSizingDisabled": true,
 		"InsecureSkipVerify":          true,
+		"KeyLogWriter":                true,
 		"NextProtos":                  true,
 		"RootCAs":                     true,
 		"ServerName":                  true,
@@ -76,6 +77,7 @@ func NewUTLSConn(conn net.Conn, config *tls.Config, cid *utls.ClientHelloID) (*U
 	uConfig := &utls.Config{
 		DynamicRecordSizingDisabled: config.DynamicRecordSizingDisabled,
 		InsecureSkipVerify:          config.InsecureSkipVerify,
+		KeyLogWriter:                config.KeyLogWriter,
 		RootCAs:                     config.RootCAs,
 		NextProtos:                  config.NextProtos,
 		ServerName:                  config.ServerName,
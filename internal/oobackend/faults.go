@@ -0,0 +1,105 @@
+package oobackend
+
+//
+// Fault injection
+//
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Fault describes a fault to inject into the responses.
+type Fault struct {
+	// PathPrefix is the OPTIONAL URL path prefix of the requests for which
+	// we should inject the fault. If empty, we match all the requests.
+	PathPrefix string
+
+	// Delay is the OPTIONAL delay before responding.
+	Delay time.Duration
+
+	// StatusCode is the OPTIONAL status code to return instead
+	// of actually handling the request (e.g., 500).
+	StatusCode int
+
+	// MalformedJSON OPTIONALLY indicates that we should respond
+	// with a malformed JSON body instead of handling the request.
+	MalformedJSON bool
+
+	// Count is the OPTIONAL number of requests for which we should
+	// inject the fault. If zero, we inject the fault forever.
+	Count int
+}
+
+// malformedJSON is the body we send when [Fault.MalformedJSON] is true.
+const malformedJSON = `{"malformed":`
+
+// AddFault adds a fault to inject. When several faults match a request,
+// we use the one that was added first.
+//
+// This method is safe to call concurrently with incoming HTTP requests.
+func (b *Backend) AddFault(fault *Fault) {
+	defer b.mu.Unlock()
+	b.mu.Lock()
+	copied := *fault
+	b.faults = append(b.faults, &copied)
+}
+
+// ClearFaults removes all the faults to inject.
+//
+// This method is safe to call concurrently with incoming HTTP requests.
+func (b *Backend) ClearFaults() {
+	defer b.mu.Unlock()
+	b.mu.Lock()
+	b.faults = nil
+}
+
+// matchFault returns the fault to inject for the given request, if any,
+// and removes the fault once we have injected it Count times.
+func (b *Backend) matchFault(r *http.Request) *Fault {
+	defer b.mu.Unlock()
+	b.mu.Lock()
+	for idx, fault := range b.faults {
+		if !strings.HasPrefix(r.URL.Path, fault.PathPrefix) {
+			continue
+		}
+		if fault.Count > 0 {
+			fault.Count--
+			if fault.Count == 0 {
+				b.faults = append(b.faults[:idx:idx], b.faults[idx+1:]...)
+			}
+		}
+		return fault
+	}
+	return nil
+}
+
+// withFaults wraps the given handler to inject the configured faults.
+func (b *Backend) withFaults(child http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fault := b.matchFault(r)
+		if fault == nil {
+			child.ServeHTTP(w, r)
+			return
+		}
+		if fault.Delay > 0 {
+			timer := time.NewTimer(fault.Delay)
+			defer timer.Stop()
+			select {
+			case <-timer.C:
+			case <-r.Context().Done():
+				return
+			}
+		}
+		switch {
+		case fault.StatusCode != 0:
+			w.WriteHeader(fault.StatusCode)
+		case fault.MalformedJSON:
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(malformedJSON))
+		default:
+			child.ServeHTTP(w, r)
+		}
+	})
+}
@@ -0,0 +1,88 @@
+package oobackend
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ooni/probe-cli/v3/internal/model"
+)
+
+func TestBackendFaults(t *testing.T) {
+	// get sends a GET request for the test helpers to the backend
+	get := func(backend *Backend) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/test-helpers", nil)
+		w := httptest.NewRecorder()
+		backend.Handler().ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("we return the configured status code Count times", func(t *testing.T) {
+		backend := &Backend{}
+		backend.AddFault(&Fault{PathPrefix: "/api/v1/test-helpers", StatusCode: 503, Count: 2})
+		for idx, expected := range []int{503, 503, 200} {
+			if w := get(backend); w.Code != expected {
+				t.Fatal("unexpected status code", idx, w.Code)
+			}
+		}
+	})
+
+	t.Run("we only match the configured prefix", func(t *testing.T) {
+		backend := &Backend{}
+		backend.AddFault(&Fault{PathPrefix: "/report", StatusCode: 500})
+		if w := get(backend); w.Code != 200 {
+			t.Fatal("unexpected status code", w.Code)
+		}
+	})
+
+	t.Run("we can send malformed JSON", func(t *testing.T) {
+		backend := &Backend{}
+		backend.AddFault(&Fault{MalformedJSON: true})
+		w := get(backend)
+		if w.Code != 200 || w.Body.String() != malformedJSON {
+			t.Fatal("unexpected response", w.Code, w.Body.String())
+		}
+		client := newClient(t, backend)
+		_, err := client.CheckIn(context.Background(), model.OOAPICheckInConfig{})
+		if err == nil || !strings.Contains(err.Error(), "unexpected end of JSON input") {
+			t.Fatal("unexpected error", err)
+		}
+	})
+
+	t.Run("we can delay responses", func(t *testing.T) {
+		backend := &Backend{}
+		backend.AddFault(&Fault{Delay: 100 * time.Millisecond})
+		t0 := time.Now()
+		if w := get(backend); w.Code != 200 {
+			t.Fatal("unexpected status code", w.Code)
+		}
+		if time.Since(t0) < 100*time.Millisecond {
+			t.Fatal("the response was not delayed")
+		}
+	})
+
+	t.Run("a delayed response honours the request context", func(t *testing.T) {
+		backend := &Backend{}
+		backend.AddFault(&Fault{Delay: time.Hour})
+		client := newClient(t, backend)
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		_, err := client.GetTestHelpers(ctx)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatal("unexpected error", err)
+		}
+	})
+
+	t.Run("ClearFaults removes all the faults", func(t *testing.T) {
+		backend := &Backend{}
+		backend.AddFault(&Fault{StatusCode: 500})
+		backend.ClearFaults()
+		if w := get(backend); w.Code != 200 {
+			t.Fatal("unexpected status code", w.Code)
+		}
+	})
+}
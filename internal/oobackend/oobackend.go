@@ -0,0 +1,327 @@
+// Package oobackend implements an in-memory stand-in for the OONI probe
+// services API, which allows running ooniprobe end-to-end without using
+// the real backend. It implements check-in, bouncer, collector, login and
+// register, the tunnels configuration APIs, and measurement metadata. It
+// records the submitted measurements and allows to inject faults.
+//
+// The [*Backend] methods panic for several errors. Only use for testing purposes!
+package oobackend
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/must"
+	"github.com/ooni/probe-cli/v3/internal/runtimex"
+	"github.com/ooni/probe-cli/v3/internal/testingx"
+)
+
+// DefaultTestHelpers returns the test helpers we return by default.
+func DefaultTestHelpers() map[string][]model.OOAPIService {
+	return map[string][]model.OOAPIService{
+		"web-connectivity": {{
+			Address: "https://0.th.ooni.org",
+			Type:    "https",
+		}, {
+			Address: "https://1.th.ooni.org",
+			Type:    "https",
+		}},
+	}
+}
+
+// DefaultCheckInURLs returns the URLs the check-in API returns by default.
+func DefaultCheckInURLs() []model.OOAPIURLInfo {
+	return []model.OOAPIURLInfo{{
+		CategoryCode: "MISC",
+		CountryCode:  "XX",
+		URL:          "https://www.example.com/",
+	}, {
+		CategoryCode: "MISC",
+		CountryCode:  "XX",
+		URL:          "https://www.example.org/",
+	}}
+}
+
+// Backend implements the OONI probe services API in memory.
+//
+// The zero value is ready to use.
+type Backend struct {
+	// OnMeasurement is an OPTIONAL callback called for each
+	// measurement the backend receives and records.
+	OnMeasurement func(meas *model.Measurement)
+
+	// checkInURLs contains the URLs to return with the check-in response.
+	checkInURLs []model.OOAPIURLInfo
+
+	// collector implements the collector API.
+	collector testingx.OONICollector
+
+	// faults contains the faults to inject.
+	faults []*Fault
+
+	// handler is the handler initialized by init.
+	handler http.Handler
+
+	// login implements the register and login API.
+	login testingx.OONIBackendWithLoginFlow
+
+	// measurements contains the submitted measurements.
+	measurements []*model.Measurement
+
+	// mu provides mutual exclusion.
+	mu sync.Mutex
+
+	// once allows to initialize the backend just once.
+	once sync.Once
+
+	// openVPNConfig is the serialized openvpn config to send to clients.
+	openVPNConfig []byte
+
+	// testHelpers contains the test helpers to return.
+	testHelpers map[string][]model.OOAPIService
+}
+
+// SetCheckInURLs sets the URLs returned by the check-in API for web_connectivity.
+//
+// This method is safe to call concurrently with incoming HTTP requests.
+func (b *Backend) SetCheckInURLs(urls []model.OOAPIURLInfo) {
+	defer b.mu.Unlock()
+	b.mu.Lock()
+	b.checkInURLs = urls
+}
+
+// SetTestHelpers sets the test helpers returned by the bouncer and the check-in API.
+//
+// This method is safe to call concurrently with incoming HTTP requests.
+func (b *Backend) SetTestHelpers(ths map[string][]model.OOAPIService) {
+	defer b.mu.Unlock()
+	b.mu.Lock()
+	b.testHelpers = ths
+}
+
+// SetOpenVPNConfig sets the openvpn configuration for all the providers.
+//
+// This method is safe to call concurrently with incoming HTTP requests.
+func (b *Backend) SetOpenVPNConfig(config []byte) {
+	defer b.mu.Unlock()
+	b.mu.Lock()
+	b.openVPNConfig = config
+}
+
+// SetPsiphonConfig sets the psiphon configuration to send to authenticated clients.
+//
+// This method is safe to call concurrently with incoming HTTP requests.
+func (b *Backend) SetPsiphonConfig(config []byte) {
+	b.once.Do(b.init)
+	b.login.SetPsiphonConfig(config)
+}
+
+// SetTorTargets sets the tor targets to send to authenticated clients.
+//
+// This method is safe to call concurrently with incoming HTTP requests.
+func (b *Backend) SetTorTargets(config []byte) {
+	b.once.Do(b.init)
+	b.login.SetTorTargets(config)
+}
+
+// Measurements returns a copy of the list of the submitted measurements.
+//
+// This method is safe to call concurrently with incoming HTTP requests.
+func (b *Backend) Measurements() []*model.Measurement {
+	defer b.mu.Unlock()
+	b.mu.Lock()
+	return append([]*model.Measurement{}, b.measurements...)
+}
+
+// Handler returns the [http.Handler] implementing the OONI probe services API.
+func (b *Backend) Handler() http.Handler {
+	b.once.Do(b.init)
+	return b.handler
+}
+
+// init initializes the backend the first time we use it.
+func (b *Backend) init() {
+	b.collector.ValidateMeasurement = b.recordMeasurement
+	b.login.SetPsiphonConfig([]byte("{}"))
+	b.login.SetTorTargets([]byte("{}"))
+	login := b.login.NewMux()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/check-in", b.handleCheckIn)
+	mux.HandleFunc("/api/v1/test-helpers", b.handleTestHelpers)
+	mux.HandleFunc("/api/v1/measurement_meta", b.handleMeasurementMeta)
+	mux.Handle("/api/v1/register", login)
+	mux.Handle("/api/v1/login", login)
+	mux.Handle("/api/v1/test-list/psiphon-config", login)
+	mux.Handle("/api/v1/test-list/tor-targets", login)
+	mux.HandleFunc("/api/v2/ooniprobe/vpn-config/", b.handleOpenVPNConfig)
+	mux.Handle("/report", &b.collector)
+	mux.Handle("/report/", &b.collector)
+	b.handler = b.withFaults(mux)
+}
+
+// recordMeasurement records a measurement submitted to the collector.
+func (b *Backend) recordMeasurement(meas *model.Measurement) error {
+	b.mu.Lock()
+	b.measurements = append(b.measurements, meas)
+	b.mu.Unlock()
+	if b.OnMeasurement != nil {
+		b.OnMeasurement(meas)
+	}
+	return nil
+}
+
+// writeJSON sends the given value as JSON to the client.
+func writeJSON(w http.ResponseWriter, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(must.MarshalJSON(value))
+}
+
+func (b *Backend) handleCheckIn(w http.ResponseWriter, r *http.Request) {
+	// make sure the method is OK
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+
+	// read and parse the request
+	rawreqbody := runtimex.Try1(io.ReadAll(r.Body))
+	var config model.OOAPICheckInConfig
+	if err := json.Unmarshal(rawreqbody, &config); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// prepare and send the response
+	b.mu.Lock()
+	urls := b.checkInURLs
+	if urls == nil {
+		urls = DefaultCheckInURLs()
+	}
+	ths := b.testHelpersLocked()
+	b.mu.Unlock()
+	response := &model.OOAPICheckInResult{
+		Conf: model.OOAPICheckInResultConfig{
+			Features:    map[string]bool{},
+			TestHelpers: ths,
+		},
+		ProbeASN: config.ProbeASN,
+		ProbeCC:  config.ProbeCC,
+		Tests: model.OOAPICheckInResultNettests{
+			WebConnectivity: &model.OOAPICheckInInfoWebConnectivity{
+				URLs: urls,
+			},
+		},
+		UTCTime: time.Now().UTC(),
+		V:       1,
+	}
+	writeJSON(w, response)
+}
+
+// testHelpersLocked returns the test helpers. This method
+// assumes the caller is holding the mutex.
+func (b *Backend) testHelpersLocked() map[string][]model.OOAPIService {
+	if b.testHelpers == nil {
+		return DefaultTestHelpers()
+	}
+	return b.testHelpers
+}
+
+func (b *Backend) handleTestHelpers(w http.ResponseWriter, r *http.Request) {
+	// make sure the method is OK
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+
+	// send the response
+	b.mu.Lock()
+	ths := b.testHelpersLocked()
+	b.mu.Unlock()
+	writeJSON(w, ths)
+}
+
+func (b *Backend) handleOpenVPNConfig(w http.ResponseWriter, r *http.Request) {
+	// make sure the method is OK
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+
+	// make sure there is a provider name
+	if strings.TrimPrefix(r.URL.Path, "/api/v2/ooniprobe/vpn-config/") == "" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	// send the response
+	b.mu.Lock()
+	config := b.openVPNConfig
+	b.mu.Unlock()
+	if config == nil {
+		config = []byte("{}")
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(config)
+}
+
+func (b *Backend) handleMeasurementMeta(w http.ResponseWriter, r *http.Request) {
+	// make sure the method is OK
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+
+	// make sure the client has provided the report ID
+	query := r.URL.Query()
+	reportID := query.Get("report_id")
+	if reportID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	input := query.Get("input")
+
+	// search for the most recent matching measurement
+	var found *model.Measurement
+	b.mu.Lock()
+	for _, meas := range b.measurements {
+		if meas.ReportID == reportID && string(meas.Input) == input {
+			found = meas
+		}
+	}
+	b.mu.Unlock()
+
+	// like the real API, return an empty object if not found
+	if found == nil {
+		writeJSON(w, map[string]any{})
+		return
+	}
+
+	// prepare and send the response
+	response := &model.OOAPIMeasurementMeta{
+		ProbeCC:  found.ProbeCC,
+		ReportID: found.ReportID,
+		TestName: found.TestName,
+	}
+	if input != "" {
+		response.Input = &input
+	}
+	response.MeasurementStartTime, _ = time.Parse(model.MeasurementDateFormat, found.MeasurementStartTime)
+	response.TestStartTime, _ = time.Parse(model.MeasurementDateFormat, found.TestStartTime)
+	response.ProbeASN = parseProbeASN(found.ProbeASN)
+	if query.Get("full") == "true" {
+		response.RawMeasurement = string(must.MarshalJSON(found))
+	}
+	writeJSON(w, response)
+}
+
+// parseProbeASN converts a probe ASN string (e.g., "AS30722") to a number.
+func parseProbeASN(value string) int64 {
+	asn, _ := strconv.ParseInt(strings.TrimPrefix(value, "AS"), 10, 64)
+	return asn
+}
@@ -0,0 +1,220 @@
+package oobackend
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/apex/log"
+	"github.com/google/go-cmp/cmp"
+	"github.com/ooni/probe-cli/v3/internal/legacy/mockable"
+	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/probeservices"
+	"github.com/ooni/probe-cli/v3/internal/runtimex"
+	"github.com/ooni/probe-cli/v3/internal/testingx"
+)
+
+// newClient creates a new probeservices client using the given backend.
+func newClient(t *testing.T, backend *Backend) *probeservices.Client {
+	srv := testingx.MustNewHTTPServer(backend.Handler())
+	t.Cleanup(func() { srv.Close() })
+	return runtimex.Try1(probeservices.NewClient(
+		&mockable.Session{
+			MockableHTTPClient: http.DefaultClient,
+			MockableLogger:     log.Log,
+		},
+		model.OOAPIService{
+			Address: srv.URL,
+			Type:    "https",
+		},
+	))
+}
+
+// newMeasurement creates a new measurement to submit.
+func newMeasurement(input string) *model.Measurement {
+	return &model.Measurement{
+		DataFormatVersion:    model.OOAPIReportDefaultDataFormatVersion,
+		Input:                model.MeasurementInput(input),
+		MeasurementStartTime: "2024-03-01 10:00:01",
+		ProbeASN:             "AS30722",
+		ProbeCC:              "IT",
+		SoftwareName:         "ooniprobe",
+		SoftwareVersion:      "3.21.0",
+		TestName:             "web_connectivity",
+		TestStartTime:        "2024-03-01 10:00:00",
+		TestVersion:          "0.5.28",
+	}
+}
+
+func TestBackendBouncerAndCheckIn(t *testing.T) {
+	t.Run("with the default settings", func(t *testing.T) {
+		client := newClient(t, &Backend{})
+		ths, err := client.GetTestHelpers(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(DefaultTestHelpers(), ths); diff != "" {
+			t.Fatal(diff)
+		}
+		result, err := client.CheckIn(context.Background(), model.OOAPICheckInConfig{
+			ProbeASN: "AS30722",
+			ProbeCC:  "IT",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.ProbeASN != "AS30722" || result.ProbeCC != "IT" {
+			t.Fatal("unexpected probe ASN or CC")
+		}
+		if diff := cmp.Diff(DefaultCheckInURLs(), result.Tests.WebConnectivity.URLs); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("with custom settings", func(t *testing.T) {
+		urls := []model.OOAPIURLInfo{{
+			CategoryCode: "NEWS",
+			CountryCode:  "IT",
+			URL:          "https://www.repubblica.it/",
+		}}
+		ths := map[string][]model.OOAPIService{
+			"web-connectivity": {{
+				Address: "http://127.0.0.1:8080",
+				Type:    "https",
+			}},
+		}
+		backend := &Backend{}
+		backend.SetCheckInURLs(urls)
+		backend.SetTestHelpers(ths)
+		client := newClient(t, backend)
+		result, err := client.CheckIn(context.Background(), model.OOAPICheckInConfig{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(urls, result.Tests.WebConnectivity.URLs); diff != "" {
+			t.Fatal(diff)
+		}
+		if diff := cmp.Diff(ths, result.Conf.TestHelpers); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+}
+
+func TestBackendCollectorAndMeasurementMeta(t *testing.T) {
+	var seen []*model.Measurement
+	backend := &Backend{
+		OnMeasurement: func(meas *model.Measurement) {
+			seen = append(seen, meas)
+		},
+	}
+	client := newClient(t, backend)
+
+	meas := newMeasurement("https://www.example.com/")
+	reportch, err := client.OpenReport(context.Background(), probeservices.NewReportTemplate(meas))
+	if err != nil {
+		t.Fatal(err)
+	}
+	meas.ReportID = reportch.ReportID()
+	if err := reportch.SubmitMeasurement(context.Background(), meas); err != nil {
+		t.Fatal(err)
+	}
+
+	recorded := backend.Measurements()
+	if len(recorded) != 1 || len(seen) != 1 {
+		t.Fatal("expected exactly one measurement")
+	}
+	if recorded[0].ReportID != meas.ReportID || recorded[0].Input != meas.Input {
+		t.Fatal("unexpected recorded measurement")
+	}
+
+	t.Run("measurement meta for an existing measurement", func(t *testing.T) {
+		meta, err := client.GetMeasurementMeta(context.Background(), model.OOAPIMeasurementMetaConfig{
+			ReportID: meas.ReportID,
+			Full:     true,
+			Input:    string(meas.Input),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if meta.ReportID != meas.ReportID || meta.ProbeASN != 30722 || meta.TestName != "web_connectivity" {
+			t.Fatal("unexpected meta", meta)
+		}
+		if meta.Input == nil || *meta.Input != string(meas.Input) {
+			t.Fatal("unexpected input")
+		}
+		if meta.RawMeasurement == "" {
+			t.Fatal("expected the raw measurement")
+		}
+	})
+
+	t.Run("measurement meta for a nonexisting measurement", func(t *testing.T) {
+		meta, err := client.GetMeasurementMeta(context.Background(), model.OOAPIMeasurementMetaConfig{
+			ReportID: "nonexistent",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if meta.ReportID != "" {
+			t.Fatal("expected an empty meta", meta)
+		}
+	})
+
+	t.Run("measurement meta without a report ID", func(t *testing.T) {
+		URL := runtimex.Try1(url.Parse(client.BaseURL))
+		URL.Path = "/api/v1/measurement_meta"
+		resp, err := http.Get(URL.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatal("unexpected status code", resp.StatusCode)
+		}
+	})
+}
+
+func TestBackendLoginAndTunnels(t *testing.T) {
+	backend := &Backend{}
+	backend.SetPsiphonConfig([]byte(`{"psiphon":true}`))
+	backend.SetOpenVPNConfig([]byte(`{"provider":"riseupvpn"}`))
+	client := newClient(t, backend)
+	metadata := model.OOAPIProbeMetadata{
+		Platform:        "linux",
+		ProbeASN:        "AS30722",
+		ProbeCC:         "IT",
+		SoftwareName:    "ooniprobe",
+		SoftwareVersion: "3.21.0",
+		SupportedTests:  []string{"web_connectivity"},
+	}
+	if err := client.MaybeRegister(context.Background(), metadata); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.MaybeLogin(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := client.FetchPsiphonConfig(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"psiphon":true}` {
+		t.Fatal("unexpected psiphon config", string(data))
+	}
+
+	targets, err := client.FetchTorTargets(context.Background(), "IT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 0 {
+		t.Fatal("expected no tor targets")
+	}
+
+	config, err := client.FetchOpenVPNConfig(context.Background(), "riseupvpn", "IT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Provider != "riseupvpn" {
+		t.Fatal("unexpected openvpn config", config)
+	}
+}
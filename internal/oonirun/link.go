@@ -26,6 +26,12 @@ type LinkConfig struct {
 	// Annotations contains OPTIONAL Annotations for the experiment.
 	Annotations map[string]string
 
+	// DryRun OPTIONALLY indicates that we should only show what running the
+	// link would do: we lint the descriptor, log the issues, the estimated costs,
+	// and the changes with respect to the cached descriptor, but we neither run
+	// any nettest nor update the cache.
+	DryRun bool
+
 	// KVStore is the MANDATORY key-value store to use to keep track of
 	// OONI Run links and know when they are new or modified.
 	KVStore model.KeyValueStore
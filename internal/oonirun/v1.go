@@ -71,6 +71,10 @@ func v1Measure(ctx context.Context, config *LinkConfig, URL string) error {
 	if mv := pu.Query().Get("mv"); mv != "1.2.0" {
 		return fmt.Errorf("%w: unknown minimum version", ErrInvalidV1URLQueryArgument)
 	}
+	if config.DryRun {
+		config.Session.Logger().Infof("oonirun/v1: would run %s with %d input(s)", name, len(inputs))
+		return nil
+	}
 	exp := &Experiment{
 		Annotations:            config.Annotations,
 		ExtraOptions:           nil, // no way to specify with v1 URLs
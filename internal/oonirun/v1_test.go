@@ -92,6 +92,22 @@ func TestOONIRunV1Link(t *testing.T) {
 	}
 }
 
+func TestOONIRunV1LinkDryRun(t *testing.T) {
+	sess := newMinimalFakeSession()
+	sess.MockNewExperimentBuilder = func(name string) (model.ExperimentBuilder, error) {
+		panic("should not be called")
+	}
+	config := &LinkConfig{
+		DryRun:  true,
+		KVStore: &kvstore.Memory{},
+		Session: sess,
+	}
+	r := NewLinkRunner(config, "https://run.ooni.io/nettest?tn=example&mv=1.2.0")
+	if err := r.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestV1MeasureInvalidURL(t *testing.T) {
 	t.Run("URL does not parse", func(t *testing.T) {
 		ctx := context.Background()
@@ -64,9 +64,9 @@ type V2Nettest struct {
 	TestName string `json:"test_name"`
 }
 
-// getV2DescriptorFromHTTPSURL GETs a v2Descriptor instance from
+// GetV2DescriptorFromHTTPSURL GETs a v2Descriptor instance from
 // a static URL (e.g., from a GitHub repo or from a Gist).
-func getV2DescriptorFromHTTPSURL(ctx context.Context, client model.HTTPClient,
+func GetV2DescriptorFromHTTPSURL(ctx context.Context, client model.HTTPClient,
 	logger model.Logger, URL, auth string) (*V2Descriptor, error) {
 	if auth != "" {
 		// we assume a bearer token
@@ -150,7 +150,7 @@ func (cache *v2DescriptorCache) PullChangesWithoutSideEffects(
 	ctx context.Context, client model.HTTPClient, logger model.Logger,
 	URL, auth string) (oldValue, newValue *V2Descriptor, err error) {
 	oldValue = cache.Entries[URL]
-	newValue, err = getV2DescriptorFromHTTPSURL(ctx, client, logger, URL, auth)
+	newValue, err = GetV2DescriptorFromHTTPSURL(ctx, client, logger, URL, auth)
 	return
 }
 
@@ -180,6 +180,12 @@ func V2MeasureDescriptor(ctx context.Context, config *LinkConfig, desc *V2Descri
 
 	logger := config.Session.Logger()
 
+	// when dry running, just show what running the descriptor would do
+	if config.DryRun {
+		V2LogReport(logger, desc.Name, V2LintDescriptor(ctx, desc))
+		return nil
+	}
+
 	for _, nettest := range desc.Nettests {
 		// early handling of the case where the test name is empty
 		if nettest.TestName == "" {
@@ -225,8 +231,8 @@ func V2MeasureDescriptor(ctx context.Context, config *LinkConfig, desc *V2Descri
 // we can actually run this set of descriptors.
 var ErrNeedToAcceptChanges = errors.New("oonirun: need to accept changes")
 
-// v2DescriptorDiff shows what changed between the old and the new descriptors.
-func v2DescriptorDiff(oldValue, newValue *V2Descriptor, URL string) string {
+// V2DescriptorDiff shows what changed between the old and the new descriptors.
+func V2DescriptorDiff(oldValue, newValue *V2Descriptor, URL string) string {
 	// JSON serialize old descriptor
 	oldData, err := json.MarshalIndent(oldValue, "", "  ")
 	runtimex.PanicOnError(err, "json.MarshalIndent failed unexpectedly")
@@ -259,8 +265,25 @@ func v2DescriptorDiff(oldValue, newValue *V2Descriptor, URL string) string {
 //
 // In such a case, the caller SHOULD print additional information
 // explaining how to accept changes and then SHOULD exit 1 or similar.
+//
+// When config.DryRun is set, this function logs what has changed along
+// with the lint report and returns without running any nettest.
 func v2MeasureHTTPS(ctx context.Context, config *LinkConfig, URL string) error {
 	logger := config.Session.Logger()
+
+	// when dry running, just show what running the link would do
+	if config.DryRun {
+		report, err := V2DryRun(ctx, config, URL)
+		if err != nil {
+			return err
+		}
+		if report.Diff != "" {
+			logger.Infof("oonirun: %s changed as follows:\n\n%s", URL, report.Diff)
+		}
+		V2LogReport(logger, URL, report)
+		return nil
+	}
+
 	logger.Infof("oonirun/v2: running %s", URL)
 
 	// load the descriptor from the cache
@@ -281,7 +304,7 @@ func v2MeasureHTTPS(ctx context.Context, config *LinkConfig, URL string) error {
 	}
 
 	// compare the new descriptor to the old descriptor
-	diff := v2DescriptorDiff(oldValue, newValue, URL)
+	diff := V2DescriptorDiff(oldValue, newValue, URL)
 
 	// possibly stop if configured to ask for permission when accepting changes
 	if !config.AcceptChanges && diff != "" {
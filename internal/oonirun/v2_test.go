@@ -456,6 +456,29 @@ func TestOONIRunV2LinkNonParseableJSON(t *testing.T) {
 
 func TestV2MeasureDescriptor(t *testing.T) {
 
+	t.Run("when dry running", func(t *testing.T) {
+		sess := newMinimalFakeSession()
+		sess.MockNewExperimentBuilder = func(name string) (model.ExperimentBuilder, error) {
+			panic("should not be called")
+		}
+		config := &LinkConfig{
+			DryRun:  true,
+			Session: sess,
+		}
+		desc := &V2Descriptor{
+			Name:        "Example",
+			Description: "Example descriptor",
+			Author:      "OONI",
+			Nettests: []V2Nettest{{
+				Options:  json.RawMessage(`{"SleepTime": 10000000}`),
+				TestName: "example",
+			}},
+		}
+		if err := V2MeasureDescriptor(context.Background(), config, desc); err != nil {
+			t.Fatal(err)
+		}
+	})
+
 	t.Run("with nil descriptor", func(t *testing.T) {
 		ctx := context.Background()
 		config := &LinkConfig{}
@@ -579,6 +602,49 @@ func TestV2MeasureDescriptor(t *testing.T) {
 
 func TestV2MeasureHTTPS(t *testing.T) {
 
+	t.Run("when dry running", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"name":"Example","nettests":[{"test_name":"example"}]}`))
+		}))
+		defer server.Close()
+
+		sess := newMinimalFakeSession()
+		sess.MockNewExperimentBuilder = func(name string) (model.ExperimentBuilder, error) {
+			panic("should not be called")
+		}
+		store := &kvstore.Memory{}
+		config := &LinkConfig{
+			DryRun:  true,
+			KVStore: store,
+			Session: sess,
+		}
+
+		// make sure we neither run nor accept the changes although we did not set AcceptChanges
+		if err := v2MeasureHTTPS(context.Background(), config, server.URL); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.Get(v2DescriptorCacheKey); !errors.Is(err, kvstore.ErrNoSuchKey) {
+			t.Fatal("expected the cache to be unmodified", err)
+		}
+	})
+
+	t.Run("when dry running and we cannot load from cache", func(t *testing.T) {
+		expected := errors.New("mocked error")
+		config := &LinkConfig{
+			DryRun: true,
+			KVStore: &mocks.KeyValueStore{
+				MockGet: func(key string) (value []byte, err error) {
+					return nil, expected
+				},
+			},
+			Session: newMinimalFakeSession(),
+		}
+		err := v2MeasureHTTPS(context.Background(), config, "")
+		if !errors.Is(err, expected) {
+			t.Fatal("unexpected err", err)
+		}
+	})
+
 	t.Run("when we cannot load from cache", func(t *testing.T) {
 		expected := errors.New("mocked error")
 		ctx := context.Background()
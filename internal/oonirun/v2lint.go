@@ -0,0 +1,468 @@
+package oonirun
+
+//
+// OONI Run v2 descriptors validation
+//
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ooni/probe-cli/v3/internal/experimentname"
+	"github.com/ooni/probe-cli/v3/internal/humanize"
+	"github.com/ooni/probe-cli/v3/internal/inputparser"
+	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/registry"
+)
+
+// V2LintSeverity is the severity of a [*V2LintIssue].
+type V2LintSeverity string
+
+const (
+	// V2LintError indicates that we cannot run the descriptor as is.
+	V2LintError = V2LintSeverity("error")
+
+	// V2LintWarning indicates that the descriptor runs but could be improved.
+	V2LintWarning = V2LintSeverity("warning")
+)
+
+// V2LintIssue is an issue found when linting a [*V2Descriptor].
+type V2LintIssue struct {
+	// Severity is the issue severity.
+	Severity V2LintSeverity
+
+	// Nettest is the index of the nettest inside the descriptor or
+	// -1 when the issue refers to the descriptor as a whole.
+	Nettest int
+
+	// TestName is the name of the nettest, if any.
+	TestName string
+
+	// Message describes the issue.
+	Message string
+}
+
+// String returns a human readable representation of the issue.
+func (issue *V2LintIssue) String() string {
+	if issue.Nettest < 0 {
+		return fmt.Sprintf("%s: %s", issue.Severity, issue.Message)
+	}
+	return fmt.Sprintf("%s: nettests[%d] (%s): %s", issue.Severity, issue.Nettest, issue.TestName, issue.Message)
+}
+
+// V2NettestEstimate contains the estimated cost of running a nettest.
+type V2NettestEstimate struct {
+	// TestName is the canonical name of the nettest.
+	TestName string
+
+	// NumInputs is the number of inputs we're going to measure. When
+	// InputsFromBackend is true, this is zero and the estimate refers
+	// to measuring a single input.
+	NumInputs int
+
+	// InputsFromBackend indicates that the inputs are going to be fetched
+	// from the OONI backend when running, so we cannot count them.
+	InputsFromBackend bool
+
+	// Runtime is the estimated runtime.
+	Runtime time.Duration
+
+	// DataUsage is the estimated data usage in bytes.
+	DataUsage int64
+}
+
+// V2LintReport is the result of linting a [*V2Descriptor].
+type V2LintReport struct {
+	// Issues contains the issues we found.
+	Issues []*V2LintIssue
+
+	// Estimates contains the estimated cost of each valid nettest.
+	Estimates []*V2NettestEstimate
+
+	// Runtime is the estimated runtime of the whole descriptor.
+	Runtime time.Duration
+
+	// DataUsage is the estimated data usage of the whole descriptor in bytes.
+	DataUsage int64
+
+	// Diff is the diff between the cached descriptor and the linted
+	// one, which is only set by [V2DryRun] and may be empty.
+	Diff string
+}
+
+// Errors returns the number of issues with [V2LintError] severity.
+func (r *V2LintReport) Errors() (count int) {
+	for _, issue := range r.Issues {
+		if issue.Severity == V2LintError {
+			count++
+		}
+	}
+	return
+}
+
+// Warnings returns the number of issues with [V2LintWarning] severity.
+func (r *V2LintReport) Warnings() (count int) {
+	for _, issue := range r.Issues {
+		if issue.Severity == V2LintWarning {
+			count++
+		}
+	}
+	return
+}
+
+// add adds a new issue to the report.
+func (r *V2LintReport) add(severity V2LintSeverity, idx int, name, format string, v ...any) {
+	r.Issues = append(r.Issues, &V2LintIssue{
+		Severity: severity,
+		Nettest:  idx,
+		TestName: name,
+		Message:  fmt.Sprintf(format, v...),
+	})
+}
+
+// v2NettestCost is the rough cost of measuring a single input with a nettest.
+type v2NettestCost struct {
+	runtime   time.Duration
+	dataUsage int64
+}
+
+// v2NettestCosts contains rough per-input costs for nettests whose cost differs
+// significantly from v2DefaultNettestCost. These values only aim to give an idea of
+// the cost of running a descriptor and depend on the network conditions.
+var v2NettestCosts = map[string]v2NettestCost{
+	"dash":                  {runtime: 50 * time.Second, dataUsage: 60 << 20},
+	"dnscheck":              {runtime: 5 * time.Second, dataUsage: 20 << 10},
+	"facebook_messenger":    {runtime: 10 * time.Second, dataUsage: 100 << 10},
+	"ndt":                   {runtime: 25 * time.Second, dataUsage: 100 << 20},
+	"psiphon":               {runtime: 30 * time.Second, dataUsage: 1 << 20},
+	"signal":                {runtime: 10 * time.Second, dataUsage: 100 << 10},
+	"stunreachability":      {runtime: 2 * time.Second, dataUsage: 1 << 10},
+	"telegram":              {runtime: 20 * time.Second, dataUsage: 2 << 20},
+	"tor":                   {runtime: 60 * time.Second, dataUsage: 2 << 20},
+	"torsf":                 {runtime: 120 * time.Second, dataUsage: 2 << 20},
+	"vanilla_tor":           {runtime: 120 * time.Second, dataUsage: 2 << 20},
+	"web_connectivity":      {runtime: 5 * time.Second, dataUsage: 250 << 10},
+	"web_connectivity@v0.5": {runtime: 5 * time.Second, dataUsage: 250 << 10},
+	"whatsapp":              {runtime: 20 * time.Second, dataUsage: 1 << 20},
+}
+
+// v2DefaultNettestCost is the cost of nettests not inside v2NettestCosts.
+var v2DefaultNettestCost = v2NettestCost{runtime: 10 * time.Second, dataUsage: 50 << 10}
+
+// errV2LintOffline indicates that linting would need to contact the OONI backend.
+var errV2LintOffline = errors.New("oonirun: linting does not contact the OONI backend")
+
+// v2LintSession is the [model.ExperimentTargetLoaderSession] we use when linting,
+// which fails every operation requiring the OONI backend.
+type v2LintSession struct{}
+
+var _ model.ExperimentTargetLoaderSession = &v2LintSession{}
+
+// CheckIn implements model.ExperimentTargetLoaderSession.
+func (*v2LintSession) CheckIn(ctx context.Context, config *model.OOAPICheckInConfig) (*model.OOAPICheckInResult, error) {
+	return nil, errV2LintOffline
+}
+
+// FetchOpenVPNConfig implements model.ExperimentTargetLoaderSession.
+func (*v2LintSession) FetchOpenVPNConfig(ctx context.Context, provider, cc string) (*model.OOAPIVPNProviderConfig, error) {
+	return nil, errV2LintOffline
+}
+
+// Logger implements model.ExperimentTargetLoaderSession.
+func (*v2LintSession) Logger() model.Logger {
+	return model.DiscardLogger
+}
+
+// ProbeCC implements model.ExperimentTargetLoaderSession.
+func (*v2LintSession) ProbeCC() string {
+	return model.DefaultProbeCC
+}
+
+// V2LintDescriptor checks whether the given descriptor is valid without contacting
+// the OONI backend. We check whether nettest names exist in the registry, whether
+// options have the types expected by each experiment, and whether we can load the
+// inputs. We also estimate the runtime and data usage of the descriptor.
+func V2LintDescriptor(ctx context.Context, desc *V2Descriptor) *V2LintReport {
+	report := &V2LintReport{}
+	if desc == nil {
+		report.add(V2LintError, -1, "", "%s", ErrNilDescriptor.Error())
+		return report
+	}
+	if desc.Name == "" {
+		report.add(V2LintWarning, -1, "", "the descriptor name is empty")
+	}
+	if desc.Description == "" {
+		report.add(V2LintWarning, -1, "", "the descriptor description is empty")
+	}
+	if desc.Author == "" {
+		report.add(V2LintWarning, -1, "", "the descriptor author is empty")
+	}
+	if len(desc.Nettests) <= 0 {
+		report.add(V2LintError, -1, "", "the descriptor does not contain any nettest")
+	}
+	for idx, nettest := range desc.Nettests {
+		if estimate := v2LintNettest(ctx, report, idx, &nettest); estimate != nil {
+			report.Estimates = append(report.Estimates, estimate)
+			report.Runtime += estimate.Runtime
+			report.DataUsage += estimate.DataUsage
+		}
+	}
+	return report
+}
+
+// v2LintNettest lints the nettest at index idx and returns its estimated
+// cost when the nettest is valid and nil otherwise.
+func v2LintNettest(ctx context.Context, report *V2LintReport, idx int, nettest *V2Nettest) *V2NettestEstimate {
+	// make sure the nettest exists
+	if nettest.TestName == "" {
+		report.add(V2LintError, idx, "", "the nettest name is empty")
+		return nil
+	}
+	name := experimentname.Canonicalize(nettest.TestName)
+	ff := registry.AllExperiments[name]
+	if ff == nil {
+		report.add(V2LintError, idx, nettest.TestName, "%s", registry.ErrNoSuchExperiment.Error())
+		return nil
+	}
+	if name != nettest.TestName {
+		report.add(V2LintWarning, idx, nettest.TestName, "the canonical nettest name is %s", name)
+	}
+	factory := ff()
+
+	// make sure the options are valid
+	valid := v2LintOptions(report, idx, nettest, factory)
+
+	// make sure the inputs are valid
+	valid = v2LintInputs(report, idx, nettest, factory) && valid
+	if !valid {
+		return nil
+	}
+
+	// load the inputs like we would do when measuring
+	loader := factory.NewTargetLoader(&model.ExperimentTargetLoaderConfig{
+		CheckInConfig: nil,
+		Session:       &v2LintSession{},
+		StaticInputs:  nettest.Inputs,
+		SourceFiles:   []string{},
+	})
+	estimate := &V2NettestEstimate{TestName: name}
+	targets, err := loader.Load(ctx)
+	switch {
+	case errors.Is(err, errV2LintOffline):
+		estimate.InputsFromBackend = true
+	case err != nil:
+		report.add(V2LintError, idx, nettest.TestName, "cannot load inputs: %s", err.Error())
+		return nil
+	default:
+		estimate.NumInputs = len(targets)
+	}
+
+	// estimate the cost of running this nettest
+	cost, found := v2NettestCosts[name]
+	if !found {
+		cost = v2DefaultNettestCost
+	}
+	count := max(estimate.NumInputs, 1)
+	estimate.Runtime = time.Duration(count) * cost.runtime
+	estimate.DataUsage = int64(count) * cost.dataUsage
+	return estimate
+}
+
+// v2LintOptions lints the options of the nettest and returns whether they are valid.
+func v2LintOptions(report *V2LintReport, idx int, nettest *V2Nettest, factory *registry.Factory) bool {
+	if len(nettest.Options) <= 0 {
+		return true
+	}
+	var options map[string]any
+	if err := json.Unmarshal(nettest.Options, &options); err != nil {
+		report.add(V2LintError, idx, nettest.TestName, "cannot parse options: %s", err.Error())
+		return false
+	}
+
+	// check the types of the options documented by the experiment
+	//
+	// note: encoding/json matches field names case insensitively
+	infos, err := factory.Options()
+	if err != nil {
+		report.add(V2LintError, idx, nettest.TestName, "cannot get options: %s", err.Error())
+		return false
+	}
+	valid := true
+	for key, value := range options {
+		for name, info := range infos {
+			if !strings.EqualFold(key, name) || v2LintOptionHasType(value, info.Type) {
+				continue
+			}
+			report.add(V2LintError, idx, nettest.TestName,
+				"option %s should be of type %s, got %T", key, info.Type, value)
+			valid = false
+		}
+	}
+	if !valid {
+		return false
+	}
+
+	// catch unknown options and options not documented by the experiment
+	if err := factory.ValidateOptionsJSON(nettest.Options); err != nil {
+		report.add(V2LintError, idx, nettest.TestName, "invalid options: %s", err.Error())
+		return false
+	}
+	return true
+}
+
+// v2LintOptionHasType returns whether the JSON value is compatible with the
+// given option type. We return true for types we don't know about.
+func v2LintOptionHasType(value any, typ string) bool {
+	switch typ {
+	case "bool":
+		_, good := value.(bool)
+		return good
+	case "string":
+		_, good := value.(string)
+		return good
+	case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64":
+		number, good := value.(float64)
+		return good && number == float64(int64(number))
+	case "[]string":
+		values, good := value.([]any)
+		for _, entry := range values {
+			if _, isString := entry.(string); !isString {
+				return false
+			}
+		}
+		return good
+	default:
+		return true
+	}
+}
+
+// v2LintInputs lints the inputs of the nettest and returns whether they are valid.
+func v2LintInputs(report *V2LintReport, idx int, nettest *V2Nettest, factory *registry.Factory) bool {
+	valid := true
+	seen := make(map[string]bool)
+	for _, input := range nettest.Inputs {
+		if input == "" {
+			report.add(V2LintError, idx, nettest.TestName, "empty input")
+			valid = false
+			continue
+		}
+		if seen[input] {
+			report.add(V2LintWarning, idx, nettest.TestName, "duplicate input: %s", input)
+		}
+		seen[input] = true
+
+		// experiments querying the backend for inputs measure URLs
+		if factory.InputPolicy() != model.InputOrQueryBackend {
+			continue
+		}
+		config := &inputparser.Config{AcceptedSchemes: []string{"http", "https"}}
+		if _, err := inputparser.Parse(config, model.MeasurementInput(input)); err != nil {
+			report.add(V2LintError, idx, nettest.TestName, "invalid input: %s", err.Error())
+			valid = false
+		}
+	}
+	return valid
+}
+
+// V2DryRun fetches the OONI Run v2 descriptor at the given URL, lints it and
+// computes the diff with respect to the cached descriptor. This function does
+// not run any nettest and does not modify the cache.
+func V2DryRun(ctx context.Context, config *LinkConfig, URL string) (*V2LintReport, error) {
+	logger := config.Session.Logger()
+	logger.Infof("oonirun/v2: dry-running %s", URL)
+
+	// load the descriptor from the cache
+	cache, err := v2DescriptorCacheLoad(config.KVStore)
+	if err != nil {
+		return nil, err
+	}
+
+	// pull a possibly new descriptor without updating the old descriptor
+	clnt := config.Session.DefaultHTTPClient()
+	auth, err := v2MaybeGetAuthenticationTokenFromFile(config.AuthFile)
+	if err != nil {
+		logger.Warnf("oonirun: failed to retrieve auth token: %v", err)
+	}
+	oldValue, newValue, err := cache.PullChangesWithoutSideEffects(ctx, clnt, logger, URL, auth)
+	if err != nil {
+		return nil, err
+	}
+
+	// lint and compare the new descriptor to the old descriptor
+	report := V2LintDescriptor(ctx, newValue)
+	report.Diff = V2DescriptorDiff(oldValue, newValue, URL)
+	return report, nil
+}
+
+// V2ReadDescriptorFile reads an OONI Run v2 descriptor from the given file.
+func V2ReadDescriptorFile(filename string) (*V2Descriptor, error) {
+	data, err := os.ReadFile(filename) // #nosec G304 - this is working as intended
+	if err != nil {
+		return nil, err
+	}
+	var desc V2Descriptor
+	if err := json.Unmarshal(data, &desc); err != nil {
+		return nil, err
+	}
+	return &desc, nil
+}
+
+// ErrV2LintFailed indicates that linting OONI Run v2 descriptors found errors
+// or, when linting in strict mode, warnings.
+var ErrV2LintFailed = errors.New("oonirun: linting descriptors failed")
+
+// V2LintFiles lints the OONI Run v2 descriptors inside the given files, logs the
+// errors and, when strict, the warnings, and returns [ErrV2LintFailed] if there
+// are errors or, when strict, warnings.
+func V2LintFiles(ctx context.Context, logger model.Logger, filenames []string, strict bool) error {
+	var numErrors, numWarnings int
+	for _, filename := range filenames {
+		desc, err := V2ReadDescriptorFile(filename)
+		if err != nil {
+			logger.Warnf("oonirun: %s: %s", filename, err.Error())
+			numErrors++
+			continue
+		}
+		report := V2LintDescriptor(ctx, desc)
+		V2LogIssues(logger, filename, report, strict)
+		numErrors += report.Errors()
+		numWarnings += report.Warnings()
+	}
+	if numErrors > 0 || (strict && numWarnings > 0) {
+		return fmt.Errorf("%w: %d error(s) and %d warning(s)", ErrV2LintFailed, numErrors, numWarnings)
+	}
+	logger.Infof("oonirun: found %d error(s) and %d warning(s)", numErrors, numWarnings)
+	return nil
+}
+
+// V2LogIssues logs the errors and possibly the warnings inside the report.
+func V2LogIssues(logger model.Logger, source string, report *V2LintReport, warnings bool) {
+	for _, issue := range report.Issues {
+		switch {
+		case issue.Severity == V2LintError:
+			logger.Warnf("oonirun: %s: %s", source, issue.String())
+		case warnings:
+			logger.Infof("oonirun: %s: %s", source, issue.String())
+		}
+	}
+}
+
+// V2LogReport logs the issues and the estimates inside the report.
+func V2LogReport(logger model.Logger, source string, report *V2LintReport) {
+	V2LogIssues(logger, source, report, true)
+	for _, estimate := range report.Estimates {
+		inputs := fmt.Sprintf("%d input(s)", estimate.NumInputs)
+		if estimate.InputsFromBackend {
+			inputs = "each input fetched from the backend"
+		}
+		logger.Infof("oonirun: %s: %s (%s): ~%s, ~%s", source, estimate.TestName, inputs,
+			estimate.Runtime, humanize.SI(float64(estimate.DataUsage), "byte"))
+	}
+	logger.Infof("oonirun: %s: estimated total: ~%s, ~%s", source,
+		report.Runtime, humanize.SI(float64(report.DataUsage), "byte"))
+}
@@ -0,0 +1,278 @@
+package oonirun
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ooni/probe-cli/v3/internal/kvstore"
+	"github.com/ooni/probe-cli/v3/internal/mocks"
+	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/runtimex"
+)
+
+// v2LintIssuesContain returns whether there is an issue with the given severity
+// and whose string representation contains the given substring.
+func v2LintIssuesContain(report *V2LintReport, severity V2LintSeverity, substring string) bool {
+	for _, issue := range report.Issues {
+		if issue.Severity == severity && strings.Contains(issue.String(), substring) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestV2LintDescriptor(t *testing.T) {
+	t.Run("with a nil descriptor", func(t *testing.T) {
+		report := V2LintDescriptor(context.Background(), nil)
+		if report.Errors() != 1 || !v2LintIssuesContain(report, V2LintError, "descriptor is nil") {
+			t.Fatal("unexpected issues", report.Issues)
+		}
+	})
+
+	t.Run("with a valid descriptor", func(t *testing.T) {
+		desc := &V2Descriptor{
+			Name:        "Example",
+			Description: "Example descriptor",
+			Author:      "OONI",
+			Nettests: []V2Nettest{{
+				Options:  json.RawMessage(`{"SleepTime": 10000000, "Message": "hello"}`),
+				TestName: "example",
+			}, {
+				Inputs:   []string{"https://www.example.com/", "http://www.example.org/"},
+				TestName: "web_connectivity",
+			}},
+		}
+		report := V2LintDescriptor(context.Background(), desc)
+		if len(report.Issues) != 0 {
+			t.Fatal("unexpected issues", report.Issues)
+		}
+		if len(report.Estimates) != 2 {
+			t.Fatal("unexpected estimates", report.Estimates)
+		}
+		if report.Estimates[0].NumInputs != 1 || report.Estimates[1].NumInputs != 2 {
+			t.Fatal("unexpected number of inputs", report.Estimates[0], report.Estimates[1])
+		}
+		if report.Runtime != 20*time.Second {
+			t.Fatal("unexpected runtime", report.Runtime)
+		}
+		if report.DataUsage != (50<<10)+2*(250<<10) {
+			t.Fatal("unexpected data usage", report.DataUsage)
+		}
+	})
+
+	t.Run("with inputs fetched from the backend", func(t *testing.T) {
+		desc := &V2Descriptor{
+			Name:        "Websites",
+			Description: "Measures websites",
+			Author:      "OONI",
+			Nettests:    []V2Nettest{{TestName: "web_connectivity"}},
+		}
+		report := V2LintDescriptor(context.Background(), desc)
+		if len(report.Issues) != 0 {
+			t.Fatal("unexpected issues", report.Issues)
+		}
+		if !report.Estimates[0].InputsFromBackend {
+			t.Fatal("expected inputs from backend")
+		}
+	})
+
+	t.Run("with descriptor level issues", func(t *testing.T) {
+		report := V2LintDescriptor(context.Background(), &V2Descriptor{})
+		if report.Errors() != 1 || report.Warnings() != 3 {
+			t.Fatal("unexpected issues", report.Issues)
+		}
+		if !v2LintIssuesContain(report, V2LintError, "does not contain any nettest") {
+			t.Fatal("unexpected issues", report.Issues)
+		}
+	})
+
+	t.Run("with nettest level issues", func(t *testing.T) {
+		desc := &V2Descriptor{
+			Name:        "Broken",
+			Description: "Broken descriptor",
+			Author:      "OONI",
+			Nettests: []V2Nettest{{
+				TestName: "",
+			}, {
+				TestName: "antani",
+			}, {
+				Options:  json.RawMessage(`{"SleepTime": "ten"}`),
+				TestName: "example",
+			}, {
+				Options:  json.RawMessage(`{"Antani": true}`),
+				TestName: "example",
+			}, {
+				Options:  json.RawMessage(`[]`),
+				TestName: "example",
+			}, {
+				Inputs:   []string{"https://www.example.com/"},
+				TestName: "example",
+			}, {
+				Inputs:   []string{"", "ftp://www.example.com/"},
+				TestName: "web_connectivity",
+			}, {
+				Inputs:   []string{"https://www.example.com/", "https://www.example.com/"},
+				TestName: "WebConnectivity",
+			}},
+		}
+		report := V2LintDescriptor(context.Background(), desc)
+		expectations := []struct {
+			severity  V2LintSeverity
+			substring string
+		}{
+			{V2LintError, "nettests[0] (): the nettest name is empty"},
+			{V2LintError, "nettests[1] (antani): no such experiment"},
+			{V2LintError, "nettests[2] (example): option SleepTime should be of type int64, got string"},
+			{V2LintError, `nettests[3] (example): invalid options: json: unknown field "Antani"`},
+			{V2LintError, "nettests[4] (example): cannot parse options"},
+			{V2LintError, "nettests[5] (example): cannot load inputs: we did not expect any input"},
+			{V2LintError, "nettests[6] (web_connectivity): empty input"},
+			{V2LintError, "nettests[6] (web_connectivity): invalid input: inputparser: unsupported URL.Scheme"},
+			{V2LintWarning, "nettests[7] (WebConnectivity): the canonical nettest name is web_connectivity"},
+			{V2LintWarning, "nettests[7] (WebConnectivity): duplicate input: https://www.example.com/"},
+		}
+		for _, expect := range expectations {
+			if !v2LintIssuesContain(report, expect.severity, expect.substring) {
+				t.Fatal("missing issue", expect.substring, "in", report.Issues)
+			}
+		}
+		if report.Errors() != 8 || report.Warnings() != 2 {
+			t.Fatal("unexpected issues", report.Issues)
+		}
+		if len(report.Estimates) != 1 || report.Estimates[0].NumInputs != 2 {
+			t.Fatal("unexpected estimates", report.Estimates)
+		}
+	})
+}
+
+func TestV2DryRun(t *testing.T) {
+	// make a local server that returns a reasonable descriptor for the example experiment
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		descriptor := &V2Descriptor{
+			Name:        "Example",
+			Description: "Example descriptor",
+			Author:      "OONI",
+			Nettests: []V2Nettest{{
+				Options:  json.RawMessage(`{"SleepTime": 10000000}`),
+				TestName: "example",
+			}},
+		}
+		data, err := json.Marshal(descriptor)
+		runtimex.PanicOnError(err, "json.Marshal failed")
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	t.Run("we lint and diff without modifying the cache", func(t *testing.T) {
+		store := &kvstore.Memory{}
+		config := &LinkConfig{
+			KVStore: store,
+			Session: newMinimalFakeSession(),
+		}
+		report, err := V2DryRun(context.Background(), config, server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(report.Issues) != 0 {
+			t.Fatal("unexpected issues", report.Issues)
+		}
+		if !strings.Contains(report.Diff, `+  "name": "Example",`) {
+			t.Fatal("unexpected diff", report.Diff)
+		}
+		if _, err := store.Get(v2DescriptorCacheKey); !errors.Is(err, kvstore.ErrNoSuchKey) {
+			t.Fatal("expected the cache to be unmodified", err)
+		}
+	})
+
+	t.Run("we handle errors when loading the cache", func(t *testing.T) {
+		expected := errors.New("mocked error")
+		config := &LinkConfig{
+			KVStore: &mocks.KeyValueStore{
+				MockGet: func(key string) ([]byte, error) {
+					return nil, expected
+				},
+			},
+			Session: newMinimalFakeSession(),
+		}
+		report, err := V2DryRun(context.Background(), config, server.URL)
+		if !errors.Is(err, expected) {
+			t.Fatal("unexpected error", err)
+		}
+		if report != nil {
+			t.Fatal("expected nil report")
+		}
+	})
+
+	t.Run("we handle errors when fetching the descriptor", func(t *testing.T) {
+		config := &LinkConfig{
+			KVStore: &kvstore.Memory{},
+			Session: newMinimalFakeSession(),
+		}
+		report, err := V2DryRun(context.Background(), config, "http://127.0.0.1:0/")
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+		if report != nil {
+			t.Fatal("expected nil report")
+		}
+	})
+}
+
+func TestV2LintFiles(t *testing.T) {
+	// writeFile writes the given content into a file inside a temporary directory
+	writeFile := func(t *testing.T, content string) string {
+		filename := filepath.Join(t.TempDir(), "descriptor.json")
+		runtimex.Try0(os.WriteFile(filename, []byte(content), 0600))
+		return filename
+	}
+
+	valid := `{"name":"Example","description":"Example descriptor","author":"OONI",` +
+		`"nettests":[{"test_name":"example","options":{"SleepTime":10000000}}]}`
+	withWarnings := `{"nettests":[{"test_name":"example"}]}`
+
+	t.Run("with a valid descriptor", func(t *testing.T) {
+		filenames := []string{writeFile(t, valid)}
+		if err := V2LintFiles(context.Background(), model.DiscardLogger, filenames, true); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("with warnings when not strict", func(t *testing.T) {
+		filenames := []string{writeFile(t, withWarnings)}
+		if err := V2LintFiles(context.Background(), model.DiscardLogger, filenames, false); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("with warnings when strict", func(t *testing.T) {
+		filenames := []string{writeFile(t, withWarnings)}
+		err := V2LintFiles(context.Background(), model.DiscardLogger, filenames, true)
+		if !errors.Is(err, ErrV2LintFailed) {
+			t.Fatal("unexpected error", err)
+		}
+	})
+
+	t.Run("with a file we cannot read", func(t *testing.T) {
+		filenames := []string{filepath.Join(t.TempDir(), "nonexistent.json")}
+		err := V2LintFiles(context.Background(), model.DiscardLogger, filenames, false)
+		if !errors.Is(err, ErrV2LintFailed) {
+			t.Fatal("unexpected error", err)
+		}
+	})
+
+	t.Run("with a file we cannot parse", func(t *testing.T) {
+		filenames := []string{writeFile(t, "{")}
+		err := V2LintFiles(context.Background(), model.DiscardLogger, filenames, false)
+		if !errors.Is(err, ErrV2LintFailed) {
+			t.Fatal("unexpected error", err)
+		}
+	})
+}
@@ -0,0 +1,113 @@
+package packetcapture
+
+//
+// Capturing packets using AF_PACKET sockets
+//
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// afpacketSource reads packets from an AF_PACKET socket in a background goroutine.
+type afpacketSource struct {
+	// closeOnce provides "once" semantics for Close.
+	closeOnce sync.Once
+
+	// done is closed to tell the background goroutine to stop.
+	done chan any
+
+	// fd is the socket file descriptor.
+	fd int
+
+	// joined is closed when the background goroutine has terminated.
+	joined chan any
+}
+
+// htons converts a short to network byte order.
+func htons(v uint16) uint16 {
+	return v<<8 | v>>8
+}
+
+// newAFPacketSource creates a cooked AF_PACKET socket capturing the IPv4 and IPv6
+// packets sent and received using all the interfaces and delivers them to c.
+func newAFPacketSource(c *Capture) (*afpacketSource, error) {
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, int(htons(unix.ETH_P_ALL)))
+	if err != nil {
+		return nil, err
+	}
+	// make sure recvfrom periodically returns so we can check whether we're done
+	tv := unix.NsecToTimeval((250 * time.Millisecond).Nanoseconds())
+	if err := unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv); err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+	s := &afpacketSource{
+		closeOnce: sync.Once{},
+		done:      make(chan any),
+		fd:        fd,
+		joined:    make(chan any),
+	}
+	go s.loop(c, loopbackInterfaces())
+	return s, nil
+}
+
+// loopbackInterfaces returns the indexes of the loopback interfaces.
+func loopbackInterfaces() map[int]bool {
+	out := map[int]bool{}
+	ifaces, _ := net.Interfaces()
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 {
+			out[iface.Index] = true
+		}
+	}
+	return out
+}
+
+// loop reads packets until we're done.
+func (s *afpacketSource) loop(c *Capture, loopback map[int]bool) {
+	defer close(s.joined)
+	buffer := make([]byte, DefaultSnapLen)
+	for {
+		select {
+		case <-s.done:
+			return
+		default:
+		}
+		count, from, err := unix.Recvfrom(s.fd, buffer, 0)
+		if errors.Is(err, unix.EAGAIN) || errors.Is(err, unix.EINTR) {
+			continue
+		}
+		if err != nil {
+			c.logger.Warnf("packetcapture: recvfrom: %s", err.Error())
+			return
+		}
+		sll, good := from.(*unix.SockaddrLinklayer)
+		if !good {
+			continue
+		}
+		if proto := htons(sll.Protocol); proto != unix.ETH_P_IP && proto != unix.ETH_P_IPV6 {
+			continue
+		}
+		// we see the packets sent over the loopback twice, so skip the outgoing copy
+		if sll.Pkttype == unix.PACKET_OUTGOING && loopback[sll.Ifindex] {
+			continue
+		}
+		c.WritePacket(time.Now(), buffer[:count])
+	}
+}
+
+// Close stops the background goroutine and closes the socket.
+func (s *afpacketSource) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.done)
+		<-s.joined
+		err = unix.Close(s.fd)
+	})
+	return err
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package packetcapture
+
+//
+// Capturing packets using AF_PACKET sockets (unsupported)
+//
+
+import "io"
+
+// newAFPacketSource returns [ErrNotSupported] on systems other than Linux.
+func newAFPacketSource(c *Capture) (io.Closer, error) {
+	return nil, ErrNotSupported
+}
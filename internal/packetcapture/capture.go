@@ -0,0 +1,238 @@
+package packetcapture
+
+//
+// Implementation of Capture
+//
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ooni/probe-cli/v3/internal/model"
+)
+
+// DefaultSnapLen is the default maximum number of bytes we capture per packet.
+const DefaultSnapLen = 262144
+
+// ErrNotSupported indicates that capturing is not supported on this platform.
+var ErrNotSupported = errors.New("packetcapture: not supported on this platform")
+
+// Config contains config for creating a [*Capture].
+type Config struct {
+	// Filename is the MANDATORY name of the pcapng file to create.
+	Filename string
+
+	// Logger is the MANDATORY logger to use.
+	Logger model.Logger
+
+	// SnapLen is the OPTIONAL maximum number of bytes to capture
+	// per packet. If zero or negative, we use [DefaultSnapLen].
+	SnapLen int
+}
+
+// Capture writes the packets of the flows created by traces into a pcapng
+// file. The zero value is invalid; please, use [New] or [Start].
+//
+// The methods of a nil *Capture are no-ops, such that the code using
+// [ContextCapture] does not need to check whether we are capturing.
+type Capture struct {
+	// bufw buffers writes to filep.
+	bufw *bufio.Writer
+
+	// closeOnce provides "once" semantics for Close.
+	closeOnce sync.Once
+
+	// closed indicates that we should not write anymore.
+	closed bool
+
+	// err is the first error that occurred when writing.
+	err error
+
+	// filep is the open pcapng file.
+	filep *os.File
+
+	// flows contains the flows created by traces.
+	flows *flowTable
+
+	// logger is the logger to use.
+	logger model.Logger
+
+	// mu provides mutual exclusion.
+	mu sync.Mutex
+
+	// snapLen is the maximum number of bytes to capture per packet.
+	snapLen int
+
+	// source is the possibly-nil packets source.
+	source io.Closer
+
+	// writer writes pcapng blocks into bufw.
+	writer *pcapngWriter
+}
+
+// New creates a new [*Capture] writing into the configured file. You need to
+// deliver packets to the returned [*Capture] using [*Capture.WritePacket] or by
+// registering the [*Capture] as a NIC wrapper for netem using [*Capture.WrapNIC].
+func New(config *Config) (*Capture, error) {
+	snapLen := config.SnapLen
+	if snapLen <= 0 {
+		snapLen = DefaultSnapLen
+	}
+	filep, err := os.Create(config.Filename)
+	if err != nil {
+		return nil, err
+	}
+	bufw := bufio.NewWriter(filep)
+	writer, err := newPCAPNGWriter(bufw, uint32(snapLen))
+	if err != nil {
+		filep.Close()
+		return nil, err
+	}
+	c := &Capture{
+		bufw:      bufw,
+		closeOnce: sync.Once{},
+		closed:    false,
+		err:       nil,
+		filep:     filep,
+		flows:     newFlowTable(),
+		logger:    config.Logger,
+		mu:        sync.Mutex{},
+		snapLen:   snapLen,
+		source:    nil,
+		writer:    writer,
+	}
+	return c, nil
+}
+
+// Start is like [New] but also starts capturing packets using an AF_PACKET
+// socket. This function returns [ErrNotSupported] on systems other than Linux and
+// fails if we do not have the permission to capture (i.e., CAP_NET_RAW).
+func Start(config *Config) (*Capture, error) {
+	c, err := New(config)
+	if err != nil {
+		return nil, err
+	}
+	source, err := newAFPacketSource(c)
+	if err != nil {
+		c.Close()
+		os.Remove(config.Filename)
+		return nil, err
+	}
+	c.source = source
+	return c, nil
+}
+
+// Filename returns the name of the pcapng file.
+func (c *Capture) Filename() string {
+	return c.filep.Name()
+}
+
+// AddRemote registers the remote endpoint the trace with the given
+// index is going to use for the given network.
+func (c *Capture) AddRemote(index int64, network, remoteAddr string) {
+	if c != nil {
+		defer c.mu.Unlock()
+		c.mu.Lock()
+		c.flows.AddRemote(index, network, remoteAddr)
+	}
+}
+
+// AddFlow registers the flow created by the trace with the given index.
+func (c *Capture) AddFlow(index int64, network, localAddr, remoteAddr string) {
+	if c != nil {
+		defer c.mu.Unlock()
+		c.mu.Lock()
+		c.flows.AddFlow(index, network, localAddr, remoteAddr)
+	}
+}
+
+// ConnAddrs is the interface implemented by [net.Conn] and QUIC
+// connections to expose the local and remote addresses.
+type ConnAddrs interface {
+	LocalAddr() net.Addr
+	RemoteAddr() net.Addr
+}
+
+// AddConnFlow is like [*Capture.AddFlow] but obtains the local and remote
+// addresses from the given conn, which we only access when capturing.
+func (c *Capture) AddConnFlow(index int64, network string, conn ConnAddrs) {
+	if c != nil {
+		c.AddFlow(index, network, addrString(conn.LocalAddr()), addrString(conn.RemoteAddr()))
+	}
+}
+
+// addrString returns the string representation of a possibly-nil address.
+func addrString(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	return addr.String()
+}
+
+// WritePacket writes the given raw IPv4 or IPv6 packet, captured at the given time, into
+// the pcapng file, if the packet belongs to a registered flow, and ignores it otherwise.
+func (c *Capture) WritePacket(t time.Time, packet []byte) {
+	if c == nil {
+		return
+	}
+	defer c.mu.Unlock()
+	c.mu.Lock()
+	index, found := c.flows.Lookup(packet)
+	if !found || c.closed || c.err != nil {
+		return
+	}
+	originalLen := len(packet)
+	if len(packet) > c.snapLen {
+		packet = packet[:c.snapLen]
+	}
+	comment := fmt.Sprintf("trace_index=%d", index)
+	if err := c.writer.WritePacket(t, packet, originalLen, comment); err != nil {
+		c.logger.Warnf("packetcapture: cannot write packet: %s", err.Error())
+		c.err = err
+	}
+}
+
+// Close stops capturing and closes the pcapng file. This method is idempotent.
+func (c *Capture) Close() (err error) {
+	if c == nil {
+		return nil
+	}
+	c.closeOnce.Do(func() {
+		if c.source != nil {
+			c.source.Close()
+		}
+		defer c.mu.Unlock()
+		c.mu.Lock()
+		c.closed = true
+		err = c.bufw.Flush()
+		if err2 := c.filep.Close(); err == nil {
+			err = err2
+		}
+		if err == nil {
+			err = c.err
+		}
+	})
+	return
+}
+
+// FileSHA256 returns the hex-encoded SHA256 of the given file.
+func FileSHA256(filename string) (string, error) {
+	filep, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer filep.Close()
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, filep); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
@@ -0,0 +1,144 @@
+package packetcapture
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/google/gopacket/pcapgo"
+	"github.com/ooni/probe-cli/v3/internal/mocks"
+)
+
+// readPackets reads the packets inside a pcapng file.
+func readPackets(t *testing.T, filename string) (packets [][]byte, lengths []int) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, err := pcapgo.NewNgReader(bytes.NewReader(data), pcapgo.DefaultNgReaderOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		packet, ci, err := reader.ReadPacketData()
+		if errors.Is(err, io.EOF) {
+			return
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		packets = append(packets, packet)
+		lengths = append(lengths, ci.Length)
+	}
+}
+
+func TestCapture(t *testing.T) {
+	t.Run("New fails when it cannot create the file", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "nonexistent", "capture.pcapng")
+		capture, err := New(&Config{Filename: filename, Logger: log.Log})
+		if err == nil || capture != nil {
+			t.Fatal("expected an error")
+		}
+	})
+
+	t.Run("we only write the packets belonging to registered flows", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "capture.pcapng")
+		capture, err := New(&Config{Filename: filename, Logger: log.Log, SnapLen: 30})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if capture.Filename() != filename {
+			t.Fatal("unexpected filename")
+		}
+		capture.AddConnFlow(11, "tcp", &mocks.Conn{
+			MockLocalAddr: func() net.Addr {
+				return &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 54321}
+			},
+			MockRemoteAddr: func() net.Addr {
+				return &net.TCPAddr{IP: net.IPv4(93, 184, 216, 34), Port: 443}
+			},
+		})
+		related := serializePacket(t, "tcp", "93.184.216.34", "10.0.0.1", 443, 54321)
+		unrelated := serializePacket(t, "tcp", "10.0.0.1", "10.0.0.2", 1234, 80)
+		capture.WritePacket(time.Now(), related)
+		capture.WritePacket(time.Now(), unrelated)
+		if err := capture.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := capture.Close(); err != nil { // idempotent
+			t.Fatal(err)
+		}
+
+		packets, lengths := readPackets(t, filename)
+		if len(packets) != 1 {
+			t.Fatal("expected a single packet")
+		}
+		if !bytes.Equal(packets[0], related[:30]) || lengths[0] != len(related) {
+			t.Fatal("unexpected packet")
+		}
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Contains(data, []byte("trace_index=11")) {
+			t.Fatal("missing trace index comment")
+		}
+	})
+
+	t.Run("the methods of a nil capture are no-ops", func(t *testing.T) {
+		var capture *Capture
+		capture.AddRemote(1, "tcp", "10.0.0.1:443")
+		capture.AddFlow(1, "tcp", "10.0.0.2:5555", "10.0.0.1:443")
+		capture.AddConnFlow(1, "udp", &mocks.QUICEarlyConnection{}) // must not access the conn
+		capture.WritePacket(time.Now(), []byte{})
+		if err := capture.Close(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("Start captures or fails gracefully", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "capture.pcapng")
+		capture, err := Start(&Config{Filename: filename, Logger: log.Log})
+		if err != nil {
+			// we are either not on Linux or we lack CAP_NET_RAW
+			if _, err := os.Stat(filename); !errors.Is(err, os.ErrNotExist) {
+				t.Fatal("expected the file to be removed")
+			}
+			return
+		}
+		if err := capture.Close(); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestFileSHA256(t *testing.T) {
+	t.Run("on success", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "file")
+		if err := os.WriteFile(filename, []byte("antani"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		digest, err := FileSHA256(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect := sha256.Sum256([]byte("antani"))
+		if digest != hex.EncodeToString(expect[:]) {
+			t.Fatal("unexpected digest")
+		}
+	})
+
+	t.Run("on failure", func(t *testing.T) {
+		if _, err := FileSHA256(filepath.Join(t.TempDir(), "nonexistent")); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
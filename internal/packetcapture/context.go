@@ -0,0 +1,20 @@
+package packetcapture
+
+//
+// Implicit packet capture based on context
+//
+
+import "context"
+
+type captureKey struct{}
+
+// ContextCapture retrieves the possibly-nil [*Capture] from the context.
+func ContextCapture(ctx context.Context) *Capture {
+	capture, _ := ctx.Value(captureKey{}).(*Capture)
+	return capture
+}
+
+// WithCapture assigns the [*Capture] to the context.
+func WithCapture(ctx context.Context, capture *Capture) context.Context {
+	return context.WithValue(ctx, captureKey{}, capture)
+}
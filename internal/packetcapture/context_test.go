@@ -0,0 +1,17 @@
+package packetcapture
+
+import (
+	"context"
+	"testing"
+)
+
+func TestContextCapture(t *testing.T) {
+	ctx := context.Background()
+	if ContextCapture(ctx) != nil {
+		t.Fatal("expected nil capture")
+	}
+	capture := &Capture{}
+	if ContextCapture(WithCapture(ctx, capture)) != capture {
+		t.Fatal("unexpected capture")
+	}
+}
@@ -0,0 +1,17 @@
+// Package packetcapture contains code to capture the packets exchanged
+// while performing a measurement and to write them into a pcapng file.
+//
+// Capturing is opt-in. To capture, you create a [*Capture] and assign it to
+// the context using [WithCapture]. The [measurexlite.Trace] dialers register
+// the flows they create using [ContextCapture] and we only write packets
+// belonging to such flows, so the capture does not contain unrelated traffic.
+// Each packet includes a comment containing the index of the trace that
+// created the flow, which allows to link packets to the archival data.
+//
+// A [*Capture] receives packets from a source. Use [Start] to capture
+// using an AF_PACKET socket (Linux only, requires CAP_NET_RAW) and use
+// [New] along with [netemx.QAEnvOptionPacketCapture] to capture using netem.
+//
+// [netemx.QAEnvOptionPacketCapture]: https://pkg.go.dev/github.com/ooni/probe-cli/v3/internal/netemx#QAEnvOptionPacketCapture
+// [measurexlite.Trace]: https://pkg.go.dev/github.com/ooni/probe-cli/v3/internal/measurexlite#Trace
+package packetcapture
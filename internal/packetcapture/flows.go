@@ -0,0 +1,184 @@
+package packetcapture
+
+//
+// Mapping packets to traces
+//
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+)
+
+// flowTable maps flows to the index of the trace that created them.
+//
+// We register a remote endpoint before connecting, such that we also map
+// the packets of connections that do not complete (e.g., a SYN without
+// reply), and we register the full flow after connecting. The zero value
+// is invalid; please, use newFlowTable to construct.
+type flowTable struct {
+	// flows maps a network, local, and remote endpoint to a trace index.
+	flows map[string]int64
+
+	// remotes maps a network and remote endpoint to a trace index.
+	remotes map[string]int64
+}
+
+// newFlowTable creates a new flowTable.
+func newFlowTable() *flowTable {
+	return &flowTable{
+		flows:   map[string]int64{},
+		remotes: map[string]int64{},
+	}
+}
+
+// AddRemote registers the remote endpoint used by the given trace.
+func (ft *flowTable) AddRemote(index int64, network, remoteAddr string) {
+	if key, good := flowRemoteKey(network, remoteAddr); good {
+		ft.remotes[key] = index
+	}
+}
+
+// AddFlow registers the flow created by the given trace.
+func (ft *flowTable) AddFlow(index int64, network, localAddr, remoteAddr string) {
+	if key, good := flowKey(network, localAddr, remoteAddr); good {
+		ft.flows[key] = index
+	}
+	ft.AddRemote(index, network, remoteAddr)
+}
+
+// Lookup returns the index of the trace that created the flow of the given raw IPv4
+// or IPv6 packet. The boolean is false if the packet does not belong to any flow.
+func (ft *flowTable) Lookup(packet []byte) (int64, bool) {
+	network, src, dst, good := parsePacket(packet)
+	if !good {
+		return 0, false
+	}
+	for _, key := range []string{
+		flowKeyString(network, src, dst),
+		flowKeyString(network, dst, src),
+	} {
+		if index, found := ft.flows[key]; found {
+			return index, true
+		}
+	}
+	for _, key := range []string{
+		flowRemoteKeyString(network, dst),
+		flowRemoteKeyString(network, src),
+	} {
+		if index, found := ft.remotes[key]; found {
+			return index, true
+		}
+	}
+	return 0, false
+}
+
+// flowNetwork maps the network to "tcp" or "udp".
+func flowNetwork(network string) (string, bool) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+		return "tcp", true
+	case "udp", "udp4", "udp6":
+		return "udp", true
+	default:
+		return "", false
+	}
+}
+
+// flowKey returns the key for the given flow.
+func flowKey(network, localAddr, remoteAddr string) (string, bool) {
+	network, good := flowNetwork(network)
+	if !good {
+		return "", false
+	}
+	local, err := netip.ParseAddrPort(localAddr)
+	if err != nil {
+		return "", false
+	}
+	remote, err := netip.ParseAddrPort(remoteAddr)
+	if err != nil {
+		return "", false
+	}
+	return flowKeyString(network, flowNormalize(local), flowNormalize(remote)), true
+}
+
+// flowRemoteKey returns the key for the given remote endpoint.
+func flowRemoteKey(network, remoteAddr string) (string, bool) {
+	network, good := flowNetwork(network)
+	if !good {
+		return "", false
+	}
+	remote, err := netip.ParseAddrPort(remoteAddr)
+	if err != nil {
+		return "", false
+	}
+	return flowRemoteKeyString(network, flowNormalize(remote)), true
+}
+
+// flowNormalize unmaps IPv4-mapped IPv6 addresses and drops the zone.
+func flowNormalize(epnt netip.AddrPort) netip.AddrPort {
+	return netip.AddrPortFrom(epnt.Addr().Unmap().WithZone(""), epnt.Port())
+}
+
+func flowKeyString(network string, local, remote netip.AddrPort) string {
+	return fmt.Sprintf("%s %s %s", network, local, remote)
+}
+
+func flowRemoteKeyString(network string, remote netip.AddrPort) string {
+	return fmt.Sprintf("%s %s", network, remote)
+}
+
+// parsePacket parses a raw IPv4 or IPv6 packet carrying TCP or UDP and returns
+// the network along with the source and destination endpoints.
+func parsePacket(packet []byte) (network string, src, dst netip.AddrPort, good bool) {
+	if len(packet) < 1 {
+		return
+	}
+	var (
+		proto     byte
+		srcAddr   netip.Addr
+		dstAddr   netip.Addr
+		transport []byte
+	)
+	switch packet[0] >> 4 {
+	case 4:
+		if len(packet) < 20 {
+			return
+		}
+		ihl := int(packet[0]&0x0f) * 4
+		fragmentOffset := binary.BigEndian.Uint16(packet[6:8]) & 0x1fff
+		if ihl < 20 || len(packet) < ihl || fragmentOffset != 0 {
+			return
+		}
+		proto = packet[9]
+		srcAddr = netip.AddrFrom4([4]byte(packet[12:16]))
+		dstAddr = netip.AddrFrom4([4]byte(packet[16:20]))
+		transport = packet[ihl:]
+	case 6:
+		if len(packet) < 40 {
+			return
+		}
+		// Note: we do not follow IPv6 extension headers
+		proto = packet[6]
+		srcAddr = netip.AddrFrom16([16]byte(packet[8:24]))
+		dstAddr = netip.AddrFrom16([16]byte(packet[24:40]))
+		transport = packet[40:]
+	default:
+		return
+	}
+	switch proto {
+	case 6:
+		network = "tcp"
+	case 17:
+		network = "udp"
+	default:
+		return
+	}
+	if len(transport) < 4 {
+		return
+	}
+	src = netip.AddrPortFrom(srcAddr.Unmap(), binary.BigEndian.Uint16(transport[0:2]))
+	dst = netip.AddrPortFrom(dstAddr.Unmap(), binary.BigEndian.Uint16(transport[2:4]))
+	good = true
+	return
+}
@@ -0,0 +1,131 @@
+package packetcapture
+
+import (
+	"net"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// serializePacket serializes an IPv4 or IPv6 packet carrying TCP or UDP.
+func serializePacket(t *testing.T, network, src, dst string, srcPort, dstPort uint16) []byte {
+	var (
+		ipLayer        gopacket.SerializableLayer
+		networkLayer   gopacket.NetworkLayer
+		transportLayer gopacket.SerializableLayer
+		proto          layers.IPProtocol
+	)
+	switch network {
+	case "tcp":
+		proto = layers.IPProtocolTCP
+	case "udp":
+		proto = layers.IPProtocolUDP
+	}
+	srcIP, dstIP := net.ParseIP(src), net.ParseIP(dst)
+	if srcIP.To4() != nil {
+		ip := &layers.IPv4{Version: 4, TTL: 64, Protocol: proto, SrcIP: srcIP.To4(), DstIP: dstIP.To4()}
+		ipLayer, networkLayer = ip, ip
+	} else {
+		ip := &layers.IPv6{Version: 6, HopLimit: 64, NextHeader: proto, SrcIP: srcIP, DstIP: dstIP}
+		ipLayer, networkLayer = ip, ip
+	}
+	switch network {
+	case "tcp":
+		tcp := &layers.TCP{SrcPort: layers.TCPPort(srcPort), DstPort: layers.TCPPort(dstPort), SYN: true}
+		tcp.SetNetworkLayerForChecksum(networkLayer)
+		transportLayer = tcp
+	case "udp":
+		udp := &layers.UDP{SrcPort: layers.UDPPort(srcPort), DstPort: layers.UDPPort(dstPort)}
+		udp.SetNetworkLayerForChecksum(networkLayer)
+		transportLayer = udp
+	}
+	buffer := gopacket.NewSerializeBuffer()
+	options := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buffer, options, ipLayer, transportLayer); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func TestFlowTable(t *testing.T) {
+	ft := newFlowTable()
+	ft.AddFlow(1, "tcp4", "10.0.0.1:54321", "93.184.216.34:443")
+	ft.AddRemote(2, "udp", "8.8.8.8:53")
+	ft.AddFlow(3, "udp6", "[2001:db8::1]:12345", "[2001:db8::2]:443")
+	ft.AddFlow(4, "tcp", "[::ffff:10.0.0.1]:40000", "[::ffff:1.1.1.1]:853")
+	ft.AddRemote(5, "unix", "/tmp/socket")     // ignored
+	ft.AddFlow(6, "tcp", "invalid", "invalid") // ditto
+
+	type testcase struct {
+		name   string
+		packet []byte
+		index  int64
+		found  bool
+	}
+
+	cases := []testcase{{
+		name:   "outgoing TCP packet of a flow",
+		packet: serializePacket(t, "tcp", "10.0.0.1", "93.184.216.34", 54321, 443),
+		index:  1,
+		found:  true,
+	}, {
+		name:   "incoming TCP packet of a flow",
+		packet: serializePacket(t, "tcp", "93.184.216.34", "10.0.0.1", 443, 54321),
+		index:  1,
+		found:  true,
+	}, {
+		name:   "outgoing UDP packet to a remote endpoint",
+		packet: serializePacket(t, "udp", "10.0.0.1", "8.8.8.8", 33333, 53),
+		index:  2,
+		found:  true,
+	}, {
+		name:   "incoming UDP packet from a remote endpoint",
+		packet: serializePacket(t, "udp", "8.8.8.8", "10.0.0.1", 53, 33333),
+		index:  2,
+		found:  true,
+	}, {
+		name:   "IPv6 UDP packet of a flow",
+		packet: serializePacket(t, "udp", "2001:db8::2", "2001:db8::1", 443, 12345),
+		index:  3,
+		found:  true,
+	}, {
+		name:   "IPv4-mapped addresses are normalized",
+		packet: serializePacket(t, "tcp", "10.0.0.1", "1.1.1.1", 40000, 853),
+		index:  4,
+		found:  true,
+	}, {
+		name:   "the network must match",
+		packet: serializePacket(t, "udp", "10.0.0.1", "93.184.216.34", 54321, 443),
+		found:  false,
+	}, {
+		name:   "unrelated packet",
+		packet: serializePacket(t, "tcp", "10.0.0.1", "10.0.0.2", 1234, 80),
+		found:  false,
+	}, {
+		name:   "empty packet",
+		packet: []byte{},
+		found:  false,
+	}, {
+		name:   "truncated IPv4 packet",
+		packet: serializePacket(t, "tcp", "10.0.0.1", "93.184.216.34", 54321, 443)[:21],
+		found:  false,
+	}, {
+		name:   "truncated IPv6 packet",
+		packet: serializePacket(t, "udp", "2001:db8::2", "2001:db8::1", 443, 12345)[:39],
+		found:  false,
+	}, {
+		name:   "not an IP packet",
+		packet: []byte{0x10, 0x00},
+		found:  false,
+	}}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			index, found := ft.Lookup(tc.packet)
+			if found != tc.found || index != tc.index {
+				t.Fatal("expected", tc.index, tc.found, "got", index, found)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package packetcapture
+
+//
+// Capturing packets using netem
+//
+
+import (
+	"time"
+
+	"github.com/ooni/netem"
+)
+
+var _ netem.LinkNICWrapper = &Capture{}
+
+// WrapNIC implements [netem.LinkNICWrapper]. Register the [*Capture] as the
+// NIC wrapper of the link connecting the client to capture both the packets
+// sent and the packets received by the client.
+func (c *Capture) WrapNIC(nic netem.NIC) netem.NIC {
+	return &captureNIC{c: c, nic: nic}
+}
+
+// captureNIC is a [netem.NIC] delivering packets to a [*Capture].
+type captureNIC struct {
+	c   *Capture
+	nic netem.NIC
+}
+
+var _ netem.NIC = &captureNIC{}
+
+// Close implements netem.NIC
+func (n *captureNIC) Close() error {
+	return n.nic.Close()
+}
+
+// FrameAvailable implements netem.NIC
+func (n *captureNIC) FrameAvailable() <-chan any {
+	return n.nic.FrameAvailable()
+}
+
+// IPAddress implements netem.NIC
+func (n *captureNIC) IPAddress() string {
+	return n.nic.IPAddress()
+}
+
+// InterfaceName implements netem.NIC
+func (n *captureNIC) InterfaceName() string {
+	return n.nic.InterfaceName()
+}
+
+// ReadFrameNonblocking implements netem.NIC
+func (n *captureNIC) ReadFrameNonblocking() (*netem.Frame, error) {
+	frame, err := n.nic.ReadFrameNonblocking()
+	if err != nil {
+		return nil, err
+	}
+	n.c.WritePacket(time.Now(), frame.Payload)
+	return frame, nil
+}
+
+// StackClosed implements netem.NIC
+func (n *captureNIC) StackClosed() <-chan any {
+	return n.nic.StackClosed()
+}
+
+// WriteFrame implements netem.NIC
+func (n *captureNIC) WriteFrame(frame *netem.Frame) error {
+	n.c.WritePacket(time.Now(), frame.Payload)
+	return n.nic.WriteFrame(frame)
+}
@@ -0,0 +1,68 @@
+package packetcapture_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/ooni/probe-cli/v3/internal/measurexlite"
+	"github.com/ooni/probe-cli/v3/internal/netemx"
+	"github.com/ooni/probe-cli/v3/internal/netxlite"
+	"github.com/ooni/probe-cli/v3/internal/packetcapture"
+)
+
+func TestCaptureWithNetem(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "capture.pcapng")
+	capture, err := packetcapture.New(&packetcapture.Config{Filename: filename, Logger: log.Log})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env := netemx.MustNewQAEnv(
+		netemx.QAEnvOptionPacketCapture(capture),
+		netemx.QAEnvOptionNetStack(
+			netemx.AddressWwwExampleCom,
+			&netemx.HTTPCleartextServerFactory{
+				Factory: netemx.ExampleWebPageHandlerFactory(),
+				Ports:   []int{80},
+			},
+		),
+	)
+	defer env.Close()
+
+	// connect using a trace and a context containing the capture
+	trace := measurexlite.NewTrace(7, time.Now())
+	trace.Netx = &netxlite.Netx{Underlying: &netxlite.NetemUnderlyingNetworkAdapter{UNet: env.ClientStack}}
+	dialer := trace.NewDialerWithoutResolver(log.Log)
+	ctx := packetcapture.WithCapture(context.Background(), capture)
+	conn, err := dialer.DialContext(ctx, "tcp", netemx.AddressWwwExampleCom+":80")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Write([]byte("GET / HTTP/1.0\r\nHost: www.example.com\r\n\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	buffer := make([]byte, 1024)
+	if _, err := conn.Read(buffer); err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	if err := capture.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte("GET / HTTP/1.0")) {
+		t.Fatal("missing request packet")
+	}
+	if !bytes.Contains(data, []byte("trace_index=7")) {
+		t.Fatal("missing trace index comment")
+	}
+}
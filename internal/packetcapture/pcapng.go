@@ -0,0 +1,127 @@
+package packetcapture
+
+//
+// Minimal pcapng writer
+//
+// See https://datatracker.ietf.org/doc/draft-ietf-opsawg-pcapng/.
+//
+
+import (
+	"encoding/binary"
+	"io"
+	"time"
+)
+
+const (
+	// pcapngBlockSectionHeader is the section header block type.
+	pcapngBlockSectionHeader = 0x0A0D0D0A
+
+	// pcapngBlockInterfaceDescription is the interface description block type.
+	pcapngBlockInterfaceDescription = 0x00000001
+
+	// pcapngBlockEnhancedPacket is the enhanced packet block type.
+	pcapngBlockEnhancedPacket = 0x00000006
+
+	// pcapngByteOrderMagic is the byte-order magic.
+	pcapngByteOrderMagic = 0x1A2B3C4D
+
+	// pcapngOptionEndOfOpt terminates the list of options.
+	pcapngOptionEndOfOpt = 0
+
+	// pcapngOptionComment is the comment option.
+	pcapngOptionComment = 1
+
+	// pcapngOptionSHBUserAppl is the section header option
+	// containing the name of the application.
+	pcapngOptionSHBUserAppl = 4
+
+	// pcapngLinkTypeRaw is the link type for raw IPv4 and IPv6 packets.
+	pcapngLinkTypeRaw = 101
+)
+
+// pcapngOption is a pcapng option.
+type pcapngOption struct {
+	code  uint16
+	value string
+}
+
+// pcapngWriter writes a pcapng file containing a single section with
+// a single interface carrying raw IPv4 and IPv6 packets.
+type pcapngWriter struct {
+	w io.Writer
+}
+
+// newPCAPNGWriter creates a new pcapngWriter and writes the section
+// header block and the interface description block.
+func newPCAPNGWriter(w io.Writer, snapLen uint32) (*pcapngWriter, error) {
+	pw := &pcapngWriter{w: w}
+
+	// section header block
+	shb := binary.LittleEndian.AppendUint32(nil, pcapngByteOrderMagic)
+	shb = binary.LittleEndian.AppendUint16(shb, 1) // major version
+	shb = binary.LittleEndian.AppendUint16(shb, 0) // minor version
+	shb = binary.LittleEndian.AppendUint64(shb, 0xFFFFFFFFFFFFFFFF)
+	shb = pcapngAppendOptions(shb, pcapngOption{code: pcapngOptionSHBUserAppl, value: "ooniprobe"})
+	if err := pw.writeBlock(pcapngBlockSectionHeader, shb); err != nil {
+		return nil, err
+	}
+
+	// interface description block
+	idb := binary.LittleEndian.AppendUint16(nil, pcapngLinkTypeRaw)
+	idb = binary.LittleEndian.AppendUint16(idb, 0) // reserved
+	idb = binary.LittleEndian.AppendUint32(idb, snapLen)
+	if err := pw.writeBlock(pcapngBlockInterfaceDescription, idb); err != nil {
+		return nil, err
+	}
+
+	return pw, nil
+}
+
+// WritePacket writes an enhanced packet block containing the first snapLen bytes
+// of the given packet and the given comment, which is omitted if empty.
+func (pw *pcapngWriter) WritePacket(t time.Time, packet []byte, originalLen int, comment string) error {
+	usec := uint64(t.UnixMicro())
+	epb := binary.LittleEndian.AppendUint32(nil, 0) // interface ID
+	epb = binary.LittleEndian.AppendUint32(epb, uint32(usec>>32))
+	epb = binary.LittleEndian.AppendUint32(epb, uint32(usec))
+	epb = binary.LittleEndian.AppendUint32(epb, uint32(len(packet)))
+	epb = binary.LittleEndian.AppendUint32(epb, uint32(originalLen))
+	epb = append(epb, packet...)
+	epb = pcapngAppendPadding(epb)
+	if comment != "" {
+		epb = pcapngAppendOptions(epb, pcapngOption{code: pcapngOptionComment, value: comment})
+	}
+	return pw.writeBlock(pcapngBlockEnhancedPacket, epb)
+}
+
+// writeBlock writes a block with the given type and body, which MUST
+// already be padded to a multiple of four bytes.
+func (pw *pcapngWriter) writeBlock(blockType uint32, body []byte) error {
+	totalLen := uint32(len(body) + 12)
+	block := binary.LittleEndian.AppendUint32(nil, blockType)
+	block = binary.LittleEndian.AppendUint32(block, totalLen)
+	block = append(block, body...)
+	block = binary.LittleEndian.AppendUint32(block, totalLen)
+	_, err := pw.w.Write(block)
+	return err
+}
+
+// pcapngAppendOptions appends the options and the end-of-options marker.
+func pcapngAppendOptions(data []byte, options ...pcapngOption) []byte {
+	for _, option := range options {
+		data = binary.LittleEndian.AppendUint16(data, option.code)
+		data = binary.LittleEndian.AppendUint16(data, uint16(len(option.value)))
+		data = append(data, option.value...)
+		data = pcapngAppendPadding(data)
+	}
+	data = binary.LittleEndian.AppendUint16(data, pcapngOptionEndOfOpt)
+	return binary.LittleEndian.AppendUint16(data, 0)
+}
+
+// pcapngAppendPadding pads data to a multiple of four bytes.
+func pcapngAppendPadding(data []byte) []byte {
+	for len(data)%4 != 0 {
+		data = append(data, 0)
+	}
+	return data
+}
@@ -0,0 +1,85 @@
+package packetcapture
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+)
+
+// failingWriter is an io.Writer that always fails.
+type failingWriter struct {
+	err error
+}
+
+func (w *failingWriter) Write(data []byte) (int, error) {
+	return 0, w.err
+}
+
+func TestPCAPNGWriter(t *testing.T) {
+	t.Run("we can read back what we write", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+		w, err := newPCAPNGWriter(buffer, 1500)
+		if err != nil {
+			t.Fatal(err)
+		}
+		now := time.Unix(1700000000, 123456000)
+		packets := [][]byte{
+			[]byte("abc"),        // needs padding
+			[]byte("abcdefghij"), // ditto
+			[]byte("abcd"),       // no padding
+		}
+		for _, packet := range packets {
+			if err := w.WritePacket(now, packet, len(packet)+10, "trace_index=7"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.WritePacket(now, []byte("xyz"), 3, ""); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Contains(buffer.Bytes(), []byte("trace_index=7")) {
+			t.Fatal("missing comment")
+		}
+		if !bytes.Contains(buffer.Bytes(), []byte("ooniprobe")) {
+			t.Fatal("missing application name")
+		}
+
+		reader, err := pcapgo.NewNgReader(bytes.NewReader(buffer.Bytes()), pcapgo.DefaultNgReaderOptions)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reader.LinkType() != layers.LinkTypeRaw {
+			t.Fatal("unexpected link type", reader.LinkType())
+		}
+		var got [][]byte
+		for {
+			data, ci, err := reader.ReadPacketData()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !ci.Timestamp.Equal(now) {
+				t.Fatal("unexpected timestamp", ci.Timestamp)
+			}
+			got = append(got, data)
+		}
+		expect := append(packets, []byte("xyz"))
+		if diff := cmp.Diff(expect, got); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("we handle write errors", func(t *testing.T) {
+		expected := errors.New("mocked error")
+		if _, err := newPCAPNGWriter(&failingWriter{expected}, 1500); !errors.Is(err, expected) {
+			t.Fatal("unexpected error", err)
+		}
+	})
+}
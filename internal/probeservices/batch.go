@@ -0,0 +1,167 @@
+package probeservices
+
+//
+// batch.go - batched and compressed measurement submission
+//
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/ooni/probe-cli/v3/internal/httpclientx"
+	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/urlx"
+)
+
+// SubmitMeasurements submits several measurements belonging to the report. When the
+// collector supports batch updates, we submit up to the advertised number of measurements
+// per request, otherwise we fall back to submitting one measurement per request. On
+// success, all the measurements contain the report ID. Otherwise, the measurements
+// that we could not submit have an empty report ID.
+func (r *reportChan) SubmitMeasurements(ctx context.Context, ms []*model.Measurement) error {
+	for len(ms) > 0 {
+		size := r.batchSize()
+		if size <= 1 {
+			return submitEach(ctx, r, ms)
+		}
+		batch := ms[:min(size, len(ms))]
+		err := r.submitBatch(ctx, batch)
+		if requestFailedWithStatus(err, http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented) {
+			r.client.Logger.Warnf("probeservices: batch updates not supported, falling back: %s", err.Error())
+			r.disableBatching()
+			continue
+		}
+		if err != nil {
+			clearReportIDs(ms)
+			return err
+		}
+		ms = ms[len(batch):]
+	}
+	return nil
+}
+
+// submitBatch submits the given measurements using a single batch update request.
+func (r *reportChan) submitBatch(ctx context.Context, ms []*model.Measurement) error {
+	apiReq := model.OOAPICollectorBatchUpdateRequest{
+		Format: "json",
+	}
+	for _, m := range ms {
+		m.ReportID = r.ID
+		if err := r.maybeSign(m); err != nil {
+			clearReportIDs(ms)
+			return err
+		}
+		apiReq.Content = append(apiReq.Content, m)
+	}
+
+	updateResponse, err := reportChanPostJSON[
+		model.OOAPICollectorBatchUpdateRequest, *model.OOAPICollectorBatchUpdateResponse](
+		ctx, r, fmt.Sprintf("/report/%s/batch", r.ID), apiReq)
+
+	if err != nil {
+		clearReportIDs(ms)
+		return err
+	}
+
+	for _, measurementUID := range updateResponse.MeasurementUIDs {
+		r.client.Logger.Infof("Measurement URL: https://explorer.ooni.org/m/%s", measurementUID)
+	}
+	return nil
+}
+
+// batchSize returns the number of measurements to submit per request.
+func (r *reportChan) batchSize() int {
+	defer r.mu.Unlock()
+	r.mu.Lock()
+	return r.maxBatchSize
+}
+
+// disableBatching disables batch updates for this report.
+func (r *reportChan) disableBatching() {
+	defer r.mu.Unlock()
+	r.mu.Lock()
+	r.maxBatchSize = 0
+}
+
+// contentEncoding returns the content encoding to use for request bodies.
+func (r *reportChan) contentEncoding() string {
+	defer r.mu.Unlock()
+	r.mu.Lock()
+	return r.encoding
+}
+
+// disableContentEncoding disables compressing request bodies for this report.
+func (r *reportChan) disableContentEncoding() {
+	defer r.mu.Unlock()
+	r.mu.Lock()
+	r.encoding = ""
+}
+
+// reportChanPostJSON POSTs the given input to the given URL path, compressing the request
+// body using the report's content encoding, if any. When the collector rejects the content
+// encoding, we disable compression and retry sending an uncompressed request body.
+func reportChanPostJSON[Input, Output any](
+	ctx context.Context, r *reportChan, urlpath string, input Input) (Output, error) {
+	URL, err := urlx.ResolveReference(r.client.BaseURL, urlpath, "")
+	if err != nil {
+		return *new(Output), err
+	}
+	for {
+		encoding := r.contentEncoding()
+		output, err := httpclientx.PostJSON[Input, Output](
+			ctx,
+			httpclientx.NewEndpoint(URL).WithHostOverride(r.client.Host),
+			input,
+			&httpclientx.Config{
+				Client:          r.client.HTTPClient,
+				ContentEncoding: encoding,
+				Logger:          r.client.Logger,
+				UserAgent:       r.client.UserAgent,
+			},
+		)
+		if encoding != "" && requestFailedWithStatus(err, http.StatusUnsupportedMediaType) {
+			r.client.Logger.Warnf("probeservices: %s encoding not supported, falling back", encoding)
+			r.disableContentEncoding()
+			continue
+		}
+		return output, err
+	}
+}
+
+// requestFailedWithStatus returns whether the error is an HTTP request
+// failure with any of the given status codes.
+func requestFailedWithStatus(err error, statusCodes ...int) bool {
+	var failure *httpclientx.ErrRequestFailed
+	return errors.As(err, &failure) && slices.Contains(statusCodes, failure.StatusCode)
+}
+
+// submitToChannel submits the given measurements using the given channel, using a
+// single batched upload when the channel implements [BatchReportChannel].
+func submitToChannel(ctx context.Context, channel ReportChannel, ms []*model.Measurement) error {
+	if batcher, ok := channel.(BatchReportChannel); ok {
+		return batcher.SubmitMeasurements(ctx, ms)
+	}
+	return submitEach(ctx, channel, ms)
+}
+
+// submitEach submits the given measurements one at a time.
+func submitEach(ctx context.Context, channel ReportChannel, ms []*model.Measurement) error {
+	for idx, m := range ms {
+		if err := channel.SubmitMeasurement(ctx, m); err != nil {
+			clearReportIDs(ms[idx:])
+			return err
+		}
+	}
+	return nil
+}
+
+// clearReportIDs clears the report ID of the given measurements to
+// indicate that we have not submitted them.
+func clearReportIDs(ms []*model.Measurement) {
+	for _, m := range ms {
+		m.ReportID = ""
+	}
+}
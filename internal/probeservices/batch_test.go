@@ -0,0 +1,359 @@
+package probeservices
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	stdlog "log"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ooni/probe-cli/v3/internal/httpclientx"
+	"github.com/ooni/probe-cli/v3/internal/legacy/mockable"
+	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/runtimex"
+	"github.com/ooni/probe-cli/v3/internal/testingx"
+)
+
+// collectorStats records the requests received by a testing collector.
+type collectorStats struct {
+	batches   int
+	bytes     int64
+	encodings []string
+	mu        sync.Mutex
+	requests  int
+}
+
+// wrap returns a handler recording the requests before invoking the given handler.
+func (cs *collectorStats) wrap(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cs.mu.Lock()
+		cs.requests++
+		if strings.HasSuffix(r.URL.Path, "/batch") {
+			cs.batches++
+		}
+		if r.ContentLength > 0 {
+			cs.bytes += r.ContentLength
+		}
+		cs.encodings = append(cs.encodings, r.Header.Get("Content-Encoding"))
+		cs.mu.Unlock()
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// newclientWithHandler creates a client speaking with a local server using the given handler.
+func newclientWithHandler(tb testing.TB, handler http.Handler) *Client {
+	srv := testingx.MustNewHTTPServer(handler)
+	tb.Cleanup(func() { srv.Close() })
+	return runtimex.Try1(NewClient(
+		&mockable.Session{
+			MockableHTTPClient: http.DefaultClient,
+			MockableLogger:     model.DiscardLogger,
+		},
+		model.OOAPIService{
+			Address: srv.URL,
+			Type:    "https",
+		},
+	))
+}
+
+// makeMeasurements creates count measurements using the given template.
+func makeMeasurements(rt model.OOAPIReportTemplate, count int) (ms []*model.Measurement) {
+	for idx := 0; idx < count; idx++ {
+		m := makeMeasurement(rt, "")
+		m.Input = model.MeasurementInput(fmt.Sprintf("https://www.example.com/%d", idx))
+		ms = append(ms, &m)
+	}
+	return
+}
+
+// openReportForTesting opens a report using the given client and casts it to *reportChan.
+func openReportForTesting(t *testing.T, client *Client) *reportChan {
+	report, err := client.OpenReport(context.Background(), newReportTemplateForTesting())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return report.(*reportChan)
+}
+
+func TestSelectContentEncoding(t *testing.T) {
+	tests := []struct {
+		supported []string
+		expect    string
+	}{
+		{nil, ""},
+		{[]string{"br"}, ""},
+		{[]string{"gzip"}, "gzip"},
+		{[]string{"gzip", "zstd"}, "zstd"},
+	}
+	for _, tt := range tests {
+		if got := selectContentEncoding(tt.supported); got != tt.expect {
+			t.Fatal("for", tt.supported, "expected", tt.expect, "got", got)
+		}
+	}
+}
+
+func TestReportChanSubmitMeasurements(t *testing.T) {
+	t.Run("when the collector supports batching and compression", func(t *testing.T) {
+		stats := &collectorStats{}
+		collector := &testingx.OONICollector{
+			MaxBatchSize:       3,
+			SupportedEncodings: []string{"gzip", "zstd"},
+		}
+		report := openReportForTesting(t, newclientWithHandler(t, stats.wrap(collector)))
+		if report.encoding != "zstd" || report.maxBatchSize != 3 {
+			t.Fatal("unexpected report settings", report.encoding, report.maxBatchSize)
+		}
+
+		ms := makeMeasurements(report.tmpl, 7)
+		if err := report.SubmitMeasurements(context.Background(), ms); err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range ms {
+			if m.ReportID != report.ReportID() {
+				t.Fatal("unexpected report ID", m.ReportID)
+			}
+		}
+
+		defer stats.mu.Unlock()
+		stats.mu.Lock()
+		if stats.batches != 3 {
+			t.Fatal("unexpected number of batches", stats.batches)
+		}
+		// note: the first request opens the report and is not compressed
+		for _, encoding := range stats.encodings[1:] {
+			if encoding != "zstd" {
+				t.Fatal("unexpected encoding", encoding)
+			}
+		}
+	})
+
+	t.Run("when the collector does not support batching", func(t *testing.T) {
+		stats := &collectorStats{}
+		collector := &testingx.OONICollector{
+			// pretend we support batching but then fail the batch requests with 404
+			EditOpenReportResponse: func(resp *model.OOAPICollectorOpenResponse) {
+				resp.MaxBatchSize = 10
+			},
+		}
+		report := openReportForTesting(t, newclientWithHandler(t, stats.wrap(collector)))
+
+		ms := makeMeasurements(report.tmpl, 4)
+		if err := report.SubmitMeasurements(context.Background(), ms); err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range ms {
+			if m.ReportID != report.ReportID() {
+				t.Fatal("unexpected report ID", m.ReportID)
+			}
+		}
+		if report.maxBatchSize != 0 {
+			t.Fatal("expected batching to be disabled")
+		}
+
+		defer stats.mu.Unlock()
+		stats.mu.Lock()
+		// one open request, one failed batch, and four single updates
+		if stats.batches != 1 || stats.requests != 6 {
+			t.Fatal("unexpected number of requests", stats.batches, stats.requests)
+		}
+	})
+
+	t.Run("when the collector does not support the content encoding", func(t *testing.T) {
+		stats := &collectorStats{}
+		collector := &testingx.OONICollector{
+			// pretend we support zstd but then fail the requests using it with 415
+			EditOpenReportResponse: func(resp *model.OOAPICollectorOpenResponse) {
+				resp.SupportedEncodings = []string{"zstd"}
+			},
+			MaxBatchSize: 10,
+		}
+		report := openReportForTesting(t, newclientWithHandler(t, stats.wrap(collector)))
+
+		ms := makeMeasurements(report.tmpl, 4)
+		if err := report.SubmitMeasurements(context.Background(), ms); err != nil {
+			t.Fatal(err)
+		}
+		if report.encoding != "" {
+			t.Fatal("expected compression to be disabled")
+		}
+
+		defer stats.mu.Unlock()
+		stats.mu.Lock()
+		expect := []string{"", "zstd", ""}
+		if strings.Join(stats.encodings, ",") != strings.Join(expect, ",") {
+			t.Fatal("unexpected encodings", stats.encodings)
+		}
+	})
+
+	t.Run("when the batch update fails", func(t *testing.T) {
+		collector := &testingx.OONICollector{MaxBatchSize: 10}
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasSuffix(r.URL.Path, "/batch") {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			collector.ServeHTTP(w, r)
+		})
+		report := openReportForTesting(t, newclientWithHandler(t, handler))
+
+		ms := makeMeasurements(report.tmpl, 4)
+		err := report.SubmitMeasurements(context.Background(), ms)
+		var failure *httpclientx.ErrRequestFailed
+		if !errors.As(err, &failure) || failure.StatusCode != http.StatusInternalServerError {
+			t.Fatal("unexpected error", err)
+		}
+		for _, m := range ms {
+			if m.ReportID != "" {
+				t.Fatal("expected empty report ID")
+			}
+		}
+	})
+
+	t.Run("when a single update fails", func(t *testing.T) {
+		collector := &testingx.OONICollector{}
+		report := openReportForTesting(t, newclientWithHandler(t, collector))
+
+		ms := makeMeasurements(report.tmpl, 3)
+		ms[1].TestName = "antani" // does not match the template
+		if err := report.SubmitMeasurements(context.Background(), ms); err == nil {
+			t.Fatal("expected an error")
+		}
+		if ms[0].ReportID != report.ReportID() || ms[1].ReportID != "" || ms[2].ReportID != "" {
+			t.Fatal("unexpected report IDs")
+		}
+	})
+}
+
+func TestSubmitterSubmitMany(t *testing.T) {
+	t.Run("with channels not supporting batching", func(t *testing.T) {
+		rro := &RecordingReportOpener{}
+		submitter := NewSubmitter(rro, model.DiscardLogger)
+		ms := []*model.Measurement{
+			makeMeasurementWithoutTemplate("example"),
+			makeMeasurementWithoutTemplate("example"),
+			makeMeasurementWithoutTemplate("example_extended"),
+		}
+		if err := submitter.SubmitMany(context.Background(), ms); err != nil {
+			t.Fatal(err)
+		}
+		if len(rro.channels) != 2 {
+			t.Fatal("unexpected number of channels")
+		}
+		if len(rro.channels[0].m) != 2 || len(rro.channels[1].m) != 1 {
+			t.Fatal("unexpected number of measurements per channel")
+		}
+	})
+
+	t.Run("with channels supporting batching", func(t *testing.T) {
+		stats := &collectorStats{}
+		collector := &testingx.OONICollector{MaxBatchSize: 100}
+		client := newclientWithHandler(t, stats.wrap(collector))
+		submitter := NewSubmitter(client, model.DiscardLogger)
+		rt := newReportTemplateForTesting()
+		ms := makeMeasurements(rt, 5)
+		rt.TestName = "dummy_extended"
+		ms = append(ms, makeMeasurements(rt, 5)...)
+		if err := submitter.SubmitMany(context.Background(), ms); err != nil {
+			t.Fatal(err)
+		}
+		if ms[0].ReportID == "" || ms[9].ReportID == "" || ms[0].ReportID == ms[9].ReportID {
+			t.Fatal("unexpected report IDs")
+		}
+
+		defer stats.mu.Unlock()
+		stats.mu.Lock()
+		// two open requests and two batches
+		if stats.batches != 2 || stats.requests != 4 {
+			t.Fatal("unexpected number of requests", stats.batches, stats.requests)
+		}
+	})
+
+	t.Run("when we cannot open a report", func(t *testing.T) {
+		rro := &RecordingReportOpener{}
+		submitter := NewSubmitter(rro, model.DiscardLogger)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel() // fail immediately
+		ms := []*model.Measurement{
+			makeMeasurementWithoutTemplate("example"),
+			makeMeasurementWithoutTemplate("example"),
+		}
+		ms[1].ReportID = "xxx-xxx-xxx-xxx"
+		if err := submitter.SubmitMany(ctx, ms); !errors.Is(err, context.Canceled) {
+			t.Fatal("unexpected error", err)
+		}
+		if ms[1].ReportID != "" {
+			t.Fatal("expected empty report ID")
+		}
+	})
+}
+
+// makeWebConnectivityMeasurements creates measurements whose size is
+// similar to the one of typical web_connectivity measurements.
+func makeWebConnectivityMeasurements(rt model.OOAPIReportTemplate, count int) []*model.Measurement {
+	body := strings.Repeat("<div class=\"article\"><p>Lorem ipsum dolor sit amet.</p></div>\n", 512)
+	ms := makeMeasurements(rt, count)
+	for _, m := range ms {
+		m.TestKeys = map[string]any{
+			"requests": []any{map[string]any{
+				"request":  map[string]any{"url": string(m.Input), "method": "GET"},
+				"response": map[string]any{"code": 200, "body": body},
+			}},
+			"accessible": true,
+			"blocking":   false,
+		}
+	}
+	return ms
+}
+
+// benchmarkSubmitMeasurements benchmarks submitting measurements to a collector
+// supporting the given batch size and encodings and reports the uploaded bytes.
+func benchmarkSubmitMeasurements(b *testing.B, maxBatchSize int, encodings []string) {
+	// silence the logging of the collector and of the measurement URLs
+	writer := stdlog.Writer()
+	stdlog.SetOutput(io.Discard)
+	b.Cleanup(func() { stdlog.SetOutput(writer) })
+
+	stats := &collectorStats{}
+	collector := &testingx.OONICollector{
+		MaxBatchSize:       maxBatchSize,
+		SupportedEncodings: encodings,
+	}
+	client := newclientWithHandler(b, stats.wrap(collector))
+	ms := makeWebConnectivityMeasurements(newReportTemplateForTesting(), 100)
+
+	b.ResetTimer()
+	for idx := 0; idx < b.N; idx++ {
+		submitter := NewSubmitter(client, model.DiscardLogger)
+		if err := submitter.SubmitMany(context.Background(), ms); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+
+	stats.mu.Lock()
+	b.ReportMetric(float64(stats.bytes)/float64(b.N), "uploaded-bytes/op")
+	b.ReportMetric(float64(stats.requests)/float64(b.N), "requests/op")
+	stats.mu.Unlock()
+}
+
+func BenchmarkSubmitMeasurements(b *testing.B) {
+	b.Run("one measurement per request", func(b *testing.B) {
+		benchmarkSubmitMeasurements(b, 0, nil)
+	})
+	b.Run("one measurement per request with zstd", func(b *testing.B) {
+		benchmarkSubmitMeasurements(b, 0, []string{"zstd"})
+	})
+	b.Run("batches of 25", func(b *testing.B) {
+		benchmarkSubmitMeasurements(b, 25, nil)
+	})
+	b.Run("batches of 25 with gzip", func(b *testing.B) {
+		benchmarkSubmitMeasurements(b, 25, []string{"gzip"})
+	})
+	b.Run("batches of 25 with zstd", func(b *testing.B) {
+		benchmarkSubmitMeasurements(b, 25, []string{"zstd"})
+	})
+}
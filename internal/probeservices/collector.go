@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"reflect"
+	"slices"
 	"sync"
 
 	"github.com/ooni/probe-cli/v3/internal/httpclientx"
@@ -48,6 +49,15 @@ type reportChan struct {
 	// client is the client that was used.
 	client Client
 
+	// encoding is the content encoding to use for request bodies.
+	encoding string
+
+	// maxBatchSize is the maximum number of measurements per batch.
+	maxBatchSize int
+
+	// mu provides mutual exclusion.
+	mu sync.Mutex
+
 	// tmpl is the template used when opening this report.
 	tmpl model.OOAPIReportTemplate
 }
@@ -83,16 +93,33 @@ func (c Client) OpenReport(ctx context.Context, rt model.OOAPIReportTemplate) (R
 
 	for _, format := range cor.SupportedFormats {
 		if format == "json" {
-			return &reportChan{ID: cor.ReportID, client: c, tmpl: rt}, nil
+			return &reportChan{
+				ID:           cor.ReportID,
+				client:       c,
+				encoding:     selectContentEncoding(cor.SupportedEncodings),
+				maxBatchSize: cor.MaxBatchSize,
+				tmpl:         rt,
+			}, nil
 		}
 	}
 	return nil, ErrJSONFormatNotSupported
 }
 
+// selectContentEncoding returns the preferred content encoding among the
+// ones supported by the collector or an empty string if none is usable.
+func selectContentEncoding(supported []string) string {
+	for _, encoding := range []string{"zstd", "gzip"} {
+		if slices.Contains(supported, encoding) {
+			return encoding
+		}
+	}
+	return ""
+}
+
 // CanSubmit returns true whether the provided measurement belongs to
 // this report, false otherwise. We say that a given measurement belongs
 // to this report if its report template matches the report's one.
-func (r reportChan) CanSubmit(m *model.Measurement) bool {
+func (r *reportChan) CanSubmit(m *model.Measurement) bool {
 	return reflect.DeepEqual(NewReportTemplate(m), r.tmpl)
 }
 
@@ -101,14 +128,14 @@ func (r reportChan) CanSubmit(m *model.Measurement) bool {
 // such that it contains the report ID for which it has been
 // submitted. Otherwise, we'll set the report ID to the empty
 // string, so that you know which measurements weren't submitted.
-func (r reportChan) SubmitMeasurement(ctx context.Context, m *model.Measurement) error {
+func (r *reportChan) SubmitMeasurement(ctx context.Context, m *model.Measurement) error {
 	// TODO(bassosimone): do we need to prevent measurement submission
 	// if the measurement isn't consistent with the orig template?
 
 	m.ReportID = r.ID
 
-	URL, err := urlx.ResolveReference(r.client.BaseURL, fmt.Sprintf("/report/%s", r.ID), "")
-	if err != nil {
+	if err := r.maybeSign(m); err != nil {
+		m.ReportID = ""
 		return err
 	}
 
@@ -117,17 +144,9 @@ func (r reportChan) SubmitMeasurement(ctx context.Context, m *model.Measurement)
 		Content: m,
 	}
 
-	updateResponse, err := httpclientx.PostJSON[
+	updateResponse, err := reportChanPostJSON[
 		model.OOAPICollectorUpdateRequest, *model.OOAPICollectorUpdateResponse](
-		ctx,
-		httpclientx.NewEndpoint(URL).WithHostOverride(r.client.Host),
-		apiReq,
-		&httpclientx.Config{
-			Client:    r.client.HTTPClient,
-			Logger:    r.client.Logger,
-			UserAgent: r.client.UserAgent,
-		},
-	)
+		ctx, r, fmt.Sprintf("/report/%s", r.ID), apiReq)
 
 	if err != nil {
 		m.ReportID = ""
@@ -140,8 +159,17 @@ func (r reportChan) SubmitMeasurement(ctx context.Context, m *model.Measurement)
 	return nil
 }
 
+// maybeSign signs the measurement if the client has a signer. We sign after
+// setting the report ID because the signature covers all the fields.
+func (r *reportChan) maybeSign(m *model.Measurement) error {
+	if r.client.Signer == nil {
+		return nil
+	}
+	return r.client.Signer.SignMeasurement(m)
+}
+
 // ReportID returns the report ID.
-func (r reportChan) ReportID() string {
+func (r *reportChan) ReportID() string {
 	return r.ID
 }
 
@@ -155,6 +183,15 @@ type ReportChannel interface {
 
 var _ ReportChannel = &reportChan{}
 
+// BatchReportChannel is a [ReportChannel] that can also submit several
+// measurements belonging to the same report at once.
+type BatchReportChannel interface {
+	ReportChannel
+	SubmitMeasurements(ctx context.Context, ms []*model.Measurement) error
+}
+
+var _ BatchReportChannel = &reportChan{}
+
 // ReportOpener is any struct that is able to open a new ReportChannel. The
 // Client struct belongs to this interface.
 type ReportOpener interface {
@@ -183,15 +220,52 @@ func NewSubmitter(opener ReportOpener, logger model.Logger) *Submitter {
 // Submit submits the current measurement to the OONI backend created using
 // the ReportOpener passed to the constructor.
 func (sub *Submitter) Submit(ctx context.Context, m *model.Measurement) error {
-	var err error
 	sub.mu.Lock()
 	defer sub.mu.Unlock()
-	if sub.channel == nil || !sub.channel.CanSubmit(m) {
-		sub.channel, err = sub.opener.OpenReport(ctx, NewReportTemplate(m))
-		if err != nil {
+	if err := sub.maybeOpenReportLocked(ctx, m); err != nil {
+		return err
+	}
+	return sub.channel.SubmitMeasurement(ctx, m)
+}
+
+// SubmitMany is like Submit but submits several measurements. We group consecutive
+// measurements belonging to the same report and submit each group using a batched
+// and compressed upload when the backend supports that. On failure, the measurements
+// we could not submit have an empty report ID.
+func (sub *Submitter) SubmitMany(ctx context.Context, ms []*model.Measurement) error {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	for len(ms) > 0 {
+		if err := sub.maybeOpenReportLocked(ctx, ms[0]); err != nil {
+			clearReportIDs(ms)
+			return err
+		}
+		count := 1
+		for count < len(ms) && sub.channel.CanSubmit(ms[count]) {
+			count++
+		}
+		if err := submitToChannel(ctx, sub.channel, ms[:count]); err != nil {
+			clearReportIDs(ms[count:])
 			return err
 		}
-		sub.logger.Infof("New reportID: %s", sub.channel.ReportID())
+		ms = ms[count:]
 	}
-	return sub.channel.SubmitMeasurement(ctx, m)
+	return nil
+}
+
+// maybeOpenReportLocked opens a new report unless the current one can be
+// used to submit the given measurement. This method assumes the caller is
+// holding the mutex.
+func (sub *Submitter) maybeOpenReportLocked(ctx context.Context, m *model.Measurement) error {
+	if sub.channel != nil && sub.channel.CanSubmit(m) {
+		return nil
+	}
+	channel, err := sub.opener.OpenReport(ctx, NewReportTemplate(m))
+	if err != nil {
+		sub.channel = nil
+		return err
+	}
+	sub.channel = channel
+	sub.logger.Infof("New reportID: %s", sub.channel.ReportID())
+	return nil
 }
@@ -7,12 +7,16 @@ import (
 	"net/url"
 	"reflect"
 	"sync"
+	"sync/atomic"
 	"testing"
 
 	"github.com/apex/log"
 	"github.com/google/go-cmp/cmp"
+	"github.com/ooni/probe-cli/v3/internal/kvstore"
+	"github.com/ooni/probe-cli/v3/internal/measurementsig"
 	"github.com/ooni/probe-cli/v3/internal/mocks"
 	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/must"
 	"github.com/ooni/probe-cli/v3/internal/netxlite"
 	"github.com/ooni/probe-cli/v3/internal/runtimex"
 	"github.com/ooni/probe-cli/v3/internal/testingx"
@@ -798,3 +802,64 @@ func TestSubmitterCannotOpenNewChannel(t *testing.T) {
 		t.Fatal("unexpected number of channels")
 	}
 }
+
+// measurementSignerFunc is a [MeasurementSigner] implemented by a func.
+type measurementSignerFunc func(m *model.Measurement) error
+
+func (fn measurementSignerFunc) SignMeasurement(m *model.Measurement) error {
+	return fn(m)
+}
+
+func TestReportChanSignsMeasurements(t *testing.T) {
+	t.Run("we submit measurements signed after setting the report ID", func(t *testing.T) {
+		signer := runtimex.Try1(measurementsig.NewSigner(&kvstore.Memory{}, model.DiscardLogger))
+		verified := &atomic.Int64{}
+		collector := &testingx.OONICollector{
+			MaxBatchSize: 2,
+			ValidateMeasurement: func(meas *model.Measurement) error {
+				if err := measurementsig.Verify(must.MarshalJSON(meas), signer.PublicKey()); err != nil {
+					return err
+				}
+				verified.Add(1)
+				return nil
+			},
+		}
+		client := newclientWithHandler(t, collector)
+		client.Signer = signer
+		report := openReportForTesting(t, client)
+
+		ms := makeMeasurements(report.tmpl, 3)
+		if err := report.SubmitMeasurement(context.Background(), ms[0]); err != nil {
+			t.Fatal(err)
+		}
+		if err := report.SubmitMeasurements(context.Background(), ms[1:]); err != nil {
+			t.Fatal(err)
+		}
+		if verified.Load() != 3 {
+			t.Fatal("unexpected number of verified measurements", verified.Load())
+		}
+	})
+
+	t.Run("we do not submit when we cannot sign", func(t *testing.T) {
+		expected := errors.New("mocked error")
+		collector := &testingx.OONICollector{MaxBatchSize: 2}
+		client := newclientWithHandler(t, collector)
+		client.Signer = measurementSignerFunc(func(m *model.Measurement) error {
+			return expected
+		})
+		report := openReportForTesting(t, client)
+
+		ms := makeMeasurements(report.tmpl, 3)
+		if err := report.SubmitMeasurement(context.Background(), ms[0]); !errors.Is(err, expected) {
+			t.Fatal("unexpected error", err)
+		}
+		if err := report.SubmitMeasurements(context.Background(), ms[1:]); !errors.Is(err, expected) {
+			t.Fatal("unexpected error", err)
+		}
+		for _, m := range ms {
+			if m.ReportID != "" {
+				t.Fatal("expected empty report ID")
+			}
+		}
+	})
+}
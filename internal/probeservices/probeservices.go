@@ -61,6 +61,12 @@ type Session interface {
 	UserAgent() string
 }
 
+// MeasurementSigner signs measurements before we submit them. The Client.Signer
+// field is OPTIONAL and, when nil, we submit unsigned measurements.
+type MeasurementSigner interface {
+	SignMeasurement(m *model.Measurement) error
+}
+
 // Client is a client for the OONI probe services API.
 type Client struct {
 	BaseURL       string
@@ -70,6 +76,7 @@ type Client struct {
 	Logger        model.Logger
 	LoginCalls    *atomic.Int64
 	RegisterCalls *atomic.Int64
+	Signer        MeasurementSigner
 	StateFile     StateFile
 	UserAgent     string
 }
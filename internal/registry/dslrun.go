@@ -0,0 +1,26 @@
+package registry
+
+//
+// Registers the `dslrun' experiment.
+//
+
+import (
+	"github.com/ooni/probe-cli/v3/internal/experiment/dslrun"
+	"github.com/ooni/probe-cli/v3/internal/model"
+)
+
+func init() {
+	const canonicalName = "dslrun"
+	AllExperiments[canonicalName] = func() *Factory {
+		return &Factory{
+			build: func(config interface{}) model.ExperimentMeasurer {
+				return dslrun.NewExperimentMeasurer(
+					*config.(*dslrun.Config),
+				)
+			},
+			canonicalName: canonicalName,
+			config:        &dslrun.Config{},
+			inputPolicy:   model.InputStrictlyRequired,
+		}
+	}
+}
@@ -5,6 +5,7 @@ package registry
 //
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -57,6 +58,8 @@ func (b *Factory) NewTargetLoader(config *model.ExperimentTargetLoaderConfig) mo
 		Session:        config.Session,
 		StaticInputs:   config.StaticInputs,
 		SourceFiles:    config.SourceFiles,
+		Sampling:       config.Sampling,    // OPTIONAL
+		RemoteLists:    config.RemoteLists, // OPTIONAL
 	}
 
 	// If an experiment implements richer input, it will use its custom loader
@@ -267,6 +270,28 @@ func (b *Factory) SetOptionsJSON(value json.RawMessage) error {
 	return json.Unmarshal(value, b.config)
 }
 
+// ValidateOptionsJSON checks whether [SetOptionsJSON] would accept the
+// given [json.RawMessage] and additionally fails if it contains options that
+// the experiment does not know about, which [SetOptionsJSON] ignores. This
+// method does not modify the experiment specific configuration.
+func (b *Factory) ValidateOptionsJSON(value json.RawMessage) error {
+	// handle the case where the options are empty
+	if len(value) <= 0 {
+		return nil
+	}
+
+	// make sure we're dealing with a pointer
+	ptrinfo := reflect.ValueOf(b.config)
+	if ptrinfo.Kind() != reflect.Ptr {
+		return ErrConfigIsNotAStructPointer
+	}
+
+	// unmarshal into a scratch copy of the configuration
+	decoder := json.NewDecoder(bytes.NewReader(value))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(reflect.New(ptrinfo.Elem().Type()).Interface())
+}
+
 // fieldbyname return v's field whose name is equal to the given key.
 func (b *Factory) fieldbyname(v interface{}, key string) (reflect.Value, error) {
 	// See https://stackoverflow.com/a/6396678/4354461
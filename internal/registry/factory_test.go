@@ -8,6 +8,7 @@ import (
 	"math"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/apex/log"
@@ -564,6 +565,57 @@ func TestFactorySetOptionsJSON(t *testing.T) {
 	}
 }
 
+func TestFactoryValidateOptionsJSON(t *testing.T) {
+	// PersonRecord is a fake experiment configuration.
+	type PersonRecord struct {
+		Name    string
+		Age     int64
+		Friends []string
+	}
+
+	t.Run("we accept zero-length options", func(t *testing.T) {
+		factory := &Factory{config: &PersonRecord{}}
+		if err := factory.ValidateOptionsJSON([]byte{}); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("we accept known options without modifying the config", func(t *testing.T) {
+		config := &PersonRecord{Name: "foo"}
+		factory := &Factory{config: config}
+		if err := factory.ValidateOptionsJSON([]byte(`{"Name": "bar", "Age": 11}`)); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(&PersonRecord{Name: "foo"}, config); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("we reject options with the wrong type", func(t *testing.T) {
+		factory := &Factory{config: &PersonRecord{}}
+		err := factory.ValidateOptionsJSON([]byte(`{"Age": "eleven"}`))
+		if err == nil || !strings.Contains(err.Error(), "cannot unmarshal string") {
+			t.Fatal("unexpected error", err)
+		}
+	})
+
+	t.Run("we reject unknown options", func(t *testing.T) {
+		factory := &Factory{config: &PersonRecord{}}
+		err := factory.ValidateOptionsJSON([]byte(`{"Antani": true}`))
+		if err == nil || err.Error() != `json: unknown field "Antani"` {
+			t.Fatal("unexpected error", err)
+		}
+	})
+
+	t.Run("we return an error if the config is not a pointer", func(t *testing.T) {
+		factory := &Factory{config: PersonRecord{}}
+		err := factory.ValidateOptionsJSON([]byte(`{}`))
+		if !errors.Is(err, ErrConfigIsNotAStructPointer) {
+			t.Fatal("unexpected error", err)
+		}
+	})
+}
+
 func TestNewFactory(t *testing.T) {
 	// experimentSpecificExpectations contains expectations for an experiment
 	type experimentSpecificExpectations struct {
@@ -592,6 +644,12 @@ func TestNewFactory(t *testing.T) {
 			enabledByDefault: true,
 			inputPolicy:      model.InputOrStaticDefault,
 		},
+		"dslrun": {
+			// Note: dslrun is not enabled by default because it is a new
+			// experiment whose input is a measurement program.
+			//enabledByDefault: false,
+			inputPolicy: model.InputStrictlyRequired,
+		},
 		"echcheck": {
 			// Note: echcheck is not enabled by default because we just introduced it
 			// into 3.19.0-alpha, which makes it a relatively new experiment.
@@ -619,6 +677,12 @@ func TestNewFactory(t *testing.T) {
 			enabledByDefault: true,
 			inputPolicy:      model.InputNone,
 		},
+		"jsscript": {
+			// Note: jsscript is not enabled by default because it is a new
+			// experiment that runs the script configured using options.
+			//enabledByDefault: false,
+			inputPolicy: model.InputOptional,
+		},
 		"ndt": {
 			enabledByDefault: true,
 			inputPolicy:      model.InputNone,
@@ -677,6 +741,12 @@ func TestNewFactory(t *testing.T) {
 			enabledByDefault: true,
 			inputPolicy:      model.InputStrictlyRequired,
 		},
+		"throttlingcheck": {
+			// Note: throttlingcheck is not enabled by default because it is a new
+			// experiment that downloads large objects and may use a lot of data.
+			//enabledByDefault: false,
+			inputPolicy: model.InputStrictlyRequired,
+		},
 		"tlsmiddlebox": {
 			enabledByDefault: true,
 			inputPolicy:      model.InputStrictlyRequired,
@@ -722,6 +792,12 @@ func TestNewFactory(t *testing.T) {
 			enabledByDefault: true,
 			inputPolicy:      model.InputOrQueryBackend,
 		},
+		"webrtcreachability": {
+			// Note: webrtcreachability is not enabled by default because we just
+			// introduced it, which makes it a relatively new experiment.
+			//enabledByDefault: false,
+			inputPolicy: model.InputOrStaticDefault,
+		},
 		"whatsapp": {
 			enabledByDefault: true,
 			inputPolicy:      model.InputNone,
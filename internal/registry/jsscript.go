@@ -0,0 +1,26 @@
+package registry
+
+//
+// Registers the `jsscript' experiment.
+//
+
+import (
+	"github.com/ooni/probe-cli/v3/internal/experiment/jsscript"
+	"github.com/ooni/probe-cli/v3/internal/model"
+)
+
+func init() {
+	const canonicalName = "jsscript"
+	AllExperiments[canonicalName] = func() *Factory {
+		return &Factory{
+			build: func(config interface{}) model.ExperimentMeasurer {
+				return jsscript.NewExperimentMeasurer(
+					*config.(*jsscript.Config),
+				)
+			},
+			canonicalName: canonicalName,
+			config:        &jsscript.Config{},
+			inputPolicy:   model.InputOptional,
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package registry
+
+//
+// Registers the `throttlingcheck' experiment.
+//
+
+import (
+	"github.com/ooni/probe-cli/v3/internal/experiment/throttlingcheck"
+	"github.com/ooni/probe-cli/v3/internal/model"
+)
+
+func init() {
+	const canonicalName = "throttlingcheck"
+	AllExperiments[canonicalName] = func() *Factory {
+		return &Factory{
+			build: func(config interface{}) model.ExperimentMeasurer {
+				return throttlingcheck.NewExperimentMeasurer(
+					*config.(*throttlingcheck.Config),
+				)
+			},
+			canonicalName: canonicalName,
+			config:        &throttlingcheck.Config{},
+			inputPolicy:   model.InputStrictlyRequired,
+		}
+	}
+}
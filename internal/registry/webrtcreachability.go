@@ -0,0 +1,26 @@
+package registry
+
+//
+// Registers the `webrtcreachability' experiment.
+//
+
+import (
+	"github.com/ooni/probe-cli/v3/internal/experiment/webrtcreachability"
+	"github.com/ooni/probe-cli/v3/internal/model"
+)
+
+func init() {
+	const canonicalName = "webrtcreachability"
+	AllExperiments[canonicalName] = func() *Factory {
+		return &Factory{
+			build: func(config interface{}) model.ExperimentMeasurer {
+				return webrtcreachability.NewExperimentMeasurer(
+					*config.(*webrtcreachability.Config),
+				)
+			},
+			canonicalName: canonicalName,
+			config:        &webrtcreachability.Config{},
+			inputPolicy:   model.InputOrStaticDefault,
+		}
+	}
+}
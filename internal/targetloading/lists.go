@@ -0,0 +1,245 @@
+package targetloading
+
+//
+// Custom lists of URLs in the citizenlab test-lists format and remote signed lists
+//
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/ooni/probe-cli/v3/internal/httpclientx"
+	"github.com/ooni/probe-cli/v3/internal/model"
+)
+
+// These errors are returned when loading custom lists.
+var (
+	ErrInvalidCSVList       = errors.New("invalid test-lists CSV")
+	ErrInvalidPublicKey     = errors.New("invalid remote list public key")
+	ErrInvalidSignature     = errors.New("invalid remote list signature")
+	ErrNoURLsAfterFiltering = errors.New("no URLs after filtering by category")
+)
+
+// isTestListsCSV returns whether the given lines contain a list in the citizenlab
+// test-lists CSV format, which we detect by looking for the header line.
+func isTestListsCSV(lines []string) bool {
+	return len(lines) > 0 && strings.HasPrefix(strings.ToLower(strings.TrimSpace(lines[0])), "url,")
+}
+
+// parseTargetLines parses the non-empty lines of a list, which either contains one
+// URL per line or uses the citizenlab test-lists CSV format, whose columns are
+// url, category_code, and notes, plus other optional columns we ignore. When using
+// the CSV format, we only keep the URLs belonging to the given categories, unless
+// the list of categories is empty, and we return [ErrNoURLsAfterFiltering] when no
+// URL belongs to such categories. A list containing one URL per line does not
+// have any category information, therefore we do not filter it.
+func parseTargetLines(lines []string, categories []string) ([]model.OOAPIURLInfo, error) {
+	if !isTestListsCSV(lines) {
+		var targets []model.OOAPIURLInfo
+		for _, line := range lines {
+			targets = append(targets, *model.NewOOAPIURLInfoWithDefaultCategoryAndCountry(line))
+		}
+		return targets, nil
+	}
+	reader := csv.NewReader(strings.NewReader(strings.Join(lines, "\n")))
+	reader.FieldsPerRecord = -1 // tolerate rows with missing trailing columns
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidCSVList, err.Error())
+	}
+	columns := make(map[string]int)
+	for idx, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = idx
+	}
+	field := func(record []string, name string) string {
+		idx, found := columns[name]
+		if !found || idx >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[idx])
+	}
+	var targets []model.OOAPIURLInfo
+	for _, record := range records[1:] {
+		entry := model.NewOOAPIURLInfoWithDefaultCategoryAndCountry(field(record, "url"))
+		if entry.URL == "" {
+			continue
+		}
+		if category := field(record, "category_code"); category != "" {
+			entry.CategoryCode = category
+		}
+		targets = append(targets, *entry)
+	}
+	filtered := filterByCategory(targets, categories)
+	if len(targets) > 0 && len(filtered) <= 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoURLsAfterFiltering, strings.Join(categories, ","))
+	}
+	return filtered, nil
+}
+
+// readTargetsFile reads targets from the specified file using [parseTargetLines]. The
+// open argument should be compatible with stdlib's fs.Open and helps us with unit testing.
+func readTargetsFile(filepath string, open openFunc, categories []string) ([]model.OOAPIURLInfo, error) {
+	lines, err := readfile(filepath, open)
+	if err != nil {
+		return nil, err
+	}
+	targets, err := parseTargetLines(lines, categories)
+	if errors.Is(err, ErrNoURLsAfterFiltering) {
+		return nil, fmt.Errorf("%w: %s", err, filepath)
+	}
+	return targets, err
+}
+
+// filterByCategory returns the targets belonging to the given categories or
+// all the targets when the list of categories is empty.
+func filterByCategory(targets []model.OOAPIURLInfo, categories []string) (output []model.OOAPIURLInfo) {
+	if len(categories) <= 0 {
+		return targets
+	}
+	for _, target := range targets {
+		for _, category := range categories {
+			if target.CategoryCode == category {
+				output = append(output, target)
+				break
+			}
+		}
+	}
+	return
+}
+
+// remoteListCacheEntry is the kvstore entry caching a verified remote list.
+type remoteListCacheEntry struct {
+	// Body is the list body.
+	Body []byte
+
+	// Signature is the signature of the body.
+	Signature []byte
+}
+
+// remoteListCacheKey returns the kvstore key caching the list at the given URL.
+func remoteListCacheKey(URL string) string {
+	digest := sha256.Sum256([]byte(URL))
+	return "targetloading-remote-list-" + hex.EncodeToString(digest[:])
+}
+
+// loadRemoteLists loads the targets inside the configured remote lists.
+func (il *Loader) loadRemoteLists(ctx context.Context) ([]model.OOAPIURLInfo, error) {
+	if il.RemoteLists == nil {
+		return nil, nil
+	}
+	var targets []model.OOAPIURLInfo
+	for _, list := range il.RemoteLists.Lists {
+		body, err := il.fetchRemoteList(ctx, list)
+		if err != nil {
+			return nil, err
+		}
+		var lines []string
+		for _, line := range strings.Split(string(body), "\n") {
+			if line = strings.TrimRight(line, "\r"); line != "" {
+				lines = append(lines, line)
+			}
+		}
+		if len(lines) <= 0 {
+			return nil, fmt.Errorf("%w: %s", ErrDetectedEmptyFile, list.URL)
+		}
+		extra, err := parseTargetLines(lines, il.categoryCodes())
+		if errors.Is(err, ErrNoURLsAfterFiltering) {
+			return nil, fmt.Errorf("%w: %s", err, list.URL)
+		}
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, extra...)
+	}
+	return targets, nil
+}
+
+// fetchRemoteList fetches and verifies the given remote list. On success, we cache the
+// list. On failure, we fall back to the cached list, if any and if still valid.
+func (il *Loader) fetchRemoteList(ctx context.Context, list model.ExperimentTargetRemoteList) ([]byte, error) {
+	pubkey, err := base64.StdEncoding.DecodeString(list.PublicKey)
+	if err != nil || len(pubkey) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPublicKey, list.URL)
+	}
+	body, signature, err := il.fetchRemoteListAndSignature(ctx, list.URL)
+	if err == nil && !ed25519.Verify(pubkey, body, signature) {
+		err = fmt.Errorf("%w: %s", ErrInvalidSignature, list.URL)
+	}
+	store := il.RemoteLists.KVStore
+	if err == nil {
+		data, _ := json.Marshal(&remoteListCacheEntry{Body: body, Signature: signature})
+		if err := store.Set(remoteListCacheKey(list.URL), data); err != nil {
+			il.logger().Warnf("cannot cache remote list %s: %s", list.URL, err.Error())
+		}
+		return body, nil
+	}
+	data, cacheErr := store.Get(remoteListCacheKey(list.URL))
+	if cacheErr != nil {
+		return nil, err
+	}
+	var entry remoteListCacheEntry
+	if json.Unmarshal(data, &entry) != nil || !ed25519.Verify(pubkey, entry.Body, entry.Signature) {
+		return nil, err
+	}
+	il.logger().Warnf("cannot fetch remote list %s: %s; using the cached copy", list.URL, err.Error())
+	return entry.Body, nil
+}
+
+// fetchRemoteListAndSignature fetches the list body and the detached signature.
+func (il *Loader) fetchRemoteListAndSignature(ctx context.Context, URL string) ([]byte, []byte, error) {
+	signatureURL, err := remoteListSignatureURL(URL)
+	if err != nil {
+		return nil, nil, err
+	}
+	config := &httpclientx.Config{
+		Authorization: "", // not needed
+		Client:        il.RemoteLists.HTTPClient,
+		Logger:        il.remoteListsLogger(),
+		UserAgent:     il.RemoteLists.UserAgent,
+	}
+	body, err := httpclientx.GetRaw(ctx, httpclientx.NewEndpoint(URL), config)
+	if err != nil {
+		return nil, nil, err
+	}
+	encoded, err := httpclientx.GetRaw(ctx, httpclientx.NewEndpoint(signatureURL), config)
+	if err != nil {
+		return nil, nil, err
+	}
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encoded)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %s", ErrInvalidSignature, URL)
+	}
+	return body, signature, nil
+}
+
+// remoteListSignatureURL returns the URL of the detached signature of the
+// list at the given URL, which has the same path plus the ".sig" suffix.
+func remoteListSignatureURL(URL string) (string, error) {
+	parsed, err := url.Parse(URL)
+	if err != nil {
+		return "", err
+	}
+	parsed.Path += ".sig"
+	if parsed.RawPath != "" {
+		parsed.RawPath += ".sig"
+	}
+	return parsed.String(), nil
+}
+
+// remoteListsLogger returns the [model.Logger] to use when fetching remote
+// lists, which is our logger when it is also a [model.Logger].
+func (il *Loader) remoteListsLogger() model.Logger {
+	if logger, ok := il.logger().(model.Logger); ok {
+		return logger
+	}
+	return model.DiscardLogger
+}
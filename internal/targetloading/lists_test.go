@@ -0,0 +1,427 @@
+package targetloading
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/ooni/probe-cli/v3/internal/kvstore"
+	"github.com/ooni/probe-cli/v3/internal/mocks"
+	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/runtimex"
+)
+
+func TestParseTargetLines(t *testing.T) {
+	t.Run("with one URL per line", func(t *testing.T) {
+		out, err := parseTargetLines([]string{"https://www.x.org/", "https://abc.xyz/"}, []string{"NEWS"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect := []model.OOAPIURLInfo{
+			*model.NewOOAPIURLInfoWithDefaultCategoryAndCountry("https://www.x.org/"),
+			*model.NewOOAPIURLInfoWithDefaultCategoryAndCountry("https://abc.xyz/"),
+		}
+		if diff := cmp.Diff(expect, out); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("with the test-lists CSV format", func(t *testing.T) {
+		lines := []string{
+			"url,category_code,notes",
+			"https://www.repubblica.it/,NEWS,",
+			",HUMR,missing URL",
+			"https://www.amnesty.org/,HUMR",
+			"https://www.example.com/",
+		}
+		out, err := parseTargetLines(lines, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect := []model.OOAPIURLInfo{{
+			CategoryCode: "NEWS",
+			CountryCode:  model.DefaultCountryCode,
+			URL:          "https://www.repubblica.it/",
+		}, {
+			CategoryCode: "HUMR",
+			CountryCode:  model.DefaultCountryCode,
+			URL:          "https://www.amnesty.org/",
+		}, {
+			CategoryCode: model.DefaultCategoryCode,
+			CountryCode:  model.DefaultCountryCode,
+			URL:          "https://www.example.com/",
+		}}
+		if diff := cmp.Diff(expect, out); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("when no URL belongs to the given categories", func(t *testing.T) {
+		lines := []string{
+			"url,category_code,notes",
+			"https://www.repubblica.it/,NEWS,",
+		}
+		out, err := parseTargetLines(lines, []string{"HUMR"})
+		if !errors.Is(err, ErrNoURLsAfterFiltering) {
+			t.Fatal("unexpected error", err)
+		}
+		if out != nil {
+			t.Fatal("expected nil output")
+		}
+	})
+
+	t.Run("with an invalid CSV", func(t *testing.T) {
+		out, err := parseTargetLines([]string{"url,category_code", `"https://www.x.org/`}, nil)
+		if !errors.Is(err, ErrInvalidCSVList) {
+			t.Fatal("unexpected error", err)
+		}
+		if out != nil {
+			t.Fatal("expected nil output")
+		}
+	})
+}
+
+func TestTargetLoaderWithTestListsCSVFile(t *testing.T) {
+	il := &Loader{
+		CheckInConfig: &model.OOAPICheckInConfig{
+			WebConnectivity: model.OOAPICheckInConfigWebConnectivity{
+				CategoryCodes: []string{"NEWS"},
+			},
+		},
+		InputPolicy:  model.InputOrQueryBackend,
+		StaticInputs: []string{"https://www.google.com/"},
+		SourceFiles:  []string{"testdata/testlist.csv"},
+	}
+	out, err := il.Load(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var inputs, categories []string
+	for _, entry := range out {
+		inputs = append(inputs, entry.Input())
+		categories = append(categories, entry.Category())
+	}
+	if diff := cmp.Diff([]string{"https://www.google.com/", "https://www.repubblica.it/", "https://www.corriere.it/"}, inputs); diff != "" {
+		t.Fatal(diff)
+	}
+	if diff := cmp.Diff([]string{model.DefaultCategoryCode, "NEWS", "NEWS"}, categories); diff != "" {
+		t.Fatal(diff)
+	}
+
+	t.Run("we fail when no URL belongs to the configured categories", func(t *testing.T) {
+		il := &Loader{
+			CheckInConfig: &model.OOAPICheckInConfig{
+				WebConnectivity: model.OOAPICheckInConfigWebConnectivity{
+					CategoryCodes: []string{"GAME"},
+				},
+			},
+			InputPolicy: model.InputOrQueryBackend,
+			SourceFiles: []string{"testdata/testlist.csv"},
+		}
+		out, err := il.Load(context.Background())
+		if !errors.Is(err, ErrNoURLsAfterFiltering) {
+			t.Fatal("unexpected error", err)
+		}
+		if out != nil {
+			t.Fatal("expected nil output")
+		}
+	})
+
+	t.Run("LoadStatic returns the URLs", func(t *testing.T) {
+		inputs, err := LoadStatic(&Loader{SourceFiles: []string{"testdata/testlist.csv"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(inputs) != 3 || inputs[1] != "https://www.amnesty.org/" {
+			t.Fatal("unexpected inputs", inputs)
+		}
+	})
+}
+
+// remoteListServer is a server serving a signed remote list.
+type remoteListServer struct {
+	// body is the list body.
+	body string
+
+	// broken causes the server to fail.
+	broken bool
+
+	// privkey is the key to sign the list.
+	privkey ed25519.PrivateKey
+
+	// pubkey is the base64 encoded public key.
+	pubkey string
+}
+
+func newRemoteListServer(body string) *remoteListServer {
+	pubkey, privkey, err := ed25519.GenerateKey(nil)
+	runtimex.PanicOnError(err, "ed25519.GenerateKey failed")
+	return &remoteListServer{
+		body:    body,
+		privkey: privkey,
+		pubkey:  base64.StdEncoding.EncodeToString(pubkey),
+	}
+}
+
+func (srv *remoteListServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case srv.broken:
+		w.WriteHeader(http.StatusInternalServerError)
+	case r.URL.Path == "/list.csv":
+		w.Write([]byte(srv.body))
+	case r.URL.Path == "/list.csv.sig":
+		w.Write([]byte(base64.StdEncoding.EncodeToString(ed25519.Sign(srv.privkey, []byte(srv.body)))))
+	case r.URL.Path == "/broken.csv":
+		w.Write([]byte(srv.body))
+	case r.URL.Path == "/broken.csv.sig":
+		w.Write([]byte("%%%"))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestTargetLoaderWithRemoteLists(t *testing.T) {
+	body := "url,category_code,notes\r\nhttps://www.repubblica.it/,NEWS,\r\nhttps://www.amnesty.org/,HUMR,\r\n"
+	handler := newRemoteListServer(body)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	newLoader := func(store model.KeyValueStore, list model.ExperimentTargetRemoteList) *Loader {
+		return &Loader{
+			CheckInConfig: &model.OOAPICheckInConfig{
+				WebConnectivity: model.OOAPICheckInConfigWebConnectivity{
+					CategoryCodes: []string{"HUMR"},
+				},
+			},
+			InputPolicy: model.InputOrQueryBackend,
+			RemoteLists: &model.ExperimentTargetRemoteListsConfig{
+				HTTPClient: http.DefaultClient,
+				KVStore:    store,
+				Lists:      []model.ExperimentTargetRemoteList{list},
+			},
+			Session: &TargetLoaderMockableSession{},
+		}
+	}
+
+	store := &kvstore.Memory{}
+	list := model.ExperimentTargetRemoteList{URL: server.URL + "/list.csv", PublicKey: handler.pubkey}
+
+	t.Run("we fetch, verify, filter, and cache the list", func(t *testing.T) {
+		out, err := newLoader(store, list).Load(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(out) != 1 || out[0].Input() != "https://www.amnesty.org/" || out[0].Category() != "HUMR" {
+			t.Fatal("unexpected output", out)
+		}
+		if _, err := store.Get(remoteListCacheKey(list.URL)); err != nil {
+			t.Fatal("expected the list to be cached", err)
+		}
+	})
+
+	t.Run("we fetch the signature of a list whose URL has a query string", func(t *testing.T) {
+		list := model.ExperimentTargetRemoteList{URL: server.URL + "/list.csv?lang=it", PublicKey: handler.pubkey}
+		out, err := newLoader(&kvstore.Memory{}, list).Load(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(out) != 1 || out[0].Input() != "https://www.amnesty.org/" {
+			t.Fatal("unexpected output", out)
+		}
+	})
+
+	t.Run("we use the cached list when the server fails", func(t *testing.T) {
+		handler.broken = true
+		defer func() { handler.broken = false }()
+		out, err := newLoader(store, list).Load(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(out) != 1 || out[0].Input() != "https://www.amnesty.org/" {
+			t.Fatal("unexpected output", out)
+		}
+	})
+
+	t.Run("we fail when the server fails and there is no cached list", func(t *testing.T) {
+		handler.broken = true
+		defer func() { handler.broken = false }()
+		out, err := newLoader(&kvstore.Memory{}, list).Load(context.Background())
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+		if out != nil {
+			t.Fatal("expected nil output")
+		}
+	})
+
+	t.Run("we reject a list signed with another key", func(t *testing.T) {
+		other := newRemoteListServer(body)
+		list := model.ExperimentTargetRemoteList{URL: list.URL, PublicKey: other.pubkey}
+		out, err := newLoader(&kvstore.Memory{}, list).Load(context.Background())
+		if !errors.Is(err, ErrInvalidSignature) {
+			t.Fatal("unexpected error", err)
+		}
+		if out != nil {
+			t.Fatal("expected nil output")
+		}
+	})
+
+	t.Run("we do not use a cached list signed with another key", func(t *testing.T) {
+		handler.broken = true
+		defer func() { handler.broken = false }()
+		other := newRemoteListServer(body)
+		list := model.ExperimentTargetRemoteList{URL: list.URL, PublicKey: other.pubkey}
+		out, err := newLoader(store, list).Load(context.Background())
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+		if out != nil {
+			t.Fatal("expected nil output")
+		}
+	})
+
+	t.Run("we reject a signature that is not base64", func(t *testing.T) {
+		list := model.ExperimentTargetRemoteList{URL: server.URL + "/broken.csv", PublicKey: handler.pubkey}
+		_, err := newLoader(&kvstore.Memory{}, list).Load(context.Background())
+		if !errors.Is(err, ErrInvalidSignature) {
+			t.Fatal("unexpected error", err)
+		}
+	})
+
+	t.Run("we reject an invalid public key", func(t *testing.T) {
+		list := model.ExperimentTargetRemoteList{URL: list.URL, PublicKey: "AAAA"}
+		_, err := newLoader(&kvstore.Memory{}, list).Load(context.Background())
+		if !errors.Is(err, ErrInvalidPublicKey) {
+			t.Fatal("unexpected error", err)
+		}
+	})
+
+	t.Run("we do not load the lists when there are static inputs or source files", func(t *testing.T) {
+		broken := &remoteListServer{broken: true}
+		server := httptest.NewServer(broken)
+		defer server.Close()
+		list := model.ExperimentTargetRemoteList{URL: server.URL + "/list.csv", PublicKey: handler.pubkey}
+
+		t.Run("with static inputs", func(t *testing.T) {
+			il := newLoader(&kvstore.Memory{}, list)
+			il.StaticInputs = []string{"https://www.google.com/"}
+			out, err := il.Load(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(out) != 1 || out[0].Input() != "https://www.google.com/" {
+				t.Fatal("unexpected output", out)
+			}
+		})
+
+		t.Run("with source files", func(t *testing.T) {
+			il := newLoader(&kvstore.Memory{}, list)
+			il.SourceFiles = []string{"testdata/loader1.txt"}
+			out, err := il.Load(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(out) != 3 || out[0].Input() != "https://www.x.org/" {
+				t.Fatal("unexpected output", out)
+			}
+		})
+	})
+
+	t.Run("we fail when no URL in the list belongs to the configured categories", func(t *testing.T) {
+		news := newRemoteListServer("url,category_code,notes\nhttps://www.repubblica.it/,NEWS,\n")
+		server := httptest.NewServer(news)
+		defer server.Close()
+		list := model.ExperimentTargetRemoteList{URL: server.URL + "/list.csv", PublicKey: news.pubkey}
+		_, err := newLoader(&kvstore.Memory{}, list).Load(context.Background())
+		if !errors.Is(err, ErrNoURLsAfterFiltering) {
+			t.Fatal("unexpected error", err)
+		}
+	})
+
+	t.Run("we reject an empty list", func(t *testing.T) {
+		empty := newRemoteListServer("\n")
+		server := httptest.NewServer(empty)
+		defer server.Close()
+		list := model.ExperimentTargetRemoteList{URL: server.URL + "/list.csv", PublicKey: empty.pubkey}
+		_, err := newLoader(&kvstore.Memory{}, list).Load(context.Background())
+		if !errors.Is(err, ErrDetectedEmptyFile) {
+			t.Fatal("unexpected error", err)
+		}
+	})
+
+	t.Run("we continue when we cannot cache the list", func(t *testing.T) {
+		store := &mocks.KeyValueStore{
+			MockSet: func(key string, value []byte) error {
+				return errors.New("mocked error")
+			},
+		}
+		out, err := newLoader(store, list).Load(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(out) != 1 {
+			t.Fatal("unexpected output", out)
+		}
+	})
+}
+
+func TestRemoteListSignatureURL(t *testing.T) {
+	cases := []struct {
+		input  string
+		expect string
+	}{{
+		input:  "https://example.com/list.csv",
+		expect: "https://example.com/list.csv.sig",
+	}, {
+		input:  "https://example.com/list.csv?lang=it#top",
+		expect: "https://example.com/list.csv.sig?lang=it#top",
+	}, {
+		input:  "https://example.com/a%2Fb.csv",
+		expect: "https://example.com/a%2Fb.csv.sig",
+	}}
+	for _, tc := range cases {
+		t.Run(tc.input, func(t *testing.T) {
+			got, err := remoteListSignatureURL(tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.expect {
+				t.Fatal("expected", tc.expect, "got", got)
+			}
+		})
+	}
+
+	t.Run("with an invalid URL", func(t *testing.T) {
+		if _, err := remoteListSignatureURL("\t"); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
+
+func TestLoaderRemoteListsLogger(t *testing.T) {
+	t.Run("with a model.Logger", func(t *testing.T) {
+		logger := &mocks.Logger{}
+		il := &Loader{Logger: logger}
+		if il.remoteListsLogger() != logger {
+			t.Fatal("expected our logger")
+		}
+	})
+
+	t.Run("with a logger that only emits warnings", func(t *testing.T) {
+		il := &Loader{Logger: &warningsOnlyLogger{}}
+		if il.remoteListsLogger() != model.DiscardLogger {
+			t.Fatal("expected the discard logger")
+		}
+	})
+}
+
+// warningsOnlyLogger is a [Logger] that is not a [model.Logger].
+type warningsOnlyLogger struct{}
+
+// Warnf implements Logger.
+func (*warningsOnlyLogger) Warnf(format string, v ...interface{}) {}
@@ -0,0 +1,120 @@
+package targetloading
+
+//
+// Sampling of the URLs returned by the check-in API
+//
+
+import (
+	"math"
+	"math/rand"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ooni/probe-cli/v3/internal/model"
+)
+
+// samplingDefaultStaleAfter is the default interval after which we
+// consider stale a URL that we have already measured.
+const samplingDefaultStaleAfter = 7 * 24 * time.Hour
+
+// samplingEntry is an entry managed by [sampleURLs].
+type samplingEntry struct {
+	// info is the original URL info.
+	info model.OOAPIURLInfo
+
+	// key is the weighted random sorting key.
+	key float64
+
+	// lastMeasured is when we last measured the URL (zero if never).
+	lastMeasured time.Time
+
+	// stale indicates that we should measure the URL first.
+	stale bool
+}
+
+// sampleURLs reorders the inputs according to the given config such that:
+//
+// 1. the stale URLs come first, in random order weighted by category priority;
+//
+// 2. the other URLs follow, least recently measured first;
+//
+// 3. if configured, URLs whose domain we already saw move after all the
+// URLs with a previously unseen domain, preserving their relative order.
+//
+// We use a weighted random shuffle where each entry gets the u^(1/w) key, with
+// u drawn uniformly from [0, 1), and we sort by decreasing key. The entries
+// with zero or negative weight get a key smaller than any other key.
+func sampleURLs(inputs []model.OOAPIURLInfo,
+	config *model.ExperimentTargetSamplingConfig, now time.Time) []model.OOAPIURLInfo {
+	seed := config.Seed
+	if seed == 0 {
+		seed = now.UnixNano()
+	}
+	rng := rand.New(rand.NewSource(seed)) // #nosec G404 -- not used for security purposes
+	staleAfter := config.StaleAfter
+	if staleAfter <= 0 {
+		staleAfter = samplingDefaultStaleAfter
+	}
+
+	entries := make([]samplingEntry, 0, len(inputs))
+	for _, input := range inputs {
+		entry := samplingEntry{info: input}
+		entry.key = samplingKey(rng.Float64(), samplingWeight(config.CategoryPriorities, input.CategoryCode))
+		lastMeasured, found := config.LastMeasured[input.URL]
+		entry.lastMeasured = lastMeasured
+		entry.stale = !found || now.Sub(lastMeasured) >= staleAfter
+		entries = append(entries, entry)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		left, right := entries[i], entries[j]
+		if left.stale != right.stale {
+			return left.stale
+		}
+		if !left.stale && !left.lastMeasured.Equal(right.lastMeasured) {
+			return left.lastMeasured.Before(right.lastMeasured)
+		}
+		return left.key > right.key
+	})
+
+	var head, tail []model.OOAPIURLInfo
+	seen := make(map[string]bool)
+	for _, entry := range entries {
+		domain := samplingDomain(entry.info.URL)
+		if config.DeduplicateDomains && seen[domain] {
+			tail = append(tail, entry.info)
+			continue
+		}
+		seen[domain] = true
+		head = append(head, entry.info)
+	}
+	return append(head, tail...)
+}
+
+// samplingWeight returns the weight of the given category.
+func samplingWeight(priorities map[string]float64, categoryCode string) float64 {
+	if weight, found := priorities[categoryCode]; found {
+		return weight
+	}
+	return 1
+}
+
+// samplingKey returns the sorting key given a uniform random number in [0, 1) and a weight.
+func samplingKey(u, weight float64) float64 {
+	if weight <= 0 {
+		return u - 2 // smaller than the key of any entry with positive weight
+	}
+	return math.Pow(u, 1/weight)
+}
+
+// samplingDomain returns the domain of the given URL without the "www." prefix or
+// the URL itself when we cannot parse it or it does not contain any hostname.
+func samplingDomain(URL string) string {
+	parsed, err := url.Parse(URL)
+	if err != nil || parsed.Hostname() == "" {
+		return URL
+	}
+	return strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www.")
+}
@@ -0,0 +1,173 @@
+package targetloading
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/ooni/probe-cli/v3/internal/model"
+)
+
+// samplingURLs returns the URLs inside the given list of URL infos.
+func samplingURLs(inputs []model.OOAPIURLInfo) (output []string) {
+	for _, input := range inputs {
+		output = append(output, input.URL)
+	}
+	return
+}
+
+func TestSampleURLs(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	inputs := []model.OOAPIURLInfo{
+		{CategoryCode: "NEWS", CountryCode: "IT", URL: "https://www.repubblica.it/"},
+		{CategoryCode: "NEWS", CountryCode: "IT", URL: "https://corriere.it/"},
+		{CategoryCode: "HUMR", CountryCode: "XX", URL: "https://www.amnesty.org/"},
+		{CategoryCode: "GRP", CountryCode: "XX", URL: "https://twitter.com/"},
+		{CategoryCode: "NEWS", CountryCode: "IT", URL: "https://repubblica.it/esteri/"},
+	}
+
+	t.Run("the same seed produces the same sampling", func(t *testing.T) {
+		config := &model.ExperimentTargetSamplingConfig{Seed: 4}
+		first := sampleURLs(inputs, config, now)
+		second := sampleURLs(inputs, config, now)
+		if diff := cmp.Diff(first, second); diff != "" {
+			t.Fatal(diff)
+		}
+		if len(first) != len(inputs) {
+			t.Fatal("unexpected number of URLs", len(first))
+		}
+	})
+
+	t.Run("stale URLs come first and the others are least recently measured first", func(t *testing.T) {
+		config := &model.ExperimentTargetSamplingConfig{
+			LastMeasured: map[string]time.Time{
+				"https://www.repubblica.it/":    now.Add(-time.Hour),
+				"https://corriere.it/":          now.Add(-2 * time.Hour),
+				"https://www.amnesty.org/":      now.Add(-30 * 24 * time.Hour),
+				"https://twitter.com/":          now.Add(-3 * time.Hour),
+				"https://repubblica.it/esteri/": now.Add(-10 * time.Minute),
+			},
+			Seed: 1,
+		}
+		expect := []string{
+			"https://www.amnesty.org/",
+			"https://twitter.com/",
+			"https://corriere.it/",
+			"https://www.repubblica.it/",
+			"https://repubblica.it/esteri/",
+		}
+		if diff := cmp.Diff(expect, samplingURLs(sampleURLs(inputs, config, now))); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("we honour the stale after setting", func(t *testing.T) {
+		config := &model.ExperimentTargetSamplingConfig{
+			LastMeasured: map[string]time.Time{
+				"https://www.repubblica.it/":    now.Add(-2 * time.Hour),
+				"https://corriere.it/":          now.Add(-2 * time.Hour),
+				"https://www.amnesty.org/":      now.Add(-2 * time.Hour),
+				"https://twitter.com/":          now.Add(-2 * time.Hour),
+				"https://repubblica.it/esteri/": now.Add(-10 * time.Minute),
+			},
+			Seed:       1,
+			StaleAfter: time.Hour,
+		}
+		output := samplingURLs(sampleURLs(inputs, config, now))
+		if output[len(output)-1] != "https://repubblica.it/esteri/" {
+			t.Fatal("expected the only fresh URL to be the last one", output)
+		}
+	})
+
+	t.Run("categories with higher priority are more likely to come first", func(t *testing.T) {
+		config := &model.ExperimentTargetSamplingConfig{
+			CategoryPriorities: map[string]float64{
+				"HUMR": 1000,
+				"GRP":  0,
+			},
+		}
+		var firstHUMR, lastGRP int
+		for seed := int64(1); seed <= 100; seed++ {
+			config.Seed = seed
+			output := sampleURLs(inputs, config, now)
+			if output[0].CategoryCode == "HUMR" {
+				firstHUMR++
+			}
+			if output[len(output)-1].CategoryCode == "GRP" {
+				lastGRP++
+			}
+		}
+		if firstHUMR < 90 {
+			t.Fatal("expected HUMR to come first most of the times", firstHUMR)
+		}
+		if lastGRP != 100 {
+			t.Fatal("expected GRP to always come last", lastGRP)
+		}
+	})
+
+	t.Run("we move duplicate domains at the end", func(t *testing.T) {
+		config := &model.ExperimentTargetSamplingConfig{
+			DeduplicateDomains: true,
+		}
+		for seed := int64(1); seed <= 20; seed++ {
+			config.Seed = seed
+			output := samplingURLs(sampleURLs(inputs, config, now))
+			last := output[len(output)-1]
+			if last != "https://www.repubblica.it/" && last != "https://repubblica.it/esteri/" {
+				t.Fatal("expected a duplicate domain at the end", output)
+			}
+			if len(output) != len(inputs) {
+				t.Fatal("we should not drop any URL", output)
+			}
+		}
+	})
+
+	t.Run("with URLs without a domain", func(t *testing.T) {
+		input := []model.OOAPIURLInfo{{URL: "\t"}, {URL: "\t"}, {URL: "https://www.example.com/"}}
+		config := &model.ExperimentTargetSamplingConfig{
+			DeduplicateDomains: true,
+			CategoryPriorities: map[string]float64{"MISC": 0},
+			Seed:               1,
+		}
+		output := samplingURLs(sampleURLs(input, config, now))
+		if output[len(output)-1] != "\t" {
+			t.Fatal("expected a duplicate URL at the end", output)
+		}
+	})
+}
+
+func TestTargetLoaderCheckInSuccessWithSampling(t *testing.T) {
+	inputs := []model.OOAPIURLInfo{{
+		CategoryCode: "NEWS",
+		CountryCode:  "IT",
+		URL:          "https://repubblica.it",
+	}, {
+		CategoryCode: "NEWS",
+		CountryCode:  "IT",
+		URL:          "https://corriere.it",
+	}}
+	il := &Loader{
+		Sampling: &model.ExperimentTargetSamplingConfig{
+			LastMeasured: map[string]time.Time{
+				"https://repubblica.it": time.Now(),
+			},
+		},
+		Session: &TargetLoaderMockableSession{
+			Output: &model.OOAPICheckInResult{
+				Tests: model.OOAPICheckInResultNettests{
+					WebConnectivity: &model.OOAPICheckInInfoWebConnectivity{
+						URLs: inputs,
+					},
+				},
+			},
+		},
+	}
+	out, err := il.loadRemoteWebConnectivity(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 2 || out[0].Input() != "https://corriere.it" {
+		t.Fatal("expected the never measured URL to come first", out)
+	}
+}
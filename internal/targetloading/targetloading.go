@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io/fs"
 	"net/url"
+	"time"
 
 	"github.com/apex/log"
 	"github.com/ooni/probe-cli/v3/internal/experimentname"
@@ -106,6 +107,16 @@ type Loader struct {
 	// per line. We will fail if any file is unreadable
 	// as well as if any file is empty.
 	SourceFiles []string
+
+	// Sampling contains optional settings for sampling the URLs
+	// returned by the check-in API. If not set, we use the URLs
+	// in the order in which the API returned them.
+	Sampling *model.ExperimentTargetSamplingConfig
+
+	// RemoteLists contains optional settings for loading
+	// signed lists of URLs from remote HTTPS servers, which
+	// we only use when StaticInputs and SourceFiles are empty.
+	RemoteLists *model.ExperimentTargetRemoteListsConfig
 }
 
 // Load attempts to load input using the specified input loader. We will
@@ -113,7 +124,7 @@ type Loader struct {
 func (il *Loader) Load(ctx context.Context) ([]model.ExperimentTarget, error) {
 	switch il.InputPolicy {
 	case model.InputOptional:
-		return il.loadOptional()
+		return il.loadOptional(ctx)
 	case model.InputOrQueryBackend:
 		return il.loadOrQueryBackend(ctx)
 	case model.InputStrictlyRequired:
@@ -137,8 +148,8 @@ func (il *Loader) loadNone() ([]model.ExperimentTarget, error) {
 }
 
 // loadOptional implements the InputOptional policy.
-func (il *Loader) loadOptional() ([]model.ExperimentTarget, error) {
-	inputs, err := il.loadLocal()
+func (il *Loader) loadOptional(ctx context.Context) ([]model.ExperimentTarget, error) {
+	inputs, err := il.loadLocal(ctx)
 	if err == nil && len(inputs) <= 0 {
 		// Implementation note: the convention for input-less experiments is that
 		// they require a single entry containing an empty input.
@@ -148,8 +159,8 @@ func (il *Loader) loadOptional() ([]model.ExperimentTarget, error) {
 }
 
 // loadStrictlyRequired implements the InputStrictlyRequired policy.
-func (il *Loader) loadStrictlyRequired(_ context.Context) ([]model.ExperimentTarget, error) {
-	inputs, err := il.loadLocal()
+func (il *Loader) loadStrictlyRequired(ctx context.Context) ([]model.ExperimentTarget, error) {
+	inputs, err := il.loadLocal(ctx)
 	if err != nil || len(inputs) > 0 {
 		return inputs, err
 	}
@@ -158,7 +169,7 @@ func (il *Loader) loadStrictlyRequired(_ context.Context) ([]model.ExperimentTar
 
 // loadOrQueryBackend implements the InputOrQueryBackend policy.
 func (il *Loader) loadOrQueryBackend(ctx context.Context) ([]model.ExperimentTarget, error) {
-	inputs, err := il.loadLocal()
+	inputs, err := il.loadLocal(ctx)
 	if err != nil || len(inputs) > 0 {
 		return inputs, err
 	}
@@ -231,6 +242,9 @@ func staticBareInputForExperiment(name string) ([]string, error) {
 		// TODO(https://github.com/ooni/probe/issues/2557): server STUNReachability
 		// inputs using richer input (aka check-in v2).
 		return stunReachabilityDefaultInput, nil
+	case "webrtcreachability":
+		// Note: webrtcreachability accepts the same URLs as stunreachability.
+		return stunReachabilityDefaultInput, nil
 	default:
 		return nil, ErrNoStaticInput
 	}
@@ -243,25 +257,30 @@ func staticInputForExperiment(name string) ([]model.ExperimentTarget, error) {
 }
 
 // loadOrStaticDefault implements the InputOrStaticDefault policy.
-func (il *Loader) loadOrStaticDefault(_ context.Context) ([]model.ExperimentTarget, error) {
-	inputs, err := il.loadLocal()
+func (il *Loader) loadOrStaticDefault(ctx context.Context) ([]model.ExperimentTarget, error) {
+	inputs, err := il.loadLocal(ctx)
 	if err != nil || len(inputs) > 0 {
 		return inputs, err
 	}
 	return staticInputForExperiment(il.ExperimentName)
 }
 
-// loadLocal loads inputs from the [*Loader] StaticInputs and SourceFiles.
-func (il *Loader) loadLocal() ([]model.ExperimentTarget, error) {
-	inputs, err := LoadStatic(il)
+// loadLocal loads inputs from the [*Loader] StaticInputs and SourceFiles. When
+// both are empty, we load inputs from the [*Loader] RemoteLists, since the user
+// explicitly providing inputs takes precedence over the configured remote lists.
+func (il *Loader) loadLocal(ctx context.Context) ([]model.ExperimentTarget, error) {
+	if len(il.StaticInputs) <= 0 && len(il.SourceFiles) <= 0 {
+		remote, err := il.loadRemoteLists(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return modelOOAPIURLInfoToModelExperimentTarget(remote), nil
+	}
+	inputs, err := loadStaticTargets(il)
 	if err != nil {
 		return nil, err
 	}
-	var targets []model.ExperimentTarget
-	for _, input := range inputs {
-		targets = append(targets, model.NewOOAPIURLInfoWithDefaultCategoryAndCountry(input))
-	}
-	return targets, nil
+	return modelOOAPIURLInfoToModelExperimentTarget(inputs), nil
 }
 
 // openFunc is the type of the function to open a file.
@@ -294,9 +313,26 @@ func readfile(filepath string, open openFunc) ([]string, error) {
 
 // LoadStatic loads inputs from the [*Loader] StaticInputs and SourceFiles.
 func LoadStatic(config *Loader) ([]string, error) {
-	inputs := append([]string{}, config.StaticInputs...)
+	targets, err := loadStaticTargets(config)
+	if err != nil {
+		return nil, err
+	}
+	inputs := []string{}
+	for _, target := range targets {
+		inputs = append(inputs, target.URL)
+	}
+	return inputs, nil
+}
+
+// loadStaticTargets loads targets from the [*Loader] StaticInputs and SourceFiles, where
+// each file contains either one URL per line or a list in the test-lists CSV format.
+func loadStaticTargets(config *Loader) ([]model.OOAPIURLInfo, error) {
+	var targets []model.OOAPIURLInfo
+	for _, input := range config.StaticInputs {
+		targets = append(targets, *model.NewOOAPIURLInfoWithDefaultCategoryAndCountry(input))
+	}
 	for _, filepath := range config.SourceFiles {
-		extra, err := readfile(filepath, fsx.OpenFile)
+		extra, err := readTargetsFile(filepath, fsx.OpenFile, config.categoryCodes())
 		if err != nil {
 			return nil, err
 		}
@@ -304,9 +340,18 @@ func LoadStatic(config *Loader) ([]string, error) {
 		if len(extra) <= 0 {
 			return nil, fmt.Errorf("%w: %s", ErrDetectedEmptyFile, filepath)
 		}
-		inputs = append(inputs, extra...)
+		targets = append(targets, extra...)
 	}
-	return inputs, nil
+	return targets, nil
+}
+
+// categoryCodes returns the category codes we should use for
+// filtering the lists using the test-lists CSV format.
+func (il *Loader) categoryCodes() []string {
+	if il.CheckInConfig == nil {
+		return nil
+	}
+	return il.CheckInConfig.WebConnectivity.CategoryCodes
 }
 
 // loadRemoteWebConnectivity loads webconnectivity inputs from a remote source.
@@ -326,7 +371,11 @@ func (il *Loader) loadRemoteWebConnectivity(ctx context.Context) ([]model.Experi
 	if reply.WebConnectivity == nil || len(reply.WebConnectivity.URLs) <= 0 {
 		return nil, ErrNoURLsReturned
 	}
-	output := modelOOAPIURLInfoToModelExperimentTarget(reply.WebConnectivity.URLs)
+	urls := reply.WebConnectivity.URLs
+	if il.Sampling != nil {
+		urls = sampleURLs(urls, il.Sampling, time.Now())
+	}
+	output := modelOOAPIURLInfoToModelExperimentTarget(urls)
 	return output, nil
 }
 
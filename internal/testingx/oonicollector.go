@@ -1,15 +1,19 @@
 package testingx
 
 import (
+	"bytes"
+	"compress/gzip"
 	"encoding/json"
 	"io"
 	"log"
 	"net/http"
+	"slices"
 	"strings"
 	"sync"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/uuid"
+	"github.com/klauspost/compress/zstd"
 	"github.com/ooni/probe-cli/v3/internal/model"
 	"github.com/ooni/probe-cli/v3/internal/must"
 	"github.com/ooni/probe-cli/v3/internal/runtimex"
@@ -29,6 +33,15 @@ type OONICollector struct {
 	// before the server actually sends it to the client.
 	EditUpdateResponse func(resp *model.OOAPICollectorUpdateResponse)
 
+	// MaxBatchSize is the OPTIONAL maximum number of measurements we accept
+	// within a batch update request. When zero or one, we do not advertise
+	// support for batch updates and we fail them with 404.
+	MaxBatchSize int
+
+	// SupportedEncodings contains the OPTIONAL request body encodings we
+	// advertise and accept. We fail requests using other encodings with 415.
+	SupportedEncodings []string
+
 	// ValidateMeasurement is an OPTIONAL callback to validate the incoming measurement
 	// beyond checks that ensure it is consistent with the original template.
 	ValidateMeasurement func(meas *model.Measurement) error
@@ -81,9 +94,25 @@ func (oc *OONICollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// make sure we support the content-encoding
+	encoding := r.Header.Get("Content-Encoding")
+	if encoding != "" && !slices.Contains(oc.SupportedEncodings, encoding) {
+		log.Printf("OONICollector: unsupported content-encoding: %s", encoding)
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+
 	// read the raw request body or panic if we cannot read it
 	body := runtimex.Try1(io.ReadAll(r.Body))
 
+	// decode the request body
+	body, err := decodeBody(encoding, body)
+	if err != nil {
+		log.Printf("OONICollector: cannot decode body: %s", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
 	log.Printf("OONICollector: URLPath %+v", r.URL.Path)
 	log.Printf("OONICollector: request body %s", string(body))
 
@@ -94,6 +123,13 @@ func (oc *OONICollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// handle the case where the user wants to append several measurements
+	if strings.HasSuffix(r.URL.Path, "/batch") {
+		log.Printf("OONICollector: batch updating existing report")
+		oc.batchUpdateReport(w, r.URL.Path, body)
+		return
+	}
+
 	// handle the case where the user wants to append to an existing report
 	log.Printf("OONICollector: updating existing report")
 	oc.updateReport(w, r.URL.Path, body)
@@ -139,6 +175,10 @@ func (oc *OONICollector) openReport(w http.ResponseWriter, body []byte) {
 		SupportedFormats: []string{
 			model.OOAPIReportDefaultFormat,
 		},
+		SupportedEncodings: oc.SupportedEncodings,
+	}
+	if oc.MaxBatchSize > 1 {
+		response.MaxBatchSize = oc.MaxBatchSize
 	}
 
 	// optionally allow the user to modify the response
@@ -162,48 +202,115 @@ func (oc *OONICollector) updateReport(w http.ResponseWriter, urlpath string, bod
 	// get the report ID
 	reportID := strings.TrimPrefix(urlpath, "/report/")
 
-	// obtain the report template
-	oc.mu.Lock()
-	template := oc.reports[reportID]
-	oc.mu.Unlock()
+	// make sure we can parse the incoming request
+	var request model.OOAPICollectorUpdateRequest
+	if err := json.Unmarshal(body, &request); err != nil {
+		log.Printf("OONICollector: cannot unmarshal JSON: %s", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
 
-	// handle the case of missing template
-	if template == nil {
-		log.Printf("OONICollector: the report does not exist: %s", reportID)
+	// make sure the measurement is valid
+	if !oc.acceptMeasurement(reportID, request.Format, request.Content) {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
+	// create the response
+	response := &model.OOAPICollectorUpdateResponse{
+		MeasurementUID: uuid.Must(uuid.NewRandom()).String(),
+	}
+
+	// optionally allow the user to modify the response
+	if oc.EditUpdateResponse != nil {
+		oc.EditUpdateResponse(response)
+	}
+
+	// set the content-type header
+	w.Header().Set("Content-Type", "application/json")
+
+	// serialize and send
+	_, _ = w.Write(must.MarshalJSON(response))
+}
+
+// batchUpdateReport handles appending several measurements to an existing OONI report.
+func (oc *OONICollector) batchUpdateReport(w http.ResponseWriter, urlpath string, body []byte) {
+	// make sure we support batch updates
+	if oc.MaxBatchSize <= 1 {
+		log.Printf("OONICollector: batch updates are not supported")
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	// get the report ID
+	reportID := strings.TrimSuffix(strings.TrimPrefix(urlpath, "/report/"), "/batch")
+
 	// make sure we can parse the incoming request
-	var request model.OOAPICollectorUpdateRequest
+	var request model.OOAPICollectorBatchUpdateRequest
 	if err := json.Unmarshal(body, &request); err != nil {
 		log.Printf("OONICollector: cannot unmarshal JSON: %s", err.Error())
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
-	// make sure the measurement is encoded as JSON
-	if request.Format != "json" {
-		log.Printf("OONICollector: invalid request format: %s", request.Format)
+	// make sure the batch size is acceptable
+	if len(request.Content) <= 0 || len(request.Content) > oc.MaxBatchSize {
+		log.Printf("OONICollector: invalid batch size: %d", len(request.Content))
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
+	// make sure all the measurements are valid
+	response := &model.OOAPICollectorBatchUpdateResponse{}
+	for _, content := range request.Content {
+		if !oc.acceptMeasurement(reportID, request.Format, content) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		response.MeasurementUIDs = append(response.MeasurementUIDs, uuid.Must(uuid.NewRandom()).String())
+	}
+
+	// set the content-type header
+	w.Header().Set("Content-Type", "application/json")
+
+	// serialize and send
+	_, _ = w.Write(must.MarshalJSON(response))
+}
+
+// acceptMeasurement returns whether the given measurement content is valid
+// for the given report ID and format, logging the reason on failure.
+func (oc *OONICollector) acceptMeasurement(reportID, format string, content any) bool {
+	// obtain the report template
+	oc.mu.Lock()
+	template := oc.reports[reportID]
+	oc.mu.Unlock()
+
+	// handle the case of missing template
+	if template == nil {
+		log.Printf("OONICollector: the report does not exist: %s", reportID)
+		return false
+	}
+
+	// make sure the measurement is encoded as JSON
+	if format != "json" {
+		log.Printf("OONICollector: invalid request format: %s", format)
+		return false
+	}
+
 	// make sure we can parse the content
 	//
 	// note: we unmarshaled into a map[string]any so we need to marshal
 	// and unmarshal again to get a measurement structure
 	var measurement model.Measurement
-	if err := json.Unmarshal(must.MarshalJSON(request.Content), &measurement); err != nil {
+	if err := json.Unmarshal(must.MarshalJSON(content), &measurement); err != nil {
 		log.Printf("OONICollector: cannot unmarshal JSON: %s", err.Error())
-		w.WriteHeader(http.StatusBadRequest)
-		return
+		return false
 	}
 
 	// make sure all the required fields match
 	mt := &model.OOAPIReportTemplate{
 		DataFormatVersion: measurement.DataFormatVersion,
-		Format:            request.Format,
+		Format:            format,
 		ProbeASN:          measurement.ProbeASN,
 		ProbeCC:           measurement.ProbeCC,
 		SoftwareName:      measurement.SoftwareName,
@@ -214,32 +321,36 @@ func (oc *OONICollector) updateReport(w http.ResponseWriter, urlpath string, bod
 	}
 	if diff := cmp.Diff(template, mt); diff != "" {
 		log.Printf("OONICollector: measurement differs from template %s", diff)
-		w.WriteHeader(http.StatusBadRequest)
-		return
+		return false
 	}
 
 	// give the user a chance to validate the measurement
 	if oc.ValidateMeasurement != nil {
 		if err := oc.ValidateMeasurement(&measurement); err != nil {
 			log.Printf("OONICollector: invalid measurement: %s", err.Error())
-			w.WriteHeader(http.StatusBadRequest)
-			return
+			return false
 		}
 	}
+	return true
+}
 
-	// create the response
-	response := &model.OOAPICollectorUpdateResponse{
-		MeasurementUID: uuid.Must(uuid.NewRandom()).String(),
-	}
-
-	// optionally allow the user to modify the response
-	if oc.EditUpdateResponse != nil {
-		oc.EditUpdateResponse(response)
+// decodeBody decodes a request body using the given content-encoding.
+func decodeBody(encoding string, body []byte) ([]byte, error) {
+	switch encoding {
+	case "gzip":
+		reader, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		return io.ReadAll(reader)
+	case "zstd":
+		decoder, err := zstd.NewReader(nil)
+		if err != nil {
+			return nil, err
+		}
+		defer decoder.Close()
+		return decoder.DecodeAll(body, nil)
+	default:
+		return body, nil
 	}
-
-	// set the content-type header
-	w.Header().Set("Content-Type", "application/json")
-
-	// serialize and send
-	_, _ = w.Write(must.MarshalJSON(response))
 }
@@ -2,6 +2,7 @@ package testingx
 
 import (
 	"bytes"
+	"compress/gzip"
 	"errors"
 	"io"
 	"net/http"
@@ -888,4 +889,181 @@ func TestOONICollector(t *testing.T) {
 			t.Fatal("the measurement UID is unexpectedly empty")
 		}
 	})
+
+	// This is a convenience function to create a measurement matching a template
+	newmeasurement := func(template *model.OOAPIReportTemplate, reportID string) *model.Measurement {
+		return &model.Measurement{
+			DataFormatVersion:    template.DataFormatVersion,
+			MeasurementStartTime: template.TestStartTime,
+			ProbeASN:             template.ProbeASN,
+			ProbeCC:              template.ProbeCC,
+			ReportID:             reportID,
+			SoftwareName:         template.SoftwareName,
+			SoftwareVersion:      template.SoftwareVersion,
+			TestKeys:             nil,
+			TestName:             template.TestName,
+			TestStartTime:        template.TestStartTime,
+			TestVersion:          template.TestVersion,
+		}
+	}
+
+	// This is a convenience function to POST a body and return the response
+	post := func(t *testing.T, stringURL, urlpath, encoding string, body []byte) (int, []byte) {
+		URL := runtimex.Try1(url.Parse(stringURL))
+		URL.Path = urlpath
+		req := runtimex.Try1(http.NewRequest("POST", URL.String(), bytes.NewReader(body)))
+		req.Header.Set("Content-Type", "application/json")
+		if encoding != "" {
+			req.Header.Set("Content-Encoding", encoding)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		return resp.StatusCode, runtimex.Try1(io.ReadAll(resp.Body))
+	}
+
+	t.Run("common: when the Content-Encoding is not supported", func(t *testing.T) {
+		collector := &OONICollector{SupportedEncodings: []string{"gzip"}}
+		srv := MustNewHTTPServer(collector)
+		defer srv.Close()
+
+		status, _ := post(t, srv.URL, "/report", "zstd", []byte(`{}`))
+		if status != http.StatusUnsupportedMediaType {
+			t.Fatal("unexpected status code", status)
+		}
+	})
+
+	t.Run("common: when we cannot decode the body", func(t *testing.T) {
+		collector := &OONICollector{SupportedEncodings: []string{"gzip"}}
+		srv := MustNewHTTPServer(collector)
+		defer srv.Close()
+
+		status, _ := post(t, srv.URL, "/report", "gzip", []byte(`{}`))
+		if status != http.StatusBadRequest {
+			t.Fatal("unexpected status code", status)
+		}
+	})
+
+	t.Run("submit: we can submit a gzip compressed measurement", func(t *testing.T) {
+		collector := &OONICollector{SupportedEncodings: []string{"gzip"}}
+		srv := MustNewHTTPServer(collector)
+		defer srv.Close()
+
+		template, reportInfo := openreport(t, srv.URL)
+		if diff := cmp.Diff([]string{"gzip"}, reportInfo.SupportedEncodings); diff != "" {
+			t.Fatal(diff)
+		}
+
+		request := &model.OOAPICollectorUpdateRequest{
+			Format:  "json",
+			Content: newmeasurement(template, reportInfo.ReportID),
+		}
+		var buff bytes.Buffer
+		writer := gzip.NewWriter(&buff)
+		runtimex.Try1(writer.Write(must.MarshalJSON(request)))
+		runtimex.Try0(writer.Close())
+
+		status, _ := post(t, srv.URL, "/report/"+reportInfo.ReportID, "gzip", buff.Bytes())
+		if status != http.StatusOK {
+			t.Fatal("unexpected status code", status)
+		}
+	})
+
+	t.Run("batch: when batch updates are not supported", func(t *testing.T) {
+		collector := &OONICollector{}
+		srv := MustNewHTTPServer(collector)
+		defer srv.Close()
+
+		_, reportInfo := openreport(t, srv.URL)
+		if reportInfo.MaxBatchSize != 0 {
+			t.Fatal("unexpected MaxBatchSize", reportInfo.MaxBatchSize)
+		}
+
+		status, _ := post(t, srv.URL, "/report/"+reportInfo.ReportID+"/batch", "", []byte(`{}`))
+		if status != http.StatusNotFound {
+			t.Fatal("unexpected status code", status)
+		}
+	})
+
+	t.Run("batch: when the batch is too large", func(t *testing.T) {
+		collector := &OONICollector{MaxBatchSize: 2}
+		srv := MustNewHTTPServer(collector)
+		defer srv.Close()
+
+		template, reportInfo := openreport(t, srv.URL)
+		measurement := newmeasurement(template, reportInfo.ReportID)
+		request := &model.OOAPICollectorBatchUpdateRequest{
+			Format:  "json",
+			Content: []any{measurement, measurement, measurement},
+		}
+
+		status, _ := post(t, srv.URL, "/report/"+reportInfo.ReportID+"/batch", "", must.MarshalJSON(request))
+		if status != http.StatusBadRequest {
+			t.Fatal("unexpected status code", status)
+		}
+	})
+
+	t.Run("batch: when a measurement does not match the template", func(t *testing.T) {
+		collector := &OONICollector{MaxBatchSize: 2}
+		srv := MustNewHTTPServer(collector)
+		defer srv.Close()
+
+		template, reportInfo := openreport(t, srv.URL)
+		measurement := newmeasurement(template, reportInfo.ReportID)
+		request := &model.OOAPICollectorBatchUpdateRequest{
+			Format:  "json",
+			Content: []any{measurement, &model.Measurement{}},
+		}
+
+		status, _ := post(t, srv.URL, "/report/"+reportInfo.ReportID+"/batch", "", must.MarshalJSON(request))
+		if status != http.StatusBadRequest {
+			t.Fatal("unexpected status code", status)
+		}
+	})
+
+	t.Run("batch: we get measurement IDs back", func(t *testing.T) {
+		var (
+			count int
+			mu    sync.Mutex
+		)
+		collector := &OONICollector{
+			MaxBatchSize: 2,
+			ValidateMeasurement: func(meas *model.Measurement) error {
+				mu.Lock()
+				count++
+				mu.Unlock()
+				return nil
+			},
+		}
+		srv := MustNewHTTPServer(collector)
+		defer srv.Close()
+
+		template, reportInfo := openreport(t, srv.URL)
+		if reportInfo.MaxBatchSize != 2 {
+			t.Fatal("unexpected MaxBatchSize", reportInfo.MaxBatchSize)
+		}
+		measurement := newmeasurement(template, reportInfo.ReportID)
+		request := &model.OOAPICollectorBatchUpdateRequest{
+			Format:  "json",
+			Content: []any{measurement, measurement},
+		}
+
+		status, rawrespbody := post(t, srv.URL, "/report/"+reportInfo.ReportID+"/batch", "", must.MarshalJSON(request))
+		if status != http.StatusOK {
+			t.Fatal("unexpected status code", status)
+		}
+		var response model.OOAPICollectorBatchUpdateResponse
+		must.UnmarshalJSON(rawrespbody, &response)
+		if len(response.MeasurementUIDs) != 2 {
+			t.Fatal("unexpected number of measurement UIDs")
+		}
+
+		defer mu.Unlock()
+		mu.Lock()
+		if count != 2 {
+			t.Fatal("unexpected number of validated measurements", count)
+		}
+	})
 }
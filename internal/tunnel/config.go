@@ -52,6 +52,17 @@ type Config struct {
 	// executing. When not set, we execute `tor`.
 	TorBinary string
 
+	// TorColdStart OPTIONALLY disables reusing the tor directory
+	// information cached inside TunnelDir by previous sessions, thus
+	// forcing tor to bootstrap from scratch. By default, we reuse
+	// the cached information, which makes the bootstrap faster.
+	TorColdStart bool
+
+	// OnBootstrapEvent is the OPTIONAL callback invoked for each
+	// bootstrap progress event. We may invoke this callback from
+	// a background goroutine while the tunnel is bootstrapping.
+	OnBootstrapEvent func(ev BootstrapEvent)
+
 	// testExecabsLookPath allows us to mock exeabs.LookPath
 	testExecabsLookPath func(name string) (string, error)
 
@@ -14,6 +14,7 @@ import (
 type fakeTunnel struct {
 	addr          net.Addr
 	bootstrapTime time.Duration
+	event         BootstrapEvent
 	listener      net.Listener
 	once          sync.Once
 }
@@ -23,6 +24,15 @@ func (t *fakeTunnel) BootstrapTime() time.Duration {
 	return t.bootstrapTime
 }
 
+// Health implements Tunnel.Health.
+func (t *fakeTunnel) Health() Health {
+	return Health{
+		Name:            "fake",
+		BootstrapTime:   t.bootstrapTime,
+		BootstrapEvents: []BootstrapEvent{t.event},
+	}
+}
+
 // Stop implements Tunnel.Stop.
 func (t *fakeTunnel) Stop() {
 	// Implementation note: closing the listener causes
@@ -76,10 +86,18 @@ func fakeStart(ctx context.Context, config *Config) (Tunnel, DebugInfo, error) {
 		return nil, debugInfo, err
 	}
 	bootstrapTime := time.Since(start)
+	ev := BootstrapEvent{
+		Elapsed:  bootstrapTime,
+		Progress: 100,
+		Tag:      "done",
+		Summary:  "Done",
+	}
+	config.emitBootstrapEvent(ev)
 	go server.Serve(listener)
 	return &fakeTunnel{
 		addr:          listener.Addr(),
 		bootstrapTime: bootstrapTime,
+		event:         ev,
 		listener:      listener,
 	}, debugInfo, nil
 }
@@ -40,5 +40,8 @@ func TestFakeStartStop(t *testing.T) {
 	if tunnel.BootstrapTime() <= 0 {
 		t.Fatal("expected positive bootstrap time here")
 	}
+	if health := tunnel.Health(); health.Name != "fake" || len(health.BootstrapEvents) != 1 {
+		t.Fatal("unexpected health", health)
+	}
 	tunnel.Stop()
 }
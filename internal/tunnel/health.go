@@ -0,0 +1,149 @@
+package tunnel
+
+//
+// health: tunnel health metrics and persistent tunnel state
+//
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// BootstrapEvent is a tunnel bootstrap progress event.
+type BootstrapEvent struct {
+	// Elapsed is the time elapsed since we started
+	// bootstrapping when we observed this event.
+	Elapsed time.Duration
+
+	// Progress is the progress (between 0 and 100).
+	Progress int64
+
+	// Tag is the machine readable description of the bootstrap state.
+	Tag string
+
+	// Summary is the human readable summary.
+	Summary string
+}
+
+// Health contains information about the health of a tunnel. We collect
+// this information while starting the tunnel, therefore it is a snapshot
+// of the tunnel state right after the bootstrap.
+type Health struct {
+	// Name is the tunnel name.
+	Name string
+
+	// BootstrapTime is the time it required to bootstrap.
+	BootstrapTime time.Duration
+
+	// BootstrapEvents contains the bootstrap progress events.
+	BootstrapEvents []BootstrapEvent
+
+	// WarmStart indicates whether the tunnel bootstrapped reusing
+	// state cached inside the tunnel directory by a previous session.
+	WarmStart bool
+
+	// Circuits is the number of built circuits (tor only).
+	Circuits int64
+
+	// EgressRelay is the fingerprint and nickname of the relay
+	// through which the traffic exits the tunnel (tor only).
+	EgressRelay string
+
+	// EgressAddress is the IP address of the relay through
+	// which the traffic exits the tunnel (tor only).
+	EgressAddress string
+}
+
+// State is the tunnel state we persist inside the tunnel directory
+// such that following sessions could inspect previous bootstraps.
+type State struct {
+	// Name is the tunnel name.
+	Name string
+
+	// Starts is the number of times we successfully started the tunnel.
+	Starts int64
+
+	// LastStart is the last time we successfully started the tunnel.
+	LastStart time.Time
+
+	// LastBootstrapTime is the time required by the last bootstrap.
+	LastBootstrapTime time.Duration
+
+	// LastWarmStart indicates whether the last bootstrap was a warm start.
+	LastWarmStart bool
+
+	// LastEgressAddress is the last egress address, if known.
+	LastEgressAddress string
+}
+
+// stateFileName is the name of the file containing the tunnel state.
+const stateFileName = "state.json"
+
+// stateTunnelNames contains the names of the tunnels persisting
+// their state, which is also the name of their directory.
+var stateTunnelNames = []string{"psiphon", "tor"}
+
+// ReadState reads the state of the tunnel with the given name from
+// the given tunnel directory (i.e., the Config.TunnelDir field).
+func ReadState(tunnelDir, name string) (*State, error) {
+	data, err := os.ReadFile(filepath.Join(tunnelDir, name, stateFileName)) // #nosec G304 - this is working as intended
+	if err != nil {
+		return nil, err
+	}
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// ReadStates returns the state of all the tunnels that persisted
+// their state inside the given tunnel directory, skipping the tunnels
+// that have never been started or whose state we cannot read.
+func ReadStates(tunnelDir string) (out []*State) {
+	for _, name := range stateTunnelNames {
+		if state, err := ReadState(tunnelDir, name); err == nil {
+			out = append(out, state)
+		}
+	}
+	return
+}
+
+// writeState updates the state of the tunnel inside the tunnel directory
+// after we successfully started the tunnel using the given health info.
+func writeState(tunnelDir string, health *Health, now time.Time) error {
+	state, err := ReadState(tunnelDir, health.Name)
+	if err != nil {
+		state = &State{Name: health.Name}
+	}
+	state.Starts++
+	state.LastStart = now
+	state.LastBootstrapTime = health.BootstrapTime
+	state.LastWarmStart = health.WarmStart
+	state.LastEgressAddress = health.EgressAddress
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(tunnelDir, health.Name)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, stateFileName), data, 0600)
+}
+
+// writeStateOrWarn is like writeState but emits a warning on failure.
+func writeStateOrWarn(config *Config, health *Health) {
+	if err := writeState(config.TunnelDir, health, time.Now()); err != nil {
+		config.logger().Warnf("tunnel: cannot write %s state: %s", health.Name, err.Error())
+	}
+}
+
+// emitBootstrapEvent calls the OnBootstrapEvent callback, if set.
+func (c *Config) emitBootstrapEvent(ev BootstrapEvent) {
+	if c.OnBootstrapEvent != nil {
+		c.OnBootstrapEvent(ev)
+	}
+}
@@ -0,0 +1,89 @@
+package tunnel
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWriteAndReadState(t *testing.T) {
+	tunnelDir := t.TempDir()
+	if states := ReadStates(tunnelDir); len(states) != 0 {
+		t.Fatal("expected no states", states)
+	}
+
+	first := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	health := &Health{
+		Name:          "tor",
+		BootstrapTime: 10 * time.Second,
+		EgressAddress: "130.192.91.211",
+	}
+	if err := writeState(tunnelDir, health, first); err != nil {
+		t.Fatal(err)
+	}
+
+	second := first.Add(time.Hour)
+	health = &Health{
+		Name:          "tor",
+		BootstrapTime: time.Second,
+		WarmStart:     true,
+	}
+	if err := writeState(tunnelDir, health, second); err != nil {
+		t.Fatal(err)
+	}
+
+	expect := &State{
+		Name:              "tor",
+		Starts:            2,
+		LastStart:         second,
+		LastBootstrapTime: time.Second,
+		LastWarmStart:     true,
+		LastEgressAddress: "",
+	}
+	state, err := ReadState(tunnelDir, "tor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(expect, state); diff != "" {
+		t.Fatal(diff)
+	}
+	if diff := cmp.Diff([]*State{expect}, ReadStates(tunnelDir)); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+func TestReadStateWithInvalidState(t *testing.T) {
+	tunnelDir := t.TempDir()
+	dir := filepath.Join(tunnelDir, "psiphon")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, stateFileName), []byte(`{`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	state, err := ReadState(tunnelDir, "psiphon")
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if state != nil {
+		t.Fatal("expected nil state")
+	}
+	if states := ReadStates(tunnelDir); len(states) != 0 {
+		t.Fatal("expected no states", states)
+	}
+
+	// make sure we start over when the state is invalid
+	if err := writeState(tunnelDir, &Health{Name: "psiphon"}, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	state, err = ReadState(tunnelDir, "psiphon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Starts != 1 {
+		t.Fatal("unexpected number of starts", state.Starts)
+	}
+}
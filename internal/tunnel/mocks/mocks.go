@@ -16,6 +16,9 @@ type Tunnel struct {
 	// MockSOCKS5ProxyURL allows to mock Socks5ProxyURL.
 	MockSOCKS5ProxyURL func() *url.URL
 
+	// MockHealth allows to mock Health.
+	MockHealth func() tunnel.Health
+
 	// MockStop allows to mock Stop.
 	MockStop func()
 }
@@ -29,6 +32,11 @@ func (t *Tunnel) SOCKS5ProxyURL() *url.URL {
 	return t.MockSOCKS5ProxyURL()
 }
 
+// Health implements Tunnel.Health.
+func (t *Tunnel) Health() tunnel.Health {
+	return t.MockHealth()
+}
+
 // Stop implements Tunnel.Stop.
 func (t *Tunnel) Stop() {
 	t.MockStop()
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/ooni/probe-cli/v3/internal/tunnel"
 )
 
 func TestTunnel(t *testing.T) {
@@ -45,6 +46,18 @@ func TestTunnel(t *testing.T) {
 		}
 	})
 
+	t.Run("Health", func(t *testing.T) {
+		expected := tunnel.Health{Name: "tor", Circuits: 4}
+		tun := &Tunnel{
+			MockHealth: func() tunnel.Health {
+				return expected
+			},
+		}
+		if diff := cmp.Diff(expected, tun.Health()); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+
 	t.Run("Stop", func(t *testing.T) {
 		called := &atomic.Int64{}
 		tun := &Tunnel{
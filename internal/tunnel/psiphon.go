@@ -22,6 +22,9 @@ type psiphonTunnel struct {
 	// bootstrapTime is the bootstrapTime of the bootstrap
 	bootstrapTime time.Duration
 
+	// health contains the health info collected at bootstrap
+	health Health
+
 	// tunnel is the underlying psiphon tunnel
 	tunnel psiphonfeat.Tunnel
 }
@@ -58,15 +61,35 @@ func psiphonStart(ctx context.Context, config *Config) (Tunnel, DebugInfo, error
 	if err != nil {
 		return nil, debugInfo, err
 	}
+	// Psiphon caches the server entries inside the working directory
+	// and reuses them across sessions, so we consider a bootstrap to be
+	// a warm start when we have successfully started it before.
+	_, err = ReadState(config.TunnelDir, config.Name)
+	warmStart := err == nil
 	start := time.Now()
 	tunnel, err := mockableStartPsiphon(ctx, configJSON, workdir)
 	if err != nil {
 		return nil, debugInfo, err
 	}
 	stop := time.Now()
+	ev := BootstrapEvent{
+		Elapsed:  stop.Sub(start),
+		Progress: 100,
+		Tag:      "done",
+		Summary:  "Done",
+	}
+	config.emitBootstrapEvent(ev)
+	health := Health{
+		Name:            config.Name,
+		BootstrapTime:   stop.Sub(start),
+		BootstrapEvents: []BootstrapEvent{ev},
+		WarmStart:       warmStart,
+	}
+	writeStateOrWarn(config, &health)
 	return &psiphonTunnel{
 		tunnel:        tunnel,
-		bootstrapTime: stop.Sub(start),
+		bootstrapTime: health.BootstrapTime,
+		health:        health,
 	}, debugInfo, nil
 }
 
@@ -88,3 +111,8 @@ func (t *psiphonTunnel) SOCKS5ProxyURL() *url.URL {
 func (t *psiphonTunnel) BootstrapTime() time.Duration {
 	return t.bootstrapTime
 }
+
+// Health returns the tunnel health
+func (t *psiphonTunnel) Health() Health {
+	return t.health
+}
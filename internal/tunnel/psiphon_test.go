@@ -101,3 +101,56 @@ func TestPsiphonStartFailure(t *testing.T) {
 		t.Fatal("expected nil tunnel here")
 	}
 }
+
+// fakePsiphonTunnel is a fake psiphonfeat.Tunnel.
+type fakePsiphonTunnel struct{}
+
+func (*fakePsiphonTunnel) Stop() {}
+
+func (*fakePsiphonTunnel) GetSOCKSProxyPort() int {
+	return 5555
+}
+
+func TestPsiphonStartSuccess(t *testing.T) {
+	oldStartPsiphon := mockableStartPsiphon
+	defer func() {
+		mockableStartPsiphon = oldStartPsiphon
+	}()
+	mockableStartPsiphon = func(ctx context.Context, config []byte,
+		workdir string) (psiphonfeat.Tunnel, error) {
+		return &fakePsiphonTunnel{}, nil
+	}
+	tunnelDir := t.TempDir()
+	for _, expectWarmStart := range []bool{false, true} {
+		var events []BootstrapEvent
+		tunnel, _, err := psiphonStart(context.Background(), &Config{
+			Name:      "psiphon",
+			Session:   &MockableSession{Result: []byte(`{}`)},
+			TunnelDir: tunnelDir,
+			OnBootstrapEvent: func(ev BootstrapEvent) {
+				events = append(events, ev)
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tunnel.SOCKS5ProxyURL().String() != "socks5://127.0.0.1:5555" {
+			t.Fatal("invalid socks5 proxy URL")
+		}
+		health := tunnel.Health()
+		if health.Name != "psiphon" || health.WarmStart != expectWarmStart {
+			t.Fatal("invalid health", health)
+		}
+		if len(events) != 1 || events[0].Progress != 100 || len(health.BootstrapEvents) != 1 {
+			t.Fatal("invalid bootstrap events", events, health.BootstrapEvents)
+		}
+		tunnel.Stop()
+	}
+	state, err := ReadState(tunnelDir, "psiphon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Starts != 2 || !state.LastWarmStart {
+		t.Fatal("invalid state", state)
+	}
+}
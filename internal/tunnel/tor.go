@@ -27,6 +27,9 @@ type torTunnel struct {
 	// bootstrapTime is the duration of the bootstrap
 	bootstrapTime time.Duration
 
+	// health contains the health info collected at bootstrap
+	health Health
+
 	// instance is the running tor instance
 	instance torProcess
 
@@ -44,6 +47,11 @@ func (tt *torTunnel) SOCKS5ProxyURL() *url.URL {
 	return tt.proxy
 }
 
+// Health returns the tunnel health
+func (tt *torTunnel) Health() Health {
+	return tt.health
+}
+
 // Stop stops the Tor tunnel
 func (tt *torTunnel) Stop() {
 	_ = tt.instance.Close()
@@ -78,6 +86,10 @@ func torStart(ctx context.Context, config *Config) (Tunnel, DebugInfo, error) {
 	logfile := filepath.Join(stateDir, "tor.log")
 	debugInfo.LogFilePath = logfile
 	maybeCleanupTunnelDir(stateDir, logfile)
+	if config.TorColdStart {
+		torRemoveCache(stateDir)
+	}
+	warmStart := torHasFreshCache(stateDir, time.Now())
 	extraArgs := append([]string{}, config.TorArgs...)
 	extraArgs = append(extraArgs, "Log")
 	extraArgs = append(extraArgs, "notice stderr")
@@ -98,7 +110,11 @@ func torStart(ctx context.Context, config *Config) (Tunnel, DebugInfo, error) {
 	debugInfo.Version = protoInfo.TorVersion
 	instance.StopProcessOnClose = true
 	start := time.Now()
-	if err := config.torEnableNetwork(ctx, instance, true); err != nil {
+	watcher := newTorBootstrapWatcher(config, logfile, start)
+	watcher.start()
+	err = config.torEnableNetwork(ctx, instance, true)
+	events := watcher.stop()
+	if err != nil {
 		_ = instance.Close()
 		return nil, debugInfo, err
 	}
@@ -118,8 +134,20 @@ func torStart(ctx context.Context, config *Config) (Tunnel, DebugInfo, error) {
 		_ = instance.Close()
 		return nil, debugInfo, ErrTorReturnedUnsupportedProxy
 	}
+	health := Health{
+		Name:            "tor",
+		BootstrapTime:   stop.Sub(start),
+		BootstrapEvents: events,
+		WarmStart:       warmStart,
+	}
+	health.Circuits, health.EgressRelay = torCircuitStatus(config, instance)
+	if health.EgressRelay != "" {
+		health.EgressAddress = torRelayAddress(config, instance, health.EgressRelay)
+	}
+	writeStateOrWarn(config, &health)
 	return &torTunnel{
-		bootstrapTime: stop.Sub(start),
+		bootstrapTime: health.BootstrapTime,
+		health:        health,
 		instance:      instance,
 		proxy:         &url.URL{Scheme: "socks5", Host: proxyAddress},
 	}, debugInfo, nil
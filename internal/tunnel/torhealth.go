@@ -0,0 +1,185 @@
+package tunnel
+
+//
+// torhealth: collecting tor health metrics
+//
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cretz/bine/tor"
+	"github.com/ooni/probe-cli/v3/internal/torlogs"
+)
+
+// torCacheMaxAge is the maximum age of the cached consensus
+// for us to consider a tor bootstrap a warm start.
+const torCacheMaxAge = 24 * time.Hour
+
+// torCachedConsensusFiles contains the files in which tor caches the consensus.
+var torCachedConsensusFiles = []string{"cached-microdesc-consensus", "cached-consensus"}
+
+// torHasFreshCache returns whether the tor state directory contains
+// a recent enough consensus that tor could reuse to bootstrap.
+func torHasFreshCache(stateDir string, now time.Time) bool {
+	for _, name := range torCachedConsensusFiles {
+		stat, err := os.Stat(filepath.Join(stateDir, name))
+		if err == nil && now.Sub(stat.ModTime()) < torCacheMaxAge {
+			return true
+		}
+	}
+	return false
+}
+
+// torRemoveCache removes the directory information cached
+// by tor inside the given state directory.
+func torRemoveCache(stateDir string) {
+	removeWithGlob(filepath.Join(stateDir, "cached-*"))
+}
+
+// torBootstrapWatcherInterval is the interval between
+// successive reads of the tor logs while bootstrapping.
+const torBootstrapWatcherInterval = 250 * time.Millisecond
+
+// torBootstrapWatcher reads the tor logs while tor is bootstrapping
+// and emits the corresponding bootstrap events.
+type torBootstrapWatcher struct {
+	begin   time.Time
+	config  *Config
+	done    chan any
+	events  []BootstrapEvent
+	logfile string
+	seen    int
+	wg      sync.WaitGroup
+}
+
+// newTorBootstrapWatcher creates a new torBootstrapWatcher.
+func newTorBootstrapWatcher(config *Config, logfile string, begin time.Time) *torBootstrapWatcher {
+	return &torBootstrapWatcher{
+		begin:   begin,
+		config:  config,
+		done:    make(chan any),
+		logfile: logfile,
+	}
+}
+
+// start starts reading the logs in a background goroutine.
+func (w *torBootstrapWatcher) start() {
+	w.wg.Add(1)
+	go w.loop()
+}
+
+// loop periodically reads the logs until we call stop.
+func (w *torBootstrapWatcher) loop() {
+	defer w.wg.Done()
+	ticker := time.NewTicker(torBootstrapWatcherInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+			w.poll()
+		}
+	}
+}
+
+// stop stops the background goroutine, reads the logs one
+// last time, and returns all the observed bootstrap events.
+func (w *torBootstrapWatcher) stop() []BootstrapEvent {
+	close(w.done)
+	w.wg.Wait()
+	w.poll()
+	return w.events
+}
+
+// poll reads the bootstrap logs we have not seen yet.
+func (w *torBootstrapWatcher) poll() {
+	logs, err := torlogs.ReadBootstrapLogs(w.logfile)
+	if err != nil || len(logs) <= w.seen {
+		return
+	}
+	for _, line := range logs[w.seen:] {
+		bi, err := torlogs.ParseBootstrapLogLine(line)
+		if err != nil {
+			continue
+		}
+		ev := BootstrapEvent{
+			Elapsed:  time.Since(w.begin),
+			Progress: bi.Progress,
+			Tag:      bi.Tag,
+			Summary:  bi.Summary,
+		}
+		w.events = append(w.events, ev)
+		w.config.emitBootstrapEvent(ev)
+	}
+	w.seen = len(logs)
+}
+
+// torCircuitStatus returns the number of built circuits and the exit relay
+// of the most recent general purpose built circuit using the control port.
+func torCircuitStatus(config *Config, instance *tor.Tor) (circuits int64, relay string) {
+	info, err := config.torGetInfo(instance.Control, "circuit-status")
+	if err != nil {
+		config.logger().Warnf("tunnel: cannot get tor circuit status: %s", err.Error())
+		return
+	}
+	if len(info) != 1 || info[0].Key != "circuit-status" {
+		return
+	}
+	// Each line is like `<ID> BUILT <PATH> [<KEY>=<VALUE> ...]` where PATH
+	// is a comma separated list of `$<FINGERPRINT>~<NICKNAME>` relays.
+	for _, line := range strings.Split(info[0].Val, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || fields[1] != "BUILT" {
+			continue
+		}
+		circuits++
+		if torCircuitPurpose(fields[3:]) == "GENERAL" {
+			path := strings.Split(fields[2], ",")
+			relay = path[len(path)-1]
+		}
+	}
+	return
+}
+
+// torCircuitPurpose returns the PURPOSE of a circuit given its
+// `<KEY>=<VALUE>` fields, or the empty string if not present.
+func torCircuitPurpose(fields []string) string {
+	for _, field := range fields {
+		if value, found := strings.CutPrefix(field, "PURPOSE="); found {
+			return value
+		}
+	}
+	return ""
+}
+
+// torRelayAddress returns the IP address of the given relay, which is
+// formatted as `$<FINGERPRINT>~<NICKNAME>`, using the control port.
+func torRelayAddress(config *Config, instance *tor.Tor, relay string) string {
+	fingerprint, _, _ := strings.Cut(strings.TrimPrefix(relay, "$"), "~")
+	if fingerprint == "" {
+		return ""
+	}
+	key := "ns/id/" + fingerprint
+	info, err := config.torGetInfo(instance.Control, key)
+	if err != nil {
+		config.logger().Warnf("tunnel: cannot get tor relay address: %s", err.Error())
+		return ""
+	}
+	if len(info) != 1 || info[0].Key != key {
+		return ""
+	}
+	// The router status entry contains a line like
+	// `r <NICKNAME> <IDENTITY> <DIGEST> <DATE> <TIME> <IP> <ORPORT> <DIRPORT>`.
+	for _, line := range strings.Split(info[0].Val, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 7 && fields[0] == "r" {
+			return fields[6]
+		}
+	}
+	return ""
+}
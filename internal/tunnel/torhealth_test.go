@@ -0,0 +1,235 @@
+package tunnel
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cretz/bine/control"
+	"github.com/cretz/bine/tor"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+// torTestingCircuitStatus is a circuit-status response for testing.
+const torTestingCircuitStatus = `1 BUILT $AAAA~relay1,$BBBB~relay2,$CCCC~exit1 BUILD_FLAGS=NEED_CAPACITY PURPOSE=GENERAL TIME_CREATED=2024-03-01T10:00:00.000000
+2 BUILT $AAAA~relay1,$DDDD~relay4 BUILD_FLAGS=IS_INTERNAL,NEED_CAPACITY PURPOSE=HS_CLIENT_HSDIR
+3 EXTENDED $AAAA~relay1 BUILD_FLAGS=NEED_CAPACITY PURPOSE=GENERAL`
+
+// torTestingRouterStatus is a ns/id/CCCC response for testing.
+const torTestingRouterStatus = `r exit1 zMzM 9mMD 2024-03-01 09:00:00 130.192.91.211 9001 0
+s Exit Fast Running Stable Valid
+w Bandwidth=1000`
+
+// torTestingGetInfo is a testTorGetInfo implementation for testing.
+func torTestingGetInfo(ctrl *control.Conn, keys ...string) ([]*control.KeyVal, error) {
+	switch keys[0] {
+	case "net/listeners/socks":
+		return []*control.KeyVal{{Key: keys[0], Val: "127.0.0.1:9050"}}, nil
+	case "circuit-status":
+		return []*control.KeyVal{{Key: keys[0], Val: torTestingCircuitStatus}}, nil
+	case "ns/id/CCCC":
+		return []*control.KeyVal{{Key: keys[0], Val: torTestingRouterStatus}}, nil
+	default:
+		return nil, errors.New("mocked error")
+	}
+}
+
+func TestTorHasFreshCache(t *testing.T) {
+	stateDir := t.TempDir()
+	now := time.Now()
+	if torHasFreshCache(stateDir, now) {
+		t.Fatal("expected no fresh cache")
+	}
+	consensus := filepath.Join(stateDir, "cached-microdesc-consensus")
+	if err := os.WriteFile(consensus, []byte("deadbeef\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if !torHasFreshCache(stateDir, now) {
+		t.Fatal("expected fresh cache")
+	}
+	if torHasFreshCache(stateDir, now.Add(2*torCacheMaxAge)) {
+		t.Fatal("expected stale cache")
+	}
+	torRemoveCache(stateDir)
+	if _, err := os.Stat(consensus); !errors.Is(err, os.ErrNotExist) {
+		t.Fatal("expected the cache to be removed", err)
+	}
+}
+
+func TestTorCircuitStatus(t *testing.T) {
+	t.Run("on success", func(t *testing.T) {
+		config := &Config{testTorGetInfo: torTestingGetInfo}
+		circuits, relay := torCircuitStatus(config, &tor.Tor{})
+		if circuits != 2 {
+			t.Fatal("unexpected number of circuits", circuits)
+		}
+		if relay != "$CCCC~exit1" {
+			t.Fatal("unexpected relay", relay)
+		}
+		if address := torRelayAddress(config, &tor.Tor{}, relay); address != "130.192.91.211" {
+			t.Fatal("unexpected address", address)
+		}
+	})
+
+	t.Run("on failure", func(t *testing.T) {
+		config := &Config{
+			testTorGetInfo: func(ctrl *control.Conn, keys ...string) ([]*control.KeyVal, error) {
+				return nil, errors.New("mocked error")
+			},
+		}
+		circuits, relay := torCircuitStatus(config, &tor.Tor{})
+		if circuits != 0 || relay != "" {
+			t.Fatal("unexpected circuit status", circuits, relay)
+		}
+		if address := torRelayAddress(config, &tor.Tor{}, "$CCCC~exit1"); address != "" {
+			t.Fatal("unexpected address", address)
+		}
+	})
+
+	t.Run("with unexpected keys", func(t *testing.T) {
+		config := &Config{
+			testTorGetInfo: func(ctrl *control.Conn, keys ...string) ([]*control.KeyVal, error) {
+				return []*control.KeyVal{{Key: "antani", Val: torTestingCircuitStatus}}, nil
+			},
+		}
+		circuits, relay := torCircuitStatus(config, &tor.Tor{})
+		if circuits != 0 || relay != "" {
+			t.Fatal("unexpected circuit status", circuits, relay)
+		}
+		if address := torRelayAddress(config, &tor.Tor{}, "$CCCC~exit1"); address != "" {
+			t.Fatal("unexpected address", address)
+		}
+	})
+
+	t.Run("with an invalid relay", func(t *testing.T) {
+		config := &Config{testTorGetInfo: torTestingGetInfo}
+		if address := torRelayAddress(config, &tor.Tor{}, "~exit1"); address != "" {
+			t.Fatal("unexpected address", address)
+		}
+	})
+}
+
+func TestTorStartCollectsHealth(t *testing.T) {
+	bootstrapLogs := []string{
+		"Mar 01 10:00:00.000 [notice] Bootstrapped 0% (starting): Starting",
+		"Mar 01 10:00:01.000 [notice] Opening Socks listener on 127.0.0.1:9050",
+		"Mar 01 10:00:02.000 [notice] Bootstrapped 50% (loading_descriptors): Loading relay descriptors",
+		"Mar 01 10:00:03.000 [notice] Bootstrapped 100% (done): Done",
+	}
+
+	run := func(t *testing.T, tunnelDir string, coldStart bool) (Tunnel, []BootstrapEvent) {
+		var events []BootstrapEvent
+		tun, _, err := torStart(context.Background(), &Config{
+			Session:   &MockableSession{},
+			TunnelDir: tunnelDir,
+			testExecabsLookPath: func(name string) (string, error) {
+				return "/usr/local/bin/tor", nil
+			},
+			testTorStart: func(ctx context.Context, conf *tor.StartConf) (*tor.Tor, error) {
+				return &tor.Tor{}, nil
+			},
+			testTorProtocolInfo: func(tor *tor.Tor) (*control.ProtocolInfo, error) {
+				return &control.ProtocolInfo{}, nil
+			},
+			testTorEnableNetwork: func(ctx context.Context, tor *tor.Tor, wait bool) error {
+				logfile := filepath.Join(tunnelDir, "tor", "tor.log")
+				data := []byte(strings.Join(bootstrapLogs, "\n") + "\n")
+				return os.WriteFile(logfile, data, 0600)
+			},
+			testTorGetInfo: torTestingGetInfo,
+			TorColdStart:   coldStart,
+			OnBootstrapEvent: func(ev BootstrapEvent) {
+				events = append(events, ev)
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tun, events
+	}
+
+	tunnelDir := t.TempDir()
+	stateDir := filepath.Join(tunnelDir, "tor")
+	if err := os.MkdirAll(stateDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	consensus := filepath.Join(stateDir, "cached-microdesc-consensus")
+
+	for _, tc := range []struct {
+		name      string
+		cached    bool
+		coldStart bool
+		warmStart bool
+	}{{
+		name:      "without cached consensus",
+		cached:    false,
+		coldStart: false,
+		warmStart: false,
+	}, {
+		name:      "with cached consensus",
+		cached:    true,
+		coldStart: false,
+		warmStart: true,
+	}, {
+		name:      "with cached consensus and cold start",
+		cached:    true,
+		coldStart: true,
+		warmStart: false,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.cached {
+				if err := os.WriteFile(consensus, []byte("deadbeef\n"), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			tun, events := run(t, tunnelDir, tc.coldStart)
+			expect := Health{
+				Name:      "tor",
+				WarmStart: tc.warmStart,
+				BootstrapEvents: []BootstrapEvent{{
+					Progress: 0,
+					Tag:      "starting",
+					Summary:  "Starting",
+				}, {
+					Progress: 50,
+					Tag:      "loading_descriptors",
+					Summary:  "Loading relay descriptors",
+				}, {
+					Progress: 100,
+					Tag:      "done",
+					Summary:  "Done",
+				}},
+				Circuits:      2,
+				EgressRelay:   "$CCCC~exit1",
+				EgressAddress: "130.192.91.211",
+			}
+			ignore := cmpopts.IgnoreFields(Health{}, "BootstrapTime")
+			ignoreElapsed := cmpopts.IgnoreFields(BootstrapEvent{}, "Elapsed")
+			health := tun.Health()
+			if diff := cmp.Diff(expect, health, ignore, ignoreElapsed); diff != "" {
+				t.Fatal(diff)
+			}
+			if diff := cmp.Diff(expect.BootstrapEvents, events, ignoreElapsed); diff != "" {
+				t.Fatal(diff)
+			}
+			if tc.coldStart {
+				if _, err := os.Stat(consensus); !errors.Is(err, os.ErrNotExist) {
+					t.Fatal("expected the cache to be removed", err)
+				}
+			}
+		})
+	}
+
+	state, err := ReadState(tunnelDir, "tor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Starts != 3 || state.LastWarmStart || state.LastEgressAddress != "130.192.91.211" {
+		t.Fatal("unexpected state", state)
+	}
+}
@@ -115,6 +115,13 @@ func (tt *torsfTunnel) SOCKS5ProxyURL() *url.URL {
 	return tt.torTunnel.SOCKS5ProxyURL()
 }
 
+// Health implements Tunnel
+func (tt *torsfTunnel) Health() Health {
+	health := tt.torTunnel.Health()
+	health.Name = "torsf"
+	return health
+}
+
 // Stop implements Tunnel
 func (tt *torsfTunnel) Stop() {
 	tt.torTunnel.Stop()
@@ -196,6 +196,9 @@ func Test_torsfStart(t *testing.T) {
 		if tun.SOCKS5ProxyURL().String() != "socks5://127.0.0.1:5555" {
 			t.Fatal("invalid socks5 proxy URL")
 		}
+		if health := tun.Health(); health.Name != "torsf" || health.BootstrapTime != 123 {
+			t.Fatal("invalid health", health)
+		}
 		tun.Stop()
 	})
 }
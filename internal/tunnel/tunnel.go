@@ -62,6 +62,10 @@ type Tunnel interface {
 	// SOCKS5ProxyURL returns the SOCSK5 proxy URL.
 	SOCKS5ProxyURL() *url.URL
 
+	// Health returns information about the tunnel health
+	// collected while bootstrapping the tunnel.
+	Health() Health
+
 	// Stop stops the tunnel. You should not attempt to
 	// use any other tunnel method after Stop.
 	Stop()
@@ -0,0 +1,123 @@
+package dsljavascript
+
+import (
+	"context"
+	"errors"
+	"runtime/metrics"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrCPUTimeLimit indicates that a script used too much CPU time.
+	ErrCPUTimeLimit = errors.New("dsljavascript: CPU time limit exceeded")
+
+	// ErrHeapGrowthLimit indicates that the process heap grew too much while
+	// running a script. See [VMConfig] for the limitations of this check.
+	ErrHeapGrowthLimit = errors.New("dsljavascript: heap growth limit exceeded")
+
+	// ErrTimeLimit indicates that a script ran for too much time.
+	ErrTimeLimit = errors.New("dsljavascript: time limit exceeded")
+)
+
+// watchdogInterval is the interval with which the watchdog checks the limits.
+const watchdogInterval = 25 * time.Millisecond
+
+// heapObjectsMetric is the runtime metric we use to enforce the heap growth limit. Note
+// that this metric accounts for the whole process heap rather than for the VM heap.
+const heapObjectsMetric = "/memory/classes/heap/objects:bytes"
+
+// runWithLimits runs fn while enforcing the limits configured in [VMConfig] and makes
+// the context available to native modules. The fn function MUST run JavaScript code
+// using the goja VM, which we interrupt when we exceed any limit.
+func (vm *VM) runWithLimits(ctx context.Context, fn func() error) error {
+	// honour the maximum wall clock runtime
+	var cancel context.CancelFunc
+	if vm.config.MaxRuntime > 0 {
+		ctx, cancel = context.WithTimeout(ctx, vm.config.MaxRuntime)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	// make the context available to native modules
+	vm.ctx = ctx
+	defer func() {
+		vm.ctx = context.Background()
+	}()
+
+	// start the watchdog in the background
+	done := make(chan any)
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		vm.watchdog(ctx, done)
+	}()
+
+	// run the code
+	err := fn()
+
+	// stop the watchdog and make sure it does not interrupt the VM
+	// after we have cleared the interrupt flag
+	close(done)
+	wg.Wait()
+	vm.vm.ClearInterrupt()
+	return err
+}
+
+// watchdog interrupts the VM as soon as any limit is exceeded.
+func (vm *VM) watchdog(ctx context.Context, done <-chan any) {
+	ticker := time.NewTicker(watchdogInterval)
+	defer ticker.Stop()
+
+	t0 := time.Now()
+	blocked0 := vm.blocked.Load()
+	heap0 := readHeapObjectsBytes()
+
+	for {
+		select {
+		case <-done:
+			return
+
+		case <-ctx.Done():
+			vm.vm.Interrupt(vm.contextError(ctx))
+			return
+
+		case <-ticker.C:
+			blocked := time.Duration(vm.blocked.Load() - blocked0)
+			if limit := vm.config.MaxCPUTime; limit > 0 && time.Since(t0)-blocked > limit {
+				vm.vm.Interrupt(ErrCPUTimeLimit)
+				return
+			}
+			if limit := vm.config.MaxHeapGrowth; limit > 0 && readHeapObjectsBytes() > heap0+limit {
+				vm.vm.Interrupt(ErrHeapGrowthLimit)
+				return
+			}
+		}
+	}
+}
+
+// contextError maps the context error to the error to use for interrupting the VM.
+func (vm *VM) contextError(ctx context.Context) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return ErrTimeLimit
+	}
+	return ctx.Err()
+}
+
+// trackBlocking accounts for the time spent blocking inside native modules since
+// the given time, which is not counted as CPU time. Use it with defer.
+func (vm *VM) trackBlocking(t0 time.Time) {
+	vm.blocked.Add(int64(time.Since(t0)))
+}
+
+// readHeapObjectsBytes returns the number of bytes occupied by heap objects.
+func readHeapObjectsBytes() uint64 {
+	samples := []metrics.Sample{{Name: heapObjectsMetric}}
+	metrics.Read(samples)
+	if samples[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return samples[0].Value.Uint64()
+}
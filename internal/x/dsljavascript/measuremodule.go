@@ -0,0 +1,391 @@
+package dsljavascript
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/ooni/probe-cli/v3/internal/logx"
+	"github.com/ooni/probe-cli/v3/internal/measurexlite"
+	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/netxlite"
+	"github.com/ooni/probe-cli/v3/internal/runtimex"
+	"github.com/ooni/probe-cli/v3/internal/x/dslvm"
+	"github.com/quic-go/quic-go"
+)
+
+// measureTimeout is the timeout of each _measure operation.
+const measureTimeout = 10 * time.Second
+
+// measureMaxBodySnapshotSize is the maximum HTTP body snapshot size.
+const measureMaxBodySnapshotSize = 1 << 17
+
+// measureMaxSleep is the maximum duration of a single _measure.sleep call.
+const measureMaxSleep = 30 * time.Second
+
+// errMeasureInvalidURL indicates that the URL passed to httpGet is invalid.
+var errMeasureInvalidURL = errors.New("dsljavascript: _measure.httpGet: invalid URL")
+
+// newModuleMeasure creates the _measure module in JavaScript. This module gives
+// scripts a small set of capabilities (DNS lookups, TCP connects, TLS and QUIC
+// handshakes, HTTP GETs, sleeping, and emitting test keys). The network operations
+// are always traced, so we archive all the observations they produce.
+func (vm *VM) newModuleMeasure(gojaVM *goja.Runtime, mod *goja.Object) {
+	runtimex.Assert(vm.vm == gojaVM, "dsljavascript: unexpected gojaVM pointer value")
+	exports := mod.Get("exports").(*goja.Object)
+	runtimex.Try0(exports.Set("emit", vm.measureEmit))
+	runtimex.Try0(exports.Set("httpGet", vm.measureHTTPGet))
+	runtimex.Try0(exports.Set("lookupHost", vm.measureLookupHost))
+	runtimex.Try0(exports.Set("quicHandshake", vm.measureQUICHandshake))
+	runtimex.Try0(exports.Set("sleep", vm.measureSleep))
+	runtimex.Try0(exports.Set("tcpConnect", vm.measureTCPConnect))
+	runtimex.Try0(exports.Set("tlsHandshake", vm.measureTLSHandshake))
+}
+
+// measureTLSOptions contains the options for TLS and QUIC handshakes.
+type measureTLSOptions struct {
+	// ALPN contains the OPTIONAL ALPN protocols to offer.
+	ALPN []string `json:"alpn"`
+
+	// SNI is the OPTIONAL SNI; by default we use the endpoint hostname.
+	SNI string `json:"sni"`
+}
+
+// measureHTTPOptions contains the options for HTTP GETs.
+type measureHTTPOptions struct {
+	// Address is the OPTIONAL IP address to use; by default we use getaddrinfo.
+	Address string `json:"address"`
+}
+
+// parseOptions parses the optional options object into the given value.
+func (vm *VM) parseOptions(value goja.Value, out any) error {
+	if value == nil || goja.IsUndefined(value) || goja.IsNull(value) {
+		return nil
+	}
+	data, err := value.ToObject(vm.vm).MarshalJSON()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// newTrace creates a new trace for a _measure operation.
+func (vm *VM) newTrace() *measurexlite.Trace {
+	return measurexlite.NewTrace(vm.idGenerator.Add(1), vm.config.ZeroTime)
+}
+
+// saveTrace saves the observations collected by the given trace.
+func (vm *VM) saveTrace(trace *measurexlite.Trace) {
+	vm.saveObservations(&dslvm.Observations{
+		NetworkEvents:  trace.NetworkEvents(),
+		Queries:        trace.DNSLookupsFromRoundTrip(),
+		Requests:       []*model.ArchivalHTTPRequestResult{},
+		TCPConnect:     trace.TCPConnects(),
+		TLSHandshakes:  trace.TLSHandshakes(),
+		QUICHandshakes: trace.QUICHandshakes(),
+	})
+}
+
+// saveObservations merges the given observations with the VM observations.
+func (vm *VM) saveObservations(obs *dslvm.Observations) {
+	vm.observations.NetworkEvents = append(vm.observations.NetworkEvents, obs.NetworkEvents...)
+	vm.observations.Queries = append(vm.observations.Queries, obs.Queries...)
+	vm.observations.Requests = append(vm.observations.Requests, obs.Requests...)
+	vm.observations.TCPConnect = append(vm.observations.TCPConnect, obs.TCPConnect...)
+	vm.observations.TLSHandshakes = append(vm.observations.TLSHandshakes, obs.TLSHandshakes...)
+	vm.observations.QUICHandshakes = append(vm.observations.QUICHandshakes, obs.QUICHandshakes...)
+}
+
+// measureEmit implements _measure.emit, which saves a test key.
+func (vm *VM) measureEmit(key string, value goja.Value) error {
+	// make sure we can serialize the value we're going to archive
+	exported := value.Export()
+	if _, err := json.Marshal(exported); err != nil {
+		return err
+	}
+	vm.emitted[key] = exported
+	return nil
+}
+
+// measureSleep implements _measure.sleep, which sleeps for the given number of
+// milliseconds or until the context is done, whichever happens first.
+func (vm *VM) measureSleep(millis int64) {
+	defer vm.trackBlocking(time.Now())
+	delay := min(time.Duration(millis)*time.Millisecond, measureMaxSleep)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-vm.ctx.Done():
+	case <-timer.C:
+	}
+}
+
+// measureLookupHost implements _measure.lookupHost using getaddrinfo.
+func (vm *VM) measureLookupHost(domain string) map[string]any {
+	defer vm.trackBlocking(time.Now())
+	addrs, err := vm.lookupHost(domain)
+	return map[string]any{
+		"addresses": addrs,
+		"failure":   measureFailure(err),
+	}
+}
+
+func (vm *VM) lookupHost(domain string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(vm.ctx, measureTimeout)
+	defer cancel()
+	trace := vm.newTrace()
+	ol := logx.NewOperationLogger(vm.logger, "[#%d] LookupHost %s", trace.Index(), domain)
+	reso := trace.NewStdlibResolver(vm.logger)
+	addrs, err := reso.LookupHost(ctx, domain)
+	ol.Stop(err)
+	vm.saveTrace(trace)
+	if addrs == nil {
+		addrs = []string{}
+	}
+	return addrs, err
+}
+
+// measureTCPConnect implements _measure.tcpConnect.
+func (vm *VM) measureTCPConnect(endpoint string) map[string]any {
+	defer vm.trackBlocking(time.Now())
+	ctx, cancel := context.WithTimeout(vm.ctx, measureTimeout)
+	defer cancel()
+	trace := vm.newTrace()
+	conn, err := vm.tcpConnect(ctx, trace, endpoint)
+	measurexlite.MaybeClose(conn)
+	vm.saveTrace(trace)
+	return map[string]any{
+		"failure": measureFailure(err),
+	}
+}
+
+func (vm *VM) tcpConnect(ctx context.Context, trace *measurexlite.Trace, endpoint string) (net.Conn, error) {
+	ol := logx.NewOperationLogger(vm.logger, "[#%d] TCPConnect %s", trace.Index(), endpoint)
+	dialer := trace.NewDialerWithoutResolver(vm.logger)
+	conn, err := dialer.DialContext(ctx, "tcp", endpoint)
+	ol.Stop(err)
+	return conn, err
+}
+
+// newTLSConfig creates the TLS config for the given endpoint and options.
+func (vm *VM) newTLSConfig(endpoint string, options *measureTLSOptions) *tls.Config {
+	sni := options.SNI
+	if sni == "" {
+		sni, _, _ = net.SplitHostPort(endpoint)
+	}
+	// See https://github.com/ooni/probe/issues/2413 to understand
+	// why we're using nil to force netxlite to use the cached
+	// default Mozilla cert pool.
+	return &tls.Config{ // #nosec G402 - we need to use a large TLS versions range for measuring
+		NextProtos: options.ALPN,
+		RootCAs:    nil,
+		ServerName: sni,
+	}
+}
+
+// measureTLSHandshake implements _measure.tlsHandshake.
+func (vm *VM) measureTLSHandshake(endpoint string, optionsValue goja.Value) (map[string]any, error) {
+	defer vm.trackBlocking(time.Now())
+	options := &measureTLSOptions{}
+	if err := vm.parseOptions(optionsValue, options); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(vm.ctx, measureTimeout)
+	defer cancel()
+	trace := vm.newTrace()
+	tlsConn, err := vm.tlsConnectAndHandshake(ctx, trace, endpoint, vm.newTLSConfig(endpoint, options))
+	var negotiatedProtocol string
+	if err == nil {
+		negotiatedProtocol = tlsConn.ConnectionState().NegotiatedProtocol
+		tlsConn.Close()
+	}
+	vm.saveTrace(trace)
+	return map[string]any{
+		"failure":            measureFailure(err),
+		"negotiatedProtocol": negotiatedProtocol,
+	}, nil
+}
+
+func (vm *VM) tlsConnectAndHandshake(ctx context.Context,
+	trace *measurexlite.Trace, endpoint string, config *tls.Config) (model.TLSConn, error) {
+	conn, err := vm.tcpConnect(ctx, trace, endpoint)
+	if err != nil {
+		return nil, err
+	}
+	ol := logx.NewOperationLogger(vm.logger, "[#%d] TLSHandshake with %s SNI=%s ALPN=%v",
+		trace.Index(), endpoint, config.ServerName, config.NextProtos)
+	thx := trace.NewTLSHandshakerStdlib(vm.logger)
+	tlsConn, err := thx.Handshake(ctx, conn, config)
+	ol.Stop(err)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// measureQUICHandshake implements _measure.quicHandshake.
+func (vm *VM) measureQUICHandshake(endpoint string, optionsValue goja.Value) (map[string]any, error) {
+	defer vm.trackBlocking(time.Now())
+	options := &measureTLSOptions{}
+	if err := vm.parseOptions(optionsValue, options); err != nil {
+		return nil, err
+	}
+	if len(options.ALPN) <= 0 {
+		options.ALPN = []string{"h3"}
+	}
+	ctx, cancel := context.WithTimeout(vm.ctx, measureTimeout)
+	defer cancel()
+	trace := vm.newTrace()
+	config := vm.newTLSConfig(endpoint, options)
+	ol := logx.NewOperationLogger(vm.logger, "[#%d] QUICHandshake with %s SNI=%s ALPN=%v",
+		trace.Index(), endpoint, config.ServerName, config.NextProtos)
+	quicDialer := trace.NewQUICDialerWithoutResolver(trace.NewUDPListener(), vm.logger)
+	quicConn, err := quicDialer.DialContext(ctx, endpoint, config, &quic.Config{})
+	ol.Stop(err)
+	var negotiatedProtocol string
+	if err == nil {
+		negotiatedProtocol = quicConn.ConnectionState().TLS.NegotiatedProtocol
+		measurexlite.MaybeCloseQUICConn(quicConn)
+	}
+	vm.saveTrace(trace)
+	return map[string]any{
+		"failure":            measureFailure(err),
+		"negotiatedProtocol": negotiatedProtocol,
+	}, nil
+}
+
+// measureHTTPGet implements _measure.httpGet, which resolves the URL domain unless
+// an address is provided, connects, and performs an HTTP GET using HTTP/1.1 or HTTP/2.
+func (vm *VM) measureHTTPGet(URL string, optionsValue goja.Value) (map[string]any, error) {
+	defer vm.trackBlocking(time.Now())
+	options := &measureHTTPOptions{}
+	if err := vm.parseOptions(optionsValue, options); err != nil {
+		return nil, err
+	}
+	parsed, err := url.Parse(URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Hostname() == "" {
+		return nil, errMeasureInvalidURL
+	}
+	resp, body, err := vm.httpGet(parsed, options)
+	var statusCode int64
+	if resp != nil {
+		statusCode = int64(resp.StatusCode)
+	}
+	return map[string]any{
+		"body":       string(body),
+		"failure":    measureFailure(err),
+		"statusCode": statusCode,
+	}, nil
+}
+
+func (vm *VM) httpGet(URL *url.URL, options *measureHTTPOptions) (*http.Response, []byte, error) {
+	// figure out the address to use
+	address := options.Address
+	if address == "" {
+		addrs, err := vm.lookupHost(URL.Hostname())
+		if err != nil {
+			return nil, nil, err
+		}
+		address = addrs[0]
+	}
+
+	// compute the endpoint to use
+	port := URL.Port()
+	if port == "" {
+		port = map[string]string{"http": "80", "https": "443"}[URL.Scheme]
+	}
+	endpoint := net.JoinHostPort(address, port)
+
+	// establish a connection and create a single use transport
+	ctx, cancel := context.WithTimeout(vm.ctx, measureTimeout)
+	defer cancel()
+	trace := vm.newTrace()
+	defer vm.saveTrace(trace)
+	var (
+		network            = "tcp"
+		negotiatedProtocol string
+		txp                model.HTTPTransport
+	)
+	switch URL.Scheme {
+	case "https":
+		config := vm.newTLSConfig(endpoint, &measureTLSOptions{
+			ALPN: []string{"h2", "http/1.1"},
+			SNI:  URL.Hostname(),
+		})
+		tlsConn, err := vm.tlsConnectAndHandshake(ctx, trace, endpoint, config)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer tlsConn.Close()
+		negotiatedProtocol = tlsConn.ConnectionState().NegotiatedProtocol
+		txp = netxlite.NewHTTPTransport(vm.logger, netxlite.NewNullDialer(), netxlite.NewSingleUseTLSDialer(tlsConn))
+	default:
+		conn, err := vm.tcpConnect(ctx, trace, endpoint)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer conn.Close()
+		txp = netxlite.NewHTTPTransport(vm.logger, netxlite.NewSingleUseDialer(conn), netxlite.NewNullTLSDialer())
+	}
+
+	// create the HTTP request
+	req, err := http.NewRequestWithContext(ctx, "GET", URL.String(), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Accept", model.HTTPHeaderAccept)
+	req.Header.Set("Accept-Language", model.HTTPHeaderAcceptLanguage)
+	req.Header.Set("User-Agent", model.HTTPHeaderUserAgent)
+	req.Header.Set("Host", req.Host) // we want to have it in the measurement
+
+	// perform the round trip and read a snapshot of the body
+	ol := logx.NewOperationLogger(vm.logger, "[#%d] HTTPRequest %s with %s/%s",
+		trace.Index(), URL.String(), endpoint, network)
+	started := trace.TimeSince(trace.ZeroTime())
+	resp, err := txp.RoundTrip(req)
+	var body []byte
+	if err == nil {
+		defer resp.Body.Close()
+		reader := io.LimitReader(resp.Body, measureMaxBodySnapshotSize)
+		body, err = netxlite.ReadAllContext(ctx, reader)
+	}
+	finished := trace.TimeSince(trace.ZeroTime())
+	ol.Stop(err)
+
+	// save the HTTP observations
+	vm.saveObservations(&dslvm.Observations{
+		Requests: []*model.ArchivalHTTPRequestResult{
+			measurexlite.NewArchivalHTTPRequestResult(
+				trace.Index(),
+				started,
+				network,
+				endpoint,
+				negotiatedProtocol,
+				txp.Network(),
+				req,
+				resp,
+				measureMaxBodySnapshotSize,
+				body,
+				err,
+				finished,
+			),
+		},
+	})
+	return resp, body, err
+}
+
+// measureFailure returns the OONI failure string or nil, which becomes null in JavaScript.
+func measureFailure(err error) any {
+	if failure := measurexlite.NewFailure(err); failure != nil {
+		return *failure
+	}
+	return nil
+}
@@ -1,16 +1,21 @@
 package dsljavascript
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"math/rand"
 	"os"
 	"path/filepath"
+	"sync/atomic"
+	"time"
 
 	"github.com/dop251/goja"
 	"github.com/dop251/goja_nodejs/require"
 	"github.com/dop251/goja_nodejs/util"
 	"github.com/ooni/probe-cli/v3/internal/model"
 	"github.com/ooni/probe-cli/v3/internal/runtimex"
+	"github.com/ooni/probe-cli/v3/internal/x/dslvm"
 )
 
 // VMConfig contains configuration for creating a VM.
@@ -18,8 +23,43 @@ type VMConfig struct {
 	// Logger is the MANDATORY logger to use.
 	Logger model.Logger
 
-	// ScriptBaseDir is the MANDATORY script base dir to use.
+	// MaxCallStackSize is the OPTIONAL maximum call stack size. When zero or
+	// negative, we use the goja default, which is unlimited.
+	MaxCallStackSize int
+
+	// MaxCPUTime is the OPTIONAL maximum time spent running JavaScript code,
+	// which excludes the time spent blocking inside native modules (e.g.,
+	// while performing network operations or sleeping).
+	MaxCPUTime time.Duration
+
+	// MaxHeapGrowth is the OPTIONAL maximum number of bytes by which the
+	// process heap may grow while running JavaScript code. Because neither the Go
+	// runtime nor goja allow us to account memory per VM, this is an approximate,
+	// process-wide guard against runaway scripts rather than a per-VM budget: other
+	// goroutines allocating memory count towards the limit and the garbage
+	// collector freeing memory allows a script to allocate more.
+	MaxHeapGrowth uint64
+
+	// MaxRuntime is the OPTIONAL maximum wall clock time for running code.
+	MaxRuntime time.Duration
+
+	// RandSeed is the OPTIONAL seed for Math.random. When nonzero, the
+	// sequence of random numbers is deterministic, which allows to replay
+	// scripts when running inside netem scenarios.
+	RandSeed int64
+
+	// Sandboxed OPTIONALLY prevents scripts from loading modules from the
+	// file system and from using the _ooni native module, which does not honour
+	// the resource limits, so that they can only require the other native modules.
+	Sandboxed bool
+
+	// ScriptBaseDir is the script base dir to use. This field is MANDATORY
+	// unless the Sandboxed field is true, in which case it is ignored.
 	ScriptBaseDir string
+
+	// ZeroTime is the OPTIONAL zero time for the observations collected
+	// by the _measure module. If zero, we use the VM creation time.
+	ZeroTime time.Time
 }
 
 // errVMConfig indicates that some setting in the [*VMConfig] is invalid.
@@ -31,7 +71,7 @@ func (cfg *VMConfig) check() error {
 		return fmt.Errorf("%w: the Logger field is nil", errVMConfig)
 	}
 
-	if cfg.ScriptBaseDir == "" {
+	if !cfg.Sandboxed && cfg.ScriptBaseDir == "" {
 		return fmt.Errorf("%w: the ScriptBaseDir field is empty", errVMConfig)
 	}
 
@@ -41,9 +81,27 @@ func (cfg *VMConfig) check() error {
 // VM wraps the [*github.com/dop251/goja.Runtime]. The zero value of this
 // struct is invalid; please, use [NewVM] to construct.
 type VM struct {
+	// blocked is the time spent blocking inside native modules.
+	blocked *atomic.Int64
+
+	// config is the config with which we created the VM.
+	config VMConfig
+
+	// ctx is the context used by native modules while running code.
+	ctx context.Context
+
+	// emitted contains the test keys emitted using the _measure module.
+	emitted map[string]any
+
+	// idGenerator generates the IDs of the _measure module traces.
+	idGenerator *atomic.Int64
+
 	// logger is the logger to use.
 	logger model.Logger
 
+	// observations contains the _measure module observations.
+	observations *dslvm.Observations
+
 	// registry is the JavaScript package registry to use.
 	registry *require.Registry
 
@@ -76,24 +134,55 @@ func NewVM(config *VMConfig, scriptPath string) (*VM, error) {
 		return nil, err
 	}
 
-	// convert the script base dir to be an absolute path
-	scriptBaseDir, err := filepath.Abs(config.ScriptBaseDir)
-	if err != nil {
-		return nil, err
-	}
-
 	// create package registry ("By default, a registry's global folders list is empty")
-	registry := require.NewRegistry(require.WithGlobalFolders(scriptBaseDir))
+	var (
+		registry      *require.Registry
+		scriptBaseDir string
+	)
+	if config.Sandboxed {
+		registry = require.NewRegistry(require.WithLoader(sandboxedSourceLoader))
+	} else {
+		// convert the script base dir to be an absolute path
+		var err error
+		scriptBaseDir, err = filepath.Abs(config.ScriptBaseDir)
+		if err != nil {
+			return nil, err
+		}
+		registry = require.NewRegistry(require.WithGlobalFolders(scriptBaseDir))
+	}
 
 	// create the goja virtual machine
 	gojaVM := goja.New()
 
+	// apply the configured call stack limit
+	if config.MaxCallStackSize > 0 {
+		gojaVM.SetMaxCallStackSize(config.MaxCallStackSize)
+	}
+
+	// make Math.random deterministic when we have a seed
+	if config.RandSeed != 0 {
+		gojaVM.SetRandSource(rand.New(rand.NewSource(config.RandSeed)).Float64) // #nosec G404 - we want determinism here
+	}
+
+	// make sure the zero time is initialized
+	zeroTime := config.ZeroTime
+	if zeroTime.IsZero() {
+		zeroTime = time.Now()
+	}
+	config.ZeroTime = zeroTime
+
 	// enable 'require' for the virtual machine
 	registry.Enable(gojaVM)
 
 	// create the virtual machine wrapper
 	vm := &VM{
+		blocked:       &atomic.Int64{},
+		config:        *config,
+		ctx:           context.Background(),
+		emitted:       map[string]any{},
+		idGenerator:   &atomic.Int64{},
 		logger:        config.Logger,
+		observations:  dslvm.NewObservations(),
 		registry:      registry,
 		scriptBaseDir: scriptBaseDir,
 		util:          require.Require(gojaVM, util.ModuleName).(*goja.Object),
@@ -109,12 +198,24 @@ func NewVM(config *VMConfig, scriptPath string) (*VM, error) {
 	// register the _golang module in JavaScript
 	registry.RegisterNativeModule("_golang", vm.newModuleGolang)
 
-	// register the _ooni module in JavaScript
-	registry.RegisterNativeModule("_ooni", vm.newModuleOONI)
+	// register the _ooni module in JavaScript, unless we're sandboxed, because
+	// its functions are not bounded by the resource limits
+	if !config.Sandboxed {
+		registry.RegisterNativeModule("_ooni", vm.newModuleOONI)
+	}
+
+	// register the _measure module in JavaScript
+	registry.RegisterNativeModule("_measure", vm.newModuleMeasure)
 
 	return vm, nil
 }
 
+// sandboxedSourceLoader is the [require.SourceLoader] that prevents
+// sandboxed scripts from loading modules from the file system.
+func sandboxedSourceLoader(path string) ([]byte, error) {
+	return nil, require.ModuleFileDoesNotExistError
+}
+
 // LoadExperiment loads the given experiment file and returns a new VM primed
 // to execute the experiment several times for several inputs.
 func LoadExperiment(config *VMConfig, exPath string) (*VM, error) {
@@ -135,6 +236,27 @@ func LoadExperiment(config *VMConfig, exPath string) (*VM, error) {
 	return vm, nil
 }
 
+// LoadExperimentSource is like [LoadExperiment] except that it uses the
+// given source code, which is useful when the caller has already read the
+// script (e.g., to compute its hash). The name is used in stack traces.
+func LoadExperimentSource(config *VMConfig, name string, source []byte) (*VM, error) {
+	// create a new VM instance
+	vm, err := NewVM(config, name)
+	if err != nil {
+		return nil, err
+	}
+
+	// make sure there's an empty dictionary containing exports
+	runtimex.Try0(vm.vm.Set("exports", vm.vm.NewObject()))
+
+	// run the script
+	if err := vm.runSource(name, source); err != nil {
+		return nil, err
+	}
+
+	return vm, nil
+}
+
 func (vm *VM) RunScript(exPath string) error {
 	// read the file content
 	content, err := os.ReadFile(exPath) // #nosec G304 - this is working as intended
@@ -143,11 +265,15 @@ func (vm *VM) RunScript(exPath string) error {
 	}
 
 	// interpret the script defining the experiment
-	if _, err = vm.vm.RunScript(exPath, string(content)); err != nil {
-		return err
-	}
+	return vm.runSource(exPath, content)
+}
 
-	return nil
+// runSource runs the given source code applying the configured limits.
+func (vm *VM) runSource(name string, source []byte) error {
+	return vm.runWithLimits(context.Background(), func() error {
+		_, err := vm.vm.RunScript(name, string(source))
+		return err
+	})
 }
 
 func (vm *VM) findExportedSymbol(name string) (goja.Value, error) {
@@ -212,3 +338,30 @@ func (vm *VM) Run(input string) (string, error) {
 	}
 	return run(input)
 }
+
+// RunContext is like [*VM.Run] but applies the configured limits, allows native
+// modules to use the given context, and ignores the value returned by the script,
+// which should instead emit test keys using the _measure module.
+func (vm *VM) RunContext(ctx context.Context, input string) error {
+	var run func(string) error
+	value, err := vm.findExportedSymbol("run")
+	if err != nil {
+		return err
+	}
+	if err := vm.vm.ExportTo(value, &run); err != nil {
+		return err
+	}
+	return vm.runWithLimits(ctx, func() error {
+		return run(input)
+	})
+}
+
+// Observations returns the observations collected by the _measure module.
+func (vm *VM) Observations() *dslvm.Observations {
+	return vm.observations
+}
+
+// TestKeys returns the test keys emitted using the _measure module.
+func (vm *VM) TestKeys() map[string]any {
+	return vm.emitted
+}
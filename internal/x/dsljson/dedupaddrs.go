@@ -14,7 +14,7 @@ type dedupAddrsValue struct {
 func (lx *loader) onDedupAddrs(raw json.RawMessage) error {
 	// parse the raw value
 	var value dedupAddrsValue
-	if err := json.Unmarshal(raw, &value); err != nil {
+	if err := unmarshalValue(raw, &value); err != nil {
 		return err
 	}
 
@@ -16,7 +16,7 @@ type dnsLookupUDPValue struct {
 func (lx *loader) onDNSLookupUDP(raw json.RawMessage) error {
 	// parse the raw value
 	var value dnsLookupUDPValue
-	if err := json.Unmarshal(raw, &value); err != nil {
+	if err := unmarshalValue(raw, &value); err != nil {
 		return err
 	}
 
@@ -15,7 +15,7 @@ type dropValue struct {
 func (lx *loader) onDrop(raw json.RawMessage) error {
 	// parse the raw value
 	var value dropValue
-	if err := json.Unmarshal(raw, &value); err != nil {
+	if err := unmarshalValue(raw, &value); err != nil {
 		return err
 	}
 
@@ -15,7 +15,7 @@ type getaddrinfoValue struct {
 func (lx *loader) onGetaddrinfo(raw json.RawMessage) error {
 	// parse the raw value
 	var value getaddrinfoValue
-	if err := json.Unmarshal(raw, &value); err != nil {
+	if err := unmarshalValue(raw, &value); err != nil {
 		return err
 	}
 
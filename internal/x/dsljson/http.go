@@ -23,7 +23,7 @@ type httpRoundTripValue struct {
 func (lx *loader) onHTTPRoundTrip(raw json.RawMessage) error {
 	// parse the raw value
 	var value httpRoundTripValue
-	if err := json.Unmarshal(raw, &value); err != nil {
+	if err := unmarshalValue(raw, &value); err != nil {
 		return err
 	}
 
@@ -15,7 +15,7 @@ type makeEndpointsValue struct {
 func (lx *loader) onMakeEndpoints(raw json.RawMessage) error {
 	// parse the raw value
 	var value makeEndpointsValue
-	if err := json.Unmarshal(raw, &value); err != nil {
+	if err := unmarshalValue(raw, &value); err != nil {
 		return err
 	}
 
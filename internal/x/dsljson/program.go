@@ -0,0 +1,72 @@
+package dsljson
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ooni/probe-cli/v3/internal/model"
+)
+
+// ProgramVersion is the version of the [*Program] format we support.
+const ProgramVersion = 1
+
+// Program is a versioned DSL program, which is the format we use when we
+// receive the DSL from external sources (e.g., OONI Run v2 descriptors).
+type Program struct {
+	// Version is the version of the program format.
+	Version int64 `json:"version"`
+
+	// Stages contains the stages of the program.
+	Stages []StageNode `json:"stages"`
+}
+
+var (
+	// ErrInvalidProgram indicates that a [*Program] is not valid.
+	ErrInvalidProgram = errors.New("dsljson: invalid program")
+
+	// ErrUnsupportedProgramVersion indicates that we do not support the [*Program] version.
+	ErrUnsupportedProgramVersion = errors.New("dsljson: unsupported program version")
+)
+
+// ParseProgram parses and validates a [*Program] and returns the corresponding [*RootNode].
+//
+// The validation consists of rejecting unknown fields and instructions, checking the
+// program version, and loading the program to check registers usage and types.
+func ParseProgram(logger model.Logger, data []byte) (*RootNode, error) {
+	var program Program
+	if err := unmarshalValue(data, &program); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidProgram, err.Error())
+	}
+	if program.Version != ProgramVersion {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedProgramVersion, program.Version)
+	}
+	if len(program.Stages) <= 0 {
+		return nil, fmt.Errorf("%w: no stages", ErrInvalidProgram)
+	}
+	root := &RootNode{Stages: program.Stages}
+	if err := Validate(logger, root); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidProgram, err.Error())
+	}
+	return root, nil
+}
+
+// Validate loads the given [*RootNode] without running it to check whether it is valid.
+func Validate(logger model.Logger, root *RootNode) error {
+	return newLoader().load(logger, root)
+}
+
+// unmarshalValue is like [json.Unmarshal] but rejects unknown fields and trailing data.
+func unmarshalValue(raw []byte, value any) error {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(value); err != nil {
+		return err
+	}
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return errors.New("unexpected data after the JSON value")
+	}
+	return nil
+}
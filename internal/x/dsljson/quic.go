@@ -21,7 +21,7 @@ type quicHandshakeValue struct {
 func (lx *loader) onQUICHandshake(raw json.RawMessage) error {
 	// parse the raw value
 	var value quicHandshakeValue
-	if err := json.Unmarshal(raw, &value); err != nil {
+	if err := unmarshalValue(raw, &value); err != nil {
 		return err
 	}
 
@@ -16,7 +16,7 @@ type takeNValue struct {
 func (lx *loader) onTakeN(raw json.RawMessage) error {
 	// parse the raw value
 	var value takeNValue
-	if err := json.Unmarshal(raw, &value); err != nil {
+	if err := unmarshalValue(raw, &value); err != nil {
 		return err
 	}
 
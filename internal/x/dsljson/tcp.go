@@ -15,7 +15,7 @@ type tcpConnectValue struct {
 func (lx *loader) onTCPConnect(raw json.RawMessage) error {
 	// parse the raw value
 	var value tcpConnectValue
-	if err := json.Unmarshal(raw, &value); err != nil {
+	if err := unmarshalValue(raw, &value); err != nil {
 		return err
 	}
 
@@ -14,7 +14,7 @@ type teeAddrsValue struct {
 func (lx *loader) onTeeAddrs(raw json.RawMessage) error {
 	// parse the raw value
 	var value teeAddrsValue
-	if err := json.Unmarshal(raw, &value); err != nil {
+	if err := unmarshalValue(raw, &value); err != nil {
 		return err
 	}
 
@@ -20,7 +20,7 @@ type tlsHandshakeValue struct {
 func (lx *loader) onTLSHandshake(raw json.RawMessage) error {
 	// parse the raw value
 	var value tlsHandshakeValue
-	if err := json.Unmarshal(raw, &value); err != nil {
+	if err := unmarshalValue(raw, &value); err != nil {
 		return err
 	}
 
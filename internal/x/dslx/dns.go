@@ -10,6 +10,8 @@ import (
 	"time"
 
 	"github.com/ooni/probe-cli/v3/internal/logx"
+	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/netxlite"
 )
 
 // DomainName is a domain name to resolve.
@@ -56,20 +58,30 @@ type DomainToResolve struct {
 // ResolvedAddresses contains the results of DNS lookups. To initialize
 // this struct manually, follow specific instructions for each field.
 type ResolvedAddresses struct {
+	// ALPN contains the OPTIONAL ALPNs advertised by the HTTPS record. Only the
+	// functions issuing HTTPS queries (e.g., [DNSLookupHTTPSRecordUDP]) set this field.
+	ALPN []string
+
 	// Addresses contains the nonempty resolved addresses.
 	Addresses []string
 
 	// Domain is the domain we resolved. We inherit this field
 	// from the value inside the DomainToResolve.
 	Domain string
+
+	// ECHConfig contains the OPTIONAL ECHConfigList advertised by the HTTPS record. Only
+	// the functions issuing HTTPS queries (e.g., [DNSLookupHTTPSRecordUDP]) set this field.
+	ECHConfig []byte
 }
 
 // Flatten transforms a [ResolvedAddresses] into a slice of zero or more [ResolvedAddress].
 func (ra *ResolvedAddresses) Flatten() (out []*ResolvedAddress) {
 	for _, ipAddr := range ra.Addresses {
 		out = append(out, &ResolvedAddress{
-			Address: ipAddr,
-			Domain:  ra.Domain,
+			ALPN:      ra.ALPN,
+			Address:   ipAddr,
+			Domain:    ra.Domain,
+			ECHConfig: ra.ECHConfig,
 		})
 	}
 	return
@@ -77,11 +89,17 @@ func (ra *ResolvedAddresses) Flatten() (out []*ResolvedAddress) {
 
 // ResolvedAddress is a single address resolved using a DNS lookup function.
 type ResolvedAddress struct {
+	// ALPN contains the OPTIONAL ALPNs advertised by the HTTPS record.
+	ALPN []string
+
 	// Address is the address that was resolved.
 	Address string
 
 	// Domain is the domain from which we resolved the address.
 	Domain string
+
+	// ECHConfig contains the OPTIONAL ECHConfigList advertised by the HTTPS record.
+	ECHConfig []byte
 }
 
 // DNSLookupGetaddrinfo returns a function that resolves a domain name to
@@ -179,6 +197,170 @@ func DNSLookupUDP(rt Runtime, endpoint string) Func[*DomainToResolve, *ResolvedA
 	})
 }
 
+// DNSLookupDoH returns a function that resolves a domain name to
+// IP addresses using the given DNS-over-HTTPS resolver URL.
+func DNSLookupDoH(rt Runtime, URL string) Func[*DomainToResolve, *ResolvedAddresses] {
+	return dnsLookupHost(rt, URL, "doh", func(trace Trace) model.Resolver {
+		return trace.NewParallelDNSOverHTTPSResolver(rt.Logger(), URL)
+	})
+}
+
+// DNSLookupDoT returns a function that resolves a domain name to IP addresses
+// using the given DNS-over-TLS resolver endpoint (e.g., 8.8.8.8:853). We use
+// the endpoint IP address as the SNI and we collect observations about the
+// TCP connect and the TLS handshake with the resolver.
+//
+// Note: there is no DNS-over-QUIC lookup function because [netxlite]
+// does not implement a DNS-over-QUIC transport yet.
+func DNSLookupDoT(rt Runtime, endpoint string) Func[*DomainToResolve, *ResolvedAddresses] {
+	return dnsLookupHost(rt, endpoint, "dot", func(trace Trace) model.Resolver {
+		return trace.NewParallelDNSOverTLSResolver(rt.Logger(), endpoint)
+	})
+}
+
+// dnsLookupHost is the common implementation of the functions resolving a domain
+// name to IP addresses using the resolver returned by newResolver.
+func dnsLookupHost(rt Runtime, address, network string,
+	newResolver func(trace Trace) model.Resolver) Func[*DomainToResolve, *ResolvedAddresses] {
+	return Operation[*DomainToResolve, *ResolvedAddresses](func(ctx context.Context, input *DomainToResolve) (*ResolvedAddresses, error) {
+		// create trace
+		trace := rt.NewTrace(rt.IDGenerator().Add(1), rt.ZeroTime(), input.Tags...)
+
+		// start the operation logger
+		ol := logx.NewOperationLogger(
+			rt.Logger(),
+			"[#%d] DNSLookup[%s/%s] %s",
+			trace.Index(),
+			address,
+			network,
+			input.Domain,
+		)
+
+		// setup
+		const timeout = 4 * time.Second
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		// create the resolver
+		resolver := newResolver(trace)
+
+		// lookup
+		addrs, err := resolver.LookupHost(ctx, input.Domain)
+
+		// save the observations
+		rt.SaveObservations(maybeTraceToObservations(trace)...)
+
+		// handle error case
+		if err != nil {
+			ol.Stop(err)
+			return nil, err
+		}
+
+		// handle success
+		ol.Stop(addrs)
+		state := &ResolvedAddresses{
+			Addresses: addrs,
+			Domain:    input.Domain,
+		}
+		return state, nil
+	})
+}
+
+// DNSLookupHTTPSRecordUDP returns a function that issues an HTTPS query for the
+// domain name using the given DNS-over-UDP resolver endpoint. On success, the
+// [ResolvedAddresses] contains the IP hints, the ALPNs, and the ECH config
+// advertised by the HTTPS record, which allows to choose endpoints (e.g., using
+// QUIC when the record advertises "h3") as web browsers would do. Because the
+// [ResolvedAddresses] must contain addresses, we fail with the dns_no_answer
+// failure when the HTTPS record does not contain any IP hint.
+func DNSLookupHTTPSRecordUDP(rt Runtime, endpoint string) Func[*DomainToResolve, *ResolvedAddresses] {
+	return dnsLookupHTTPSRecord(rt, endpoint, "udp", func(trace Trace) model.Resolver {
+		return trace.NewParallelUDPResolver(
+			rt.Logger(),
+			trace.NewDialerWithoutResolver(rt.Logger()),
+			endpoint,
+		)
+	})
+}
+
+// DNSLookupHTTPSRecordDoH is like [DNSLookupHTTPSRecordUDP] but uses
+// the given DNS-over-HTTPS resolver URL.
+func DNSLookupHTTPSRecordDoH(rt Runtime, URL string) Func[*DomainToResolve, *ResolvedAddresses] {
+	return dnsLookupHTTPSRecord(rt, URL, "doh", func(trace Trace) model.Resolver {
+		return trace.NewParallelDNSOverHTTPSResolver(rt.Logger(), URL)
+	})
+}
+
+// DNSLookupHTTPSRecordDoT is like [DNSLookupHTTPSRecordUDP] but uses
+// the given DNS-over-TLS resolver endpoint (e.g., 8.8.8.8:853).
+func DNSLookupHTTPSRecordDoT(rt Runtime, endpoint string) Func[*DomainToResolve, *ResolvedAddresses] {
+	return dnsLookupHTTPSRecord(rt, endpoint, "dot", func(trace Trace) model.Resolver {
+		return trace.NewParallelDNSOverTLSResolver(rt.Logger(), endpoint)
+	})
+}
+
+// dnsLookupHTTPSRecord is the common implementation of the functions issuing
+// HTTPS queries using the resolver returned by newResolver.
+func dnsLookupHTTPSRecord(rt Runtime, address, network string,
+	newResolver func(trace Trace) model.Resolver) Func[*DomainToResolve, *ResolvedAddresses] {
+	return Operation[*DomainToResolve, *ResolvedAddresses](func(ctx context.Context, input *DomainToResolve) (*ResolvedAddresses, error) {
+		// create trace
+		trace := rt.NewTrace(rt.IDGenerator().Add(1), rt.ZeroTime(), input.Tags...)
+
+		// start the operation logger
+		ol := logx.NewOperationLogger(
+			rt.Logger(),
+			"[#%d] DNSLookupHTTPSRecord[%s/%s] %s",
+			trace.Index(),
+			address,
+			network,
+			input.Domain,
+		)
+
+		// setup
+		const timeout = 4 * time.Second
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		// create the resolver
+		resolver := newResolver(trace)
+
+		// lookup
+		https, err := resolver.LookupHTTPS(ctx, input.Domain)
+
+		// save the observations
+		rt.SaveObservations(maybeTraceToObservations(trace)...)
+
+		// handle error case
+		if err != nil {
+			ol.Stop(err)
+			return nil, err
+		}
+
+		// handle the case where there are no address hints, which would
+		// otherwise produce a state without any address to connect to
+		var addrs []string
+		addrs = append(addrs, https.IPv4...)
+		addrs = append(addrs, https.IPv6...)
+		if len(addrs) <= 0 {
+			err := netxlite.MaybeNewErrWrapper(
+				netxlite.ClassifyResolverError, netxlite.ResolveOperation, netxlite.ErrOODNSNoAnswer)
+			ol.Stop(err)
+			return nil, err
+		}
+
+		// handle success
+		ol.Stop(addrs)
+		state := &ResolvedAddresses{
+			ALPN:      https.ALPN,
+			Addresses: addrs,
+			Domain:    input.Domain,
+			ECHConfig: https.ECHConfig,
+		}
+		return state, nil
+	})
+}
+
 // ErrDNSLookupParallel indicates that DNSLookupParallel failed.
 var ErrDNSLookupParallel = errors.New("dslx: DNSLookupParallel failed")
 
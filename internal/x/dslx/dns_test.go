@@ -11,6 +11,7 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"github.com/ooni/probe-cli/v3/internal/mocks"
 	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/netxlite"
 )
 
 /*
@@ -208,3 +209,306 @@ func TestLookupUDP(t *testing.T) {
 		})
 	})
 }
+
+/*
+Test cases:
+- Apply DNSLookupDoH
+  - with nil resolver
+  - with lookup error
+  - with success
+*/
+func TestLookupDoH(t *testing.T) {
+	domain := &DomainToResolve{
+		Domain: "example.com",
+		Tags:   []string{"antani"},
+	}
+
+	t.Run("with nil resolver", func(t *testing.T) {
+		rt := NewRuntimeMeasurexLite(model.DiscardLogger, time.Now())
+		f := DNSLookupDoH(rt, "https://1.1.1.1/dns-query")
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		res := f.Apply(ctx, NewMaybeWithValue(domain))
+		if obs := rt.Observations(); obs == nil || len(obs.Queries) <= 0 {
+			t.Fatal("unexpected empty observations")
+		}
+		if res.Error == nil {
+			t.Fatalf("expected an error here")
+		}
+	})
+
+	t.Run("with lookup error", func(t *testing.T) {
+		mockedErr := errors.New("mocked")
+		rt := NewRuntimeMeasurexLite(model.DiscardLogger, time.Now(), RuntimeMeasurexLiteOptionMeasuringNetwork(&mocks.MeasuringNetwork{
+			MockNewParallelDNSOverHTTPSResolver: func(logger model.DebugLogger, URL string) model.Resolver {
+				return &mocks.Resolver{
+					MockLookupHost: func(ctx context.Context, domain string) ([]string, error) {
+						return nil, mockedErr
+					},
+				}
+			},
+		}))
+		f := DNSLookupDoH(rt, "https://1.1.1.1/dns-query")
+		res := f.Apply(context.Background(), NewMaybeWithValue(domain))
+		if res.Error != mockedErr {
+			t.Fatalf("unexpected error type: %s", res.Error)
+		}
+		if res.State != nil {
+			t.Fatal("expected nil state")
+		}
+	})
+
+	t.Run("with success", func(t *testing.T) {
+		rt := NewRuntimeMeasurexLite(model.DiscardLogger, time.Now(), RuntimeMeasurexLiteOptionMeasuringNetwork(&mocks.MeasuringNetwork{
+			MockNewParallelDNSOverHTTPSResolver: func(logger model.DebugLogger, URL string) model.Resolver {
+				return &mocks.Resolver{
+					MockLookupHost: func(ctx context.Context, domain string) ([]string, error) {
+						return []string{"93.184.216.34"}, nil
+					},
+				}
+			},
+		}))
+		f := DNSLookupDoH(rt, "https://1.1.1.1/dns-query")
+		res := f.Apply(context.Background(), NewMaybeWithValue(domain))
+		if res.Error != nil {
+			t.Fatalf("unexpected error: %s", res.Error)
+		}
+		if len(res.State.Addresses) != 1 || res.State.Addresses[0] != "93.184.216.34" {
+			t.Fatal("unexpected addresses")
+		}
+	})
+}
+
+/*
+Test cases:
+- Apply DNSLookupDoT
+  - with nil resolver
+  - with dial error
+*/
+func TestLookupDoT(t *testing.T) {
+	domain := &DomainToResolve{
+		Domain: "example.com",
+		Tags:   []string{"antani"},
+	}
+
+	t.Run("with nil resolver", func(t *testing.T) {
+		rt := NewRuntimeMeasurexLite(model.DiscardLogger, time.Now())
+		f := DNSLookupDoT(rt, "1.1.1.1:853")
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		res := f.Apply(ctx, NewMaybeWithValue(domain))
+		if obs := rt.Observations(); obs == nil || len(obs.Queries) <= 0 {
+			t.Fatal("unexpected empty observations")
+		}
+		if res.Error == nil {
+			t.Fatalf("expected an error here")
+		}
+	})
+
+	t.Run("with dial error", func(t *testing.T) {
+		mockedErr := errors.New("mocked")
+		rt := NewRuntimeMeasurexLite(model.DiscardLogger, time.Now(), RuntimeMeasurexLiteOptionMeasuringNetwork(&mocks.MeasuringNetwork{
+			MockNewDialerWithoutResolver: func(dl model.DebugLogger, w ...model.DialerWrapper) model.Dialer {
+				return &mocks.Dialer{
+					MockDialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+						return nil, mockedErr
+					},
+				}
+			},
+			MockNewTLSHandshakerStdlib: func(logger model.DebugLogger) model.TLSHandshaker {
+				return &mocks.TLSHandshaker{}
+			},
+		}))
+		f := DNSLookupDoT(rt, "1.1.1.1:853")
+		res := f.Apply(context.Background(), NewMaybeWithValue(domain))
+		if !errors.Is(res.Error, mockedErr) {
+			t.Fatalf("unexpected error type: %s", res.Error)
+		}
+		if res.State != nil {
+			t.Fatal("expected nil state")
+		}
+		obs := rt.Observations()
+		if len(obs.Queries) <= 0 {
+			t.Fatal("expected to see queries")
+		}
+		for _, query := range obs.Queries {
+			if query.Engine != "dot" || query.ResolverAddress != "1.1.1.1:853" {
+				t.Fatal("unexpected query", query.Engine, query.ResolverAddress)
+			}
+		}
+	})
+}
+
+/*
+Test cases:
+- Apply DNSLookupHTTPSRecordUDP with nil resolver
+- Apply DNSLookupHTTPSRecordUDP with lookup error
+- Apply DNSLookupHTTPSRecordUDP without address hints
+- Apply DNSLookupHTTPSRecordUDP with success
+- Apply DNSLookupHTTPSRecordDoH with success
+- Apply DNSLookupHTTPSRecordDoT with nil resolver
+*/
+func TestLookupHTTPSRecord(t *testing.T) {
+	domain := &DomainToResolve{
+		Domain: "example.com",
+		Tags:   []string{"antani"},
+	}
+
+	expectedHTTPS := &model.HTTPSSvc{
+		ALPN:      []string{"h3", "h2"},
+		ECHConfig: []byte{0x00, 0x04, 0xfe, 0x0d, 0x00, 0x00},
+		IPv4:      []string{"93.184.216.34"},
+		IPv6:      []string{"2606:2800:220:1:248:1893:25c8:1946"},
+	}
+
+	expectedState := &ResolvedAddresses{
+		ALPN:      []string{"h3", "h2"},
+		Addresses: []string{"93.184.216.34", "2606:2800:220:1:248:1893:25c8:1946"},
+		Domain:    "example.com",
+		ECHConfig: []byte{0x00, 0x04, 0xfe, 0x0d, 0x00, 0x00},
+	}
+
+	t.Run("UDP", func(t *testing.T) {
+		t.Run("with nil resolver", func(t *testing.T) {
+			rt := NewRuntimeMeasurexLite(model.DiscardLogger, time.Now())
+			f := DNSLookupHTTPSRecordUDP(rt, "1.1.1.1:53")
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			res := f.Apply(ctx, NewMaybeWithValue(domain))
+			obs := rt.Observations()
+			if obs == nil || len(obs.Queries) != 1 {
+				t.Fatal("expected a single query")
+			}
+			if obs.Queries[0].QueryType != "HTTPS" {
+				t.Fatal("unexpected query type", obs.Queries[0].QueryType)
+			}
+			if res.Error == nil {
+				t.Fatalf("expected an error here")
+			}
+		})
+
+		t.Run("with lookup error", func(t *testing.T) {
+			mockedErr := errors.New("mocked")
+			rt := NewRuntimeMeasurexLite(model.DiscardLogger, time.Now(), RuntimeMeasurexLiteOptionMeasuringNetwork(&mocks.MeasuringNetwork{
+				MockNewParallelUDPResolver: func(logger model.DebugLogger, dialer model.Dialer, address string) model.Resolver {
+					return &mocks.Resolver{
+						MockLookupHTTPS: func(ctx context.Context, domain string) (*model.HTTPSSvc, error) {
+							return nil, mockedErr
+						},
+					}
+				},
+				MockNewDialerWithoutResolver: func(dl model.DebugLogger, w ...model.DialerWrapper) model.Dialer {
+					return &mocks.Dialer{}
+				},
+			}))
+			f := DNSLookupHTTPSRecordUDP(rt, "1.1.1.1:53")
+			res := f.Apply(context.Background(), NewMaybeWithValue(domain))
+			if res.Error != mockedErr {
+				t.Fatalf("unexpected error type: %s", res.Error)
+			}
+			if res.State != nil {
+				t.Fatal("expected nil state")
+			}
+		})
+
+		t.Run("without address hints", func(t *testing.T) {
+			rt := NewRuntimeMeasurexLite(model.DiscardLogger, time.Now(), RuntimeMeasurexLiteOptionMeasuringNetwork(&mocks.MeasuringNetwork{
+				MockNewParallelUDPResolver: func(logger model.DebugLogger, dialer model.Dialer, address string) model.Resolver {
+					return &mocks.Resolver{
+						MockLookupHTTPS: func(ctx context.Context, domain string) (*model.HTTPSSvc, error) {
+							return &model.HTTPSSvc{ALPN: []string{"h2"}}, nil
+						},
+					}
+				},
+				MockNewDialerWithoutResolver: func(dl model.DebugLogger, w ...model.DialerWrapper) model.Dialer {
+					return &mocks.Dialer{}
+				},
+			}))
+			f := DNSLookupHTTPSRecordUDP(rt, "1.1.1.1:53")
+			res := f.Apply(context.Background(), NewMaybeWithValue(domain))
+			if !errors.Is(res.Error, netxlite.ErrOODNSNoAnswer) {
+				t.Fatalf("unexpected error: %v", res.Error)
+			}
+			if res.State != nil {
+				t.Fatal("expected nil state")
+			}
+		})
+
+		t.Run("with success", func(t *testing.T) {
+			rt := NewRuntimeMeasurexLite(model.DiscardLogger, time.Now(), RuntimeMeasurexLiteOptionMeasuringNetwork(&mocks.MeasuringNetwork{
+				MockNewParallelUDPResolver: func(logger model.DebugLogger, dialer model.Dialer, address string) model.Resolver {
+					return &mocks.Resolver{
+						MockLookupHTTPS: func(ctx context.Context, domain string) (*model.HTTPSSvc, error) {
+							return expectedHTTPS, nil
+						},
+					}
+				},
+				MockNewDialerWithoutResolver: func(dl model.DebugLogger, w ...model.DialerWrapper) model.Dialer {
+					return &mocks.Dialer{}
+				},
+			}))
+			f := DNSLookupHTTPSRecordUDP(rt, "1.1.1.1:53")
+			res := f.Apply(context.Background(), NewMaybeWithValue(domain))
+			if res.Error != nil {
+				t.Fatalf("unexpected error: %s", res.Error)
+			}
+			if diff := cmp.Diff(expectedState, res.State); diff != "" {
+				t.Fatal(diff)
+			}
+			flat := res.State.Flatten()
+			if len(flat) != 2 {
+				t.Fatal("expected two addresses")
+			}
+			for _, entry := range flat {
+				if diff := cmp.Diff(expectedState.ALPN, entry.ALPN); diff != "" {
+					t.Fatal(diff)
+				}
+				if diff := cmp.Diff(expectedState.ECHConfig, entry.ECHConfig); diff != "" {
+					t.Fatal(diff)
+				}
+			}
+		})
+	})
+
+	t.Run("DoH", func(t *testing.T) {
+		t.Run("with success", func(t *testing.T) {
+			rt := NewRuntimeMeasurexLite(model.DiscardLogger, time.Now(), RuntimeMeasurexLiteOptionMeasuringNetwork(&mocks.MeasuringNetwork{
+				MockNewParallelDNSOverHTTPSResolver: func(logger model.DebugLogger, URL string) model.Resolver {
+					return &mocks.Resolver{
+						MockLookupHTTPS: func(ctx context.Context, domain string) (*model.HTTPSSvc, error) {
+							return expectedHTTPS, nil
+						},
+					}
+				},
+			}))
+			f := DNSLookupHTTPSRecordDoH(rt, "https://1.1.1.1/dns-query")
+			res := f.Apply(context.Background(), NewMaybeWithValue(domain))
+			if res.Error != nil {
+				t.Fatalf("unexpected error: %s", res.Error)
+			}
+			if diff := cmp.Diff(expectedState, res.State); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	})
+
+	t.Run("DoT", func(t *testing.T) {
+		t.Run("with nil resolver", func(t *testing.T) {
+			rt := NewRuntimeMeasurexLite(model.DiscardLogger, time.Now())
+			f := DNSLookupHTTPSRecordDoT(rt, "1.1.1.1:853")
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			res := f.Apply(ctx, NewMaybeWithValue(domain))
+			obs := rt.Observations()
+			if obs == nil || len(obs.Queries) != 1 {
+				t.Fatal("expected a single query")
+			}
+			if obs.Queries[0].QueryType != "HTTPS" || obs.Queries[0].Engine != "dot" {
+				t.Fatal("unexpected query", obs.Queries[0].QueryType, obs.Queries[0].Engine)
+			}
+			if res.Error == nil {
+				t.Fatalf("expected an error here")
+			}
+		})
+	})
+}
@@ -0,0 +1,343 @@
+package dslx
+
+//
+// Functional extensions (operational combinators)
+//
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ooni/probe-cli/v3/internal/measurexlite"
+	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/netxlite"
+)
+
+// saveDecision saves an annotation network event recording a decision taken by
+// one of the combinators defined in this file, along with the error that caused it.
+func saveDecision(rt Runtime, index int64, operation string, err error, tags ...string) {
+	t := time.Since(rt.ZeroTime())
+	ev := measurexlite.NewAnnotationArchivalNetworkEvent(index, t, operation, tags...)
+	ev.Failure = measurexlite.NewFailure(err)
+	rt.SaveObservations(&Observations{
+		NetworkEvents: []*model.ArchivalNetworkEvent{ev},
+	})
+}
+
+// sleepContext sleeps for the given duration unless the context is done first, in
+// which case it returns the context error.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// IsRetryableError returns whether err is a transient error that may go away
+// by retrying. We classify the error using netxlite's failure strings and we
+// only consider as retryable network conditions that may not be caused by
+// censorship (e.g., NXDOMAIN and TLS errors are deterministic enough that
+// retrying would mostly waste time and add noise to the measurement).
+func IsRetryableError(err error) bool {
+	if err == nil || errors.Is(err, ErrSkip) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	failure := measurexlite.NewFailure(err)
+	switch *failure {
+	case netxlite.FailureConnectionRefused,
+		netxlite.FailureDNSServerMisbehaving,
+		netxlite.FailureDNSServfailError,
+		netxlite.FailureDNSTemporaryFailure,
+		netxlite.FailureGenericTimeoutError,
+		netxlite.FailureHostUnreachable,
+		netxlite.FailureNetworkDown,
+		netxlite.FailureNetworkUnreachable,
+		netxlite.FailureTimedOut:
+		return true
+	default:
+		return false
+	}
+}
+
+// retryConfig contains the configuration for [Retry].
+type retryConfig struct {
+	backoff     time.Duration
+	classifier  func(err error) bool
+	maxAttempts int
+	maxBackoff  time.Duration
+	tags        []string
+}
+
+// RetryOption is an option for [Retry].
+type RetryOption func(config *retryConfig)
+
+// RetryOptionMaxAttempts sets the maximum number of attempts. The default is
+// three. Values lower than one are ignored.
+func RetryOptionMaxAttempts(value int) RetryOption {
+	return func(config *retryConfig) {
+		if value >= 1 {
+			config.maxAttempts = value
+		}
+	}
+}
+
+// RetryOptionBackoff configures the exponential backoff. The first retry waits
+// for initial, and every subsequent retry doubles the wait time up to max. The
+// default is to start from 500 milliseconds and to stop at four seconds.
+func RetryOptionBackoff(initial, max time.Duration) RetryOption {
+	return func(config *retryConfig) {
+		config.backoff = initial
+		config.maxBackoff = max
+	}
+}
+
+// RetryOptionClassifier sets the function deciding whether an error is
+// retryable. The default is [IsRetryableError].
+func RetryOptionClassifier(fx func(err error) bool) RetryOption {
+	return func(config *retryConfig) {
+		config.classifier = fx
+	}
+}
+
+// RetryOptionTags allows to set tags to tag the observations recording
+// the decisions taken by [Retry].
+func RetryOptionTags(value ...string) RetryOption {
+	return func(config *retryConfig) {
+		config.tags = append(config.tags, value...)
+	}
+}
+
+// Retry returns a [Func] that applies fx and retries it with exponential backoff
+// as long as it fails with a retryable error. Each decision is saved as an annotation
+// network event inside the runtime observations using the following operations:
+//
+// - retry_attempt: we are about to apply fx;
+//
+// - retry_backoff: fx failed with a retryable error and we're going to wait;
+//
+// - retry_give_up: fx failed with an error that is not retryable;
+//
+// - retry_exhausted: fx failed and there are no attempts left.
+//
+// The returned [Func] returns the result of the last attempt.
+func Retry[A, B any](rt Runtime, fx Func[A, B], options ...RetryOption) Func[A, B] {
+	config := &retryConfig{
+		backoff:     500 * time.Millisecond,
+		classifier:  IsRetryableError,
+		maxAttempts: 3,
+		maxBackoff:  4 * time.Second,
+		tags:        []string{},
+	}
+	for _, option := range options {
+		option(config)
+	}
+	return FuncAdapter[A, B](func(ctx context.Context, input *Maybe[A]) *Maybe[B] {
+		if err := input.Error; err != nil {
+			return NewMaybeWithError[B](err)
+		}
+		index := rt.IDGenerator().Add(1)
+		backoff := config.backoff
+		for attempt := 1; ; attempt++ {
+			saveDecision(rt, index, "retry_attempt", nil, config.tags...)
+			output := fx.Apply(ctx, input)
+			switch {
+			case output.Error == nil:
+				return output
+			case !config.classifier(output.Error):
+				saveDecision(rt, index, "retry_give_up", output.Error, config.tags...)
+				return output
+			case attempt >= config.maxAttempts:
+				saveDecision(rt, index, "retry_exhausted", output.Error, config.tags...)
+				return output
+			}
+			saveDecision(rt, index, "retry_backoff", output.Error, config.tags...)
+			if err := sleepContext(ctx, backoff); err != nil {
+				return output
+			}
+			backoff = min(2*backoff, config.maxBackoff)
+		}
+	})
+}
+
+// Timeout returns a [Func] that applies fx using a context that expires after the
+// given timeout. When the timeout expires, we save a timeout_expired annotation network
+// event inside the runtime observations. We do not save such an event when the
+// parent context is done, since in such a case the timeout was not the cause.
+func Timeout[A, B any](rt Runtime, timeout time.Duration, fx Func[A, B], tags ...string) Func[A, B] {
+	return FuncAdapter[A, B](func(ctx context.Context, input *Maybe[A]) *Maybe[B] {
+		if err := input.Error; err != nil {
+			return NewMaybeWithError[B](err)
+		}
+		index := rt.IDGenerator().Add(1)
+		tctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		output := fx.Apply(tctx, input)
+		if ctx.Err() == nil && errors.Is(tctx.Err(), context.DeadlineExceeded) {
+			saveDecision(rt, index, "timeout_expired", output.Error, tags...)
+		}
+		return output
+	})
+}
+
+// RateLimiter limits the rate at which [RateLimit] applies functions. The same
+// limiter is safe to use from multiple goroutines and is typically shared by
+// several pipelines, e.g., to avoid flooding a given server with requests.
+//
+// The zero value is invalid; construct using [NewRateLimiter].
+type RateLimiter struct {
+	interval time.Duration
+	mu       sync.Mutex
+	next     time.Time
+}
+
+// NewRateLimiter creates a [RateLimiter] ensuring that at least the given
+// interval elapses between starting two successive operations.
+func NewRateLimiter(interval time.Duration) *RateLimiter {
+	return &RateLimiter{
+		interval: interval,
+		mu:       sync.Mutex{},
+		next:     time.Time{},
+	}
+}
+
+// reserve reserves the next available slot and returns how long we need to wait.
+func (rl *RateLimiter) reserve() time.Duration {
+	defer rl.mu.Unlock()
+	rl.mu.Lock()
+	now := time.Now()
+	slot := rl.next
+	if slot.Before(now) {
+		slot = now
+	}
+	rl.next = slot.Add(rl.interval)
+	return slot.Sub(now)
+}
+
+// Wait blocks until we're allowed to start the next operation or the
+// context is done. It returns how long we waited and the context error, if any.
+func (rl *RateLimiter) Wait(ctx context.Context) (time.Duration, error) {
+	delay := rl.reserve()
+	if delay <= 0 {
+		return 0, nil
+	}
+	return delay, sleepContext(ctx, delay)
+}
+
+// RateLimit returns a [Func] that waits for the given [RateLimiter] before applying
+// fx. When we need to wait, we save a rate_limit_wait annotation network event inside
+// the runtime observations. If the context is done while waiting, we do not apply
+// fx and we return the context error.
+func RateLimit[A, B any](rt Runtime, limiter *RateLimiter, fx Func[A, B], tags ...string) Func[A, B] {
+	return FuncAdapter[A, B](func(ctx context.Context, input *Maybe[A]) *Maybe[B] {
+		if err := input.Error; err != nil {
+			return NewMaybeWithError[B](err)
+		}
+		delay, err := limiter.Wait(ctx)
+		if delay > 0 {
+			saveDecision(rt, rt.IDGenerator().Add(1), "rate_limit_wait", err, tags...)
+		}
+		if err != nil {
+			return NewMaybeWithError[B](err)
+		}
+		return fx.Apply(ctx, input)
+	})
+}
+
+// ErrFirstSuccessNoInputs indicates that [FirstSuccess] received no inputs.
+var ErrFirstSuccessNoInputs = errors.New("dslx: FirstSuccess: no inputs")
+
+// FirstSuccess returns a [Func] that races fx over a list of inputs (e.g., the
+// endpoints of a domain) using happy-eyeballs-like delays and returns the first
+// successful result. We start applying fx to the first input immediately. We start
+// each subsequent attempt after the given delay or as soon as the previous attempt
+// fails, whichever comes first. When an attempt succeeds, we cancel the context of
+// the attempts still in progress and ignore their results. Resources (e.g., connections)
+// created by losing attempts are tracked by the runtime and released by its Close.
+//
+// When all attempts fail, we return the error of the first attempt, which is
+// the one that has been running for the longest time.
+//
+// We save the following annotation network events inside the runtime observations:
+//
+// - first_success_start: we started a new attempt;
+//
+// - first_success_winner: an attempt succeeded;
+//
+// - first_success_failed: all the attempts failed.
+func FirstSuccess[A, B any](rt Runtime, delay time.Duration, fx Func[A, B], tags ...string) Func[[]A, B] {
+	return FuncAdapter[[]A, B](func(ctx context.Context, input *Maybe[[]A]) *Maybe[B] {
+		if err := input.Error; err != nil {
+			return NewMaybeWithError[B](err)
+		}
+		if len(input.State) <= 0 {
+			return NewMaybeWithError[B](ErrFirstSuccessNoInputs)
+		}
+		index := rt.IDGenerator().Add(1)
+
+		// make sure we interrupt the losers when we return
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		type result struct {
+			idx    int
+			output *Maybe[B]
+		}
+		results := make(chan *result, len(input.State)) // buffered so losers don't block
+
+		errs := make([]error, len(input.State))
+		var (
+			running int
+			next    int
+		)
+		timer := time.NewTimer(0)
+		defer timer.Stop()
+
+		for {
+			select {
+			case <-timer.C:
+				// fallthrough to start the next attempt below
+
+			case r := <-results:
+				running--
+				if r.output.Error == nil {
+					saveDecision(rt, index, "first_success_winner", nil, tags...)
+					return r.output
+				}
+				errs[r.idx] = r.output.Error
+				if running <= 0 && next >= len(input.State) {
+					saveDecision(rt, index, "first_success_failed", errs[0], tags...)
+					return NewMaybeWithError[B](errs[0])
+				}
+				// the previous attempt failed, so start the next one immediately
+				if !timer.Stop() {
+					select {
+					case <-timer.C:
+					default:
+					}
+				}
+
+			case <-ctx.Done():
+				err := ctx.Err()
+				saveDecision(rt, index, "first_success_failed", err, tags...)
+				return NewMaybeWithError[B](err)
+			}
+
+			if next < len(input.State) {
+				idx, value := next, input.State[next]
+				next++
+				running++
+				saveDecision(rt, index, "first_success_start", nil, tags...)
+				go func() {
+					results <- &result{idx, fx.Apply(ctx, NewMaybeWithValue(value))}
+				}()
+				timer.Reset(delay)
+			}
+		}
+	})
+}
@@ -0,0 +1,433 @@
+package dslx
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/netxlite"
+)
+
+// operations returns the operations of the given network events.
+func operations(events []*model.ArchivalNetworkEvent) (out []string) {
+	for _, ev := range events {
+		out = append(out, ev.Operation)
+	}
+	return
+}
+
+// newErrWrapper returns a netxlite.ErrWrapper with the given failure.
+func newErrWrapper(failure string) error {
+	return &netxlite.ErrWrapper{Failure: failure, WrappedErr: errors.New(failure)}
+}
+
+// sameOperations returns whether got and expect contain the same operations.
+func sameOperations(got, expect []string) bool {
+	if len(got) != len(expect) {
+		return false
+	}
+	for idx := range got {
+		if got[idx] != expect[idx] {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+Test cases:
+- IsRetryableError with nil, ErrSkip, context.Canceled, timeout, NXDOMAIN and TLS errors
+*/
+func TestIsRetryableError(t *testing.T) {
+	tests := map[string]struct {
+		err    error
+		expect bool
+	}{
+		"nil":       {err: nil, expect: false},
+		"skip":      {err: ErrSkip, expect: false},
+		"canceled":  {err: context.Canceled, expect: false},
+		"timeout":   {err: context.DeadlineExceeded, expect: true},
+		"refused":   {err: newErrWrapper(netxlite.FailureConnectionRefused), expect: true},
+		"nxdomain":  {err: newErrWrapper(netxlite.FailureDNSNXDOMAINError), expect: false},
+		"tls":       {err: newErrWrapper(netxlite.FailureSSLInvalidHostname), expect: false},
+		"reset":     {err: newErrWrapper(netxlite.FailureConnectionReset), expect: false},
+		"servfail":  {err: newErrWrapper(netxlite.FailureDNSServfailError), expect: true},
+		"temporary": {err: newErrWrapper(netxlite.FailureDNSTemporaryFailure), expect: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := IsRetryableError(tt.err); got != tt.expect {
+				t.Fatal("expected", tt.expect, "got", got)
+			}
+		})
+	}
+}
+
+/*
+Test cases:
+- Retry:
+  - with input error
+  - with success at the first attempt
+  - with success after retryable errors
+  - with a non retryable error
+  - with too many retryable errors
+  - with the context done while backing off
+*/
+func TestRetry(t *testing.T) {
+	// newFlakyOperation returns an operation that fails with the given errors before succeeding.
+	newFlakyOperation := func(errs ...error) (Func[int, int], *atomic.Int64) {
+		count := &atomic.Int64{}
+		return Operation[int, int](func(ctx context.Context, i int) (int, error) {
+			idx := count.Add(1) - 1
+			if idx < int64(len(errs)) {
+				return 0, errs[idx]
+			}
+			return i + 1, nil
+		}), count
+	}
+
+	timeoutErr := newErrWrapper(netxlite.FailureGenericTimeoutError)
+
+	t.Run("with input error", func(t *testing.T) {
+		rt := NewMinimalRuntime(model.DiscardLogger, time.Now())
+		fx, count := newFlakyOperation()
+		expected := errors.New("mocked error")
+		output := Retry(rt, fx).Apply(context.Background(), NewMaybeWithError[int](expected))
+		if !errors.Is(output.Error, expected) {
+			t.Fatal("unexpected error", output.Error)
+		}
+		if count.Load() != 0 {
+			t.Fatal("should not have called the function")
+		}
+		events := rt.Observations().NetworkEvents
+		if len(events) != 0 {
+			t.Fatal("expected no network events")
+		}
+	})
+
+	t.Run("with success at the first attempt", func(t *testing.T) {
+		rt := NewMinimalRuntime(model.DiscardLogger, time.Now())
+		fx, _ := newFlakyOperation()
+		output := Retry(rt, fx).Apply(context.Background(), NewMaybeWithValue(1))
+		if output.Error != nil || output.State != 2 {
+			t.Fatal("unexpected output", output)
+		}
+		events := rt.Observations().NetworkEvents
+		if got := operations(events); !sameOperations(got, []string{"retry_attempt"}) {
+			t.Fatal("unexpected operations", got)
+		}
+	})
+
+	t.Run("with success after retryable errors", func(t *testing.T) {
+		rt := NewMinimalRuntime(model.DiscardLogger, time.Now())
+		fx, count := newFlakyOperation(timeoutErr, timeoutErr)
+		retry := Retry(rt, fx, RetryOptionBackoff(time.Millisecond, 2*time.Millisecond), RetryOptionTags("antani"))
+		output := retry.Apply(context.Background(), NewMaybeWithValue(1))
+		if output.Error != nil || output.State != 2 {
+			t.Fatal("unexpected output", output)
+		}
+		if count.Load() != 3 {
+			t.Fatal("unexpected number of attempts", count.Load())
+		}
+		expect := []string{"retry_attempt", "retry_backoff", "retry_attempt", "retry_backoff", "retry_attempt"}
+		events := rt.Observations().NetworkEvents
+		if got := operations(events); !sameOperations(got, expect) {
+			t.Fatal("unexpected operations", got)
+		}
+		ev := events[1]
+		if ev.Failure == nil || *ev.Failure != netxlite.FailureGenericTimeoutError {
+			t.Fatal("unexpected failure", ev.Failure)
+		}
+		if len(ev.Tags) != 1 || ev.Tags[0] != "antani" {
+			t.Fatal("unexpected tags", ev.Tags)
+		}
+	})
+
+	t.Run("with a non retryable error", func(t *testing.T) {
+		rt := NewMinimalRuntime(model.DiscardLogger, time.Now())
+		fx, count := newFlakyOperation(newErrWrapper(netxlite.FailureDNSNXDOMAINError))
+		output := Retry(rt, fx).Apply(context.Background(), NewMaybeWithValue(1))
+		if output.Error == nil || output.Error.Error() != netxlite.FailureDNSNXDOMAINError {
+			t.Fatal("unexpected error", output.Error)
+		}
+		if count.Load() != 1 {
+			t.Fatal("unexpected number of attempts", count.Load())
+		}
+		events := rt.Observations().NetworkEvents
+		if got := operations(events); !sameOperations(got, []string{"retry_attempt", "retry_give_up"}) {
+			t.Fatal("unexpected operations", got)
+		}
+	})
+
+	t.Run("with too many retryable errors", func(t *testing.T) {
+		rt := NewMinimalRuntime(model.DiscardLogger, time.Now())
+		fx, count := newFlakyOperation(timeoutErr, timeoutErr, timeoutErr)
+		retry := Retry(rt, fx, RetryOptionMaxAttempts(2), RetryOptionBackoff(time.Millisecond, time.Millisecond))
+		output := retry.Apply(context.Background(), NewMaybeWithValue(1))
+		if !errors.Is(output.Error, timeoutErr) {
+			t.Fatal("unexpected error", output.Error)
+		}
+		if count.Load() != 2 {
+			t.Fatal("unexpected number of attempts", count.Load())
+		}
+		expect := []string{"retry_attempt", "retry_backoff", "retry_attempt", "retry_exhausted"}
+		events := rt.Observations().NetworkEvents
+		if got := operations(events); !sameOperations(got, expect) {
+			t.Fatal("unexpected operations", got)
+		}
+	})
+
+	t.Run("with the context done while backing off", func(t *testing.T) {
+		rt := NewMinimalRuntime(model.DiscardLogger, time.Now())
+		ctx, cancel := context.WithCancel(context.Background())
+		fx := Operation[int, int](func(_ context.Context, i int) (int, error) {
+			cancel()
+			return 0, timeoutErr
+		})
+		retry := Retry[int, int](rt, fx, RetryOptionBackoff(time.Hour, time.Hour))
+		output := retry.Apply(ctx, NewMaybeWithValue(1))
+		if !errors.Is(output.Error, timeoutErr) {
+			t.Fatal("unexpected error", output.Error)
+		}
+		events := rt.Observations().NetworkEvents
+		if got := operations(events); !sameOperations(got, []string{"retry_attempt", "retry_backoff"}) {
+			t.Fatal("unexpected operations", got)
+		}
+	})
+}
+
+/*
+Test cases:
+- Timeout:
+  - with input error
+  - when the function completes in time
+  - when the timeout expires
+*/
+func TestTimeout(t *testing.T) {
+	blocking := Operation[int, int](func(ctx context.Context, i int) (int, error) {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	})
+
+	t.Run("with input error", func(t *testing.T) {
+		rt := NewMinimalRuntime(model.DiscardLogger, time.Now())
+		expected := errors.New("mocked error")
+		output := Timeout[int, int](rt, time.Second, blocking).Apply(context.Background(), NewMaybeWithError[int](expected))
+		if !errors.Is(output.Error, expected) {
+			t.Fatal("unexpected error", output.Error)
+		}
+	})
+
+	t.Run("when the function completes in time", func(t *testing.T) {
+		rt := NewMinimalRuntime(model.DiscardLogger, time.Now())
+		output := Timeout(rt, time.Second, getFn(nil, "succeed")).Apply(context.Background(), NewMaybeWithValue(1))
+		if output.Error != nil || output.State != 2 {
+			t.Fatal("unexpected output", output)
+		}
+		events := rt.Observations().NetworkEvents
+		if len(events) != 0 {
+			t.Fatal("expected no network events")
+		}
+	})
+
+	t.Run("when the timeout expires", func(t *testing.T) {
+		rt := NewMinimalRuntime(model.DiscardLogger, time.Now())
+		output := Timeout[int, int](rt, 10*time.Millisecond, blocking, "antani").Apply(context.Background(), NewMaybeWithValue(1))
+		if !errors.Is(output.Error, context.DeadlineExceeded) {
+			t.Fatal("unexpected error", output.Error)
+		}
+		events := rt.Observations().NetworkEvents
+		if got := operations(events); !sameOperations(got, []string{"timeout_expired"}) {
+			t.Fatal("unexpected operations", got)
+		}
+		ev := events[0]
+		if ev.Failure == nil || *ev.Failure != netxlite.FailureGenericTimeoutError {
+			t.Fatal("unexpected failure", ev.Failure)
+		}
+	})
+}
+
+/*
+Test cases:
+- RateLimit:
+  - with input error
+  - with several goroutines sharing the same limiter
+  - with the context done while waiting
+*/
+func TestRateLimit(t *testing.T) {
+	t.Run("with input error", func(t *testing.T) {
+		rt := NewMinimalRuntime(model.DiscardLogger, time.Now())
+		expected := errors.New("mocked error")
+		fx := RateLimit(rt, NewRateLimiter(time.Hour), getFn(nil, "succeed"))
+		output := fx.Apply(context.Background(), NewMaybeWithError[int](expected))
+		if !errors.Is(output.Error, expected) {
+			t.Fatal("unexpected error", output.Error)
+		}
+	})
+
+	t.Run("with several goroutines sharing the same limiter", func(t *testing.T) {
+		rt := NewMinimalRuntime(model.DiscardLogger, time.Now())
+		const interval, count = 20 * time.Millisecond, 4
+		fx := RateLimit(rt, NewRateLimiter(interval), getFn(nil, "succeed"))
+		t0 := time.Now()
+		wg := &sync.WaitGroup{}
+		for idx := 0; idx < count; idx++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if output := fx.Apply(context.Background(), NewMaybeWithValue(1)); output.Error != nil {
+					t.Error(output.Error)
+				}
+			}()
+		}
+		wg.Wait()
+		if elapsed := time.Since(t0); elapsed < (count-1)*interval {
+			t.Fatal("completed too quickly", elapsed)
+		}
+		events := rt.Observations().NetworkEvents
+		// the first operation does not need to wait
+		if got := operations(events); !sameOperations(got, []string{"rate_limit_wait", "rate_limit_wait", "rate_limit_wait"}) {
+			t.Fatal("unexpected operations", got)
+		}
+	})
+
+	t.Run("with the context done while waiting", func(t *testing.T) {
+		rt := NewMinimalRuntime(model.DiscardLogger, time.Now())
+		limiter := NewRateLimiter(time.Hour)
+		fx := RateLimit(rt, limiter, getFn(nil, "succeed"))
+		if output := fx.Apply(context.Background(), NewMaybeWithValue(1)); output.Error != nil {
+			t.Fatal(output.Error)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		output := fx.Apply(ctx, NewMaybeWithValue(1))
+		if !errors.Is(output.Error, context.Canceled) {
+			t.Fatal("unexpected error", output.Error)
+		}
+		events := rt.Observations().NetworkEvents
+		ev := events[0]
+		if ev.Operation != "rate_limit_wait" || ev.Failure == nil || *ev.Failure != netxlite.FailureInterrupted {
+			t.Fatal("unexpected event", ev)
+		}
+	})
+}
+
+/*
+Test cases:
+- FirstSuccess:
+  - with input error
+  - with no inputs
+  - when the first attempt wins
+  - when a later attempt wins after the delay
+  - when attempts fail and we move on immediately
+  - when all attempts fail
+  - with the context done
+*/
+func TestFirstSuccess(t *testing.T) {
+	// newOperation returns an operation sleeping for the given delay and then
+	// failing with the given error, using the input as the index.
+	newOperation := func(delays []time.Duration, errs []error) Func[int, int] {
+		return Operation[int, int](func(ctx context.Context, i int) (int, error) {
+			select {
+			case <-time.After(delays[i]):
+				return i, errs[i]
+			case <-ctx.Done():
+				return 0, ctx.Err()
+			}
+		})
+	}
+
+	t.Run("with input error", func(t *testing.T) {
+		rt := NewMinimalRuntime(model.DiscardLogger, time.Now())
+		expected := errors.New("mocked error")
+		fx := FirstSuccess(rt, time.Second, getFn(nil, "succeed"))
+		output := fx.Apply(context.Background(), NewMaybeWithError[[]int](expected))
+		if !errors.Is(output.Error, expected) {
+			t.Fatal("unexpected error", output.Error)
+		}
+	})
+
+	t.Run("with no inputs", func(t *testing.T) {
+		rt := NewMinimalRuntime(model.DiscardLogger, time.Now())
+		fx := FirstSuccess(rt, time.Second, getFn(nil, "succeed"))
+		output := fx.Apply(context.Background(), NewMaybeWithValue([]int{}))
+		if !errors.Is(output.Error, ErrFirstSuccessNoInputs) {
+			t.Fatal("unexpected error", output.Error)
+		}
+	})
+
+	t.Run("when the first attempt wins", func(t *testing.T) {
+		rt := NewMinimalRuntime(model.DiscardLogger, time.Now())
+		fx := FirstSuccess(rt, time.Second, newOperation(
+			[]time.Duration{0, 0}, []error{nil, nil}), "antani")
+		output := fx.Apply(context.Background(), NewMaybeWithValue([]int{0, 1}))
+		if output.Error != nil || output.State != 0 {
+			t.Fatal("unexpected output", output)
+		}
+		events := rt.Observations().NetworkEvents
+		if got := operations(events); !sameOperations(got, []string{"first_success_start", "first_success_winner"}) {
+			t.Fatal("unexpected operations", got)
+		}
+	})
+
+	t.Run("when a later attempt wins after the delay", func(t *testing.T) {
+		rt := NewMinimalRuntime(model.DiscardLogger, time.Now())
+		fx := FirstSuccess(rt, 10*time.Millisecond, newOperation(
+			[]time.Duration{time.Hour, 0}, []error{nil, nil}))
+		output := fx.Apply(context.Background(), NewMaybeWithValue([]int{0, 1}))
+		if output.Error != nil || output.State != 1 {
+			t.Fatal("unexpected output", output)
+		}
+		expect := []string{"first_success_start", "first_success_start", "first_success_winner"}
+		events := rt.Observations().NetworkEvents
+		if got := operations(events); !sameOperations(got, expect) {
+			t.Fatal("unexpected operations", got)
+		}
+	})
+
+	t.Run("when attempts fail and we move on immediately", func(t *testing.T) {
+		rt := NewMinimalRuntime(model.DiscardLogger, time.Now())
+		fx := FirstSuccess(rt, time.Hour, newOperation(
+			[]time.Duration{0, 0}, []error{errors.New("mocked error"), nil}))
+		output := fx.Apply(context.Background(), NewMaybeWithValue([]int{0, 1}))
+		if output.Error != nil || output.State != 1 {
+			t.Fatal("unexpected output", output)
+		}
+	})
+
+	t.Run("when all attempts fail", func(t *testing.T) {
+		rt := NewMinimalRuntime(model.DiscardLogger, time.Now())
+		first, second := errors.New("first error"), errors.New("second error")
+		fx := FirstSuccess(rt, time.Millisecond, newOperation(
+			[]time.Duration{10 * time.Millisecond, 0}, []error{first, second}))
+		output := fx.Apply(context.Background(), NewMaybeWithValue([]int{0, 1}))
+		if !errors.Is(output.Error, first) {
+			t.Fatal("unexpected error", output.Error)
+		}
+		expect := []string{"first_success_start", "first_success_start", "first_success_failed"}
+		events := rt.Observations().NetworkEvents
+		if got := operations(events); !sameOperations(got, expect) {
+			t.Fatal("unexpected operations", got)
+		}
+	})
+
+	t.Run("with the context done", func(t *testing.T) {
+		rt := NewMinimalRuntime(model.DiscardLogger, time.Now())
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		fx := FirstSuccess(rt, time.Hour, newOperation(
+			[]time.Duration{time.Hour}, []error{nil}))
+		output := fx.Apply(ctx, NewMaybeWithValue([]int{0}))
+		if output.Error == nil {
+			t.Fatal("expected an error")
+		}
+		events := rt.Observations().NetworkEvents
+		if got := operations(events); got[len(got)-1] != "first_success_failed" {
+			t.Fatal("unexpected operations", got)
+		}
+	})
+}
@@ -150,6 +150,17 @@ func (tx *minimalTrace) NewDialerWithoutResolver(dl model.DebugLogger, wrappers
 	return tx.netx.NewDialerWithoutResolver(dl, wrappers...)
 }
 
+// NewParallelDNSOverHTTPSResolver implements Trace.
+func (tx *minimalTrace) NewParallelDNSOverHTTPSResolver(logger model.DebugLogger, URL string) model.Resolver {
+	return tx.netx.NewParallelDNSOverHTTPSResolver(logger, URL)
+}
+
+// NewParallelDNSOverTLSResolver implements Trace.
+func (tx *minimalTrace) NewParallelDNSOverTLSResolver(logger model.DebugLogger, address string) model.Resolver {
+	tlsDialer := netxlite.NewTLSDialer(tx.netx.NewDialerWithoutResolver(logger), tx.netx.NewTLSHandshakerStdlib(logger))
+	return netxlite.NewParallelDNSOverTLSResolver(logger, tlsDialer, address)
+}
+
 // NewParallelUDPResolver implements Trace.
 func (tx *minimalTrace) NewParallelUDPResolver(logger model.DebugLogger, dialer model.Dialer, address string) model.Resolver {
 	return tx.netx.NewParallelUDPResolver(logger, dialer, address)
@@ -167,6 +167,20 @@ func TestMinimalRuntime(t *testing.T) {
 			}
 		})
 
+		t.Run("NewParallelDNSOverHTTPSResolver", func(t *testing.T) {
+			out := trace.NewParallelDNSOverHTTPSResolver(model.DiscardLogger, "https://8.8.8.8/dns-query")
+			if out == nil {
+				t.Fatal("expected non-nil pointer")
+			}
+		})
+
+		t.Run("NewParallelDNSOverTLSResolver", func(t *testing.T) {
+			out := trace.NewParallelDNSOverTLSResolver(model.DiscardLogger, "8.8.8.8:853")
+			if out == nil {
+				t.Fatal("expected non-nil pointer")
+			}
+		})
+
 		t.Run("NewParallelUDPResolver", func(t *testing.T) {
 			out := trace.NewParallelUDPResolver(model.DiscardLogger, &mocks.Dialer{}, "8.8.8.8:53")
 			if out == nil {
@@ -29,6 +29,12 @@ type Trace interface {
 	// model.MeasuringNetwork interface, but they're not used by this function.
 	NewDialerWithoutResolver(dl model.DebugLogger, wrappers ...model.DialerWrapper) model.Dialer
 
+	// NewParallelDNSOverHTTPSResolver returns a possibly-trace-ware parallel DoH resolver
+	NewParallelDNSOverHTTPSResolver(logger model.DebugLogger, URL string) model.Resolver
+
+	// NewParallelDNSOverTLSResolver returns a possibly-trace-ware parallel DoT resolver
+	NewParallelDNSOverTLSResolver(logger model.DebugLogger, address string) model.Resolver
+
 	// NewParallelUDPResolver returns a possibly-trace-ware parallel UDP resolver
 	NewParallelUDPResolver(logger model.DebugLogger, dialer model.Dialer, address string) model.Resolver
 
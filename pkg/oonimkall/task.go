@@ -25,6 +25,7 @@ type Task struct {
 	isstarted chan interface{} // for testing
 	isstopped chan interface{} // for testing
 	out       chan *event
+	runner    taskRunner
 }
 
 // StartTask starts an asynchronous task. The input argument is a
@@ -36,18 +37,19 @@ func StartTask(input string) (*Task, error) {
 	}
 	const bufsiz = 128 // common case: we don't want runner to block
 	ctx, cancel := context.WithCancel(context.Background())
+	out := make(chan *event, bufsiz)
+	emitter := newTaskEmitterUsingChan(out)
 	task := &Task{
 		cancel:    cancel,
 		isdone:    &atomic.Int64{},
 		isstarted: make(chan interface{}),
 		isstopped: make(chan interface{}),
-		out:       make(chan *event, bufsiz),
+		out:       out,
+		runner:    newRunner(&settings, emitter),
 	}
 	go func() {
 		close(task.isstarted)
-		emitter := newTaskEmitterUsingChan(task.out)
-		r := newRunner(&settings, emitter)
-		r.Run(ctx)
+		task.runner.Run(ctx)
 		task.out <- nil // signal that we're done w/o closing the channel
 		_ = emitter.Close()
 		close(task.isstopped)
@@ -81,3 +83,16 @@ func (t *Task) IsDone() bool {
 func (t *Task) Interrupt() {
 	t.cancel()
 }
+
+// Pause pauses the task, which stops before measuring the next input and
+// emits the status.paused event. Note that the maximum runtime, if any, also
+// accounts for the time in which the task is paused. Interrupting a paused
+// task causes the task to terminate without resuming it.
+func (t *Task) Pause() {
+	t.runner.Pause()
+}
+
+// Resume resumes a paused task, which emits the status.resumed event.
+func (t *Task) Resume() {
+	t.runner.Resume()
+}
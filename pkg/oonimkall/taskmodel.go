@@ -65,16 +65,38 @@ const (
 	eventTypeMeasurement                  = "measurement"
 	eventTypeStatusEnd                    = "status.end"
 	eventTypeStatusGeoIPLookup            = "status.geoip_lookup"
+	eventTypeStatusInputEnd               = "status.input_end"
+	eventTypeStatusInputStart             = "status.input_start"
 	eventTypeStatusMeasurementDone        = "status.measurement_done"
 	eventTypeStatusMeasurementStart       = "status.measurement_start"
 	eventTypeStatusMeasurementSubmission  = "status.measurement_submission"
+	eventTypeStatusPaused                 = "status.paused"
+	eventTypeStatusPhase                  = "status.phase"
 	eventTypeStatusProgress               = "status.progress"
 	eventTypeStatusQueued                 = "status.queued"
 	eventTypeStatusReportCreate           = "status.report_create"
 	eventTypeStatusResolverLookup         = "status.resolver_lookup"
+	eventTypeStatusResumed                = "status.resumed"
 	eventTypeStatusStarted                = "status.started"
 )
 
+// phases of a running task, which we emit using the status.phase event.
+const (
+	// taskPhaseGeolocation is when we discover the OONI backends
+	// and we lookup the probe location.
+	taskPhaseGeolocation = "geolocation"
+
+	// taskPhaseCheckIn is when we load the targets, which may
+	// entail calling the check-in API, and we open the report.
+	taskPhaseCheckIn = "check_in"
+
+	// taskPhaseMeasuring is when we measure a target.
+	taskPhaseMeasuring = "measuring"
+
+	// taskPhaseSubmitting is when we submit a measurement.
+	taskPhaseSubmitting = "submitting"
+)
+
 // taskEmitter is anything that allows us to
 // emit events while running a task.
 //
@@ -133,6 +155,36 @@ type eventStatusProgress struct {
 	Percentage float64 `json:"percentage"`
 }
 
+// eventStatusInputStart is emitted before measuring a target.
+type eventStatusInputStart struct {
+	CategoryCode string `json:"category_code,omitempty"`
+	CountryCode  string `json:"country_code,omitempty"`
+	Idx          int64  `json:"idx"`
+	Input        string `json:"input"`
+	Total        int64  `json:"total"`
+}
+
+// eventStatusInputEnd is emitted after we have measured a target and
+// possibly submitted the measurement. The DownloadedKB and UploadedKB
+// fields count the bytes transferred since the beginning of the task,
+// while ETA estimates the seconds required to measure the remaining
+// targets, given the average time required to measure a target.
+type eventStatusInputEnd struct {
+	DownloadedKB float64 `json:"downloaded_kb"`
+	ETA          float64 `json:"eta"`
+	Failure      string  `json:"failure,omitempty"`
+	Idx          int64   `json:"idx"`
+	Input        string  `json:"input"`
+	Runtime      float64 `json:"runtime"`
+	Total        int64   `json:"total"`
+	UploadedKB   float64 `json:"uploaded_kb"`
+}
+
+// eventStatusPhase reports that the task entered a new phase.
+type eventStatusPhase struct {
+	Phase string `json:"phase"`
+}
+
 type eventStatusReportGeneric struct {
 	ReportID string `json:"report_id"`
 }
@@ -217,6 +269,12 @@ type taskSession interface {
 type taskRunner interface {
 	// Run runs until completion.
 	Run(ctx context.Context)
+
+	// Pause pauses the task before it measures the next target.
+	Pause()
+
+	// Resume resumes a paused task.
+	Resume()
 }
 
 //
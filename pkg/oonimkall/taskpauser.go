@@ -0,0 +1,50 @@
+package oonimkall
+
+import "sync"
+
+// taskPauser allows to pause and resume a running task.
+//
+// The zero value is invalid; please, use newTaskPauser.
+type taskPauser struct {
+	// mu provides mutual exclusion.
+	mu sync.Mutex
+
+	// resumed is closed when the task is resumed and is nil
+	// when the task is not paused.
+	resumed chan any
+}
+
+// newTaskPauser creates a new taskPauser.
+func newTaskPauser() *taskPauser {
+	return &taskPauser{
+		mu:      sync.Mutex{},
+		resumed: nil,
+	}
+}
+
+// Pause pauses the task. This function is idempotent.
+func (p *taskPauser) Pause() {
+	defer p.mu.Unlock()
+	p.mu.Lock()
+	if p.resumed == nil {
+		p.resumed = make(chan any)
+	}
+}
+
+// Resume resumes the task. This function is idempotent.
+func (p *taskPauser) Resume() {
+	defer p.mu.Unlock()
+	p.mu.Lock()
+	if p.resumed != nil {
+		close(p.resumed)
+		p.resumed = nil
+	}
+}
+
+// Paused returns a channel closed when the task is resumed if the task
+// is paused and returns nil otherwise.
+func (p *taskPauser) Paused() <-chan any {
+	defer p.mu.Unlock()
+	p.mu.Lock()
+	return p.resumed
+}
@@ -0,0 +1,31 @@
+package oonimkall
+
+import "testing"
+
+func TestTaskPauser(t *testing.T) {
+	t.Run("a new pauser is not paused", func(t *testing.T) {
+		p := newTaskPauser()
+		if p.Paused() != nil {
+			t.Fatal("expected nil channel")
+		}
+	})
+
+	t.Run("we can pause and resume", func(t *testing.T) {
+		p := newTaskPauser()
+		p.Pause()
+		resumed := p.Paused()
+		if resumed == nil {
+			t.Fatal("expected non-nil channel")
+		}
+		p.Pause() // idempotent
+		if p.Paused() != resumed {
+			t.Fatal("expected the same channel")
+		}
+		p.Resume()
+		<-resumed // should not block
+		if p.Paused() != nil {
+			t.Fatal("expected nil channel")
+		}
+		p.Resume() // idempotent
+	})
+}
@@ -0,0 +1,57 @@
+package oonimkall
+
+import "time"
+
+// taskProgress tracks the progress of measuring the targets and
+// estimates the time required to measure the remaining targets.
+type taskProgress struct {
+	// completed is the number of targets we have measured.
+	completed int64
+
+	// deadline is the time when we stop measuring or the zero
+	// value when there is no maximum runtime.
+	deadline time.Time
+
+	// elapsed is the time spent measuring the completed targets.
+	elapsed time.Duration
+
+	// total is the total number of targets.
+	total int64
+}
+
+// newTaskProgress creates a new taskProgress for the given number of
+// targets and the given deadline, which may be the zero value.
+func newTaskProgress(total int, deadline time.Time) *taskProgress {
+	return &taskProgress{
+		completed: 0,
+		deadline:  deadline,
+		elapsed:   0,
+		total:     int64(total),
+	}
+}
+
+// Done records that we have measured a target in the given time.
+func (p *taskProgress) Done(runtime time.Duration) {
+	p.completed++
+	p.elapsed += runtime
+}
+
+// ETA returns the estimated time required to measure the remaining targets,
+// which is the average time per target multiplied by the number of remaining
+// targets, capped by the time left before the deadline, if any.
+func (p *taskProgress) ETA(now time.Time) time.Duration {
+	if p.completed <= 0 || p.completed >= p.total {
+		return 0
+	}
+	eta := (p.elapsed / time.Duration(p.completed)) * time.Duration(p.total-p.completed)
+	if !p.deadline.IsZero() {
+		left := p.deadline.Sub(now)
+		if left < 0 {
+			left = 0
+		}
+		if left < eta {
+			eta = left
+		}
+	}
+	return eta
+}
@@ -0,0 +1,44 @@
+package oonimkall
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTaskProgress(t *testing.T) {
+	t.Run("the ETA is zero before measuring any target", func(t *testing.T) {
+		p := newTaskProgress(10, time.Time{})
+		if eta := p.ETA(time.Now()); eta != 0 {
+			t.Fatal("unexpected ETA", eta)
+		}
+	})
+
+	t.Run("the ETA uses the average time per target", func(t *testing.T) {
+		p := newTaskProgress(4, time.Time{})
+		p.Done(1 * time.Second)
+		p.Done(3 * time.Second)
+		if eta := p.ETA(time.Now()); eta != 4*time.Second {
+			t.Fatal("unexpected ETA", eta)
+		}
+	})
+
+	t.Run("the ETA is zero after measuring all targets", func(t *testing.T) {
+		p := newTaskProgress(1, time.Time{})
+		p.Done(time.Second)
+		if eta := p.ETA(time.Now()); eta != 0 {
+			t.Fatal("unexpected ETA", eta)
+		}
+	})
+
+	t.Run("the ETA does not exceed the deadline", func(t *testing.T) {
+		now := time.Now()
+		p := newTaskProgress(100, now.Add(5*time.Second))
+		p.Done(time.Second)
+		if eta := p.ETA(now); eta != 5*time.Second {
+			t.Fatal("unexpected ETA", eta)
+		}
+		if eta := p.ETA(now.Add(time.Minute)); eta != 0 {
+			t.Fatal("unexpected ETA", eta)
+		}
+	})
+}
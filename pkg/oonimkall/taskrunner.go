@@ -18,6 +18,7 @@ type runnerForTask struct {
 	emitter    *taskEmitterWrapper
 	newKVStore func(path string) (model.KeyValueStore, error)
 	newSession func(ctx context.Context, config engine.SessionConfig) (taskSession, error)
+	pauser     *taskPauser
 	settings   *settings
 }
 
@@ -39,6 +40,7 @@ func newRunner(settings *settings, emitter taskEmitter) *runnerForTask {
 			// factory returns a nil *engine.Session because of golang nil conversion.
 			return engine.NewSession(ctx, config)
 		},
+		pauser:   newTaskPauser(),
 		settings: settings,
 	}
 }
@@ -98,6 +100,32 @@ func (r *runnerForTask) contextForExperiment(
 	return context.Background()
 }
 
+// Pause implements taskRunner.
+func (r *runnerForTask) Pause() {
+	r.pauser.Pause()
+}
+
+// Resume implements taskRunner.
+func (r *runnerForTask) Resume() {
+	r.pauser.Resume()
+}
+
+// waitWhilePaused blocks while the task is paused or until the context is done.
+func (r *runnerForTask) waitWhilePaused(ctx context.Context, logger model.Logger) {
+	resumed := r.pauser.Paused()
+	if resumed == nil {
+		return
+	}
+	logger.Info("Task paused")
+	r.emitter.Emit(eventTypeStatusPaused, eventEmpty{})
+	select {
+	case <-resumed:
+		logger.Info("Task resumed")
+		r.emitter.Emit(eventTypeStatusResumed, eventEmpty{})
+	case <-ctx.Done():
+	}
+}
+
 type runnerCallbacks struct {
 	emitter taskEmitter
 }
@@ -143,6 +171,15 @@ func (r *runnerForTask) Run(rootCtx context.Context) {
 		return
 	}
 
+	// make sure we emit the status.phase event only when the phase changes
+	var currentPhase string
+	enterPhase := func(phase string) {
+		if phase != currentPhase {
+			currentPhase = phase
+			r.emitter.Emit(eventTypeStatusPhase, eventStatusPhase{Phase: phase})
+		}
+	}
+
 	// make sure we emit the status.end event when we're done
 	endEvent := new(eventStatusEnd)
 	defer func() {
@@ -158,6 +195,7 @@ func (r *runnerForTask) Run(rootCtx context.Context) {
 	}
 
 	// choose the proper OONI backend to use
+	enterPhase(taskPhaseGeolocation)
 	logger.Info("Looking up OONI backends... please, be patient")
 	if err := sess.MaybeLookupBackendsContext(rootCtx); err != nil {
 		r.emitter.EmitFailureStartup(err.Error())
@@ -192,6 +230,7 @@ func (r *runnerForTask) Run(rootCtx context.Context) {
 	builder.SetCallbacks(&runnerCallbacks{emitter: r.emitter})
 
 	// load targets using the experiment-specific loader
+	enterPhase(taskPhaseCheckIn)
 	loader := builder.NewTargetLoader(&model.ExperimentTargetLoaderConfig{
 		CheckInConfig: &model.OOAPICheckInConfig{
 			// TODO(https://github.com/ooni/probe/issues/2766): to correctly load Web Connectivity targets
@@ -275,13 +314,45 @@ func (r *runnerForTask) Run(rootCtx context.Context) {
 	start := time.Now()
 	inflatedMaxRuntime := r.settings.Options.MaxRuntime + r.settings.Options.MaxRuntime/10
 	eta := start.Add(time.Duration(inflatedMaxRuntime) * time.Second)
+	deadline, _ := measCtx.Deadline()
+	progress := newTaskProgress(inputCount, deadline)
 
 	for idx, target := range targets {
+		// honour the user's request to pause the task
+		r.waitWhilePaused(measCtx, logger)
+
 		// handle the case where the time allocated for measuring has elapsed
 		if measCtx.Err() != nil {
 			break
 		}
 
+		// notify the mobile app that we are about to measure the target
+		enterPhase(taskPhaseMeasuring)
+		inputStart := time.Now()
+		r.emitter.Emit(eventTypeStatusInputStart, eventStatusInputStart{
+			CategoryCode: target.Category(),
+			CountryCode:  target.Country(),
+			Idx:          int64(idx),
+			Input:        target.Input(),
+			Total:        int64(inputCount),
+		})
+
+		// emitInputEnd notifies the mobile app that we're done with the target
+		emitInputEnd := func(failure string) {
+			runtime := time.Since(inputStart)
+			progress.Done(runtime)
+			r.emitter.Emit(eventTypeStatusInputEnd, eventStatusInputEnd{
+				DownloadedKB: experiment.KibiBytesReceived(),
+				ETA:          progress.ETA(time.Now()).Seconds(),
+				Failure:      failure,
+				Idx:          int64(idx),
+				Input:        target.Input(),
+				Runtime:      runtime.Seconds(),
+				Total:        int64(inputCount),
+				UploadedKB:   experiment.KibiBytesSent(),
+			})
+		}
+
 		// notify the mobile app that we are about to measure a specific target
 		//
 		// note that here we provide also the CategoryCode and the CountryCode
@@ -328,6 +399,7 @@ func (r *runnerForTask) Run(rootCtx context.Context) {
 				Idx:     int64(idx),
 				Input:   target.Input(),
 			})
+			emitInputEnd(err.Error())
 			// Historical note: here we used to fallthrough but, since we have
 			// implemented async measurements, the case where there is an error
 			// and we also have a valid measurement cant't happen anymore. So,
@@ -351,6 +423,7 @@ func (r *runnerForTask) Run(rootCtx context.Context) {
 
 		// if possible, submit the measurement to the OONI backend
 		if !r.settings.Options.NoCollector {
+			enterPhase(taskPhaseSubmitting)
 			logger.Info("Submitting measurement... please, be patient")
 			err := experiment.SubmitAndUpdateMeasurementContext(submitCtx, m)
 			warnOnFailure(logger, "cannot submit measurement", err)
@@ -367,6 +440,7 @@ func (r *runnerForTask) Run(rootCtx context.Context) {
 			Idx:   int64(idx),
 			Input: target.Input(),
 		})
+		emitInputEnd("")
 	}
 }
 
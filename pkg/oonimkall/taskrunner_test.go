@@ -343,6 +343,7 @@ func TestTaskRunnerRun(t *testing.T) {
 		expect := []eventKeyCount{
 			{Key: eventTypeStatusQueued, Count: 1},
 			{Key: eventTypeStatusStarted, Count: 1},
+			{Key: eventTypeStatusPhase, Count: 1},
 			{Key: eventTypeFailureStartup, Count: 1},
 			{Key: eventTypeStatusEnd, Count: 1},
 		}
@@ -361,6 +362,7 @@ func TestTaskRunnerRun(t *testing.T) {
 		expect := []eventKeyCount{
 			{Key: eventTypeStatusQueued, Count: 1},
 			{Key: eventTypeStatusStarted, Count: 1},
+			{Key: eventTypeStatusPhase, Count: 1},
 			{Key: eventTypeStatusProgress, Count: 1},
 			{Key: eventTypeFailureIPLookup, Count: 1},
 			{Key: eventTypeFailureASNLookup, Count: 1},
@@ -387,9 +389,11 @@ func TestTaskRunnerRun(t *testing.T) {
 		expect := []eventKeyCount{
 			{Key: eventTypeStatusQueued, Count: 1},
 			{Key: eventTypeStatusStarted, Count: 1},
+			{Key: eventTypeStatusPhase, Count: 1},
 			{Key: eventTypeStatusProgress, Count: 3},
 			{Key: eventTypeStatusGeoIPLookup, Count: 1},
 			{Key: eventTypeStatusResolverLookup, Count: 1},
+			{Key: eventTypeStatusPhase, Count: 1},
 			{Key: eventTypeFailureStartup, Count: 1},
 			{Key: eventTypeStatusEnd, Count: 1},
 		}
@@ -408,9 +412,11 @@ func TestTaskRunnerRun(t *testing.T) {
 		expect := []eventKeyCount{
 			{Key: eventTypeStatusQueued, Count: 1},
 			{Key: eventTypeStatusStarted, Count: 1},
+			{Key: eventTypeStatusPhase, Count: 1},
 			{Key: eventTypeStatusProgress, Count: 3},
 			{Key: eventTypeStatusGeoIPLookup, Count: 1},
 			{Key: eventTypeStatusResolverLookup, Count: 1},
+			{Key: eventTypeStatusPhase, Count: 1},
 			{Key: eventTypeFailureReportCreate, Count: 1},
 			{Key: eventTypeStatusEnd, Count: 1},
 		}
@@ -426,15 +432,21 @@ func TestTaskRunnerRun(t *testing.T) {
 		expect := []eventKeyCount{
 			{Key: eventTypeStatusQueued, Count: 1},
 			{Key: eventTypeStatusStarted, Count: 1},
+			{Key: eventTypeStatusPhase, Count: 1},
 			{Key: eventTypeStatusProgress, Count: 3},
 			{Key: eventTypeStatusGeoIPLookup, Count: 1},
 			{Key: eventTypeStatusResolverLookup, Count: 1},
+			{Key: eventTypeStatusPhase, Count: 1},
 			{Key: eventTypeStatusProgress, Count: 1},
 			{Key: eventTypeStatusReportCreate, Count: 1},
+			{Key: eventTypeStatusPhase, Count: 1},
+			{Key: eventTypeStatusInputStart, Count: 1},
 			{Key: eventTypeStatusMeasurementStart, Count: 1},
 			{Key: eventTypeMeasurement, Count: 1},
+			{Key: eventTypeStatusPhase, Count: 1},
 			{Key: eventTypeStatusMeasurementSubmission, Count: 1},
 			{Key: eventTypeStatusMeasurementDone, Count: 1},
+			{Key: eventTypeStatusInputEnd, Count: 1},
 			{Key: eventTypeStatusEnd, Count: 1},
 		}
 		assertReducedEventsLike(t, expect, reduced)
@@ -452,13 +464,18 @@ func TestTaskRunnerRun(t *testing.T) {
 		expect := []eventKeyCount{
 			{Key: eventTypeStatusQueued, Count: 1},
 			{Key: eventTypeStatusStarted, Count: 1},
+			{Key: eventTypeStatusPhase, Count: 1},
 			{Key: eventTypeStatusProgress, Count: 3},
 			{Key: eventTypeStatusGeoIPLookup, Count: 1},
 			{Key: eventTypeStatusResolverLookup, Count: 1},
+			{Key: eventTypeStatusPhase, Count: 1},
 			{Key: eventTypeStatusProgress, Count: 1},
 			{Key: eventTypeStatusReportCreate, Count: 1},
+			{Key: eventTypeStatusPhase, Count: 1},
+			{Key: eventTypeStatusInputStart, Count: 1},
 			{Key: eventTypeStatusMeasurementStart, Count: 1},
 			{Key: eventTypeFailureMeasurement, Count: 1},
+			{Key: eventTypeStatusInputEnd, Count: 1},
 			{Key: eventTypeStatusEnd, Count: 1},
 		}
 		assertReducedEventsLike(t, expect, reduced)
@@ -482,13 +499,18 @@ func TestTaskRunnerRun(t *testing.T) {
 		expect := []eventKeyCount{
 			{Key: eventTypeStatusQueued, Count: 1},
 			{Key: eventTypeStatusStarted, Count: 1},
+			{Key: eventTypeStatusPhase, Count: 1},
 			{Key: eventTypeStatusProgress, Count: 3},
 			{Key: eventTypeStatusGeoIPLookup, Count: 1},
 			{Key: eventTypeStatusResolverLookup, Count: 1},
+			{Key: eventTypeStatusPhase, Count: 1},
 			{Key: eventTypeStatusProgress, Count: 1},
 			{Key: eventTypeStatusReportCreate, Count: 1},
+			{Key: eventTypeStatusPhase, Count: 1},
+			{Key: eventTypeStatusInputStart, Count: 1},
 			{Key: eventTypeStatusMeasurementStart, Count: 1},
 			{Key: eventTypeFailureMeasurement, Count: 1},
+			{Key: eventTypeStatusInputEnd, Count: 1},
 			{Key: eventTypeStatusEnd, Count: 1},
 		}
 		assertReducedEventsLike(t, expect, reduced)
@@ -518,35 +540,53 @@ func TestTaskRunnerRun(t *testing.T) {
 		expect := []eventKeyCount{
 			{Key: eventTypeStatusQueued, Count: 1},
 			{Key: eventTypeStatusStarted, Count: 1},
+			{Key: eventTypeStatusPhase, Count: 1},
 			{Key: eventTypeStatusProgress, Count: 3},
 			{Key: eventTypeStatusGeoIPLookup, Count: 1},
 			{Key: eventTypeStatusResolverLookup, Count: 1},
+			{Key: eventTypeStatusPhase, Count: 1},
 			{Key: eventTypeStatusProgress, Count: 1},
 			{Key: eventTypeStatusReportCreate, Count: 1},
 			//
+			{Key: eventTypeStatusPhase, Count: 1},
+			{Key: eventTypeStatusInputStart, Count: 1},
 			{Key: eventTypeStatusMeasurementStart, Count: 1},
 			{Key: eventTypeStatusProgress, Count: 1},
 			{Key: eventTypeMeasurement, Count: 1},
+			{Key: eventTypeStatusPhase, Count: 1},
 			{Key: eventTypeStatusMeasurementSubmission, Count: 1},
 			{Key: eventTypeStatusMeasurementDone, Count: 1},
+			{Key: eventTypeStatusInputEnd, Count: 1},
 			//
+			{Key: eventTypeStatusPhase, Count: 1},
+			{Key: eventTypeStatusInputStart, Count: 1},
 			{Key: eventTypeStatusMeasurementStart, Count: 1},
 			{Key: eventTypeStatusProgress, Count: 1},
 			{Key: eventTypeMeasurement, Count: 1},
+			{Key: eventTypeStatusPhase, Count: 1},
 			{Key: eventTypeStatusMeasurementSubmission, Count: 1},
 			{Key: eventTypeStatusMeasurementDone, Count: 1},
+			{Key: eventTypeStatusInputEnd, Count: 1},
 			//
+			{Key: eventTypeStatusPhase, Count: 1},
+			{Key: eventTypeStatusInputStart, Count: 1},
 			{Key: eventTypeStatusMeasurementStart, Count: 1},
 			{Key: eventTypeStatusProgress, Count: 1},
 			{Key: eventTypeMeasurement, Count: 1},
+			{Key: eventTypeStatusPhase, Count: 1},
 			{Key: eventTypeStatusMeasurementSubmission, Count: 1},
 			{Key: eventTypeStatusMeasurementDone, Count: 1},
+			{Key: eventTypeStatusInputEnd, Count: 1},
 			//
+			{Key: eventTypeStatusPhase, Count: 1},
+			{Key: eventTypeStatusInputStart, Count: 1},
 			{Key: eventTypeStatusMeasurementStart, Count: 1},
 			{Key: eventTypeStatusProgress, Count: 1},
 			{Key: eventTypeMeasurement, Count: 1},
+			{Key: eventTypeStatusPhase, Count: 1},
 			{Key: eventTypeStatusMeasurementSubmission, Count: 1},
 			{Key: eventTypeStatusMeasurementDone, Count: 1},
+			{Key: eventTypeStatusInputEnd, Count: 1},
 			//
 			{Key: eventTypeStatusEnd, Count: 1},
 		}
@@ -580,23 +620,33 @@ func TestTaskRunnerRun(t *testing.T) {
 		expect := []eventKeyCount{
 			{Key: eventTypeStatusQueued, Count: 1},
 			{Key: eventTypeStatusStarted, Count: 1},
+			{Key: eventTypeStatusPhase, Count: 1},
 			{Key: eventTypeStatusProgress, Count: 3},
 			{Key: eventTypeStatusGeoIPLookup, Count: 1},
 			{Key: eventTypeStatusResolverLookup, Count: 1},
+			{Key: eventTypeStatusPhase, Count: 1},
 			{Key: eventTypeStatusProgress, Count: 1},
 			{Key: eventTypeStatusReportCreate, Count: 1},
 			//
+			{Key: eventTypeStatusPhase, Count: 1},
+			{Key: eventTypeStatusInputStart, Count: 1},
 			{Key: eventTypeStatusMeasurementStart, Count: 1},
 			{Key: eventTypeStatusProgress, Count: 1},
 			{Key: eventTypeMeasurement, Count: 1},
+			{Key: eventTypeStatusPhase, Count: 1},
 			{Key: eventTypeStatusMeasurementSubmission, Count: 1},
 			{Key: eventTypeStatusMeasurementDone, Count: 1},
+			{Key: eventTypeStatusInputEnd, Count: 1},
 			//
+			{Key: eventTypeStatusPhase, Count: 1},
+			{Key: eventTypeStatusInputStart, Count: 1},
 			{Key: eventTypeStatusMeasurementStart, Count: 1},
 			{Key: eventTypeStatusProgress, Count: 1},
 			{Key: eventTypeMeasurement, Count: 1},
+			{Key: eventTypeStatusPhase, Count: 1},
 			{Key: eventTypeStatusMeasurementSubmission, Count: 1},
 			{Key: eventTypeStatusMeasurementDone, Count: 1},
+			{Key: eventTypeStatusInputEnd, Count: 1},
 			//
 			{Key: eventTypeStatusEnd, Count: 1},
 		}
@@ -634,12 +684,16 @@ func TestTaskRunnerRun(t *testing.T) {
 		expect := []eventKeyCount{
 			{Key: eventTypeStatusQueued, Count: 1},
 			{Key: eventTypeStatusStarted, Count: 1},
+			{Key: eventTypeStatusPhase, Count: 1},
 			{Key: eventTypeStatusProgress, Count: 3},
 			{Key: eventTypeStatusGeoIPLookup, Count: 1},
 			{Key: eventTypeStatusResolverLookup, Count: 1},
+			{Key: eventTypeStatusPhase, Count: 1},
 			{Key: eventTypeStatusProgress, Count: 1},
 			{Key: eventTypeStatusReportCreate, Count: 1},
 			//
+			{Key: eventTypeStatusPhase, Count: 1},
+			{Key: eventTypeStatusInputStart, Count: 1},
 			{Key: eventTypeStatusMeasurementStart, Count: 1},
 			{Key: eventTypeStatusProgress, Count: 1},
 			//
@@ -676,17 +730,23 @@ func TestTaskRunnerRun(t *testing.T) {
 		expect := []eventKeyCount{
 			{Key: eventTypeStatusQueued, Count: 1},
 			{Key: eventTypeStatusStarted, Count: 1},
+			{Key: eventTypeStatusPhase, Count: 1},
 			{Key: eventTypeStatusProgress, Count: 3},
 			{Key: eventTypeStatusGeoIPLookup, Count: 1},
 			{Key: eventTypeStatusResolverLookup, Count: 1},
+			{Key: eventTypeStatusPhase, Count: 1},
 			{Key: eventTypeStatusProgress, Count: 1},
 			{Key: eventTypeStatusReportCreate, Count: 1},
 			//
+			{Key: eventTypeStatusPhase, Count: 1},
+			{Key: eventTypeStatusInputStart, Count: 1},
 			{Key: eventTypeStatusMeasurementStart, Count: 1},
 			{Key: eventTypeStatusProgress, Count: 1},
 			{Key: eventTypeMeasurement, Count: 1},
+			{Key: eventTypeStatusPhase, Count: 1},
 			{Key: eventTypeFailureMeasurementSubmission, Count: 1},
 			{Key: eventTypeStatusMeasurementDone, Count: 1},
+			{Key: eventTypeStatusInputEnd, Count: 1},
 			//
 			{Key: eventTypeStatusEnd, Count: 1},
 		}
@@ -710,18 +770,197 @@ func TestTaskRunnerRun(t *testing.T) {
 		expect := []eventKeyCount{
 			{Key: eventTypeStatusQueued, Count: 1},
 			{Key: eventTypeStatusStarted, Count: 1},
+			{Key: eventTypeStatusPhase, Count: 1},
 			{Key: eventTypeStatusProgress, Count: 3},
 			{Key: eventTypeStatusGeoIPLookup, Count: 1},
 			{Key: eventTypeStatusResolverLookup, Count: 1},
+			{Key: eventTypeStatusPhase, Count: 1},
 			{Key: eventTypeStatusProgress, Count: 1},
 			{Key: eventTypeStatusReportCreate, Count: 1},
+			{Key: eventTypeStatusPhase, Count: 1},
+			{Key: eventTypeStatusInputStart, Count: 1},
 			{Key: eventTypeStatusMeasurementStart, Count: 1},
 			{Key: eventTypeStatusProgress, Count: 1},
 			{Key: eventTypeMeasurement, Count: 1},
+			{Key: eventTypeStatusPhase, Count: 1},
 			{Key: eventTypeStatusMeasurementSubmission, Count: 1},
 			{Key: eventTypeStatusMeasurementDone, Count: 1},
+			{Key: eventTypeStatusInputEnd, Count: 1},
 			{Key: eventTypeStatusEnd, Count: 1},
 		}
 		assertReducedEventsLike(t, expect, reduced)
 	})
+
+	// newRunnerWithInputsForTesting is like newRunnerForTesting but the
+	// target loader returns a target for each of the given inputs.
+	newRunnerWithInputsForTesting := func(inputs ...string) (*runnerForTask, *CollectorTaskEmitter, *MockableTaskRunnerDependencies) {
+		runner, emitter := newRunnerForTesting()
+		runner.settings.Inputs = inputs // this is basically ignored because we override MockLoad
+		fake := fakeSuccessfulDeps()
+		fake.Builder.MockNewTargetLoader = func(config *model.ExperimentTargetLoaderConfig) model.ExperimentTargetLoader {
+			return &mocks.ExperimentTargetLoader{
+				MockLoad: func(ctx context.Context) (targets []model.ExperimentTarget, err error) {
+					for _, input := range inputs {
+						targets = append(targets, model.NewOOAPIURLInfoWithDefaultCategoryAndCountry(input))
+					}
+					return
+				},
+			}
+		}
+		runner.newSession = fake.NewSession
+		return runner, emitter, fake
+	}
+
+	t.Run("with phases and input events", func(t *testing.T) {
+		runner, emitter, _ := newRunnerWithInputsForTesting("a", "b")
+		events := runAndCollect(runner, emitter)
+		var (
+			phases []string
+			starts []eventStatusInputStart
+			ends   []eventStatusInputEnd
+		)
+		for _, ev := range events {
+			switch ev.Key {
+			case eventTypeStatusPhase:
+				phases = append(phases, ev.Value.(eventStatusPhase).Phase)
+			case eventTypeStatusInputStart:
+				starts = append(starts, ev.Value.(eventStatusInputStart))
+			case eventTypeStatusInputEnd:
+				ends = append(ends, ev.Value.(eventStatusInputEnd))
+			}
+		}
+		expectPhases := []string{
+			taskPhaseGeolocation,
+			taskPhaseCheckIn,
+			taskPhaseMeasuring,
+			taskPhaseSubmitting,
+			taskPhaseMeasuring,
+			taskPhaseSubmitting,
+		}
+		if diff := cmp.Diff(expectPhases, phases); diff != "" {
+			t.Fatal(diff)
+		}
+		expectStarts := []eventStatusInputStart{{
+			CategoryCode: model.DefaultCategoryCode,
+			CountryCode:  model.DefaultCountryCode,
+			Idx:          0,
+			Input:        "a",
+			Total:        2,
+		}, {
+			CategoryCode: model.DefaultCategoryCode,
+			CountryCode:  model.DefaultCountryCode,
+			Idx:          1,
+			Input:        "b",
+			Total:        2,
+		}}
+		if diff := cmp.Diff(expectStarts, starts); diff != "" {
+			t.Fatal(diff)
+		}
+		if len(ends) != 2 {
+			t.Fatal("expected two input_end events")
+		}
+		for idx, ev := range ends {
+			if ev.Idx != int64(idx) || ev.Input != []string{"a", "b"}[idx] || ev.Total != 2 {
+				t.Fatal("unexpected event", ev)
+			}
+			if ev.Failure != "" || ev.Runtime < 0 {
+				t.Fatal("unexpected event", ev)
+			}
+			if ev.DownloadedKB != 10 || ev.UploadedKB != 4 {
+				t.Fatal("unexpected bytes count", ev)
+			}
+		}
+		if ends[1].ETA != 0 {
+			t.Fatal("expected zero ETA after the last input")
+		}
+	})
+
+	t.Run("with measurement failure and input_end event", func(t *testing.T) {
+		runner, emitter, fake := newRunnerWithInputsForTesting("a")
+		fake.Experiment.MockMeasureWithContext = func(ctx context.Context, target model.ExperimentTarget) (*model.Measurement, error) {
+			return nil, errors.New("mocked error")
+		}
+		events := runAndCollect(runner, emitter)
+		var failures []string
+		for _, ev := range events {
+			if ev.Key == eventTypeStatusInputEnd {
+				failures = append(failures, ev.Value.(eventStatusInputEnd).Failure)
+			}
+		}
+		if diff := cmp.Diff([]string{"mocked error"}, failures); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("with pause and resume", func(t *testing.T) {
+		runner, emitter, fake := newRunnerWithInputsForTesting("a", "b")
+		fake.Experiment.MockMeasureWithContext = func(ctx context.Context, target model.ExperimentTarget) (*model.Measurement, error) {
+			if target.Input() == "a" {
+				runner.Pause()
+				go func() {
+					time.Sleep(100 * time.Millisecond)
+					runner.Resume()
+				}()
+			}
+			return &model.Measurement{}, nil
+		}
+		events := runAndCollect(runner, emitter)
+		reduced := reduceEventsKeysIgnoreLog(t, events)
+		expect := []eventKeyCount{
+			{Key: eventTypeStatusQueued, Count: 1},
+			{Key: eventTypeStatusStarted, Count: 1},
+			{Key: eventTypeStatusPhase, Count: 1},
+			{Key: eventTypeStatusProgress, Count: 3},
+			{Key: eventTypeStatusGeoIPLookup, Count: 1},
+			{Key: eventTypeStatusResolverLookup, Count: 1},
+			{Key: eventTypeStatusPhase, Count: 1},
+			{Key: eventTypeStatusProgress, Count: 1},
+			{Key: eventTypeStatusReportCreate, Count: 1},
+			//
+			{Key: eventTypeStatusPhase, Count: 1},
+			{Key: eventTypeStatusInputStart, Count: 1},
+			{Key: eventTypeStatusMeasurementStart, Count: 1},
+			{Key: eventTypeStatusProgress, Count: 1},
+			{Key: eventTypeMeasurement, Count: 1},
+			{Key: eventTypeStatusPhase, Count: 1},
+			{Key: eventTypeStatusMeasurementSubmission, Count: 1},
+			{Key: eventTypeStatusMeasurementDone, Count: 1},
+			{Key: eventTypeStatusInputEnd, Count: 1},
+			//
+			{Key: eventTypeStatusPaused, Count: 1},
+			{Key: eventTypeStatusResumed, Count: 1},
+			//
+			{Key: eventTypeStatusPhase, Count: 1},
+			{Key: eventTypeStatusInputStart, Count: 1},
+			{Key: eventTypeStatusMeasurementStart, Count: 1},
+			{Key: eventTypeStatusProgress, Count: 1},
+			{Key: eventTypeMeasurement, Count: 1},
+			{Key: eventTypeStatusPhase, Count: 1},
+			{Key: eventTypeStatusMeasurementSubmission, Count: 1},
+			{Key: eventTypeStatusMeasurementDone, Count: 1},
+			{Key: eventTypeStatusInputEnd, Count: 1},
+			//
+			{Key: eventTypeStatusEnd, Count: 1},
+		}
+		assertReducedEventsLike(t, expect, reduced)
+	})
+
+	t.Run("with interrupt while paused", func(t *testing.T) {
+		runner, emitter, fake := newRunnerWithInputsForTesting("a", "b")
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		fake.Experiment.MockMeasureWithContext = func(ctx context.Context, target model.ExperimentTarget) (*model.Measurement, error) {
+			runner.Pause()
+			go func() {
+				time.Sleep(100 * time.Millisecond)
+				cancel()
+			}()
+			return &model.Measurement{}, nil
+		}
+		events := runAndCollectContext(ctx, runner, emitter)
+		assertCountEventsByKey(events, eventTypeStatusInputEnd, 1)
+		assertCountEventsByKey(events, eventTypeStatusPaused, 1)
+		assertCountEventsByKey(events, eventTypeStatusResumed, 0)
+		assertCountEventsByKey(events, eventTypeStatusEnd, 1)
+	})
 }